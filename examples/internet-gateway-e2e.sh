@@ -43,25 +43,32 @@ if [[ "${state:-}" != "active" ]]; then
   exit 1
 fi
 
+echo "==> Discover the tenant's network endpoint"
+NETWORK_URL="$(curl -fsS "$BASE/v1/tenants/$TENANT/.wellknown/secapi" | jq -r '.endpoints[] | select(.provider == "seca.network/v1") | .url')"
+if [[ -z "$NETWORK_URL" ]]; then
+  echo "tenant $TENANT is not entitled to seca.network/v1" >&2
+  exit 1
+fi
+
 echo "==> Create network"
-curl -fsS -X PUT "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/networks/$NET" \
+curl -fsS -X PUT "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/networks/$NET" \
   -H 'content-type: application/json' \
   -d "{\"metadata\":{\"name\":\"$NET\",\"tenant\":\"$TENANT\",\"workspace\":\"$WS\",\"region\":\"$REGION\"},\"spec\":{\"cidr\":{\"ipv4\":\"10.10.0.0/16\"},\"routeTableRef\":\"route-tables/$RT\",\"skuRef\":\"skus/hcloud-network\"}}" | jq
 
 echo "==> Create internet gateway"
-curl -fsS -X PUT "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/internet-gateways/$IGW" \
+curl -fsS -X PUT "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/internet-gateways/$IGW" \
   -H 'content-type: application/json' \
   -d "{\"metadata\":{\"name\":\"$IGW\",\"tenant\":\"$TENANT\",\"workspace\":\"$WS\",\"region\":\"$REGION\"},\"spec\":{\"egressOnly\":true}}" | jq
 
 echo "==> Attach route-table default route to internet gateway"
-curl -fsS -X PUT "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/networks/$NET/route-tables/$RT" \
+curl -fsS -X PUT "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/networks/$NET/route-tables/$RT" \
   -H 'content-type: application/json' \
   -d "{\"metadata\":{\"name\":\"$RT\",\"tenant\":\"$TENANT\",\"workspace\":\"$WS\",\"network\":\"$NET\",\"region\":\"$REGION\"},\"spec\":{\"routes\":[{\"destinationCidrBlock\":\"0.0.0.0/0\",\"targetRef\":\"internet-gateways/$IGW\"}]}}" | jq
 
 echo "==> Show resources"
-curl -fsS "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/networks/$NET" | jq
-curl -fsS "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/internet-gateways/$IGW" | jq
-curl -fsS "$BASE/network/v1/tenants/$TENANT/workspaces/$WS/networks/$NET/route-tables/$RT" | jq
+curl -fsS "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/networks/$NET" | jq
+curl -fsS "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/internet-gateways/$IGW" | jq
+curl -fsS "$NETWORK_URL/tenants/$TENANT/workspaces/$WS/networks/$NET/route-tables/$RT" | jq
 
 echo "Done. Optional provider checks:"
 echo "  hcloud server list -o columns=id,name,labels | grep \"seca.kind=internet-gateway\""
@@ -26,6 +26,8 @@ SECA_ADMIN_BASE_URL="${SECA_ADMIN_BASE_URL:-http://127.0.0.1:8081}"
 SECA_TENANTS="${SECA_TENANTS:-dev}"
 SECA_TOKEN_PROVISIONER_INTERVAL="${SECA_TOKEN_PROVISIONER_INTERVAL:-1}"
 
+SECA_PUBLIC_TOKEN="${SECA_PUBLIC_TOKEN:-}"
+
 log() {
   printf '[token-provisioner] %s\n' "$*" >&2
 }
@@ -71,6 +73,16 @@ bind_workspace() {
   return 1
 }
 
+register_public_token() {
+  local tenant="$1"
+  local url="${SECA_ADMIN_BASE_URL}/admin/v1/tenants/${tenant}/tokens/token-provisioner"
+
+  curl -fsS -o /dev/null -X PUT "$url" \
+    -H "authorization: Bearer ${SECA_ADMIN_TOKEN}" \
+    -H "content-type: application/json" \
+    --data "$(jq -nc --arg token "$SECA_PUBLIC_TOKEN" '{token:$token}')"
+}
+
 poll_once() {
   local payload tenant url list workspaces workspace
   payload="$(build_payload)"
@@ -82,7 +94,7 @@ poll_once() {
     [ -n "$tenant" ] || continue
 
     url="${SECA_PUBLIC_BASE_URL}/workspace/v1/tenants/${tenant}/workspaces"
-    if ! list="$(curl -fsS "$url" 2>/dev/null)"; then
+    if ! list="$(curl -fsS -H "authorization: Bearer ${SECA_PUBLIC_TOKEN}" "$url" 2>/dev/null)"; then
       log "list failed tenant=${tenant}"
       continue
     fi
@@ -102,6 +114,17 @@ EOF
   IFS="$OLDIFS"
 }
 
+if [ -n "$SECA_PUBLIC_TOKEN" ]; then
+  OLDIFS="$IFS"
+  IFS=','
+  for tenant in $SECA_TENANTS; do
+    tenant="$(printf '%s' "$tenant" | xargs)"
+    [ -n "$tenant" ] || continue
+    register_public_token "$tenant" || log "token registration failed tenant=${tenant}"
+  done
+  IFS="$OLDIFS"
+fi
+
 log "starting (public=${SECA_PUBLIC_BASE_URL} admin=${SECA_ADMIN_BASE_URL} tenants=${SECA_TENANTS} interval=${SECA_TOKEN_PROVISIONER_INTERVAL}s)"
 while true; do
   poll_once
@@ -4,62 +4,239 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/httpserver"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/metrics"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/fake"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/reconciler"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
 )
 
 func main() {
-	cfg := config.Load()
-	if cfg.AdminToken == "" {
-		log.Fatal("SECA_ADMIN_TOKEN must be configured")
-	}
-	if cfg.CredentialsKey == "" {
-		log.Fatal("SECA_CREDENTIALS_KEY must be configured")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
+	log.Printf("effective configuration: %s", cfg.Redacted())
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	store, err := state.New(ctx, cfg.DatabaseURL, cfg.CredentialsKey)
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		log.Fatalf("tracing init failed: %v", err)
+	}
+
+	store, err := state.New(ctx, cfg.DatabaseURL, cfg.CredentialsKey, state.PoolConfig{
+		MaxConns:         int32(cfg.DBMaxConns),
+		MinConns:         int32(cfg.DBMinConns),
+		AcquireTimeout:   cfg.DBAcquireTimeout,
+		StatementTimeout: cfg.DBStatementTimeout,
+	})
 	if err != nil {
 		log.Fatalf("db init failed: %v", err)
 	}
 	defer store.Close()
+	if tracing.Enabled() {
+		store.TraceQueries()
+	}
 
-	regionService := hetzner.NewRegionService(cfg)
-	servers := httpserver.New(cfg, store, regionService, regionService, regionService, regionService)
-	log.Printf("runtime mode: conformance=%t (SECA_CONFORMANCE_MODE)", cfg.ConformanceMode)
-	log.Printf("runtime mode: internet_gateway_nat_vm=%t (SECA_INTERNET_GATEWAY_NAT_VM)", cfg.InternetGatewayNATVM)
+	var serviceMetrics *metrics.Metrics
+	var hetznerCalls hetzner.CallObserver
+	if cfg.Metrics {
+		serviceMetrics = metrics.New()
+		hetznerCalls = serviceMetrics
+	}
 
-	go func() {
-		log.Printf("starting secapi-proxy-hetzner public api on %s", cfg.ListenAddr)
-		if err := servers.Public.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("public http server failed: %v", err)
+	var cloud provider
+	if cfg.FakeProvider {
+		cloud = fake.New()
+	} else {
+		regionService := hetzner.NewRegionService(cfg, hetznerCalls, hetznerAudit{store: store})
+		// A global token set through the admin API outlives restarts and
+		// takes precedence over SECA_HETZNER_TOKEN.
+		if cred, err := store.GetGlobalProviderCredential(ctx, "hetzner"); err != nil {
+			log.Printf("global hetzner credential not loaded, using SECA_HETZNER_TOKEN: %v", err)
+		} else if cred != nil {
+			regionService.SetGlobalToken(cred.APIToken)
 		}
-	}()
-	go func() {
-		log.Printf("starting secapi-proxy-hetzner admin api on %s", cfg.AdminListenAddr)
-		if err := servers.Admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("admin http server failed: %v", err)
+		if cfg.StartupWarmup {
+			go func() {
+				status := regionService.Warmup(ctx, cfg.StartupWarmupTimeout)
+				if status.State == hetzner.WarmupStateFailed {
+					log.Printf("provider warm-up incomplete, falling back to lazy fetch: %v", status.Errors)
+					return
+				}
+				log.Printf("provider warm-up finished in %s", status.FinishedAt.Sub(status.StartedAt).Round(time.Millisecond))
+			}()
+		} else {
+			regionService.DisableWarmup()
 		}
-	}()
+		cloud = regionService
+	}
+	// The reconcilers stop with ctx; shutdown waits for their current pass
+	// before the store is closed.
+	var reconcilers sync.WaitGroup
+	runReconciler := func(run func(context.Context)) {
+		reconcilers.Add(1)
+		go func() {
+			defer reconcilers.Done()
+			run(ctx)
+		}()
+	}
+	if cfg.OperationReconcile > 0 {
+		runReconciler(reconciler.NewOperations(store, cloud, cfg.OperationReconcile, cfg.OperationRetention).Run)
+	}
+	if cfg.BindingReconcile > 0 {
+		runReconciler(reconciler.NewBindings(store, cloud, cfg.BindingReconcile).Run)
+	}
+	if cfg.SoftDeleteRetention > 0 {
+		runReconciler(reconciler.NewSoftDeletes(store, time.Hour, cfg.SoftDeleteRetention).Run)
+	}
+	servers := httpserver.New(cfg, store, cloud, cloud, cloud, cloud, serviceMetrics)
+	// Provider work started by requests counts as in flight until it is done,
+	// so shutdown can wait for it past the end of the request.
+	drainer := hetzner.NewDrainer()
+	for _, server := range []*http.Server{servers.Public, servers.Admin} {
+		server.BaseContext = func(net.Listener) context.Context {
+			return hetzner.WithDrainer(context.Background(), drainer)
+		}
+	}
+	var reloaders []*httpserver.CertificateReloader
+	if cfg.TLSCertFile != "" {
+		reloader, err := httpserver.ConfigureTLS(servers.Public, cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("public tls init failed: %v", err)
+		}
+		reloaders = append(reloaders, reloader)
+	}
+	if cfg.AdminTLSCertFile != "" {
+		reloader, err := httpserver.ConfigureTLS(servers.Admin, cfg.AdminTLSCertFile, cfg.AdminTLSKeyFile)
+		if err != nil {
+			log.Fatalf("admin tls init failed: %v", err)
+		}
+		reloaders = append(reloaders, reloader)
+	}
+	if len(reloaders) > 0 {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					signal.Stop(hangup)
+					return
+				case <-hangup:
+					failed := false
+					for _, reloader := range reloaders {
+						if err := reloader.Reload(); err != nil {
+							log.Printf("tls certificate reload failed, keeping the previous certificate: %v", err)
+							failed = true
+						}
+					}
+					if !failed {
+						log.Printf("tls certificate reload done")
+					}
+				}
+			}
+		}()
+	}
+	log.Printf("runtime mode: conformance=%t (SECA_CONFORMANCE_MODE) flags: %s", cfg.ConformanceMode, cfg.ConformanceFlags)
+	log.Printf("runtime mode: internet_gateway_nat_vm=%t (SECA_INTERNET_GATEWAY_NAT_VM)", cfg.InternetGatewayNATVM)
+	log.Printf("runtime mode: startup_warmup=%t (SECA_STARTUP_WARMUP)", cfg.StartupWarmup)
+	log.Printf("runtime mode: operation_reconcile=%s (SECA_OPERATION_RECONCILE_INTERVAL)", cfg.OperationReconcile)
+	log.Printf("runtime mode: binding_reconcile=%s (SECA_BINDING_RECONCILE_INTERVAL)", cfg.BindingReconcile)
+	log.Printf("runtime mode: fault_injection=%t (SECA_FAULT_INJECTION)", cfg.FaultInjection)
+	log.Printf("runtime mode: fake_provider=%t (SECA_FAKE_PROVIDER)", cfg.FakeProvider)
+	log.Printf("runtime mode: metrics=%t (SECA_METRICS)", cfg.Metrics)
+	log.Printf("runtime mode: tracing=%t (OTEL_EXPORTER_OTLP_ENDPOINT)", tracing.Enabled())
+
+	go serve("public", servers.Public)
+	go serve("admin", servers.Admin)
 
 	<-ctx.Done()
 	stop()
+	log.Printf("shutting down, waiting up to %s for in-flight requests and provider operations", cfg.ShutdownTimeout)
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Both servers and the in-flight provider work share one deadline.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
-	if err := servers.Public.Shutdown(shutdownCtx); err != nil {
-		log.Printf("public graceful shutdown failed: %v", err)
+	var shutdown sync.WaitGroup
+	for name, server := range map[string]*http.Server{"public": servers.Public, "admin": servers.Admin} {
+		shutdown.Add(1)
+		go func() {
+			defer shutdown.Done()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Printf("%s graceful shutdown failed: %v", name, err)
+			}
+		}()
+	}
+	if !drainer.Drain(shutdownCtx) {
+		log.Printf("provider operations still running after %s were interrupted", cfg.ShutdownTimeout)
+	}
+	shutdown.Wait()
+	reconcilers.Wait()
+	// Flush the spans of the last requests; the export gets its own short
+	// deadline since shutdownCtx may already be used up.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("flushing traces failed: %v", err)
+	}
+}
+
+// provider is what the servers and reconcilers need from the cloud: the
+// Hetzner region service, or the in-memory fake for local development.
+type provider interface {
+	httpserver.RegionProvider
+	httpserver.CatalogProvider
+	httpserver.ComputeStorageProvider
+	httpserver.NetworkProvider
+	reconciler.ActionProvider
+	reconciler.Inventory
+}
+
+// serve runs the server until it is shut down, over TLS when ConfigureTLS
+// has set it up.
+func serve(name string, server *http.Server) {
+	var err error
+	if server.TLSConfig != nil {
+		log.Printf("starting secapi-proxy-hetzner %s api on %s (tls)", name, server.Addr)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("starting secapi-proxy-hetzner %s api on %s", name, server.Addr)
+		err = server.ListenAndServe()
 	}
-	if err := servers.Admin.Shutdown(shutdownCtx); err != nil {
-		log.Printf("admin graceful shutdown failed: %v", err)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("%s http server failed: %v", name, err)
 	}
 }
+
+// hetznerAudit records the mutating Hetzner calls in the state store.
+type hetznerAudit struct {
+	store *state.Store
+}
+
+func (a hetznerAudit) RecordHetznerCall(ctx context.Context, entry hetzner.AuditEntry) error {
+	return a.store.AppendAuditEntry(ctx, state.AuditEntry{
+		Time:       entry.Time,
+		Tenant:     entry.Tenant,
+		Workspace:  entry.Workspace,
+		Kind:       entry.Kind,
+		Operation:  entry.Operation,
+		Resource:   entry.Resource,
+		ActionID:   entry.ActionID,
+		StatusCode: entry.StatusCode,
+		Error:      entry.Error,
+	})
+}
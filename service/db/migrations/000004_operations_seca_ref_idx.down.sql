@@ -0,0 +1 @@
+DROP INDEX IF EXISTS operations_seca_ref_created_idx;
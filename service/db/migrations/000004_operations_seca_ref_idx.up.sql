@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS operations_seca_ref_created_idx
+  ON operations (seca_ref, created_at);
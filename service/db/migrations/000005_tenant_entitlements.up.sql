@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS tenant_entitlements (
+  id BIGSERIAL PRIMARY KEY,
+  tenant TEXT NOT NULL UNIQUE,
+  providers JSONB NOT NULL DEFAULT '[]'::jsonb,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
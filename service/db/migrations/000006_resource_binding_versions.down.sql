@@ -0,0 +1,2 @@
+ALTER TABLE resource_bindings
+  DROP COLUMN IF EXISTS resource_version;
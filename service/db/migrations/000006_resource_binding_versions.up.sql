@@ -0,0 +1,2 @@
+ALTER TABLE resource_bindings
+  ADD COLUMN IF NOT EXISTS resource_version BIGINT NOT NULL DEFAULT 1;
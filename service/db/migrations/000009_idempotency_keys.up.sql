@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  tenant TEXT NOT NULL,
+  idempotency_key TEXT NOT NULL,
+  request_hash TEXT NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  response_header JSONB NOT NULL DEFAULT '{}'::jsonb,
+  response_body BYTEA,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (tenant, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_tenant_expires_idx
+  ON idempotency_keys (tenant, expires_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS auth_role_assignments_roles_idx;
+DROP INDEX IF EXISTS auth_role_assignments_subs_idx;
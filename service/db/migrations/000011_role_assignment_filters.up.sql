@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS auth_role_assignments_subs_idx
+  ON auth_role_assignments USING GIN ((spec -> 'subs'))
+  WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS auth_role_assignments_roles_idx
+  ON auth_role_assignments USING GIN ((spec -> 'roles'))
+  WHERE deleted_at IS NULL;
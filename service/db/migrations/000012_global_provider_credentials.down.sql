@@ -0,0 +1 @@
+DROP TABLE IF EXISTS global_provider_credentials;
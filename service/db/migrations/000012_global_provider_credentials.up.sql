@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS global_provider_credentials (
+  provider TEXT PRIMARY KEY,
+  api_token_encrypted TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  rotated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,24 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO audit_log (
+  tenant,
+  workspace,
+  kind,
+  operation,
+  resource,
+  action_id,
+  status_code,
+  error_text,
+  created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+);
+
+-- name: ListAuditLogEntries :many
+SELECT *
+FROM audit_log
+WHERE (sqlc.arg(tenant)::text = '' OR tenant = sqlc.arg(tenant)::text)
+  AND (sqlc.arg(kind)::text = '' OR kind = sqlc.arg(kind)::text)
+  AND created_at >= sqlc.arg(since)::timestamptz
+  AND (sqlc.arg(before_id)::bigint = 0 OR id < sqlc.arg(before_id)::bigint)
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
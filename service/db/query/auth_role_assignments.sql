@@ -12,6 +12,8 @@ SET
   resource_version = auth_role_assignments.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE sqlc.arg(expected_version)::bigint = 0
+  OR auth_role_assignments.resource_version = sqlc.arg(expected_version)::bigint
 RETURNING *;
 
 -- name: GetAuthRoleAssignment :one
@@ -28,6 +30,15 @@ WHERE tenant = $1
   AND deleted_at IS NULL
 ORDER BY name;
 
+-- name: ListAuthRoleAssignmentsMatching :many
+SELECT *
+FROM auth_role_assignments
+WHERE tenant = sqlc.arg(tenant)
+  AND deleted_at IS NULL
+  AND (sqlc.arg(subject)::text = '' OR spec -> 'subs' ? sqlc.arg(subject)::text)
+  AND (cardinality(sqlc.arg(roles)::text[]) = 0 OR spec -> 'roles' ?| sqlc.arg(roles)::text[])
+ORDER BY name;
+
 -- name: SoftDeleteAuthRoleAssignment :execrows
 UPDATE auth_role_assignments
 SET
@@ -36,4 +47,27 @@ SET
   updated_at = NOW()
 WHERE tenant = $1
   AND name = $2
-  AND deleted_at IS NULL;
+  AND deleted_at IS NULL
+  AND (sqlc.arg(expected_version)::bigint = 0 OR resource_version = sqlc.arg(expected_version)::bigint);
+
+-- name: GetAuthRoleAssignmentDeletedAt :one
+SELECT deleted_at
+FROM auth_role_assignments
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1;
+
+-- name: PurgeDeletedAuthRoleAssignments :execrows
+DELETE FROM auth_role_assignments
+WHERE deleted_at < $1;
+
+-- name: RestoreAuthRoleAssignment :one
+UPDATE auth_role_assignments
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= sqlc.arg(deleted_after)::timestamptz
+RETURNING *;
@@ -12,6 +12,8 @@ SET
   resource_version = auth_roles.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE sqlc.arg(expected_version)::bigint = 0
+  OR auth_roles.resource_version = sqlc.arg(expected_version)::bigint
 RETURNING *;
 
 -- name: GetAuthRole :one
@@ -36,4 +38,27 @@ SET
   updated_at = NOW()
 WHERE tenant = $1
   AND name = $2
-  AND deleted_at IS NULL;
+  AND deleted_at IS NULL
+  AND (sqlc.arg(expected_version)::bigint = 0 OR resource_version = sqlc.arg(expected_version)::bigint);
+
+-- name: GetAuthRoleDeletedAt :one
+SELECT deleted_at
+FROM auth_roles
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1;
+
+-- name: PurgeDeletedAuthRoles :execrows
+DELETE FROM auth_roles
+WHERE deleted_at < $1;
+
+-- name: RestoreAuthRole :one
+UPDATE auth_roles
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= sqlc.arg(deleted_after)::timestamptz
+RETURNING *;
@@ -0,0 +1,31 @@
+-- name: UpsertGlobalProviderCredential :one
+INSERT INTO global_provider_credentials (
+  provider, api_token_encrypted
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (provider) DO UPDATE SET
+  api_token_encrypted = EXCLUDED.api_token_encrypted,
+  rotated_at = NOW()
+RETURNING *;
+
+-- name: GetGlobalProviderCredential :one
+SELECT *
+FROM global_provider_credentials
+WHERE provider = $1
+LIMIT 1;
+
+-- name: DeleteGlobalProviderCredential :execrows
+DELETE FROM global_provider_credentials
+WHERE provider = $1;
+
+-- name: ListGlobalProviderCredentials :many
+SELECT *
+FROM global_provider_credentials
+ORDER BY provider;
+
+-- name: ReencryptGlobalProviderCredentialToken :execrows
+UPDATE global_provider_credentials
+SET api_token_encrypted = sqlc.arg(new_token)
+WHERE provider = sqlc.arg(provider)
+  AND api_token_encrypted = sqlc.arg(old_token);
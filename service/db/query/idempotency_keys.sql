@@ -0,0 +1,36 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  tenant,
+  idempotency_key,
+  request_hash,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (tenant, idempotency_key) DO NOTHING
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT *
+FROM idempotency_keys
+WHERE tenant = $1
+  AND idempotency_key = $2
+LIMIT 1;
+
+-- name: CompleteIdempotencyKey :execrows
+UPDATE idempotency_keys
+SET status_code = $3,
+    response_header = $4,
+    response_body = $5
+WHERE tenant = $1
+  AND idempotency_key = $2;
+
+-- name: DeleteIdempotencyKey :execrows
+DELETE FROM idempotency_keys
+WHERE tenant = $1
+  AND idempotency_key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE tenant = $1
+  AND expires_at < NOW();
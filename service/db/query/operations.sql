@@ -14,5 +14,50 @@ WHERE operation_id = $1;
 -- name: ListOperationsBySecaRef :many
 SELECT *
 FROM operations
-WHERE seca_ref = $1
-ORDER BY created_at DESC;
+WHERE seca_ref = sqlc.arg(seca_ref)
+  AND (sqlc.arg(phase)::text = '' OR phase = sqlc.arg(phase)::text)
+  AND (sqlc.arg(before_id)::bigint = 0 OR id < sqlc.arg(before_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: CreateOperations :batchone
+INSERT INTO operations (
+  operation_id, seca_ref, provider_action_id, phase, error_text
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+ON CONFLICT (operation_id) DO NOTHING
+RETURNING operation_id;
+
+-- name: CountActiveOperationsByPhase :many
+SELECT phase, count(*) AS count
+FROM operations
+WHERE phase NOT IN ('succeeded', 'failed')
+GROUP BY phase;
+
+-- name: ListPendingOperations :many
+SELECT *
+FROM operations
+WHERE phase NOT IN ('succeeded', 'failed')
+  AND provider_action_id IS NOT NULL
+ORDER BY updated_at
+LIMIT $1;
+
+-- name: UpdateOperationPhase :execrows
+UPDATE operations
+SET phase = $2,
+    error_text = $3,
+    updated_at = NOW()
+WHERE operation_id = $1;
+
+-- name: UpdateOperationProviderAction :execrows
+UPDATE operations
+SET provider_action_id = $2,
+    phase = $3,
+    updated_at = NOW()
+WHERE operation_id = $1;
+
+-- name: DeleteCompletedOperationsBefore :execrows
+DELETE FROM operations
+WHERE phase IN ('succeeded', 'failed')
+  AND updated_at < $1;
@@ -16,7 +16,66 @@ ON CONFLICT (seca_ref) DO UPDATE
 SET
   provider_ref = EXCLUDED.provider_ref,
   status = EXCLUDED.status,
+  resource_version = resource_bindings.resource_version + 1,
   updated_at = NOW()
+WHERE sqlc.arg(expected_version)::bigint = 0
+  OR resource_bindings.resource_version = sqlc.arg(expected_version)::bigint
+RETURNING *;
+
+-- name: SyncResourceBinding :one
+INSERT INTO resource_bindings (
+  tenant, workspace, kind, seca_ref, provider_ref, status
+) VALUES (
+  $1, $2, $3, $4, $5,
+  CASE
+    WHEN $6 = 'active' AND EXISTS (
+      SELECT 1 FROM resource_bindings WHERE seca_ref = $4 AND status = 'error'
+    ) THEN 'error'
+    ELSE $6
+  END
+)
+ON CONFLICT (seca_ref) DO UPDATE
+SET
+  provider_ref = EXCLUDED.provider_ref,
+  status = EXCLUDED.status,
+  resource_version = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN resource_bindings.resource_version + 1
+    ELSE resource_bindings.resource_version
+  END,
+  updated_at = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN NOW()
+    ELSE resource_bindings.updated_at
+  END
+RETURNING *;
+
+-- name: SyncResourceBindings :batchone
+INSERT INTO resource_bindings (
+  tenant, workspace, kind, seca_ref, provider_ref, status
+) VALUES (
+  $1, $2, $3, $4, $5,
+  CASE
+    WHEN $6 = 'active' AND EXISTS (
+      SELECT 1 FROM resource_bindings WHERE seca_ref = $4 AND status = 'error'
+    ) THEN 'error'
+    ELSE $6
+  END
+)
+ON CONFLICT (seca_ref) DO UPDATE
+SET
+  provider_ref = EXCLUDED.provider_ref,
+  status = EXCLUDED.status,
+  resource_version = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN resource_bindings.resource_version + 1
+    ELSE resource_bindings.resource_version
+  END,
+  updated_at = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN NOW()
+    ELSE resource_bindings.updated_at
+  END
 RETURNING *;
 
 -- name: GetResourceBindingBySecaRef :one
@@ -32,6 +91,50 @@ WHERE tenant = $1
   AND kind = $3
 ORDER BY seca_ref;
 
+-- name: ListResourceBindingsByScope :many
+SELECT *
+FROM resource_bindings
+WHERE tenant = $1
+  AND workspace = $2
+ORDER BY seca_ref;
+
+-- name: CountResourceBindingsByTenant :many
+SELECT workspace, kind, COUNT(*) AS count
+FROM resource_bindings
+WHERE tenant = $1
+GROUP BY workspace, kind
+ORDER BY workspace, kind;
+
+-- name: ListResourceBindingsByKinds :many
+SELECT *
+FROM resource_bindings
+WHERE kind = ANY(sqlc.arg(kinds)::text[])
+ORDER BY tenant, workspace, seca_ref;
+
+-- name: UpdateResourceBindingStatus :execrows
+UPDATE resource_bindings
+SET
+  status = $2,
+  updated_at = NOW()
+WHERE seca_ref = $1;
+
+-- name: ListResourceBindingsByTenantAndKind :many
+SELECT *
+FROM resource_bindings
+WHERE tenant = $1
+  AND kind = $2
+ORDER BY seca_ref;
+
 -- name: DeleteResourceBindingBySecaRef :exec
 DELETE FROM resource_bindings
 WHERE seca_ref = $1;
+
+-- name: DeleteResourceBindingIfStatus :execrows
+DELETE FROM resource_bindings
+WHERE seca_ref = $1
+  AND status = $2;
+
+-- name: DeleteResourceBindingIfVersion :execrows
+DELETE FROM resource_bindings
+WHERE seca_ref = $1
+  AND resource_version = $2;
@@ -0,0 +1,27 @@
+-- name: UpsertTenantAPIToken :one
+INSERT INTO tenant_api_tokens (
+  tenant, name, token_hash
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (tenant, name) DO UPDATE SET
+  token_hash = EXCLUDED.token_hash,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetTenantAPITokenByHash :one
+SELECT *
+FROM tenant_api_tokens
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: ListTenantAPITokens :many
+SELECT *
+FROM tenant_api_tokens
+WHERE tenant = $1
+ORDER BY name;
+
+-- name: DeleteTenantAPIToken :execrows
+DELETE FROM tenant_api_tokens
+WHERE tenant = $1
+  AND name = $2;
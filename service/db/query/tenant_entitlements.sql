@@ -0,0 +1,20 @@
+-- name: UpsertTenantEntitlements :one
+INSERT INTO tenant_entitlements (
+  tenant, providers
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (tenant) DO UPDATE SET
+  providers = EXCLUDED.providers,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetTenantEntitlements :one
+SELECT *
+FROM tenant_entitlements
+WHERE tenant = $1
+LIMIT 1;
+
+-- name: DeleteTenantEntitlements :execrows
+DELETE FROM tenant_entitlements
+WHERE tenant = $1;
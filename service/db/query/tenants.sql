@@ -0,0 +1,24 @@
+-- name: UpsertTenant :one
+INSERT INTO tenants (
+  name
+) VALUES (
+  $1
+)
+ON CONFLICT (name) DO UPDATE SET
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetTenant :one
+SELECT *
+FROM tenants
+WHERE name = $1
+LIMIT 1;
+
+-- name: ListTenants :many
+SELECT *
+FROM tenants
+ORDER BY name;
+
+-- name: DeleteTenant :execrows
+DELETE FROM tenants
+WHERE name = $1;
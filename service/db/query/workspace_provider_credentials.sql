@@ -21,6 +21,23 @@ WHERE tenant = $1
   AND deleted_at IS NULL
 LIMIT 1;
 
+-- name: ListWorkspaceProviderCredentialsByTenant :many
+SELECT *
+FROM workspace_provider_credentials
+WHERE tenant = $1
+  AND deleted_at IS NULL
+ORDER BY workspace, provider;
+
+-- name: RotateWorkspaceProviderCredentialToken :one
+UPDATE workspace_provider_credentials
+SET api_token_encrypted = $4,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND workspace = $2
+  AND provider = $3
+  AND deleted_at IS NULL
+RETURNING *;
+
 -- name: SoftDeleteWorkspaceProviderCredential :execrows
 UPDATE workspace_provider_credentials
 SET deleted_at = NOW(),
@@ -29,3 +46,20 @@ WHERE tenant = $1
   AND workspace = $2
   AND provider = $3
   AND deleted_at IS NULL;
+
+-- name: ListAllWorkspaceProviderCredentials :many
+SELECT *
+FROM workspace_provider_credentials
+WHERE deleted_at IS NULL
+ORDER BY tenant, workspace, provider;
+
+-- name: ReencryptWorkspaceProviderCredentialToken :execrows
+UPDATE workspace_provider_credentials
+SET api_token_encrypted = sqlc.arg(new_token)
+WHERE id = sqlc.arg(id)
+  AND api_token_encrypted = sqlc.arg(old_token)
+  AND deleted_at IS NULL;
+
+-- name: PurgeDeletedWorkspaceProviderCredentials :execrows
+DELETE FROM workspace_provider_credentials
+WHERE deleted_at < $1;
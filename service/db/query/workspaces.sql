@@ -12,6 +12,8 @@ ON CONFLICT (tenant, name) DO UPDATE SET
   resource_version = workspaces.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE sqlc.arg(expected_version)::bigint = 0
+  OR workspaces.resource_version = sqlc.arg(expected_version)::bigint
 RETURNING *;
 
 -- name: GetWorkspace :one
@@ -35,4 +37,27 @@ SET deleted_at = NOW(),
     updated_at = NOW()
 WHERE tenant = $1
   AND name = $2
-  AND deleted_at IS NULL;
+  AND deleted_at IS NULL
+  AND (sqlc.arg(expected_version)::bigint = 0 OR resource_version = sqlc.arg(expected_version)::bigint);
+
+-- name: GetWorkspaceDeletedAt :one
+SELECT deleted_at
+FROM workspaces
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1;
+
+-- name: PurgeDeletedWorkspaces :execrows
+DELETE FROM workspaces
+WHERE deleted_at < $1;
+
+-- name: RestoreWorkspace :one
+UPDATE workspaces
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= sqlc.arg(deleted_after)::timestamptz
+RETURNING *;
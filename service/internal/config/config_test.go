@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadReportsEveryInvalidField(t *testing.T) {
+	t.Setenv("SECA_ADMIN_TOKEN", "admin-secret")
+	t.Setenv("SECA_CREDENTIALS_KEY", "c2hvcnQ=")
+	t.Setenv("SECA_LISTEN_ADDR", ":8080")
+	t.Setenv("SECA_ADMIN_LISTEN_ADDR", "0.0.0.0:8080")
+	t.Setenv("SECA_PUBLIC_BASE_URL", "https://api.example.com/seca")
+	t.Setenv("SECA_CONFORMANCE_MODE", "maybe")
+	t.Setenv("SECA_CATALOG_CACHE_TTL", "soon")
+	t.Setenv("SECA_TLS_CERT_FILE", "/etc/seca/tls.crt")
+	t.Setenv("SECA_DB_MAX_CONNS", "4")
+	t.Setenv("SECA_DB_MIN_CONNS", "8")
+	t.Setenv("SECA_DEFAULT_SKU_STRATEGY", "largest")
+	t.Setenv("SECA_VOLUME_MAX_SIZE_GB", "0")
+
+	_, err := Load()
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	got := map[string]bool{}
+	for _, field := range validation.Fields {
+		got[field.Key] = true
+	}
+	for _, key := range []string{"SECA_CREDENTIALS_KEY", "SECA_ADMIN_LISTEN_ADDR", "SECA_PUBLIC_BASE_URL", "SECA_CONFORMANCE_MODE", "SECA_CATALOG_CACHE_TTL", "SECA_TLS_KEY_FILE", "SECA_DB_MIN_CONNS", "SECA_DEFAULT_SKU_STRATEGY"} {
+		if !got[key] {
+			t.Fatalf("expected %s to be reported, got %v", key, validation.Fields)
+		}
+	}
+	if len(validation.Fields) != 8 {
+		t.Fatalf("expected exactly 8 invalid fields, got %v", validation.Fields)
+	}
+
+	t.Setenv("SECA_VOLUME_MAX_SIZE_GB", "5")
+	_, err = Load()
+	if !errors.As(err, &validation) || len(validation.Fields) != 9 || !strings.Contains(err.Error(), "SECA_VOLUME_MAX_SIZE_GB") {
+		t.Fatalf("expected a cap below 10 GB to be reported, got %v", err)
+	}
+}
+
+func TestRedactedMasksSecrets(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		DatabaseURL:    "postgres://app:hunter2@db:5432/seca",
+		AdminToken:     "admin-secret",
+		CredentialsKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		HetznerToken:   "hcloud-secret",
+		ListenAddr:     ":8080",
+	}
+	out := cfg.Redacted()
+	for _, secret := range []string{"hunter2", "admin-secret", "MDEyMzQ1", "hcloud-secret"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("expected %q to be masked in %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "SECA_LISTEN_ADDR=:8080") || !strings.Contains(out, "app:") {
+		t.Fatalf("expected non-secret values to be shown, got %s", out)
+	}
+}
+
+func TestConformanceModeSetsFlagDefaults(t *testing.T) {
+	t.Setenv("SECA_ADMIN_TOKEN", "admin-secret")
+	t.Setenv("SECA_CREDENTIALS_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	t.Setenv("SECA_CONFORMANCE_MODE", "true")
+	t.Setenv("SECA_CONFORMANCE_LOCATION_FALLBACK", "false")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := ConformanceFlags{SKUFallback: true, LockMasking: true, ImageStub: true, BackupSkip: true}
+	if cfg.ConformanceFlags != want {
+		t.Fatalf("expected %s, got %s", want, cfg.ConformanceFlags)
+	}
+}
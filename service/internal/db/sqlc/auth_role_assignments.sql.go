@@ -7,6 +7,8 @@ package dbsqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const getAuthRoleAssignment = `-- name: GetAuthRoleAssignment :one
@@ -40,6 +42,26 @@ func (q *Queries) GetAuthRoleAssignment(ctx context.Context, arg GetAuthRoleAssi
 	return i, err
 }
 
+const getAuthRoleAssignmentDeletedAt = `-- name: GetAuthRoleAssignmentDeletedAt :one
+SELECT deleted_at
+FROM auth_role_assignments
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1
+`
+
+type GetAuthRoleAssignmentDeletedAtParams struct {
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) GetAuthRoleAssignmentDeletedAt(ctx context.Context, arg GetAuthRoleAssignmentDeletedAtParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getAuthRoleAssignmentDeletedAt, arg.Tenant, arg.Name)
+	var deleted_at pgtype.Timestamptz
+	err := row.Scan(&deleted_at)
+	return deleted_at, err
+}
+
 const listAuthRoleAssignmentsByTenant = `-- name: ListAuthRoleAssignmentsByTenant :many
 SELECT id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 FROM auth_role_assignments
@@ -79,6 +101,102 @@ func (q *Queries) ListAuthRoleAssignmentsByTenant(ctx context.Context, tenant st
 	return items, nil
 }
 
+const listAuthRoleAssignmentsMatching = `-- name: ListAuthRoleAssignmentsMatching :many
+SELECT id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
+FROM auth_role_assignments
+WHERE tenant = $1
+  AND deleted_at IS NULL
+  AND ($2::text = '' OR spec -> 'subs' ? $2::text)
+  AND (cardinality($3::text[]) = 0 OR spec -> 'roles' ?| $3::text[])
+ORDER BY name
+`
+
+type ListAuthRoleAssignmentsMatchingParams struct {
+	Tenant  string   `json:"tenant"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+func (q *Queries) ListAuthRoleAssignmentsMatching(ctx context.Context, arg ListAuthRoleAssignmentsMatchingParams) ([]AuthRoleAssignment, error) {
+	rows, err := q.db.Query(ctx, listAuthRoleAssignmentsMatching, arg.Tenant, arg.Subject, arg.Roles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuthRoleAssignment{}
+	for rows.Next() {
+		var i AuthRoleAssignment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Name,
+			&i.Labels,
+			&i.Spec,
+			&i.Status,
+			&i.ResourceVersion,
+			&i.DeletedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedAuthRoleAssignments = `-- name: PurgeDeletedAuthRoleAssignments :execrows
+DELETE FROM auth_role_assignments
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedAuthRoleAssignments(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedAuthRoleAssignments, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreAuthRoleAssignment = `-- name: RestoreAuthRoleAssignment :one
+UPDATE auth_role_assignments
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= $3::timestamptz
+RETURNING id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
+`
+
+type RestoreAuthRoleAssignmentParams struct {
+	Tenant       string             `json:"tenant"`
+	Name         string             `json:"name"`
+	DeletedAfter pgtype.Timestamptz `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreAuthRoleAssignment(ctx context.Context, arg RestoreAuthRoleAssignmentParams) (AuthRoleAssignment, error) {
+	row := q.db.QueryRow(ctx, restoreAuthRoleAssignment, arg.Tenant, arg.Name, arg.DeletedAfter)
+	var i AuthRoleAssignment
+	err := row.Scan(
+		&i.ID,
+		&i.Tenant,
+		&i.Name,
+		&i.Labels,
+		&i.Spec,
+		&i.Status,
+		&i.ResourceVersion,
+		&i.DeletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const softDeleteAuthRoleAssignment = `-- name: SoftDeleteAuthRoleAssignment :execrows
 UPDATE auth_role_assignments
 SET
@@ -88,15 +206,17 @@ SET
 WHERE tenant = $1
   AND name = $2
   AND deleted_at IS NULL
+  AND ($3::bigint = 0 OR resource_version = $3::bigint)
 `
 
 type SoftDeleteAuthRoleAssignmentParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) SoftDeleteAuthRoleAssignment(ctx context.Context, arg SoftDeleteAuthRoleAssignmentParams) (int64, error) {
-	result, err := q.db.Exec(ctx, softDeleteAuthRoleAssignment, arg.Tenant, arg.Name)
+	result, err := q.db.Exec(ctx, softDeleteAuthRoleAssignment, arg.Tenant, arg.Name, arg.ExpectedVersion)
 	if err != nil {
 		return 0, err
 	}
@@ -117,15 +237,18 @@ SET
   resource_version = auth_role_assignments.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE $6::bigint = 0
+  OR auth_role_assignments.resource_version = $6::bigint
 RETURNING id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 `
 
 type UpsertAuthRoleAssignmentParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
-	Labels []byte `json:"labels"`
-	Spec   []byte `json:"spec"`
-	Status []byte `json:"status"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	Labels          []byte `json:"labels"`
+	Spec            []byte `json:"spec"`
+	Status          []byte `json:"status"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) UpsertAuthRoleAssignment(ctx context.Context, arg UpsertAuthRoleAssignmentParams) (AuthRoleAssignment, error) {
@@ -135,6 +258,7 @@ func (q *Queries) UpsertAuthRoleAssignment(ctx context.Context, arg UpsertAuthRo
 		arg.Labels,
 		arg.Spec,
 		arg.Status,
+		arg.ExpectedVersion,
 	)
 	var i AuthRoleAssignment
 	err := row.Scan(
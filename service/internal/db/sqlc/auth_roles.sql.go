@@ -7,6 +7,8 @@ package dbsqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const getAuthRole = `-- name: GetAuthRole :one
@@ -40,6 +42,26 @@ func (q *Queries) GetAuthRole(ctx context.Context, arg GetAuthRoleParams) (AuthR
 	return i, err
 }
 
+const getAuthRoleDeletedAt = `-- name: GetAuthRoleDeletedAt :one
+SELECT deleted_at
+FROM auth_roles
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1
+`
+
+type GetAuthRoleDeletedAtParams struct {
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) GetAuthRoleDeletedAt(ctx context.Context, arg GetAuthRoleDeletedAtParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getAuthRoleDeletedAt, arg.Tenant, arg.Name)
+	var deleted_at pgtype.Timestamptz
+	err := row.Scan(&deleted_at)
+	return deleted_at, err
+}
+
 const listAuthRolesByTenant = `-- name: ListAuthRolesByTenant :many
 SELECT id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 FROM auth_roles
@@ -79,6 +101,55 @@ func (q *Queries) ListAuthRolesByTenant(ctx context.Context, tenant string) ([]A
 	return items, nil
 }
 
+const purgeDeletedAuthRoles = `-- name: PurgeDeletedAuthRoles :execrows
+DELETE FROM auth_roles
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedAuthRoles(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedAuthRoles, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreAuthRole = `-- name: RestoreAuthRole :one
+UPDATE auth_roles
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= $3::timestamptz
+RETURNING id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
+`
+
+type RestoreAuthRoleParams struct {
+	Tenant       string             `json:"tenant"`
+	Name         string             `json:"name"`
+	DeletedAfter pgtype.Timestamptz `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreAuthRole(ctx context.Context, arg RestoreAuthRoleParams) (AuthRole, error) {
+	row := q.db.QueryRow(ctx, restoreAuthRole, arg.Tenant, arg.Name, arg.DeletedAfter)
+	var i AuthRole
+	err := row.Scan(
+		&i.ID,
+		&i.Tenant,
+		&i.Name,
+		&i.Labels,
+		&i.Spec,
+		&i.Status,
+		&i.ResourceVersion,
+		&i.DeletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const softDeleteAuthRole = `-- name: SoftDeleteAuthRole :execrows
 UPDATE auth_roles
 SET
@@ -88,15 +159,17 @@ SET
 WHERE tenant = $1
   AND name = $2
   AND deleted_at IS NULL
+  AND ($3::bigint = 0 OR resource_version = $3::bigint)
 `
 
 type SoftDeleteAuthRoleParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) SoftDeleteAuthRole(ctx context.Context, arg SoftDeleteAuthRoleParams) (int64, error) {
-	result, err := q.db.Exec(ctx, softDeleteAuthRole, arg.Tenant, arg.Name)
+	result, err := q.db.Exec(ctx, softDeleteAuthRole, arg.Tenant, arg.Name, arg.ExpectedVersion)
 	if err != nil {
 		return 0, err
 	}
@@ -117,15 +190,18 @@ SET
   resource_version = auth_roles.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE $6::bigint = 0
+  OR auth_roles.resource_version = $6::bigint
 RETURNING id, tenant, name, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 `
 
 type UpsertAuthRoleParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
-	Labels []byte `json:"labels"`
-	Spec   []byte `json:"spec"`
-	Status []byte `json:"status"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	Labels          []byte `json:"labels"`
+	Spec            []byte `json:"spec"`
+	Status          []byte `json:"status"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) UpsertAuthRole(ctx context.Context, arg UpsertAuthRoleParams) (AuthRole, error) {
@@ -135,6 +211,7 @@ func (q *Queries) UpsertAuthRole(ctx context.Context, arg UpsertAuthRoleParams)
 		arg.Labels,
 		arg.Spec,
 		arg.Status,
+		arg.ExpectedVersion,
 	)
 	var i AuthRole
 	err := row.Scan(
@@ -8,6 +8,19 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditLog struct {
+	ID         int64              `json:"id"`
+	Tenant     string             `json:"tenant"`
+	Workspace  string             `json:"workspace"`
+	Kind       string             `json:"kind"`
+	Operation  string             `json:"operation"`
+	Resource   string             `json:"resource"`
+	ActionID   string             `json:"action_id"`
+	StatusCode int32              `json:"status_code"`
+	ErrorText  string             `json:"error_text"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
 type AuthRole struct {
 	ID              int64              `json:"id"`
 	Tenant          string             `json:"tenant"`
@@ -34,6 +47,24 @@ type AuthRoleAssignment struct {
 	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
 }
 
+type GlobalProviderCredential struct {
+	Provider          string             `json:"provider"`
+	ApiTokenEncrypted string             `json:"api_token_encrypted"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	RotatedAt         pgtype.Timestamptz `json:"rotated_at"`
+}
+
+type IdempotencyKey struct {
+	Tenant         string             `json:"tenant"`
+	IdempotencyKey string             `json:"idempotency_key"`
+	RequestHash    string             `json:"request_hash"`
+	StatusCode     int32              `json:"status_code"`
+	ResponseHeader []byte             `json:"response_header"`
+	ResponseBody   []byte             `json:"response_body"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
 type Operation struct {
 	ID               int64              `json:"id"`
 	OperationID      string             `json:"operation_id"`
@@ -46,15 +77,40 @@ type Operation struct {
 }
 
 type ResourceBinding struct {
-	ID          int64              `json:"id"`
-	Tenant      string             `json:"tenant"`
-	Workspace   string             `json:"workspace"`
-	Kind        string             `json:"kind"`
-	SecaRef     string             `json:"seca_ref"`
-	ProviderRef string             `json:"provider_ref"`
-	Status      string             `json:"status"`
-	CreatedAt   pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+	ID              int64              `json:"id"`
+	Tenant          string             `json:"tenant"`
+	Workspace       string             `json:"workspace"`
+	Kind            string             `json:"kind"`
+	SecaRef         string             `json:"seca_ref"`
+	ProviderRef     string             `json:"provider_ref"`
+	Status          string             `json:"status"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	ResourceVersion int64              `json:"resource_version"`
+}
+
+type Tenant struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TenantApiToken struct {
+	ID        int64              `json:"id"`
+	Tenant    string             `json:"tenant"`
+	Name      string             `json:"name"`
+	TokenHash string             `json:"token_hash"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TenantEntitlement struct {
+	ID        int64              `json:"id"`
+	Tenant    string             `json:"tenant"`
+	Providers []byte             `json:"providers"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Workspace struct {
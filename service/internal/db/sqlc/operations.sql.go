@@ -11,6 +11,38 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countActiveOperationsByPhase = `-- name: CountActiveOperationsByPhase :many
+SELECT phase, count(*) AS count
+FROM operations
+WHERE phase NOT IN ('succeeded', 'failed')
+GROUP BY phase
+`
+
+type CountActiveOperationsByPhaseRow struct {
+	Phase string `json:"phase"`
+	Count int64  `json:"count"`
+}
+
+func (q *Queries) CountActiveOperationsByPhase(ctx context.Context) ([]CountActiveOperationsByPhaseRow, error) {
+	rows, err := q.db.Query(ctx, countActiveOperationsByPhase)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountActiveOperationsByPhaseRow{}
+	for rows.Next() {
+		var i CountActiveOperationsByPhaseRow
+		if err := rows.Scan(&i.Phase, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createOperation = `-- name: CreateOperation :one
 INSERT INTO operations (
   operation_id, seca_ref, provider_action_id, phase, error_text
@@ -50,6 +82,20 @@ func (q *Queries) CreateOperation(ctx context.Context, arg CreateOperationParams
 	return i, err
 }
 
+const deleteCompletedOperationsBefore = `-- name: DeleteCompletedOperationsBefore :execrows
+DELETE FROM operations
+WHERE phase IN ('succeeded', 'failed')
+  AND updated_at < $1
+`
+
+func (q *Queries) DeleteCompletedOperationsBefore(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCompletedOperationsBefore, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getOperationByID = `-- name: GetOperationByID :one
 SELECT id, operation_id, seca_ref, provider_action_id, phase, error_text, created_at, updated_at
 FROM operations
@@ -76,11 +122,26 @@ const listOperationsBySecaRef = `-- name: ListOperationsBySecaRef :many
 SELECT id, operation_id, seca_ref, provider_action_id, phase, error_text, created_at, updated_at
 FROM operations
 WHERE seca_ref = $1
-ORDER BY created_at DESC
+  AND ($2::text = '' OR phase = $2::text)
+  AND ($3::bigint = 0 OR id < $3::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
 `
 
-func (q *Queries) ListOperationsBySecaRef(ctx context.Context, secaRef string) ([]Operation, error) {
-	rows, err := q.db.Query(ctx, listOperationsBySecaRef, secaRef)
+type ListOperationsBySecaRefParams struct {
+	SecaRef  string `json:"seca_ref"`
+	Phase    string `json:"phase"`
+	BeforeID int64  `json:"before_id"`
+	RowLimit int32  `json:"row_limit"`
+}
+
+func (q *Queries) ListOperationsBySecaRef(ctx context.Context, arg ListOperationsBySecaRefParams) ([]Operation, error) {
+	rows, err := q.db.Query(ctx, listOperationsBySecaRef,
+		arg.SecaRef,
+		arg.Phase,
+		arg.BeforeID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -107,3 +168,85 @@ func (q *Queries) ListOperationsBySecaRef(ctx context.Context, secaRef string) (
 	}
 	return items, nil
 }
+
+const listPendingOperations = `-- name: ListPendingOperations :many
+SELECT id, operation_id, seca_ref, provider_action_id, phase, error_text, created_at, updated_at
+FROM operations
+WHERE phase NOT IN ('succeeded', 'failed')
+  AND provider_action_id IS NOT NULL
+ORDER BY updated_at
+LIMIT $1
+`
+
+func (q *Queries) ListPendingOperations(ctx context.Context, limit int32) ([]Operation, error) {
+	rows, err := q.db.Query(ctx, listPendingOperations, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Operation{}
+	for rows.Next() {
+		var i Operation
+		if err := rows.Scan(
+			&i.ID,
+			&i.OperationID,
+			&i.SecaRef,
+			&i.ProviderActionID,
+			&i.Phase,
+			&i.ErrorText,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateOperationPhase = `-- name: UpdateOperationPhase :execrows
+UPDATE operations
+SET phase = $2,
+    error_text = $3,
+    updated_at = NOW()
+WHERE operation_id = $1
+`
+
+type UpdateOperationPhaseParams struct {
+	OperationID string      `json:"operation_id"`
+	Phase       string      `json:"phase"`
+	ErrorText   pgtype.Text `json:"error_text"`
+}
+
+func (q *Queries) UpdateOperationPhase(ctx context.Context, arg UpdateOperationPhaseParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOperationPhase, arg.OperationID, arg.Phase, arg.ErrorText)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateOperationProviderAction = `-- name: UpdateOperationProviderAction :execrows
+UPDATE operations
+SET provider_action_id = $2,
+    phase = $3,
+    updated_at = NOW()
+WHERE operation_id = $1
+`
+
+type UpdateOperationProviderActionParams struct {
+	OperationID      string      `json:"operation_id"`
+	ProviderActionID pgtype.Text `json:"provider_action_id"`
+	Phase            string      `json:"phase"`
+}
+
+func (q *Queries) UpdateOperationProviderAction(ctx context.Context, arg UpdateOperationProviderActionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOperationProviderAction, arg.OperationID, arg.ProviderActionID, arg.Phase)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
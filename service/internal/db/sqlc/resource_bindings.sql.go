@@ -9,13 +9,47 @@ import (
 	"context"
 )
 
+const countResourceBindingsByTenant = `-- name: CountResourceBindingsByTenant :many
+SELECT workspace, kind, COUNT(*) AS count
+FROM resource_bindings
+WHERE tenant = $1
+GROUP BY workspace, kind
+ORDER BY workspace, kind
+`
+
+type CountResourceBindingsByTenantRow struct {
+	Workspace string `json:"workspace"`
+	Kind      string `json:"kind"`
+	Count     int64  `json:"count"`
+}
+
+func (q *Queries) CountResourceBindingsByTenant(ctx context.Context, tenant string) ([]CountResourceBindingsByTenantRow, error) {
+	rows, err := q.db.Query(ctx, countResourceBindingsByTenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountResourceBindingsByTenantRow{}
+	for rows.Next() {
+		var i CountResourceBindingsByTenantRow
+		if err := rows.Scan(&i.Workspace, &i.Kind, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createResourceBinding = `-- name: CreateResourceBinding :one
 INSERT INTO resource_bindings (
   tenant, workspace, kind, seca_ref, provider_ref, status
 ) VALUES (
   $1, $2, $3, $4, $5, $6
 )
-RETURNING id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at
+RETURNING id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
 `
 
 type CreateResourceBindingParams struct {
@@ -47,6 +81,7 @@ func (q *Queries) CreateResourceBinding(ctx context.Context, arg CreateResourceB
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ResourceVersion,
 	)
 	return i, err
 }
@@ -61,8 +96,46 @@ func (q *Queries) DeleteResourceBindingBySecaRef(ctx context.Context, secaRef st
 	return err
 }
 
+const deleteResourceBindingIfStatus = `-- name: DeleteResourceBindingIfStatus :execrows
+DELETE FROM resource_bindings
+WHERE seca_ref = $1
+  AND status = $2
+`
+
+type DeleteResourceBindingIfStatusParams struct {
+	SecaRef string `json:"seca_ref"`
+	Status  string `json:"status"`
+}
+
+func (q *Queries) DeleteResourceBindingIfStatus(ctx context.Context, arg DeleteResourceBindingIfStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteResourceBindingIfStatus, arg.SecaRef, arg.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteResourceBindingIfVersion = `-- name: DeleteResourceBindingIfVersion :execrows
+DELETE FROM resource_bindings
+WHERE seca_ref = $1
+  AND resource_version = $2
+`
+
+type DeleteResourceBindingIfVersionParams struct {
+	SecaRef         string `json:"seca_ref"`
+	ResourceVersion int64  `json:"resource_version"`
+}
+
+func (q *Queries) DeleteResourceBindingIfVersion(ctx context.Context, arg DeleteResourceBindingIfVersionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteResourceBindingIfVersion, arg.SecaRef, arg.ResourceVersion)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getResourceBindingBySecaRef = `-- name: GetResourceBindingBySecaRef :one
-SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at
+SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
 FROM resource_bindings
 WHERE seca_ref = $1
 `
@@ -80,12 +153,95 @@ func (q *Queries) GetResourceBindingBySecaRef(ctx context.Context, secaRef strin
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ResourceVersion,
 	)
 	return i, err
 }
 
+const listResourceBindingsByKinds = `-- name: ListResourceBindingsByKinds :many
+SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
+FROM resource_bindings
+WHERE kind = ANY($1::text[])
+ORDER BY tenant, workspace, seca_ref
+`
+
+func (q *Queries) ListResourceBindingsByKinds(ctx context.Context, kinds []string) ([]ResourceBinding, error) {
+	rows, err := q.db.Query(ctx, listResourceBindingsByKinds, kinds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ResourceBinding{}
+	for rows.Next() {
+		var i ResourceBinding
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Workspace,
+			&i.Kind,
+			&i.SecaRef,
+			&i.ProviderRef,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ResourceVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listResourceBindingsByScope = `-- name: ListResourceBindingsByScope :many
+SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
+FROM resource_bindings
+WHERE tenant = $1
+  AND workspace = $2
+ORDER BY seca_ref
+`
+
+type ListResourceBindingsByScopeParams struct {
+	Tenant    string `json:"tenant"`
+	Workspace string `json:"workspace"`
+}
+
+func (q *Queries) ListResourceBindingsByScope(ctx context.Context, arg ListResourceBindingsByScopeParams) ([]ResourceBinding, error) {
+	rows, err := q.db.Query(ctx, listResourceBindingsByScope, arg.Tenant, arg.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ResourceBinding{}
+	for rows.Next() {
+		var i ResourceBinding
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Workspace,
+			&i.Kind,
+			&i.SecaRef,
+			&i.ProviderRef,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ResourceVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listResourceBindingsByScopeAndKind = `-- name: ListResourceBindingsByScopeAndKind :many
-SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at
+SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
 FROM resource_bindings
 WHERE tenant = $1
   AND workspace = $2
@@ -118,6 +274,7 @@ func (q *Queries) ListResourceBindingsByScopeAndKind(ctx context.Context, arg Li
 			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ResourceVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -129,21 +286,80 @@ func (q *Queries) ListResourceBindingsByScopeAndKind(ctx context.Context, arg Li
 	return items, nil
 }
 
-const upsertResourceBinding = `-- name: UpsertResourceBinding :one
+const listResourceBindingsByTenantAndKind = `-- name: ListResourceBindingsByTenantAndKind :many
+SELECT id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
+FROM resource_bindings
+WHERE tenant = $1
+  AND kind = $2
+ORDER BY seca_ref
+`
+
+type ListResourceBindingsByTenantAndKindParams struct {
+	Tenant string `json:"tenant"`
+	Kind   string `json:"kind"`
+}
+
+func (q *Queries) ListResourceBindingsByTenantAndKind(ctx context.Context, arg ListResourceBindingsByTenantAndKindParams) ([]ResourceBinding, error) {
+	rows, err := q.db.Query(ctx, listResourceBindingsByTenantAndKind, arg.Tenant, arg.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ResourceBinding{}
+	for rows.Next() {
+		var i ResourceBinding
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Workspace,
+			&i.Kind,
+			&i.SecaRef,
+			&i.ProviderRef,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ResourceVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const syncResourceBinding = `-- name: SyncResourceBinding :one
 INSERT INTO resource_bindings (
   tenant, workspace, kind, seca_ref, provider_ref, status
 ) VALUES (
-  $1, $2, $3, $4, $5, $6
+  $1, $2, $3, $4, $5,
+  CASE
+    WHEN $6 = 'active' AND EXISTS (
+      SELECT 1 FROM resource_bindings WHERE seca_ref = $4 AND status = 'error'
+    ) THEN 'error'
+    ELSE $6
+  END
 )
 ON CONFLICT (seca_ref) DO UPDATE
 SET
   provider_ref = EXCLUDED.provider_ref,
   status = EXCLUDED.status,
-  updated_at = NOW()
-RETURNING id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at
+  resource_version = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN resource_bindings.resource_version + 1
+    ELSE resource_bindings.resource_version
+  END,
+  updated_at = CASE
+    WHEN (resource_bindings.provider_ref, resource_bindings.status) IS DISTINCT FROM (EXCLUDED.provider_ref, EXCLUDED.status)
+      THEN NOW()
+    ELSE resource_bindings.updated_at
+  END
+RETURNING id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
 `
 
-type UpsertResourceBindingParams struct {
+type SyncResourceBindingParams struct {
 	Tenant      string `json:"tenant"`
 	Workspace   string `json:"workspace"`
 	Kind        string `json:"kind"`
@@ -152,6 +368,79 @@ type UpsertResourceBindingParams struct {
 	Status      string `json:"status"`
 }
 
+func (q *Queries) SyncResourceBinding(ctx context.Context, arg SyncResourceBindingParams) (ResourceBinding, error) {
+	row := q.db.QueryRow(ctx, syncResourceBinding,
+		arg.Tenant,
+		arg.Workspace,
+		arg.Kind,
+		arg.SecaRef,
+		arg.ProviderRef,
+		arg.Status,
+	)
+	var i ResourceBinding
+	err := row.Scan(
+		&i.ID,
+		&i.Tenant,
+		&i.Workspace,
+		&i.Kind,
+		&i.SecaRef,
+		&i.ProviderRef,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ResourceVersion,
+	)
+	return i, err
+}
+
+const updateResourceBindingStatus = `-- name: UpdateResourceBindingStatus :execrows
+UPDATE resource_bindings
+SET
+  status = $2,
+  updated_at = NOW()
+WHERE seca_ref = $1
+`
+
+type UpdateResourceBindingStatusParams struct {
+	SecaRef string `json:"seca_ref"`
+	Status  string `json:"status"`
+}
+
+func (q *Queries) UpdateResourceBindingStatus(ctx context.Context, arg UpdateResourceBindingStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateResourceBindingStatus, arg.SecaRef, arg.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const upsertResourceBinding = `-- name: UpsertResourceBinding :one
+INSERT INTO resource_bindings (
+  tenant, workspace, kind, seca_ref, provider_ref, status
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (seca_ref) DO UPDATE
+SET
+  provider_ref = EXCLUDED.provider_ref,
+  status = EXCLUDED.status,
+  resource_version = resource_bindings.resource_version + 1,
+  updated_at = NOW()
+WHERE $7::bigint = 0
+  OR resource_bindings.resource_version = $7::bigint
+RETURNING id, tenant, workspace, kind, seca_ref, provider_ref, status, created_at, updated_at, resource_version
+`
+
+type UpsertResourceBindingParams struct {
+	Tenant          string `json:"tenant"`
+	Workspace       string `json:"workspace"`
+	Kind            string `json:"kind"`
+	SecaRef         string `json:"seca_ref"`
+	ProviderRef     string `json:"provider_ref"`
+	Status          string `json:"status"`
+	ExpectedVersion int64  `json:"expected_version"`
+}
+
 func (q *Queries) UpsertResourceBinding(ctx context.Context, arg UpsertResourceBindingParams) (ResourceBinding, error) {
 	row := q.db.QueryRow(ctx, upsertResourceBinding,
 		arg.Tenant,
@@ -160,6 +449,7 @@ func (q *Queries) UpsertResourceBinding(ctx context.Context, arg UpsertResourceB
 		arg.SecaRef,
 		arg.ProviderRef,
 		arg.Status,
+		arg.ExpectedVersion,
 	)
 	var i ResourceBinding
 	err := row.Scan(
@@ -172,6 +462,7 @@ func (q *Queries) UpsertResourceBinding(ctx context.Context, arg UpsertResourceB
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ResourceVersion,
 	)
 	return i, err
 }
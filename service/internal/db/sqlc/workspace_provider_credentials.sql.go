@@ -45,6 +45,159 @@ func (q *Queries) GetWorkspaceProviderCredential(ctx context.Context, arg GetWor
 	return i, err
 }
 
+const listAllWorkspaceProviderCredentials = `-- name: ListAllWorkspaceProviderCredentials :many
+SELECT id, tenant, workspace, provider, project_ref, api_endpoint, api_token_encrypted, created_at, updated_at, deleted_at
+FROM workspace_provider_credentials
+WHERE deleted_at IS NULL
+ORDER BY tenant, workspace, provider
+`
+
+func (q *Queries) ListAllWorkspaceProviderCredentials(ctx context.Context) ([]WorkspaceProviderCredential, error) {
+	rows, err := q.db.Query(ctx, listAllWorkspaceProviderCredentials)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WorkspaceProviderCredential{}
+	for rows.Next() {
+		var i WorkspaceProviderCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Workspace,
+			&i.Provider,
+			&i.ProjectRef,
+			&i.ApiEndpoint,
+			&i.ApiTokenEncrypted,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceProviderCredentialsByTenant = `-- name: ListWorkspaceProviderCredentialsByTenant :many
+SELECT id, tenant, workspace, provider, project_ref, api_endpoint, api_token_encrypted, created_at, updated_at, deleted_at
+FROM workspace_provider_credentials
+WHERE tenant = $1
+  AND deleted_at IS NULL
+ORDER BY workspace, provider
+`
+
+func (q *Queries) ListWorkspaceProviderCredentialsByTenant(ctx context.Context, tenant string) ([]WorkspaceProviderCredential, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceProviderCredentialsByTenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WorkspaceProviderCredential{}
+	for rows.Next() {
+		var i WorkspaceProviderCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.Tenant,
+			&i.Workspace,
+			&i.Provider,
+			&i.ProjectRef,
+			&i.ApiEndpoint,
+			&i.ApiTokenEncrypted,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedWorkspaceProviderCredentials = `-- name: PurgeDeletedWorkspaceProviderCredentials :execrows
+DELETE FROM workspace_provider_credentials
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedWorkspaceProviderCredentials(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedWorkspaceProviderCredentials, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reencryptWorkspaceProviderCredentialToken = `-- name: ReencryptWorkspaceProviderCredentialToken :execrows
+UPDATE workspace_provider_credentials
+SET api_token_encrypted = $1
+WHERE id = $2
+  AND api_token_encrypted = $3
+  AND deleted_at IS NULL
+`
+
+type ReencryptWorkspaceProviderCredentialTokenParams struct {
+	NewToken string `json:"new_token"`
+	ID       int64  `json:"id"`
+	OldToken string `json:"old_token"`
+}
+
+func (q *Queries) ReencryptWorkspaceProviderCredentialToken(ctx context.Context, arg ReencryptWorkspaceProviderCredentialTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reencryptWorkspaceProviderCredentialToken, arg.NewToken, arg.ID, arg.OldToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rotateWorkspaceProviderCredentialToken = `-- name: RotateWorkspaceProviderCredentialToken :one
+UPDATE workspace_provider_credentials
+SET api_token_encrypted = $4,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND workspace = $2
+  AND provider = $3
+  AND deleted_at IS NULL
+RETURNING id, tenant, workspace, provider, project_ref, api_endpoint, api_token_encrypted, created_at, updated_at, deleted_at
+`
+
+type RotateWorkspaceProviderCredentialTokenParams struct {
+	Tenant            string `json:"tenant"`
+	Workspace         string `json:"workspace"`
+	Provider          string `json:"provider"`
+	ApiTokenEncrypted string `json:"api_token_encrypted"`
+}
+
+func (q *Queries) RotateWorkspaceProviderCredentialToken(ctx context.Context, arg RotateWorkspaceProviderCredentialTokenParams) (WorkspaceProviderCredential, error) {
+	row := q.db.QueryRow(ctx, rotateWorkspaceProviderCredentialToken,
+		arg.Tenant,
+		arg.Workspace,
+		arg.Provider,
+		arg.ApiTokenEncrypted,
+	)
+	var i WorkspaceProviderCredential
+	err := row.Scan(
+		&i.ID,
+		&i.Tenant,
+		&i.Workspace,
+		&i.Provider,
+		&i.ProjectRef,
+		&i.ApiEndpoint,
+		&i.ApiTokenEncrypted,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const softDeleteWorkspaceProviderCredential = `-- name: SoftDeleteWorkspaceProviderCredential :execrows
 UPDATE workspace_provider_credentials
 SET deleted_at = NOW(),
@@ -7,6 +7,8 @@ package dbsqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const getWorkspace = `-- name: GetWorkspace :one
@@ -42,6 +44,26 @@ func (q *Queries) GetWorkspace(ctx context.Context, arg GetWorkspaceParams) (Wor
 	return i, err
 }
 
+const getWorkspaceDeletedAt = `-- name: GetWorkspaceDeletedAt :one
+SELECT deleted_at
+FROM workspaces
+WHERE tenant = $1
+  AND name = $2
+LIMIT 1
+`
+
+type GetWorkspaceDeletedAtParams struct {
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) GetWorkspaceDeletedAt(ctx context.Context, arg GetWorkspaceDeletedAtParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceDeletedAt, arg.Tenant, arg.Name)
+	var deleted_at pgtype.Timestamptz
+	err := row.Scan(&deleted_at)
+	return deleted_at, err
+}
+
 const listWorkspacesByTenant = `-- name: ListWorkspacesByTenant :many
 SELECT id, tenant, name, region, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 FROM workspaces
@@ -82,6 +104,56 @@ func (q *Queries) ListWorkspacesByTenant(ctx context.Context, tenant string) ([]
 	return items, nil
 }
 
+const purgeDeletedWorkspaces = `-- name: PurgeDeletedWorkspaces :execrows
+DELETE FROM workspaces
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedWorkspaces(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedWorkspaces, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreWorkspace = `-- name: RestoreWorkspace :one
+UPDATE workspaces
+SET deleted_at = NULL,
+    resource_version = resource_version + 1,
+    updated_at = NOW()
+WHERE tenant = $1
+  AND name = $2
+  AND deleted_at IS NOT NULL
+  AND deleted_at >= $3::timestamptz
+RETURNING id, tenant, name, region, labels, spec, status, resource_version, deleted_at, created_at, updated_at
+`
+
+type RestoreWorkspaceParams struct {
+	Tenant       string             `json:"tenant"`
+	Name         string             `json:"name"`
+	DeletedAfter pgtype.Timestamptz `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreWorkspace(ctx context.Context, arg RestoreWorkspaceParams) (Workspace, error) {
+	row := q.db.QueryRow(ctx, restoreWorkspace, arg.Tenant, arg.Name, arg.DeletedAfter)
+	var i Workspace
+	err := row.Scan(
+		&i.ID,
+		&i.Tenant,
+		&i.Name,
+		&i.Region,
+		&i.Labels,
+		&i.Spec,
+		&i.Status,
+		&i.ResourceVersion,
+		&i.DeletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const softDeleteWorkspace = `-- name: SoftDeleteWorkspace :execrows
 UPDATE workspaces
 SET deleted_at = NOW(),
@@ -89,15 +161,17 @@ SET deleted_at = NOW(),
 WHERE tenant = $1
   AND name = $2
   AND deleted_at IS NULL
+  AND ($3::bigint = 0 OR resource_version = $3::bigint)
 `
 
 type SoftDeleteWorkspaceParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) SoftDeleteWorkspace(ctx context.Context, arg SoftDeleteWorkspaceParams) (int64, error) {
-	result, err := q.db.Exec(ctx, softDeleteWorkspace, arg.Tenant, arg.Name)
+	result, err := q.db.Exec(ctx, softDeleteWorkspace, arg.Tenant, arg.Name, arg.ExpectedVersion)
 	if err != nil {
 		return 0, err
 	}
@@ -118,16 +192,19 @@ ON CONFLICT (tenant, name) DO UPDATE SET
   resource_version = workspaces.resource_version + 1,
   deleted_at = NULL,
   updated_at = NOW()
+WHERE $7::bigint = 0
+  OR workspaces.resource_version = $7::bigint
 RETURNING id, tenant, name, region, labels, spec, status, resource_version, deleted_at, created_at, updated_at
 `
 
 type UpsertWorkspaceParams struct {
-	Tenant string `json:"tenant"`
-	Name   string `json:"name"`
-	Region string `json:"region"`
-	Labels []byte `json:"labels"`
-	Spec   []byte `json:"spec"`
-	Status []byte `json:"status"`
+	Tenant          string `json:"tenant"`
+	Name            string `json:"name"`
+	Region          string `json:"region"`
+	Labels          []byte `json:"labels"`
+	Spec            []byte `json:"spec"`
+	Status          []byte `json:"status"`
+	ExpectedVersion int64  `json:"expected_version"`
 }
 
 func (q *Queries) UpsertWorkspace(ctx context.Context, arg UpsertWorkspaceParams) (Workspace, error) {
@@ -138,6 +215,7 @@ func (q *Queries) UpsertWorkspace(ctx context.Context, arg UpsertWorkspaceParams
 		arg.Labels,
 		arg.Spec,
 		arg.Status,
+		arg.ExpectedVersion,
 	)
 	var i Workspace
 	err := row.Scan(
@@ -0,0 +1,187 @@
+// Package faults injects provider and store errors for chaos testing. It is
+// only wired in when SECA_FAULT_INJECTION is on; a nil *Injector injects
+// nothing.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	LayerProvider = "provider"
+	LayerStore    = "store"
+
+	// MatchAll matches every provider method or store query of a layer.
+	MatchAll = "*"
+
+	DefaultTTL = 5 * time.Minute
+	MaxTTL     = time.Hour
+)
+
+// errorKinds maps the error names accepted in rules to the error returned to
+// the caller. Provider kinds mimic Hetzner API errors so the regular error
+// mapping applies; no_rows mimics a missing database row.
+var errorKinds = map[string]func() error{
+	"locked":      func() error { return apiError(hcloud.ErrorCodeLocked, "resource is locked") },
+	"conflict":    func() error { return apiError(hcloud.ErrorCodeConflict, "resource changed concurrently") },
+	"not_found":   func() error { return apiError(hcloud.ErrorCodeNotFound, "resource not found") },
+	"rate_limit":  func() error { return apiError(hcloud.ErrorCodeRateLimitExceeded, "rate limit exceeded") },
+	"unavailable": func() error { return apiError(hcloud.ErrorCodeResourceUnavailable, "resource unavailable") },
+	"no_rows":     func() error { return fmt.Errorf("injected fault: %w", pgx.ErrNoRows) },
+	"internal":    func() error { return errors.New("injected fault: internal error") },
+}
+
+func apiError(code hcloud.ErrorCode, message string) error {
+	return hcloud.Error{Code: code, Message: "injected fault: " + message}
+}
+
+// Rule makes calls matching Layer and Name fail with Error and/or wait for
+// Latency, each time with the given Probability, until ExpiresAt.
+type Rule struct {
+	ID          string
+	Layer       string
+	Name        string
+	Probability float64
+	Error       string
+	Latency     time.Duration
+	ExpiresAt   time.Time
+}
+
+func (r Rule) matches(layer, name string) bool {
+	return r.Layer == layer && (r.Name == MatchAll || r.Name == name)
+}
+
+// Injector holds the active rules.
+type Injector struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	random func() float64
+	nextID int
+	rules  []Rule
+}
+
+func NewInjector() *Injector {
+	return &Injector{now: time.Now, random: rand.Float64}
+}
+
+// Add validates rule, sets its ID and expiry from ttl and activates it. A
+// zero ttl uses DefaultTTL.
+func (i *Injector) Add(rule Rule, ttl time.Duration) (Rule, error) {
+	if rule.Layer != LayerProvider && rule.Layer != LayerStore {
+		return Rule{}, fmt.Errorf("layer must be %q or %q", LayerProvider, LayerStore)
+	}
+	if rule.Name == "" {
+		return Rule{}, errors.New("name is required")
+	}
+	if rule.Probability <= 0 || rule.Probability > 1 {
+		return Rule{}, errors.New("probability must be in (0, 1]")
+	}
+	if rule.Error == "" && rule.Latency <= 0 {
+		return Rule{}, errors.New("error or latency is required")
+	}
+	if rule.Error != "" {
+		if _, ok := errorKinds[rule.Error]; !ok {
+			return Rule{}, fmt.Errorf("unknown error %q", rule.Error)
+		}
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < 0 || ttl > MaxTTL {
+		return Rule{}, fmt.Errorf("ttl must be at most %s", MaxTTL)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.nextID++
+	rule.ID = strconv.Itoa(i.nextID)
+	rule.ExpiresAt = i.now().Add(ttl)
+	i.rules = append(i.rules, rule)
+	return rule, nil
+}
+
+// Rules returns the active rules ordered by ID.
+func (i *Injector) Rules() []Rule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.pruneLocked()
+	out := append([]Rule(nil), i.rules...)
+	sort.Slice(out, func(a, b int) bool {
+		ai, _ := strconv.Atoi(out[a].ID)
+		bi, _ := strconv.Atoi(out[b].ID)
+		return ai < bi
+	})
+	return out
+}
+
+// Remove deactivates the rule with id and reports whether it was active.
+func (i *Injector) Remove(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.pruneLocked()
+	for idx, rule := range i.rules {
+		if rule.ID == id {
+			i.rules = append(i.rules[:idx], i.rules[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Inject applies the first active rule matching layer and name: it waits for
+// the rule latency and returns the rule error, if any.
+func (i *Injector) Inject(ctx context.Context, layer, name string) error {
+	if i == nil {
+		return nil
+	}
+	rule, ok := i.pick(layer, name)
+	if !ok {
+		return nil
+	}
+	if rule.Latency > 0 {
+		timer := time.NewTimer(rule.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if rule.Error == "" {
+		return nil
+	}
+	return errorKinds[rule.Error]()
+}
+
+func (i *Injector) pick(layer, name string) (Rule, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.pruneLocked()
+	for _, rule := range i.rules {
+		if rule.matches(layer, name) && i.random() < rule.Probability {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (i *Injector) pruneLocked() {
+	now := i.now()
+	active := i.rules[:0]
+	for _, rule := range i.rules {
+		if now.Before(rule.ExpiresAt) {
+			active = append(active, rule)
+		}
+	}
+	i.rules = active
+}
@@ -0,0 +1,99 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func newTestInjector(now *time.Time, roll float64) *Injector {
+	injector := NewInjector()
+	injector.now = func() time.Time { return *now }
+	injector.random = func() float64 { return roll }
+	return injector
+}
+
+func TestInjectorAppliesMatchingRulesUntilExpiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	injector := newTestInjector(&now, 0.5)
+	if _, err := injector.Add(Rule{Layer: LayerProvider, Name: "StartInstance", Probability: 1, Error: "locked"}, time.Minute); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	var apiErr hcloud.Error
+	if err := injector.Inject(context.Background(), LayerProvider, "StartInstance"); !errors.As(err, &apiErr) || apiErr.Code != hcloud.ErrorCodeLocked {
+		t.Fatalf("expected injected locked error, got %v", err)
+	}
+	if err := injector.Inject(context.Background(), LayerProvider, "StopInstance"); err != nil {
+		t.Fatalf("expected other methods to pass, got %v", err)
+	}
+	if err := injector.Inject(context.Background(), LayerStore, "StartInstance"); err != nil {
+		t.Fatalf("expected other layers to pass, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := injector.Inject(context.Background(), LayerProvider, "StartInstance"); err != nil {
+		t.Fatalf("expected expired rule to be inert, got %v", err)
+	}
+	if rules := injector.Rules(); len(rules) != 0 {
+		t.Fatalf("expected expired rule to be dropped, got %v", rules)
+	}
+}
+
+func TestInjectorHonoursProbability(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	injector := newTestInjector(&now, 0.7)
+	rule, err := injector.Add(Rule{Layer: LayerStore, Name: MatchAll, Probability: 0.5, Error: "no_rows"}, 0)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if !rule.ExpiresAt.Equal(now.Add(DefaultTTL)) {
+		t.Fatalf("expected default ttl, got expiry %s", rule.ExpiresAt)
+	}
+	if err := injector.Inject(context.Background(), LayerStore, "GetResourceBinding"); err != nil {
+		t.Fatalf("expected roll above probability to pass, got %v", err)
+	}
+	injector.random = func() float64 { return 0.2 }
+	if err := injector.Inject(context.Background(), LayerStore, "GetResourceBinding"); err == nil {
+		t.Fatal("expected roll below probability to fail")
+	}
+	if !injector.Remove(rule.ID) || injector.Remove(rule.ID) {
+		t.Fatal("expected rule to be removed exactly once")
+	}
+}
+
+func TestInjectorRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	injector := NewInjector()
+	for name, rule := range map[string]Rule{
+		"layer":       {Layer: "network", Name: "x", Probability: 1, Error: "locked"},
+		"name":        {Layer: LayerProvider, Probability: 1, Error: "locked"},
+		"probability": {Layer: LayerProvider, Name: "x", Probability: 1.5, Error: "locked"},
+		"effect":      {Layer: LayerProvider, Name: "x", Probability: 1},
+		"error":       {Layer: LayerProvider, Name: "x", Probability: 1, Error: "boom"},
+	} {
+		if _, err := injector.Add(rule, 0); err == nil {
+			t.Fatalf("%s: expected rule to be rejected", name)
+		}
+	}
+	if _, err := injector.Add(Rule{Layer: LayerProvider, Name: "x", Probability: 1, Error: "locked"}, 2*MaxTTL); err == nil {
+		t.Fatal("expected ttl above the maximum to be rejected")
+	}
+}
+
+func TestNilInjectorIsInert(t *testing.T) {
+	t.Parallel()
+
+	var injector *Injector
+	if err := injector.Inject(context.Background(), LayerProvider, "StartInstance"); err != nil {
+		t.Fatalf("expected nil injector to pass, got %v", err)
+	}
+}
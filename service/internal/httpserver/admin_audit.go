@@ -0,0 +1,108 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const (
+	auditListDefaultLimit = 100
+	auditListMaxLimit     = 1000
+)
+
+// auditLogStore is the part of *state.Store the audit listing uses.
+type auditLogStore interface {
+	ListAuditEntries(ctx context.Context, filter state.AuditFilter) ([]state.AuditEntry, error)
+}
+
+type auditEntryResponse struct {
+	ID         int64  `json:"id"`
+	Time       string `json:"time"`
+	Tenant     string `json:"tenant,omitempty"`
+	Workspace  string `json:"workspace,omitempty"`
+	Kind       string `json:"kind"`
+	Operation  string `json:"operation"`
+	Resource   string `json:"resource,omitempty"`
+	ActionID   string `json:"actionId,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type auditEntryList struct {
+	Items     []auditEntryResponse `json:"items"`
+	SkipToken string               `json:"skipToken,omitempty"`
+}
+
+// adminListAudit lists the recorded Hetzner mutations, newest first. A full
+// page carries a skipToken; passing it back continues after the last entry.
+func adminListAudit(store auditLogStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, problem := parseAuditFilter(r.URL.Query())
+		if problem != "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", problem, r.URL.Path)
+			return
+		}
+		entries, err := store.ListAuditEntries(r.Context(), filter)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list audit entries", r.URL.Path)
+			return
+		}
+		list := auditEntryList{Items: make([]auditEntryResponse, 0, len(entries))}
+		for _, entry := range entries {
+			list.Items = append(list.Items, auditEntryResponse{
+				ID:         entry.ID,
+				Time:       entry.Time.Format(time.RFC3339Nano),
+				Tenant:     entry.Tenant,
+				Workspace:  entry.Workspace,
+				Kind:       entry.Kind,
+				Operation:  entry.Operation,
+				Resource:   entry.Resource,
+				ActionID:   entry.ActionID,
+				StatusCode: entry.StatusCode,
+				Error:      entry.Error,
+			})
+		}
+		if len(entries) == filter.Limit {
+			list.SkipToken = strconv.FormatInt(entries[len(entries)-1].ID, 10)
+		}
+		respondJSON(w, http.StatusOK, list)
+	}
+}
+
+// parseAuditFilter reads tenant, kind, since, limit and skipToken. It returns
+// a problem detail when one of them is malformed.
+func parseAuditFilter(query url.Values) (state.AuditFilter, string) {
+	filter := state.AuditFilter{
+		Tenant: query.Get("tenant"),
+		Kind:   query.Get("kind"),
+		Since:  time.Unix(0, 0).UTC(),
+		Limit:  auditListDefaultLimit,
+	}
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, "since must be an RFC 3339 timestamp"
+		}
+		filter.Since = since
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > auditListMaxLimit {
+			return filter, "limit must be between 1 and " + strconv.Itoa(auditListMaxLimit)
+		}
+		filter.Limit = limit
+	}
+	if raw := query.Get("skipToken"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID < 1 {
+			return filter, "skipToken is invalid"
+		}
+		filter.BeforeID = beforeID
+	}
+	return filter, ""
+}
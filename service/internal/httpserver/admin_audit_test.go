@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeAuditLogStore struct {
+	filter  state.AuditFilter
+	entries []state.AuditEntry
+}
+
+func (s *fakeAuditLogStore) ListAuditEntries(_ context.Context, filter state.AuditFilter) ([]state.AuditEntry, error) {
+	s.filter = filter
+	return s.entries, nil
+}
+
+func TestAdminListAuditPaginates(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeAuditLogStore{entries: []state.AuditEntry{
+		{ID: 12, Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Tenant: "acme", Kind: "servers", Operation: "POST /servers", Resource: "web-1", ActionID: "7001", StatusCode: 201},
+		{ID: 11, Time: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC), Tenant: "acme", Kind: "servers", Operation: "DELETE /servers/{id}", Resource: "41", StatusCode: 200},
+	}}
+	rec := httptest.NewRecorder()
+	adminListAudit(store)(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/audit?tenant=acme&kind=servers&since=2026-01-01T00:00:00Z&limit=2&skipToken=20", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := state.AuditFilter{Tenant: "acme", Kind: "servers", Since: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), BeforeID: 20, Limit: 2}
+	if store.filter != want {
+		t.Fatalf("expected filter %+v, got %+v", want, store.filter)
+	}
+	var list auditEntryList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].ActionID != "7001" || list.SkipToken != "11" {
+		t.Fatalf("unexpected page: %+v", list)
+	}
+}
+
+func TestAdminListAuditRejectsBadQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=5000", "skipToken=abc"} {
+		rec := httptest.NewRecorder()
+		adminListAudit(&fakeAuditLogStore{})(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/audit?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
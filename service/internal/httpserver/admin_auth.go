@@ -21,12 +21,8 @@ func requireAdminAuth(expectedToken string, next http.HandlerFunc) http.HandlerF
 }
 
 func constantTimeBearerMatch(expectedToken, authHeader string) bool {
-	const prefix = "Bearer "
-	if !strings.HasPrefix(authHeader, prefix) {
-		return false
-	}
-	presented := strings.TrimPrefix(authHeader, prefix)
-	if len(presented) != len(expectedToken) {
+	presented, ok := bearerToken(authHeader)
+	if !ok || len(presented) != len(expectedToken) {
 		return false
 	}
 	return subtle.ConstantTimeCompare([]byte(presented), []byte(expectedToken)) == 1
@@ -0,0 +1,110 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type workspaceBindingEntry struct {
+	Kind            string `json:"kind"`
+	SecaRef         string `json:"secaRef"`
+	ProviderRef     string `json:"providerRef"`
+	Status          string `json:"status"`
+	ResourceVersion int64  `json:"resourceVersion"`
+	CreatedAt       string `json:"createdAt"`
+	UpdatedAt       string `json:"updatedAt"`
+	// Consistent is false when the provider has no labelled resource for the
+	// binding and absent for kinds that are not checked.
+	Consistent *bool `json:"consistent,omitempty"`
+}
+
+type workspaceBindingList struct {
+	Items []workspaceBindingEntry `json:"items"`
+}
+
+// adminListWorkspaceBindings lists every resource binding of the workspace
+// and checks instances, block storages, security groups and public IPs
+// against the labelled provider resources.
+func adminListWorkspaceBindings(store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		bindings, err := store.ListWorkspaceResourceBindings(ctx, tenant, workspace)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list resource bindings", r.URL.Path)
+			return
+		}
+		present := map[string]map[string]struct{}{}
+		for _, scan := range rebuildScans(computeProvider, networkProvider, tenant, workspace) {
+			candidates, err := scan.list(ctx)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			refs := make(map[string]struct{}, len(candidates))
+			for _, candidate := range candidates {
+				refs[strings.ToLower(candidate.secaRef)] = struct{}{}
+			}
+			present[scan.kind] = refs
+		}
+		respondJSON(w, http.StatusOK, workspaceBindingList{Items: workspaceBindingEntries(bindings, present)})
+	}
+}
+
+// workspaceBindingEntries renders bindings, marking those of a checked kind
+// by whether present, keyed by kind and lower-cased secaRef, holds them.
+func workspaceBindingEntries(bindings []state.ResourceBinding, present map[string]map[string]struct{}) []workspaceBindingEntry {
+	out := make([]workspaceBindingEntry, 0, len(bindings))
+	for _, binding := range bindings {
+		entry := workspaceBindingEntry{
+			Kind:            binding.Kind,
+			SecaRef:         binding.SecaRef,
+			ProviderRef:     binding.ProviderRef,
+			Status:          binding.Status,
+			ResourceVersion: binding.ResourceVersion,
+			CreatedAt:       binding.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:       binding.UpdatedAt.Format(time.RFC3339),
+		}
+		if refs, checked := present[binding.Kind]; checked {
+			_, found := refs[strings.ToLower(binding.SecaRef)]
+			entry.Consistent = &found
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// adminDeleteWorkspaceBinding removes one binding of the workspace without
+// touching the provider resource it points at.
+func adminDeleteWorkspaceBinding(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		secaRef := r.PathValue("secaRef")
+		binding, err := store.GetResourceBinding(r.Context(), secaRef)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to read resource binding", r.URL.Path)
+			return
+		}
+		if binding == nil || !strings.EqualFold(binding.Tenant, tenant) || !strings.EqualFold(binding.Workspace, workspace) {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "resource binding not found", r.URL.Path)
+			return
+		}
+		if err := store.DeleteResourceBinding(r.Context(), binding.SecaRef); err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete resource binding", r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
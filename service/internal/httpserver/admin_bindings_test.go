@@ -0,0 +1,34 @@
+package httpserver
+
+import (
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestWorkspaceBindingEntriesFlagsMissingProviderResources(t *testing.T) {
+	t.Parallel()
+
+	bindings := []state.ResourceBinding{
+		{Kind: "instance", SecaRef: computeInstanceRef("t1", "ws1", "web"), ProviderRef: "hetzner.cloud/servers/1", Status: "active"},
+		{Kind: "instance", SecaRef: computeInstanceRef("t1", "ws1", "gone"), ProviderRef: "hetzner.cloud/servers/2", Status: "active"},
+		{Kind: resourceBindingKindSubnet, SecaRef: "seca.network/v1/tenants/t1/workspaces/ws1/networks/n1/subnets/s1", Status: "active"},
+	}
+	present := map[string]map[string]struct{}{
+		"instance": {computeInstanceRef("t1", "ws1", "web"): {}},
+	}
+
+	entries := workspaceBindingEntries(bindings, present)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if entries[0].Consistent == nil || !*entries[0].Consistent {
+		t.Fatalf("expected %s to be consistent, got %+v", entries[0].SecaRef, entries[0])
+	}
+	if entries[1].Consistent == nil || *entries[1].Consistent {
+		t.Fatalf("expected %s to be flagged, got %+v", entries[1].SecaRef, entries[1])
+	}
+	if entries[2].Consistent != nil {
+		t.Fatalf("expected unchecked kinds to carry no flag, got %+v", entries[2])
+	}
+}
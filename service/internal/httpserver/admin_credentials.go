@@ -0,0 +1,49 @@
+package httpserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// credentialRotationStore is the part of *state.Store the key rotation uses.
+type credentialRotationStore interface {
+	ReencryptWorkspaceProviderCredentials(ctx context.Context) (state.CredentialRotationResult, error)
+}
+
+type credentialRotationFailure struct {
+	Tenant    string `json:"tenant"`
+	Workspace string `json:"workspace"`
+	Provider  string `json:"provider"`
+	Error     string `json:"error"`
+}
+
+type credentialRotationResponse struct {
+	Rotated   int                         `json:"rotated"`
+	Unchanged int                         `json:"unchanged"`
+	Failures  []credentialRotationFailure `json:"failures"`
+}
+
+// adminRotateCredentialsKey re-encrypts stored provider credentials under the
+// first SECA_CREDENTIALS_KEY. Rows that fail are listed and left as they are.
+func adminRotateCredentialsKey(store credentialRotationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := store.ReencryptWorkspaceProviderCredentials(r.Context())
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		resp := credentialRotationResponse{
+			Rotated:   result.Rotated,
+			Unchanged: result.Unchanged,
+			Failures:  make([]credentialRotationFailure, 0, len(result.Failures)),
+		}
+		for _, failure := range result.Failures {
+			log.Printf("credentials key rotation failed for %s/%s/%s: %s", failure.Tenant, failure.Workspace, failure.Provider, failure.Error)
+			resp.Failures = append(resp.Failures, credentialRotationFailure(failure))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
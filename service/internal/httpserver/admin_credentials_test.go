@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeCredentialRotationStore struct {
+	result state.CredentialRotationResult
+}
+
+func (s fakeCredentialRotationStore) ReencryptWorkspaceProviderCredentials(context.Context) (state.CredentialRotationResult, error) {
+	return s.result, nil
+}
+
+func TestAdminRotateCredentialsKeyReportsFailures(t *testing.T) {
+	t.Parallel()
+
+	store := fakeCredentialRotationStore{result: state.CredentialRotationResult{
+		Rotated:   3,
+		Unchanged: 1,
+		Failures:  []state.CredentialRotationFailure{{Tenant: "acme", Workspace: "ws1", Provider: "hetzner", Error: "decrypt: ciphertext uses credentials key 1a2b3c4d, which is not configured"}},
+	}}
+	rec := httptest.NewRecorder()
+	adminRotateCredentialsKey(store)(rec, httptest.NewRequest(http.MethodPost, "/admin/v1/credentials/rotate", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp credentialRotationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Rotated != 3 || resp.Unchanged != 1 || len(resp.Failures) != 1 || resp.Failures[0].Workspace != "ws1" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// globalCredentialStore is the part of *state.Store the global credential
+// handlers use.
+type globalCredentialStore interface {
+	GetGlobalProviderCredential(ctx context.Context, provider string) (*state.GlobalProviderCredential, error)
+	UpsertGlobalProviderCredential(ctx context.Context, provider, token string) (*state.GlobalProviderCredential, error)
+	DeleteGlobalProviderCredential(ctx context.Context, provider string) (bool, error)
+}
+
+type globalCredentialRequest struct {
+	APIToken string `json:"apiToken"`
+}
+
+// globalCredentialView describes the stored global token without revealing
+// more of it than its last four characters.
+type globalCredentialView struct {
+	Provider    string `json:"provider"`
+	TokenSuffix string `json:"tokenSuffix"`
+	CreatedAt   string `json:"createdAt"`
+	RotatedAt   string `json:"rotatedAt"`
+}
+
+func adminGetGlobalHetznerCredential(store globalCredentialStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, err := store.GetGlobalProviderCredential(r.Context(), "hetzner")
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load global provider credential", r.URL.Path)
+			return
+		}
+		if cred == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "global provider credential not found", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toGlobalCredentialView(*cred))
+	}
+}
+
+// adminPutGlobalHetznerCredential validates the token against Hetzner,
+// stores it and switches the provider over to it without a restart.
+func adminPutGlobalHetznerCredential(store globalCredentialStore, manager GlobalCredentialManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req globalCredentialRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		req.APIToken = strings.TrimSpace(req.APIToken)
+		if req.APIToken == "" {
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/apiToken", "apiToken is required"))
+			return
+		}
+		if err := manager.ValidateToken(r.Context(), req.APIToken); err != nil {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "hetzner credential validation failed", r.URL.Path)
+			return
+		}
+		cred, err := store.UpsertGlobalProviderCredential(r.Context(), "hetzner", req.APIToken)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to persist global provider credential", r.URL.Path)
+			return
+		}
+		manager.SetGlobalToken(cred.APIToken)
+		respondJSON(w, http.StatusOK, toGlobalCredentialView(*cred))
+	}
+}
+
+// adminDeleteGlobalHetznerCredential drops the stored token; the provider
+// falls back to SECA_HETZNER_TOKEN.
+func adminDeleteGlobalHetznerCredential(store globalCredentialStore, manager GlobalCredentialManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := store.DeleteGlobalProviderCredential(r.Context(), "hetzner")
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete global provider credential", r.URL.Path)
+			return
+		}
+		if !deleted {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "global provider credential not found", r.URL.Path)
+			return
+		}
+		manager.SetGlobalToken("")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func toGlobalCredentialView(cred state.GlobalProviderCredential) globalCredentialView {
+	suffix := cred.APIToken
+	if len(suffix) > 4 {
+		suffix = suffix[len(suffix)-4:]
+	}
+	return globalCredentialView{
+		Provider:    cred.Provider,
+		TokenSuffix: suffix,
+		CreatedAt:   cred.CreatedAt.Format(time.RFC3339),
+		RotatedAt:   cred.RotatedAt.Format(time.RFC3339),
+	}
+}
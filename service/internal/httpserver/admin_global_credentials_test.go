@@ -0,0 +1,90 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeGlobalCredentialStore struct {
+	cred *state.GlobalProviderCredential
+}
+
+func (s *fakeGlobalCredentialStore) GetGlobalProviderCredential(context.Context, string) (*state.GlobalProviderCredential, error) {
+	return s.cred, nil
+}
+
+func (s *fakeGlobalCredentialStore) UpsertGlobalProviderCredential(_ context.Context, provider, token string) (*state.GlobalProviderCredential, error) {
+	now := time.Now().UTC()
+	if s.cred == nil {
+		s.cred = &state.GlobalProviderCredential{Provider: provider, CreatedAt: now}
+	}
+	s.cred.APIToken = token
+	s.cred.RotatedAt = now
+	return s.cred, nil
+}
+
+func (s *fakeGlobalCredentialStore) DeleteGlobalProviderCredential(context.Context, string) (bool, error) {
+	deleted := s.cred != nil
+	s.cred = nil
+	return deleted, nil
+}
+
+type fakeGlobalCredentialManager struct {
+	valid string
+	token string
+}
+
+func (m *fakeGlobalCredentialManager) ValidateToken(_ context.Context, token string) error {
+	if token != m.valid {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+func (m *fakeGlobalCredentialManager) SetGlobalToken(token string) {
+	m.token = token
+}
+
+func TestAdminGlobalHetznerCredentialLifecycle(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeGlobalCredentialStore{}
+	manager := &fakeGlobalCredentialManager{valid: "good-token-1234"}
+	do := func(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/admin/v1/providers/hetzner/credentials", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := do(adminPutGlobalHetznerCredential(store, manager), http.MethodPut, `{"apiToken":"bad-token"}`); rec.Code != http.StatusBadRequest || store.cred != nil || manager.token != "" {
+		t.Fatalf("expected a rejected token to be neither stored nor used, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := do(adminPutGlobalHetznerCredential(store, manager), http.MethodPut, `{"apiToken":"good-token-1234"}`)
+	if rec.Code != http.StatusOK || manager.token != "good-token-1234" {
+		t.Fatalf("expected the token to be stored and applied, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = do(adminGetGlobalHetznerCredential(store), http.MethodGet, "")
+	var view globalCredentialView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil || rec.Code != http.StatusOK || view.TokenSuffix != "1234" {
+		t.Fatalf("expected the redacted credential, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "good-token") {
+		t.Fatalf("expected the token to stay hidden, got %s", rec.Body.String())
+	}
+	if rec := do(adminDeleteGlobalHetznerCredential(store, manager), http.MethodDelete, ""); rec.Code != http.StatusNoContent || manager.token != "" {
+		t.Fatalf("expected delete to fall back to the env token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(adminGetGlobalHetznerCredential(store), http.MethodGet, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,266 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type rebuildBindingsReport struct {
+	DryRun   bool                  `json:"dryRun"`
+	Created  []rebuildBindingEntry `json:"created"`
+	Existing []rebuildBindingEntry `json:"existing"`
+	Dangling []rebuildBindingEntry `json:"dangling"`
+	Skipped  []rebuildBindingEntry `json:"skipped"`
+}
+
+type rebuildBindingEntry struct {
+	Kind        string `json:"kind"`
+	SecaRef     string `json:"secaRef"`
+	ProviderRef string `json:"providerRef,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// rebuildCandidate is a provider resource carrying this workspace's labels,
+// together with the binding the API would have written for it.
+type rebuildCandidate struct {
+	labels      map[string]string
+	secaRef     string
+	providerRef string
+	binding     string
+}
+
+// adminRebuildWorkspaceBindings recreates resource bindings for provider
+// resources labelled with the workspace. Provider resources are only read;
+// bindings without a provider resource are reported, never removed.
+func adminRebuildWorkspaceBindings(store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		dryRun := false
+		if raw := strings.TrimSpace(r.URL.Query().Get("dryRun")); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "dryRun must be a boolean", r.URL.Path)
+				return
+			}
+			dryRun = parsed
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+
+		report := rebuildBindingsReport{
+			DryRun:   dryRun,
+			Created:  []rebuildBindingEntry{},
+			Existing: []rebuildBindingEntry{},
+			Dangling: []rebuildBindingEntry{},
+			Skipped:  []rebuildBindingEntry{},
+		}
+		for _, scan := range rebuildScans(computeProvider, networkProvider, tenant, workspace) {
+			candidates, err := scan.list(ctx)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if err := reconcileRebuildCandidates(ctx, store, tenant, workspace, scan.kind, candidates, dryRun, &report); err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to rebuild "+scan.kind+" bindings", r.URL.Path)
+				return
+			}
+		}
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
+// rebuildScan lists the provider resources of one binding kind that carry
+// the workspace's labels.
+type rebuildScan struct {
+	kind string
+	list func(context.Context) ([]rebuildCandidate, error)
+}
+
+func rebuildScans(computeProvider ComputeStorageProvider, networkProvider NetworkProvider, tenant, workspace string) []rebuildScan {
+	return []rebuildScan{
+		{kind: "instance", list: func(ctx context.Context) ([]rebuildCandidate, error) {
+			return instanceRebuildCandidates(ctx, computeProvider, tenant, workspace)
+		}},
+		{kind: "block-storage", list: func(ctx context.Context) ([]rebuildCandidate, error) {
+			return blockStorageRebuildCandidates(ctx, computeProvider, tenant, workspace)
+		}},
+		{kind: resourceBindingKindSecurityGroup, list: func(ctx context.Context) ([]rebuildCandidate, error) {
+			return securityGroupRebuildCandidates(ctx, networkProvider, tenant, workspace)
+		}},
+		{kind: resourceBindingKindPublicIP, list: func(ctx context.Context) ([]rebuildCandidate, error) {
+			return publicIPRebuildCandidates(ctx, networkProvider, tenant, workspace)
+		}},
+	}
+}
+
+func reconcileRebuildCandidates(
+	ctx context.Context,
+	store *state.Store,
+	tenant, workspace, kind string,
+	candidates []rebuildCandidate,
+	dryRun bool,
+	report *rebuildBindingsReport,
+) error {
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, kind)
+	if err != nil {
+		return err
+	}
+	bound := make(map[string]struct{}, len(bindings))
+	for _, binding := range bindings {
+		bound[strings.ToLower(binding.SecaRef)] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(candidates))
+	for _, candidate := range candidates {
+		entry := rebuildBindingEntry{Kind: kind, SecaRef: candidate.secaRef, ProviderRef: candidate.providerRef}
+		if labelKind := candidate.labels[secaLabelKind]; labelKind != "" && !strings.EqualFold(labelKind, kind) {
+			entry.Reason = fmt.Sprintf("labelled as kind %q", labelKind)
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		}
+		seen[strings.ToLower(candidate.secaRef)] = struct{}{}
+		if _, ok := bound[strings.ToLower(candidate.secaRef)]; ok {
+			report.Existing = append(report.Existing, entry)
+			continue
+		}
+		if !dryRun {
+			if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+				Tenant:      tenant,
+				Workspace:   workspace,
+				Kind:        kind,
+				SecaRef:     candidate.secaRef,
+				ProviderRef: candidate.binding,
+				Status:      "active",
+			}); err != nil {
+				return err
+			}
+		}
+		report.Created = append(report.Created, entry)
+	}
+
+	for _, binding := range bindings {
+		if _, ok := seen[strings.ToLower(binding.SecaRef)]; ok {
+			continue
+		}
+		report.Dangling = append(report.Dangling, rebuildBindingEntry{
+			Kind:    kind,
+			SecaRef: binding.SecaRef,
+			Reason:  "provider resource not found",
+		})
+	}
+	return nil
+}
+
+func instanceRebuildCandidates(ctx context.Context, provider ComputeStorageProvider, tenant, workspace string) ([]rebuildCandidate, error) {
+	instances, err := provider.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rebuildCandidate, 0, len(instances))
+	for _, instance := range instances {
+		if !providerLabelsInScope(instance.Labels, tenant, workspace) {
+			continue
+		}
+		providerRef := serverProviderRef(instance.ID, instance.Name)
+		out = append(out, rebuildCandidate{
+			labels:      instance.Labels,
+			secaRef:     computeInstanceRef(tenant, workspace, instance.Name),
+			providerRef: providerRef,
+			binding:     providerRef,
+		})
+	}
+	return out, nil
+}
+
+func blockStorageRebuildCandidates(ctx context.Context, provider ComputeStorageProvider, tenant, workspace string) ([]rebuildCandidate, error) {
+	volumes, err := provider.ListBlockStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rebuildCandidate, 0, len(volumes))
+	for _, volume := range volumes {
+		if !providerLabelsInScope(volume.Labels, tenant, workspace) {
+			continue
+		}
+		providerRef := volumeProviderRef(volume.ID, volume.Name)
+		out = append(out, rebuildCandidate{
+			labels:      volume.Labels,
+			secaRef:     blockStorageRef(tenant, workspace, volume.Name),
+			providerRef: providerRef,
+			binding:     providerRef,
+		})
+	}
+	return out, nil
+}
+
+func securityGroupRebuildCandidates(ctx context.Context, provider NetworkProvider, tenant, workspace string) ([]rebuildCandidate, error) {
+	groups, err := provider.ListSecurityGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rebuildCandidate, 0, len(groups))
+	for _, group := range groups {
+		if !providerLabelsInScope(group.Labels, tenant, workspace) {
+			continue
+		}
+		name := strings.ToLower(group.Name)
+		raw, err := json.Marshal(securityGroupBindingPayload{
+			Name:   name,
+			Region: "global",
+			Labels: userProviderLabels(group.Labels),
+			Spec:   securityGroupSpec{Rules: toSecurityGroupRuleSpecs(group.Rules)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rebuildCandidate{
+			labels:      group.Labels,
+			secaRef:     securityGroupRef(tenant, workspace, name),
+			providerRef: "hetzner.cloud/firewalls/" + name,
+			binding:     string(raw),
+		})
+	}
+	return out, nil
+}
+
+func publicIPRebuildCandidates(ctx context.Context, provider NetworkProvider, tenant, workspace string) ([]rebuildCandidate, error) {
+	ips, err := provider.ListPublicIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rebuildCandidate, 0, len(ips))
+	for _, ip := range ips {
+		if !providerLabelsInScope(ip.Labels, tenant, workspace) {
+			continue
+		}
+		payload := publicIPBindingPayload{
+			Name:   ip.Name,
+			Region: ip.Region,
+			Labels: userProviderLabels(ip.Labels),
+			Spec:   publicIPSpec{Version: ip.Version},
+		}
+		applyAllocatedPublicIP(&payload, ip)
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rebuildCandidate{
+			labels:      ip.Labels,
+			secaRef:     publicIPRef(tenant, workspace, ip.Name),
+			providerRef: payload.ProviderRef,
+			binding:     string(raw),
+		})
+	}
+	return out, nil
+}
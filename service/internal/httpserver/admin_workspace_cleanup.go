@@ -0,0 +1,231 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type workspaceCleanupReport struct {
+	DryRun     bool                    `json:"dryRun"`
+	Candidates []workspaceCleanupEntry `json:"candidates"`
+	Removed    []workspaceCleanupEntry `json:"removed"`
+	Failed     []workspaceCleanupEntry `json:"failed"`
+	Bindings   []string                `json:"bindings"`
+}
+
+type workspaceCleanupEntry struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	ProviderRef string `json:"providerRef"`
+	SecaRef     string `json:"secaRef"`
+	Error       string `json:"error,omitempty"`
+}
+
+// workspaceCleanupCandidate is a Hetzner resource carrying the workspace's
+// labels, with the call that deletes it.
+type workspaceCleanupCandidate struct {
+	entry  workspaceCleanupEntry
+	remove func(ctx context.Context) error
+}
+
+// adminCleanupWorkspace deletes every Hetzner resource labelled with the
+// workspace, whether or not the store still binds it, and drops the bindings
+// of what it removed. With ?dryRun=true it only lists the candidates.
+func adminCleanupWorkspace(store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		dryRun := false
+		if raw := strings.TrimSpace(r.URL.Query().Get("dryRun")); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondValidationProblem(w, r.URL.Path, fieldParameter("dryRun", "dryRun must be a boolean"))
+				return
+			}
+			dryRun = parsed
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		steps, err := workspaceCleanupSteps(ctx, computeProvider, networkProvider, tenant, workspace)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		bindings, err := store.ListWorkspaceResourceBindings(ctx, tenant, workspace)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load resource bindings", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, runWorkspaceCleanup(ctx, steps, bindings, store.DeleteResourceBinding, dryRun))
+	}
+}
+
+// workspaceCleanupSteps lists the workspace's labelled resources in deletion
+// order: volume detaches, servers, volumes, floating IPs, firewalls and
+// networks. Each step must finish before the next one starts.
+func workspaceCleanupSteps(ctx context.Context, computeProvider ComputeStorageProvider, networkProvider NetworkProvider, tenant, workspace string) ([][]workspaceCleanupCandidate, error) {
+	labels := map[string]string{
+		secaLabelManaged:   "true",
+		secaLabelTenant:    compactLabelValue(tenant),
+		secaLabelWorkspace: compactLabelValue(workspace),
+	}
+	instances, err := computeProvider.ListInstancesByLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := computeProvider.ListBlockStoragesByLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := networkProvider.ListPublicIPsByLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := networkProvider.ListSecurityGroupsByLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := networkProvider.ListNetworksByLabels(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([][]workspaceCleanupCandidate, 6)
+	for _, volume := range volumes {
+		if volume.AttachedTo == "" {
+			continue
+		}
+		name := volume.Name
+		steps[0] = append(steps[0], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: "block-storage-attachment", Name: name, ProviderRef: volumeProviderRef(volume.ID, name)},
+			remove: func(ctx context.Context) error {
+				_, actionID, err := computeProvider.DetachBlockStorage(ctx, name)
+				if err != nil {
+					return err
+				}
+				return waitForCleanupAction(ctx, computeProvider, actionID)
+			},
+		})
+	}
+	for _, instance := range instances {
+		name := instance.Name
+		secaRef := computeInstanceRef(tenant, workspace, name)
+		if instance.Labels[secaLabelKind] == resourceBindingKindInternetGateway {
+			secaRef = internetGatewayRef(tenant, workspace, instance.Labels[secaLabelName])
+		}
+		steps[1] = append(steps[1], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: "instance", Name: name, ProviderRef: serverProviderRef(instance.ID, name), SecaRef: secaRef},
+			remove: func(ctx context.Context) error {
+				_, actionID, err := computeProvider.DeleteInstance(ctx, name)
+				if err != nil {
+					return err
+				}
+				return waitForCleanupAction(ctx, computeProvider, actionID)
+			},
+		})
+	}
+	for _, volume := range volumes {
+		name := volume.Name
+		steps[2] = append(steps[2], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: "block-storage", Name: name, ProviderRef: volumeProviderRef(volume.ID, name), SecaRef: blockStorageRef(tenant, workspace, name)},
+			remove: func(ctx context.Context) error {
+				_, err := computeProvider.DeleteBlockStorage(ctx, name, true)
+				return err
+			},
+		})
+	}
+	for _, ip := range ips {
+		name := ip.Name
+		steps[3] = append(steps[3], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: resourceBindingKindPublicIP, Name: name, ProviderRef: fmt.Sprintf("hetzner.cloud/floating-ips/%d", ip.ID), SecaRef: publicIPRef(tenant, workspace, name)},
+			remove: func(ctx context.Context) error {
+				_, err := networkProvider.DeletePublicIP(ctx, name)
+				return err
+			},
+		})
+	}
+	for _, group := range groups {
+		name := strings.ToLower(group.Name)
+		steps[4] = append(steps[4], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: resourceBindingKindSecurityGroup, Name: name, ProviderRef: "hetzner.cloud/firewalls/" + name, SecaRef: securityGroupRef(tenant, workspace, name)},
+			remove: func(ctx context.Context) error {
+				_, err := networkProvider.DeleteSecurityGroup(ctx, name)
+				return err
+			},
+		})
+	}
+	for _, network := range networks {
+		name := network.Name
+		steps[5] = append(steps[5], workspaceCleanupCandidate{
+			entry: workspaceCleanupEntry{Kind: "network", Name: name, ProviderRef: "hetzner.cloud/networks/" + name, SecaRef: strings.ToLower("seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + name)},
+			remove: func(ctx context.Context) error {
+				_, err := networkProvider.DeleteNetwork(ctx, name)
+				return err
+			},
+		})
+	}
+	return steps, nil
+}
+
+// runWorkspaceCleanup runs the steps in order and, for every removed
+// resource, drops its binding and the bindings nested under it, such as a
+// network's subnets. Failures are reported and do not stop later steps.
+func runWorkspaceCleanup(ctx context.Context, steps [][]workspaceCleanupCandidate, bindings []state.ResourceBinding, deleteBinding func(ctx context.Context, secaRef string) error, dryRun bool) workspaceCleanupReport {
+	report := workspaceCleanupReport{
+		DryRun:     dryRun,
+		Candidates: []workspaceCleanupEntry{},
+		Removed:    []workspaceCleanupEntry{},
+		Failed:     []workspaceCleanupEntry{},
+		Bindings:   []string{},
+	}
+	for _, step := range steps {
+		for _, candidate := range step {
+			report.Candidates = append(report.Candidates, candidate.entry)
+			if dryRun {
+				continue
+			}
+			entry := candidate.entry
+			if err := candidate.remove(ctx); err != nil {
+				entry.Error = err.Error()
+				report.Failed = append(report.Failed, entry)
+				continue
+			}
+			report.Removed = append(report.Removed, entry)
+			if entry.SecaRef == "" {
+				continue
+			}
+			for _, binding := range bindings {
+				ref := strings.ToLower(binding.SecaRef)
+				prefix := strings.ToLower(entry.SecaRef)
+				if ref != prefix && !strings.HasPrefix(ref, prefix+"/") {
+					continue
+				}
+				if err := deleteBinding(ctx, binding.SecaRef); err != nil {
+					report.Failed = append(report.Failed, workspaceCleanupEntry{Kind: binding.Kind, SecaRef: binding.SecaRef, Error: err.Error()})
+					continue
+				}
+				report.Bindings = append(report.Bindings, binding.SecaRef)
+			}
+		}
+	}
+	return report
+}
+
+// waitForCleanupAction waits for a provider action so that the next cleanup
+// step does not race it.
+func waitForCleanupAction(ctx context.Context, provider ComputeStorageProvider, actionID string) error {
+	id, err := strconv.ParseInt(actionID, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return provider.WaitForAction(ctx, id)
+}
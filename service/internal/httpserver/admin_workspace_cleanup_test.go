@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// fakeCleanupComputeProvider implements the ComputeStorageProvider calls a
+// workspace cleanup makes; any other call panics on the nil embedded interface.
+type fakeCleanupComputeProvider struct {
+	ComputeStorageProvider
+	instances []hetzner.Instance
+	volumes   []hetzner.BlockStorage
+	calls     *[]string
+}
+
+func (f *fakeCleanupComputeProvider) ListInstancesByLabels(_ context.Context, labels map[string]string) ([]hetzner.Instance, error) {
+	if labels[secaLabelWorkspace] != "ws1" {
+		return nil, nil
+	}
+	return f.instances, nil
+}
+
+func (f *fakeCleanupComputeProvider) ListBlockStoragesByLabels(context.Context, map[string]string) ([]hetzner.BlockStorage, error) {
+	return f.volumes, nil
+}
+
+func (f *fakeCleanupComputeProvider) DetachBlockStorage(_ context.Context, name string) (bool, string, error) {
+	*f.calls = append(*f.calls, "detach/"+name)
+	return true, "", nil
+}
+
+func (f *fakeCleanupComputeProvider) DeleteInstance(_ context.Context, name string) (bool, string, error) {
+	*f.calls = append(*f.calls, "instance/"+name)
+	return true, "", nil
+}
+
+func (f *fakeCleanupComputeProvider) DeleteBlockStorage(_ context.Context, name string, _ bool) (bool, error) {
+	*f.calls = append(*f.calls, "block-storage/"+name)
+	return true, nil
+}
+
+type fakeCleanupNetworkProvider struct {
+	NetworkProvider
+	calls *[]string
+}
+
+func (f *fakeCleanupNetworkProvider) ListPublicIPsByLabels(context.Context, map[string]string) ([]hetzner.PublicIP, error) {
+	return []hetzner.PublicIP{{ID: 3, Name: "ip1"}}, nil
+}
+
+func (f *fakeCleanupNetworkProvider) ListSecurityGroupsByLabels(context.Context, map[string]string) ([]hetzner.SecurityGroup, error) {
+	return []hetzner.SecurityGroup{{Name: "web"}}, nil
+}
+
+func (f *fakeCleanupNetworkProvider) ListNetworksByLabels(context.Context, map[string]string) ([]hetzner.Network, error) {
+	return []hetzner.Network{{Name: "net"}}, nil
+}
+
+func (f *fakeCleanupNetworkProvider) DeletePublicIP(_ context.Context, name string) (bool, error) {
+	*f.calls = append(*f.calls, "public-ip/"+name)
+	return true, nil
+}
+
+func (f *fakeCleanupNetworkProvider) DeleteSecurityGroup(_ context.Context, name string) (bool, error) {
+	*f.calls = append(*f.calls, "security-group/"+name)
+	return false, errors.New("firewall still applied")
+}
+
+func (f *fakeCleanupNetworkProvider) DeleteNetwork(_ context.Context, name string) (bool, error) {
+	*f.calls = append(*f.calls, "network/"+name)
+	return true, nil
+}
+
+func TestWorkspaceCleanupDeletesInDependencyOrder(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	compute := &fakeCleanupComputeProvider{
+		instances: []hetzner.Instance{{ID: 1, Name: "vm1"}},
+		volumes:   []hetzner.BlockStorage{{ID: 2, Name: "data", AttachedTo: "vm1"}},
+		calls:     &calls,
+	}
+	network := &fakeCleanupNetworkProvider{calls: &calls}
+	steps, err := workspaceCleanupSteps(context.Background(), compute, network, "t1", "ws1")
+	if err != nil {
+		t.Fatalf("steps: %v", err)
+	}
+
+	dry := runWorkspaceCleanup(context.Background(), steps, nil, nil, true)
+	if len(calls) != 0 || len(dry.Candidates) != 6 || len(dry.Removed) != 0 {
+		t.Fatalf("expected a dry run to only list 6 candidates, got calls %v and report %+v", calls, dry)
+	}
+
+	bindings := []state.ResourceBinding{
+		{Kind: "instance", SecaRef: computeInstanceRef("t1", "ws1", "vm1")},
+		{Kind: resourceBindingKindSubnet, SecaRef: "seca.network/v1/tenants/t1/workspaces/ws1/networks/net/subnets/a"},
+		{Kind: resourceBindingKindSecurityGroup, SecaRef: securityGroupRef("t1", "ws1", "web")},
+	}
+	var dropped []string
+	deleteBinding := func(_ context.Context, ref string) error {
+		dropped = append(dropped, ref)
+		return nil
+	}
+	report := runWorkspaceCleanup(context.Background(), steps, bindings, deleteBinding, false)
+
+	wantCalls := []string{"detach/data", "instance/vm1", "block-storage/data", "public-ip/ip1", "security-group/web", "network/net"}
+	if !slices.Equal(calls, wantCalls) {
+		t.Fatalf("expected provider calls %v, got %v", wantCalls, calls)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Name != "web" || len(report.Removed) != 5 {
+		t.Fatalf("expected only the firewall to fail, got %+v", report)
+	}
+	wantDropped := []string{bindings[0].SecaRef, bindings[1].SecaRef}
+	if !slices.Equal(dropped, wantDropped) || !slices.Equal(report.Bindings, wantDropped) {
+		t.Fatalf("expected bindings %v dropped, got %v (report %v)", wantDropped, dropped, report.Bindings)
+	}
+}
@@ -1,9 +1,10 @@
 package httpserver
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
@@ -20,19 +21,34 @@ type workspaceProviderBindResponse struct {
 	Provider string `json:"provider"`
 }
 
-func adminWorkspaceHetznerBinding(store *state.Store, regionProvider RegionProvider) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPut:
-			adminPutWorkspaceHetznerBinding(store, regionProvider)(w, r)
-		case http.MethodGet:
-			adminGetWorkspaceHetznerBinding(store)(w, r)
-		case http.MethodDelete:
-			adminDeleteWorkspaceHetznerBinding(store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
+type workspaceProviderRotateRequest struct {
+	APIToken string `json:"apiToken"`
+}
+
+// workspaceProviderBinding describes a stored credential; the token itself
+// never leaves the store through the admin API.
+type workspaceProviderBinding struct {
+	Workspace   string `json:"workspace"`
+	Provider    string `json:"provider"`
+	ProjectRef  string `json:"projectRef"`
+	APIEndpoint string `json:"apiEndpoint"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+type workspaceProviderBindingList struct {
+	Items []workspaceProviderBinding `json:"items"`
+}
+
+// workspaceCredentialStore is the part of *state.Store the credential
+// listing, rotation and revocation handlers use.
+type workspaceCredentialStore interface {
+	ListWorkspaceProviderCredentials(ctx context.Context, tenant string) ([]state.WorkspaceProviderCredential, error)
+	GetWorkspaceProviderCredential(ctx context.Context, tenant, workspace, provider string) (*state.WorkspaceProviderCredential, error)
+	RotateWorkspaceProviderCredentialToken(ctx context.Context, tenant, workspace, provider, token string) (*state.WorkspaceProviderCredential, error)
+	SoftDeleteWorkspaceProviderCredential(ctx context.Context, tenant, workspace, provider string) (bool, error)
+	GetWorkspace(ctx context.Context, tenant, name string) (*state.WorkspaceResource, error)
+	UpsertWorkspace(ctx context.Context, resource state.WorkspaceResource) (*state.WorkspaceResource, error)
 }
 
 func adminPutWorkspaceHetznerBinding(store *state.Store, regionProvider RegionProvider) http.HandlerFunc {
@@ -52,8 +68,7 @@ func adminPutWorkspaceHetznerBinding(store *state.Store, regionProvider RegionPr
 		}
 
 		var req workspaceProviderBindRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
 			return
 		}
 		req.APIToken = strings.TrimSpace(req.APIToken)
@@ -97,7 +112,7 @@ func adminPutWorkspaceHetznerBinding(store *state.Store, regionProvider RegionPr
 	}
 }
 
-func adminGetWorkspaceHetznerBinding(store *state.Store) http.HandlerFunc {
+func adminGetWorkspaceHetznerBinding(store workspaceCredentialStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
@@ -121,7 +136,7 @@ func adminGetWorkspaceHetznerBinding(store *state.Store) http.HandlerFunc {
 	}
 }
 
-func adminDeleteWorkspaceHetznerBinding(store *state.Store) http.HandlerFunc {
+func adminDeleteWorkspaceHetznerBinding(store workspaceCredentialStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
@@ -145,3 +160,82 @@ func adminDeleteWorkspaceHetznerBinding(store *state.Store) http.HandlerFunc {
 		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
 	}
 }
+
+func adminListHetznerBindings(store workspaceCredentialStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		creds, err := store.ListWorkspaceProviderCredentials(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list workspace provider credentials", r.URL.Path)
+			return
+		}
+		items := make([]workspaceProviderBinding, 0, len(creds))
+		for _, cred := range creds {
+			if cred.Provider == "hetzner" {
+				items = append(items, toWorkspaceProviderBinding(cred))
+			}
+		}
+		respondJSON(w, http.StatusOK, workspaceProviderBindingList{Items: items})
+	}
+}
+
+// adminRotateWorkspaceHetznerBinding swaps the token of an existing binding
+// in a single update, after validating it against the binding's endpoint.
+// Project and endpoint stay as they were bound.
+func adminRotateWorkspaceHetznerBinding(store workspaceCredentialStore, regionProvider RegionProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		var req workspaceProviderRotateRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		req.APIToken = strings.TrimSpace(req.APIToken)
+		if req.APIToken == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "apiToken is required", r.URL.Path)
+			return
+		}
+
+		current, err := store.GetWorkspaceProviderCredential(r.Context(), tenant, workspace, "hetzner")
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load workspace provider credential", r.URL.Path)
+			return
+		}
+		if current == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "workspace provider credential not found", r.URL.Path)
+			return
+		}
+
+		validateCtx := hetzner.WithWorkspaceCredential(r.Context(), hetzner.WorkspaceCredential{
+			Token:       req.APIToken,
+			CloudAPIURL: current.APIEndpoint,
+		})
+		if _, err := regionProvider.ListRegions(validateCtx); err != nil {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "hetzner credential validation failed", r.URL.Path)
+			return
+		}
+
+		rotated, err := store.RotateWorkspaceProviderCredentialToken(r.Context(), tenant, workspace, "hetzner", req.APIToken)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to rotate workspace provider credential", r.URL.Path)
+			return
+		}
+		if rotated == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "workspace provider credential not found", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toWorkspaceProviderBinding(*rotated))
+	}
+}
+
+func toWorkspaceProviderBinding(cred state.WorkspaceProviderCredential) workspaceProviderBinding {
+	return workspaceProviderBinding{
+		Workspace:   cred.Workspace,
+		Provider:    cred.Provider,
+		ProjectRef:  cred.ProjectRef,
+		APIEndpoint: cred.APIEndpoint,
+		CreatedAt:   cred.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   cred.UpdatedAt.Format(time.RFC3339),
+	}
+}
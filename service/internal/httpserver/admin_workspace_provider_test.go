@@ -0,0 +1,189 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeBindingStore struct {
+	creds      map[string]state.WorkspaceProviderCredential
+	workspaces map[string]state.WorkspaceResource
+}
+
+func newFakeBindingStore(creds ...state.WorkspaceProviderCredential) *fakeBindingStore {
+	store := &fakeBindingStore{
+		creds:      map[string]state.WorkspaceProviderCredential{},
+		workspaces: map[string]state.WorkspaceResource{},
+	}
+	for _, cred := range creds {
+		store.creds[cred.Tenant+"/"+cred.Workspace+"/"+cred.Provider] = cred
+		store.workspaces[cred.Tenant+"/"+cred.Workspace] = state.WorkspaceResource{Tenant: cred.Tenant, Name: cred.Workspace, Status: map[string]any{"state": "active"}}
+	}
+	return store
+}
+
+func (f *fakeBindingStore) ListWorkspaceProviderCredentials(_ context.Context, tenant string) ([]state.WorkspaceProviderCredential, error) {
+	var out []state.WorkspaceProviderCredential
+	for _, cred := range f.creds {
+		if cred.Tenant == tenant {
+			cred.APIToken = ""
+			out = append(out, cred)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBindingStore) GetWorkspaceProviderCredential(_ context.Context, tenant, workspace, provider string) (*state.WorkspaceProviderCredential, error) {
+	cred, ok := f.creds[tenant+"/"+workspace+"/"+provider]
+	if !ok {
+		return nil, nil
+	}
+	return &cred, nil
+}
+
+func (f *fakeBindingStore) RotateWorkspaceProviderCredentialToken(_ context.Context, tenant, workspace, provider, token string) (*state.WorkspaceProviderCredential, error) {
+	key := tenant + "/" + workspace + "/" + provider
+	cred, ok := f.creds[key]
+	if !ok {
+		return nil, nil
+	}
+	cred.APIToken = token
+	cred.UpdatedAt = cred.UpdatedAt.Add(time.Minute)
+	f.creds[key] = cred
+	cred.APIToken = ""
+	return &cred, nil
+}
+
+func (f *fakeBindingStore) SoftDeleteWorkspaceProviderCredential(_ context.Context, tenant, workspace, provider string) (bool, error) {
+	key := tenant + "/" + workspace + "/" + provider
+	if _, ok := f.creds[key]; !ok {
+		return false, nil
+	}
+	delete(f.creds, key)
+	return true, nil
+}
+
+func (f *fakeBindingStore) GetWorkspace(_ context.Context, tenant, name string) (*state.WorkspaceResource, error) {
+	ws, ok := f.workspaces[tenant+"/"+name]
+	if !ok {
+		return nil, nil
+	}
+	return &ws, nil
+}
+
+func (f *fakeBindingStore) UpsertWorkspace(_ context.Context, resource state.WorkspaceResource) (*state.WorkspaceResource, error) {
+	f.workspaces[resource.Tenant+"/"+resource.Name] = resource
+	return &resource, nil
+}
+
+// rejectingRegionProvider fails every call, like Hetzner refusing a token.
+type rejectingRegionProvider struct {
+	fakeRegionProvider
+}
+
+func (rejectingRegionProvider) ListRegions(context.Context) ([]hetzner.Region, error) {
+	return nil, errors.New("unauthorized")
+}
+
+func credentialAdminMux(store workspaceCredentialStore, regionProvider RegionProvider) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/v1/tenants/{tenant}/providers/hetzner", adminListHetznerBindings(store))
+	mux.HandleFunc("DELETE /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner", adminDeleteWorkspaceHetznerBinding(store))
+	mux.HandleFunc("POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner/rotate", adminRotateWorkspaceHetznerBinding(store, regionProvider))
+	return mux
+}
+
+func TestAdminListHetznerBindingsOmitsTokens(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := newFakeBindingStore(
+		state.WorkspaceProviderCredential{Tenant: "t1", Workspace: "ws1", Provider: "hetzner", ProjectRef: "p1", APIEndpoint: "https://api.example", APIToken: "secret-1", CreatedAt: created, UpdatedAt: created},
+		state.WorkspaceProviderCredential{Tenant: "t2", Workspace: "ws2", Provider: "hetzner", APIToken: "secret-2"},
+	)
+
+	rec := httptest.NewRecorder()
+	credentialAdminMux(store, fakeRegionProvider{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/tenants/t1/providers/hetzner", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("listing leaked a token: %s", rec.Body.String())
+	}
+	var list workspaceProviderBindingList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	want := workspaceProviderBinding{Workspace: "ws1", Provider: "hetzner", ProjectRef: "p1", APIEndpoint: "https://api.example", CreatedAt: "2026-01-02T03:04:05Z", UpdatedAt: "2026-01-02T03:04:05Z"}
+	if len(list.Items) != 1 || list.Items[0] != want {
+		t.Fatalf("expected only the t1 binding %+v, got %+v", want, list.Items)
+	}
+}
+
+func TestAdminRotateWorkspaceHetznerBinding(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeBindingStore(state.WorkspaceProviderCredential{Tenant: "t1", Workspace: "ws1", Provider: "hetzner", ProjectRef: "p1", APIToken: "old-token"})
+	rotate := func(regionProvider RegionProvider, workspace string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		target := "/admin/v1/tenants/t1/workspaces/" + workspace + "/providers/hetzner/rotate"
+		credentialAdminMux(store, regionProvider).ServeHTTP(rec, jsonRequest(http.MethodPost, target, `{"apiToken":"new-token"}`))
+		return rec
+	}
+
+	if rec := rotate(rejectingRegionProvider{}, "ws1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a token Hetzner rejects, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := store.creds["t1/ws1/hetzner"].APIToken; got != "old-token" {
+		t.Fatalf("rejected rotation replaced the token with %q", got)
+	}
+
+	rec := rotate(fakeRegionProvider{}, "ws1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "new-token") {
+		t.Fatalf("rotation response leaked the token: %s", rec.Body.String())
+	}
+	if cred := store.creds["t1/ws1/hetzner"]; cred.APIToken != "new-token" || cred.ProjectRef != "p1" {
+		t.Fatalf("expected the token rotated and the project kept, got %+v", cred)
+	}
+
+	if rec := rotate(fakeRegionProvider{}, "missing"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unbound workspace, got %d", rec.Code)
+	}
+}
+
+func TestAdminDeleteWorkspaceHetznerBindingRevokes(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeBindingStore(state.WorkspaceProviderCredential{Tenant: "t1", Workspace: "ws1", Provider: "hetzner", APIToken: "secret"})
+	mux := credentialAdminMux(store, fakeRegionProvider{})
+	remove := func() int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/v1/tenants/t1/workspaces/ws1/providers/hetzner", nil))
+		return rec.Code
+	}
+
+	if code := remove(); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if _, ok := store.creds["t1/ws1/hetzner"]; ok {
+		t.Fatal("expected the credential to be revoked")
+	}
+	if got := store.workspaces["t1/ws1"].Status["state"]; got != "creating" {
+		t.Fatalf("expected the workspace back in creating, got %v", got)
+	}
+	if code := remove(); code != http.StatusNotFound {
+		t.Fatalf("expected 404 on a second delete, got %d", code)
+	}
+}
@@ -1,11 +1,9 @@
 package httpserver
 
 import (
-	"encoding/json"
 	"net/http"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
@@ -24,10 +22,6 @@ type authResource struct {
 
 func listRoles(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		if tenant == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
@@ -40,139 +34,183 @@ func listRoles(store *state.Store) http.HandlerFunc {
 		}
 		out := make([]authResource, 0, len(items))
 		for _, item := range items {
-			out = append(out, toAuthResource("roles", "role", http.MethodGet, item))
+			out = append(out, toAuthResource("roles", "role", verbList, item))
 		}
 		sort.Slice(out, func(i, j int) bool {
 			return out[i].Metadata.Name < out[j].Metadata.Name
 		})
 		respondJSON(w, http.StatusOK, authIterator{
 			Items:    out,
-			Metadata: responseMetaObject{Provider: "seca.authorization/v1", Resource: "tenants/" + tenant + "/roles", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.authorization/v1", Resource: "tenants/" + tenant + "/roles", Verb: verbList},
 		})
 	}
 }
 
-func roleCRUD(store *state.Store) http.HandlerFunc {
-	return authCRUD(store, "roles", "role")
-}
-
 func listRoleAssignments(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		if tenant == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
 			return
 		}
-		items, err := store.ListRoleAssignments(r.Context(), tenant)
+		query := r.URL.Query()
+		subject := strings.TrimSpace(query.Get("subject"))
+		var roles []string
+		if raw := query.Get("roleRef"); raw != "" {
+			role := resourceNameFromRef(raw)
+			if role == "" {
+				respondValidationProblem(w, r.URL.Path, fieldParameter("roleRef", "roleRef must name a role"))
+				return
+			}
+			roles = roleRefForms(tenant, role)
+		}
+		items, err := store.ListRoleAssignmentsMatching(r.Context(), tenant, subject, roles)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		out := make([]authResource, 0, len(items))
 		for _, item := range items {
-			out = append(out, toAuthResource("role-assignments", "role-assignment", http.MethodGet, item))
+			out = append(out, toAuthResource("role-assignments", "role-assignment", verbList, item))
 		}
 		sort.Slice(out, func(i, j int) bool {
 			return out[i].Metadata.Name < out[j].Metadata.Name
 		})
 		respondJSON(w, http.StatusOK, authIterator{
 			Items:    out,
-			Metadata: responseMetaObject{Provider: "seca.authorization/v1", Resource: "tenants/" + tenant + "/role-assignments", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.authorization/v1", Resource: "tenants/" + tenant + "/role-assignments", Verb: verbList},
 		})
 	}
 }
 
-func roleAssignmentCRUD(store *state.Store) http.HandlerFunc {
-	return authCRUD(store, "role-assignments", "role-assignment")
+// roleRefForms are the ways a role assignment can name role: bare, relative
+// to the tenant or as a full reference.
+func roleRefForms(tenant, role string) []string {
+	return []string{
+		role,
+		"roles/" + role,
+		"tenants/" + tenant + "/roles/" + role,
+		"seca.authorization/v1/tenants/" + tenant + "/roles/" + role,
+	}
 }
 
-func authCRUD(store *state.Store, collection, kind string) http.HandlerFunc {
+func getAuthResourceHandler(store *state.Store, collection, kind string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			tenant, name, _, ok := authPath(r, collection)
-			if !ok {
-				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
-				return
-			}
-			item, err := getAuthResource(r, store, collection, tenant, name)
-			if err != nil {
-				respondFromError(w, err, r.URL.Path)
-				return
-			}
-			if item == nil {
-				respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", kind+" not found", r.URL.Path)
-				return
-			}
-			out := toAuthResource(collection, kind, http.MethodGet, *item)
-			out.Status.State = "active"
-			respondJSON(w, http.StatusOK, out)
-		case http.MethodPut:
-			tenant, name, _, ok := authPath(r, collection)
-			if !ok {
-				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
-				return
-			}
-			var req authResource
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
-				return
-			}
+		tenant, name, _, ok := authPath(r, collection)
+		if !ok {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
+			return
+		}
+		item, err := getAuthResource(r, store, collection, tenant, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if item == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", kind+" not found", r.URL.Path)
+			return
+		}
+		out := toAuthResource(collection, kind, verbGet, *item)
+		out.Status.State = "active"
+		respondJSON(w, http.StatusOK, out)
+	}
+}
 
-			existing, err := getAuthResource(r, store, collection, tenant, name)
-			if err != nil {
-				respondFromError(w, err, r.URL.Path)
-				return
-			}
-			stateValue := "creating"
-			code := http.StatusCreated
-			if existing != nil {
-				stateValue = "updating"
-				code = http.StatusOK
-			}
+func putAuthResourceHandler(store *state.Store, collection, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, name, _, ok := authPath(r, collection)
+		if !ok {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
+			return
+		}
+		var req authResource
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
+			return
+		}
 
-			dbRecord := state.AuthResource{
-				Tenant: tenant,
-				Name:   name,
-				Labels: req.Labels,
-				Spec:   req.Spec,
-				Status: map[string]any{"state": stateValue},
-			}
-			if err := upsertAuthResource(r, store, collection, dbRecord); err != nil {
-				respondFromError(w, err, r.URL.Path)
+		existing, err := getAuthResource(r, store, collection, tenant, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if !precondition.check(w, r, existing != nil, authResourceVersion(existing)) {
+			return
+		}
+		stateValue := "creating"
+		code := http.StatusCreated
+		if existing != nil {
+			stateValue = "updating"
+			code = http.StatusOK
+		}
+
+		dbRecord := state.AuthResource{
+			Tenant: tenant,
+			Name:   name,
+			Labels: req.Labels,
+			Spec:   req.Spec,
+			Status: map[string]any{"state": stateValue},
+		}
+		if err := upsertAuthResource(r, store, collection, dbRecord, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
 				return
 			}
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
 
-			stored, err := getAuthResource(r, store, collection, tenant, name)
+		stored, err := getAuthResource(r, store, collection, tenant, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if stored == nil {
+			respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", "failed to persist auth resource", r.URL.Path)
+			return
+		}
+		out := toAuthResource(collection, kind, upsertVerb(existing == nil), *stored)
+		out.Status.State = stateValue
+		respondJSON(w, code, out)
+	}
+}
+
+func deleteAuthResourceHandler(store *state.Store, collection, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, name, _, ok := authPath(r, collection)
+		if !ok {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
+		if precondition.conditional() {
+			existing, err := getAuthResource(r, store, collection, tenant, name)
 			if err != nil {
 				respondFromError(w, err, r.URL.Path)
 				return
 			}
-			if stored == nil {
-				respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", "failed to persist auth resource", r.URL.Path)
+			if !precondition.check(w, r, existing != nil, authResourceVersion(existing)) {
 				return
 			}
-			out := toAuthResource(collection, kind, http.MethodPut, *stored)
-			out.Status.State = stateValue
-			respondJSON(w, code, out)
-		case http.MethodDelete:
-			tenant, name, _, ok := authPath(r, collection)
-			if !ok {
-				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
-				return
-			}
-			if err := softDeleteAuthResource(r, store, collection, tenant, name); err != nil {
-				respondFromError(w, err, r.URL.Path)
+		}
+		deleted, err := softDeleteAuthResource(r, store, collection, tenant, name, precondition.version)
+		if err != nil {
+			if precondition.respondStoreError(w, r, err) {
 				return
 			}
-			w.WriteHeader(http.StatusAccepted)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if !deleted {
+			respondDeleteNotFound(w, r, "seca.authorization/v1/tenants/"+tenant+"/"+collection+"/"+name, kind+" not found")
+			return
 		}
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -196,32 +234,36 @@ func getAuthResource(r *http.Request, store *state.Store, collection, tenant, na
 	}
 }
 
-func upsertAuthResource(r *http.Request, store *state.Store, collection string, resource state.AuthResource) error {
+func upsertAuthResource(r *http.Request, store *state.Store, collection string, resource state.AuthResource, expectedVersion int64) error {
 	switch collection {
 	case "roles":
-		return store.UpsertRole(r.Context(), resource)
+		return store.UpsertRoleIfVersion(r.Context(), resource, expectedVersion)
 	case "role-assignments":
-		return store.UpsertRoleAssignment(r.Context(), resource)
+		return store.UpsertRoleAssignmentIfVersion(r.Context(), resource, expectedVersion)
 	default:
 		return nil
 	}
 }
 
-func softDeleteAuthResource(r *http.Request, store *state.Store, collection, tenant, name string) error {
+func softDeleteAuthResource(r *http.Request, store *state.Store, collection, tenant, name string, expectedVersion int64) (bool, error) {
 	switch collection {
 	case "roles":
-		_, err := store.SoftDeleteRole(r.Context(), tenant, name)
-		return err
+		return store.SoftDeleteRoleIfVersion(r.Context(), tenant, name, expectedVersion)
 	case "role-assignments":
-		_, err := store.SoftDeleteRoleAssignment(r.Context(), tenant, name)
-		return err
+		return store.SoftDeleteRoleAssignmentIfVersion(r.Context(), tenant, name, expectedVersion)
 	default:
-		return nil
+		return false, nil
+	}
+}
+
+func authResourceVersion(resource *state.AuthResource) int64 {
+	if resource == nil {
+		return 0
 	}
+	return resource.ResourceVersion
 }
 
-func toAuthResource(collection, kind, verb string, resource state.AuthResource) authResource {
-	now := time.Now().UTC().Format(time.RFC3339)
+func toAuthResource(collection, kind string, verb resourceVerb, resource state.AuthResource) authResource {
 	statusState := "active"
 	if rawState, ok := resource.Status["state"].(string); ok && rawState != "" {
 		statusState = strings.ToLower(rawState)
@@ -232,8 +274,8 @@ func toAuthResource(collection, kind, verb string, resource state.AuthResource)
 			Provider:        "seca.authorization/v1",
 			Resource:        "tenants/" + resource.Tenant + "/" + collection + "/" + resource.Name,
 			Verb:            verb,
-			CreatedAt:       now,
-			LastModifiedAt:  now,
+			CreatedAt:       providerTimestamp(resource.CreatedAt),
+			LastModifiedAt:  providerTimestamp(resource.UpdatedAt),
 			ResourceVersion: resource.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            kind,
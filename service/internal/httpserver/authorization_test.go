@@ -0,0 +1,130 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteAnswersNotFoundForUnknownAuthAndWorkspaceResources(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/roles/{name}", putAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("DELETE /v1/tenants/{tenant}/roles/{name}", deleteAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/role-assignments/{name}", putAuthResourceHandler(store, "role-assignments", "role-assignment"))
+	mux.HandleFunc("DELETE /v1/tenants/{tenant}/role-assignments/{name}", deleteAuthResourceHandler(store, "role-assignments", "role-assignment"))
+	mux.HandleFunc("PUT /workspace/v1/tenants/{tenant}/workspaces/{name}", putWorkspace(store, fakeRegionProvider{}))
+	mux.HandleFunc("DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}", deleteWorkspace(store, &fakeComputeProvider{}, nil))
+	serve := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	for _, path := range []string{
+		"/v1/tenants/" + tenant + "/roles/viewer",
+		"/v1/tenants/" + tenant + "/role-assignments/alice-viewer",
+		"/workspace/v1/tenants/" + tenant + "/workspaces/ws",
+	} {
+		if code := serve(http.MethodDelete, path, ""); code != http.StatusNotFound {
+			t.Fatalf("DELETE %s: expected 404 before it exists, got %d", path, code)
+		}
+		if code := serve(http.MethodPut, path, `{"spec":{}}`); code != http.StatusCreated {
+			t.Fatalf("PUT %s: expected 201, got %d", path, code)
+		}
+		if code := serve(http.MethodDelete, path, ""); code != http.StatusAccepted {
+			t.Fatalf("DELETE %s: expected 202, got %d", path, code)
+		}
+		if code := serve(http.MethodDelete, path, ""); code != http.StatusNotFound {
+			t.Fatalf("DELETE %s: expected 404 once deleted, got %d", path, code)
+		}
+	}
+}
+
+func TestListRoleAssignmentsFiltersBySubjectAndRole(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/role-assignments/{name}", putAuthResourceHandler(store, "role-assignments", "role-assignment"))
+	mux.HandleFunc("GET /v1/tenants/{tenant}/role-assignments", listRoleAssignments(store))
+	for name, spec := range map[string]string{
+		"alice-viewer": `{"subs":["alice"],"roles":["viewer"]}`,
+		"alice-admin":  `{"subs":["alice","bob"],"roles":["seca.authorization/v1/tenants/` + tenant + `/roles/admin"]}`,
+		"bob-viewer":   `{"subs":["bob"],"roles":["roles/viewer"]}`,
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/v1/tenants/"+tenant+"/role-assignments/"+name, strings.NewReader(`{"spec":`+spec+`}`)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected 201, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	list := func(query string) []string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tenants/"+tenant+"/role-assignments"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %q: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var out authIterator
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode list: %v", err)
+		}
+		names := []string{}
+		for _, item := range out.Items {
+			names = append(names, item.Metadata.Name)
+		}
+		return names
+	}
+
+	for query, want := range map[string]string{
+		"":                                 "alice-admin,alice-viewer,bob-viewer",
+		"?subject=alice":                   "alice-admin,alice-viewer",
+		"?roleRef=roles/viewer":            "alice-viewer,bob-viewer",
+		"?subject=bob&roleRef=admin":       "alice-admin",
+		"?subject=carol":                   "",
+		"?subject=alice&roleRef=roles/ops": "",
+	} {
+		if got := strings.Join(list(query), ","); got != want {
+			t.Fatalf("list %q: expected [%s], got [%s]", query, want, got)
+		}
+	}
+}
+
+func TestAuthResourceTimestampsComeFromTheStore(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/roles/{name}", putAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("GET /v1/tenants/{tenant}/roles/{name}", getAuthResourceHandler(store, "roles", "role"))
+	path := "/v1/tenants/" + tenant + "/roles/viewer"
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"spec":{}}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT %s: expected 201, got %d: %s", path, rec.Code, rec.Body.String())
+	}
+	get := func() resourceMetadata {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var out authResource
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode role: %v", err)
+		}
+		return out.Metadata
+	}
+
+	first := get()
+	time.Sleep(1100 * time.Millisecond)
+	second := get()
+	if first.CreatedAt != second.CreatedAt || first.LastModifiedAt != second.LastModifiedAt {
+		t.Fatalf("expected reads to agree on the timestamps, got %+v and %+v", first, second)
+	}
+}
@@ -0,0 +1,27 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+// catalogRequestContext honours "Cache-Control: no-cache" on catalog reads by
+// asking the provider for fresh data.
+func catalogRequestContext(r *http.Request) context.Context {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return hetzner.WithoutCatalogCache(r.Context())
+		}
+	}
+	return r.Context()
+}
+
+func adminCatalogCache(invalidator CatalogCacheInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invalidator.InvalidateCatalogCache()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// bulkInstanceActionParallelism bounds how many power actions a bulk request
+// has in flight against Hetzner at once.
+const bulkInstanceActionParallelism = 5
+
+// bulkInstanceActionResponse summarizes a bulk power action. The request
+// answers 202 even when some instances failed; each result carries its own
+// outcome, with the problem a single-instance request would have answered.
+type bulkInstanceActionResponse struct {
+	Status   string                     `json:"status"`
+	Accepted int                        `json:"accepted"`
+	Failed   int                        `json:"failed"`
+	Results  []bulkInstanceActionResult `json:"results"`
+}
+
+type bulkInstanceActionResult struct {
+	Instance  string           `json:"instance"`
+	Status    string           `json:"status"`
+	Operation string           `json:"operation,omitempty"`
+	Problem   *problemResponse `json:"problem,omitempty"`
+}
+
+func startWorkspaceInstances(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return bulkInstanceAction(provider, startLifecycle(provider), store)
+}
+
+func stopWorkspaceInstances(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return bulkInstanceAction(provider, stopLifecycle(provider), store)
+}
+
+func restartWorkspaceInstances(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return bulkInstanceAction(provider, restartLifecycle(provider), store)
+}
+
+// bulkInstanceAction applies lifecycle to every instance of the workspace
+// matching the optional labels selector. A failing instance does not stop the
+// others; its problem names the path of the single-instance action.
+func bulkInstanceAction(provider ComputeStorageProvider, lifecycle instanceLifecycle, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		selector, err := parseLabelSelector(r.URL.Query().Get("labels"))
+		if err != nil {
+			respondValidationProblem(w, r.URL.Path, fieldParameter("labels", "%v", err))
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		instances, err := provider.ListInstancesByLabels(ctx, map[string]string{
+			secaLabelManaged:   "true",
+			secaLabelTenant:    compactLabelValue(tenant),
+			secaLabelWorkspace: compactLabelValue(workspace),
+		})
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		targets := make([]hetzner.Instance, 0, len(instances))
+		for _, instance := range instances {
+			if providerLabelsInScope(instance.Labels, tenant, workspace) && selector.matches(instance.Labels) {
+				targets = append(targets, instance)
+			}
+		}
+
+		results := make([]bulkInstanceActionResult, len(targets))
+		slots := make(chan struct{}, bulkInstanceActionParallelism)
+		var wg sync.WaitGroup
+		for i, instance := range targets {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				result := bulkInstanceActionResult{Instance: "instances/" + instance.Name, Status: "accepted"}
+				operation, err := lifecycle.run(ctx, store, tenant, workspace, instance)
+				if err != nil {
+					result.Status = "failed"
+					result.Problem = problemFromError(w, err, strings.Replace(r.URL.Path, "/instances:", "/instances/"+instance.Name+"/", 1))
+				}
+				result.Operation = operation
+				results[i] = result
+			}()
+		}
+		wg.Wait()
+
+		resp := bulkInstanceActionResponse{Status: "accepted", Results: results}
+		for _, result := range results {
+			if result.Problem != nil {
+				resp.Failed++
+			} else {
+				resp.Accepted++
+			}
+		}
+		if resp.Failed > 0 {
+			resp.Status = "partial"
+		}
+		respondJSON(w, http.StatusAccepted, resp)
+	}
+}
+
+// problemFromError returns the problem respondFromError would answer on w
+// for err, without writing it.
+func problemFromError(w http.ResponseWriter, err error, instance string) *problemResponse {
+	buffered := &bufferedResponse{header: w.Header().Clone()}
+	respondFromError(buffered, err, instance)
+	var problem problemResponse
+	_ = json.Unmarshal(buffered.body.Bytes(), &problem)
+	return &problem
+}
@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const (
+	instanceMetricsDefaultWindow = time.Hour
+	instanceMetricsMaxWindow     = 31 * 24 * time.Hour
+	// instanceMetricsMaxSamples bounds window/step so a request cannot ask
+	// Hetzner for an unbounded number of points.
+	instanceMetricsMaxSamples = 1000
+)
+
+var instanceMetricTypes = []string{"cpu", "disk", "network"}
+
+type instanceMetricsResponse struct {
+	Type        string                 `json:"type"`
+	Start       string                 `json:"start"`
+	End         string                 `json:"end"`
+	StepSeconds int                    `json:"step"`
+	Series      []instanceMetricSeries `json:"series"`
+}
+
+// instanceMetricSeries keeps timestamps (Unix seconds) and values in
+// parallel arrays.
+type instanceMetricSeries struct {
+	Name       string    `json:"name"`
+	Timestamps []int64   `json:"timestamps"`
+	Values     []float64 `json:"values"`
+}
+
+func getInstanceMetrics(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
+		if !ok {
+			return
+		}
+		req, detail := parseInstanceMetricsQuery(r, time.Now().UTC())
+		if detail != "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", detail, r.URL.Path)
+			return
+		}
+		req.Name = name
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if instance == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			return
+		}
+		metrics, err := provider.GetInstanceMetrics(ctx, req)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if metrics == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toInstanceMetricsResponse(req.Type, *metrics))
+	}
+}
+
+// parseInstanceMetricsQuery reads type, start, end (RFC 3339) and step
+// (seconds). It returns a problem detail when the query is invalid; end
+// defaults to now and start to an hour before end.
+func parseInstanceMetricsQuery(r *http.Request, now time.Time) (hetzner.InstanceMetricsRequest, string) {
+	query := r.URL.Query()
+	req := hetzner.InstanceMetricsRequest{Type: strings.ToLower(strings.TrimSpace(query.Get("type")))}
+	if req.Type == "" {
+		req.Type = "cpu"
+	}
+	known := false
+	for _, metricType := range instanceMetricTypes {
+		known = known || req.Type == metricType
+	}
+	if !known {
+		return req, "type must be one of " + strings.Join(instanceMetricTypes, ", ")
+	}
+	req.End = now
+	if raw := query.Get("end"); raw != "" {
+		end, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return req, "end must be an RFC 3339 timestamp"
+		}
+		req.End = end
+	}
+	req.Start = req.End.Add(-instanceMetricsDefaultWindow)
+	if raw := query.Get("start"); raw != "" {
+		start, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return req, "start must be an RFC 3339 timestamp"
+		}
+		req.Start = start
+	}
+	window := req.End.Sub(req.Start)
+	if window <= 0 {
+		return req, "start must be before end"
+	}
+	if window > instanceMetricsMaxWindow {
+		return req, "time range must not exceed " + strconv.Itoa(int(instanceMetricsMaxWindow/(24*time.Hour))) + " days"
+	}
+	if raw := query.Get("step"); raw != "" {
+		step, err := strconv.Atoi(raw)
+		if err != nil || step <= 0 {
+			return req, "step must be a positive number of seconds"
+		}
+		if window/(time.Duration(step)*time.Second) > instanceMetricsMaxSamples {
+			return req, "step is too small for the time range (at most " + strconv.Itoa(instanceMetricsMaxSamples) + " samples)"
+		}
+		req.StepSeconds = step
+	}
+	return req, ""
+}
+
+func toInstanceMetricsResponse(metricType string, metrics hetzner.InstanceMetrics) instanceMetricsResponse {
+	names := make([]string, 0, len(metrics.Series))
+	for name := range metrics.Series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	series := make([]instanceMetricSeries, 0, len(names))
+	for _, name := range names {
+		samples := metrics.Series[name]
+		item := instanceMetricSeries{
+			Name:       name,
+			Timestamps: make([]int64, 0, len(samples)),
+			Values:     make([]float64, 0, len(samples)),
+		}
+		for _, sample := range samples {
+			item.Timestamps = append(item.Timestamps, sample.Timestamp.Unix())
+			item.Values = append(item.Values, sample.Value)
+		}
+		series = append(series, item)
+	}
+	return instanceMetricsResponse{
+		Type:        metricType,
+		Start:       metrics.Start.UTC().Format(time.RFC3339),
+		End:         metrics.End.UTC().Format(time.RFC3339),
+		StepSeconds: metrics.StepSeconds,
+		Series:      series,
+	}
+}
@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestParseInstanceMetricsQuery(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	parse := func(query string) (hetzner.InstanceMetricsRequest, string) {
+		return parseInstanceMetricsQuery(httptest.NewRequest(http.MethodGet, "/metrics"+query, nil), now)
+	}
+
+	req, detail := parse("")
+	if detail != "" || req.Type != "cpu" || !req.End.Equal(now) || !req.Start.Equal(now.Add(-time.Hour)) || req.StepSeconds != 0 {
+		t.Fatalf("unexpected defaults %+v (%q)", req, detail)
+	}
+	req, detail = parse("?type=network&start=2026-05-01T00:00:00Z&end=2026-05-01T06:00:00Z&step=60")
+	if detail != "" || req.Type != "network" || req.End.Sub(req.Start) != 6*time.Hour || req.StepSeconds != 60 {
+		t.Fatalf("unexpected request %+v (%q)", req, detail)
+	}
+
+	for query, want := range map[string]string{
+		"?type=memory":                       "type must be one of",
+		"?start=2026-05-01T13:00:00Z":        "start must be before end",
+		"?start=2026-03-01T00:00:00Z":        "must not exceed",
+		"?end=yesterday":                     "end must be an RFC 3339 timestamp",
+		"?step=0":                            "step must be a positive",
+		"?start=2026-04-30T12:00:00Z&step=1": "step is too small",
+		"?type=disk&start=2026-05-01T11:00:00Z&end=2026-05-01T11:00:00Z": "start must be before end",
+	} {
+		if _, detail := parse(query); !strings.Contains(detail, want) {
+			t.Fatalf("%s: expected %q, got %q", query, want, detail)
+		}
+	}
+}
+
+func TestToInstanceMetricsResponseSortsSeries(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	resp := toInstanceMetricsResponse("network", hetzner.InstanceMetrics{
+		Start:       start,
+		End:         start.Add(time.Minute),
+		StepSeconds: 60,
+		Series: map[string][]hetzner.MetricSample{
+			"network.0.bandwidth.out": {{Timestamp: start, Value: 2}},
+			"network.0.bandwidth.in":  {{Timestamp: start, Value: 1}, {Timestamp: start.Add(time.Minute), Value: 3}},
+		},
+	})
+	if len(resp.Series) != 2 || resp.Series[0].Name != "network.0.bandwidth.in" {
+		t.Fatalf("expected series sorted by name, got %+v", resp.Series)
+	}
+	in := resp.Series[0]
+	if len(in.Timestamps) != 2 || in.Timestamps[1] != start.Add(time.Minute).Unix() || in.Values[1] != 3 {
+		t.Fatalf("unexpected series %+v", in)
+	}
+	if resp.Start != "2026-05-01T12:00:00Z" || resp.StepSeconds != 60 {
+		t.Fatalf("unexpected range %+v", resp)
+	}
+
+	empty := toInstanceMetricsResponse("cpu", hetzner.InstanceMetrics{Series: map[string][]hetzner.MetricSample{}})
+	if empty.Series == nil || len(empty.Series) != 0 {
+		t.Fatalf("expected an empty series list for a stopped instance, got %+v", empty.Series)
+	}
+}
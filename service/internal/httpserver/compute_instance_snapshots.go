@@ -0,0 +1,207 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+)
+
+// instanceSnapshotRestartTimeout bounds the background wait for a frozen
+// snapshot before the server is powered on again.
+const instanceSnapshotRestartTimeout = 30 * time.Minute
+
+// instanceSnapshotRestartRetry is how long the restart waits before trying a
+// power-on again while the server is still locked.
+const instanceSnapshotRestartRetry = 2 * time.Second
+
+// instanceSnapshotRunningWarning is returned when a running server is
+// snapshotted without freeze.
+const instanceSnapshotRunningWarning = "instance was running during the snapshot; the image is crash-consistent only, use ?freeze=true to power off for the snapshot"
+
+type instanceSnapshotResponse struct {
+	Operation string        `json:"operation,omitempty"`
+	ImageRef  refObject     `json:"imageRef"`
+	Image     imageResource `json:"image"`
+	Warning   string        `json:"warning,omitempty"`
+}
+
+// snapshotInstance creates a tenant image from the instance's disk. The
+// image name comes from ?image= and defaults to the instance name with a
+// timestamp. With ?freeze=true a running server is powered off for the
+// snapshot and powered on again once the snapshot action finishes.
+func snapshotInstance(catalogProvider CatalogProvider, provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
+		if !ok {
+			return
+		}
+		freeze := false
+		if raw := r.URL.Query().Get("freeze"); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondValidationProblem(w, r.URL.Path, fieldParameter("freeze", "freeze must be a boolean"))
+				return
+			}
+			freeze = parsed
+		}
+		imageName := instanceSnapshotImageName(r.URL.Query().Get("image"), name, time.Now())
+
+		catalogImage, err := catalogProvider.GetCatalogImage(catalogRequestContext(r), imageName, "")
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if catalogImage != nil {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is a read-only catalog image", imageName), r.URL.Path)
+			return
+		}
+		ref := tenantImageRef(tenant, imageName)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+			return
+		}
+		if binding != nil {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q already exists", imageName), r.URL.Path)
+			return
+		}
+
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if instance == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			return
+		}
+		running := instance.PowerState == "on"
+		frozen := false
+		if freeze && running {
+			if !stopInstanceForSnapshot(ctx, w, r, provider, name) {
+				return
+			}
+			frozen = true
+		}
+
+		req := imageResource{
+			Metadata: resourceMetadata{Region: instance.Region},
+			Spec:     imageSpec{BlockStorageRef: refObject{Resource: computeInstanceRef(tenant, workspace, name)}},
+		}
+		snapshotReq := hetzner.ImageSnapshotCreateRequest{
+			Description:  imageName,
+			Labels:       withSecaProviderLabels(nil, tenant, workspace, resourceBindingKindImage, imageName, ref),
+			InstanceName: instance.Name,
+		}
+		image, operation, actionID, ok := createTenantImage(ctx, w, r, provider, store, tenant, workspace, imageName, req, snapshotReq)
+		if !ok {
+			if frozen {
+				restartInstanceAfterSnapshot(ctx, provider, store, computeInstanceRef(tenant, workspace, name), name, "")
+			}
+			return
+		}
+		if frozen {
+			restartInstanceAfterSnapshot(ctx, provider, store, computeInstanceRef(tenant, workspace, name), name, actionID)
+		}
+		resp := instanceSnapshotResponse{
+			Operation: operation,
+			ImageRef:  refObject{Resource: ref},
+			Image:     image,
+		}
+		if running && !freeze {
+			resp.Warning = instanceSnapshotRunningWarning
+		}
+		respondJSON(w, http.StatusAccepted, resp)
+	}
+}
+
+// instanceSnapshotImageName returns the requested image name, or the
+// instance name suffixed with a UTC timestamp when none was given.
+func instanceSnapshotImageName(requested, instance string, now time.Time) string {
+	if name := strings.ToLower(strings.TrimSpace(requested)); name != "" {
+		return name
+	}
+	return instance + "-" + now.UTC().Format("20060102150405")
+}
+
+// stopInstanceForSnapshot powers the server off and waits until it is off.
+func stopInstanceForSnapshot(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, name string) bool {
+	found, actionID, err := provider.StopInstance(ctx, name)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return false
+	}
+	if !found {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+		return false
+	}
+	id, err := strconv.ParseInt(actionID, 10, 64)
+	if err != nil {
+		return true
+	}
+	if err := provider.WaitForAction(ctx, id); err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// restartInstanceAfterSnapshot powers the server on in the background once
+// the snapshot action, if any, has finished. Failures are logged. A shutdown
+// that cannot wait for it leaves an interrupted deferred power-on for the
+// operation reconciler.
+func restartInstanceAfterSnapshot(ctx context.Context, provider ComputeStorageProvider, store *state.Store, ref, name, snapshotActionID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), instanceSnapshotRestartTimeout)
+	ctx, done := hetzner.TrackInFlight(ctx)
+	go func() {
+		defer done()
+		defer cancel()
+		defer func() {
+			if !errors.Is(context.Cause(ctx), hetzner.ErrShuttingDown) {
+				return
+			}
+			if err := store.CreateOperation(context.WithoutCancel(ctx), state.OperationRecord{
+				OperationID:      operationID("instance-start", name),
+				SecaRef:          ref,
+				ProviderActionID: hetzner.DeferredPowerOn,
+				Phase:            "interrupted",
+			}); err != nil {
+				tracing.Logf(ctx, "power on instance %s after snapshot: record interrupted power-on: %v", name, err)
+			}
+		}()
+		if id, err := strconv.ParseInt(snapshotActionID, 10, 64); err == nil {
+			if err := provider.WaitForAction(ctx, id); err != nil {
+				tracing.Logf(ctx, "snapshot of instance %s: %v", name, err)
+			}
+		}
+		for {
+			_, actionID, err := provider.StartInstance(ctx, name)
+			if err != nil {
+				tracing.Logf(ctx, "power on instance %s after snapshot: %v", name, err)
+				return
+			}
+			if actionID != hetzner.DeferredPowerOn {
+				return
+			}
+			// The server is still locked by the snapshot; try again shortly.
+			select {
+			case <-ctx.Done():
+				tracing.Logf(ctx, "power on instance %s after snapshot: server still locked", name)
+				return
+			case <-time.After(instanceSnapshotRestartRetry):
+			}
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInstanceSnapshotImageName(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 1, 12, 30, 5, 0, time.UTC)
+	if got := instanceSnapshotImageName("", "vm1", now); got != "vm1-20260501123005" {
+		t.Fatalf("expected a timestamped default name, got %q", got)
+	}
+	if got := instanceSnapshotImageName(" Golden ", "vm1", now); got != "golden" {
+		t.Fatalf("expected the requested name lowercased, got %q", got)
+	}
+}
+
+func TestSnapshotInstanceRejectsBadFreezeAndCatalogNames(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/snapshot", snapshotInstance(fakeCatalogProvider{}, nil, nil))
+	path := "/compute/v1/tenants/t/workspaces/ws1/instances/vm1/snapshot"
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path+"?freeze=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad freeze flag, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path+"?image=ubuntu-24.04", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a catalog image name, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -2,12 +2,18 @@ package httpserver
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 type instanceIterator struct {
@@ -16,16 +22,34 @@ type instanceIterator struct {
 }
 
 type instanceResource struct {
-	Metadata resourceMetadata `json:"metadata"`
-	Spec     instanceSpec     `json:"spec"`
-	Status   instanceStatus   `json:"status"`
+	Metadata resourceMetadata  `json:"metadata"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Spec     instanceSpec      `json:"spec"`
+	Status   instanceStatus    `json:"status"`
 }
 
 type instanceSpec struct {
-	SkuRef     refObject       `json:"skuRef"`
-	ImageRef   refObject       `json:"imageRef"`
-	BootVolume volumeReference `json:"bootVolume,omitempty"`
-	Zone       string          `json:"zone,omitempty"`
+	SkuRef            refObject       `json:"skuRef"`
+	ImageRef          refObject       `json:"imageRef"`
+	BootVolume        volumeReference `json:"bootVolume,omitempty"`
+	Zone              string          `json:"zone,omitempty"`
+	SecurityGroupRefs []refObject     `json:"securityGroupRefs,omitempty"`
+	PublicNetwork     *instancePublicNetwork `json:"publicNetwork,omitempty"`
+	BackupsEnabled    bool            `json:"backupsEnabled,omitempty"`
+	// PlacementGroupRef places the instance on its own physical host among
+	// the instances of a spread placement group.
+	PlacementGroupRef *refObject `json:"placementGroupRef,omitempty"`
+	// UserDataHash fingerprints the user data the instance was created with.
+	UserDataHash      string          `json:"userDataHash,omitempty"`
+	// UserData is only returned by GET with ?includeUserData=true.
+	UserData          string          `json:"userData,omitempty"`
+}
+
+// instancePublicNetwork selects the public interfaces of a new instance; an
+// omitted family stays enabled.
+type instancePublicNetwork struct {
+	IPv4 *bool `json:"ipv4,omitempty"`
+	IPv6 *bool `json:"ipv6,omitempty"`
 }
 
 type volumeReference struct {
@@ -33,10 +57,23 @@ type volumeReference struct {
 }
 
 type instanceStatus struct {
-	State      string `json:"state"`
-	PowerState string `json:"powerState"`
+	State      string           `json:"state"`
+	PowerState string           `json:"powerState"`
+	// Zone is the datacenter the instance runs in.
+	Zone       string           `json:"zone,omitempty"`
+	BootVolume *volumeReference `json:"bootVolume,omitempty"`
+	PublicIPv4 string           `json:"publicIPv4,omitempty"`
+	PublicIPv6 string           `json:"publicIPv6,omitempty"`
+	BackupWindow string         `json:"backupWindow,omitempty"`
+	// Note carries advisories about the last PUT, such as backup billing.
+	Note string `json:"note,omitempty"`
+	// Conditions carry the error of the last Hetzner action when it failed.
+	Conditions []statusCondition `json:"conditions,omitempty"`
 }
 
+// instanceBackupsBillingNote is reported when a PUT enables backups.
+const instanceBackupsBillingNote = "automated backups enabled; Hetzner bills them at 20% of the server price"
+
 type instanceUpsertRequest struct {
 	Labels map[string]string `json:"labels,omitempty"`
 	Spec struct {
@@ -46,18 +83,22 @@ type instanceUpsertRequest struct {
 		BootVolume     *struct {
 			DeviceRef refObject `json:"deviceRef"`
 		} `json:"bootVolume,omitempty"`
-		Zone     string `json:"zone,omitempty"`
-		UserData string `json:"userData,omitempty"`
+		Zone              string      `json:"zone,omitempty"`
+		UserData          string      `json:"userData,omitempty"`
+		SecurityGroupRefs []refObject `json:"securityGroupRefs,omitempty"`
+		PublicNetwork     *instancePublicNetwork `json:"publicNetwork,omitempty"`
+		BackupsEnabled    *bool       `json:"backupsEnabled,omitempty"`
+		PlacementGroupRef *refObject  `json:"placementGroupRef,omitempty"`
 	} `json:"spec"`
 }
 
 func listInstances(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
 			return
 		}
-		tenant, workspace, ok := scopeFromPath(w, r)
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
 		if !ok {
 			return
 		}
@@ -72,57 +113,69 @@ func listInstances(provider ComputeStorageProvider, store *state.Store) http.Han
 			return
 		}
 
-		items := make([]instanceResource, 0, len(instances))
+		boundNames := boundNamesByID(ctx, store, tenant, workspace, "instance", hetzner.ResourceServer)
+		scoped := make([]hetzner.Instance, 0, len(instances))
+		bindings := make([]state.ResourceBinding, 0, len(instances))
 		for _, instance := range instances {
-			spec, ok := runtimeResourceState.getInstanceSpec(computeInstanceRef(tenant, workspace, instance.Name))
-			if ok {
-				items = append(items, toInstanceResource(tenant, workspace, instance, http.MethodGet, "active", &spec))
-			} else {
-				items = append(items, toInstanceResource(tenant, workspace, instance, http.MethodGet, "active", nil))
+			if !providerLabelsInScope(instance.Labels, tenant, workspace) {
+				continue
+			}
+			name, ok := boundNames.listed(instance.ID, instance.Name)
+			if !ok {
+				continue
 			}
-			_ = store.UpsertResourceBinding(ctx, state.ResourceBinding{
-				Tenant:      tenant,
-				Workspace:   workspace,
-				Kind:        "instance",
+			instance.Name = name
+			scoped = append(scoped, instance)
+			bindings = append(bindings, state.ResourceBinding{
 				SecaRef:     computeInstanceRef(tenant, workspace, instance.Name),
 				ProviderRef: serverProviderRef(instance.ID, instance.Name),
 				Status:      "active",
 			})
 		}
+		stored, err := store.SyncResourceBindings(ctx, tenant, workspace, "instance", bindings)
+		if err != nil {
+			tracing.Logf(ctx, "list instances of %s/%s: %v", tenant, workspace, err)
+		}
+		items := make([]instanceResource, 0, len(scoped))
+		for _, instance := range scoped {
+			binding := storedBinding(stored, computeInstanceRef(tenant, workspace, instance.Name))
+			var specOverride *instanceSpec
+			if spec, ok := runtimeResourceState.getInstanceSpec(computeInstanceRef(tenant, workspace, instance.Name)); ok {
+				specOverride = &spec
+			}
+			stateValue, conditions := failedActionState(ctx, store, binding, instanceStateValue(tenant, workspace, instance))
+			resource := toInstanceResource(tenant, workspace, instance, verbList, stateValue, specOverride, systemLabels)
+			resource.Status.Conditions = conditions
+			stampLastModified(&resource.Metadata, binding)
+			items = append(items, resource)
+		}
 
 		respondJSON(w, http.StatusOK, instanceIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/instances", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/instances", Verb: verbList},
 		})
 	}
 }
 
-func instanceCRUD(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getInstance(provider, store)(w, r)
-		case http.MethodPut:
-			putInstance(provider, store)(w, r)
-		case http.MethodDelete:
-			deleteInstance(provider, store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
-func getInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+func getInstance(provider ComputeStorageProvider, store *state.Store, userDataReadable bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
 		if !ok {
 			return
 		}
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
+		if !ok {
+			return
+		}
+		includeUserData, ok := includeUserDataFromQuery(w, r, userDataReadable)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
-		instance, err := provider.GetInstance(ctx, name)
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -131,44 +184,76 @@ func getInstance(provider ComputeStorageProvider, store *state.Store) http.Handl
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		binding, err := store.SyncResourceBinding(ctx, state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        "instance",
 			SecaRef:     computeInstanceRef(tenant, workspace, name),
 			ProviderRef: serverProviderRef(instance.ID, instance.Name),
 			Status:      "active",
-		}); err != nil {
+		})
+		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
+		stateValue, conditions := failedActionState(ctx, store, binding, instanceStateValue(tenant, workspace, *instance))
 		spec, ok := runtimeResourceState.getInstanceSpec(computeInstanceRef(tenant, workspace, name))
 		if ok {
-			respondJSON(w, http.StatusOK, toInstanceResource(tenant, workspace, *instance, http.MethodGet, "active", &spec))
+			resource := toInstanceResource(tenant, workspace, *instance, verbGet, stateValue, &spec, systemLabels)
+			resource.Status.Conditions = conditions
+			resource.Status.BootVolume = bootVolumeStatus(ctx, provider, tenant, workspace, *instance, spec)
+			if includeUserData {
+				resource.Spec.UserData = spec.UserData
+			}
+			stampLastModified(&resource.Metadata, binding)
+			respondJSON(w, http.StatusOK, resource)
 			return
 		}
-		respondJSON(w, http.StatusOK, toInstanceResource(tenant, workspace, *instance, http.MethodGet, "active", nil))
+		resource := toInstanceResource(tenant, workspace, *instance, verbGet, stateValue, nil, systemLabels)
+		resource.Status.Conditions = conditions
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, http.StatusOK, resource)
 	}
 }
 
-func putInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+// putInstance creates the instance or converges an existing one on the
+// requested spec. A SKU change resizes the server; an image change rebuilds it
+// when imageRebuild is set and is rejected as an immutable field otherwise.
+// User data is kept for GET only when userDataReadable is set. A new instance
+// without spec.imageRef runs defaultImage; with none configured the imageRef
+// is required.
+func putInstance(provider ComputeStorageProvider, networkProvider NetworkProvider, store *state.Store, imageRebuild, userDataReadable bool, defaultImage string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
 		if !ok {
 			return
 		}
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
+		if !ok {
+			return
+		}
+		dryRun, ok := dryRunFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
 		var reqBody instanceUpsertRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &reqBody) {
 			return
 		}
 		skuName := resourceNameFromRef(reqBody.Spec.SkuRef.Resource)
+		var problems []fieldProblem
 		if skuName == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.skuRef.resource is required", r.URL.Path)
+			problems = append(problems, fieldPointer("/spec/skuRef/resource", "spec.skuRef.resource is required"))
+		}
+		problems = append(problems, reservedLabelProblems(reqBody.Labels)...)
+		userData := normalizeUserData(reqBody.Spec.UserData)
+		problems = append(problems, userDataSizeProblem(userData)...)
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
 			return
 		}
 		imageName := ""
@@ -178,16 +263,90 @@ func putInstance(provider ComputeStorageProvider, store *state.Store) http.Handl
 		if imageName == "" && reqBody.Spec.SourceImageRef != nil {
 			imageName = resourceNameFromRef(reqBody.Spec.SourceImageRef.Resource)
 		}
+		desiredSpec := instanceSpec{SkuRef: reqBody.Spec.SkuRef, Zone: reqBody.Spec.Zone}
+		if imageName != "" {
+			desiredSpec.ImageRef = refObject{Resource: "images/" + imageName}
+		}
+		securityGroupRefs, securityGroupNames, ok := resolveInstanceSecurityGroups(ctx, w, r, store, networkProvider, tenant, workspace, reqBody.Spec.SecurityGroupRefs)
+		if !ok {
+			return
+		}
+		existing, err := provider.GetInstance(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if existing != nil && !providerLabelsInScope(existing.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "instance name is already in use outside this workspace", r.URL.Path)
+			return
+		}
+		if existing != nil && desiredSpec.ImageRef.Resource == "" && existing.ImageName != "" {
+			imageName = existing.ImageName
+		}
 		if imageName == "" {
-			imageName = "ubuntu-24.04"
+			if existing == nil && defaultImage == "" {
+				respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/imageRef", "spec.imageRef is required: no default image is configured"))
+				return
+			}
+			imageName = defaultImage
 		}
+		if existing != nil && !imageRebuild {
+			currentSpec := instanceSpec{}
+			if existing.ImageName != "" {
+				currentSpec.ImageRef = refObject{Resource: "images/" + existing.ImageName}
+			}
+			if spec, ok := runtimeResourceState.getInstanceSpec(computeInstanceRef(tenant, workspace, name)); ok {
+				currentSpec = spec
+			}
+			violation, err := findImmutableFieldViolation("instance", currentSpec, desiredSpec)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if violation != nil {
+				respondImmutableFieldViolation(w, *violation, r.URL.Path)
+				return
+			}
+		}
+
+		var imageID int64
+		if desiredSpec.ImageRef.Resource != "" {
+			if imageID, ok = resolveTenantImageID(ctx, w, r, provider, store, tenant, imageName); !ok {
+				return
+			}
+		}
+		bootVolume := ""
+		if existing == nil && reqBody.Spec.BootVolume != nil {
+			bootVolume = bootVolumeName(reqBody.Spec.BootVolume.DeviceRef)
+		}
+		if bootVolume != "" {
+			volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, bootVolume)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if volume == nil {
+				respondProblemWithSources(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", fmt.Sprintf("block storage %q not found", bootVolume), r.URL.Path, []problemSource{{Pointer: "/spec/bootVolume/deviceRef"}})
+				return
+			}
+		}
+		placementGroup, ok := resolveInstancePlacementGroup(ctx, w, r, provider, store, tenant, workspace, reqBody.Spec.PlacementGroupRef, dryRun)
+		if !ok {
+			return
+		}
+		placementGroupRef := placementGroupSpecRef(reqBody.Spec.PlacementGroupRef)
 
-		instance, created, actionID, err := provider.CreateOrUpdateInstance(ctx, hetzner.InstanceCreateRequest{
-			Name:      name,
-			SKUName:   skuName,
-			ImageName: imageName,
-			Region:    regionFromZone(reqBody.Spec.Zone),
-			UserData:  reqBody.Spec.UserData,
+		createReq := hetzner.InstanceCreateRequest{
+			Name:       name,
+			SKUName:    skuName,
+			ImageName:  imageName,
+			ImageID:    imageID,
+			BootVolume: bootVolume,
+			PublicNetwork: reqBody.Spec.PublicNetwork.provider(),
+			Region:     regionFromZone(reqBody.Spec.Zone),
+			Zone:       reqBody.Spec.Zone,
+			UserData:   userData,
+			PlacementGroup: placementGroup,
 			Labels: withSecaProviderLabels(
 				reqBody.Labels,
 				tenant,
@@ -196,25 +355,78 @@ func putInstance(provider ComputeStorageProvider, store *state.Store) http.Handl
 				name,
 				computeInstanceRef(tenant, workspace, name),
 			),
-		})
+		}
+		if dryRun {
+			instance, created, err := provider.ValidateInstanceCreate(ctx, createReq)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			spec := instanceSpec{
+				SkuRef:            appliedSKURef(reqBody.Spec.SkuRef, skuName, instance.SKUName),
+				ImageRef:          refObject{Resource: "images/" + imageName},
+				Zone:              reqBody.Spec.Zone,
+				SecurityGroupRefs: securityGroupRefs,
+				PublicNetwork:     reqBody.Spec.PublicNetwork,
+				BackupsEnabled:    instance.BackupWindow != "",
+				PlacementGroupRef: placementGroupRef,
+			}
+			spec.UserDataHash, _ = instanceUserData(tenant, workspace, name, userData, created, false)
+			if reqBody.Spec.BootVolume != nil {
+				spec.BootVolume.DeviceRef = reqBody.Spec.BootVolume.DeviceRef
+			}
+			if reqBody.Spec.BackupsEnabled != nil {
+				spec.BackupsEnabled = *reqBody.Spec.BackupsEnabled
+			}
+			respondJSON(w, http.StatusOK, toInstanceResource(tenant, workspace, *instance, upsertVerb(created), dryRunState, &spec, systemLabels))
+			return
+		}
+		instance, created, actionID, err := provider.CreateOrUpdateInstance(ctx, createReq)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		ownsSecurityGroup, err := workspaceSecurityGroupOwner(r.Context(), store, tenant, workspace)
 		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security groups", r.URL.Path)
+			return
+		}
+		if err := provider.SyncInstanceSecurityGroups(ctx, name, securityGroupNames, ownsSecurityGroup); err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		backupsEnabled := instance.BackupWindow != ""
+		backupsNote := ""
+		if want := reqBody.Spec.BackupsEnabled; want != nil && *want != backupsEnabled {
+			// No action means the provider left backups alone, as it does in
+			// conformance mode.
+			_, backupsAction, err := provider.SetInstanceBackups(ctx, name, *want)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if backupsAction != "" {
+				backupsEnabled = *want
+				if *want {
+					backupsNote = instanceBackupsBillingNote
+				}
+			}
+		}
+		binding, err := store.UpsertResourceBindingIfVersion(ctx, state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        "instance",
 			SecaRef:     computeInstanceRef(tenant, workspace, name),
 			ProviderRef: serverProviderRef(instance.ID, instance.Name),
 			Status:      "active",
-		}); err != nil {
+		}, 0)
+		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if actionID != "" {
 			if err := store.CreateOperation(ctx, state.OperationRecord{
-				OperationID:      operationID("instance-upsert", name),
+				OperationID:      operationID(instanceUpsertOperation(existing, skuName, imageName, placementGroup), name),
 				SecaRef:          computeInstanceRef(tenant, workspace, name),
 				ProviderActionID: actionID,
 				Phase:            "accepted",
@@ -230,70 +442,228 @@ func putInstance(provider ComputeStorageProvider, store *state.Store) http.Handl
 			stateValue = "creating"
 		}
 		storedSpec := instanceSpec{
-			SkuRef:     reqBody.Spec.SkuRef,
-			ImageRef:   refObject{Resource: "images/" + imageName},
-			BootVolume: volumeReference{},
-			Zone:       reqBody.Spec.Zone,
-		}
+			SkuRef:            appliedSKURef(reqBody.Spec.SkuRef, skuName, instance.SKUName),
+			ImageRef:          refObject{Resource: "images/" + imageName},
+			BootVolume:        volumeReference{},
+			Zone:              reqBody.Spec.Zone,
+			SecurityGroupRefs: securityGroupRefs,
+			PublicNetwork:     reqBody.Spec.PublicNetwork,
+			BackupsEnabled:    backupsEnabled,
+			PlacementGroupRef: placementGroupRef,
+		}
+		storedSpec.UserDataHash, storedSpec.UserData = instanceUserData(tenant, workspace, name, userData, created, userDataReadable)
 		if reqBody.Spec.BootVolume != nil {
 			storedSpec.BootVolume.DeviceRef = reqBody.Spec.BootVolume.DeviceRef
 		}
 		runtimeResourceState.setInstanceSpec(computeInstanceRef(tenant, workspace, name), storedSpec)
-		respondJSON(w, code, toInstanceResource(tenant, workspace, *instance, http.MethodPut, stateValue, &storedSpec))
+		resource := toInstanceResource(tenant, workspace, *instance, upsertVerb(created), stateValue, &storedSpec, systemLabels)
+		resource.Status.BootVolume = bootVolumeStatus(ctx, provider, tenant, workspace, *instance, storedSpec)
+		resource.Spec.BackupsEnabled = backupsEnabled
+		resource.Status.Note = backupsNote
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, code, resource)
 	}
 }
 
-func deleteInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+// instanceDeleteWaitTimeout caps how long a waiting DELETE holds the request
+// open for the Hetzner delete action.
+const instanceDeleteWaitTimeout = 2 * time.Minute
+
+// deleteInstance accepts the delete and, with ?wait=true or waitByDefault,
+// only answers once Hetzner has finished deleting the server.
+func deleteInstance(provider ComputeStorageProvider, store *state.Store, waitByDefault bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
 		if !ok {
 			return
 		}
+		wait := waitByDefault
+		if raw := r.URL.Query().Get("wait"); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondValidationProblem(w, r.URL.Path, fieldParameter("wait", "wait must be true or false"))
+				return
+			}
+			wait = parsed
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		ref := computeInstanceRef(tenant, workspace, name)
+		if instance == nil {
+			respondDeleteNotFound(w, r, ref, "instance not found")
+			return
+		}
+		providerRef := serverProviderRef(instance.ID, instance.Name)
+		if respondDeleteInProgress(w, ref, providerRef) {
+			return
+		}
 		deleted, actionID, err := provider.DeleteInstance(ctx, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if !deleted {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "instance not found")
 			return
 		}
-		_ = store.DeleteResourceBinding(ctx, computeInstanceRef(tenant, workspace, name))
-		runtimeResourceState.deleteInstanceSpec(computeInstanceRef(tenant, workspace, name))
+		_ = store.DeleteResourceBinding(ctx, ref)
+		runtimeResourceState.deleteInstanceSpec(ref)
+		operation := ""
 		if actionID != "" {
+			operation = operationID("instance-delete", name)
 			_ = store.CreateOperation(ctx, state.OperationRecord{
-				OperationID:      operationID("instance-delete", name),
-				SecaRef:          computeInstanceRef(tenant, workspace, name),
+				OperationID:      operation,
+				SecaRef:          ref,
 				ProviderActionID: actionID,
 				Phase:            "accepted",
 			})
+			pendingDeletes.accept(ref, providerRef, operation)
+			if wait && !waitForInstanceDelete(ctx, w, r, provider, store, ref, operation, actionID) {
+				return
+			}
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, operation)
+	}
+}
+
+// waitForInstanceDelete waits for the delete action and records its outcome
+// on the operation. It answers 504 when the wait times out, leaving the
+// delete in progress so a repeated DELETE returns the same operation.
+func waitForInstanceDelete(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, store *state.Store, ref, operation, actionID string) bool {
+	id, err := strconv.ParseInt(actionID, 10, 64)
+	if err != nil {
+		return true
+	}
+	// Shutdown waits for the outcome to be recorded, not just for the action.
+	ctx, done := hetzner.TrackInFlight(ctx)
+	defer done()
+	waitCtx, cancel := context.WithTimeout(ctx, instanceDeleteWaitTimeout)
+	defer cancel()
+	err = provider.WaitForAction(waitCtx, id)
+	switch {
+	case err == nil:
+		_ = store.UpdateOperationPhase(ctx, operation, "succeeded", "")
+		pendingDeletes.forget(ref)
+		return true
+	case errors.Is(err, hetzner.ErrShuttingDown):
+		// The action carries on at Hetzner; the operation reconciler picks
+		// the operation up again after the restart.
+		_ = store.UpdateOperationPhase(context.WithoutCancel(ctx), operation, "interrupted", err.Error())
+		respondFromError(w, err, r.URL.Path)
+		return false
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, hetzner.ErrTimeout):
+		respondProblem(w, http.StatusGatewayTimeout, "http://secapi.cloud/errors/provider-unavailable", "Gateway Timeout", "instance delete is still running at hetzner (operation "+operation+")", r.URL.Path)
+		return false
+	default:
+		_ = store.UpdateOperationPhase(ctx, operation, "failed", err.Error())
+		pendingDeletes.forget(ref)
+		respondFromError(w, err, r.URL.Path)
+		return false
 	}
 }
 
 func startInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
-	return instanceAction(provider.StartInstance, "instance-start", store)
+	return instanceAction(provider, startLifecycle(provider), store)
 }
 
 func stopInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
-	return instanceAction(provider.StopInstance, "instance-stop", store)
+	return instanceAction(provider, stopLifecycle(provider), store)
 }
 
 func restartInstance(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
-	return instanceAction(provider.RestartInstance, "instance-restart", store)
+	return instanceAction(provider, restartLifecycle(provider), store)
 }
 
-func instanceAction(action func(ctx context.Context, name string) (bool, string, error), phase string, store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only POST is supported", r.URL.Path)
-			return
+// instanceActionCheck looks at the server status before a lifecycle action is
+// sent. done means the server is already where the action would take it;
+// a non-empty conflict rejects the action with that detail.
+type instanceActionCheck func(status string) (done bool, conflict string)
+
+func startInstanceCheck(status string) (bool, string) {
+	return status == string(hcloud.ServerStatusRunning) || status == string(hcloud.ServerStatusStarting), ""
+}
+
+func stopInstanceCheck(status string) (bool, string) {
+	return status == string(hcloud.ServerStatusOff) || status == string(hcloud.ServerStatusStopping), ""
+}
+
+// restartInstanceCheck rejects restarting a stopped server rather than
+// powering it on, so a restart never boots a server that was meant to be off.
+func restartInstanceCheck(status string) (bool, string) {
+	if status == string(hcloud.ServerStatusOff) || status == string(hcloud.ServerStatusStopping) {
+		return false, "instance is stopped; restart only reboots a running instance, use start to power it on"
+	}
+	return false, ""
+}
+
+// instanceLifecycle is a power action together with its precondition and
+// the operation phase it is recorded under.
+type instanceLifecycle struct {
+	action func(ctx context.Context, name string) (bool, string, error)
+	check  instanceActionCheck
+	phase  string
+}
+
+func startLifecycle(provider ComputeStorageProvider) instanceLifecycle {
+	return instanceLifecycle{action: provider.StartInstance, check: startInstanceCheck, phase: "instance-start"}
+}
+
+func stopLifecycle(provider ComputeStorageProvider) instanceLifecycle {
+	return instanceLifecycle{action: provider.StopInstance, check: stopInstanceCheck, phase: "instance-stop"}
+}
+
+func restartLifecycle(provider ComputeStorageProvider) instanceLifecycle {
+	return instanceLifecycle{action: provider.RestartInstance, check: restartInstanceCheck, phase: "instance-restart"}
+}
+
+// run applies the action to instance and records its operation, returning
+// the operation ID. A rejected precondition is a conflict and a server gone
+// in the meantime is not found, both as provider errors.
+func (l instanceLifecycle) run(ctx context.Context, store *state.Store, tenant, workspace string, instance hetzner.Instance) (string, error) {
+	done, conflict := l.check(instance.Status)
+	if conflict != "" {
+		return "", hetzner.ProviderError{Code: "conflict", Message: conflict}
+	}
+	operation := operationID(l.phase, instance.Name)
+	if done {
+		// Already in the requested power state: record the operation as
+		// finished without asking Hetzner, which would reject it.
+		if err := store.CreateOperation(ctx, state.OperationRecord{
+			OperationID: operation,
+			SecaRef:     computeInstanceRef(tenant, workspace, instance.Name),
+			Phase:       "succeeded",
+		}); err != nil {
+			return "", err
 		}
+		return operation, nil
+	}
+	found, actionID, err := l.action(ctx, instance.Name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", hetzner.ProviderError{Code: "not_found", Message: "instance not found"}
+	}
+	if err := store.CreateOperation(ctx, state.OperationRecord{
+		OperationID:      operation,
+		SecaRef:          computeInstanceRef(tenant, workspace, instance.Name),
+		ProviderActionID: actionID,
+		Phase:            "accepted",
+	}); err != nil {
+		return "", err
+	}
+	return operation, nil
+}
+
+func instanceAction(provider ComputeStorageProvider, lifecycle instanceLifecycle, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "instance name is required")
 		if !ok {
 			return
@@ -302,21 +672,16 @@ func instanceAction(action func(ctx context.Context, name string) (bool, string,
 		if !ok {
 			return
 		}
-		found, actionID, err := action(ctx, name)
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		if !found {
+		if instance == nil {
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
 			return
 		}
-		if err := store.CreateOperation(ctx, state.OperationRecord{
-			OperationID:      operationID(phase, name),
-			SecaRef:          computeInstanceRef(tenant, workspace, name),
-			ProviderActionID: actionID,
-			Phase:            "accepted",
-		}); err != nil {
+		if _, err := lifecycle.run(ctx, store, tenant, workspace, *instance); err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
@@ -324,17 +689,187 @@ func instanceAction(action func(ctx context.Context, name string) (bool, string,
 	}
 }
 
-func toInstanceResource(tenant, workspace string, instance hetzner.Instance, verb, state string, specOverride *instanceSpec) instanceResource {
-	now := time.Now().UTC().Format(time.RFC3339)
+// resolveInstanceSecurityGroups checks that every referenced security group
+// exists in the workspace and returns the normalized refs and provider names.
+// An adopted group resolves to the name of the firewall it adopts.
+func resolveInstanceSecurityGroups(ctx context.Context, w http.ResponseWriter, r *http.Request, store *state.Store, networkProvider NetworkProvider, tenant, workspace string, refs []refObject) ([]refObject, []string, bool) {
+	normalized := make([]refObject, 0, len(refs))
+	names := make([]string, 0, len(refs))
+	seen := map[string]struct{}{}
+	for i, ref := range refs {
+		name := resourceNameFromRef(ref.Resource)
+		if name == "" {
+			respondProblemWithSources(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", fmt.Sprintf("spec.securityGroupRefs[%d] is empty", i), r.URL.Path, []problemSource{{Pointer: fmt.Sprintf("/spec/securityGroupRefs/%d", i)}})
+			return nil, nil, false
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		binding, err := store.GetResourceBinding(r.Context(), securityGroupRef(tenant, workspace, name))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+			return nil, nil, false
+		}
+		var group *hetzner.SecurityGroup
+		adopted, isAdopted := adoptedSecurityGroupFromBinding(binding)
+		if isAdopted {
+			group, err = networkProvider.GetSecurityGroupByID(ctx, adopted.ProviderID)
+		} else {
+			group, err = networkProvider.GetSecurityGroup(ctx, name)
+		}
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return nil, nil, false
+		}
+		if group == nil || (!isAdopted && !providerLabelsInScope(group.Labels, tenant, workspace)) {
+			respondProblemWithSources(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", fmt.Sprintf("security group %q not found", name), r.URL.Path, []problemSource{{Pointer: fmt.Sprintf("/spec/securityGroupRefs/%d", i)}})
+			return nil, nil, false
+		}
+		normalized = append(normalized, refObject{Resource: "security-groups/" + name})
+		names = append(names, group.Name)
+	}
+	if len(normalized) == 0 {
+		normalized = nil
+	}
+	return normalized, names, true
+}
+
+// workspaceSecurityGroupOwner reports which firewalls belong to the
+// workspace: those labelled for it and those its security groups adopt. An
+// instance sync only removes these, so firewalls applied from the console or
+// by the internet gateway stay on the server.
+func workspaceSecurityGroupOwner(ctx context.Context, store *state.Store, tenant, workspace string) (func(hetzner.SecurityGroup) bool, error) {
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindSecurityGroup)
+	if err != nil {
+		return nil, err
+	}
+	adoptedIDs := map[int64]struct{}{}
+	for i := range bindings {
+		if adopted, ok := adoptedSecurityGroupFromBinding(&bindings[i]); ok {
+			adoptedIDs[adopted.ProviderID] = struct{}{}
+		}
+	}
+	return func(group hetzner.SecurityGroup) bool {
+		if _, ok := adoptedIDs[group.ID]; ok {
+			return true
+		}
+		return providerLabelsInScope(group.Labels, tenant, workspace)
+	}, nil
+}
+
+// getWorkspaceInstance resolves a server by name and hides it unless its SECA
+// labels place it in tenant/workspace, so other scopes read it as missing.
+func getWorkspaceInstance(ctx context.Context, provider ComputeStorageProvider, tenant, workspace, name string) (*hetzner.Instance, error) {
+	instance, err := provider.GetInstance(ctx, name)
+	if err != nil || instance == nil {
+		return nil, err
+	}
+	if !providerLabelsInScope(instance.Labels, tenant, workspace) {
+		return nil, nil
+	}
+	return instance, nil
+}
+
+// bootVolumeName returns the block storage named by spec.bootVolume.deviceRef.
+// Other device references, such as images, are only kept for display.
+func bootVolumeName(ref refObject) string {
+	resource := strings.ToLower(strings.TrimSpace(ref.Resource))
+	if !strings.Contains(resource, "block-storages/") {
+		return ""
+	}
+	return resourceNameFromRef(resource)
+}
+
+// bootVolumeStatus reports the boot volume once Hetzner lists it on the
+// server.
+func bootVolumeStatus(ctx context.Context, provider ComputeStorageProvider, tenant, workspace string, instance hetzner.Instance, spec instanceSpec) *volumeReference {
+	name := bootVolumeName(spec.BootVolume.DeviceRef)
+	if name == "" {
+		return nil
+	}
+	volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
+	if err != nil || volume == nil || !slices.Contains(instance.VolumeIDs, volume.ID) {
+		return nil
+	}
+	return &volumeReference{DeviceRef: refObject{Resource: "block-storages/" + volume.Name}}
+}
+
+// instanceStateValue reports "updating" while Hetzner still runs an action
+// on the server, such as a resize or rebuild.
+// instanceStateValue maps the Hetzner server status onto the SECA lifecycle.
+// An accepted delete of the same server reads as deleting while the server
+// still lists.
+func instanceStateValue(tenant, workspace string, instance hetzner.Instance) string {
+	ref := computeInstanceRef(tenant, workspace, instance.Name)
+	if _, deleting := pendingDeletes.inProgress(ref, serverProviderRef(instance.ID, instance.Name)); deleting {
+		return "deleting"
+	}
+	switch hcloud.ServerStatus(instance.Status) {
+	case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting:
+		return "creating"
+	case hcloud.ServerStatusDeleting:
+		return "deleting"
+	case hcloud.ServerStatusRebuilding, hcloud.ServerStatusMigrating:
+		return "updating"
+	case hcloud.ServerStatusUnknown:
+		return "error"
+	}
+	if instance.Locked {
+		return "updating"
+	}
+	return "active"
+}
+
+// appliedSKURef is the skuRef an instance reports: the requested one, or the
+// server type it runs on when the SKU fallback substituted another.
+func appliedSKURef(requested refObject, skuName, actual string) refObject {
+	if actual == "" || strings.EqualFold(actual, skuName) {
+		return requested
+	}
+	return refObject{Resource: "skus/" + strings.ToLower(actual)}
+}
+
+// instanceUpsertOperation names the operation recorded for an instance PUT.
+func instanceUpsertOperation(existing *hetzner.Instance, skuName, imageName, placementGroup string) string {
+	switch {
+	case existing == nil:
+		return "instance-upsert"
+	case !strings.EqualFold(existing.ImageName, imageName) && existing.ImageName != "":
+		return "instance-rebuild"
+	case !strings.EqualFold(existing.SKUName, skuName):
+		return "instance-resize"
+	case existing.PlacementGroup != placementGroup:
+		return "instance-placement"
+	}
+	return "instance-upsert"
+}
+
+// placementGroupSpecRef is the placementGroupRef an instance reports for the
+// reference it was given.
+func placementGroupSpecRef(ref *refObject) *refObject {
+	if ref == nil {
+		return nil
+	}
+	return &refObject{Resource: "placement-groups/" + resourceNameFromRef(ref.Resource)}
+}
+
+func toInstanceResource(tenant, workspace string, instance hetzner.Instance, verb resourceVerb, state string, specOverride *instanceSpec, systemLabels bool) instanceResource {
+	createdAt := providerTimestamp(instance.CreatedAt)
 	spec := instanceSpec{
 		SkuRef:     refObject{Resource: "skus/" + instance.SKUName},
 		ImageRef:   refObject{Resource: "images/" + instance.ImageName},
 		BootVolume: volumeReference{},
 		Zone:       instance.Region,
 	}
+	if instance.PlacementGroup != "" {
+		spec.PlacementGroupRef = &refObject{Resource: "placement-groups/" + instance.PlacementGroup}
+	}
 	if specOverride != nil {
 		spec = *specOverride
 	}
+	spec.UserData = ""
+	spec.BackupsEnabled = instance.BackupWindow != ""
 	region := defaultRegion(instance.Region)
 	if spec.Zone != "" {
 		region = defaultRegion(regionFromZone(spec.Zone))
@@ -345,8 +880,8 @@ func toInstanceResource(tenant, workspace string, instance hetzner.Instance, ver
 			Provider:        "seca.compute/v1",
 			Resource:        "tenants/" + tenant + "/workspaces/" + workspace + "/instances/" + instance.Name,
 			Verb:            verb,
-			CreatedAt:       now,
-			LastModifiedAt:  now,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  createdAt,
 			ResourceVersion: 1,
 			APIVersion:      "v1",
 			Kind:            "instance",
@@ -355,10 +890,26 @@ func toInstanceResource(tenant, workspace string, instance hetzner.Instance, ver
 			Workspace:       workspace,
 			Region:          region,
 		},
-		Spec: spec,
+		Labels: responseLabels(instance.Labels, systemLabels),
+		Spec:   spec,
 		Status: instanceStatus{
 			State:      state,
 			PowerState: instance.PowerState,
+			Zone:       instance.Zone,
+			PublicIPv4: instance.PublicIPv4,
+			PublicIPv6: instance.PublicIPv6,
+			BackupWindow: instance.BackupWindow,
 		},
 	}
 }
+
+// provider maps the spec onto the Hetzner request; nil keeps dual stack.
+func (n *instancePublicNetwork) provider() *hetzner.PublicNetwork {
+	if n == nil {
+		return nil
+	}
+	return &hetzner.PublicNetwork{
+		IPv4: n.IPv4 == nil || *n.IPv4,
+		IPv6: n.IPv6 == nil || *n.IPv6,
+	}
+}
@@ -0,0 +1,143 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestInstanceUpsertOperationNamesUpdateKind(t *testing.T) {
+	t.Parallel()
+
+	existing := &hetzner.Instance{Name: "vm1", SKUName: "cx22", ImageName: "ubuntu-24.04"}
+	cases := []struct {
+		name      string
+		existing  *hetzner.Instance
+		sku       string
+		image     string
+		placement string
+		want      string
+	}{
+		{name: "create", existing: nil, sku: "cx22", image: "ubuntu-24.04", want: "instance-upsert"},
+		{name: "unchanged", existing: existing, sku: "CX22", image: "ubuntu-24.04", want: "instance-upsert"},
+		{name: "resize", existing: existing, sku: "cx32", image: "ubuntu-24.04", want: "instance-resize"},
+		{name: "rebuild", existing: existing, sku: "cx22", image: "debian-12", want: "instance-rebuild"},
+		{name: "resize and rebuild", existing: existing, sku: "cx32", image: "debian-12", want: "instance-rebuild"},
+		{name: "placement", existing: existing, sku: "cx22", image: "ubuntu-24.04", placement: "ha", want: "instance-placement"},
+	}
+	for _, tc := range cases {
+		if got := instanceUpsertOperation(tc.existing, tc.sku, tc.image, tc.placement); got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestInstanceStateValueMapsServerStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		instance hetzner.Instance
+		want     string
+	}{
+		{instance: hetzner.Instance{}, want: "active"},
+		{instance: hetzner.Instance{Status: "running"}, want: "active"},
+		{instance: hetzner.Instance{Status: "off"}, want: "active"},
+		{instance: hetzner.Instance{Status: "running", Locked: true}, want: "updating"},
+		{instance: hetzner.Instance{Status: "initializing"}, want: "creating"},
+		{instance: hetzner.Instance{Status: "starting"}, want: "creating"},
+		{instance: hetzner.Instance{Status: "rebuilding"}, want: "updating"},
+		{instance: hetzner.Instance{Status: "migrating"}, want: "updating"},
+		{instance: hetzner.Instance{Status: "deleting"}, want: "deleting"},
+		{instance: hetzner.Instance{Status: "unknown"}, want: "error"},
+	}
+	for _, tc := range cases {
+		tc.instance.Name = "vm-state"
+		if got := instanceStateValue("t1", "ws1", tc.instance); got != tc.want {
+			t.Fatalf("%+v: expected %q, got %q", tc.instance, tc.want, got)
+		}
+	}
+
+	deleting := hetzner.Instance{ID: 9, Name: "vm-state-delete", Status: "running"}
+	pendingDeletes.accept(computeInstanceRef("t1", "ws1", deleting.Name), serverProviderRef(deleting.ID, deleting.Name), "op-9")
+	t.Cleanup(func() { pendingDeletes.forget(computeInstanceRef("t1", "ws1", deleting.Name)) })
+	if got := instanceStateValue("t1", "ws1", deleting); got != "deleting" {
+		t.Fatalf("expected an accepted delete to read as deleting, got %q", got)
+	}
+	if got := blockStorageStateValue("t1", "ws1", hetzner.BlockStorage{Name: "vol-state", Status: "creating"}); got != "creating" {
+		t.Fatalf("expected a creating volume to read as creating, got %q", got)
+	}
+}
+
+func TestInstanceActionChecksSkipPowerStateNoops(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		check    instanceActionCheck
+		status   string
+		done     bool
+		conflict bool
+	}{
+		{name: "start running", check: startInstanceCheck, status: "running", done: true},
+		{name: "start off", check: startInstanceCheck, status: "off"},
+		{name: "stop off", check: stopInstanceCheck, status: "off", done: true},
+		{name: "stop stopping", check: stopInstanceCheck, status: "stopping", done: true},
+		{name: "stop running", check: stopInstanceCheck, status: "running"},
+		{name: "restart running", check: restartInstanceCheck, status: "running"},
+		{name: "restart off", check: restartInstanceCheck, status: "off", conflict: true},
+	}
+	for _, tc := range cases {
+		done, conflict := tc.check(tc.status)
+		if done != tc.done || (conflict != "") != tc.conflict {
+			t.Fatalf("%s: expected done=%t conflict=%t, got %t %q", tc.name, tc.done, tc.conflict, done, conflict)
+		}
+	}
+}
+
+func TestWaitForInstanceDeleteTimesOutWithGatewayTimeout(t *testing.T) {
+	t.Parallel()
+
+	ref := computeInstanceRef("t1", "ws1", "vm-wait")
+	pendingDeletes.accept(ref, "server/1/vm-wait", "op-1")
+	provider := &fakeComputeProvider{waitErr: fmt.Errorf("%w: remaining running actions: [7]", context.DeadlineExceeded)}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/compute/v1/tenants/t1/workspaces/ws1/instances/vm-wait?wait=true", nil)
+	if waitForInstanceDelete(context.Background(), w, r, provider, nil, ref, "op-1", "7") {
+		t.Fatal("expected the handler to stop after a timed out wait")
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", w.Code, w.Body.String())
+	}
+	if operation, ok := pendingDeletes.inProgress(ref, "server/1/vm-wait"); !ok || operation != "op-1" {
+		t.Fatalf("expected the delete to stay in progress, got %q (%v)", operation, ok)
+	}
+}
+
+func TestInstanceTimestampsComeFromProviderAndBinding(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2026, 3, 1, 8, 0, 0, 0, time.FixedZone("CET", 3600))
+	instance := hetzner.Instance{Name: "vm1", CreatedAt: created}
+	first := toInstanceResource("t1", "ws1", instance, verbGet, "active", nil, false)
+	second := toInstanceResource("t1", "ws1", instance, verbGet, "active", nil, false)
+	if first.Metadata.CreatedAt != "2026-03-01T07:00:00Z" || first.Metadata != second.Metadata {
+		t.Fatalf("expected stable provider timestamps, got %+v and %+v", first.Metadata, second.Metadata)
+	}
+
+	stampLastModified(&first.Metadata, &state.ResourceBinding{UpdatedAt: created.Add(time.Hour)})
+	if first.Metadata.LastModifiedAt != "2026-03-01T08:00:00Z" {
+		t.Fatalf("expected lastModifiedAt from the binding, got %q", first.Metadata.LastModifiedAt)
+	}
+
+	static := toInstanceResource("t1", "ws1", hetzner.Instance{Name: "vm2"}, verbGet, "active", nil, false)
+	if static.Metadata.CreatedAt != catalogTimestamp {
+		t.Fatalf("expected the process start for an undated instance, got %q", static.Metadata.CreatedAt)
+	}
+}
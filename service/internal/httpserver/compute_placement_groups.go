@@ -0,0 +1,479 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const resourceBindingKindPlacementGroup = "placement-group"
+
+// placementPolicySpread is the only policy Hetzner offers: every instance of
+// the group runs on a different physical host.
+const placementPolicySpread = "spread"
+
+type placementGroupIterator struct {
+	Items    []placementGroupResource `json:"items"`
+	Metadata responseMetaObject       `json:"metadata"`
+}
+
+type placementGroupResource struct {
+	Metadata resourceMetadata     `json:"metadata"`
+	Labels   map[string]string    `json:"labels,omitempty"`
+	Spec     placementGroupSpec   `json:"spec"`
+	Status   placementGroupStatus `json:"status"`
+}
+
+type placementGroupSpec struct {
+	Policy string `json:"policy"`
+}
+
+type placementGroupStatus struct {
+	State        string      `json:"state"`
+	InstanceRefs []refObject `json:"instanceRefs,omitempty"`
+}
+
+type placementGroupBindingPayload struct {
+	Name        string             `json:"name"`
+	Labels      map[string]string  `json:"labels,omitempty"`
+	Spec        placementGroupSpec `json:"spec"`
+	ProviderRef string             `json:"providerRef,omitempty"`
+	// Instances are the instances Hetzner reports in the group. They are
+	// read on every request and never stored.
+	Instances []string `json:"-"`
+}
+
+func listPlacementGroups(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		bindings, err := store.ListResourceBindings(r.Context(), tenant, workspace, resourceBindingKindPlacementGroup)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list placement groups", r.URL.Path)
+			return
+		}
+		groups, err := provider.ListPlacementGroups(ctx)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		instances, err := provider.ListInstances(ctx)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		byName := make(map[string]hetzner.PlacementGroup, len(groups))
+		for _, group := range groups {
+			if providerLabelsInScope(group.Labels, tenant, workspace) {
+				byName[group.Name] = group
+			}
+		}
+		items := make([]placementGroupResource, 0, len(bindings))
+		for _, binding := range bindings {
+			payload, err := parsePlacementGroupBinding(binding.ProviderRef)
+			if err != nil {
+				continue
+			}
+			if group, ok := byName[payload.Name]; ok {
+				applyPlacementGroup(&payload, group, instances, tenant, workspace)
+			}
+			items = append(items, toPlacementGroupResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
+		}
+		respondJSON(w, http.StatusOK, placementGroupIterator{
+			Items:    items,
+			Metadata: responseMetaObject{Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/placement-groups", Verb: verbList},
+		})
+	}
+}
+
+func getPlacementGroup(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "placement group name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		binding, err := store.GetResourceBinding(r.Context(), placementGroupRef(tenant, workspace, name))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load placement group", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "placement group not found", r.URL.Path)
+			return
+		}
+		payload, err := parsePlacementGroupBinding(binding.ProviderRef)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid placement group payload", r.URL.Path)
+			return
+		}
+		group, err := provider.GetPlacementGroup(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if group != nil && providerLabelsInScope(group.Labels, tenant, workspace) {
+			instances, err := provider.ListInstances(ctx)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			applyPlacementGroup(&payload, *group, instances, tenant, workspace)
+		}
+		respondJSON(w, http.StatusOK, toPlacementGroupResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
+	}
+}
+
+func putPlacementGroup(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "placement group name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		var req placementGroupResource
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
+			return
+		}
+		var problems []fieldProblem
+		req.Spec.Policy = strings.ToLower(strings.TrimSpace(req.Spec.Policy))
+		if req.Spec.Policy == "" {
+			req.Spec.Policy = placementPolicySpread
+		}
+		if req.Spec.Policy != placementPolicySpread {
+			problems = append(problems, fieldPointer("/spec/policy", "spec.policy must be %q", placementPolicySpread))
+		}
+		problems = append(problems, reservedLabelProblems(req.Labels)...)
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
+			return
+		}
+		ref := placementGroupRef(tenant, workspace, name)
+		existing, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load placement group", r.URL.Path)
+			return
+		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		group, ok := ensurePlacementGroup(ctx, w, r, provider, tenant, workspace, name, req.Labels)
+		if !ok {
+			return
+		}
+		instances, err := provider.ListInstances(ctx)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		payload := placementGroupBindingPayload{Name: name, Labels: req.Labels, Spec: req.Spec}
+		applyPlacementGroup(&payload, *group, instances, tenant, workspace)
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode placement group", r.URL.Path)
+			return
+		}
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
+			Tenant:      tenant,
+			Workspace:   workspace,
+			Kind:        resourceBindingKindPlacementGroup,
+			SecaRef:     ref,
+			ProviderRef: string(raw),
+			Status:      "active",
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save placement group", r.URL.Path)
+			return
+		}
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil || binding == nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load placement group", r.URL.Path)
+			return
+		}
+		stateValue, code := "updating", http.StatusOK
+		if existing == nil {
+			stateValue, code = "creating", http.StatusCreated
+		}
+		respondJSON(w, code, toPlacementGroupResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
+	}
+}
+
+// deletePlacementGroup refuses to delete a group that instances are still
+// placed in: Hetzner would refuse too, and the instance specs would dangle.
+func deletePlacementGroup(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "placement group name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
+		ref := placementGroupRef(tenant, workspace, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load placement group", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			respondDeleteNotFound(w, r, ref, "placement group not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
+			return
+		}
+		group, err := provider.GetPlacementGroup(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if group != nil && providerLabelsInScope(group.Labels, tenant, workspace) {
+			if len(group.ServerIDs) > 0 {
+				instances, err := provider.ListInstances(ctx)
+				if err != nil {
+					respondFromError(w, err, r.URL.Path)
+					return
+				}
+				refs := placementGroupInstanceRefs(*group, instances, tenant, workspace)
+				sources := make([]problemSource, 0, len(refs))
+				for _, instanceRef := range refs {
+					sources = append(sources, problemSource{Parameter: instanceRef})
+				}
+				detail := fmt.Sprintf("placement group %q is still referenced by %s; move or delete those instances first", name, strings.Join(refs, ", "))
+				respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", detail, r.URL.Path, sources)
+				return
+			}
+			if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
+				return
+			}
+			if _, err := provider.DeletePlacementGroup(ctx, name); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete placement group", r.URL.Path)
+			return
+		}
+		respondDeleteAccepted(w, "")
+	}
+}
+
+// ensurePlacementGroup creates the Hetzner placement group name, or relabels
+// it when it already belongs to the workspace. A group of the same name in
+// another workspace answers 409.
+func ensurePlacementGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, tenant, workspace, name string, labels map[string]string) (*hetzner.PlacementGroup, bool) {
+	current, err := provider.GetPlacementGroup(ctx, name)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return nil, false
+	}
+	if current != nil && !providerLabelsInScope(current.Labels, tenant, workspace) {
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "placement group name is already in use outside this workspace", r.URL.Path)
+		return nil, false
+	}
+	group, _, err := provider.CreateOrUpdatePlacementGroup(ctx, hetzner.PlacementGroupCreateRequest{
+		Name: name,
+		Labels: withSecaProviderLabels(
+			labels,
+			tenant,
+			workspace,
+			resourceBindingKindPlacementGroup,
+			name,
+			placementGroupRef(tenant, workspace, name),
+		),
+	})
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return nil, false
+	}
+	if group == nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "provider returned empty placement group", r.URL.Path)
+		return nil, false
+	}
+	return group, true
+}
+
+// resolveInstancePlacementGroup returns the Hetzner placement group an
+// instance PUT places the server in. A group the instance references before
+// it was created is created on the spot, with a binding, so the reference
+// alone is enough; a dry run only checks that the name is free.
+func resolveInstancePlacementGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, store *state.Store, tenant, workspace string, ref *refObject, dryRun bool) (string, bool) {
+	if ref == nil {
+		return "", true
+	}
+	name := resourceNameFromRef(ref.Resource)
+	if name == "" {
+		respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/placementGroupRef/resource", "spec.placementGroupRef.resource is required"))
+		return "", false
+	}
+	if err := validateResourceName(name); err != nil {
+		respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/placementGroupRef/resource", "placement group name %v", err))
+		return "", false
+	}
+	current, err := provider.GetPlacementGroup(ctx, name)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return "", false
+	}
+	if current != nil && !providerLabelsInScope(current.Labels, tenant, workspace) {
+		respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("placement group %q is already in use outside this workspace", name), r.URL.Path, []problemSource{{Pointer: "/spec/placementGroupRef"}})
+		return "", false
+	}
+	if current != nil {
+		return name, true
+	}
+	if dryRun {
+		return "", true
+	}
+	binding, err := store.GetResourceBinding(ctx, placementGroupRef(tenant, workspace, name))
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load placement group", r.URL.Path)
+		return "", false
+	}
+	payload := placementGroupBindingPayload{Name: name, Spec: placementGroupSpec{Policy: placementPolicySpread}}
+	if binding != nil {
+		if stored, err := parsePlacementGroupBinding(binding.ProviderRef); err == nil {
+			payload = stored
+		}
+	}
+	group, ok := ensurePlacementGroup(ctx, w, r, provider, tenant, workspace, name, payload.Labels)
+	if !ok {
+		return "", false
+	}
+	payload.ProviderRef = placementGroupProviderRef(group.ID)
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode placement group", r.URL.Path)
+		return "", false
+	}
+	if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		Tenant:      tenant,
+		Workspace:   workspace,
+		Kind:        resourceBindingKindPlacementGroup,
+		SecaRef:     placementGroupRef(tenant, workspace, name),
+		ProviderRef: string(raw),
+		Status:      "active",
+	}); err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save placement group", r.URL.Path)
+		return "", false
+	}
+	return name, true
+}
+
+func placementGroupRef(tenant, workspace, name string) string {
+	return "seca.compute/v1/tenants/" + strings.ToLower(strings.TrimSpace(tenant)) +
+		"/workspaces/" + strings.ToLower(strings.TrimSpace(workspace)) +
+		"/placement-groups/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+func placementGroupProviderRef(id int64) string {
+	return fmt.Sprintf("hetzner.cloud/placement-groups/%d", id)
+}
+
+func parsePlacementGroupBinding(raw string) (placementGroupBindingPayload, error) {
+	var payload placementGroupBindingPayload
+	err := json.Unmarshal([]byte(raw), &payload)
+	return payload, err
+}
+
+func applyPlacementGroup(payload *placementGroupBindingPayload, group hetzner.PlacementGroup, instances []hetzner.Instance, tenant, workspace string) {
+	payload.ProviderRef = placementGroupProviderRef(group.ID)
+	payload.Instances = placementGroupInstanceRefs(group, instances, tenant, workspace)
+}
+
+// placementGroupInstanceRefs names the servers in group: the instance ref
+// for servers of the workspace, the Hetzner ref otherwise.
+func placementGroupInstanceRefs(group hetzner.PlacementGroup, instances []hetzner.Instance, tenant, workspace string) []string {
+	refs := make([]string, 0, len(group.ServerIDs))
+	for _, id := range group.ServerIDs {
+		ref := serverProviderRef(id, "")
+		for _, instance := range instances {
+			if instance.ID == id && providerLabelsInScope(instance.Labels, tenant, workspace) {
+				ref = computeInstanceRef(tenant, workspace, instance.Name)
+				break
+			}
+		}
+		refs = append(refs, ref)
+	}
+	slices.Sort(refs)
+	return refs
+}
+
+func toPlacementGroupResourceFromBinding(
+	binding state.ResourceBinding,
+	payload placementGroupBindingPayload,
+	tenant,
+	workspace string,
+	verb resourceVerb,
+	stateValue string,
+) placementGroupResource {
+	createdAt := catalogTimestamp
+	updatedAt := createdAt
+	if !binding.CreatedAt.IsZero() {
+		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if !binding.UpdatedAt.IsZero() {
+		updatedAt = binding.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	var instanceRefs []refObject
+	for _, ref := range payload.Instances {
+		instanceRefs = append(instanceRefs, refObject{Resource: ref})
+	}
+	return placementGroupResource{
+		Metadata: resourceMetadata{
+			Name:            payload.Name,
+			Provider:        "seca.compute/v1",
+			Resource:        "tenants/" + tenant + "/workspaces/" + workspace + "/placement-groups/" + payload.Name,
+			Verb:            verb,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  updatedAt,
+			ResourceVersion: binding.ResourceVersion,
+			APIVersion:      "v1",
+			Kind:            "placement-group",
+			Ref:             placementGroupRef(tenant, workspace, payload.Name),
+			Tenant:          tenant,
+			Workspace:       workspace,
+			Region:          "global",
+		},
+		Labels: payload.Labels,
+		Spec:   payload.Spec,
+		Status: placementGroupStatus{State: stateValue, InstanceRefs: instanceRefs},
+	}
+}
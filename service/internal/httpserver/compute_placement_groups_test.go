@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestPlacementGroupInstanceRefsNameWorkspaceInstances(t *testing.T) {
+	t.Parallel()
+
+	prod := withSecaProviderLabels(nil, "acme", "prod", "instance", "", "")
+	staging := withSecaProviderLabels(nil, "acme", "staging", "instance", "", "")
+	instances := []hetzner.Instance{
+		{ID: 7, Name: "web-2", Labels: prod},
+		{ID: 8, Name: "db-1", Labels: staging},
+		{ID: 9, Name: "web-1", Labels: prod},
+	}
+	group := hetzner.PlacementGroup{Name: "ha", ServerIDs: []int64{9, 8, 7}}
+	got := placementGroupInstanceRefs(group, instances, "acme", "prod")
+	want := []string{
+		"hetzner.cloud/servers/8",
+		computeInstanceRef("acme", "prod", "web-1"),
+		computeInstanceRef("acme", "prod", "web-2"),
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPlacementGroupSpecRefNormalizesReference(t *testing.T) {
+	t.Parallel()
+
+	if ref := placementGroupSpecRef(nil); ref != nil {
+		t.Fatalf("expected no ref, got %+v", ref)
+	}
+	ref := placementGroupSpecRef(&refObject{Resource: "seca.compute/v1/tenants/acme/workspaces/prod/placement-groups/ha"})
+	if ref == nil || ref.Resource != "placement-groups/ha" {
+		t.Fatalf("unexpected ref %+v", ref)
+	}
+}
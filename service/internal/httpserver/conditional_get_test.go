@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConditionalGETAnswersNotModifiedForMatchingETag(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{name}", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]string{"name": r.PathValue("name")})
+	})
+	mux.HandleFunc("GET /things", func(w http.ResponseWriter, _ *http.Request) {
+		respondJSON(w, http.StatusOK, map[string][]string{"items": {"a"}})
+	})
+	handler := withConditionalGET(mux)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/things/a", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("expected 200 with a strong ETag, got %d %q", rec.Code, etag)
+	}
+
+	cases := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "match", path: "/things/a", ifNoneMatch: etag, status: http.StatusNotModified},
+		{name: "match in list", path: "/things/a", ifNoneMatch: `"other", ` + etag, status: http.StatusNotModified},
+		{name: "mismatch", path: "/things/a", ifNoneMatch: `"other"`, status: http.StatusOK},
+		{name: "changed resource", path: "/things/b", ifNoneMatch: etag, status: http.StatusOK},
+		{name: "missing header", path: "/things/a", status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.status, rec.Code)
+		}
+		if tc.status == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Fatalf("%s: expected an empty body, got %q", tc.name, rec.Body.String())
+		}
+		if tc.status == http.StatusOK && rec.Body.Len() == 0 {
+			t.Fatalf("%s: expected the resource body", tc.name)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/things", nil))
+	listETag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(listETag, "W/") {
+		t.Fatalf("expected a weak ETag on the collection, got %q", listETag)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/things", nil)
+	req.Header.Set("If-None-Match", listETag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for an unchanged collection, got %d", rec.Code)
+	}
+}
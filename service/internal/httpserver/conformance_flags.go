@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+const (
+	conformanceHeader = "X-Seca-Conformance"
+	adminTokenHeader  = "X-Seca-Admin-Token"
+)
+
+// withConformanceFlags puts the conformance flags of every request on its
+// context for the handlers and the provider. X-Seca-Conformance: off (or on)
+// turns every flag off (or on) for one request; it needs the admin token in
+// X-Seca-Admin-Token since the public Authorization header carries the
+// tenant's.
+func withConformanceFlags(defaults config.ConformanceFlags, adminToken string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flags := defaults
+		if override := strings.TrimSpace(r.Header.Get(conformanceHeader)); override != "" {
+			presented := r.Header.Get(adminTokenHeader)
+			if strings.TrimSpace(adminToken) == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(adminToken)) != 1 {
+				respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", conformanceHeader+" requires a valid "+adminTokenHeader, r.URL.Path)
+				return
+			}
+			switch strings.ToLower(override) {
+			case "off":
+				flags = config.ConformanceFlags{}
+			case "on":
+				flags = config.ConformanceFlags{SKUFallback: true, LocationFallback: true, LockMasking: true, ImageStub: true, BackupSkip: true}
+			default:
+				respondValidationProblem(w, r.URL.Path, fieldParameter(conformanceHeader, "%s must be on or off", conformanceHeader))
+				return
+			}
+			if flags != defaults {
+				log.Printf("conformance flags for %s %s: %s (defaults %s)", r.Method, r.URL.Path, flags, defaults)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(hetzner.WithConformanceFlags(r.Context(), flags)))
+	})
+}
+
+// conformanceFlags returns the conformance flags withConformanceFlags put on
+// the request, or defaults outside it.
+func conformanceFlags(r *http.Request, defaults config.ConformanceFlags) config.ConformanceFlags {
+	return hetzner.ConformanceFlagsFrom(r.Context(), defaults)
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+)
+
+func TestConformanceHeaderOverridesFlagsForAdmins(t *testing.T) {
+	t.Parallel()
+
+	defaults := config.ConformanceFlags{SKUFallback: true, LocationFallback: true, ImageStub: true}
+	var seen config.ConformanceFlags
+	handler := withConformanceFlags(defaults, "admin-secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = conformanceFlags(r, config.ConformanceFlags{})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(headers map[string]string) int {
+		req := httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/t1/workspaces/ws1/instances", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(nil); code != http.StatusNoContent || seen != defaults {
+		t.Fatalf("expected the defaults without a header, got %d with %s", code, seen)
+	}
+	if code := serve(map[string]string{conformanceHeader: "off", adminTokenHeader: "admin-secret"}); code != http.StatusNoContent || seen != (config.ConformanceFlags{}) {
+		t.Fatalf("expected off to clear every flag, got %d with %s", code, seen)
+	}
+	if code := serve(map[string]string{conformanceHeader: "off", adminTokenHeader: "tenant-token"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without the admin token, got %d", code)
+	}
+	if code := serve(map[string]string{conformanceHeader: "maybe", adminTokenHeader: "admin-secret"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown value, got %d", code)
+	}
+}
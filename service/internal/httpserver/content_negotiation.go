@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// withAcceptNegotiation answers 406 when the Accept header rules out JSON.
+// Every public endpoint speaks application/json on success and
+// application/problem+json on failure, so a client accepting either, or any
+// range covering them, is served; a missing Accept header accepts anything.
+// The 406 itself is a problem document regardless of Accept.
+func withAcceptNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := strings.Join(r.Header.Values("Accept"), ",")
+		if strings.TrimSpace(accept) == "" || acceptsJSON(accept, documentMediaTypes[r.URL.Path]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		respondProblem(w, http.StatusNotAcceptable, "http://secapi.cloud/errors/invalid-request", "Not Acceptable", "Accept must allow application/json or application/problem+json", r.URL.Path)
+	})
+}
+
+// documentMediaTypes are the media types of the paths that answer in
+// something other than JSON on success.
+var documentMediaTypes = map[string]string{
+	"/openapi.yaml": openAPIYAMLContentType,
+}
+
+// acceptsJSON reports whether accept allows JSON, or extra when it is set.
+func acceptsJSON(accept, extra string) bool {
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		switch mediaType {
+		case "*/*", "application/*", "application/json", problemContentType:
+			return true
+		}
+		if extra != "" && mediaType == extra {
+			return true
+		}
+	}
+	return false
+}
+
+// withLegacyProblemContentType labels problem documents application/json as
+// they were before problems got their own media type, for clients that
+// match on the exact header. It goes away in the next release.
+func withLegacyProblemContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&legacyProblemWriter{ResponseWriter: w}, r)
+	})
+}
+
+type legacyProblemWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *legacyProblemWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		header := w.Header()
+		if header.Get("Content-Type") == problemContentType {
+			header.Set("Content-Type", "application/json")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *legacyProblemWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *legacyProblemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
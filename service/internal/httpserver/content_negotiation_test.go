@@ -0,0 +1,79 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestProblemsUseProblemContentType(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /invalid", func(w http.ResponseWriter, r *http.Request) {
+		respondValidationProblem(w, r.URL.Path, fieldPointer("/spec", "spec is required"))
+	})
+	mux.HandleFunc("GET /conflict", func(w http.ResponseWriter, r *http.Request) {
+		respondFromError(w, hetzner.ProviderError{Code: "conflict", Message: "busy"}, r.URL.Path)
+	})
+	mux.HandleFunc("GET /internal", func(w http.ResponseWriter, r *http.Request) {
+		respondFromError(w, errors.New("boom"), r.URL.Path)
+	})
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, _ *http.Request) {
+		respondJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+	})
+	handler := withAcceptNegotiation(problemFallbacks(mux))
+
+	cases := []struct {
+		method, path, accept string
+		want                 int
+		wantType             string
+	}{
+		{method: http.MethodPost, path: "/invalid", want: http.StatusBadRequest, wantType: problemContentType},
+		{method: http.MethodGet, path: "/missing", want: http.StatusNotFound, wantType: problemContentType},
+		{method: http.MethodGet, path: "/conflict", want: http.StatusConflict, wantType: problemContentType},
+		{method: http.MethodGet, path: "/internal", want: http.StatusInternalServerError, wantType: problemContentType},
+		{method: http.MethodGet, path: "/ok", want: http.StatusOK, wantType: "application/json"},
+		{method: http.MethodGet, path: "/ok", accept: "application/json", want: http.StatusOK, wantType: "application/json"},
+		{method: http.MethodGet, path: "/ok", accept: "text/html, */*;q=0.1", want: http.StatusOK, wantType: "application/json"},
+		{method: http.MethodGet, path: "/ok", accept: "text/html", want: http.StatusNotAcceptable, wantType: problemContentType},
+		{method: http.MethodGet, path: "/ok", accept: "application/json;q=0", want: http.StatusNotAcceptable, wantType: problemContentType},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s (Accept %q): expected %d, got %d", tc.method, tc.path, tc.accept, tc.want, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tc.wantType {
+			t.Fatalf("%s %s (Accept %q): expected Content-Type %q, got %q", tc.method, tc.path, tc.accept, tc.wantType, got)
+		}
+		if tc.want != http.StatusOK {
+			var problem problemResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Status != tc.want {
+				t.Fatalf("%s %s: expected a problem body, got %s (%v)", tc.method, tc.path, rec.Body.String(), err)
+			}
+		}
+	}
+}
+
+func TestLegacyProblemContentTypeRestoresApplicationJSON(t *testing.T) {
+	t.Parallel()
+
+	handler := withLegacyProblemContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "region not found", r.URL.Path)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/regions/nope", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a 404 labelled application/json, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
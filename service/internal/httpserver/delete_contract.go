@@ -0,0 +1,118 @@
+package httpserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DELETE contract shared by every resource kind:
+//
+//   - the first accepted DELETE answers 202 with a deleteAcceptedResponse;
+//   - a DELETE repeated while the provider is still deleting the same object
+//     answers 202 again with the original operation, without a second
+//     provider call;
+//   - a DELETE of a resource that never existed or whose delete has finished
+//     answers 404.
+//
+// Handlers look the resource up first, answer 404 when it is gone, call
+// respondDeleteInProgress for resources that are still visible, and finish
+// with respondDeleteAccepted.
+//
+// Only instances need respondDeleteInProgress: Hetzner deletes servers
+// through an action that outlives the request. Volumes, networks, firewalls,
+// floating IPs, load balancers and placement groups are gone once their
+// delete call returns, and roles, role assignments and workspaces are soft
+// deleted in the store, so a repeated DELETE of any of them already finds
+// nothing and answers 404.
+
+// pendingDeleteTTL bounds how long an accepted delete is treated as still
+// running while the provider object remains visible.
+const pendingDeleteTTL = 5 * time.Minute
+
+var pendingDeletes = newDeleteTracker()
+
+type deleteAcceptedResponse struct {
+	Status    string `json:"status"`
+	Operation string `json:"operation,omitempty"`
+}
+
+type pendingDelete struct {
+	providerRef string
+	operation   string
+	acceptedAt  time.Time
+}
+
+// deleteTracker remembers deletes whose provider action may still be running.
+// Entries are keyed by SECA ref and bound to the provider object, so a
+// resource recreated under the same name is not mistaken for the old one.
+type deleteTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	pending map[string]pendingDelete
+}
+
+func newDeleteTracker() *deleteTracker {
+	return &deleteTracker{
+		now:     time.Now,
+		pending: map[string]pendingDelete{},
+	}
+}
+
+// accept records a delete and drops the expired entries, so deletes that
+// are never repeated do not keep their entry for the life of the process.
+func (t *deleteTracker) accept(ref, providerRef, operation string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for key, entry := range t.pending {
+		if now.Sub(entry.acceptedAt) > pendingDeleteTTL {
+			delete(t.pending, key)
+		}
+	}
+	t.pending[ref] = pendingDelete{providerRef: providerRef, operation: operation, acceptedAt: now}
+}
+
+// inProgress returns the operation of an accepted delete of the same provider
+// object that has not yet expired.
+func (t *deleteTracker) inProgress(ref, providerRef string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.pending[ref]
+	if !ok {
+		return "", false
+	}
+	if entry.providerRef != providerRef || t.now().Sub(entry.acceptedAt) > pendingDeleteTTL {
+		delete(t.pending, ref)
+		return "", false
+	}
+	return entry.operation, true
+}
+
+func (t *deleteTracker) forget(ref string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, ref)
+}
+
+// respondDeleteInProgress answers 202 when a delete of the same provider
+// object was already accepted and reports whether it did.
+func respondDeleteInProgress(w http.ResponseWriter, ref, providerRef string) bool {
+	operation, ok := pendingDeletes.inProgress(ref, providerRef)
+	if !ok {
+		return false
+	}
+	respondDeleteAccepted(w, operation)
+	return true
+}
+
+// respondDeleteNotFound answers 404 for a resource that never existed or whose
+// delete has finished.
+func respondDeleteNotFound(w http.ResponseWriter, r *http.Request, ref, detail string) {
+	pendingDeletes.forget(ref)
+	respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", detail, r.URL.Path)
+}
+
+func respondDeleteAccepted(w http.ResponseWriter, operation string) {
+	respondJSON(w, http.StatusAccepted, deleteAcceptedResponse{Status: "accepted", Operation: operation})
+}
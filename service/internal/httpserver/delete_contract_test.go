@@ -0,0 +1,137 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestDeleteTrackerRepeatsAcceptedInstanceDelete(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newDeleteTracker()
+	tracker.now = func() time.Time { return now }
+	ref := computeInstanceRef("t1", "ws1", "vm1")
+
+	if _, ok := tracker.inProgress(ref, serverProviderRef(1, "vm1")); ok {
+		t.Fatal("expected no delete in progress before the first DELETE")
+	}
+	tracker.accept(ref, serverProviderRef(1, "vm1"), "instance-delete-vm1-1")
+	if operation, ok := tracker.inProgress(ref, serverProviderRef(1, "vm1")); !ok || operation != "instance-delete-vm1-1" {
+		t.Fatalf("expected repeated DELETE to reuse the operation, got %q, %t", operation, ok)
+	}
+	if _, ok := tracker.inProgress(ref, serverProviderRef(2, "vm1")); ok {
+		t.Fatal("expected a recreated instance not to be treated as deleting")
+	}
+
+	tracker.accept(ref, serverProviderRef(1, "vm1"), "instance-delete-vm1-2")
+	now = now.Add(pendingDeleteTTL + time.Second)
+	if _, ok := tracker.inProgress(ref, serverProviderRef(1, "vm1")); ok {
+		t.Fatal("expected stale pending delete to expire")
+	}
+}
+
+func TestDeleteTrackerDropsExpiredDeletesOnAccept(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newDeleteTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.accept(computeInstanceRef("t1", "ws1", "vm1"), serverProviderRef(1, "vm1"), "instance-delete-vm1-1")
+	now = now.Add(pendingDeleteTTL + time.Second)
+	tracker.accept(computeInstanceRef("t1", "ws1", "vm2"), serverProviderRef(2, "vm2"), "instance-delete-vm2-1")
+
+	if len(tracker.pending) != 1 {
+		t.Fatalf("expected only the fresh delete to be kept, got %+v", tracker.pending)
+	}
+}
+
+func TestRespondDeleteNotFoundForgetsPendingDelete(t *testing.T) {
+	ref := blockStorageRef("t1", "ws1", "vol-forget")
+	pendingDeletes.accept(ref, volumeProviderRef(7, "vol-forget"), "")
+	t.Cleanup(func() { pendingDeletes.forget(ref) })
+
+	rec := httptest.NewRecorder()
+	respondDeleteNotFound(rec, httptest.NewRequest(http.MethodDelete, "/storage/v1/tenants/t1/workspaces/ws1/block-storages/vol-forget", nil), ref, "block storage not found")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if _, ok := pendingDeletes.inProgress(ref, volumeProviderRef(7, "vol-forget")); ok {
+		t.Fatal("expected pending delete to be forgotten once the resource is gone")
+	}
+}
+
+func TestImageDeleteFollowsDeleteContract(t *testing.T) {
+	mux := http.NewServeMux()
+	putImageHandler, deleteImageHandler := imageWriteHandlers(fakeCatalogProvider{}, nil, nil, true)
+	mux.HandleFunc("PUT /storage/v1/tenants/{tenant}/images/{name}", putImageHandler)
+	mux.HandleFunc("DELETE /storage/v1/tenants/{tenant}/images/{name}", deleteImageHandler)
+	t.Cleanup(func() { runtimeResourceState.deleteImage(imageRef("deletes", "custom")) })
+
+	path := "/storage/v1/tenants/deletes/images/custom"
+	put := httptest.NewRecorder()
+	mux.ServeHTTP(put, jsonRequest(http.MethodPut, path, `{"spec":{"blockStorageRef":{"resource":"block-storages/vol1"},"cpuArchitecture":"amd64"}}`))
+	if put.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on create, got %d: %s", put.Code, put.Body.String())
+	}
+
+	first := httptest.NewRecorder()
+	mux.ServeHTTP(first, httptest.NewRequest(http.MethodDelete, path, nil))
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 on first delete, got %d", first.Code)
+	}
+	var body deleteAcceptedResponse
+	if err := json.Unmarshal(first.Body.Bytes(), &body); err != nil || body.Status != "accepted" {
+		t.Fatalf("expected accepted status body, got %q (%v)", first.Body.String(), err)
+	}
+
+	second := httptest.NewRecorder()
+	mux.ServeHTTP(second, httptest.NewRequest(http.MethodDelete, path, nil))
+	if second.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 once the image is gone, got %d", second.Code)
+	}
+}
+
+func TestStoreBackedDeletesFollowDeleteContract(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	tenant := fmt.Sprintf("deletes-%d", time.Now().UnixNano())
+	if err := store.UpsertRole(ctx, state.AuthResource{Tenant: tenant, Name: "reader", Spec: map[string]any{"permissions": []any{}}}); err != nil {
+		t.Fatalf("upsert role: %v", err)
+	}
+	if err := store.UpsertRoleAssignment(ctx, state.AuthResource{Tenant: tenant, Name: "alice-reader", Spec: map[string]any{"subs": []any{"alice"}, "roles": []any{"reader"}}}); err != nil {
+		t.Fatalf("upsert role assignment: %v", err)
+	}
+	if _, err := store.UpsertWorkspace(ctx, state.WorkspaceResource{Tenant: tenant, Name: "ws-1", Region: "fsn1"}); err != nil {
+		t.Fatalf("upsert workspace: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /v1/tenants/{tenant}/roles/{name}", deleteAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("DELETE /v1/tenants/{tenant}/role-assignments/{name}", deleteAuthResourceHandler(store, "role-assignments", "role-assignment"))
+	mux.HandleFunc("DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}", deleteWorkspace(store, nil, nil))
+	for _, path := range []string{
+		"/v1/tenants/" + tenant + "/roles/reader",
+		"/v1/tenants/" + tenant + "/role-assignments/alice-reader",
+		"/workspace/v1/tenants/" + tenant + "/workspaces/ws-1",
+	} {
+		first := httptest.NewRecorder()
+		mux.ServeHTTP(first, httptest.NewRequest(http.MethodDelete, path, nil))
+		var body deleteAcceptedResponse
+		if first.Code != http.StatusAccepted || json.Unmarshal(first.Body.Bytes(), &body) != nil || body.Status != "accepted" {
+			t.Fatalf("%s: expected 202 with an accepted body, got %d: %s", path, first.Code, first.Body.String())
+		}
+		second := httptest.NewRecorder()
+		mux.ServeHTTP(second, httptest.NewRequest(http.MethodDelete, path, nil))
+		if second.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 once deleted, got %d: %s", path, second.Code, second.Body.String())
+		}
+	}
+}
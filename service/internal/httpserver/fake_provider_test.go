@@ -0,0 +1,645 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/fake"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+var (
+	_ RegionProvider         = (*fake.Provider)(nil)
+	_ CatalogProvider        = (*fake.Provider)(nil)
+	_ ComputeStorageProvider = (*fake.Provider)(nil)
+	_ NetworkProvider        = (*fake.Provider)(nil)
+)
+
+// fakeProviderServer serves the main CRUD routes against a fake provider in
+// an active workspace with Hetzner credentials.
+type fakeProviderServer struct {
+	t        *testing.T
+	mux      *http.ServeMux
+	provider *fake.Provider
+	store    *state.Store
+	prefix   string
+}
+
+func newFakeProviderServer(t *testing.T) *fakeProviderServer {
+	t.Helper()
+	store := testStore(t)
+	ctx := context.Background()
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	if _, err := store.UpsertWorkspace(ctx, state.WorkspaceResource{
+		Tenant: tenant,
+		Name:   "ws-1",
+		Region: "fsn1",
+		Status: map[string]any{"state": "active"},
+	}); err != nil {
+		t.Fatalf("upsert workspace: %v", err)
+	}
+	if _, err := store.UpsertWorkspaceProviderCredential(ctx, state.WorkspaceProviderCredential{
+		Tenant:    tenant,
+		Workspace: "ws-1",
+		Provider:  "hetzner",
+		APIToken:  "test-token",
+	}); err != nil {
+		t.Fatalf("upsert credential: %v", err)
+	}
+
+	provider := fake.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances", listInstances(provider, store))
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", getInstance(provider, store, false))
+	mux.HandleFunc("PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", putInstance(provider, provider, store, false, false, "ubuntu-24.04"))
+	mux.HandleFunc("DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", deleteInstance(provider, store, false))
+	mux.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:stop", stopWorkspaceInstances(provider, store))
+	mux.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:restart", restartWorkspaceInstances(provider, store))
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages", listBlockStorages(provider, store))
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", getBlockStorage(provider, store))
+	mux.HandleFunc("PUT /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", putBlockStorage(provider, store, 0))
+	mux.HandleFunc("POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/attach", attachBlockStorage(provider, store))
+	mux.HandleFunc("POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/detach", detachBlockStorage(provider, store))
+	mux.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks", listNetworksProvider(provider, store))
+	mux.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", getNetworkProvider(provider, store))
+	mux.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", putNetworkProvider(provider, store))
+	mux.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}", putSubnet(provider, store))
+	mux.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", putNIC(provider, provider, store))
+	mux.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", deleteNIC(provider, provider, store))
+	mux.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", getPublicIP(provider, store))
+	mux.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", putPublicIP(provider, store))
+	mux.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", deletePublicIP(provider, store))
+	mux.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", getSecurityGroup(provider, store))
+	mux.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", putSecurityGroup(provider, store))
+	mux.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", deleteSecurityGroup(provider, store))
+	mux.HandleFunc("POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{action}", adminSecurityGroupAction(store, provider))
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}", getPlacementGroup(provider, store))
+	mux.HandleFunc("DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}", deletePlacementGroup(provider, store))
+	return &fakeProviderServer{t: t, mux: mux, provider: provider, store: store, prefix: "/tenants/" + tenant + "/workspaces/ws-1"}
+}
+
+// do sends a request to /<api>/v1/tenants/<tenant>/workspaces/ws-1/<path>,
+// fails the test unless it is answered with want and returns the body.
+func (s *fakeProviderServer) do(method, api, path, body string, want int) string {
+	s.t.Helper()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(method, "/"+api+"/v1"+s.prefix+"/"+path, strings.NewReader(body)))
+	if rec.Code != want {
+		s.t.Fatalf("%s %s: expected %d, got %d: %s", method, path, want, rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestFakeProviderInstanceLifecycle(t *testing.T) {
+	server := newFakeProviderServer(t)
+
+	server.do(http.MethodPut, "compute", "instances/vm-1", `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	if !server.provider.Called("CreateInstance") {
+		t.Fatalf("expected the instance to be created, got calls %+v", server.provider.Calls(""))
+	}
+	server.do(http.MethodGet, "compute", "instances/vm-1", "", http.StatusOK)
+
+	server.do(http.MethodDelete, "compute", "instances/vm-1", "", http.StatusAccepted)
+	if !server.provider.Called("DeleteInstance") {
+		t.Fatal("expected the instance to be deleted")
+	}
+	if instance, _ := server.provider.GetInstance(context.Background(), "vm-1"); instance != nil {
+		t.Fatalf("expected vm-1 to be gone, got %+v", instance)
+	}
+	server.do(http.MethodGet, "compute", "instances/vm-1", "", http.StatusNotFound)
+}
+
+func TestFakeProviderBulkInstanceActions(t *testing.T) {
+	server := newFakeProviderServer(t)
+	ctx := context.Background()
+	for _, vm := range []struct{ name, env string }{{"vm-1", "prod"}, {"vm-2", "prod"}, {"vm-3", "dev"}} {
+		server.do(http.MethodPut, "compute", "instances/"+vm.name, `{"labels":{"env":"`+vm.env+`"},"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	}
+	if _, _, err := server.provider.StopInstance(ctx, "vm-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary bulkInstanceActionResponse
+	if err := json.Unmarshal([]byte(server.do(http.MethodPost, "compute", "instances:restart?labels=env=prod", "", http.StatusAccepted)), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Status != "partial" || summary.Accepted != 1 || summary.Failed != 1 || len(summary.Results) != 2 {
+		t.Fatalf("expected one restart and one failure among the prod instances, got %+v", summary)
+	}
+	for _, result := range summary.Results {
+		switch result.Instance {
+		case "instances/vm-1":
+			if result.Status != "accepted" || result.Operation == "" || result.Problem != nil {
+				t.Fatalf("expected vm-1 restarted, got %+v", result)
+			}
+		case "instances/vm-2":
+			if result.Status != "failed" || result.Problem == nil || result.Problem.Status != http.StatusConflict {
+				t.Fatalf("expected the stopped vm-2 to fail with 409, got %+v", result)
+			}
+		default:
+			t.Fatalf("expected the selector to leave out %s", result.Instance)
+		}
+	}
+	if calls := server.provider.Calls("RestartInstance"); len(calls) != 1 || calls[0].Args[0] != "vm-1" {
+		t.Fatalf("expected only vm-1 to be restarted, got %+v", calls)
+	}
+
+	server.provider.ResetCalls()
+	server.provider.Fail("StopInstance", hetzner.ProviderError{Code: "unavailable", Message: "try later"})
+	summary = bulkInstanceActionResponse{}
+	if err := json.Unmarshal([]byte(server.do(http.MethodPost, "compute", "instances:stop", "", http.StatusAccepted)), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Accepted != 1 || summary.Failed != 2 || len(summary.Results) != 3 {
+		t.Fatalf("expected the already stopped vm-2 to succeed and the others to fail, got %+v", summary)
+	}
+	if calls := server.provider.Calls("StopInstance"); len(calls) != 2 {
+		t.Fatalf("expected every running instance to be tried despite failures, got %+v", calls)
+	}
+}
+
+func TestFakeProviderFailedActionReportsErrorState(t *testing.T) {
+	server := newFakeProviderServer(t)
+	ctx := context.Background()
+	instance := `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`
+	server.do(http.MethodPut, "compute", "instances/vm-1", instance, http.StatusCreated)
+
+	// Record what the operation reconciler does when the create action fails.
+	ref := "seca.compute/v1" + server.prefix + "/instances/vm-1"
+	operation := fmt.Sprintf("instance-upsert-vm-1-%d", time.Now().UnixNano())
+	if err := server.store.CreateOperation(ctx, state.OperationRecord{OperationID: operation, SecaRef: ref, ProviderActionID: "1", Phase: "accepted"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.store.UpdateOperationPhase(ctx, operation, "failed", "server_error: cannot create server"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.store.UpdateResourceBindingStatus(ctx, ref, state.BindingStatusError); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads refresh the binding, so the second GET shows the error survives them.
+	for range 2 {
+		var resource instanceResource
+		if err := json.Unmarshal([]byte(server.do(http.MethodGet, "compute", "instances/vm-1", "", http.StatusOK)), &resource); err != nil {
+			t.Fatal(err)
+		}
+		if resource.Status.State != "error" || len(resource.Status.Conditions) != 1 || resource.Status.Conditions[0].Message != "server_error: cannot create server" {
+			t.Fatalf("expected the failed action in the status, got %+v", resource.Status)
+		}
+	}
+
+	server.do(http.MethodPut, "compute", "instances/vm-1", instance, http.StatusOK)
+	var resource instanceResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "compute", "instances/vm-1", "", http.StatusOK)), &resource); err != nil {
+		t.Fatal(err)
+	}
+	if resource.Status.State == "error" || len(resource.Status.Conditions) != 0 {
+		t.Fatalf("expected a PUT to clear the error, got %+v", resource.Status)
+	}
+}
+
+func TestFakeProviderBlockStorageAttachDetach(t *testing.T) {
+	server := newFakeProviderServer(t)
+
+	server.do(http.MethodPut, "compute", "instances/vm-1", `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusCreated)
+
+	var attached attachBlockStorageResponse
+	body := server.do(http.MethodPost, "storage", "block-storages/data-1/attach", `{"instanceRef":{"resource":"instances/vm-1"}}`, http.StatusAccepted)
+	if err := json.Unmarshal([]byte(body), &attached); err != nil {
+		t.Fatal(err)
+	}
+	volume, _ := server.provider.GetBlockStorage(context.Background(), "data-1")
+	if volume == nil || volume.AttachedTo != "vm-1" {
+		t.Fatalf("expected data-1 attached to vm-1, got %+v", volume)
+	}
+	if attached.DevicePath == "" || attached.DevicePath != volume.DevicePath || attached.AttachedTo == nil || attached.AttachedTo.Resource != "instances/vm-1" {
+		t.Fatalf("expected the attach to report where data-1 shows up, got %s", body)
+	}
+	if calls := server.provider.Calls("AttachBlockStorage"); len(calls) != 1 || calls[0].Args[2] != false {
+		t.Fatalf("expected an attach without automount, got %+v", calls)
+	}
+	var status blockStorageResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "storage", "block-storages/data-1", "", http.StatusOK)), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status.DevicePath == nil || *status.Status.DevicePath != volume.DevicePath {
+		t.Fatalf("expected status.devicePath %q, got %+v", volume.DevicePath, status.Status)
+	}
+
+	server.do(http.MethodPut, "compute", "instances/vm-2", `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	server.do(http.MethodPost, "storage", "block-storages/data-1/attach", `{"instanceRef":{"resource":"instances/vm-2"}}`, http.StatusConflict)
+
+	server.do(http.MethodPost, "storage", "block-storages/data-1/detach", "", http.StatusAccepted)
+	volume, _ = server.provider.GetBlockStorage(context.Background(), "data-1")
+	if volume == nil || volume.AttachedTo != "" {
+		t.Fatalf("expected data-1 detached, got %+v", volume)
+	}
+	status = blockStorageResource{}
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "storage", "block-storages/data-1", "", http.StatusOK)), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status.AttachedTo != nil || status.Status.DevicePath != nil {
+		t.Fatalf("expected detach to clear the attachment, got %+v", status.Status)
+	}
+
+	server.provider.ResetCalls()
+	server.do(http.MethodPost, "storage", "block-storages/data-1/attach", `{"instanceRef":{"resource":"instances/vm-2"},"automount":true}`, http.StatusAccepted)
+	if calls := server.provider.Calls("AttachBlockStorage"); len(calls) != 1 || calls[0].Args[2] != true {
+		t.Fatalf("expected an attach with automount, got %+v", calls)
+	}
+}
+
+func TestFakeProviderLabelsRoundTrip(t *testing.T) {
+	server := newFakeProviderServer(t)
+	server.do(http.MethodPut, "compute", "instances/vm-1", `{"labels":{"team":"payments"},"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	server.do(http.MethodPut, "storage", "block-storages/data-1", `{"labels":{"team":"payments"},"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusCreated)
+	server.provider.SetInstanceLabel("vm-1", "seca.console", "edited")
+	server.provider.SetBlockStorageLabel("data-1", "seca.console", "edited")
+
+	for _, read := range []struct{ api, path string }{
+		{"compute", "instances/vm-1"},
+		{"compute", "instances"},
+		{"storage", "block-storages/data-1"},
+		{"storage", "block-storages"},
+	} {
+		if body := server.do(http.MethodGet, read.api, read.path, "", http.StatusOK); !strings.Contains(body, `"labels":{"team":"payments"}`) {
+			t.Fatalf("GET %s: expected only the user labels, got %s", read.path, body)
+		}
+		body := server.do(http.MethodGet, read.api, read.path+"?includeSystemLabels=true", "", http.StatusOK)
+		if !strings.Contains(body, `"seca.console":"edited"`) || !strings.Contains(body, `"team":"payments"`) {
+			t.Fatalf("GET %s: expected the provider-side labels with includeSystemLabels, got %s", read.path, body)
+		}
+	}
+}
+
+func TestFakeProviderListKeepsTheBoundNameOfARenamedInstance(t *testing.T) {
+	server := newFakeProviderServer(t)
+	server.do(http.MethodPut, "compute", "instances/vm-1", `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`, http.StatusCreated)
+	server.provider.RenameInstance("vm-1", "renamed-in-console")
+
+	var listed instanceIterator
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "compute", "instances", "", http.StatusOK)), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Items) != 1 || listed.Items[0].Metadata.Name != "vm-1" {
+		t.Fatalf("expected vm-1 under its bound name, got %+v", listed.Items)
+	}
+	tenant := strings.Split(server.prefix, "/")[2]
+	bindings, err := server.store.ListResourceBindings(context.Background(), tenant, "ws-1", "instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings) != 1 {
+		t.Fatalf("expected the listing to keep one instance binding, got %+v", bindings)
+	}
+}
+
+func TestFakeProviderBlockStorageSourceImageIsNotImplemented(t *testing.T) {
+	server := newFakeProviderServer(t)
+	body := `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"},"sourceImageRef":{"resource":"images/ubuntu-24.04"}}}`
+	for _, path := range []string{"block-storages/data-1", "block-storages/data-1?dryRun=true"} {
+		problem := server.do(http.MethodPut, "storage", path, body, http.StatusNotImplemented)
+		if !strings.Contains(problem, `"pointer":"/spec/sourceImageRef"`) {
+			t.Fatalf("%s: expected the problem to point at spec.sourceImageRef, got %s", path, problem)
+		}
+	}
+	for _, call := range []string{"ValidateBlockStorageCreate", "CreateOrUpdateBlockStorage"} {
+		if server.provider.Called(call) {
+			t.Fatalf("expected %s not to be called, got calls %+v", call, server.provider.Calls(""))
+		}
+	}
+	if volume, _ := server.provider.GetBlockStorage(context.Background(), "data-1"); volume != nil {
+		t.Fatalf("expected no volume, got %+v", volume)
+	}
+}
+
+func TestFakeProviderBlockStorageSKUStaysImmutableAcrossRestarts(t *testing.T) {
+	server := newFakeProviderServer(t)
+	tenant := strings.Split(server.prefix, "/")[2]
+	server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusCreated)
+	// A restart forgets the specs cached by the process.
+	runtimeResourceState.deleteBlockStorageSpec(blockStorageRef(tenant, "ws-1", "data-1"))
+
+	server.provider.ResetCalls()
+	body := server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/other"}}}`, http.StatusUnprocessableEntity)
+	if !strings.Contains(body, `"/spec/skuRef"`) || server.provider.Called("CreateOrUpdateBlockStorage") {
+		t.Fatalf("expected the sku change to be refused before the provider is called, got %s", body)
+	}
+	server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":20,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusOK)
+}
+
+func TestFakeProviderBlockStorageGrowsButDoesNotShrink(t *testing.T) {
+	server := newFakeProviderServer(t)
+
+	server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusCreated)
+	var grown blockStorageResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":20,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusOK)), &grown); err != nil {
+		t.Fatal(err)
+	}
+	if grown.Spec.SizeGB != 20 || grown.Status.SizeGB != 20 {
+		t.Fatalf("expected the PUT to report the grown size, got spec %d status %d", grown.Spec.SizeGB, grown.Status.SizeGB)
+	}
+	if volume, _ := server.provider.GetBlockStorage(context.Background(), "data-1"); volume == nil || volume.SizeGB != 20 {
+		t.Fatalf("expected data-1 resized to 20 GB, got %+v", volume)
+	}
+
+	server.provider.ResetCalls()
+	body := server.do(http.MethodPut, "storage", "block-storages/data-1", `{"spec":{"sizeGB":10,"skuRef":{"resource":"skus/hcloud-volume"}}}`, http.StatusBadRequest)
+	if !strings.Contains(body, "/spec/sizeGB") {
+		t.Fatalf("expected the shrink to point at spec.sizeGB, got %s", body)
+	}
+	if server.provider.Called("CreateOrUpdateBlockStorage") {
+		t.Fatal("expected the shrink to be rejected before reaching the provider")
+	}
+}
+
+func TestFakeProviderNetworkCreateSurfacesFailures(t *testing.T) {
+	server := newFakeProviderServer(t)
+
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)
+	calls := server.provider.Calls("CreateOrUpdateNetwork")
+	if len(calls) != 1 {
+		t.Fatalf("expected one network create, got %+v", server.provider.Calls(""))
+	}
+	if req, ok := calls[0].Args[0].(hetzner.NetworkCreateRequest); !ok || req.CIDR != "10.0.0.0/16" {
+		t.Fatalf("unexpected network create request %+v", calls[0].Args)
+	}
+
+	server.provider.Fail("CreateOrUpdateNetwork", hetzner.ProviderError{Code: "conflict", Message: "network limit reached"})
+	server.do(http.MethodPut, "network", "networks/net-2", `{"spec":{"cidr":{"ipv4":"10.1.0.0/16"}}}`, http.StatusConflict)
+}
+
+func TestFakeProviderNetworkTimestampsSurviveReads(t *testing.T) {
+	server := newFakeProviderServer(t)
+	var created networkResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)), &created); err != nil {
+		t.Fatal(err)
+	}
+	network, _ := server.provider.GetNetwork(context.Background(), "net-1")
+	if created.Metadata.CreatedAt != network.CreatedAt.UTC().Format(time.RFC3339) {
+		t.Fatalf("expected createdAt %s from the provider, got %s", network.CreatedAt, created.Metadata.CreatedAt)
+	}
+
+	var read networkResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "network", "networks/net-1", "", http.StatusOK)), &read); err != nil {
+		t.Fatal(err)
+	}
+	var listed networkIterator
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "network", "networks", "", http.StatusOK)), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Items) != 1 {
+		t.Fatalf("expected one network, got %+v", listed.Items)
+	}
+	for _, metadata := range []resourceMetadata{read.Metadata, listed.Items[0].Metadata} {
+		if metadata.CreatedAt != created.Metadata.CreatedAt || metadata.LastModifiedAt != created.Metadata.LastModifiedAt {
+			t.Fatalf("expected reads to keep createdAt %s and lastModifiedAt %s, got %+v", created.Metadata.CreatedAt, created.Metadata.LastModifiedAt, metadata)
+		}
+	}
+}
+
+func TestFakeProviderNetworkCIDRValidation(t *testing.T) {
+	server := newFakeProviderServer(t)
+
+	for _, cidr := range []string{"not-a-cidr", "8.8.0.0/16", "10.0.0.0/8", "10.0.0.0/26"} {
+		body := server.do(http.MethodPut, "network", "networks/net-bad", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"`+cidr+`"}}}`, http.StatusBadRequest)
+		if !strings.Contains(body, `"pointer":"/spec/cidr/ipv4"`) {
+			t.Fatalf("%s: expected a problem pointing at /spec/cidr/ipv4, got %s", cidr, body)
+		}
+	}
+	if server.provider.Called("CreateOrUpdateNetwork") {
+		t.Fatal("expected invalid ranges to be rejected before reaching the provider")
+	}
+
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)
+	body := server.do(http.MethodPut, "network", "networks/net-2", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"10.0.128.0/24"}}}`, http.StatusConflict)
+	if !strings.Contains(body, `"pointer":"/spec/cidr/ipv4"`) || !strings.Contains(body, "seca.network/v1"+server.prefix+"/networks/net-1") {
+		t.Fatalf("expected the overlap to name net-1, got %s", body)
+	}
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusOK)
+	server.do(http.MethodPut, "network", "networks/net-2", `{"spec":{"skuRef":{"resource":"skus/hcloud-network"},"cidr":{"ipv4":"10.1.0.0/16"}}}`, http.StatusCreated)
+}
+
+func TestFakeProviderSubnetRollsBackWhenTheBindingIsNotSaved(t *testing.T) {
+	server := newFakeProviderServer(t)
+	var failUpserts atomic.Bool
+	server.store.InjectQueryFaults(func(_ context.Context, query string) error {
+		if query == "UpsertResourceBinding" && failUpserts.Load() {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)
+	failUpserts.Store(true)
+	server.do(http.MethodPut, "network", "networks/net-1/subnets/sub-1", `{"spec":{"cidr":{"ipv4":"10.0.1.0/24"},"zone":"fsn1"}}`, http.StatusInternalServerError)
+	if !server.provider.Called("AddSubnet") || !server.provider.Called("RemoveSubnet") {
+		t.Fatalf("expected the added subnet to be removed again, got calls %+v", server.provider.Calls(""))
+	}
+	if subnets := server.provider.Subnets("net-1"); slices.Contains(subnets, "10.0.1.0/24") {
+		t.Fatalf("expected no orphaned subnet, got %v", subnets)
+	}
+
+	failUpserts.Store(false)
+	server.do(http.MethodPut, "network", "networks/net-1/subnets/sub-1", `{"spec":{"cidr":{"ipv4":"10.0.1.0/24"},"zone":"fsn1"}}`, http.StatusCreated)
+	if subnets := server.provider.Subnets("net-1"); !slices.Contains(subnets, "10.0.1.0/24") {
+		t.Fatalf("expected the subnet after a successful PUT, got %v", subnets)
+	}
+}
+
+func TestFakeProviderConditionalSubnetPutLosingTheRaceLeavesTheProviderAlone(t *testing.T) {
+	server := newFakeProviderServer(t)
+	tenant := strings.Split(server.prefix, "/")[2]
+	ref := subnetRefKey(tenant, "ws-1", "net-1", "sub-1")
+	var race atomic.Bool
+	server.store.InjectQueryFaults(func(ctx context.Context, query string) error {
+		if query != "UpsertResourceBinding" || !race.CompareAndSwap(true, false) {
+			return nil
+		}
+		// Another writer saves the subnet after the handler checked the
+		// version but before it claims it.
+		binding, err := server.store.GetResourceBinding(ctx, ref)
+		if err != nil || binding == nil {
+			return fmt.Errorf("load subnet binding: %v", err)
+		}
+		_, err = server.store.UpsertResourceBindingIfVersion(ctx, *binding, 0)
+		return err
+	})
+
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)
+	var created struct {
+		Metadata struct {
+			ResourceVersion int64 `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	body := server.do(http.MethodPut, "network", "networks/net-1/subnets/sub-1", `{"spec":{"cidr":{"ipv4":"10.0.1.0/24"},"zone":"fsn1"}}`, http.StatusCreated)
+	if err := json.Unmarshal([]byte(body), &created); err != nil || created.Metadata.ResourceVersion == 0 {
+		t.Fatalf("expected a resourceVersion, got %s (err %v)", body, err)
+	}
+
+	server.provider.ResetCalls()
+	race.Store(true)
+	update := fmt.Sprintf(`{"metadata":{"resourceVersion":%d},"spec":{"cidr":{"ipv4":"10.0.1.0/24"},"zone":"fsn1"}}`, created.Metadata.ResourceVersion)
+	server.do(http.MethodPut, "network", "networks/net-1/subnets/sub-1", update, http.StatusConflict)
+	if server.provider.Called("AddSubnet") {
+		t.Fatalf("expected the provider to be left alone, got calls %+v", server.provider.Calls(""))
+	}
+}
+
+func TestFakeProviderSecurityGroupRulesUseAPICasing(t *testing.T) {
+	server := newFakeProviderServer(t)
+	body := `{"spec":{"rules":[{"direction":"Ingress","protocol":"TCP","ports":{"from":443,"to":444},"version":"ipv4"}]}}`
+	for _, raw := range []string{
+		server.do(http.MethodPut, "network", "security-groups/web", body, http.StatusCreated),
+		server.do(http.MethodGet, "network", "security-groups/web", "", http.StatusOK),
+	} {
+		var resource securityGroupResource
+		if err := json.Unmarshal([]byte(raw), &resource); err != nil {
+			t.Fatal(err)
+		}
+		if len(resource.Spec.Rules) != 1 {
+			t.Fatalf("expected one rule, got %s", raw)
+		}
+		rule := resource.Spec.Rules[0]
+		if rule.Direction != "ingress" || rule.Protocol != "tcp" || rule.Version != "IPv4" {
+			t.Fatalf("expected ingress/tcp/IPv4, got %+v", rule)
+		}
+	}
+}
+
+func TestFakeProviderPublicIPLifecycle(t *testing.T) {
+	server := newFakeProviderServer(t)
+	var created publicIPResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodPut, "network", "public-ips/ip-1", `{"spec":{"version":"IPv4"}}`, http.StatusCreated)), &created); err != nil {
+		t.Fatal(err)
+	}
+	allocated, _ := server.provider.GetPublicIP(context.Background(), "ip-1")
+	if allocated == nil || created.Status.Address == nil || *created.Status.Address != allocated.Address {
+		t.Fatalf("expected the allocated address in the status, got %+v and %+v", created.Status, allocated)
+	}
+	if created.Metadata.Region != "fsn1" || len(created.Status.Conditions) != 0 {
+		t.Fatalf("expected the address in the workspace region without conditions, got %q %+v", created.Metadata.Region, created.Status.Conditions)
+	}
+	var read publicIPResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodGet, "network", "public-ips/ip-1", "", http.StatusOK)), &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Status.Address == nil || *read.Status.Address != allocated.Address {
+		t.Fatalf("expected GET to report %s, got %+v", allocated.Address, read.Status)
+	}
+
+	server.do(http.MethodDelete, "network", "public-ips/ip-1", "", http.StatusAccepted)
+	if ip, _ := server.provider.GetPublicIP(context.Background(), "ip-1"); ip != nil {
+		t.Fatalf("expected the floating ip to be released, got %+v", ip)
+	}
+	server.do(http.MethodDelete, "network", "public-ips/ip-1", "", http.StatusNotFound)
+	server.do(http.MethodGet, "network", "public-ips/ip-1", "", http.StatusNotFound)
+}
+
+func TestFakeProviderPublicIPReportsRegionFallback(t *testing.T) {
+	server := newFakeProviderServer(t)
+	tenant := strings.Split(server.prefix, "/")[2]
+	if _, err := server.store.UpsertWorkspace(context.Background(), state.WorkspaceResource{
+		Tenant: tenant,
+		Name:   "ws-1",
+		Region: "eu-west-1",
+		Status: map[string]any{"state": "active"},
+	}); err != nil {
+		t.Fatalf("upsert workspace: %v", err)
+	}
+
+	for _, body := range []string{
+		server.do(http.MethodPut, "network", "public-ips/ip-1", `{"spec":{"version":"IPv4"}}`, http.StatusCreated),
+		server.do(http.MethodGet, "network", "public-ips/ip-1", "", http.StatusOK),
+	} {
+		var resource publicIPResource
+		if err := json.Unmarshal([]byte(body), &resource); err != nil {
+			t.Fatal(err)
+		}
+		if resource.Metadata.Region != "fsn1" || len(resource.Status.Conditions) != 1 || resource.Status.Conditions[0].Type != "RegionFallback" || !strings.Contains(resource.Status.Conditions[0].Message, `"eu-west-1"`) {
+			t.Fatalf("expected the fallback to fsn1 to be reported, got %s", body)
+		}
+	}
+}
+
+func TestFakeProviderNICPublicIPRefsAssignFloatingIP(t *testing.T) {
+	server := newFakeProviderServer(t)
+	ctx := context.Background()
+	instance := `{"spec":{"skuRef":{"resource":"skus/cx22"},"bootVolume":{"deviceRef":{"resource":"images/ubuntu-24.04"}}}}`
+	server.do(http.MethodPut, "compute", "instances/vm-1", instance, http.StatusCreated)
+	server.do(http.MethodPut, "compute", "instances/vm-2", instance, http.StatusCreated)
+	server.do(http.MethodPut, "network", "networks/net-1", `{"spec":{"cidr":{"ipv4":"10.0.0.0/16"}}}`, http.StatusCreated)
+	server.do(http.MethodPut, "network", "networks/net-1/subnets/sub-1", `{"spec":{"cidr":{"ipv4":"10.0.1.0/24"},"zone":"fsn1-dc14"}}`, http.StatusCreated)
+	server.do(http.MethodPut, "network", "public-ips/ip-1", `{"spec":{"version":"IPv4"}}`, http.StatusCreated)
+	vm1, _ := server.provider.GetInstance(ctx, "vm-1")
+	vm2, _ := server.provider.GetInstance(ctx, "vm-2")
+
+	server.do(http.MethodPut, "network", "nics/nic-1", `{"spec":{"subnetRef":{"resource":"subnets/sub-1"},"instanceRef":{"resource":"instances/vm-1"},"publicIpRefs":[{"resource":"public-ips/ip-1"}]}}`, http.StatusCreated)
+	if ip, _ := server.provider.GetPublicIP(ctx, "ip-1"); ip == nil || ip.ServerID != vm1.ID {
+		t.Fatalf("expected ip-1 assigned to vm-1, got %+v", ip)
+	}
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/network/v1"+server.prefix+"/public-ips/ip-1", nil))
+	if !strings.Contains(rec.Body.String(), `"assignedTo":"seca.compute/v1`+server.prefix+`/instances/vm-1"`) {
+		t.Fatalf("expected assignedTo vm-1, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/network/v1"+server.prefix+"/nics/nic-2", strings.NewReader(`{"spec":{"subnetRef":{"resource":"subnets/sub-1"},"instanceRef":{"resource":"instances/vm-2"},"publicIpRefs":[{"resource":"public-ips/ip-1"}]}}`)))
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "instances/vm-1") {
+		t.Fatalf("expected 409 naming vm-1, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls := server.provider.Calls("AssignPublicIP"); len(calls) != 1 {
+		t.Fatalf("expected no assign for the conflicting NIC, got %+v", calls)
+	}
+
+	server.do(http.MethodPut, "network", "nics/nic-1", `{"spec":{"subnetRef":{"resource":"subnets/sub-1"},"instanceRef":{"resource":"instances/vm-2"},"publicIpRefs":[{"resource":"public-ips/ip-1"}]}}`, http.StatusOK)
+	if ip, _ := server.provider.GetPublicIP(ctx, "ip-1"); ip == nil || ip.ServerID != vm2.ID {
+		t.Fatalf("expected ip-1 moved to vm-2, got %+v", ip)
+	}
+
+	server.do(http.MethodDelete, "network", "nics/nic-1", "", http.StatusAccepted)
+	if ip, _ := server.provider.GetPublicIP(ctx, "ip-1"); ip == nil || ip.ServerID != 0 {
+		t.Fatalf("expected ip-1 unassigned after the NIC delete, got %+v", ip)
+	}
+}
+
+func TestFakeProviderPlacementGroupSpreadsInstances(t *testing.T) {
+	server := newFakeProviderServer(t)
+	ctx := context.Background()
+
+	server.do(http.MethodPut, "compute", "instances/web-1", `{"spec":{"skuRef":{"resource":"skus/cx22"},"placementGroupRef":{"resource":"placement-groups/ha"}}}`, http.StatusCreated)
+	group, _ := server.provider.GetPlacementGroup(ctx, "ha")
+	if group == nil || group.Labels["seca.workspace"] != "ws-1" {
+		t.Fatalf("expected the first reference to create a labelled group, got %+v", group)
+	}
+	server.do(http.MethodGet, "compute", "placement-groups/ha", "", http.StatusOK)
+
+	server.do(http.MethodPut, "compute", "instances/web-2", `{"spec":{"skuRef":{"resource":"skus/cx22"}}}`, http.StatusCreated)
+	server.do(http.MethodPut, "compute", "instances/web-2", `{"spec":{"skuRef":{"resource":"skus/cx22"},"placementGroupRef":{"resource":"placement-groups/ha"}}}`, http.StatusOK)
+	if instance, _ := server.provider.GetInstance(ctx, "web-2"); instance == nil || instance.PlacementGroup != "ha" {
+		t.Fatalf("expected web-2 moved into ha, got %+v", instance)
+	}
+	if group, _ = server.provider.GetPlacementGroup(ctx, "ha"); len(group.ServerIDs) != 2 {
+		t.Fatalf("expected two servers in ha, got %+v", group)
+	}
+
+	server.do(http.MethodDelete, "compute", "placement-groups/ha", "", http.StatusConflict)
+	server.do(http.MethodDelete, "compute", "instances/web-1", "", http.StatusAccepted)
+	server.do(http.MethodPut, "compute", "instances/web-2", `{"spec":{"skuRef":{"resource":"skus/cx22"}}}`, http.StatusOK)
+	server.do(http.MethodDelete, "compute", "placement-groups/ha", "", http.StatusAccepted)
+	if group, _ := server.provider.GetPlacementGroup(ctx, "ha"); group != nil {
+		t.Fatalf("expected ha deleted, got %+v", group)
+	}
+}
@@ -0,0 +1,633 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/faults"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+// Fault injection (SECA_FAULT_INJECTION=true) wraps every provider interface
+// so each method consults the injector before delegating. When the flag is
+// off the wrappers are not installed at all.
+
+type faultRuleRequest struct {
+	Layer       string  `json:"layer"`
+	Name        string  `json:"name"`
+	Probability float64 `json:"probability"`
+	Error       string  `json:"error,omitempty"`
+	Latency     string  `json:"latency,omitempty"`
+	TTL         string  `json:"ttl,omitempty"`
+}
+
+type faultRuleResponse struct {
+	ID          string  `json:"id"`
+	Layer       string  `json:"layer"`
+	Name        string  `json:"name"`
+	Probability float64 `json:"probability"`
+	Error       string  `json:"error,omitempty"`
+	Latency     string  `json:"latency,omitempty"`
+	ExpiresAt   string  `json:"expiresAt"`
+}
+
+type faultRuleList struct {
+	Items []faultRuleResponse `json:"items"`
+}
+
+func toFaultRuleResponse(rule faults.Rule) faultRuleResponse {
+	out := faultRuleResponse{
+		ID:          rule.ID,
+		Layer:       rule.Layer,
+		Name:        rule.Name,
+		Probability: rule.Probability,
+		Error:       rule.Error,
+		ExpiresAt:   rule.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	if rule.Latency > 0 {
+		out.Latency = rule.Latency.String()
+	}
+	return out
+}
+
+func adminListFaultRules(injector *faults.Injector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules := injector.Rules()
+		items := make([]faultRuleResponse, 0, len(rules))
+		for _, rule := range rules {
+			items = append(items, toFaultRuleResponse(rule))
+		}
+		respondJSON(w, http.StatusOK, faultRuleList{Items: items})
+	}
+}
+
+func adminAddFaultRule(injector *faults.Injector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req faultRuleRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		rule := faults.Rule{
+			Layer:       strings.TrimSpace(req.Layer),
+			Name:        strings.TrimSpace(req.Name),
+			Probability: req.Probability,
+			Error:       strings.TrimSpace(req.Error),
+		}
+		latency, latencyErr := parseOptionalDuration(req.Latency)
+		ttl, ttlErr := parseOptionalDuration(req.TTL)
+		if latencyErr != nil || ttlErr != nil {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "latency and ttl must be durations such as 500ms or 5m", r.URL.Path)
+			return
+		}
+		rule.Latency = latency
+		added, err := injector.Add(rule, ttl)
+		if err != nil {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", err.Error(), r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusCreated, toFaultRuleResponse(added))
+	}
+}
+
+func parseOptionalDuration(raw string) (time.Duration, error) {
+	if strings.TrimSpace(raw) == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(strings.TrimSpace(raw))
+}
+
+func adminDeleteFaultRule(injector *faults.Injector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !injector.Remove(r.PathValue("id")) {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "fault rule not found", r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type faultingRegionProvider struct {
+	next   RegionProvider
+	faults *faults.Injector
+}
+
+func (p faultingRegionProvider) ListRegions(ctx context.Context) ([]hetzner.Region, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListRegions"); err != nil {
+		return nil, err
+	}
+	return p.next.ListRegions(ctx)
+}
+
+func (p faultingRegionProvider) GetRegion(ctx context.Context, name string) (*hetzner.Region, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetRegion"); err != nil {
+		return nil, err
+	}
+	return p.next.GetRegion(ctx, name)
+}
+
+type faultingCatalogProvider struct {
+	next   CatalogProvider
+	faults *faults.Injector
+}
+
+func (p faultingCatalogProvider) ListComputeSKUs(ctx context.Context) ([]hetzner.ComputeSKU, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListComputeSKUs"); err != nil {
+		return nil, err
+	}
+	return p.next.ListComputeSKUs(ctx)
+}
+
+func (p faultingCatalogProvider) GetComputeSKU(ctx context.Context, name string) (*hetzner.ComputeSKU, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetComputeSKU"); err != nil {
+		return nil, err
+	}
+	return p.next.GetComputeSKU(ctx, name)
+}
+
+func (p faultingCatalogProvider) ListCatalogImages(ctx context.Context) ([]hetzner.CatalogImage, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListCatalogImages"); err != nil {
+		return nil, err
+	}
+	return p.next.ListCatalogImages(ctx)
+}
+
+func (p faultingCatalogProvider) GetCatalogImage(ctx context.Context, name, architecture string) (*hetzner.CatalogImage, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetCatalogImage"); err != nil {
+		return nil, err
+	}
+	return p.next.GetCatalogImage(ctx, name, architecture)
+}
+
+func (p faultingCatalogProvider) GetVolumePricing(ctx context.Context) (*hetzner.VolumePricing, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetVolumePricing"); err != nil {
+		return nil, err
+	}
+	return p.next.GetVolumePricing(ctx)
+}
+
+func (p faultingCatalogProvider) VolumeCapacityByLocation(ctx context.Context) ([]hetzner.VolumeCapacity, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "VolumeCapacityByLocation"); err != nil {
+		return nil, err
+	}
+	return p.next.VolumeCapacityByLocation(ctx)
+}
+
+type faultingComputeStorageProvider struct {
+	next   ComputeStorageProvider
+	faults *faults.Injector
+}
+
+func (p faultingComputeStorageProvider) ListInstances(ctx context.Context) ([]hetzner.Instance, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListInstances"); err != nil {
+		return nil, err
+	}
+	return p.next.ListInstances(ctx)
+}
+
+func (p faultingComputeStorageProvider) ListInstancesByLabels(ctx context.Context, labels map[string]string) ([]hetzner.Instance, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListInstancesByLabels"); err != nil {
+		return nil, err
+	}
+	return p.next.ListInstancesByLabels(ctx, labels)
+}
+
+func (p faultingComputeStorageProvider) GetInstance(ctx context.Context, name string) (*hetzner.Instance, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetInstance"); err != nil {
+		return nil, err
+	}
+	return p.next.GetInstance(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) CreateOrUpdateInstance(ctx context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdateInstance"); err != nil {
+		return nil, false, "", err
+	}
+	return p.next.CreateOrUpdateInstance(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) ValidateInstanceCreate(ctx context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ValidateInstanceCreate"); err != nil {
+		return nil, false, err
+	}
+	return p.next.ValidateInstanceCreate(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) DeleteInstance(ctx context.Context, name string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteInstance"); err != nil {
+		return false, "", err
+	}
+	return p.next.DeleteInstance(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) WaitForAction(ctx context.Context, id int64) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "WaitForAction"); err != nil {
+		return err
+	}
+	return p.next.WaitForAction(ctx, id)
+}
+
+func (p faultingComputeStorageProvider) StartInstance(ctx context.Context, name string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "StartInstance"); err != nil {
+		return false, "", err
+	}
+	return p.next.StartInstance(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) StopInstance(ctx context.Context, name string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "StopInstance"); err != nil {
+		return false, "", err
+	}
+	return p.next.StopInstance(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) RestartInstance(ctx context.Context, name string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "RestartInstance"); err != nil {
+		return false, "", err
+	}
+	return p.next.RestartInstance(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) SetInstanceBackups(ctx context.Context, name string, enabled bool) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "SetInstanceBackups"); err != nil {
+		return false, "", err
+	}
+	return p.next.SetInstanceBackups(ctx, name, enabled)
+}
+
+func (p faultingComputeStorageProvider) ServerTypeForRegion(ctx context.Context, preferred, region, fallbackArchitecture string) (string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ServerTypeForRegion"); err != nil {
+		return "", err
+	}
+	return p.next.ServerTypeForRegion(ctx, preferred, region, fallbackArchitecture)
+}
+
+func (p faultingComputeStorageProvider) AttachInstanceToNetwork(ctx context.Context, instanceName, networkName string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "AttachInstanceToNetwork"); err != nil {
+		return false, "", err
+	}
+	return p.next.AttachInstanceToNetwork(ctx, instanceName, networkName)
+}
+
+func (p faultingComputeStorageProvider) AttachInstanceToNetworkWithIP(ctx context.Context, instanceName, networkName, ip string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "AttachInstanceToNetworkWithIP"); err != nil {
+		return false, "", err
+	}
+	return p.next.AttachInstanceToNetworkWithIP(ctx, instanceName, networkName, ip)
+}
+
+func (p faultingComputeStorageProvider) DetachInstanceFromNetwork(ctx context.Context, instanceName, networkName string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DetachInstanceFromNetwork"); err != nil {
+		return false, err
+	}
+	return p.next.DetachInstanceFromNetwork(ctx, instanceName, networkName)
+}
+
+func (p faultingComputeStorageProvider) SyncInstanceNetworks(ctx context.Context, instanceName string, networkNames []string) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "SyncInstanceNetworks"); err != nil {
+		return err
+	}
+	return p.next.SyncInstanceNetworks(ctx, instanceName, networkNames)
+}
+
+func (p faultingComputeStorageProvider) GetInstancePrivateIPv4(ctx context.Context, instanceName, networkName string) (string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetInstancePrivateIPv4"); err != nil {
+		return "", err
+	}
+	return p.next.GetInstancePrivateIPv4(ctx, instanceName, networkName)
+}
+
+func (p faultingComputeStorageProvider) SyncInstanceSecurityGroups(ctx context.Context, instanceName string, securityGroupNames []string, managed func(hetzner.SecurityGroup) bool) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "SyncInstanceSecurityGroups"); err != nil {
+		return err
+	}
+	return p.next.SyncInstanceSecurityGroups(ctx, instanceName, securityGroupNames, managed)
+}
+
+func (p faultingComputeStorageProvider) GetInstanceMetrics(ctx context.Context, req hetzner.InstanceMetricsRequest) (*hetzner.InstanceMetrics, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetInstanceMetrics"); err != nil {
+		return nil, err
+	}
+	return p.next.GetInstanceMetrics(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) ListPlacementGroups(ctx context.Context) ([]hetzner.PlacementGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListPlacementGroups"); err != nil {
+		return nil, err
+	}
+	return p.next.ListPlacementGroups(ctx)
+}
+
+func (p faultingComputeStorageProvider) GetPlacementGroup(ctx context.Context, name string) (*hetzner.PlacementGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetPlacementGroup"); err != nil {
+		return nil, err
+	}
+	return p.next.GetPlacementGroup(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) CreateOrUpdatePlacementGroup(ctx context.Context, req hetzner.PlacementGroupCreateRequest) (*hetzner.PlacementGroup, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdatePlacementGroup"); err != nil {
+		return nil, false, err
+	}
+	return p.next.CreateOrUpdatePlacementGroup(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) DeletePlacementGroup(ctx context.Context, name string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeletePlacementGroup"); err != nil {
+		return false, err
+	}
+	return p.next.DeletePlacementGroup(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) ListBlockStorages(ctx context.Context) ([]hetzner.BlockStorage, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListBlockStorages"); err != nil {
+		return nil, err
+	}
+	return p.next.ListBlockStorages(ctx)
+}
+
+func (p faultingComputeStorageProvider) ListBlockStoragesByLabels(ctx context.Context, labels map[string]string) ([]hetzner.BlockStorage, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListBlockStoragesByLabels"); err != nil {
+		return nil, err
+	}
+	return p.next.ListBlockStoragesByLabels(ctx, labels)
+}
+
+func (p faultingComputeStorageProvider) GetBlockStorage(ctx context.Context, name string) (*hetzner.BlockStorage, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetBlockStorage"); err != nil {
+		return nil, err
+	}
+	return p.next.GetBlockStorage(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) CreateOrUpdateBlockStorage(ctx context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdateBlockStorage"); err != nil {
+		return nil, false, "", err
+	}
+	return p.next.CreateOrUpdateBlockStorage(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) ValidateBlockStorageCreate(ctx context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ValidateBlockStorageCreate"); err != nil {
+		return nil, false, err
+	}
+	return p.next.ValidateBlockStorageCreate(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) DeleteBlockStorage(ctx context.Context, name string, force bool) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteBlockStorage"); err != nil {
+		return false, err
+	}
+	return p.next.DeleteBlockStorage(ctx, name, force)
+}
+
+func (p faultingComputeStorageProvider) AttachBlockStorage(ctx context.Context, name, instanceName string, automount bool) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "AttachBlockStorage"); err != nil {
+		return false, "", err
+	}
+	return p.next.AttachBlockStorage(ctx, name, instanceName, automount)
+}
+
+func (p faultingComputeStorageProvider) DetachBlockStorage(ctx context.Context, name string) (bool, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DetachBlockStorage"); err != nil {
+		return false, "", err
+	}
+	return p.next.DetachBlockStorage(ctx, name)
+}
+
+func (p faultingComputeStorageProvider) CreateImageSnapshot(ctx context.Context, req hetzner.ImageSnapshotCreateRequest) (*hetzner.ImageSnapshot, string, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateImageSnapshot"); err != nil {
+		return nil, "", err
+	}
+	return p.next.CreateImageSnapshot(ctx, req)
+}
+
+func (p faultingComputeStorageProvider) FindImageSnapshot(ctx context.Context, labels map[string]string) (*hetzner.ImageSnapshot, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "FindImageSnapshot"); err != nil {
+		return nil, err
+	}
+	return p.next.FindImageSnapshot(ctx, labels)
+}
+
+func (p faultingComputeStorageProvider) DeleteImageSnapshot(ctx context.Context, id int64) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteImageSnapshot"); err != nil {
+		return false, err
+	}
+	return p.next.DeleteImageSnapshot(ctx, id)
+}
+
+type faultingNetworkProvider struct {
+	next   NetworkProvider
+	faults *faults.Injector
+}
+
+func (p faultingNetworkProvider) ListNetworks(ctx context.Context) ([]hetzner.Network, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListNetworks"); err != nil {
+		return nil, err
+	}
+	return p.next.ListNetworks(ctx)
+}
+
+func (p faultingNetworkProvider) ListNetworksByLabels(ctx context.Context, labels map[string]string) ([]hetzner.Network, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListNetworksByLabels"); err != nil {
+		return nil, err
+	}
+	return p.next.ListNetworksByLabels(ctx, labels)
+}
+
+func (p faultingNetworkProvider) GetNetwork(ctx context.Context, name string) (*hetzner.Network, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetNetwork"); err != nil {
+		return nil, err
+	}
+	return p.next.GetNetwork(ctx, name)
+}
+
+func (p faultingNetworkProvider) CreateOrUpdateNetwork(ctx context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdateNetwork"); err != nil {
+		return nil, false, err
+	}
+	return p.next.CreateOrUpdateNetwork(ctx, req)
+}
+
+func (p faultingNetworkProvider) ValidateNetworkCreate(ctx context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ValidateNetworkCreate"); err != nil {
+		return nil, false, err
+	}
+	return p.next.ValidateNetworkCreate(ctx, req)
+}
+
+func (p faultingNetworkProvider) DeleteNetwork(ctx context.Context, name string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteNetwork"); err != nil {
+		return false, err
+	}
+	return p.next.DeleteNetwork(ctx, name)
+}
+
+func (p faultingNetworkProvider) UpsertNetworkRoute(ctx context.Context, networkName, destinationCIDR, gatewayIP string) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "UpsertNetworkRoute"); err != nil {
+		return err
+	}
+	return p.next.UpsertNetworkRoute(ctx, networkName, destinationCIDR, gatewayIP)
+}
+
+func (p faultingNetworkProvider) DeleteNetworkRoute(ctx context.Context, networkName, destinationCIDR string) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteNetworkRoute"); err != nil {
+		return err
+	}
+	return p.next.DeleteNetworkRoute(ctx, networkName, destinationCIDR)
+}
+
+func (p faultingNetworkProvider) AddSubnet(ctx context.Context, req hetzner.NetworkSubnetRequest) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "AddSubnet"); err != nil {
+		return false, err
+	}
+	return p.next.AddSubnet(ctx, req)
+}
+
+func (p faultingNetworkProvider) ValidateSubnetCreate(ctx context.Context, req hetzner.NetworkSubnetRequest) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ValidateSubnetCreate"); err != nil {
+		return err
+	}
+	return p.next.ValidateSubnetCreate(ctx, req)
+}
+
+func (p faultingNetworkProvider) RemoveSubnet(ctx context.Context, networkName, cidr string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "RemoveSubnet"); err != nil {
+		return false, err
+	}
+	return p.next.RemoveSubnet(ctx, networkName, cidr)
+}
+
+func (p faultingNetworkProvider) ListSecurityGroups(ctx context.Context) ([]hetzner.SecurityGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListSecurityGroups"); err != nil {
+		return nil, err
+	}
+	return p.next.ListSecurityGroups(ctx)
+}
+
+func (p faultingNetworkProvider) ListSecurityGroupsByLabels(ctx context.Context, labels map[string]string) ([]hetzner.SecurityGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListSecurityGroupsByLabels"); err != nil {
+		return nil, err
+	}
+	return p.next.ListSecurityGroupsByLabels(ctx, labels)
+}
+
+func (p faultingNetworkProvider) GetSecurityGroup(ctx context.Context, name string) (*hetzner.SecurityGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetSecurityGroup"); err != nil {
+		return nil, err
+	}
+	return p.next.GetSecurityGroup(ctx, name)
+}
+
+func (p faultingNetworkProvider) GetSecurityGroupByID(ctx context.Context, id int64) (*hetzner.SecurityGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetSecurityGroupByID"); err != nil {
+		return nil, err
+	}
+	return p.next.GetSecurityGroupByID(ctx, id)
+}
+
+func (p faultingNetworkProvider) ManageSecurityGroup(ctx context.Context, id int64, name string, labels map[string]string) (*hetzner.SecurityGroup, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ManageSecurityGroup"); err != nil {
+		return nil, err
+	}
+	return p.next.ManageSecurityGroup(ctx, id, name, labels)
+}
+
+func (p faultingNetworkProvider) CreateOrUpdateSecurityGroup(ctx context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdateSecurityGroup"); err != nil {
+		return nil, false, err
+	}
+	return p.next.CreateOrUpdateSecurityGroup(ctx, req)
+}
+
+func (p faultingNetworkProvider) ValidateSecurityGroupCreate(ctx context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ValidateSecurityGroupCreate"); err != nil {
+		return nil, false, err
+	}
+	return p.next.ValidateSecurityGroupCreate(ctx, req)
+}
+
+func (p faultingNetworkProvider) DeleteSecurityGroup(ctx context.Context, name string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteSecurityGroup"); err != nil {
+		return false, err
+	}
+	return p.next.DeleteSecurityGroup(ctx, name)
+}
+
+func (p faultingNetworkProvider) ListPublicIPs(ctx context.Context) ([]hetzner.PublicIP, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListPublicIPs"); err != nil {
+		return nil, err
+	}
+	return p.next.ListPublicIPs(ctx)
+}
+
+func (p faultingNetworkProvider) ListPublicIPsByLabels(ctx context.Context, labels map[string]string) ([]hetzner.PublicIP, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListPublicIPsByLabels"); err != nil {
+		return nil, err
+	}
+	return p.next.ListPublicIPsByLabels(ctx, labels)
+}
+
+func (p faultingNetworkProvider) GetPublicIP(ctx context.Context, name string) (*hetzner.PublicIP, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetPublicIP"); err != nil {
+		return nil, err
+	}
+	return p.next.GetPublicIP(ctx, name)
+}
+
+func (p faultingNetworkProvider) CreateOrUpdatePublicIP(ctx context.Context, req hetzner.PublicIPCreateRequest) (*hetzner.PublicIP, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdatePublicIP"); err != nil {
+		return nil, false, err
+	}
+	return p.next.CreateOrUpdatePublicIP(ctx, req)
+}
+
+func (p faultingNetworkProvider) DeletePublicIP(ctx context.Context, name string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeletePublicIP"); err != nil {
+		return false, err
+	}
+	return p.next.DeletePublicIP(ctx, name)
+}
+
+func (p faultingNetworkProvider) AssignPublicIP(ctx context.Context, name, instanceName string) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "AssignPublicIP"); err != nil {
+		return err
+	}
+	return p.next.AssignPublicIP(ctx, name, instanceName)
+}
+
+func (p faultingNetworkProvider) UnassignPublicIP(ctx context.Context, name string) error {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "UnassignPublicIP"); err != nil {
+		return err
+	}
+	return p.next.UnassignPublicIP(ctx, name)
+}
+
+func (p faultingNetworkProvider) ListLoadBalancers(ctx context.Context) ([]hetzner.LoadBalancer, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "ListLoadBalancers"); err != nil {
+		return nil, err
+	}
+	return p.next.ListLoadBalancers(ctx)
+}
+
+func (p faultingNetworkProvider) GetLoadBalancer(ctx context.Context, name string) (*hetzner.LoadBalancer, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "GetLoadBalancer"); err != nil {
+		return nil, err
+	}
+	return p.next.GetLoadBalancer(ctx, name)
+}
+
+func (p faultingNetworkProvider) CreateOrUpdateLoadBalancer(ctx context.Context, req hetzner.LoadBalancerCreateRequest) (*hetzner.LoadBalancer, bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "CreateOrUpdateLoadBalancer"); err != nil {
+		return nil, false, err
+	}
+	return p.next.CreateOrUpdateLoadBalancer(ctx, req)
+}
+
+func (p faultingNetworkProvider) DeleteLoadBalancer(ctx context.Context, name string) (bool, error) {
+	if err := p.faults.Inject(ctx, faults.LayerProvider, "DeleteLoadBalancer"); err != nil {
+		return false, err
+	}
+	return p.next.DeleteLoadBalancer(ctx, name)
+}
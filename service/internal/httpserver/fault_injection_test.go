@@ -0,0 +1,68 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/faults"
+)
+
+func TestInjectedLockedStartInstanceMapsToConflictUntilCleared(t *testing.T) {
+	t.Parallel()
+
+	injector := faults.NewInjector()
+	provider := faultingComputeStorageProvider{next: &fakeComputeProvider{}, faults: injector}
+
+	admin := http.NewServeMux()
+	admin.HandleFunc("POST /admin/v1/fault-rules", adminAddFaultRule(injector))
+	admin.HandleFunc("DELETE /admin/v1/fault-rules/{id}", adminDeleteFaultRule(injector))
+	add := httptest.NewRecorder()
+	admin.ServeHTTP(add, jsonRequest(http.MethodPost, "/admin/v1/fault-rules", `{"layer":"provider","name":"StartInstance","probability":1,"error":"locked","ttl":"1m"}`))
+	if add.Code != http.StatusCreated {
+		t.Fatalf("expected 201 when adding a rule, got %d: %s", add.Code, add.Body.String())
+	}
+	var rule faultRuleResponse
+	if err := json.Unmarshal(add.Body.Bytes(), &rule); err != nil || rule.ID == "" {
+		t.Fatalf("expected rule with id, got %s (%v)", add.Body.String(), err)
+	}
+
+	path := "/compute/v1/tenants/t1/workspaces/ws1/instances/vm1/start"
+	_, _, err := provider.StartInstance(t.Context(), "vm1")
+	rec := httptest.NewRecorder()
+	respondFromError(rec, err, path)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected injected locked error to map to 409, got %d", rec.Code)
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Type != "http://secapi.cloud/errors/resource-conflict" {
+		t.Fatalf("expected resource-conflict problem, got %s", rec.Body.String())
+	}
+
+	remove := httptest.NewRecorder()
+	admin.ServeHTTP(remove, httptest.NewRequest(http.MethodDelete, "/admin/v1/fault-rules/"+rule.ID, nil))
+	if remove.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 when removing the rule, got %d", remove.Code)
+	}
+	if accepted, _, err := provider.StartInstance(t.Context(), "vm1"); err != nil || !accepted {
+		t.Fatalf("expected retry to reach the provider once the lock clears, got %t, %v", accepted, err)
+	}
+}
+
+func TestAdminFaultRulesRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	handler := adminAddFaultRule(faults.NewInjector())
+	for _, body := range []string{
+		`{"layer":"provider","name":"StartInstance","probability":1,"error":"melted"}`,
+		`{"layer":"provider","name":"StartInstance","probability":1,"latency":"soon"}`,
+		`not json`,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, jsonRequest(http.MethodPost, "/admin/v1/fault-rules", body))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
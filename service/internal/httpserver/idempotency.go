@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyInProgressWait = "1"
+)
+
+// idempotencyStore is the part of *state.Store the idempotency middleware
+// uses.
+type idempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, tenant, key, requestHash string, ttl time.Duration) (*state.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, tenant, key string, statusCode int, header map[string][]string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, tenant, key string) error
+}
+
+// withIdempotency replays the stored response when a PUT, POST or DELETE
+// carries an Idempotency-Key the tenant already used for the same request,
+// without calling next again. Reusing a key for a different method, path or
+// body answers 422, and a retry racing the first request answers 409 until
+// it finished. Responses of 500 and above are not kept, so the retry runs
+// again. A ttl of zero turns the header off.
+func withIdempotency(store idempotencyStore, ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || ttl <= 0 || (r.Method != http.MethodPut && r.Method != http.MethodPost && r.Method != http.MethodDelete) {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "Idempotency-Key must be at most 255 characters", r.URL.Path)
+			return
+		}
+		requestHash, ok := idempotencyRequestHash(r)
+		if !ok {
+			// Too large to fingerprint; the handler answers 413 anyway.
+			next(w, r)
+			return
+		}
+		tenant := r.PathValue("tenant")
+		record, claimed, err := store.ClaimIdempotencyKey(r.Context(), tenant, key, requestHash, ttl)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if !claimed {
+			replayIdempotentResponse(w, r, record, requestHash)
+			return
+		}
+
+		completed := false
+		ctx := context.WithoutCancel(r.Context())
+		defer func() {
+			if !completed {
+				if err := store.ReleaseIdempotencyKey(ctx, tenant, key); err != nil {
+					tracing.Logf(ctx, "release idempotency key of %s: %v", r.URL.Path, err)
+				}
+			}
+		}()
+		buffered := &bufferedResponse{header: http.Header{}}
+		next(buffered, r)
+
+		status := buffered.statusCode()
+		if status < http.StatusInternalServerError {
+			if err := store.CompleteIdempotencyKey(ctx, tenant, key, status, buffered.header, buffered.body.Bytes()); err != nil {
+				tracing.Logf(ctx, "store idempotent response of %s: %v", r.URL.Path, err)
+			} else {
+				completed = true
+			}
+		}
+		for name, values := range buffered.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(buffered.body.Bytes())
+	}
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, record *state.IdempotencyRecord, requestHash string) {
+	switch {
+	case record.RequestHash != requestHash:
+		respondProblem(w, http.StatusUnprocessableEntity, "http://secapi.cloud/errors/invalid-request", "Unprocessable Entity", "Idempotency-Key was already used for a different request", r.URL.Path)
+	case record.StatusCode == 0:
+		w.Header().Set("Retry-After", idempotencyInProgressWait)
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "a request with this Idempotency-Key is still in progress", r.URL.Path)
+	default:
+		for name, values := range record.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(record.StatusCode)
+		_, _ = w.Write(record.Body)
+	}
+}
+
+// idempotencyRequestHash fingerprints method, path, query and body, and puts the
+// body back for the handler. It reports false for bodies over the listener
+// limit.
+func idempotencyRequestHash(r *http.Request) (string, bool) {
+	limit := bodyDecodingOptions(r).maxBytes
+	raw, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), r.Body))
+	if err != nil || int64(len(raw)) > limit {
+		return "", false
+	}
+	sum := sha256.New()
+	_, _ = io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = sum.Write(raw)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
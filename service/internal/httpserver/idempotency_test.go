@@ -0,0 +1,132 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*state.IdempotencyRecord
+}
+
+func (f *fakeIdempotencyStore) ClaimIdempotencyKey(_ context.Context, tenant, key, requestHash string, _ time.Duration) (*state.IdempotencyRecord, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if record, ok := f.records[tenant+"/"+key]; ok {
+		return record, false, nil
+	}
+	record := &state.IdempotencyRecord{Tenant: tenant, Key: key, RequestHash: requestHash}
+	f.records[tenant+"/"+key] = record
+	return record, true, nil
+}
+
+func (f *fakeIdempotencyStore) CompleteIdempotencyKey(_ context.Context, tenant, key string, statusCode int, header map[string][]string, body []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := f.records[tenant+"/"+key]
+	record.StatusCode, record.Header, record.Body = statusCode, header, body
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, tenant, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.records, tenant+"/"+key)
+	return nil
+}
+
+func idempotentMux(store idempotencyStore, handler http.HandlerFunc) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/things/{name}", withIdempotency(store, time.Hour, handler))
+	return mux
+}
+
+func idempotentPut(mux http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := jsonRequest(http.MethodPut, "/v1/tenants/t1/things/a", body)
+	req.Header.Set(idempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	mux := idempotentMux(&fakeIdempotencyStore{records: map[string]*state.IdempotencyRecord{}}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body map[string]any
+		if !decodeJSONBody(w, r, &body) {
+			return
+		}
+		respondJSON(w, http.StatusCreated, map[string]any{"call": calls})
+	})
+
+	first := idempotentPut(mux, "k1", `{"spec":{}}`)
+	retry := idempotentPut(mux, "k1", `{"spec":{}}`)
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the first response replayed, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(idempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("unexpected replay headers: %v", retry.Header())
+	}
+
+	if rec := idempotentPut(mux, "k1", `{"spec":{"other":true}}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a different body, got %d", rec.Code)
+	}
+	if rec := idempotentPut(mux, "k2", `{"spec":{}}`); rec.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected a new key to run the handler, got %d after %d calls", rec.Code, calls)
+	}
+}
+
+func TestIdempotencyRejectsRetryWhileFirstRequestRuns(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeIdempotencyStore{records: map[string]*state.IdempotencyRecord{}}
+	var mux *http.ServeMux
+	var inner *httptest.ResponseRecorder
+	mux = idempotentMux(store, func(w http.ResponseWriter, r *http.Request) {
+		if inner == nil {
+			inner = idempotentPut(mux, "k1", "")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if rec := idempotentPut(mux, "k1", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	if inner.Code != http.StatusConflict || inner.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 409 with Retry-After for the concurrent retry, got %d %v", inner.Code, inner.Header())
+	}
+}
+
+func TestIdempotencyForgetsServerErrors(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	mux := idempotentMux(&fakeIdempotencyStore{records: map[string]*state.IdempotencyRecord{}}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", "try again", r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if rec := idempotentPut(mux, "k1", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if rec := idempotentPut(mux, "k1", ""); rec.Code != http.StatusAccepted || calls != 2 {
+		t.Fatalf("expected the retry to run the handler again, got %d after %d calls", rec.Code, calls)
+	}
+}
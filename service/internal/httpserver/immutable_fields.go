@@ -0,0 +1,128 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// immutableSpecFields lists, per resource kind, the JSON pointers that cannot
+// change once the resource exists. Drop a pointer from its list as soon as the
+// PUT handler learns to apply updates to that field.
+var immutableSpecFields = map[string][]string{
+	"instance":                {"/spec/imageRef"},
+	"block-storage":           {"/spec/skuRef"},
+	"network":                 {"/spec/cidr/ipv4"},
+	resourceBindingKindSubnet: {"/spec/cidr/ipv4", "/spec/zone"},
+	resourceBindingKindImage:  {"/spec/blockStorageRef"},
+}
+
+type immutableFieldViolation struct {
+	Pointer string
+	Current any
+	Desired any
+}
+
+// findImmutableFieldViolation compares the current and desired spec of a
+// resource at every immutable pointer declared for kind. Fields that are
+// unset on either side are not treated as a change.
+func findImmutableFieldViolation(kind string, current, desired any) (*immutableFieldViolation, error) {
+	pointers := immutableSpecFields[kind]
+	if len(pointers) == 0 {
+		return nil, nil
+	}
+	currentDoc, err := specDocument(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredDoc, err := specDocument(desired)
+	if err != nil {
+		return nil, err
+	}
+	for _, pointer := range pointers {
+		currentValue, ok := lookupJSONPointer(currentDoc, pointer)
+		if !ok || isEmptyJSONValue(currentValue) {
+			continue
+		}
+		desiredValue, ok := lookupJSONPointer(desiredDoc, pointer)
+		if !ok || isEmptyJSONValue(desiredValue) {
+			continue
+		}
+		if !jsonValuesEqual(currentValue, desiredValue) {
+			return &immutableFieldViolation{Pointer: pointer, Current: currentValue, Desired: desiredValue}, nil
+		}
+	}
+	return nil, nil
+}
+
+func respondImmutableFieldViolation(w http.ResponseWriter, violation immutableFieldViolation, instance string) {
+	field := strings.ReplaceAll(strings.TrimPrefix(violation.Pointer, "/"), "/", ".")
+	detail := fmt.Sprintf("%s is immutable (current value: %s)", field, formatJSONValue(violation.Current))
+	respondProblemWithSources(w, http.StatusUnprocessableEntity, "http://secapi.cloud/errors/invalid-request", "Unprocessable Entity", detail, instance, []problemSource{{Pointer: violation.Pointer}})
+}
+
+func specDocument(spec any) (any, error) {
+	raw, err := json.Marshal(map[string]any{"spec": spec})
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func lookupJSONPointer(doc any, pointer string) (any, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	current := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[token]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func isEmptyJSONValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func jsonValuesEqual(a, b any) bool {
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.EqualFold(strings.TrimSpace(as), strings.TrimSpace(bs))
+		}
+	}
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(rawA) == string(rawB)
+}
+
+func formatJSONValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(raw)
+}
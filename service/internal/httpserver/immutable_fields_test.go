@@ -0,0 +1,40 @@
+package httpserver
+
+import "testing"
+
+func TestFindImmutableFieldViolationReportsChangedPointer(t *testing.T) {
+	t.Parallel()
+
+	current := instanceSpec{ImageRef: refObject{Resource: "images/ubuntu-24.04"}}
+	desired := instanceSpec{ImageRef: refObject{Resource: "images/debian-12"}}
+
+	violation, err := findImmutableFieldViolation("instance", current, desired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if violation == nil {
+		t.Fatalf("expected violation for changed imageRef")
+	}
+	if violation.Pointer != "/spec/imageRef" {
+		t.Fatalf("unexpected pointer: %q", violation.Pointer)
+	}
+	if violation.Current != "images/ubuntu-24.04" {
+		t.Fatalf("unexpected current value: %v", violation.Current)
+	}
+}
+
+func TestFindImmutableFieldViolationIgnoresUnsetAndMutableFields(t *testing.T) {
+	t.Parallel()
+
+	cidr := "10.0.0.0/16"
+	current := networkSpec{Cidr: networkCIDR{IPv4: &cidr}, SkuRef: refObject{Resource: "skus/a"}}
+	desired := networkSpec{SkuRef: refObject{Resource: "skus/b"}}
+
+	violation, err := findImmutableFieldViolation("network", current, desired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if violation != nil {
+		t.Fatalf("unexpected violation: %+v", *violation)
+	}
+}
@@ -0,0 +1,90 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// instanceUserDataMaxBytes is the largest user data Hetzner accepts.
+const instanceUserDataMaxBytes = 32 * 1024
+
+// userDataScriptPrefixes mark the cloud-init formats a base64-encoded payload
+// is expected to decode to.
+var userDataScriptPrefixes = []string{"#cloud-config", "#!", "#include", "#cloud-boothook", "Content-Type:"}
+
+// normalizeUserData decodes user data sent base64-encoded, since Hetzner
+// forwards user data to cloud-init as is. Payloads that do not decode to a
+// cloud-init document are kept unchanged.
+func normalizeUserData(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || len(trimmed)%4 != 0 {
+		return raw
+	}
+	decoded, err := base64.StdEncoding.Strict().DecodeString(trimmed)
+	if err != nil || !utf8.Valid(decoded) {
+		return raw
+	}
+	for _, prefix := range userDataScriptPrefixes {
+		if strings.HasPrefix(string(decoded), prefix) {
+			return string(decoded)
+		}
+	}
+	return raw
+}
+
+// userDataHash fingerprints user data so clients can detect drift without the
+// proxy returning secrets embedded in it.
+func userDataHash(userData string) string {
+	if userData == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userData))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// userDataSizeProblem reports user data over Hetzner's limit.
+func userDataSizeProblem(userData string) []fieldProblem {
+	if len(userData) <= instanceUserDataMaxBytes {
+		return nil
+	}
+	return []fieldProblem{fieldPointer("/spec/userData", "spec.userData is %d bytes, at most %d are allowed", len(userData), instanceUserDataMaxBytes)}
+}
+
+// instanceUserData returns the user data hash and, when readable, the payload
+// to keep for an instance PUT. Hetzner only applies user data on create, so an
+// update keeps what the instance was created with.
+func instanceUserData(tenant, workspace, name, userData string, created, readable bool) (string, string) {
+	hash := userDataHash(userData)
+	if !created {
+		previous, _ := runtimeResourceState.getInstanceSpec(computeInstanceRef(tenant, workspace, name))
+		hash, userData = previous.UserDataHash, previous.UserData
+	}
+	if !readable {
+		return hash, ""
+	}
+	return hash, userData
+}
+
+// includeUserDataFromQuery parses ?includeUserData=. Asking for user data
+// answers 403 unless readable, since the proxy only keeps it then.
+func includeUserDataFromQuery(w http.ResponseWriter, r *http.Request, readable bool) (bool, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("includeUserData"))
+	if raw == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		respondValidationProblem(w, r.URL.Path, fieldParameter("includeUserData", "includeUserData must be a boolean"))
+		return false, false
+	}
+	if parsed && !readable {
+		respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", "user data retrieval is disabled", r.URL.Path)
+		return false, false
+	}
+	return parsed, true
+}
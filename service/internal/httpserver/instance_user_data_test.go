@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUserDataDecodesBase64CloudInit(t *testing.T) {
+	cloudConfig := "#cloud-config\npackages: [nginx]\n"
+	for raw, want := range map[string]string{
+		cloudConfig: cloudConfig,
+		base64.StdEncoding.EncodeToString([]byte(cloudConfig)): cloudConfig,
+		// Valid base64 that does not decode to cloud-init stays as sent.
+		"abcd": "abcd",
+		"":     "",
+	} {
+		if got := normalizeUserData(raw); got != want {
+			t.Fatalf("normalizeUserData(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if userDataHash(cloudConfig) != userDataHash(normalizeUserData(base64.StdEncoding.EncodeToString([]byte(cloudConfig)))) {
+		t.Fatal("expected plain and base64 user data to hash alike")
+	}
+	if !strings.HasPrefix(userDataHash(cloudConfig), "sha256:") || userDataHash("") != "" {
+		t.Fatalf("unexpected hashes %q and %q", userDataHash(cloudConfig), userDataHash(""))
+	}
+}
+
+func TestUserDataLimitAndRetrievalGate(t *testing.T) {
+	if problems := userDataSizeProblem(strings.Repeat("x", instanceUserDataMaxBytes)); len(problems) != 0 {
+		t.Fatalf("expected 32 KiB to be accepted, got %v", problems)
+	}
+	if problems := userDataSizeProblem(strings.Repeat("x", instanceUserDataMaxBytes+1)); len(problems) != 1 {
+		t.Fatalf("expected one problem over 32 KiB, got %v", problems)
+	}
+	for _, tc := range []struct {
+		query    string
+		readable bool
+		code     int
+	}{
+		{"", false, http.StatusOK},
+		{"?includeUserData=false", false, http.StatusOK},
+		{"?includeUserData=true", false, http.StatusForbidden},
+		{"?includeUserData=true", true, http.StatusOK},
+		{"?includeUserData=yes", true, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		if _, ok := includeUserDataFromQuery(rec, httptest.NewRequest(http.MethodGet, "/instances/vm1"+tc.query, nil), tc.readable); ok {
+			rec.WriteHeader(http.StatusOK)
+		}
+		if rec.Code != tc.code {
+			t.Fatalf("%q readable=%t: expected %d, got %d", tc.query, tc.readable, tc.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelSelector is a parsed labels query parameter: comma-separated terms of
+// the form key=value, key!=value, key (the label is set) or !key (it is not).
+// A resource matches when every term does.
+type labelSelector []labelRequirement
+
+type labelRequirement struct {
+	key    string
+	value  string
+	negate bool
+	exists bool
+}
+
+func parseLabelSelector(raw string) (labelSelector, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var selector labelSelector
+	for _, term := range strings.Split(raw, ",") {
+		term = strings.TrimSpace(term)
+		var req labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			req = labelRequirement{key: strings.TrimSpace(key), value: strings.TrimSpace(value), negate: true}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			req = labelRequirement{key: strings.TrimSpace(key), value: strings.TrimSpace(value)}
+		case strings.HasPrefix(term, "!"):
+			req = labelRequirement{key: strings.TrimSpace(term[1:]), negate: true, exists: true}
+		default:
+			req = labelRequirement{key: term, exists: true}
+		}
+		if req.key == "" || strings.ContainsAny(req.key, "=! ") || strings.ContainsAny(req.value, "=! ") {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// matches reports whether labels satisfy every term of the selector.
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		var match bool
+		if req.exists {
+			match = ok
+		} else {
+			match = ok && value == req.value
+		}
+		if match == req.negate {
+			return false
+		}
+	}
+	return true
+}
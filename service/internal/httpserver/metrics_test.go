@@ -0,0 +1,38 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/metrics"
+)
+
+func TestInstrumentRequestsLabelsByRoutePattern(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/regions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/not-found", "Not Found", "region not found", r.URL.Path)
+	})
+	handler := instrumentRequests(m, mux)
+
+	for _, path := range []string{"/v1/regions/fsn1", "/v1/regions/nbg1", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`secapi_proxy_http_requests_total{method="GET",route="/v1/regions/{name}",status="404"} 2`,
+		`secapi_proxy_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected metrics to contain %s, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// networkDependent is a binding that still references a network or subnet
+// and blocks its deletion unless the caller asks for a cascade.
+type networkDependent struct {
+	ref      string
+	nic      *nicBindingPayload
+	gateways []string
+}
+
+// networkDependents lists the subnets, route tables and NICs that reference
+// network. Route tables are what attaches an internet gateway NAT VM to a
+// network, so the gateways they target are carried along for the cascade.
+func networkDependents(ctx context.Context, store *state.Store, tenant, workspace, network string) ([]networkDependent, error) {
+	var out []networkDependent
+	subnets, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindSubnet)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range subnets {
+		payload, err := parseSubnetBinding(binding.ProviderRef)
+		if err != nil || !strings.EqualFold(payload.Network, network) {
+			continue
+		}
+		out = append(out, networkDependent{ref: binding.SecaRef})
+	}
+	routeTables, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindRouteTable)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range routeTables {
+		payload, err := parseRouteTableBinding(binding.ProviderRef)
+		if err != nil || !strings.EqualFold(payload.Network, network) {
+			continue
+		}
+		out = append(out, networkDependent{ref: binding.SecaRef, gateways: internetGatewayNamesFromRoutes(payload.Spec.Routes)})
+	}
+	nics, err := nicDependents(ctx, store, tenant, workspace, func(payload nicBindingPayload) bool {
+		return strings.EqualFold(payload.Network, network)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, nics...), nil
+}
+
+// subnetDependents lists the NICs placed in the subnet.
+func subnetDependents(ctx context.Context, store *state.Store, tenant, workspace, network, subnet string) ([]networkDependent, error) {
+	return nicDependents(ctx, store, tenant, workspace, func(payload nicBindingPayload) bool {
+		return strings.EqualFold(payload.Network, network) && resourceNameFromRef(payload.Spec.SubnetRef.Resource) == subnet
+	})
+}
+
+func nicDependents(ctx context.Context, store *state.Store, tenant, workspace string, match func(nicBindingPayload) bool) ([]networkDependent, error) {
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindNIC)
+	if err != nil {
+		return nil, err
+	}
+	var out []networkDependent
+	for _, binding := range bindings {
+		payload, err := parseNICBinding(binding.ProviderRef)
+		if err != nil || !match(payload) {
+			continue
+		}
+		out = append(out, networkDependent{ref: binding.SecaRef, nic: &payload})
+	}
+	return out, nil
+}
+
+// forceFromQuery parses the force query flag of cascading deletes.
+func forceFromQuery(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("force"))
+	if raw == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "force must be a boolean", r.URL.Path)
+		return false, false
+	}
+	return parsed, true
+}
+
+// respondDependentsConflict answers 409 and names every blocking binding, one
+// problem source per reference.
+func respondDependentsConflict(w http.ResponseWriter, r *http.Request, resource string, dependents []networkDependent) {
+	refs := make([]string, 0, len(dependents))
+	sources := make([]problemSource, 0, len(dependents))
+	for _, dependent := range dependents {
+		refs = append(refs, dependent.ref)
+		sources = append(sources, problemSource{Parameter: dependent.ref})
+	}
+	detail := resource + " is still referenced by " + strings.Join(refs, ", ") + "; delete them first or retry with force=true"
+	respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", detail, r.URL.Path, sources)
+}
+
+// cascadeDependents detaches NIC servers and drops the dependent bindings.
+// It returns the internet gateways whose routes went away; the caller
+// reconciles them so their NAT VMs leave the network before it is deleted.
+func cascadeDependents(ctx context.Context, store *state.Store, computeProvider ComputeStorageProvider, tenant, workspace string, dependents []networkDependent) ([]string, error) {
+	seen := map[string]struct{}{}
+	var gateways []string
+	for _, dependent := range dependents {
+		if dependent.nic != nil {
+			if err := detachUnreferencedNICNetwork(ctx, computeProvider, store, tenant, workspace, dependent.ref, *dependent.nic); err != nil {
+				return nil, err
+			}
+		}
+		if err := store.DeleteResourceBinding(ctx, dependent.ref); err != nil {
+			return nil, err
+		}
+		for _, name := range dependent.gateways {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				gateways = append(gateways, name)
+			}
+		}
+	}
+	return gateways, nil
+}
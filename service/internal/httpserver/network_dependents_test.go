@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestRespondDependentsConflictListsReferences(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	respondDependentsConflict(rec, httptest.NewRequest(http.MethodDelete, "/x", nil), "network net-1", []networkDependent{
+		{ref: subnetRefKey("t", "ws", "net-1", "sub-1")},
+		{ref: nicRef("t", "ws", "nic-1")},
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if len(problem.Sources) != 2 || problem.Sources[1].Parameter != nicRef("t", "ws", "nic-1") {
+		t.Fatalf("expected one source per dependent, got %+v", problem.Sources)
+	}
+	if !strings.Contains(problem.Detail, "force=true") {
+		t.Fatalf("expected detail to mention force=true, got %q", problem.Detail)
+	}
+}
+
+func TestForceFromQuery(t *testing.T) {
+	t.Parallel()
+
+	for query, want := range map[string]bool{"": false, "?force=true": true, "?force=false": false} {
+		force, ok := forceFromQuery(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/x"+query, nil))
+		if !ok || force != want {
+			t.Fatalf("%q: expected force=%t, got %t (ok=%t)", query, want, force, ok)
+		}
+	}
+	rec := httptest.NewRecorder()
+	if _, ok := forceFromQuery(rec, httptest.NewRequest(http.MethodDelete, "/x?force=maybe", nil)); ok || rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-boolean force, got %d", rec.Code)
+	}
+}
+
+func TestNetworkDependentsAndCascade(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	bind := func(kind, ref string, payload any) {
+		t.Helper()
+		raw, _ := json.Marshal(payload)
+		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{Tenant: tenant, Workspace: "ws", Kind: kind, SecaRef: ref, ProviderRef: string(raw), Status: "active"}); err != nil {
+			t.Fatalf("bind %s: %v", ref, err)
+		}
+	}
+	bind(resourceBindingKindSubnet, subnetRefKey(tenant, "ws", "net-1", "sub-1"), subnetBindingPayload{Name: "sub-1", Network: "net-1"})
+	bind(resourceBindingKindSubnet, subnetRefKey(tenant, "ws", "net-2", "sub-2"), subnetBindingPayload{Name: "sub-2", Network: "net-2"})
+	bind(resourceBindingKindRouteTable, routeTableRefKey(tenant, "ws", "net-1", "rt-1"), routeTableBindingPayload{Name: "rt-1", Network: "net-1"})
+	bind(resourceBindingKindNIC, nicRef(tenant, "ws", "nic-1"), nicBindingPayload{Name: "nic-1", Network: "net-1", Spec: nicSpec{SubnetRef: refObject{Resource: "subnets/sub-1"}}})
+
+	subnetBlockers, err := subnetDependents(ctx, store, tenant, "ws", "net-1", "sub-1")
+	if err != nil || len(subnetBlockers) != 1 || subnetBlockers[0].ref != nicRef(tenant, "ws", "nic-1") {
+		t.Fatalf("expected nic-1 to block sub-1, got %+v, %v", subnetBlockers, err)
+	}
+	blockers, err := networkDependents(ctx, store, tenant, "ws", "net-1")
+	if err != nil || len(blockers) != 3 {
+		t.Fatalf("expected subnet, route table and nic to block net-1, got %+v, %v", blockers, err)
+	}
+
+	provider := &fakeComputeProvider{}
+	if _, err := cascadeDependents(ctx, store, provider, tenant, "ws", blockers); err != nil {
+		t.Fatalf("cascade: %v", err)
+	}
+	if remaining, _ := networkDependents(ctx, store, tenant, "ws", "net-1"); len(remaining) != 0 {
+		t.Fatalf("expected cascade to drop every dependent, got %+v", remaining)
+	}
+	if other, _ := store.GetResourceBinding(ctx, subnetRefKey(tenant, "ws", "net-2", "sub-2")); other == nil {
+		t.Fatalf("expected subnets of other networks to survive the cascade")
+	}
+}
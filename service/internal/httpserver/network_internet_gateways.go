@@ -3,10 +3,12 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
@@ -16,6 +18,39 @@ import (
 
 const resourceBindingKindInternetGateway = "internet-gateway"
 
+const (
+	internetGatewaySKULabel   = "seca.internet-gateway/sku"
+	internetGatewayImageLabel = "seca.internet-gateway/image"
+)
+
+var internetGatewayReconciles = newInternetGatewayLocks()
+
+// internetGatewayLocks serializes the reconciliation of each gateway, so two
+// route table writes cannot interleave their usage reads and provider calls
+// and tear down a NAT VM that the other one still routes through. Like the
+// attachment tracker it only covers requests served by this process.
+type internetGatewayLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newInternetGatewayLocks() *internetGatewayLocks {
+	return &internetGatewayLocks{locks: map[string]*sync.Mutex{}}
+}
+
+// lock acquires the lock of the gateway ref and returns its release func.
+func (l *internetGatewayLocks) lock(ref string) func() {
+	l.mu.Lock()
+	m, ok := l.locks[ref]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[ref] = m
+	}
+	l.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
 type internetGatewayIterator struct {
 	Items    []internetGatewayResource `json:"items"`
 	Metadata responseMetaObject        `json:"metadata"`
@@ -33,25 +68,30 @@ type internetGatewaySpec struct {
 }
 
 type internetGatewayStatusObject struct {
-	State string `json:"state"`
+	State string                `json:"state"`
+	NATVM *internetGatewayNATVM `json:"natVm,omitempty"`
+}
+
+// internetGatewayNATVM is the server type and image the NAT VM was actually
+// provisioned with, after any regional fallback.
+type internetGatewayNATVM struct {
+	ServerType string `json:"serverType"`
+	Image      string `json:"image"`
 }
 
 type internetGatewayBindingPayload struct {
-	Name        string              `json:"name"`
-	Region      string              `json:"region"`
-	Labels      map[string]string   `json:"labels,omitempty"`
-	Spec        internetGatewaySpec `json:"spec"`
-	Networks    []string            `json:"networks,omitempty"`
-	RouteTables []string            `json:"routeTables,omitempty"`
-	ProviderRef string              `json:"providerRef,omitempty"`
+	Name        string                `json:"name"`
+	Region      string                `json:"region"`
+	Labels      map[string]string     `json:"labels,omitempty"`
+	Spec        internetGatewaySpec   `json:"spec"`
+	Networks    []string              `json:"networks,omitempty"`
+	RouteTables []string              `json:"routeTables,omitempty"`
+	ProviderRef string                `json:"providerRef,omitempty"`
+	NATVM       *internetGatewayNATVM `json:"natVm,omitempty"`
 }
 
 func listInternetGateways(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
@@ -70,30 +110,15 @@ func listInternetGateways(store *state.Store) http.HandlerFunc {
 			if err != nil {
 				continue
 			}
-			items = append(items, toInternetGatewayResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			items = append(items, toInternetGatewayResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, internetGatewayIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/internet-gateways", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/internet-gateways", Verb: verbList},
 		})
 	}
 }
 
-func internetGatewayCRUD(store *state.Store, computeProvider ComputeStorageProvider, cfg config.Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getInternetGateway(store)(w, r)
-		case http.MethodPut:
-			putInternetGateway(store, computeProvider, cfg)(w, r)
-		case http.MethodDelete:
-			deleteInternetGateway(store, computeProvider, cfg)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getInternetGateway(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "internet gateway name is required")
@@ -122,7 +147,7 @@ func getInternetGateway(store *state.Store) http.HandlerFunc {
 			payload.Networks = networks
 			payload.RouteTables = routeTables
 		}
-		respondJSON(w, http.StatusOK, toInternetGatewayResourceFromBinding(*binding, payload, tenant, workspace, http.MethodGet, "active"))
+		respondJSON(w, http.StatusOK, toInternetGatewayResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
@@ -138,8 +163,11 @@ func putInternetGateway(store *state.Store, computeProvider ComputeStorageProvid
 		}
 
 		var req internetGatewayResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
 			return
 		}
 		ref := internetGatewayRef(tenant, workspace, name)
@@ -148,12 +176,21 @@ func putInternetGateway(store *state.Store, computeProvider ComputeStorageProvid
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load internet gateway", r.URL.Path)
 			return
 		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
 		payload := internetGatewayBindingPayload{
 			Name:   name,
-			Region: runtimeRegionOrDefault(req.Metadata.Region),
+			Region: region,
 			Labels: req.Labels,
 			Spec:   req.Spec,
 		}
+		release := internetGatewayReconciles.lock(ref)
+		defer release()
 		networks, routeTables, usageErr := resolveInternetGatewayRouteUsage(r.Context(), store, tenant, workspace, name)
 		if usageErr != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve internet gateway route usage", r.URL.Path)
@@ -161,7 +198,10 @@ func putInternetGateway(store *state.Store, computeProvider ComputeStorageProvid
 		}
 		payload.Networks = networks
 		payload.RouteTables = routeTables
-		providerRef, reconcileErr := reconcileInternetGatewayProvider(ctx, store, computeProvider, cfg, tenant, workspace, payload)
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		providerRef, natVM, _, reconcileErr := reconcileInternetGatewayProvider(ctx, store, computeProvider, cfg, tenant, workspace, payload)
 		if reconcileErr != nil {
 			respondFromError(w, reconcileErr, r.URL.Path)
 			return
@@ -169,19 +209,23 @@ func putInternetGateway(store *state.Store, computeProvider ComputeStorageProvid
 		if providerRef != "" {
 			payload.ProviderRef = providerRef
 		}
+		payload.NATVM = natVM
 		raw, err := json.Marshal(payload)
 		if err != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode internet gateway", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        resourceBindingKindInternetGateway,
 			SecaRef:     ref,
 			ProviderRef: string(raw),
 			Status:      "active",
-		}); err != nil {
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save internet gateway", r.URL.Path)
 			return
 		}
@@ -194,7 +238,7 @@ func putInternetGateway(store *state.Store, computeProvider ComputeStorageProvid
 		if existing == nil {
 			stateValue, code = "creating", http.StatusCreated
 		}
-		respondJSON(w, code, toInternetGatewayResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toInternetGatewayResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
 	}
 }
 
@@ -208,6 +252,10 @@ func deleteInternetGateway(store *state.Store, computeProvider ComputeStoragePro
 		if !ok {
 			return
 		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
 		ref := internetGatewayRef(tenant, workspace, name)
 		binding, err := store.GetResourceBinding(r.Context(), ref)
 		if err != nil {
@@ -215,7 +263,15 @@ func deleteInternetGateway(store *state.Store, computeProvider ComputeStoragePro
 			return
 		}
 		if binding == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "internet gateway not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "internet gateway not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
+			return
+		}
+		release := internetGatewayReconciles.lock(ref)
+		defer release()
+		if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
 			return
 		}
 		if cfg.InternetGatewayNATVM {
@@ -225,11 +281,14 @@ func deleteInternetGateway(store *state.Store, computeProvider ComputeStoragePro
 				return
 			}
 		}
-		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete internet gateway", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -285,41 +344,75 @@ func resolveInternetGatewayRouteUsage(ctx context.Context, store *state.Store, t
 	return networks, routeTables, nil
 }
 
+// refreshInternetGateways reconciles every named gateway against the current
+// route usage and records the resulting provider actions in one batch.
+func refreshInternetGateways(
+	ctx context.Context,
+	store *state.Store,
+	computeProvider ComputeStorageProvider,
+	cfg config.Config,
+	tenant, workspace string,
+	gatewayNames []string,
+) error {
+	operations := make([]state.OperationRecord, 0, len(gatewayNames))
+	for _, gatewayName := range gatewayNames {
+		actionID, err := refreshInternetGatewayFromRouteUsage(ctx, store, computeProvider, cfg, tenant, workspace, gatewayName)
+		if err != nil {
+			return err
+		}
+		if actionID == "" {
+			continue
+		}
+		operations = append(operations, state.OperationRecord{
+			OperationID:      operationID("internet-gateway-reconcile", gatewayName),
+			SecaRef:          internetGatewayRef(tenant, workspace, gatewayName),
+			ProviderActionID: actionID,
+			Phase:            "accepted",
+		})
+	}
+	return errors.Join(store.CreateOperations(ctx, operations)...)
+}
+
 func refreshInternetGatewayFromRouteUsage(
 	ctx context.Context,
 	store *state.Store,
 	computeProvider ComputeStorageProvider,
 	cfg config.Config,
 	tenant, workspace, gatewayName string,
-) error {
+) (string, error) {
 	ref := internetGatewayRef(tenant, workspace, gatewayName)
+	release := internetGatewayReconciles.lock(ref)
+	defer release()
+	// Usage is read under the lock, after the caller's route table write has
+	// committed, so the last writer always sees every other write.
 	binding, err := store.GetResourceBinding(ctx, ref)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if binding == nil {
-		return nil
+		return "", nil
 	}
 	payload, err := parseInternetGatewayBinding(binding.ProviderRef)
 	if err != nil {
-		return err
+		return "", err
 	}
 	networks, routeTables, err := resolveInternetGatewayRouteUsage(ctx, store, tenant, workspace, gatewayName)
 	if err != nil {
-		return err
+		return "", err
 	}
 	payload.Networks = networks
 	payload.RouteTables = routeTables
-	providerRef, err := reconcileInternetGatewayProvider(ctx, store, computeProvider, cfg, tenant, workspace, payload)
+	providerRef, natVM, actionID, err := reconcileInternetGatewayProvider(ctx, store, computeProvider, cfg, tenant, workspace, payload)
 	if err != nil {
-		return err
+		return "", err
 	}
 	payload.ProviderRef = providerRef
+	payload.NATVM = natVM
 	raw, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return store.UpsertResourceBinding(ctx, state.ResourceBinding{
+	return actionID, store.UpsertResourceBinding(ctx, state.ResourceBinding{
 		Tenant:      tenant,
 		Workspace:   workspace,
 		Kind:        resourceBindingKindInternetGateway,
@@ -336,34 +429,47 @@ func reconcileInternetGatewayProvider(
 	cfg config.Config,
 	tenant, workspace string,
 	payload internetGatewayBindingPayload,
-) (string, error) {
+) (string, *internetGatewayNATVM, string, error) {
 	if !cfg.InternetGatewayNATVM {
-		return "", nil
+		return "", nil, "", nil
 	}
 	if computeProvider == nil {
-		return "", fmt.Errorf("internet-gateway provisioning is enabled but compute provider is not available")
+		return "", nil, "", fmt.Errorf("internet-gateway provisioning is enabled but compute provider is not available")
 	}
 	region := strings.ToLower(strings.TrimSpace(payload.Region))
 	if region == "" {
 		workspaceRegion, ok := workspaceRegionOrDefault(ctx, store, tenant, workspace)
 		if !ok {
-			return "", fmt.Errorf("failed to resolve workspace region")
+			return "", nil, "", fmt.Errorf("failed to resolve workspace region")
 		}
 		region = workspaceRegion
 	}
 
 	instanceName := internetGatewayInstanceName(workspace, payload.Name)
 	if len(payload.RouteTables) == 0 {
-		_, _, err := computeProvider.DeleteInstance(ctx, instanceName)
-		return "", err
+		if store != nil {
+			_, routeTables, err := resolveInternetGatewayRouteUsage(ctx, store, tenant, workspace, payload.Name)
+			if err != nil {
+				return "", nil, "", err
+			}
+			if len(routeTables) > 0 {
+				return payload.ProviderRef, payload.NATVM, "", nil
+			}
+		}
+		_, actionID, err := computeProvider.DeleteInstance(ctx, instanceName)
+		return "", nil, actionID, err
 	}
 
-	_, _, _, err := computeProvider.CreateOrUpdateInstance(ctx, hetzner.InstanceCreateRequest{
+	natVM, err := internetGatewayNATVMChoice(ctx, computeProvider, cfg, payload, region)
+	if err != nil {
+		return "", nil, "", err
+	}
+	_, _, actionID, err := computeProvider.CreateOrUpdateInstance(ctx, hetzner.InstanceCreateRequest{
 		Name:      instanceName,
-		SKUName:   "cax11",
-		ImageName: "ubuntu-24.04",
+		SKUName:   natVM.ServerType,
+		ImageName: natVM.Image,
 		Region:    region,
-		UserData:  internetGatewayNATCloudInit(payload),
+		UserData:  internetGatewayNATCloudInit(payload, cfg.InternetGatewayExtra),
 		Labels: withSecaProviderLabels(
 			payload.Labels,
 			tenant,
@@ -374,22 +480,48 @@ func reconcileInternetGatewayProvider(
 		),
 	})
 	if err != nil {
-		return "", err
+		return "", nil, "", err
 	}
 	instance, err := computeProvider.GetInstance(ctx, instanceName)
 	if err != nil {
-		return "", err
+		return "", nil, "", err
 	}
 	if instance == nil {
-		return "", fmt.Errorf("internet-gateway instance %q not found after create", instanceName)
+		return "", nil, "", fmt.Errorf("internet-gateway instance %q not found after create", instanceName)
 	}
 	if syncErr := computeProvider.SyncInstanceNetworks(ctx, instanceName, payload.Networks); syncErr != nil {
-		return "", syncErr
+		return "", nil, "", syncErr
+	}
+	return fmt.Sprintf("instances/%s", instance.Name), natVM, actionID, nil
+}
+
+// internetGatewayNATVMChoice picks the NAT VM server type and image. The
+// seca.internet-gateway/sku and seca.internet-gateway/image labels override
+// the configured defaults; a server type the region does not offer falls
+// back to the smallest x86 one there.
+func internetGatewayNATVMChoice(
+	ctx context.Context,
+	computeProvider ComputeStorageProvider,
+	cfg config.Config,
+	payload internetGatewayBindingPayload,
+	region string,
+) (*internetGatewayNATVM, error) {
+	preferred := cfg.InternetGatewaySKU
+	if label := strings.TrimSpace(payload.Labels[internetGatewaySKULabel]); label != "" {
+		preferred = label
+	}
+	image := cfg.InternetGatewayImage
+	if label := strings.TrimSpace(payload.Labels[internetGatewayImageLabel]); label != "" {
+		image = label
+	}
+	serverType, err := computeProvider.ServerTypeForRegion(ctx, preferred, region, "x86")
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("instances/%s", instance.Name), nil
+	return &internetGatewayNATVM{ServerType: serverType, Image: image}, nil
 }
 
-func internetGatewayNATCloudInit(payload internetGatewayBindingPayload) string {
+func internetGatewayNATCloudInit(payload internetGatewayBindingPayload, extra string) string {
 	egressOnly := true
 	if payload.Spec.EgressOnly != nil {
 		egressOnly = *payload.Spec.EgressOnly
@@ -398,9 +530,29 @@ func internetGatewayNATCloudInit(payload internetGatewayBindingPayload) string {
 	if !egressOnly {
 		egressMarker = "false"
 	}
+	extraFile, extraCmd := "", ""
+	if strings.TrimSpace(extra) != "" {
+		extraFile = `  - path: /usr/local/sbin/seca-igw-extra.sh
+    permissions: "0755"
+    content: |
+      #!/usr/bin/env bash
+` + indentCloudInitScript(extra)
+		extraCmd = `  - [bash, -lc, "/usr/local/sbin/seca-igw-extra.sh"]
+`
+	}
 
 	return fmt.Sprintf(`#cloud-config
+ssh_pwauth: false
+package_update: true
+packages:
+  - unattended-upgrades
 write_files:
+  - path: /etc/ssh/sshd_config.d/99-seca-igw.conf
+    permissions: "0644"
+    content: |
+      PasswordAuthentication no
+      KbdInteractiveAuthentication no
+      PermitRootLogin prohibit-password
   - path: /usr/local/sbin/seca-igw-init.sh
     permissions: "0755"
     content: |
@@ -429,11 +581,26 @@ write_files:
         iptables -A FORWARD -i "${EGRESS_IFACE}" -j ACCEPT
       iptables -C FORWARD -o "${EGRESS_IFACE}" -j ACCEPT 2>/dev/null || \
         iptables -A FORWARD -o "${EGRESS_IFACE}" -j ACCEPT
-
+%s
 runcmd:
+  - [systemctl, restart, ssh]
+  - [systemctl, enable, --now, unattended-upgrades]
   - [bash, -lc, "/usr/local/sbin/seca-igw-init.sh"]
-final_message: "SECA internet-gateway init complete (egressOnly=%s)"
-`, egressMarker)
+%sfinal_message: "SECA internet-gateway init complete (egressOnly=%s)"
+`, extraFile, extraCmd, egressMarker)
+}
+
+// indentCloudInitScript nests an operator snippet in a write_files block.
+func indentCloudInitScript(script string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString("      " + line + "\n")
+	}
+	return b.String()
 }
 
 func internetGatewayInstanceName(workspace, gatewayName string) string {
@@ -451,11 +618,11 @@ func toInternetGatewayResourceFromBinding(
 	binding state.ResourceBinding,
 	payload internetGatewayBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) internetGatewayResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -471,7 +638,7 @@ func toInternetGatewayResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "internet-gateway",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/internet-gateways/" + payload.Name,
@@ -481,6 +648,6 @@ func toInternetGatewayResourceFromBinding(
 		},
 		Labels: payload.Labels,
 		Spec:   payload.Spec,
-		Status: internetGatewayStatusObject{State: stateValue},
+		Status: internetGatewayStatusObject{State: stateValue, NATVM: payload.NATVM},
 	}
 }
@@ -2,25 +2,40 @@ package httpserver
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
 
 type fakeComputeProvider struct {
 	createReq    *hetzner.InstanceCreateRequest
 	getInstance  *hetzner.Instance
 	deleteName   string
+	waitErr      error
 	syncName     string
 	syncNetworks []string
+	// serverTypeFallbacks maps regions to the server type picked when the
+	// preferred one is not offered there.
+	serverTypeFallbacks map[string]string
+	// events records instance creates and deletes in call order.
+	events []string
 }
 
 func (f *fakeComputeProvider) ListInstances(context.Context) ([]hetzner.Instance, error) {
 	return nil, nil
 }
 
+func (f *fakeComputeProvider) ListInstancesByLabels(context.Context, map[string]string) ([]hetzner.Instance, error) {
+	return nil, nil
+}
+
 func (f *fakeComputeProvider) GetInstance(context.Context, string) (*hetzner.Instance, error) {
 	return f.getInstance, nil
 }
@@ -28,14 +43,24 @@ func (f *fakeComputeProvider) GetInstance(context.Context, string) (*hetzner.Ins
 func (f *fakeComputeProvider) CreateOrUpdateInstance(_ context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, string, error) {
 	r := req
 	f.createReq = &r
+	f.events = append(f.events, "create "+req.Name)
 	return &hetzner.Instance{Name: req.Name}, true, "", nil
 }
 
+func (f *fakeComputeProvider) ValidateInstanceCreate(_ context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, error) {
+	return &hetzner.Instance{Name: req.Name, SKUName: req.SKUName, ImageName: req.ImageName, Region: req.Region, Labels: req.Labels}, f.getInstance == nil, nil
+}
+
 func (f *fakeComputeProvider) DeleteInstance(_ context.Context, name string) (bool, string, error) {
 	f.deleteName = name
+	f.events = append(f.events, "delete "+name)
 	return true, "", nil
 }
 
+func (f *fakeComputeProvider) WaitForAction(context.Context, int64) error {
+	return f.waitErr
+}
+
 func (f *fakeComputeProvider) StartInstance(context.Context, string) (bool, string, error) {
 	return true, "", nil
 }
@@ -48,10 +73,29 @@ func (f *fakeComputeProvider) RestartInstance(context.Context, string) (bool, st
 	return true, "", nil
 }
 
+func (f *fakeComputeProvider) ServerTypeForRegion(_ context.Context, preferred, region, fallbackArchitecture string) (string, error) {
+	if fallback, ok := f.serverTypeFallbacks[region]; ok {
+		return fallback, nil
+	}
+	return preferred, nil
+}
+
+func (f *fakeComputeProvider) SetInstanceBackups(context.Context, string, bool) (bool, string, error) {
+	return true, "", nil
+}
+
 func (f *fakeComputeProvider) AttachInstanceToNetwork(context.Context, string, string) (bool, string, error) {
 	return true, "", nil
 }
 
+func (f *fakeComputeProvider) AttachInstanceToNetworkWithIP(context.Context, string, string, string) (bool, string, error) {
+	return true, "", nil
+}
+
+func (f *fakeComputeProvider) DetachInstanceFromNetwork(context.Context, string, string) (bool, error) {
+	return true, nil
+}
+
 func (f *fakeComputeProvider) SyncInstanceNetworks(_ context.Context, instanceName string, networkNames []string) error {
 	f.syncName = instanceName
 	f.syncNetworks = append([]string(nil), networkNames...)
@@ -62,10 +106,38 @@ func (f *fakeComputeProvider) GetInstancePrivateIPv4(context.Context, string, st
 	return "10.10.1.10", nil
 }
 
+func (f *fakeComputeProvider) SyncInstanceSecurityGroups(context.Context, string, []string, func(hetzner.SecurityGroup) bool) error {
+	return nil
+}
+
+func (f *fakeComputeProvider) GetInstanceMetrics(context.Context, hetzner.InstanceMetricsRequest) (*hetzner.InstanceMetrics, error) {
+	return nil, nil
+}
+
+func (f *fakeComputeProvider) ListPlacementGroups(context.Context) ([]hetzner.PlacementGroup, error) {
+	return nil, nil
+}
+
+func (f *fakeComputeProvider) GetPlacementGroup(context.Context, string) (*hetzner.PlacementGroup, error) {
+	return nil, nil
+}
+
+func (f *fakeComputeProvider) CreateOrUpdatePlacementGroup(context.Context, hetzner.PlacementGroupCreateRequest) (*hetzner.PlacementGroup, bool, error) {
+	return nil, false, nil
+}
+
+func (f *fakeComputeProvider) DeletePlacementGroup(context.Context, string) (bool, error) {
+	return false, nil
+}
+
 func (f *fakeComputeProvider) ListBlockStorages(context.Context) ([]hetzner.BlockStorage, error) {
 	return nil, nil
 }
 
+func (f *fakeComputeProvider) ListBlockStoragesByLabels(context.Context, map[string]string) ([]hetzner.BlockStorage, error) {
+	return nil, nil
+}
+
 func (f *fakeComputeProvider) GetBlockStorage(context.Context, string) (*hetzner.BlockStorage, error) {
 	return nil, nil
 }
@@ -74,11 +146,15 @@ func (f *fakeComputeProvider) CreateOrUpdateBlockStorage(context.Context, hetzne
 	return nil, false, "", nil
 }
 
-func (f *fakeComputeProvider) DeleteBlockStorage(context.Context, string) (bool, error) {
+func (f *fakeComputeProvider) ValidateBlockStorageCreate(_ context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, error) {
+	return &hetzner.BlockStorage{Name: req.Name, SizeGB: req.SizeGB, Region: req.Region, Labels: req.Labels}, true, nil
+}
+
+func (f *fakeComputeProvider) DeleteBlockStorage(context.Context, string, bool) (bool, error) {
 	return true, nil
 }
 
-func (f *fakeComputeProvider) AttachBlockStorage(context.Context, string, string) (bool, string, error) {
+func (f *fakeComputeProvider) AttachBlockStorage(context.Context, string, string, bool) (bool, string, error) {
 	return true, "", nil
 }
 
@@ -86,6 +162,18 @@ func (f *fakeComputeProvider) DetachBlockStorage(context.Context, string) (bool,
 	return true, "", nil
 }
 
+func (f *fakeComputeProvider) CreateImageSnapshot(context.Context, hetzner.ImageSnapshotCreateRequest) (*hetzner.ImageSnapshot, string, error) {
+	return nil, "", nil
+}
+
+func (f *fakeComputeProvider) FindImageSnapshot(context.Context, map[string]string) (*hetzner.ImageSnapshot, error) {
+	return nil, nil
+}
+
+func (f *fakeComputeProvider) DeleteImageSnapshot(context.Context, int64) (bool, error) {
+	return true, nil
+}
+
 func TestReconcileInternetGatewayProviderCreateAndSync(t *testing.T) {
 	t.Parallel()
 
@@ -102,7 +190,7 @@ func TestReconcileInternetGatewayProviderCreateAndSync(t *testing.T) {
 		RouteTables: []string{"rt-a"},
 	}
 
-	ref, err := reconcileInternetGatewayProvider(context.Background(), nil, fake, cfg, "dev", "ws1", payload)
+	ref, _, _, err := reconcileInternetGatewayProvider(context.Background(), nil, fake, cfg, "dev", "ws1", payload)
 	if err != nil {
 		t.Fatalf("reconcileInternetGatewayProvider returned error: %v", err)
 	}
@@ -135,6 +223,49 @@ func TestReconcileInternetGatewayProviderCreateAndSync(t *testing.T) {
 	}
 }
 
+func TestReconcileInternetGatewayProviderRecordsNATVMChoice(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeComputeProvider{
+		getInstance:         &hetzner.Instance{Name: "seca-igw-ws1-igw1"},
+		serverTypeFallbacks: map[string]string{"ash": "cpx11"},
+	}
+	cfg := config.Config{
+		InternetGatewayNATVM: true,
+		InternetGatewaySKU:   "cax11",
+		InternetGatewayImage: "ubuntu-24.04",
+		InternetGatewayExtra: "echo hello\ntouch /var/lib/seca-extra",
+	}
+	payload := internetGatewayBindingPayload{
+		Name:        "igw1",
+		Region:      "ash",
+		Labels:      map[string]string{internetGatewayImageLabel: "debian-12"},
+		RouteTables: []string{"rt-a"},
+	}
+
+	_, natVM, _, err := reconcileInternetGatewayProvider(context.Background(), nil, fake, cfg, "dev", "ws1", payload)
+	if err != nil {
+		t.Fatalf("reconcileInternetGatewayProvider returned error: %v", err)
+	}
+	if natVM == nil || natVM.ServerType != "cpx11" || natVM.Image != "debian-12" {
+		t.Fatalf("unexpected NAT VM choice: %+v", natVM)
+	}
+	if fake.createReq.SKUName != "cpx11" || fake.createReq.ImageName != "debian-12" {
+		t.Fatalf("unexpected create request: %s/%s", fake.createReq.SKUName, fake.createReq.ImageName)
+	}
+	for _, want := range []string{
+		"ssh_pwauth: false",
+		"PasswordAuthentication no",
+		"- unattended-upgrades",
+		"      touch /var/lib/seca-extra\n",
+		`"/usr/local/sbin/seca-igw-extra.sh"`,
+	} {
+		if !strings.Contains(fake.createReq.UserData, want) {
+			t.Fatalf("expected %q in user data:\n%s", want, fake.createReq.UserData)
+		}
+	}
+}
+
 func TestReconcileInternetGatewayProviderCleanup(t *testing.T) {
 	t.Parallel()
 
@@ -146,7 +277,7 @@ func TestReconcileInternetGatewayProviderCleanup(t *testing.T) {
 		RouteTables: nil,
 	}
 
-	ref, err := reconcileInternetGatewayProvider(context.Background(), nil, fake, cfg, "dev", "ws1", payload)
+	ref, _, _, err := reconcileInternetGatewayProvider(context.Background(), nil, fake, cfg, "dev", "ws1", payload)
 	if err != nil {
 		t.Fatalf("reconcileInternetGatewayProvider returned error: %v", err)
 	}
@@ -160,3 +291,109 @@ func TestReconcileInternetGatewayProviderCleanup(t *testing.T) {
 		t.Fatal("did not expect create request")
 	}
 }
+
+func TestInternetGatewayLocksSerializePerGateway(t *testing.T) {
+	t.Parallel()
+
+	locks := newInternetGatewayLocks()
+	release := locks.lock("igw-a")
+
+	otherDone := make(chan struct{})
+	go func() {
+		locks.lock("igw-b")()
+		close(otherDone)
+	}()
+	select {
+	case <-otherDone:
+	case <-time.After(time.Second):
+		t.Fatal("a different gateway should not wait for the lock")
+	}
+
+	sameDone := make(chan struct{})
+	go func() {
+		locks.lock("igw-a")()
+		close(sameDone)
+	}()
+	select {
+	case <-sameDone:
+		t.Fatal("the same gateway must wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	<-sameDone
+}
+
+func TestRefreshInternetGatewayKeepsNATVMForConcurrentRouteTables(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	const workspace = "ws"
+
+	putRouteTable := func(name string, targets ...string) {
+		t.Helper()
+		payload := routeTableBindingPayload{Name: name, Network: "net-" + name}
+		for _, target := range targets {
+			payload.Spec.Routes = append(payload.Spec.Routes, routeTableRouteSpec{
+				DestinationCidrBlock: "0.0.0.0/0",
+				TargetRef:            refObject{Resource: "internet-gateways/" + target},
+			})
+		}
+		raw, _ := json.Marshal(payload)
+		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+			Tenant:      tenant,
+			Workspace:   workspace,
+			Kind:        resourceBindingKindRouteTable,
+			SecaRef:     routeTableRefKey(tenant, workspace, payload.Network, name),
+			ProviderRef: string(raw),
+			Status:      "active",
+		}); err != nil {
+			t.Fatalf("save route table %s: %v", name, err)
+		}
+	}
+	raw, _ := json.Marshal(internetGatewayBindingPayload{Name: "igw", Region: "nbg1"})
+	if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		Tenant:      tenant,
+		Workspace:   workspace,
+		Kind:        resourceBindingKindInternetGateway,
+		SecaRef:     internetGatewayRef(tenant, workspace, "igw"),
+		ProviderRef: string(raw),
+		Status:      "active",
+	}); err != nil {
+		t.Fatalf("save internet gateway: %v", err)
+	}
+	putRouteTable("rt-a", "igw")
+
+	fake := &fakeComputeProvider{getInstance: &hetzner.Instance{Name: "seca-igw-ws-igw"}}
+	cfg := config.Config{InternetGatewayNATVM: true, InternetGatewaySKU: "cax11", InternetGatewayImage: "ubuntu-24.04"}
+	for i := 0; i < 20; i++ {
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			putRouteTable("rt-a")
+			_, err := refreshInternetGatewayFromRouteUsage(ctx, store, fake, cfg, tenant, workspace, "igw")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			putRouteTable("rt-b", "igw")
+			_, err := refreshInternetGatewayFromRouteUsage(ctx, store, fake, cfg, tenant, workspace, "igw")
+			errs <- err
+		}()
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("refresh: %v", err)
+			}
+		}
+		if last := fake.events[len(fake.events)-1]; last != "create seca-igw-ws-igw" {
+			t.Fatalf("round %d: NAT VM torn down while rt-b routes through it: %v", i, fake.events)
+		}
+
+		// Swap back for the next round: rt-a routes through the gateway again.
+		putRouteTable("rt-a", "igw")
+		putRouteTable("rt-b")
+	}
+}
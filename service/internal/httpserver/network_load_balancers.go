@@ -0,0 +1,520 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const resourceBindingKindLoadBalancer = "load-balancer"
+
+// loadBalancerTargetMissing is the health of a target ref whose instance no
+// longer exists. Hetzner drops a deleted server from its load balancers, so
+// the ref stays in the spec and the gap shows up in the status instead.
+const loadBalancerTargetMissing = "missing"
+
+type loadBalancerIterator struct {
+	Items    []loadBalancerResource `json:"items"`
+	Metadata responseMetaObject     `json:"metadata"`
+}
+
+type loadBalancerResource struct {
+	Metadata resourceMetadata   `json:"metadata"`
+	Labels   map[string]string  `json:"labels,omitempty"`
+	Spec     loadBalancerSpec   `json:"spec"`
+	Status   loadBalancerStatus `json:"status"`
+}
+
+type loadBalancerSpec struct {
+	Listeners   []loadBalancerListener   `json:"listeners"`
+	TargetRefs  []refObject              `json:"targetRefs,omitempty"`
+	HealthCheck *loadBalancerHealthCheck `json:"healthCheck,omitempty"`
+	NetworkRef  *refObject               `json:"networkRef,omitempty"`
+}
+
+type loadBalancerListener struct {
+	Protocol        string `json:"protocol"`
+	ListenPort      int    `json:"listenPort"`
+	DestinationPort int    `json:"destinationPort"`
+}
+
+// loadBalancerHealthCheck applies to every listener. A zero port checks the
+// listener's destination port.
+type loadBalancerHealthCheck struct {
+	Protocol        string `json:"protocol,omitempty"`
+	Port            int    `json:"port,omitempty"`
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds  int    `json:"timeoutSeconds,omitempty"`
+	Retries         int    `json:"retries,omitempty"`
+	Path            string `json:"path,omitempty"`
+}
+
+type loadBalancerStatus struct {
+	State      string                     `json:"state"`
+	PublicIPv4 *string                    `json:"publicIPv4,omitempty"`
+	PublicIPv6 *string                    `json:"publicIPv6,omitempty"`
+	PrivateIP  *string                    `json:"privateIP,omitempty"`
+	Targets    []loadBalancerTargetStatus `json:"targets,omitempty"`
+}
+
+type loadBalancerTargetStatus struct {
+	InstanceRef refObject `json:"instanceRef"`
+	Health      string    `json:"health"`
+}
+
+type loadBalancerBindingPayload struct {
+	Name        string            `json:"name"`
+	Region      string            `json:"region"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Spec        loadBalancerSpec  `json:"spec"`
+	ProviderRef string            `json:"providerRef,omitempty"`
+	// The addresses and target health are read from Hetzner on every request
+	// and never stored.
+	PublicIPv4 string                     `json:"-"`
+	PublicIPv6 string                     `json:"-"`
+	PrivateIP  string                     `json:"-"`
+	Targets    []loadBalancerTargetStatus `json:"-"`
+}
+
+func listLoadBalancers(provider NetworkProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		bindings, err := store.ListResourceBindings(r.Context(), tenant, workspace, resourceBindingKindLoadBalancer)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list load balancers", r.URL.Path)
+			return
+		}
+		balancers, err := provider.ListLoadBalancers(ctx)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		byName := make(map[string]hetzner.LoadBalancer, len(balancers))
+		for _, lb := range balancers {
+			if providerLabelsInScope(lb.Labels, tenant, workspace) {
+				byName[lb.Name] = lb
+			}
+		}
+		items := make([]loadBalancerResource, 0, len(bindings))
+		for _, binding := range bindings {
+			payload, err := parseLoadBalancerBinding(binding.ProviderRef)
+			if err != nil {
+				continue
+			}
+			if lb, ok := byName[payload.Name]; ok {
+				applyLoadBalancer(&payload, lb)
+			}
+			items = append(items, toLoadBalancerResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
+		}
+		respondJSON(w, http.StatusOK, loadBalancerIterator{
+			Items:    items,
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/load-balancers", Verb: verbList},
+		})
+	}
+}
+
+func getLoadBalancer(provider NetworkProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "load balancer name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		binding, err := store.GetResourceBinding(r.Context(), loadBalancerRef(tenant, workspace, name))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load load balancer", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "load balancer not found", r.URL.Path)
+			return
+		}
+		payload, err := parseLoadBalancerBinding(binding.ProviderRef)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid load balancer payload", r.URL.Path)
+			return
+		}
+		lb, err := provider.GetLoadBalancer(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if lb != nil && providerLabelsInScope(lb.Labels, tenant, workspace) {
+			applyLoadBalancer(&payload, *lb)
+		}
+		respondJSON(w, http.StatusOK, toLoadBalancerResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
+	}
+}
+
+func putLoadBalancer(provider NetworkProvider, computeProvider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "load balancer name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		var req loadBalancerResource
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
+			return
+		}
+		services, problems := loadBalancerServices(req.Spec)
+		problems = append(problems, reservedLabelProblems(req.Labels)...)
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
+			return
+		}
+		ref := loadBalancerRef(tenant, workspace, name)
+		existing, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load load balancer", r.URL.Path)
+			return
+		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		current, err := provider.GetLoadBalancer(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if current != nil && !providerLabelsInScope(current.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "load balancer name is already in use outside this workspace", r.URL.Path)
+			return
+		}
+		network, ok := resolveLoadBalancerNetwork(ctx, w, r, provider, tenant, workspace, req.Spec.NetworkRef)
+		if !ok {
+			return
+		}
+		targets, ok := resolveLoadBalancerTargets(ctx, w, r, computeProvider, tenant, workspace, req.Spec.TargetRefs)
+		if !ok {
+			return
+		}
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		lb, _, err := provider.CreateOrUpdateLoadBalancer(ctx, hetzner.LoadBalancerCreateRequest{
+			Name:     name,
+			Region:   region,
+			Network:  network,
+			Services: services,
+			Targets:  targets,
+			Labels: withSecaProviderLabels(
+				req.Labels,
+				tenant,
+				workspace,
+				"load-balancer",
+				name,
+				ref,
+			),
+		})
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if lb == nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "provider returned empty load balancer", r.URL.Path)
+			return
+		}
+		payload := loadBalancerBindingPayload{
+			Name:   name,
+			Region: runtimeRegionOrDefault(region),
+			Labels: req.Labels,
+			Spec:   req.Spec,
+		}
+		applyLoadBalancer(&payload, *lb)
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode load balancer", r.URL.Path)
+			return
+		}
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
+			Tenant:      tenant,
+			Workspace:   workspace,
+			Kind:        resourceBindingKindLoadBalancer,
+			SecaRef:     ref,
+			ProviderRef: string(raw),
+			Status:      "active",
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save load balancer", r.URL.Path)
+			return
+		}
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil || binding == nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load load balancer", r.URL.Path)
+			return
+		}
+		stateValue, code := "updating", http.StatusOK
+		if existing == nil {
+			stateValue, code = "creating", http.StatusCreated
+		}
+		respondJSON(w, code, toLoadBalancerResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
+	}
+}
+
+func deleteLoadBalancer(provider NetworkProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, "load balancer name is required")
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
+		ref := loadBalancerRef(tenant, workspace, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load load balancer", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			respondDeleteNotFound(w, r, ref, "load balancer not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
+			return
+		}
+		lb, err := provider.GetLoadBalancer(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if lb != nil && providerLabelsInScope(lb.Labels, tenant, workspace) {
+			if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
+				return
+			}
+			if _, err := provider.DeleteLoadBalancer(ctx, name); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete load balancer", r.URL.Path)
+			return
+		}
+		respondDeleteAccepted(w, "")
+	}
+}
+
+// loadBalancerServices validates the listeners and health check of spec and
+// turns them into one Hetzner service per listener.
+func loadBalancerServices(spec loadBalancerSpec) ([]hetzner.LoadBalancerService, []fieldProblem) {
+	var problems []fieldProblem
+	if len(spec.Listeners) == 0 {
+		problems = append(problems, fieldPointer("/spec/listeners", "spec.listeners must name at least one listener"))
+	}
+	var check hetzner.LoadBalancerHealthCheck
+	if hc := spec.HealthCheck; hc != nil {
+		check = hetzner.LoadBalancerHealthCheck{
+			Protocol: strings.ToLower(strings.TrimSpace(hc.Protocol)),
+			Port:     hc.Port,
+			Interval: time.Duration(hc.IntervalSeconds) * time.Second,
+			Timeout:  time.Duration(hc.TimeoutSeconds) * time.Second,
+			Retries:  hc.Retries,
+			Path:     strings.TrimSpace(hc.Path),
+		}
+		if check.Protocol != "" && check.Protocol != "tcp" && check.Protocol != "http" {
+			problems = append(problems, fieldPointer("/spec/healthCheck/protocol", "spec.healthCheck.protocol must be tcp or http"))
+		}
+		if hc.Port < 0 || hc.Port > 65535 {
+			problems = append(problems, fieldPointer("/spec/healthCheck/port", "spec.healthCheck.port must be between 1 and 65535"))
+		}
+		if hc.IntervalSeconds < 0 || hc.TimeoutSeconds < 0 || hc.Retries < 0 {
+			problems = append(problems, fieldPointer("/spec/healthCheck", "spec.healthCheck intervals and retries must not be negative"))
+		}
+		if check.Path != "" && check.Protocol != "http" {
+			problems = append(problems, fieldPointer("/spec/healthCheck/path", "spec.healthCheck.path requires the http protocol"))
+		}
+	}
+	services := make([]hetzner.LoadBalancerService, 0, len(spec.Listeners))
+	seen := map[int]bool{}
+	for i, listener := range spec.Listeners {
+		protocol := strings.ToLower(strings.TrimSpace(listener.Protocol))
+		if protocol != "tcp" && protocol != "http" {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/listeners/%d/protocol", i), "spec.listeners[%d].protocol must be tcp or http", i))
+		}
+		if listener.ListenPort < 1 || listener.ListenPort > 65535 {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/listeners/%d/listenPort", i), "spec.listeners[%d].listenPort must be between 1 and 65535", i))
+		} else if seen[listener.ListenPort] {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/listeners/%d/listenPort", i), "spec.listeners[%d].listenPort %d is already used by another listener", i, listener.ListenPort))
+		}
+		seen[listener.ListenPort] = true
+		if listener.DestinationPort < 1 || listener.DestinationPort > 65535 {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/listeners/%d/destinationPort", i), "spec.listeners[%d].destinationPort must be between 1 and 65535", i))
+		}
+		services = append(services, hetzner.LoadBalancerService{
+			Protocol:        protocol,
+			ListenPort:      listener.ListenPort,
+			DestinationPort: listener.DestinationPort,
+			HealthCheck:     check,
+		})
+	}
+	return services, problems
+}
+
+// resolveLoadBalancerNetwork returns the Hetzner network the load balancer
+// attaches to, or "" when the spec names none. The network must belong to the
+// workspace.
+func resolveLoadBalancerNetwork(ctx context.Context, w http.ResponseWriter, r *http.Request, provider NetworkProvider, tenant, workspace string, ref *refObject) (string, bool) {
+	if ref == nil {
+		return "", true
+	}
+	name := resourceNameFromRef(ref.Resource)
+	if name == "" {
+		respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/networkRef", "spec.networkRef must name a network"))
+		return "", false
+	}
+	network, err := provider.GetNetwork(ctx, name)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return "", false
+	}
+	if network == nil || !providerLabelsInScope(network.Labels, tenant, workspace) {
+		respondProblemWithSources(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", fmt.Sprintf("network %q not found", name), r.URL.Path, []problemSource{{Pointer: "/spec/networkRef"}})
+		return "", false
+	}
+	return name, true
+}
+
+// resolveLoadBalancerTargets returns the instance names of refs. An instance
+// that does not exist is passed on and reported as missing in the status;
+// one that belongs to another workspace is refused.
+func resolveLoadBalancerTargets(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, tenant, workspace string, refs []refObject) ([]string, bool) {
+	names := make([]string, 0, len(refs))
+	for i, ref := range refs {
+		name := resourceNameFromRef(ref.Resource)
+		if name == "" {
+			respondValidationProblem(w, r.URL.Path, fieldPointer(fmt.Sprintf("/spec/targetRefs/%d", i), "spec.targetRefs[%d] must name an instance", i))
+			return nil, false
+		}
+		instance, err := provider.GetInstance(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return nil, false
+		}
+		if instance != nil && !providerLabelsInScope(instance.Labels, tenant, workspace) {
+			respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("instance %q belongs to another workspace", name), r.URL.Path, []problemSource{{Pointer: fmt.Sprintf("/spec/targetRefs/%d", i)}})
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+func loadBalancerRef(tenant, workspace, name string) string {
+	return "seca.network/v1/tenants/" + strings.ToLower(strings.TrimSpace(tenant)) +
+		"/workspaces/" + strings.ToLower(strings.TrimSpace(workspace)) +
+		"/load-balancers/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+func parseLoadBalancerBinding(raw string) (loadBalancerBindingPayload, error) {
+	var payload loadBalancerBindingPayload
+	err := json.Unmarshal([]byte(raw), &payload)
+	return payload, err
+}
+
+// applyLoadBalancer copies the addresses of lb into payload and reports the
+// health of every target ref; a ref whose instance is not a target of lb is
+// missing.
+func applyLoadBalancer(payload *loadBalancerBindingPayload, lb hetzner.LoadBalancer) {
+	payload.ProviderRef = fmt.Sprintf("hetzner.cloud/load-balancers/%d", lb.ID)
+	if lb.Region != "" {
+		payload.Region = lb.Region
+	}
+	payload.PublicIPv4 = lb.PublicIPv4
+	payload.PublicIPv6 = lb.PublicIPv6
+	payload.PrivateIP = lb.PrivateIP
+	health := make(map[string]string, len(lb.Targets))
+	for _, target := range lb.Targets {
+		health[strings.ToLower(target.ServerName)] = target.Health
+	}
+	payload.Targets = make([]loadBalancerTargetStatus, 0, len(payload.Spec.TargetRefs))
+	for _, ref := range payload.Spec.TargetRefs {
+		status, ok := health[resourceNameFromRef(ref.Resource)]
+		if !ok {
+			status = loadBalancerTargetMissing
+		}
+		payload.Targets = append(payload.Targets, loadBalancerTargetStatus{InstanceRef: ref, Health: status})
+	}
+}
+
+func toLoadBalancerResourceFromBinding(
+	binding state.ResourceBinding,
+	payload loadBalancerBindingPayload,
+	tenant,
+	workspace string,
+	verb resourceVerb,
+	stateValue string,
+) loadBalancerResource {
+	createdAt := catalogTimestamp
+	updatedAt := createdAt
+	if !binding.CreatedAt.IsZero() {
+		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if !binding.UpdatedAt.IsZero() {
+		updatedAt = binding.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return loadBalancerResource{
+		Metadata: resourceMetadata{
+			Name:            payload.Name,
+			Provider:        "seca.network/v1",
+			Resource:        "tenants/" + tenant + "/workspaces/" + workspace + "/load-balancers/" + payload.Name,
+			Verb:            verb,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  updatedAt,
+			ResourceVersion: binding.ResourceVersion,
+			APIVersion:      "v1",
+			Kind:            "load-balancer",
+			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/load-balancers/" + payload.Name,
+			Tenant:          tenant,
+			Workspace:       workspace,
+			Region:          defaultRegion(strings.ToLower(strings.TrimSpace(payload.Region))),
+		},
+		Labels: payload.Labels,
+		Spec:   payload.Spec,
+		Status: loadBalancerStatus{
+			State:      stateValue,
+			PublicIPv4: stringPtrOrNil(payload.PublicIPv4),
+			PublicIPv6: stringPtrOrNil(payload.PublicIPv6),
+			PrivateIP:  stringPtrOrNil(payload.PrivateIP),
+			Targets:    payload.Targets,
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestLoadBalancerServicesApplyHealthCheckToEveryListener(t *testing.T) {
+	t.Parallel()
+
+	services, problems := loadBalancerServices(loadBalancerSpec{
+		Listeners: []loadBalancerListener{
+			{Protocol: "TCP", ListenPort: 443, DestinationPort: 8443},
+			{Protocol: "http", ListenPort: 80, DestinationPort: 8080},
+		},
+		HealthCheck: &loadBalancerHealthCheck{Protocol: "http", IntervalSeconds: 5, Path: "/healthz"},
+	})
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems %+v", problems)
+	}
+	check := hetzner.LoadBalancerHealthCheck{Protocol: "http", Interval: 5 * time.Second, Path: "/healthz"}
+	want := []hetzner.LoadBalancerService{
+		{Protocol: "tcp", ListenPort: 443, DestinationPort: 8443, HealthCheck: check},
+		{Protocol: "http", ListenPort: 80, DestinationPort: 8080, HealthCheck: check},
+	}
+	if !slices.Equal(services, want) {
+		t.Fatalf("expected %+v, got %+v", want, services)
+	}
+}
+
+func TestLoadBalancerServicesRejectInvalidListeners(t *testing.T) {
+	t.Parallel()
+
+	_, problems := loadBalancerServices(loadBalancerSpec{
+		Listeners: []loadBalancerListener{
+			{Protocol: "https", ListenPort: 443, DestinationPort: 443},
+			{Protocol: "tcp", ListenPort: 443, DestinationPort: 0},
+		},
+		HealthCheck: &loadBalancerHealthCheck{Protocol: "tcp", Path: "/"},
+	})
+	var pointers []string
+	for _, problem := range problems {
+		pointers = append(pointers, problem.source.Pointer)
+	}
+	want := []string{
+		"/spec/healthCheck/path",
+		"/spec/listeners/0/protocol",
+		"/spec/listeners/1/listenPort",
+		"/spec/listeners/1/destinationPort",
+	}
+	if !slices.Equal(pointers, want) {
+		t.Fatalf("expected %v, got %v", want, pointers)
+	}
+	if _, problems := loadBalancerServices(loadBalancerSpec{}); len(problems) != 1 || problems[0].source.Pointer != "/spec/listeners" {
+		t.Fatalf("expected a missing listeners problem, got %+v", problems)
+	}
+}
+
+func TestApplyLoadBalancerReportsDeletedTargetsAsMissing(t *testing.T) {
+	t.Parallel()
+
+	payload := loadBalancerBindingPayload{
+		Name: "web",
+		Spec: loadBalancerSpec{TargetRefs: []refObject{{Resource: "instances/web-1"}, {Resource: "instances/web-2"}}},
+	}
+	applyLoadBalancer(&payload, hetzner.LoadBalancer{
+		ID:         12,
+		Region:     "fsn1",
+		PublicIPv4: "203.0.113.12",
+		Targets:    []hetzner.LoadBalancerTarget{{ServerID: 3, ServerName: "web-1", Health: "healthy"}},
+	})
+	if payload.ProviderRef != "hetzner.cloud/load-balancers/12" || payload.Region != "fsn1" || payload.PublicIPv4 != "203.0.113.12" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	want := []loadBalancerTargetStatus{
+		{InstanceRef: refObject{Resource: "instances/web-1"}, Health: "healthy"},
+		{InstanceRef: refObject{Resource: "instances/web-2"}, Health: loadBalancerTargetMissing},
+	}
+	if !slices.Equal(payload.Targets, want) {
+		t.Fatalf("expected %+v, got %+v", want, payload.Targets)
+	}
+}
@@ -2,21 +2,24 @@ package httpserver
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
-	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
 )
 
+// resourceBindingKindNetwork records a network the proxy has served. The
+// network itself lives only in Hetzner; the binding remembers when the proxy
+// last changed it, which Hetzner does not report.
+const resourceBindingKindNetwork = "network"
+
 func listNetworksProvider(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
@@ -41,33 +44,36 @@ func listNetworksProvider(provider NetworkProvider, store *state.Store) http.Han
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list network route table refs", r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
+		boundNames := boundNamesByID(r.Context(), store, tenant, workspace, resourceBindingKindNetwork, hetzner.ResourceNetwork)
+		listed := make([]hetzner.Network, 0, len(items))
+		bindings := make([]state.ResourceBinding, 0, len(items))
+		for _, item := range items {
+			name, ok := boundNames.listed(item.ID, item.Name)
+			if !ok {
+				continue
+			}
+			item.Name = name
+			listed = append(listed, item)
+			bindings = append(bindings, networkBinding(tenant, workspace, item.Name, item.ID))
+		}
+		items = listed
+		stored, err := store.SyncResourceBindings(r.Context(), tenant, workspace, resourceBindingKindNetwork, bindings)
+		if err != nil {
+			tracing.Logf(ctx, "list networks of %s/%s: %v", tenant, workspace, err)
+		}
 		out := make([]networkResource, 0, len(items))
 		for _, item := range items {
-			out = append(out, toProviderNetworkResource(item, tenant, workspace, workspaceRegion, routeRefs[item.Name], http.MethodGet, "active", now))
+			resource := toProviderNetworkResource(item, tenant, workspace, workspaceRegion, routeRefs[item.Name], verbList, "active")
+			stampLastModified(&resource.Metadata, storedBinding(stored, networkBindingRef(tenant, workspace, item.Name)))
+			out = append(out, resource)
 		}
 		respondJSON(w, http.StatusOK, networkIterator{
 			Items:    out,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks", Verb: verbList},
 		})
 	}
 }
 
-func networkCRUDProvider(provider NetworkProvider, store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getNetworkProvider(provider, store)(w, r)
-		case http.MethodPut:
-			putNetworkProvider(provider, store)(w, r)
-		case http.MethodDelete:
-			deleteNetworkProvider(provider, store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getNetworkProvider(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "network name is required")
@@ -97,8 +103,14 @@ func getNetworkProvider(provider NetworkProvider, store *state.Store) http.Handl
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load network route table ref", r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, toProviderNetworkResource(*item, tenant, workspace, workspaceRegion, routeRef, http.MethodGet, "active", now))
+		binding, err := store.SyncResourceBinding(r.Context(), networkBinding(tenant, workspace, name, item.ID))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save network binding", r.URL.Path)
+			return
+		}
+		resource := toProviderNetworkResource(*item, tenant, workspace, workspaceRegion, routeRef, verbGet, "active")
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, http.StatusOK, resource)
 	}
 }
 
@@ -108,37 +120,79 @@ func putNetworkProvider(provider NetworkProvider, store *state.Store) http.Handl
 		if !ok {
 			return
 		}
+		dryRun, ok := dryRunFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
 
 		var req networkResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
 			return
 		}
+		var problems []fieldProblem
 		if strings.TrimSpace(req.Spec.SkuRef.Resource) == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.skuRef is required", r.URL.Path)
-			return
+			problems = append(problems, fieldPointer("/spec/skuRef/resource", "spec.skuRef is required"))
 		}
+		var ipRange *net.IPNet
 		if req.Spec.Cidr.IPv4 == nil || strings.TrimSpace(*req.Spec.Cidr.IPv4) == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.cidr.ipv4 is required", r.URL.Path)
+			problems = append(problems, fieldPointer("/spec/cidr/ipv4", "spec.cidr.ipv4 is required"))
+		} else if _, parsed, err := net.ParseCIDR(strings.TrimSpace(*req.Spec.Cidr.IPv4)); err != nil {
+			problems = append(problems, fieldPointer("/spec/cidr/ipv4", "spec.cidr.ipv4 must be a CIDR range"))
+		} else {
+			ipRange = parsed
+		}
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
 			return
 		}
-		workspaceRegion, ok := workspaceRegionOrDefault(r.Context(), store, tenant, workspace)
+		workspaceRegion, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
 		if !ok {
-			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve workspace", r.URL.Path)
 			return
 		}
-		responseRegion := workspaceRegion
-		if reqRegion := strings.TrimSpace(req.Metadata.Region); reqRegion != "" {
-			responseRegion = strings.ToLower(reqRegion)
+		existing, err := provider.GetNetwork(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if existing != nil {
+			violation, err := findImmutableFieldViolation("network",
+				networkSpec{Cidr: networkCIDR{IPv4: stringPtrOrNil(existing.CIDR)}},
+				networkSpec{Cidr: req.Spec.Cidr},
+			)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if violation != nil {
+				respondImmutableFieldViolation(w, *violation, r.URL.Path)
+				return
+			}
+		} else {
+			if problem := hetzner.NetworkRangeProblem(ipRange); problem != "" {
+				respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/cidr/ipv4", "spec.cidr.ipv4: %s", problem))
+				return
+			}
+			overlap, err := overlappingNetwork(ctx, provider, tenant, workspace, name, ipRange)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if overlap != nil {
+				ref := "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + overlap.Name
+				detail := fmt.Sprintf("spec.cidr.ipv4 %s overlaps %s of network %s", ipRange, overlap.CIDR, ref)
+				respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", detail, r.URL.Path, []problemSource{{Pointer: "/spec/cidr/ipv4"}, {Parameter: ref}})
+				return
+			}
 		}
 
-		item, created, err := provider.CreateOrUpdateNetwork(ctx, hetzner.NetworkCreateRequest{
+		createReq := hetzner.NetworkCreateRequest{
 			Name:   name,
 			CIDR:   strings.TrimSpace(*req.Spec.Cidr.IPv4),
+			Region: workspaceRegion,
 			Labels: withSecaProviderLabels(
 				req.Labels,
 				tenant,
@@ -147,7 +201,18 @@ func putNetworkProvider(provider NetworkProvider, store *state.Store) http.Handl
 				name,
 				"seca.network/v1/tenants/"+tenant+"/workspaces/"+workspace+"/networks/"+name,
 			),
-		})
+		}
+		routeRef := strings.TrimSpace(req.Spec.RouteTableRef.Resource)
+		if dryRun {
+			item, created, err := provider.ValidateNetworkCreate(ctx, createReq)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			respondJSON(w, http.StatusOK, toProviderNetworkResource(*item, tenant, workspace, workspaceRegion, routeRef, upsertVerb(created), dryRunState))
+			return
+		}
+		item, created, err := provider.CreateOrUpdateNetwork(ctx, createReq)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -156,7 +221,6 @@ func putNetworkProvider(provider NetworkProvider, store *state.Store) http.Handl
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "provider returned empty network", r.URL.Path)
 			return
 		}
-		routeRef := strings.TrimSpace(req.Spec.RouteTableRef.Resource)
 		if routeRef != "" {
 			if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
 				Tenant:      tenant,
@@ -172,45 +236,103 @@ func putNetworkProvider(provider NetworkProvider, store *state.Store) http.Handl
 		} else {
 			_ = store.DeleteResourceBinding(r.Context(), networkRouteTableRefKey(tenant, workspace, name))
 		}
+		binding, err := store.UpsertResourceBindingIfVersion(r.Context(), networkBinding(tenant, workspace, name, item.ID), 0)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save network binding", r.URL.Path)
+			return
+		}
 		stateValue, code := upsertStateAndCode(created)
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, code, toProviderNetworkResource(*item, tenant, workspace, responseRegion, routeRef, http.MethodPut, stateValue, now))
+		resource := toProviderNetworkResource(*item, tenant, workspace, workspaceRegion, routeRef, upsertVerb(created), stateValue)
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, code, resource)
 	}
 }
 
-func deleteNetworkProvider(provider NetworkProvider, store *state.Store) http.HandlerFunc {
+func deleteNetworkProvider(provider NetworkProvider, computeProvider ComputeStorageProvider, store *state.Store, cfg config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "network name is required")
 		if !ok {
 			return
 		}
+		force, ok := forceFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
+		dependents, err := networkDependents(r.Context(), store, tenant, workspace, name)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve network dependents", r.URL.Path)
+			return
+		}
+		if len(dependents) > 0 {
+			if !force {
+				respondDependentsConflict(w, r, "network "+name, dependents)
+				return
+			}
+			gateways, err := cascadeDependents(ctx, store, computeProvider, tenant, workspace, dependents)
+			if err == nil && len(gateways) > 0 {
+				err = refreshInternetGateways(ctx, store, computeProvider, cfg, tenant, workspace, gateways)
+			}
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
 		deleted, err := provider.DeleteNetwork(ctx, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if !deleted {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "network not found", r.URL.Path)
+			respondDeleteNotFound(w, r, networkRef(tenant, workspace, name), "network not found")
 			return
 		}
 		_ = store.DeleteResourceBinding(r.Context(), networkRouteTableRefKey(tenant, workspace, name))
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		_ = store.DeleteResourceBinding(r.Context(), networkBindingRef(tenant, workspace, name))
+		respondDeleteAccepted(w, "")
+	}
+}
+
+// overlappingNetwork returns a network of the workspace, other than name,
+// whose range overlaps ipRange. Only networks carrying the workspace's
+// provider labels count; Hetzner itself allows overlapping networks.
+func overlappingNetwork(ctx context.Context, provider NetworkProvider, tenant, workspace, name string, ipRange *net.IPNet) (*hetzner.Network, error) {
+	networks, err := provider.ListNetworksByLabels(ctx, map[string]string{
+		secaLabelManaged:   "true",
+		secaLabelTenant:    compactLabelValue(tenant),
+		secaLabelWorkspace: compactLabelValue(workspace),
+	})
+	if err != nil {
+		return nil, err
 	}
+	for _, network := range networks {
+		if strings.EqualFold(network.Name, name) {
+			continue
+		}
+		_, other, err := net.ParseCIDR(strings.TrimSpace(network.CIDR))
+		if err != nil {
+			continue
+		}
+		if other.Contains(ipRange.IP) || ipRange.Contains(other.IP) {
+			return &network, nil
+		}
+	}
+	return nil, nil
 }
 
-func toProviderNetworkResource(item hetzner.Network, tenant, workspace, region, routeTableRef, verb, state, now string) networkResource {
+func toProviderNetworkResource(item hetzner.Network, tenant, workspace, region, routeTableRef string, verb resourceVerb, state string) networkResource {
+	createdAt := providerTimestamp(item.CreatedAt)
 	return networkResource{
 		Metadata: resourceMetadata{
 			Name:            item.Name,
 			Provider:        "seca.network/v1",
 			Resource:        "tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + item.Name,
 			Verb:            verb,
-			CreatedAt:       now,
-			LastModifiedAt:  now,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  createdAt,
 			ResourceVersion: 1,
 			APIVersion:      "v1",
 			Kind:            "network",
@@ -232,6 +354,7 @@ func toProviderNetworkResource(item hetzner.Network, tenant, workspace, region,
 			Cidr: networkCIDR{
 				IPv4: stringPtrOrNil(item.CIDR),
 			},
+			Zone: item.Zone,
 		},
 	}
 }
@@ -247,6 +370,32 @@ func workspaceRegionOrDefault(ctx context.Context, store *state.Store, tenant, w
 	return defaultRegion(strings.ToLower(strings.TrimSpace(ws.Region))), true
 }
 
+// resourceRegion returns the region a workspace resource is placed in,
+// which is always the workspace's. A metadata.region naming another region
+// answers 409; an empty one or one differing only in case is accepted.
+func resourceRegion(w http.ResponseWriter, r *http.Request, store *state.Store, tenant, workspace, requested string) (string, bool) {
+	workspaceRegion, ok := workspaceRegionOrDefault(r.Context(), store, tenant, workspace)
+	if !ok {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve workspace", r.URL.Path)
+		return "", false
+	}
+	if detail := regionOverrideConflict(requested, workspaceRegion); detail != "" {
+		respondProblemWithSources(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", detail, r.URL.Path, []problemSource{{Pointer: "/metadata/region"}})
+		return "", false
+	}
+	return workspaceRegion, true
+}
+
+// regionOverrideConflict describes why requested cannot be used in a
+// workspace of workspaceRegion, or returns "" when it can.
+func regionOverrideConflict(requested, workspaceRegion string) string {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || strings.EqualFold(requested, workspaceRegion) {
+		return ""
+	}
+	return fmt.Sprintf("metadata.region %q does not match the workspace region %q", requested, workspaceRegion)
+}
+
 func getNetworkRouteTableRef(ctx context.Context, store *state.Store, tenant, workspace, network string) (string, error) {
 	binding, err := store.GetResourceBinding(ctx, networkRouteTableRefKey(tenant, workspace, network))
 	if err != nil || binding == nil {
@@ -285,6 +434,23 @@ func networkNameFromRouteTableRefBinding(secaRef string) string {
 	return ""
 }
 
+func networkBindingRef(tenant, workspace, name string) string {
+	return "seca.network/v1/tenants/" + strings.ToLower(strings.TrimSpace(tenant)) +
+		"/workspaces/" + strings.ToLower(strings.TrimSpace(workspace)) +
+		"/networks/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+func networkBinding(tenant, workspace, name string, id int64) state.ResourceBinding {
+	return state.ResourceBinding{
+		Tenant:      tenant,
+		Workspace:   workspace,
+		Kind:        resourceBindingKindNetwork,
+		SecaRef:     networkBindingRef(tenant, workspace, name),
+		ProviderRef: networkProviderRef(id, name),
+		Status:      "active",
+	}
+}
+
 func stringPtrOrNil(s string) *string {
 	trimmed := strings.TrimSpace(s)
 	if trimmed == "" {
@@ -0,0 +1,200 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// nicPublicIPNames returns the public IPs named by spec.publicIpRefs.
+func nicPublicIPNames(spec nicSpec) []string {
+	if spec.PublicIPRefs == nil {
+		return nil
+	}
+	names := make([]string, 0, len(*spec.PublicIPRefs))
+	for _, ref := range *spec.PublicIPRefs {
+		if name := resourceNameFromRef(ref.Resource); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkNICPublicIPs verifies that the public IPs a NIC references exist in
+// the workspace and, when the NIC has an instance, are not routed to another
+// server. An IP still routed to the instance the previous version of the NIC
+// bound it to is released by the update and does not conflict.
+func checkNICPublicIPs(
+	w http.ResponseWriter,
+	r *http.Request,
+	ctx context.Context,
+	provider ComputeStorageProvider,
+	networkProvider NetworkProvider,
+	tenant, workspace string,
+	spec nicSpec,
+	instance *hetzner.Instance,
+	previous *nicBindingPayload,
+) bool {
+	var previousNames []string
+	var previousInstance *hetzner.Instance
+	if previous != nil {
+		previousNames = nicPublicIPNames(previous.Spec)
+		if name := nicInstanceName(previous.Spec); name != "" && len(previousNames) > 0 {
+			var err error
+			if previousInstance, err = getWorkspaceInstance(ctx, provider, tenant, workspace, name); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return false
+			}
+		}
+	}
+	for _, name := range nicPublicIPNames(spec) {
+		ip, err := networkProvider.GetPublicIP(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return false
+		}
+		if ip == nil || !providerLabelsInScope(ip.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", fmt.Sprintf("public ip %q referenced by spec.publicIpRefs not found", name), r.URL.Path)
+			return false
+		}
+		if instance == nil || ip.ServerID == 0 || ip.ServerID == instance.ID {
+			continue
+		}
+		if previousInstance != nil && previousInstance.ID == ip.ServerID && slices.Contains(previousNames, name) {
+			continue
+		}
+		holder, err := publicIPHolderRef(ctx, provider, tenant, workspace, ip.ServerID)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return false
+		}
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("public ip %q is already assigned to %s", name, holder), r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// assignNICPublicIPs releases the floating IPs the previous version of the
+// NIC bound and the new one no longer does, then routes the referenced ones
+// to the NIC's instance.
+func assignNICPublicIPs(ctx context.Context, provider ComputeStorageProvider, networkProvider NetworkProvider, store *state.Store, tenant, workspace, ref string, payload nicBindingPayload, previous *nicBindingPayload) error {
+	instanceName := nicInstanceName(payload.Spec)
+	if previous != nil {
+		var keep []string
+		if nicInstanceName(previous.Spec) == instanceName {
+			keep = nicPublicIPNames(payload.Spec)
+		}
+		if err := releaseNICPublicIPs(ctx, provider, networkProvider, store, tenant, workspace, ref, *previous, keep); err != nil {
+			return err
+		}
+	}
+	if instanceName == "" {
+		return nil
+	}
+	for _, name := range nicPublicIPNames(payload.Spec) {
+		if err := networkProvider.AssignPublicIP(ctx, name, instanceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseNICPublicIPs unassigns the floating IPs payload routes to its
+// instance, except those in keep and those another NIC of the workspace still
+// binds to the same instance. IPs that moved elsewhere are left alone.
+func releaseNICPublicIPs(ctx context.Context, provider ComputeStorageProvider, networkProvider NetworkProvider, store *state.Store, tenant, workspace, ref string, payload nicBindingPayload, keep []string) error {
+	instanceName := nicInstanceName(payload.Spec)
+	names := slices.DeleteFunc(nicPublicIPNames(payload.Spec), func(name string) bool { return slices.Contains(keep, name) })
+	if instanceName == "" || len(names) == 0 {
+		return nil
+	}
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindNIC)
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		if binding.SecaRef == ref {
+			continue
+		}
+		other, err := parseNICBinding(binding.ProviderRef)
+		if err != nil || nicInstanceName(other.Spec) != instanceName {
+			continue
+		}
+		otherNames := nicPublicIPNames(other.Spec)
+		names = slices.DeleteFunc(names, func(name string) bool { return slices.Contains(otherNames, name) })
+	}
+	instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, instanceName)
+	if err != nil || instance == nil {
+		return err
+	}
+	for _, name := range names {
+		ip, err := networkProvider.GetPublicIP(ctx, name)
+		if err != nil {
+			return err
+		}
+		if ip == nil || ip.ServerID != instance.ID {
+			continue
+		}
+		if err := networkProvider.UnassignPublicIP(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publicIPHolderRef names the server a floating IP is routed to: the instance
+// ref when the server belongs to the workspace, its Hetzner ref otherwise.
+func publicIPHolderRef(ctx context.Context, provider ComputeStorageProvider, tenant, workspace string, serverID int64) (string, error) {
+	instances, err := provider.ListInstances(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, instance := range instances {
+		if instance.ID == serverID && providerLabelsInScope(instance.Labels, tenant, workspace) {
+			return computeInstanceRef(tenant, workspace, instance.Name), nil
+		}
+	}
+	return serverProviderRef(serverID, ""), nil
+}
+
+// publicIPAssignments maps each public IP a NIC of the workspace binds to an
+// instance to that instance's ref.
+func publicIPAssignments(ctx context.Context, store *state.Store, tenant, workspace string) (map[string]string, error) {
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindNIC)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, binding := range bindings {
+		payload, err := parseNICBinding(binding.ProviderRef)
+		if err != nil {
+			continue
+		}
+		instanceName := nicInstanceName(payload.Spec)
+		if instanceName == "" {
+			continue
+		}
+		for _, name := range nicPublicIPNames(payload.Spec) {
+			out[name] = computeInstanceRef(tenant, workspace, instanceName)
+		}
+	}
+	return out, nil
+}
+
+// applyPublicIPAssignment sets the status assignedTo of a floating IP routed
+// to a server, preferring the instance a NIC bound it to.
+func applyPublicIPAssignment(payload *publicIPBindingPayload, ip hetzner.PublicIP, assignments map[string]string) {
+	if ip.ServerID == 0 {
+		payload.AssignedTo = ""
+		return
+	}
+	if ref, ok := assignments[payload.Name]; ok {
+		payload.AssignedTo = ref
+		return
+	}
+	payload.AssignedTo = serverProviderRef(ip.ServerID, "")
+}
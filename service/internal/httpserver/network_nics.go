@@ -1,11 +1,15 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
 
@@ -27,25 +31,25 @@ type nicSpec struct {
 	Addresses    []string     `json:"addresses,omitempty"`
 	PublicIPRefs *[]refObject `json:"publicIpRefs,omitempty"`
 	SubnetRef    refObject    `json:"subnetRef"`
+	InstanceRef  *refObject   `json:"instanceRef,omitempty"`
 }
 
 type nicStatusObject struct {
-	State string `json:"state"`
+	State     string   `json:"state"`
+	Addresses []string `json:"addresses,omitempty"`
 }
 
 type nicBindingPayload struct {
-	Name   string            `json:"name"`
-	Region string            `json:"region"`
-	Labels map[string]string `json:"labels,omitempty"`
-	Spec   nicSpec           `json:"spec"`
+	Name    string            `json:"name"`
+	Region  string            `json:"region"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Spec    nicSpec           `json:"spec"`
+	Network string            `json:"network,omitempty"`
+	Address string            `json:"address,omitempty"`
 }
 
 func listNICs(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
@@ -64,30 +68,15 @@ func listNICs(store *state.Store) http.HandlerFunc {
 			if err != nil {
 				continue
 			}
-			items = append(items, toNICResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			items = append(items, toNICResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, nicIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/nics", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/nics", Verb: verbList},
 		})
 	}
 }
 
-func nicCRUD(store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getNIC(store)(w, r)
-		case http.MethodPut:
-			putNIC(store)(w, r)
-		case http.MethodDelete:
-			deleteNIC(store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getNIC(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "nic name is required")
@@ -112,26 +101,30 @@ func getNIC(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid nic payload", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusOK, toNICResourceFromBinding(*binding, payload, tenant, workspace, http.MethodGet, "active"))
+		respondJSON(w, http.StatusOK, toNICResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
-func putNIC(store *state.Store) http.HandlerFunc {
+func putNIC(provider ComputeStorageProvider, networkProvider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "nic name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
 			return
 		}
 		var req nicResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
 			return
 		}
 		if strings.TrimSpace(req.Spec.SubnetRef.Resource) == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.subnetRef is required", r.URL.Path)
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/subnetRef/resource", "spec.subnetRef is required"))
 			return
 		}
 		ref := nicRef(tenant, workspace, name)
@@ -140,25 +133,91 @@ func putNIC(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load nic", r.URL.Path)
 			return
 		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		var previous *nicBindingPayload
+		if existing != nil {
+			if parsed, parseErr := parseNICBinding(existing.ProviderRef); parseErr == nil {
+				previous = &parsed
+			}
+		}
+		subnet, err := resolveNICSubnet(r.Context(), store, tenant, workspace, req.Spec.SubnetRef.Resource)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve subnet", r.URL.Path)
+			return
+		}
+		if subnet == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "subnet referenced by spec.subnetRef not found", r.URL.Path)
+			return
+		}
+		requestedAddress, err := validateNICAddresses(req.Spec.Addresses, subnet.Spec)
+		if err != nil {
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/addresses", "%s", err.Error()))
+			return
+		}
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
 		payload := nicBindingPayload{
-			Name:   name,
-			Region: runtimeRegionOrDefault(req.Metadata.Region),
-			Labels: req.Labels,
-			Spec:   req.Spec,
+			Name:    name,
+			Region:  region,
+			Labels:  req.Labels,
+			Spec:    req.Spec,
+			Network: subnet.Network,
+		}
+		instanceName := nicInstanceName(req.Spec)
+		var instance *hetzner.Instance
+		if instanceName != "" {
+			instance, err = getWorkspaceInstance(ctx, provider, tenant, workspace, instanceName)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if instance == nil {
+				respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance referenced by spec.instanceRef not found", r.URL.Path)
+				return
+			}
+		}
+		if !checkNICPublicIPs(w, r, ctx, provider, networkProvider, tenant, workspace, req.Spec, instance, previous) {
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		if instance != nil {
+			if _, _, err := provider.AttachInstanceToNetworkWithIP(ctx, instanceName, subnet.Network, requestedAddress); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			address, err := provider.GetInstancePrivateIPv4(ctx, instanceName, subnet.Network)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			payload.Address = address
+		}
+		if err := assignNICPublicIPs(ctx, provider, networkProvider, store, tenant, workspace, ref, payload, previous); err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
 		}
 		raw, err := json.Marshal(payload)
 		if err != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode nic", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        resourceBindingKindNIC,
 			SecaRef:     ref,
 			ProviderRef: string(raw),
 			Status:      "active",
-		}); err != nil {
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save nic", r.URL.Path)
 			return
 		}
@@ -171,17 +230,22 @@ func putNIC(store *state.Store) http.HandlerFunc {
 		if existing == nil {
 			stateValue, code = "creating", http.StatusCreated
 		}
-		respondJSON(w, code, toNICResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toNICResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
 	}
 }
 
-func deleteNIC(store *state.Store) http.HandlerFunc {
+func deleteNIC(provider ComputeStorageProvider, networkProvider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "nic name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
 			return
 		}
 		ref := nicRef(tenant, workspace, name)
@@ -191,14 +255,33 @@ func deleteNIC(store *state.Store) http.HandlerFunc {
 			return
 		}
 		if binding == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "nic not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "nic not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
 			return
 		}
-		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+		if payload, parseErr := parseNICBinding(binding.ProviderRef); parseErr == nil {
+			if err := releaseNICPublicIPs(ctx, provider, networkProvider, store, tenant, workspace, ref, payload, nil); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if err := detachUnreferencedNICNetwork(ctx, provider, store, tenant, workspace, ref, payload); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete nic", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -208,6 +291,106 @@ func nicRef(tenant, workspace, name string) string {
 		"/nics/" + strings.ToLower(strings.TrimSpace(name))
 }
 
+func nicInstanceName(spec nicSpec) string {
+	if spec.InstanceRef == nil {
+		return ""
+	}
+	return resourceNameFromRef(spec.InstanceRef.Resource)
+}
+
+// resolveNICSubnet finds the subnet binding a NIC points at. Fully qualified
+// references name the network directly; short "subnets/<name>" references are
+// matched against every subnet in the workspace.
+func resolveNICSubnet(ctx context.Context, store *state.Store, tenant, workspace, subnetRef string) (*subnetBindingPayload, error) {
+	subnetName := resourceNameFromRef(subnetRef)
+	if subnetName == "" {
+		return nil, nil
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSpace(subnetRef), "/"), "/")
+	for i := 0; i+3 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "networks") && strings.EqualFold(parts[i+2], "subnets") {
+			binding, err := store.GetResourceBinding(ctx, subnetRefKey(tenant, workspace, parts[i+1], subnetName))
+			if err != nil || binding == nil {
+				return nil, err
+			}
+			payload, err := parseSubnetBinding(binding.ProviderRef)
+			if err != nil {
+				return nil, err
+			}
+			return &payload, nil
+		}
+	}
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindSubnet)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindings {
+		payload, err := parseSubnetBinding(binding.ProviderRef)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(payload.Name, subnetName) {
+			return &payload, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateNICAddresses checks spec.addresses against the subnet CIDR and
+// returns the address to request from Hetzner. A server holds a single
+// private address per network, so at most one address is accepted.
+func validateNICAddresses(addresses []string, subnet subnetSpec) (string, error) {
+	if len(addresses) == 0 {
+		return "", nil
+	}
+	if len(addresses) > 1 {
+		return "", fmt.Errorf("spec.addresses supports a single private ipv4 address")
+	}
+	raw := strings.TrimSpace(addresses[0])
+	ip := net.ParseIP(raw)
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("spec.addresses[0] %q is not a valid ipv4 address", raw)
+	}
+	if subnet.Cidr.IPv4 != nil && strings.TrimSpace(*subnet.Cidr.IPv4) != "" {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(*subnet.Cidr.IPv4))
+		if err == nil && !cidr.Contains(ip) {
+			return "", fmt.Errorf("spec.addresses[0] %q is outside subnet cidr %s", raw, cidr)
+		}
+	}
+	return ip.String(), nil
+}
+
+// detachUnreferencedNICNetwork detaches the NIC's instance from its network
+// unless another NIC in the workspace still binds the same pair.
+func detachUnreferencedNICNetwork(ctx context.Context, provider ComputeStorageProvider, store *state.Store, tenant, workspace, ref string, payload nicBindingPayload) error {
+	instanceName := nicInstanceName(payload.Spec)
+	if instanceName == "" || payload.Network == "" {
+		return nil
+	}
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, resourceBindingKindNIC)
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		if binding.SecaRef == ref {
+			continue
+		}
+		other, err := parseNICBinding(binding.ProviderRef)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(other.Network, payload.Network) && nicInstanceName(other.Spec) == instanceName {
+			return nil
+		}
+	}
+	instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, instanceName)
+	if err != nil || instance == nil {
+		return err
+	}
+	_, err = provider.DetachInstanceFromNetwork(ctx, instanceName, payload.Network)
+	return err
+}
+
 func parseNICBinding(raw string) (nicBindingPayload, error) {
 	var payload nicBindingPayload
 	err := json.Unmarshal([]byte(raw), &payload)
@@ -218,11 +401,11 @@ func toNICResourceFromBinding(
 	binding state.ResourceBinding,
 	payload nicBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) nicResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -238,7 +421,7 @@ func toNICResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "nic",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/nics/" + payload.Name,
@@ -248,7 +431,13 @@ func toNICResourceFromBinding(
 		},
 		Labels: payload.Labels,
 		Spec:   payload.Spec,
-		Status: nicStatusObject{State: stateValue},
+		Status: nicStatusObject{State: stateValue, Addresses: nicStatusAddresses(payload)},
 	}
 }
 
+func nicStatusAddresses(payload nicBindingPayload) []string {
+	if payload.Address == "" {
+		return nil
+	}
+	return []string{payload.Address}
+}
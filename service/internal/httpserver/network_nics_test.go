@@ -0,0 +1,24 @@
+package httpserver
+
+import "testing"
+
+func TestValidateNICAddressesChecksSubnetCIDR(t *testing.T) {
+	t.Parallel()
+
+	cidr := "10.10.1.0/24"
+	subnet := subnetSpec{Cidr: networkCIDR{IPv4: &cidr}}
+
+	address, err := validateNICAddresses([]string{" 10.10.1.20 "}, subnet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if address != "10.10.1.20" {
+		t.Fatalf("unexpected address: %q", address)
+	}
+	if _, err := validateNICAddresses([]string{"10.10.2.20"}, subnet); err == nil {
+		t.Fatalf("expected address outside subnet to be rejected")
+	}
+	if _, err := validateNICAddresses([]string{"10.10.1.20", "10.10.1.21"}, subnet); err == nil {
+		t.Fatalf("expected multiple addresses to be rejected")
+	}
+}
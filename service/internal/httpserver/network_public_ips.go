@@ -2,10 +2,12 @@ package httpserver
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
 
@@ -29,27 +31,35 @@ type publicIPSpec struct {
 }
 
 type publicIPStatusObject struct {
-	State string `json:"state"`
+	State      string            `json:"state"`
+	Address    *string           `json:"address,omitempty"`
+	AssignedTo *refObject        `json:"assignedTo,omitempty"`
+	Conditions []statusCondition `json:"conditions,omitempty"`
 }
 
 type publicIPBindingPayload struct {
-	Name   string            `json:"name"`
-	Region string            `json:"region"`
-	Labels map[string]string `json:"labels,omitempty"`
-	Spec   publicIPSpec      `json:"spec"`
+	Name        string            `json:"name"`
+	Region      string            `json:"region"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Spec        publicIPSpec      `json:"spec"`
+	Address     string            `json:"address,omitempty"`
+	ProviderRef string            `json:"providerRef,omitempty"`
+	// RequestedRegion is the region the caller asked for when Hetzner
+	// allocated the address in another one.
+	RequestedRegion string `json:"requestedRegion,omitempty"`
+	// AssignedTo is the server the floating IP is routed to. It is read from
+	// Hetzner on every request and never stored.
+	AssignedTo string `json:"-"`
 }
 
-func listPublicIPs(store *state.Store) http.HandlerFunc {
+func listPublicIPs(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
 			return
 		}
 		bindings, err := store.ListResourceBindings(r.Context(), tenant, workspace, resourceBindingKindPublicIP)
@@ -57,43 +67,49 @@ func listPublicIPs(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list public ips", r.URL.Path)
 			return
 		}
+		allocated, err := provider.ListPublicIPs(ctx)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		assignments, err := publicIPAssignments(r.Context(), store, tenant, workspace)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list nics", r.URL.Path)
+			return
+		}
+		byName := make(map[string]hetzner.PublicIP, len(allocated))
+		for _, ip := range allocated {
+			if providerLabelsInScope(ip.Labels, tenant, workspace) {
+				byName[ip.Name] = ip
+			}
+		}
 		items := make([]publicIPResource, 0, len(bindings))
 		for _, binding := range bindings {
 			payload, err := parsePublicIPBinding(binding.ProviderRef)
 			if err != nil {
 				continue
 			}
-			items = append(items, toPublicIPResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			if ip, ok := byName[payload.Name]; ok {
+				applyAllocatedPublicIP(&payload, ip)
+				applyPublicIPAssignment(&payload, ip, assignments)
+			}
+			items = append(items, toPublicIPResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, publicIPIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/public-ips", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/public-ips", Verb: verbList},
 		})
 	}
 }
 
-func publicIPCRUD(store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getPublicIP(store)(w, r)
-		case http.MethodPut:
-			putPublicIP(store)(w, r)
-		case http.MethodDelete:
-			deletePublicIP(store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
-func getPublicIP(store *state.Store) http.HandlerFunc {
+func getPublicIP(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "public ip name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
 			return
 		}
 		ref := publicIPRef(tenant, workspace, name)
@@ -111,26 +127,44 @@ func getPublicIP(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid public ip payload", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusOK, toPublicIPResourceFromBinding(*binding, payload, tenant, workspace, http.MethodGet, "active"))
+		allocated, err := provider.GetPublicIP(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if allocated != nil && providerLabelsInScope(allocated.Labels, tenant, workspace) {
+			applyAllocatedPublicIP(&payload, *allocated)
+			assignments, err := publicIPAssignments(r.Context(), store, tenant, workspace)
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list nics", r.URL.Path)
+				return
+			}
+			applyPublicIPAssignment(&payload, *allocated, assignments)
+		}
+		respondJSON(w, http.StatusOK, toPublicIPResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
-func putPublicIP(store *state.Store) http.HandlerFunc {
+func putPublicIP(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "public ip name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
 			return
 		}
 		var req publicIPResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
 			return
 		}
 		if strings.TrimSpace(req.Spec.Version) == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.version is required", r.URL.Path)
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/version", "spec.version is required"))
 			return
 		}
 		ref := publicIPRef(tenant, workspace, name)
@@ -139,25 +173,75 @@ func putPublicIP(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load public ip", r.URL.Path)
 			return
 		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		current, err := provider.GetPublicIP(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if current != nil && !providerLabelsInScope(current.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "public ip name is already in use outside this workspace", r.URL.Path)
+			return
+		}
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		allocated, _, err := provider.CreateOrUpdatePublicIP(ctx, hetzner.PublicIPCreateRequest{
+			Name:    name,
+			Version: req.Spec.Version,
+			Region:  region,
+			Labels: withSecaProviderLabels(
+				req.Labels,
+				tenant,
+				workspace,
+				"public-ip",
+				name,
+				publicIPRef(tenant, workspace, name),
+			),
+		})
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if allocated == nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "provider returned empty public ip", r.URL.Path)
+			return
+		}
 		payload := publicIPBindingPayload{
 			Name:   name,
-			Region: runtimeRegionOrDefault(req.Metadata.Region),
+			Region: runtimeRegionOrDefault(region),
 			Labels: req.Labels,
 			Spec:   req.Spec,
 		}
+		applyAllocatedPublicIP(&payload, *allocated)
+		assignments, err := publicIPAssignments(r.Context(), store, tenant, workspace)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list nics", r.URL.Path)
+			return
+		}
+		applyPublicIPAssignment(&payload, *allocated, assignments)
 		raw, err := json.Marshal(payload)
 		if err != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode public ip", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        resourceBindingKindPublicIP,
 			SecaRef:     ref,
 			ProviderRef: string(raw),
 			Status:      "active",
-		}); err != nil {
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save public ip", r.URL.Path)
 			return
 		}
@@ -170,17 +254,22 @@ func putPublicIP(store *state.Store) http.HandlerFunc {
 		if existing == nil {
 			stateValue, code = "creating", http.StatusCreated
 		}
-		respondJSON(w, code, toPublicIPResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toPublicIPResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
 	}
 }
 
-func deletePublicIP(store *state.Store) http.HandlerFunc {
+func deletePublicIP(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "public ip name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
 			return
 		}
 		ref := publicIPRef(tenant, workspace, name)
@@ -190,14 +279,34 @@ func deletePublicIP(store *state.Store) http.HandlerFunc {
 			return
 		}
 		if binding == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "public ip not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "public ip not found")
 			return
 		}
-		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+		if !precondition.checkBinding(w, r, binding) {
+			return
+		}
+		allocated, err := provider.GetPublicIP(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if allocated != nil && providerLabelsInScope(allocated.Labels, tenant, workspace) {
+			if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
+				return
+			}
+			if _, err := provider.DeletePublicIP(ctx, name); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete public ip", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -213,15 +322,31 @@ func parsePublicIPBinding(raw string) (publicIPBindingPayload, error) {
 	return payload, err
 }
 
+func applyAllocatedPublicIP(payload *publicIPBindingPayload, ip hetzner.PublicIP) {
+	payload.Address = ip.Address
+	payload.ProviderRef = fmt.Sprintf("hetzner.cloud/floating-ips/%d", ip.ID)
+	if ip.Region != "" {
+		if payload.Region != "" && !strings.EqualFold(payload.Region, ip.Region) {
+			payload.RequestedRegion = payload.Region
+		}
+		payload.Region = ip.Region
+	}
+	if ip.Address != "" {
+		// Hetzner picks the address; reflect the allocation instead of echoing
+		// whatever the caller sent.
+		payload.Spec.Address = stringPtrOrNil(ip.Address)
+	}
+}
+
 func toPublicIPResourceFromBinding(
 	binding state.ResourceBinding,
 	payload publicIPBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) publicIPResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -237,7 +362,7 @@ func toPublicIPResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "public-ip",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/public-ips/" + payload.Name,
@@ -247,7 +372,26 @@ func toPublicIPResourceFromBinding(
 		},
 		Labels: payload.Labels,
 		Spec:   payload.Spec,
-		Status: publicIPStatusObject{State: stateValue},
+		Status: publicIPStatusObject{State: stateValue, Address: stringPtrOrNil(payload.Address), AssignedTo: publicIPAssignedToRef(payload), Conditions: publicIPRegionConditions(payload)},
 	}
 }
 
+// publicIPRegionConditions reports when the conformance location fallback
+// allocated the address outside the requested region.
+func publicIPRegionConditions(payload publicIPBindingPayload) []statusCondition {
+	if payload.RequestedRegion == "" || strings.EqualFold(payload.RequestedRegion, payload.Region) {
+		return nil
+	}
+	return []statusCondition{{
+		Type:    "RegionFallback",
+		Status:  "True",
+		Message: fmt.Sprintf("region %q is not a Hetzner location; the address was allocated in %q", payload.RequestedRegion, payload.Region),
+	}}
+}
+
+func publicIPAssignedToRef(payload publicIPBindingPayload) *refObject {
+	if payload.AssignedTo == "" {
+		return nil
+	}
+	return &refObject{Resource: payload.AssignedTo}
+}
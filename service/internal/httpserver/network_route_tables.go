@@ -3,6 +3,8 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"sort"
 	"strings"
@@ -53,17 +55,13 @@ type routeTableBindingPayload struct {
 
 func listRouteTables(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
 		}
 		network := strings.ToLower(strings.TrimSpace(r.PathValue("network")))
 		if network == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "network name is required", r.URL.Path)
+			respondValidationProblem(w, r.URL.Path, fieldParameter("network", "network name is required"))
 			return
 		}
 		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
@@ -84,30 +82,15 @@ func listRouteTables(store *state.Store) http.HandlerFunc {
 			if strings.ToLower(strings.TrimSpace(payload.Network)) != network {
 				continue
 			}
-			items = append(items, toRouteTableResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			items = append(items, toRouteTableResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, routeTableIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + network + "/route-tables", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + network + "/route-tables", Verb: verbList},
 		})
 	}
 }
 
-func routeTableCRUD(store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider, cfg config.Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getRouteTable(store)(w, r)
-		case http.MethodPut:
-			putRouteTable(store, computeProvider, networkProvider, cfg)(w, r)
-		case http.MethodDelete:
-			deleteRouteTable(store, computeProvider, networkProvider, cfg)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getRouteTable(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, network, name, ok := scopedNetworkNameFromPath(w, r, "route table name is required")
@@ -132,7 +115,7 @@ func getRouteTable(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid route table payload", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusOK, toRouteTableResourceFromBinding(*binding, payload, tenant, workspace, http.MethodGet, "active"))
+		respondJSON(w, http.StatusOK, toRouteTableResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
@@ -147,8 +130,15 @@ func putRouteTable(store *state.Store, computeProvider ComputeStorageProvider, n
 			return
 		}
 		var req routeTableResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
+			return
+		}
+		if problems := routeTableRouteProblems(req.Spec.Routes); len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
 			return
 		}
 
@@ -158,6 +148,9 @@ func putRouteTable(store *state.Store, computeProvider ComputeStorageProvider, n
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load route table", r.URL.Path)
 			return
 		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
 		var previousRoutes []routeTableRouteSpec
 		if existing != nil {
 			existingPayload, parseErr := parseRouteTableBinding(existing.ProviderRef)
@@ -166,10 +159,14 @@ func putRouteTable(store *state.Store, computeProvider ComputeStorageProvider, n
 			}
 		}
 
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
 		payload := routeTableBindingPayload{
 			Name:    name,
 			Network: network,
-			Region:  runtimeRegionOrDefault(req.Metadata.Region),
+			Region:  region,
 			Labels:  req.Labels,
 			Spec:    req.Spec,
 		}
@@ -178,22 +175,23 @@ func putRouteTable(store *state.Store, computeProvider ComputeStorageProvider, n
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode route table", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		if _, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        resourceBindingKindRouteTable,
 			SecaRef:     ref,
 			ProviderRef: string(raw),
 			Status:      "active",
-		}); err != nil {
+		}, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save route table", r.URL.Path)
 			return
 		}
-		for _, gatewayName := range affectedInternetGatewayNames(req.Spec.Routes, previousRoutes) {
-			if err := refreshInternetGatewayFromRouteUsage(ctx, store, computeProvider, cfg, tenant, workspace, gatewayName); err != nil {
-				respondFromError(w, err, r.URL.Path)
-				return
-			}
+		if err := refreshInternetGateways(ctx, store, computeProvider, cfg, tenant, workspace, affectedInternetGatewayNames(req.Spec.Routes, previousRoutes)); err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
 		}
 		if err := syncHetznerNetworkRoutes(ctx, store, computeProvider, networkProvider, tenant, workspace, network, req.Spec.Routes, previousRoutes); err != nil {
 			respondFromError(w, err, r.URL.Path)
@@ -209,7 +207,7 @@ func putRouteTable(store *state.Store, computeProvider ComputeStorageProvider, n
 		if existing == nil {
 			stateValue, code = "creating", http.StatusCreated
 		}
-		respondJSON(w, code, toRouteTableResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toRouteTableResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
 	}
 }
 
@@ -223,6 +221,10 @@ func deleteRouteTable(store *state.Store, computeProvider ComputeStorageProvider
 		if !ok {
 			return
 		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
 		ref := routeTableRefKey(tenant, workspace, network, name)
 		binding, err := store.GetResourceBinding(r.Context(), ref)
 		if err != nil {
@@ -230,7 +232,10 @@ func deleteRouteTable(store *state.Store, computeProvider ComputeStorageProvider
 			return
 		}
 		if binding == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "route table not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "route table not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
 			return
 		}
 		payload, err := parseRouteTableBinding(binding.ProviderRef)
@@ -238,21 +243,22 @@ func deleteRouteTable(store *state.Store, computeProvider ComputeStorageProvider
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid route table payload", r.URL.Path)
 			return
 		}
-		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete route table", r.URL.Path)
 			return
 		}
-		for _, gatewayName := range internetGatewayNamesFromRoutes(payload.Spec.Routes) {
-			if err := refreshInternetGatewayFromRouteUsage(ctx, store, computeProvider, cfg, tenant, workspace, gatewayName); err != nil {
-				respondFromError(w, err, r.URL.Path)
-				return
-			}
+		if err := refreshInternetGateways(ctx, store, computeProvider, cfg, tenant, workspace, internetGatewayNamesFromRoutes(payload.Spec.Routes)); err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
 		}
 		if err := syncHetznerNetworkRoutes(ctx, store, computeProvider, networkProvider, tenant, workspace, network, nil, payload.Spec.Routes); err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -276,6 +282,20 @@ func parseRouteTableBinding(raw string) (routeTableBindingPayload, error) {
 	return payload, err
 }
 
+// routeTableRouteProblems checks every route's destination and target.
+func routeTableRouteProblems(routes []routeTableRouteSpec) []fieldProblem {
+	var problems []fieldProblem
+	for i, route := range routes {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(route.DestinationCidrBlock)); err != nil {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/routes/%d/destinationCidrBlock", i), "spec.routes[%d].destinationCidrBlock must be a cidr", i))
+		}
+		if resourceNameFromRef(route.TargetRef.Resource) == "" {
+			problems = append(problems, fieldPointer(fmt.Sprintf("/spec/routes/%d/targetRef/resource", i), "spec.routes[%d].targetRef is required", i))
+		}
+	}
+	return problems
+}
+
 func internetGatewayNamesFromRoutes(routes []routeTableRouteSpec) []string {
 	seen := map[string]struct{}{}
 	for _, route := range routes {
@@ -393,11 +413,11 @@ func toRouteTableResourceFromBinding(
 	binding state.ResourceBinding,
 	payload routeTableBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) routeTableResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -413,7 +433,7 @@ func toRouteTableResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "routing-table",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + payload.Network + "/route-tables/" + payload.Name,
@@ -38,3 +38,20 @@ func TestAffectedInternetGatewayNamesUnionCurrentAndPrevious(t *testing.T) {
 		t.Fatalf("unexpected names: got=%v want=%v", got, want)
 	}
 }
+
+func TestRouteTableRouteProblemsPointAtEveryInvalidRoute(t *testing.T) {
+	t.Parallel()
+
+	problems := routeTableRouteProblems([]routeTableRouteSpec{
+		{DestinationCidrBlock: "0.0.0.0/0", TargetRef: refObject{Resource: "internet-gateways/igw"}},
+		{DestinationCidrBlock: "10.0.0.300/24"},
+	})
+	var pointers []string
+	for _, problem := range problems {
+		pointers = append(pointers, problem.source.Pointer)
+	}
+	want := []string{"/spec/routes/1/destinationCidrBlock", "/spec/routes/1/targetRef/resource"}
+	if !reflect.DeepEqual(pointers, want) {
+		t.Fatalf("expected pointers %v, got %v", want, pointers)
+	}
+}
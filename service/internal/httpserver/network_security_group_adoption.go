@@ -0,0 +1,235 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// securityGroupOriginAdopted marks a security group backed by an existing
+// Hetzner firewall that an operator exposed to the workspace. Adopted groups
+// are read-only: their rules are read live from the firewall and tenants
+// cannot change or delete them until the operator unadopts the group.
+const securityGroupOriginAdopted = "adopted"
+
+type securityGroupAdoptRequest struct {
+	ProviderID int64 `json:"providerId"`
+}
+
+func (p securityGroupBindingPayload) adopted() bool {
+	return p.Origin == securityGroupOriginAdopted && p.ProviderID > 0
+}
+
+// adoptedSecurityGroupFromBinding returns the binding payload when the
+// binding belongs to an adopted security group.
+func adoptedSecurityGroupFromBinding(binding *state.ResourceBinding) (securityGroupBindingPayload, bool) {
+	if binding == nil {
+		return securityGroupBindingPayload{}, false
+	}
+	payload, err := parseSecurityGroupBinding(binding.ProviderRef)
+	if err != nil || !payload.adopted() {
+		return securityGroupBindingPayload{}, false
+	}
+	return payload, true
+}
+
+// adoptedSecurityGroupPayload overlays the live firewall rules and user
+// labels on the stored payload of an adopted group.
+func adoptedSecurityGroupPayload(payload securityGroupBindingPayload, item hetzner.SecurityGroup) securityGroupBindingPayload {
+	payload.Labels = userProviderLabels(item.Labels)
+	payload.Spec = securityGroupSpec{Rules: toSecurityGroupRuleSpecs(item.Rules)}
+	return payload
+}
+
+// ensureSecurityGroupWritable answers 403 when ref is an adopted security
+// group and reports whether the caller may go on writing it.
+func ensureSecurityGroupWritable(w http.ResponseWriter, r *http.Request, store *state.Store, ref string) bool {
+	binding, err := store.GetResourceBinding(r.Context(), ref)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+		return false
+	}
+	return securityGroupBindingWritable(w, r, binding)
+}
+
+func securityGroupBindingWritable(w http.ResponseWriter, r *http.Request, binding *state.ResourceBinding) bool {
+	if _, adopted := adoptedSecurityGroupFromBinding(binding); adopted {
+		respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", "security group is adopted from an existing firewall and is read-only", r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// adminSecurityGroupAction serves POST .../security-groups/{name}:adopt and
+// POST .../security-groups/{name}:unadopt. The mux cannot match a literal
+// suffix after a wildcard, so the action is split off the last segment.
+func adminSecurityGroupAction(store *state.Store, provider NetworkProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, action, _ := strings.Cut(r.PathValue("action"), ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "security group name is required", r.URL.Path)
+			return
+		}
+		switch action {
+		case "adopt":
+			adoptSecurityGroup(w, r, store, provider, name)
+		case "unadopt":
+			unadoptSecurityGroup(w, r, store, provider, name)
+		default:
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "unknown security group action", r.URL.Path)
+		}
+	}
+}
+
+func adoptSecurityGroup(w http.ResponseWriter, r *http.Request, store *state.Store, provider NetworkProvider, name string) {
+	tenant, workspace, ok := scopeFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req securityGroupAdoptRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.ProviderID <= 0 {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "providerId is required", r.URL.Path)
+		return
+	}
+	ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+	if !ok {
+		return
+	}
+	ref := securityGroupRef(tenant, workspace, name)
+	existing, err := store.GetResourceBinding(r.Context(), ref)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+		return
+	}
+	if existing != nil {
+		adopted, ok := adoptedSecurityGroupFromBinding(existing)
+		if !ok {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "security group already exists and is managed by the proxy", r.URL.Path)
+			return
+		}
+		if adopted.ProviderID != req.ProviderID {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "security group already adopts a different firewall", r.URL.Path)
+			return
+		}
+	}
+	item, err := provider.GetSecurityGroupByID(ctx, req.ProviderID)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return
+	}
+	if item == nil {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "firewall not found", r.URL.Path)
+		return
+	}
+	region, ok := workspaceRegionOrDefault(r.Context(), store, tenant, workspace)
+	if !ok {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve workspace", r.URL.Path)
+		return
+	}
+	payload := adoptedSecurityGroupPayload(securityGroupBindingPayload{
+		Name:       name,
+		Region:     region,
+		Origin:     securityGroupOriginAdopted,
+		ProviderID: item.ID,
+	}, *item)
+	binding, ok := saveSecurityGroupBinding(w, r, store, tenant, workspace, ref, payload)
+	if !ok {
+		return
+	}
+	code := http.StatusOK
+	if existing == nil {
+		code = http.StatusCreated
+	}
+	respondJSON(w, code, toSecurityGroupResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), "active"))
+}
+
+// unadoptSecurityGroup turns an adopted group into a managed one: the
+// firewall is renamed and labelled like a proxy-created firewall, and its
+// current rules become the group's spec.
+func unadoptSecurityGroup(w http.ResponseWriter, r *http.Request, store *state.Store, provider NetworkProvider, name string) {
+	tenant, workspace, ok := scopeFromPath(w, r)
+	if !ok {
+		return
+	}
+	ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+	if !ok {
+		return
+	}
+	ref := securityGroupRef(tenant, workspace, name)
+	existing, err := store.GetResourceBinding(r.Context(), ref)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+		return
+	}
+	adopted, ok := adoptedSecurityGroupFromBinding(existing)
+	if !ok {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "adopted security group not found", r.URL.Path)
+		return
+	}
+	current, err := provider.GetSecurityGroupByID(ctx, adopted.ProviderID)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return
+	}
+	if current == nil {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "adopted firewall no longer exists", r.URL.Path)
+		return
+	}
+	userLabels := userProviderLabels(current.Labels)
+	item, err := provider.ManageSecurityGroup(ctx, adopted.ProviderID, name, withSecaProviderLabels(
+		userLabels,
+		tenant,
+		workspace,
+		"security-group",
+		name,
+		ref,
+	))
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return
+	}
+	payload := securityGroupBindingPayload{
+		Name:       name,
+		Region:     adopted.Region,
+		Labels:     userLabels,
+		Spec:       securityGroupSpec{Rules: toSecurityGroupRuleSpecs(item.Rules)},
+		ProviderID: adopted.ProviderID,
+	}
+	binding, ok := saveSecurityGroupBinding(w, r, store, tenant, workspace, ref, payload)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, toSecurityGroupResourceFromBinding(*binding, payload, tenant, workspace, verbUpdate, "active"))
+}
+
+func saveSecurityGroupBinding(w http.ResponseWriter, r *http.Request, store *state.Store, tenant, workspace, ref string, payload securityGroupBindingPayload) (*state.ResourceBinding, bool) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode security group", r.URL.Path)
+		return nil, false
+	}
+	if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		Tenant:      tenant,
+		Workspace:   workspace,
+		Kind:        resourceBindingKindSecurityGroup,
+		SecaRef:     ref,
+		ProviderRef: string(raw),
+		Status:      "active",
+	}); err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save security group", r.URL.Path)
+		return nil, false
+	}
+	binding, err := store.GetResourceBinding(r.Context(), ref)
+	if err != nil || binding == nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+		return nil, false
+	}
+	return binding, true
+}
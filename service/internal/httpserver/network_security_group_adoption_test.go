@@ -0,0 +1,177 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func adoptedBinding(t *testing.T, payload securityGroupBindingPayload) *state.ResourceBinding {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return &state.ResourceBinding{
+		Tenant:      "t1",
+		Workspace:   "ws1",
+		Kind:        resourceBindingKindSecurityGroup,
+		SecaRef:     securityGroupRef("t1", "ws1", payload.Name),
+		ProviderRef: string(raw),
+		Status:      "active",
+	}
+}
+
+func TestAdoptedSecurityGroupBindingRecordsProviderID(t *testing.T) {
+	t.Parallel()
+
+	binding := adoptedBinding(t, securityGroupBindingPayload{Name: "legacy", Region: "global", Origin: securityGroupOriginAdopted, ProviderID: 42})
+	payload, ok := adoptedSecurityGroupFromBinding(binding)
+	if !ok || payload.ProviderID != 42 {
+		t.Fatalf("expected adopted binding with provider ID 42, got %+v, %t", payload, ok)
+	}
+
+	managed := adoptedBinding(t, securityGroupBindingPayload{Name: "web", Region: "global"})
+	if _, ok := adoptedSecurityGroupFromBinding(managed); ok {
+		t.Fatal("expected managed binding not to be treated as adopted")
+	}
+	if _, ok := adoptedSecurityGroupFromBinding(nil); ok {
+		t.Fatal("expected missing binding not to be treated as adopted")
+	}
+}
+
+func TestAdoptedSecurityGroupReadsLiveRules(t *testing.T) {
+	t.Parallel()
+
+	stored := securityGroupBindingPayload{
+		Name:       "legacy",
+		Region:     "global",
+		Origin:     securityGroupOriginAdopted,
+		ProviderID: 42,
+		Spec:       securityGroupSpec{Rules: []securityGroupRuleSpec{{Direction: "ingress", Protocol: "udp"}}},
+	}
+	live := hetzner.SecurityGroup{
+		ID:     42,
+		Name:   "hand-made-firewall",
+		Labels: map[string]string{"team": "ops", secaLabelTenant: "other"},
+		Rules: []hetzner.SecurityGroupRule{
+			{Direction: "in", Protocol: "tcp", Ports: []hetzner.PortRange{{From: 22, To: 22}}, CIDRs: []string{"0.0.0.0/0"}},
+		},
+	}
+
+	payload := adoptedSecurityGroupPayload(stored, live)
+	if !payload.adopted() || payload.ProviderID != 42 || payload.Name != "legacy" {
+		t.Fatalf("expected adoption metadata to be kept, got %+v", payload)
+	}
+	if len(payload.Spec.Rules) != 1 || payload.Spec.Rules[0].Protocol != "tcp" {
+		t.Fatalf("expected live firewall rules, got %+v", payload.Spec.Rules)
+	}
+	if payload.Labels["team"] != "ops" || payload.Labels[secaLabelTenant] != "" {
+		t.Fatalf("expected only user labels, got %v", payload.Labels)
+	}
+}
+
+func TestAdoptedSecurityGroupRejectsWrites(t *testing.T) {
+	t.Parallel()
+
+	path := "/network/v1/tenants/t1/workspaces/ws1/security-groups/legacy"
+	adopted := adoptedBinding(t, securityGroupBindingPayload{Name: "legacy", Region: "global", Origin: securityGroupOriginAdopted, ProviderID: 42})
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		if securityGroupBindingWritable(rec, httptest.NewRequest(method, path, nil), adopted) {
+			t.Fatalf("%s: expected adopted security group to be read-only", method)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d", method, rec.Code)
+		}
+	}
+
+	managed := adoptedBinding(t, securityGroupBindingPayload{Name: "legacy", Region: "global"})
+	rec := httptest.NewRecorder()
+	if !securityGroupBindingWritable(rec, httptest.NewRequest(http.MethodPut, path, nil), managed) {
+		t.Fatalf("expected managed security group to be writable, got %d", rec.Code)
+	}
+}
+
+func TestAdminSecurityGroupActionRejectsUnknownActions(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{action}", adminSecurityGroupAction(nil, nil))
+
+	cases := []struct {
+		method string
+		action string
+		want   int
+	}{
+		{method: http.MethodGet, action: "legacy:adopt", want: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, action: "legacy:import", want: http.StatusNotFound},
+		{method: http.MethodPost, action: "legacy", want: http.StatusNotFound},
+		{method: http.MethodPost, action: ":adopt", want: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tc.method, "/admin/v1/tenants/t1/workspaces/ws1/security-groups/"+tc.action, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.action, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestFakeProviderAdoptAndUnadoptSecurityGroup(t *testing.T) {
+	server := newFakeProviderServer(t)
+	ctx := context.Background()
+	firewall, _, err := server.provider.CreateOrUpdateSecurityGroup(ctx, hetzner.SecurityGroupCreateRequest{
+		Name:   "hand-made-firewall",
+		Labels: map[string]string{"team": "ops"},
+		Rules:  []hetzner.SecurityGroupRule{{Direction: "in", Protocol: "tcp", Ports: []hetzner.PortRange{{From: 22, To: 22}}, CIDRs: []string{"0.0.0.0/0"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adoptBody, _ := json.Marshal(securityGroupAdoptRequest{ProviderID: firewall.ID})
+
+	var adopted securityGroupResource
+	if err := json.Unmarshal([]byte(server.do(http.MethodPost, "admin", "security-groups/legacy:adopt", string(adoptBody), http.StatusCreated)), &adopted); err != nil {
+		t.Fatal(err)
+	}
+	if adopted.Labels["team"] != "ops" || len(adopted.Spec.Rules) != 1 || adopted.Spec.Rules[0].Protocol != "tcp" {
+		t.Fatalf("expected the firewall rules and labels, got %+v", adopted)
+	}
+	tenant := strings.Split(server.prefix, "/")[2]
+	binding, err := server.store.GetResourceBinding(ctx, securityGroupRef(tenant, "ws-1", "legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload, ok := adoptedSecurityGroupFromBinding(binding); !ok || payload.ProviderID != firewall.ID {
+		t.Fatalf("expected the binding to adopt firewall %d, got %+v", firewall.ID, binding)
+	}
+	if untouched, _ := server.provider.GetSecurityGroupByID(ctx, firewall.ID); untouched.Name != "hand-made-firewall" || len(untouched.Labels) != 1 {
+		t.Fatalf("expected adoption to leave the firewall alone, got %+v", untouched)
+	}
+	server.do(http.MethodPost, "admin", "security-groups/legacy:adopt", string(adoptBody), http.StatusOK)
+	server.do(http.MethodGet, "network", "security-groups/legacy", "", http.StatusOK)
+	server.do(http.MethodPut, "network", "security-groups/legacy", `{"spec":{"rules":[]}}`, http.StatusForbidden)
+	server.do(http.MethodDelete, "network", "security-groups/legacy", "", http.StatusForbidden)
+
+	server.do(http.MethodPost, "admin", "security-groups/legacy:unadopt", "", http.StatusOK)
+	managed, _ := server.provider.GetSecurityGroupByID(ctx, firewall.ID)
+	if managed.Name != "legacy" || managed.Labels["team"] != "ops" || managed.Labels[secaLabelTenant] != tenant || len(managed.Rules) != 1 {
+		t.Fatalf("expected the firewall to be renamed and labelled like a managed one, got %+v", managed)
+	}
+	binding, err = server.store.GetResourceBinding(ctx, securityGroupRef(tenant, "ws-1", "legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adoptedSecurityGroupFromBinding(binding); ok || binding == nil {
+		t.Fatalf("expected a managed binding after unadopt, got %+v", binding)
+	}
+	server.do(http.MethodPost, "admin", "security-groups/legacy:unadopt", "", http.StatusNotFound)
+	server.do(http.MethodDelete, "network", "security-groups/legacy", "", http.StatusAccepted)
+}
@@ -1,7 +1,10 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -29,7 +32,17 @@ type securityGroupSpec struct {
 }
 
 type securityGroupRuleSpec struct {
-	Direction string `json:"direction"`
+	Direction  string                  `json:"direction"`
+	Protocol   string                  `json:"protocol,omitempty"`
+	Ports      *securityGroupRulePorts `json:"ports,omitempty"`
+	SourceRefs []string                `json:"sourceRefs,omitempty"`
+	Version    string                  `json:"version,omitempty"`
+}
+
+type securityGroupRulePorts struct {
+	From *int  `json:"from,omitempty"`
+	To   *int  `json:"to,omitempty"`
+	List []int `json:"list,omitempty"`
 }
 
 type securityGroupStatusObj struct {
@@ -41,14 +54,15 @@ type securityGroupBindingPayload struct {
 	Region string                `json:"region"`
 	Labels map[string]string     `json:"labels,omitempty"`
 	Spec   securityGroupSpec     `json:"spec"`
+	// Origin is securityGroupOriginAdopted for firewalls attached by an
+	// operator. ProviderID identifies the firewall; groups stored before
+	// the ID was recorded have none and are matched by name.
+	Origin     string `json:"origin,omitempty"`
+	ProviderID int64  `json:"providerId,omitempty"`
 }
 
 func listSecurityGroups(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
@@ -73,7 +87,18 @@ func listSecurityGroups(provider NetworkProvider, store *state.Store) http.Handl
 			return
 		}
 		bindingsByName := make(map[string]state.ResourceBinding, len(bindings))
+		adoptedByID := map[int64]state.ResourceBinding{}
+		managedByID := map[int64]state.ResourceBinding{}
 		for _, binding := range bindings {
+			payload, err := parseSecurityGroupBinding(binding.ProviderRef)
+			if err == nil && payload.adopted() {
+				adoptedByID[payload.ProviderID] = binding
+				continue
+			}
+			if err == nil && payload.ProviderID > 0 {
+				managedByID[payload.ProviderID] = binding
+				continue
+			}
 			name := strings.TrimSpace(resourceNameFromRef(binding.SecaRef))
 			if name != "" {
 				bindingsByName[strings.ToLower(name)] = binding
@@ -81,13 +106,24 @@ func listSecurityGroups(provider NetworkProvider, store *state.Store) http.Handl
 		}
 		items := make([]securityGroupResource, 0, len(itemsFromProvider))
 		for _, item := range itemsFromProvider {
+			if binding, ok := adoptedByID[item.ID]; ok {
+				payload, _ := parseSecurityGroupBinding(binding.ProviderRef)
+				items = append(items, toSecurityGroupResourceFromBinding(binding, adoptedSecurityGroupPayload(payload, item), tenant, workspace, verbList, "active"))
+				continue
+			}
+			if !providerLabelsInScope(item.Labels, tenant, workspace) {
+				continue
+			}
 			payload := securityGroupBindingPayload{
 				Name:   item.Name,
 				Region: workspaceRegion,
 				Labels: item.Labels,
 				Spec:   securityGroupSpec{Rules: toSecurityGroupRuleSpecs(item.Rules)},
 			}
-			binding, hasBinding := bindingsByName[item.Name]
+			binding, hasBinding := managedByID[item.ID]
+			if !hasBinding {
+				binding, hasBinding = bindingsByName[item.Name]
+			}
 			if hasBinding {
 				parsed, err := parseSecurityGroupBinding(binding.ProviderRef)
 				if err == nil {
@@ -97,9 +133,7 @@ func listSecurityGroups(provider NetworkProvider, store *state.Store) http.Handl
 			if len(payload.Labels) == 0 {
 				payload.Labels = item.Labels
 			}
-			if len(payload.Spec.Rules) == 0 {
-				payload.Spec.Rules = toSecurityGroupRuleSpecs(item.Rules)
-			}
+			payload.Spec.Rules = toSecurityGroupRuleSpecs(item.Rules)
 			if payload.Region == "" {
 				payload.Region = workspaceRegion
 			}
@@ -109,30 +143,15 @@ func listSecurityGroups(provider NetworkProvider, store *state.Store) http.Handl
 					UpdatedAt: item.CreatedAt,
 				}
 			}
-			items = append(items, toSecurityGroupResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			items = append(items, toSecurityGroupResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, securityGroupIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/security-groups", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/security-groups", Verb: verbList},
 		})
 	}
 }
 
-func securityGroupCRUD(provider NetworkProvider, store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getSecurityGroup(provider, store)(w, r)
-		case http.MethodPut:
-			putSecurityGroup(provider, store)(w, r)
-		case http.MethodDelete:
-			deleteSecurityGroup(provider, store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getSecurityGroup(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "security group name is required")
@@ -148,19 +167,32 @@ func getSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 		if !ok {
 			return
 		}
-		item, err := provider.GetSecurityGroup(ctx, name)
+		ref := securityGroupRef(tenant, workspace, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
 		if err != nil {
-			respondFromError(w, err, r.URL.Path)
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
 			return
 		}
-		if item == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "security group not found", r.URL.Path)
+		if adopted, ok := adoptedSecurityGroupFromBinding(binding); ok {
+			item, err := provider.GetSecurityGroupByID(ctx, adopted.ProviderID)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if item == nil {
+				respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "adopted firewall no longer exists", r.URL.Path)
+				return
+			}
+			respondJSON(w, http.StatusOK, toSecurityGroupResourceFromBinding(*binding, adoptedSecurityGroupPayload(adopted, *item), tenant, workspace, verbGet, "active"))
 			return
 		}
-		ref := securityGroupRef(tenant, workspace, name)
-		binding, err := store.GetResourceBinding(r.Context(), ref)
+		item, err := getWorkspaceSecurityGroup(ctx, provider, tenant, workspace, name)
 		if err != nil {
-			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if item == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "security group not found", r.URL.Path)
 			return
 		}
 		if binding == nil {
@@ -194,9 +226,7 @@ func getSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 			if len(payload.Labels) == 0 {
 				payload.Labels = item.Labels
 			}
-			if len(payload.Spec.Rules) == 0 {
-				payload.Spec.Rules = toSecurityGroupRuleSpecs(item.Rules)
-			}
+			payload.Spec.Rules = toSecurityGroupRuleSpecs(item.Rules)
 			if payload.Region == "" {
 				payload.Region = workspaceRegion
 			}
@@ -205,7 +235,7 @@ func getSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 		if binding != nil {
 			outBinding = *binding
 		}
-		respondJSON(w, http.StatusOK, toSecurityGroupResourceFromBinding(outBinding, payload, tenant, workspace, http.MethodGet, "active"))
+		respondJSON(w, http.StatusOK, toSecurityGroupResourceFromBinding(outBinding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
@@ -215,18 +245,48 @@ func putSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 		if !ok {
 			return
 		}
+		dryRun, ok := dryRunFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
 		var req securityGroupResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		if !ensureSecurityGroupWritable(w, r, store, securityGroupRef(tenant, workspace, name)) {
+			return
+		}
+
+		rules, ruleProblems := securityGroupRulesFromSpec(req.Spec.Rules)
+		if len(ruleProblems) > 0 {
+			respondValidationProblem(w, r.URL.Path, ruleProblems...)
+			return
+		}
+		// Rule enums are matched case-insensitively; store and echo them the
+		// way the API spells them, so a PUT and a later GET agree.
+		req.Spec.Rules = toSecurityGroupRuleSpecs(rules)
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
+		current, err := provider.GetSecurityGroup(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if current != nil && !providerLabelsInScope(current.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "security group name is already in use outside this workspace", r.URL.Path)
 			return
 		}
 
-		item, created, err := provider.CreateOrUpdateSecurityGroup(ctx, hetzner.SecurityGroupCreateRequest{
-			Name:   name,
+		createReq := hetzner.SecurityGroupCreateRequest{
+			Name:  name,
+			Rules: rules,
 			Labels: withSecaProviderLabels(
 				req.Labels,
 				tenant,
@@ -235,7 +295,32 @@ func putSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 				name,
 				securityGroupRef(tenant, workspace, name),
 			),
-		})
+		}
+		if dryRun {
+			_, created, err := provider.ValidateSecurityGroupCreate(ctx, createReq)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			existing, err := store.GetResourceBinding(r.Context(), securityGroupRef(tenant, workspace, name))
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load security group", r.URL.Path)
+				return
+			}
+			binding := state.ResourceBinding{}
+			if existing != nil {
+				binding = *existing
+			}
+			payload := securityGroupBindingPayload{
+				Name:   name,
+				Region: region,
+				Labels: req.Labels,
+				Spec:   req.Spec,
+			}
+			respondJSON(w, http.StatusOK, toSecurityGroupResourceFromBinding(binding, payload, tenant, workspace, upsertVerb(created && existing == nil), dryRunState))
+			return
+		}
+		item, created, err := provider.CreateOrUpdateSecurityGroup(ctx, createReq)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -253,13 +338,11 @@ func putSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 		}
 
 		payload := securityGroupBindingPayload{
-			Name:   name,
-			Region: runtimeRegionOrDefault(req.Metadata.Region),
-			Labels: req.Labels,
-			Spec:   req.Spec,
-		}
-		if payload.Region == "" {
-			payload.Region = "global"
+			Name:       name,
+			Region:     region,
+			Labels:     req.Labels,
+			Spec:       req.Spec,
+			ProviderID: item.ID,
 		}
 		raw, err := json.Marshal(payload)
 		if err != nil {
@@ -286,7 +369,7 @@ func putSecurityGroup(provider NetworkProvider, store *state.Store) http.Handler
 		if existing != nil && created {
 			stateValue, code = "updating", http.StatusOK
 		}
-		respondJSON(w, code, toSecurityGroupResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toSecurityGroupResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(created && existing == nil), stateValue))
 	}
 }
 
@@ -300,24 +383,54 @@ func deleteSecurityGroup(provider NetworkProvider, store *state.Store) http.Hand
 		if !ok {
 			return
 		}
+		if !ensureSecurityGroupWritable(w, r, store, securityGroupRef(tenant, workspace, name)) {
+			return
+		}
+		item, err := getWorkspaceSecurityGroup(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		ref := securityGroupRef(tenant, workspace, name)
+		if item == nil {
+			respondDeleteNotFound(w, r, ref, "security group not found")
+			return
+		}
+		if len(item.ServerIDs) > 0 {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("security group is still referenced by %d instance(s)", len(item.ServerIDs)), r.URL.Path)
+			return
+		}
 		deleted, err := provider.DeleteSecurityGroup(ctx, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if !deleted {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "security group not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "security group not found")
 			return
 		}
-		ref := securityGroupRef(tenant, workspace, name)
 		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete security group", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
+// getWorkspaceSecurityGroup returns the firewall behind a security group of
+// the workspace. Firewalls without the workspace's SECA labels, created by
+// hand or by other tools, are reported as missing.
+func getWorkspaceSecurityGroup(ctx context.Context, provider NetworkProvider, tenant, workspace, name string) (*hetzner.SecurityGroup, error) {
+	item, err := provider.GetSecurityGroup(ctx, name)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if !providerLabelsInScope(item.Labels, tenant, workspace) {
+		return nil, nil
+	}
+	return item, nil
+}
+
 func securityGroupRef(tenant, workspace, name string) string {
 	return "seca.network/v1/tenants/" + strings.ToLower(strings.TrimSpace(tenant)) +
 		"/workspaces/" + strings.ToLower(strings.TrimSpace(workspace)) +
@@ -334,11 +447,11 @@ func toSecurityGroupResourceFromBinding(
 	binding state.ResourceBinding,
 	payload securityGroupBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) securityGroupResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -354,7 +467,7 @@ func toSecurityGroupResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "security-group",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/security-groups/" + payload.Name,
@@ -378,7 +491,151 @@ func toSecurityGroupRuleSpecs(rules []hetzner.SecurityGroupRule) []securityGroup
 		if direction == "" {
 			continue
 		}
-		out = append(out, securityGroupRuleSpec{Direction: strings.ToLower(direction)})
+		out = append(out, securityGroupRuleSpec{
+			Direction:  strings.ToLower(direction),
+			Protocol:   rule.Protocol,
+			Ports:      toSecurityGroupRulePorts(rule.Ports),
+			SourceRefs: rule.CIDRs,
+			Version:    rule.Version,
+		})
 	}
 	return out
 }
+
+func toSecurityGroupRulePorts(ranges []hetzner.PortRange) *securityGroupRulePorts {
+	switch {
+	case len(ranges) == 0:
+		return nil
+	case len(ranges) == 1 && ranges[0].To > ranges[0].From:
+		return &securityGroupRulePorts{From: intPtr(ranges[0].From), To: intPtr(ranges[0].To)}
+	case len(ranges) == 1:
+		return &securityGroupRulePorts{From: intPtr(ranges[0].From)}
+	}
+	list := make([]int, 0, len(ranges))
+	for _, r := range ranges {
+		list = append(list, r.From)
+	}
+	return &securityGroupRulePorts{List: list}
+}
+
+// securityGroupRulesFromSpec validates the SECA rules and converts them for
+// the provider. Every invalid rule field is reported with its JSON pointer.
+func securityGroupRulesFromSpec(rules []securityGroupRuleSpec) ([]hetzner.SecurityGroupRule, []fieldProblem) {
+	out := make([]hetzner.SecurityGroupRule, 0, len(rules))
+	var problems []fieldProblem
+	for i, rule := range rules {
+		pointer := fmt.Sprintf("/spec/rules/%d", i)
+		valid := true
+		fail := func(field, format string, args ...any) {
+			problems = append(problems, fieldPointer(pointer+field, fmt.Sprintf("spec.rules[%d]: ", i)+format, args...))
+			valid = false
+		}
+
+		direction := strings.ToLower(strings.TrimSpace(rule.Direction))
+		if direction != hetzner.SecurityGroupDirectionIngress && direction != hetzner.SecurityGroupDirectionEgress {
+			fail("/direction", "direction must be ingress or egress")
+		}
+		protocol := strings.ToLower(strings.TrimSpace(rule.Protocol))
+		portsAllowed := false
+		switch protocol {
+		case "tcp", "udp":
+			portsAllowed = true
+		case "icmp", "esp", "gre":
+		case "", "any":
+			protocol = ""
+		default:
+			fail("/protocol", "unsupported protocol %q", rule.Protocol)
+		}
+		version := ""
+		switch strings.ToLower(strings.TrimSpace(rule.Version)) {
+		case "":
+		case "ipv4":
+			version = "IPv4"
+		case "ipv6":
+			version = "IPv6"
+		default:
+			fail("/version", "unsupported version %q", rule.Version)
+		}
+
+		ranges, ok := securityGroupRulePortRanges(rule.Ports, portsAllowed, fail)
+		valid = valid && ok
+
+		cidrs := make([]string, 0, len(rule.SourceRefs))
+		for j, ref := range rule.SourceRefs {
+			ref = strings.TrimSpace(ref)
+			if net.ParseIP(ref) == nil {
+				if _, _, err := net.ParseCIDR(ref); err != nil {
+					fail(fmt.Sprintf("/sourceRefs/%d", j), "source %q must be an ip address or cidr", ref)
+					continue
+				}
+			}
+			cidrs = append(cidrs, ref)
+		}
+
+		if !valid {
+			continue
+		}
+		out = append(out, hetzner.SecurityGroupRule{
+			Direction: direction,
+			Protocol:  protocol,
+			Ports:     ranges,
+			CIDRs:     cidrs,
+			Version:   version,
+		})
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return out, nil
+}
+
+// securityGroupRulePortRanges converts a rule's ports. It stops at the first
+// invalid port since later ones are usually wrong for the same reason.
+func securityGroupRulePortRanges(ports *securityGroupRulePorts, portsAllowed bool, fail func(field, format string, args ...any)) ([]hetzner.PortRange, bool) {
+	if ports == nil {
+		return nil, true
+	}
+	if !portsAllowed {
+		fail("/ports", "ports require protocol tcp or udp")
+		return nil, false
+	}
+	if len(ports.List) > 0 && (ports.From != nil || ports.To != nil) {
+		fail("/ports", "ports.list cannot be combined with ports.from/to")
+		return nil, false
+	}
+	var ranges []hetzner.PortRange
+	for j, port := range ports.List {
+		if !validPort(port) {
+			fail(fmt.Sprintf("/ports/list/%d", j), "port %d is out of range", port)
+			return nil, false
+		}
+		ranges = append(ranges, hetzner.PortRange{From: port, To: port})
+	}
+	if ports.From != nil {
+		from, to := *ports.From, *ports.From
+		if ports.To != nil {
+			to = *ports.To
+		}
+		if !validPort(from) {
+			fail("/ports/from", "port %d is out of range", from)
+			return nil, false
+		}
+		if !validPort(to) || to < from {
+			fail("/ports/to", "port range %d-%d is invalid", from, to)
+			return nil, false
+		}
+		ranges = append(ranges, hetzner.PortRange{From: from, To: to})
+	} else if ports.To != nil {
+		fail("/ports/from", "ports.from is required with ports.to")
+		return nil, false
+	}
+	return ranges, true
+}
+
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+func intPtr(v int) *int {
+	return &v
+}
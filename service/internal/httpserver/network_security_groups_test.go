@@ -0,0 +1,103 @@
+package httpserver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+type fakeSecurityGroupProvider struct {
+	NetworkProvider
+	groups map[string]hetzner.SecurityGroup
+}
+
+func (f fakeSecurityGroupProvider) GetSecurityGroup(_ context.Context, name string) (*hetzner.SecurityGroup, error) {
+	group, ok := f.groups[name]
+	if !ok {
+		return nil, nil
+	}
+	return &group, nil
+}
+
+func TestSecurityGroupRulesFromSpecPointsAtInvalidRule(t *testing.T) {
+	t.Parallel()
+
+	from, to := 8080, 80
+	rules := []securityGroupRuleSpec{
+		{Direction: "ingress", Protocol: "tcp", Ports: &securityGroupRulePorts{List: []int{80, 443}}},
+		{Direction: "ingress", Protocol: "tcp", Ports: &securityGroupRulePorts{From: &from, To: &to}},
+	}
+	_, problems := securityGroupRulesFromSpec(rules)
+	if len(problems) != 1 {
+		t.Fatalf("expected invalid port range to be rejected, got %+v", problems)
+	}
+	if problems[0].source.Pointer != "/spec/rules/1/ports/to" {
+		t.Fatalf("unexpected pointer: %q", problems[0].source.Pointer)
+	}
+
+	_, problems = securityGroupRulesFromSpec([]securityGroupRuleSpec{{Direction: "ingress", Protocol: "sctp"}})
+	if len(problems) != 1 || problems[0].source.Pointer != "/spec/rules/0/protocol" {
+		t.Fatalf("expected protocol error, got %+v", problems)
+	}
+}
+
+func TestSecurityGroupRulesFromSpecReportsEveryInvalidField(t *testing.T) {
+	t.Parallel()
+
+	_, problems := securityGroupRulesFromSpec([]securityGroupRuleSpec{
+		{Direction: "sideways", Protocol: "tcp"},
+		{Direction: "ingress", Protocol: "icmp", Ports: &securityGroupRulePorts{List: []int{80}}, SourceRefs: []string{"not-a-cidr"}},
+	})
+	var pointers []string
+	for _, problem := range problems {
+		pointers = append(pointers, problem.source.Pointer)
+	}
+	want := []string{"/spec/rules/0/direction", "/spec/rules/1/ports", "/spec/rules/1/sourceRefs/0"}
+	if strings.Join(pointers, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected pointers %v, got %v", want, pointers)
+	}
+}
+
+func TestSecurityGroupRulesFromSpecConvertsPorts(t *testing.T) {
+	t.Parallel()
+
+	from := 22
+	converted, problems := securityGroupRulesFromSpec([]securityGroupRuleSpec{
+		{Direction: "Ingress", Protocol: "TCP", Ports: &securityGroupRulePorts{From: &from}, SourceRefs: []string{"55.44.33.11"}, Version: "ipv4"},
+	})
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems: %+v", problems)
+	}
+	if len(converted) != 1 || converted[0].Direction != "ingress" || converted[0].Protocol != "tcp" || converted[0].Version != "IPv4" {
+		t.Fatalf("unexpected rule: %+v", converted)
+	}
+	if len(converted[0].Ports) != 1 || converted[0].Ports[0].From != 22 || converted[0].Ports[0].To != 22 {
+		t.Fatalf("unexpected ports: %+v", converted[0].Ports)
+	}
+
+	back := toSecurityGroupRuleSpecs(converted)
+	if back[0].Ports == nil || back[0].Ports.From == nil || *back[0].Ports.From != 22 || back[0].Ports.To != nil {
+		t.Fatalf("single port did not round-trip: %+v", back[0].Ports)
+	}
+}
+
+func TestGetWorkspaceSecurityGroupIgnoresUnmanagedFirewalls(t *testing.T) {
+	t.Parallel()
+
+	provider := fakeSecurityGroupProvider{groups: map[string]hetzner.SecurityGroup{
+		"web":    {Name: "web", Labels: withSecaProviderLabels(nil, "t1", "ws1", resourceBindingKindSecurityGroup, "web", securityGroupRef("t1", "ws1", "web"))},
+		"other":  {Name: "other", Labels: withSecaProviderLabels(nil, "t1", "ws2", resourceBindingKindSecurityGroup, "other", securityGroupRef("t1", "ws2", "other"))},
+		"manual": {Name: "manual"},
+	}}
+	for name, want := range map[string]bool{"web": true, "other": false, "manual": false, "missing": false} {
+		got, err := getWorkspaceSecurityGroup(context.Background(), provider, "t1", "ws1", name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if (got != nil) != want {
+			t.Fatalf("%s: expected found=%t, got %+v", name, want, got)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
 )
 
 const resourceBindingKindSubnet = "subnet"
@@ -42,17 +44,13 @@ type subnetBindingPayload struct {
 
 func listSubnets(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, ok := scopeFromPath(w, r)
 		if !ok {
 			return
 		}
 		network := strings.ToLower(strings.TrimSpace(r.PathValue("network")))
 		if network == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "network name is required", r.URL.Path)
+			respondValidationProblem(w, r.URL.Path, fieldParameter("network", "network name is required"))
 			return
 		}
 		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
@@ -72,30 +70,15 @@ func listSubnets(store *state.Store) http.HandlerFunc {
 			if strings.ToLower(strings.TrimSpace(payload.Network)) != network {
 				continue
 			}
-			items = append(items, toSubnetResourceFromBinding(binding, payload, tenant, workspace, http.MethodGet, "active"))
+			items = append(items, toSubnetResourceFromBinding(binding, payload, tenant, workspace, verbList, "active"))
 		}
 		respondJSON(w, http.StatusOK, subnetIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + network + "/subnets", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + network + "/subnets", Verb: verbList},
 		})
 	}
 }
 
-func subnetCRUD(store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getSubnet(store)(w, r)
-		case http.MethodPut:
-			putSubnet(store)(w, r)
-		case http.MethodDelete:
-			deleteSubnet(store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getSubnet(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, network, name, ok := scopedNetworkNameFromPath(w, r, "subnet name is required")
@@ -120,22 +103,30 @@ func getSubnet(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid subnet payload", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusOK, toSubnetResourceFromBinding(*binding, payload, tenant, workspace, http.MethodGet, "active"))
+		respondJSON(w, http.StatusOK, toSubnetResourceFromBinding(*binding, payload, tenant, workspace, verbGet, "active"))
 	}
 }
 
-func putSubnet(store *state.Store) http.HandlerFunc {
+func putSubnet(provider NetworkProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, network, name, ok := scopedNetworkNameFromPath(w, r, "subnet name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		dryRun, ok := dryRunFromQuery(w, r)
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
 			return
 		}
 		var req subnetResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
 			return
 		}
 		ref := subnetRefKey(tenant, workspace, network, name)
@@ -144,49 +135,120 @@ func putSubnet(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load subnet", r.URL.Path)
 			return
 		}
+		if !precondition.checkBinding(w, r, existing) {
+			return
+		}
+		if existing != nil {
+			if existingPayload, parseErr := parseSubnetBinding(existing.ProviderRef); parseErr == nil {
+				violation, err := findImmutableFieldViolation(resourceBindingKindSubnet, existingPayload.Spec, req.Spec)
+				if err != nil {
+					respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to compare subnet spec", r.URL.Path)
+					return
+				}
+				if violation != nil {
+					respondImmutableFieldViolation(w, *violation, r.URL.Path)
+					return
+				}
+			}
+		}
+		var problems []fieldProblem
+		if req.Spec.Cidr.IPv4 == nil || strings.TrimSpace(*req.Spec.Cidr.IPv4) == "" {
+			problems = append(problems, fieldPointer("/spec/cidr/ipv4", "spec.cidr.ipv4 is required"))
+		}
+		if strings.TrimSpace(req.Spec.Zone) == "" {
+			problems = append(problems, fieldPointer("/spec/zone", "spec.zone is required"))
+		}
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
+			return
+		}
+		region, ok := resourceRegion(w, r, store, tenant, workspace, req.Metadata.Region)
+		if !ok {
+			return
+		}
+		subnetReq := hetzner.NetworkSubnetRequest{
+			NetworkName: network,
+			CIDR:        *req.Spec.Cidr.IPv4,
+			Zone:        req.Spec.Zone,
+		}
 		payload := subnetBindingPayload{
 			Name:    name,
 			Network: network,
-			Region:  runtimeRegionOrDefault(req.Metadata.Region),
+			Region:  region,
 			Labels:  req.Labels,
 			Spec:    req.Spec,
 		}
+		if dryRun {
+			if err := provider.ValidateSubnetCreate(ctx, subnetReq); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			binding := state.ResourceBinding{}
+			if existing != nil {
+				binding = *existing
+			}
+			respondJSON(w, http.StatusOK, toSubnetResourceFromBinding(binding, payload, tenant, workspace, upsertVerb(existing == nil), dryRunState))
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, existing); !ok {
+			return
+		}
+		added, err := provider.AddSubnet(ctx, subnetReq)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
 		raw, err := json.Marshal(payload)
 		if err != nil {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode subnet", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(r.Context(), state.ResourceBinding{
+		binding, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        resourceBindingKindSubnet,
 			SecaRef:     ref,
 			ProviderRef: string(raw),
 			Status:      "active",
-		}); err != nil {
+		}, precondition.version)
+		if err != nil {
+			// Without a binding nothing tracks the new Hetzner subnet, so
+			// take it back out.
+			if added {
+				if _, removeErr := provider.RemoveSubnet(ctx, network, subnetReq.CIDR); removeErr != nil {
+					tracing.Logf(ctx, "roll back subnet %s of network %s: %v", subnetReq.CIDR, network, removeErr)
+				}
+			}
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save subnet", r.URL.Path)
 			return
 		}
-		binding, err := store.GetResourceBinding(r.Context(), ref)
-		if err != nil || binding == nil {
-			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load subnet", r.URL.Path)
-			return
-		}
 		stateValue, code := "updating", http.StatusOK
 		if existing == nil {
 			stateValue, code = "creating", http.StatusCreated
 		}
-		respondJSON(w, code, toSubnetResourceFromBinding(*binding, payload, tenant, workspace, http.MethodPut, stateValue))
+		respondJSON(w, code, toSubnetResourceFromBinding(*binding, payload, tenant, workspace, upsertVerb(existing == nil), stateValue))
 	}
 }
 
-func deleteSubnet(store *state.Store) http.HandlerFunc {
+func deleteSubnet(provider NetworkProvider, computeProvider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, network, name, ok := scopedNetworkNameFromPath(w, r, "subnet name is required")
 		if !ok {
 			return
 		}
-		if _, ok := workspaceExecutionContext(w, r, store, tenant, workspace); !ok {
+		force, ok := forceFromQuery(w, r)
+		if !ok {
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
 			return
 		}
 		ref := subnetRefKey(tenant, workspace, network, name)
@@ -196,14 +258,49 @@ func deleteSubnet(store *state.Store) http.HandlerFunc {
 			return
 		}
 		if binding == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "subnet not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "subnet not found")
+			return
+		}
+		if !precondition.checkBinding(w, r, binding) {
 			return
 		}
-		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+		payload, err := parseSubnetBinding(binding.ProviderRef)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid subnet payload", r.URL.Path)
+			return
+		}
+		dependents, err := subnetDependents(r.Context(), store, tenant, workspace, network, name)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve subnet dependents", r.URL.Path)
+			return
+		}
+		if len(dependents) > 0 && !force {
+			respondDependentsConflict(w, r, "subnet "+name, dependents)
+			return
+		}
+		if precondition, ok = precondition.claimBinding(w, r, store, binding); !ok {
+			return
+		}
+		if len(dependents) > 0 {
+			if _, err := cascadeDependents(ctx, store, computeProvider, tenant, workspace, dependents); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if payload.Spec.Cidr.IPv4 != nil && strings.TrimSpace(*payload.Spec.Cidr.IPv4) != "" {
+			if _, err := provider.RemoveSubnet(ctx, network, *payload.Spec.Cidr.IPv4); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBindingIfVersion(r.Context(), ref, precondition.version); err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete subnet", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondDeleteAccepted(w, "")
 	}
 }
 
@@ -224,11 +321,11 @@ func toSubnetResourceFromBinding(
 	binding state.ResourceBinding,
 	payload subnetBindingPayload,
 	tenant,
-	workspace,
-	verb,
+	workspace string,
+	verb resourceVerb,
 	stateValue string,
 ) subnetResource {
-	createdAt := time.Now().UTC().Format(time.RFC3339)
+	createdAt := catalogTimestamp
 	updatedAt := createdAt
 	if !binding.CreatedAt.IsZero() {
 		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
@@ -244,7 +341,7 @@ func toSubnetResourceFromBinding(
 			Verb:            verb,
 			CreatedAt:       createdAt,
 			LastModifiedAt:  updatedAt,
-			ResourceVersion: 1,
+			ResourceVersion: binding.ResourceVersion,
 			APIVersion:      "v1",
 			Kind:            "subnet",
 			Ref:             "seca.network/v1/tenants/" + tenant + "/workspaces/" + workspace + "/networks/" + payload.Network + "/subnets/" + payload.Name,
@@ -0,0 +1,327 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const openAPIYAMLContentType = "application/yaml"
+
+// routeRegistry registers handlers on a mux and remembers their patterns, so
+// the OpenAPI document describes exactly the routes the mux serves.
+type routeRegistry struct {
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func (r *routeRegistry) HandleFunc(pattern string, handler http.HandlerFunc) {
+	r.mux.HandleFunc(pattern, handler)
+	r.patterns = append(r.patterns, pattern)
+}
+
+// openAPIDocument renders the document for every route of routes on first
+// use, so it includes routes registered after its own.
+type openAPIDocument struct {
+	routes  *routeRegistry
+	baseURL string
+	once    sync.Once
+	json    []byte
+	yaml    []byte
+}
+
+func (d *openAPIDocument) render() {
+	d.once.Do(func() {
+		doc := buildOpenAPIDocument(d.baseURL, d.routes.patterns)
+		d.json, _ = json.MarshalIndent(doc, "", "  ")
+		d.json = append(d.json, '\n')
+		d.yaml = marshalYAML(doc)
+	})
+}
+
+func (d *openAPIDocument) serveJSON(w http.ResponseWriter, _ *http.Request) {
+	d.render()
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(d.json)
+}
+
+func (d *openAPIDocument) serveYAML(w http.ResponseWriter, _ *http.Request) {
+	d.render()
+	w.Header().Set("Content-Type", openAPIYAMLContentType)
+	_, _ = w.Write(d.yaml)
+}
+
+// routeSchema describes the bodies of one route for the OpenAPI document.
+// Status defaults by method: 200 for GET, 200 and 201 for PUT, 202 for POST
+// and DELETE.
+type routeSchema struct {
+	request  any
+	response any
+	status   int
+}
+
+func buildOpenAPIDocument(baseURL string, patterns []string) map[string]any {
+	schemas := openAPISchemas{components: map[string]any{}}
+	problem := schemas.of(reflect.TypeOf(problemResponse{}))
+	paths := map[string]any{}
+	for _, pattern := range patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		route := routeSchemas[pattern]
+		params := []any{}
+		for _, segment := range strings.Split(path, "/") {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+				params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+			}
+		}
+		path = strings.ReplaceAll(path, "...}", "}")
+
+		responses := map[string]any{
+			"default": map[string]any{
+				"description": "Problem",
+				"content":     map[string]any{problemContentType: map[string]any{"schema": problem}},
+			},
+		}
+		success := map[string]any{"description": http.StatusText(http.StatusOK)}
+		if route.response != nil {
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(route.response))}}
+		}
+		for _, status := range routeStatuses(method, route.status) {
+			entry := make(map[string]any, len(success))
+			for key, value := range success {
+				entry[key] = value
+			}
+			entry["description"] = http.StatusText(status)
+			responses[strconv.Itoa(status)] = entry
+		}
+		operation := map[string]any{"parameters": params, "responses": responses}
+		if route.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(route.request))}},
+			}
+		}
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(method)] = operation
+	}
+	return map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": "SECA API proxy for Hetzner Cloud", "version": "v1"},
+		"servers":    []any{map[string]any{"url": strings.TrimRight(baseURL, "/")}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas.components},
+	}
+}
+
+func routeStatuses(method string, status int) []int {
+	if status != 0 {
+		return []int{status}
+	}
+	switch method {
+	case http.MethodPut:
+		return []int{http.StatusOK, http.StatusCreated}
+	case http.MethodPost, http.MethodDelete:
+		return []int{http.StatusAccepted}
+	}
+	return []int{http.StatusOK}
+}
+
+// openAPISchemas derives JSON schemas from the response structs through their
+// json tags. Named structs become components referenced by $ref.
+type openAPISchemas struct {
+	components map[string]any
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	refObjectType = reflect.TypeOf(refObject{})
+)
+
+func (s openAPISchemas) of(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == refObjectType:
+		// refObject marshals to its bare reference and accepts both forms.
+		s.components["Reference"] = map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "object", "properties": map[string]any{"resource": map[string]any{"type": "string"}}},
+		}}
+		return map[string]any{"$ref": "#/components/schemas/Reference"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := componentName(t)
+		if _, ok := s.components[name]; !ok {
+			// Claim the name first so recursive types terminate.
+			s.components[name] = map[string]any{}
+			s.components[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s openAPISchemas) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.collectFields(t, properties, &required)
+	out := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+	return out
+}
+
+func (s openAPISchemas) collectFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.collectFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// marshalYAML writes the document built by buildOpenAPIDocument as YAML:
+// block maps with sorted keys, block sequences and JSON-quoted strings.
+func marshalYAML(value any) []byte {
+	var b strings.Builder
+	writeYAML(&b, value, 0)
+	return []byte(b.String())
+}
+
+func writeYAML(b *strings.Builder, value any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(pad + strconv.Quote(key) + ":")
+			writeYAMLChild(b, v[key], indent+1)
+		}
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range v {
+			b.WriteString(pad + "-")
+			writeYAMLChild(b, item, indent+1)
+		}
+	case []string:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		writeYAML(b, items, indent)
+	default:
+		b.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLChild writes the value after a "key:" or "-" that is already on
+// the line: scalars and empty collections inline, the rest on the lines below.
+func writeYAMLChild(b *strings.Builder, value any, indent int) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	case []string:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, value, indent)
+}
+
+func yamlScalar(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
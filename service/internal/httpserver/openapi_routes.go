@@ -0,0 +1,118 @@
+package httpserver
+
+import "net/http"
+
+// routeSchemas holds the request and response bodies of every public route,
+// keyed by its mux pattern. A route missing here still shows up in the
+// OpenAPI document, but without body schemas.
+var routeSchemas = map[string]routeSchema{
+	"GET /healthz":           {response: statusResponse{}},
+	"GET /readyz":            {response: statusResponse{}},
+	"GET /openapi.json":      {},
+	"GET /openapi.yaml":      {},
+	"GET /.wellknown/secapi": {response: wellknownResponse{}},
+	"GET /v1/tenants/{tenant}/.wellknown/secapi": {response: wellknownResponse{}},
+	"GET /v1/limits":         {response: limitsResponse{}},
+	"GET /v1/regions":        {response: regionIterator{}},
+	"GET /v1/regions/{name}": {response: regionResource{}},
+
+	"GET /v1/tenants/{tenant}/roles":           {response: authIterator{}},
+	"GET /v1/tenants/{tenant}/roles/{name}":    {response: authResource{}},
+	"PUT /v1/tenants/{tenant}/roles/{name}":    {request: authResource{}, response: authResource{}},
+	"DELETE /v1/tenants/{tenant}/roles/{name}": {response: deleteAcceptedResponse{}},
+	"POST /v1/tenants/{tenant}/roles/{action}": {response: authResource{}, status: http.StatusOK},
+
+	"GET /v1/tenants/{tenant}/role-assignments":           {response: authIterator{}},
+	"GET /v1/tenants/{tenant}/role-assignments/{name}":    {response: authResource{}},
+	"PUT /v1/tenants/{tenant}/role-assignments/{name}":    {request: authResource{}, response: authResource{}},
+	"DELETE /v1/tenants/{tenant}/role-assignments/{name}": {response: deleteAcceptedResponse{}},
+	"POST /v1/tenants/{tenant}/role-assignments/{action}": {response: authResource{}, status: http.StatusOK},
+
+	"GET /workspace/v1/tenants/{tenant}/workspaces":           {response: workspaceIterator{}},
+	"GET /workspace/v1/tenants/{tenant}/workspaces/{name}":    {response: workspaceResource{}},
+	"PUT /workspace/v1/tenants/{tenant}/workspaces/{name}":    {request: workspaceResource{}, response: workspaceResource{}},
+	"DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}": {response: deleteAcceptedResponse{}},
+	"POST /workspace/v1/tenants/{tenant}/workspaces/{action}": {response: workspaceResource{}, status: http.StatusOK},
+
+	"GET /compute/v1/tenants/{tenant}/skus":        {response: computeSKUIterator{}},
+	"GET /compute/v1/tenants/{tenant}/skus/{name}": {response: computeSKUResource{}},
+
+	"GET /storage/v1/tenants/{tenant}/skus":                            {response: storageSKUIterator{}},
+	"GET /storage/v1/tenants/{tenant}/skus/{name}":                     {response: storageSKUResource{}},
+	"GET /storage/v1/tenants/{tenant}/skus/hcloud-volume/availability": {response: volumeAvailabilityIterator{}},
+
+	"GET /network/v1/tenants/{tenant}/skus":        {response: networkSKUIterator{}},
+	"GET /network/v1/tenants/{tenant}/skus/{name}": {response: networkSKUResource{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks":           {response: networkIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}":    {response: networkResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}":    {request: networkResource{}, response: networkResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables":           {response: routeTableIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}":    {response: routeTableResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}":    {request: routeTableResource{}, response: routeTableResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets":           {response: subnetIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}":    {response: subnetResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}":    {request: subnetResource{}, response: subnetResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/nics":           {response: nicIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}":    {response: nicResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}":    {request: nicResource{}, response: nicResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips":           {response: publicIPIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}":    {response: publicIPResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}":    {request: publicIPResource{}, response: publicIPResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers":           {response: loadBalancerIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}":    {response: loadBalancerResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}":    {request: loadBalancerResource{}, response: loadBalancerResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups":           {response: securityGroupIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}":    {response: securityGroupResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}":    {request: securityGroupResource{}, response: securityGroupResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways":           {response: internetGatewayIterator{}},
+	"GET /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}":    {response: internetGatewayResource{}},
+	"PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}":    {request: internetGatewayResource{}, response: internetGatewayResource{}},
+	"DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /storage/v1/tenants/{tenant}/images":           {response: imageIterator{}},
+	"GET /storage/v1/tenants/{tenant}/images/{name}":    {response: imageResource{}},
+	"PUT /storage/v1/tenants/{tenant}/images/{name}":    {request: imageResource{}, response: imageResource{}},
+	"DELETE /storage/v1/tenants/{tenant}/images/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances":                   {response: instanceIterator{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:start":            {response: bulkInstanceActionResponse{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:stop":             {response: bulkInstanceActionResponse{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:restart":          {response: bulkInstanceActionResponse{}},
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}":            {response: instanceResource{}},
+	"PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}":            {request: instanceUpsertRequest{}, response: instanceResource{}},
+	"DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}":         {response: deleteAcceptedResponse{}},
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/metrics":    {response: instanceMetricsResponse{}},
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/operations": {response: operationList{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/snapshot":  {response: instanceSnapshotResponse{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/start":     {response: map[string]string{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/stop":      {response: map[string]string{}},
+	"POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/restart":   {response: map[string]string{}},
+
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups":           {response: placementGroupIterator{}},
+	"GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}":    {response: placementGroupResource{}},
+	"PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}":    {request: placementGroupResource{}, response: placementGroupResource{}},
+	"DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}": {response: deleteAcceptedResponse{}},
+
+	"GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages":                   {response: blockStorageIterator{}},
+	"GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}":            {response: blockStorageResource{}},
+	"PUT /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}":            {request: blockStorageUpsertRequest{}, response: blockStorageResource{}},
+	"DELETE /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}":         {response: deleteAcceptedResponse{}},
+	"GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/operations": {response: operationList{}},
+	"POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/attach":    {request: attachBlockStorageRequest{}, response: attachBlockStorageResponse{}},
+	"POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/detach":    {response: map[string]string{}},
+}
@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/fake"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// registeredPublicPatterns reads the patterns New registers on the public
+// mux from its source, independently of the registry the document is built
+// from.
+func registeredPublicPatterns(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse server.go: %v", err)
+	}
+	var patterns []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || selector.Sel.Name != "HandleFunc" {
+			return true
+		}
+		if receiver, ok := selector.X.(*ast.Ident); !ok || receiver.Name != "publicRoutes" {
+			return true
+		}
+		literal, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			t.Fatalf("public route registered with a non-literal pattern at %v", call.Pos())
+		}
+		pattern, _ := strconv.Unquote(literal.Value)
+		patterns = append(patterns, pattern)
+		return true
+	})
+	if len(patterns) == 0 {
+		t.Fatal("found no public routes in server.go")
+	}
+	return patterns
+}
+
+func TestOpenAPIDocumentCoversEveryPublicRoute(t *testing.T) {
+	t.Parallel()
+
+	provider := fake.New()
+	servers := New(config.Config{PublicBaseURL: "https://seca.example"}, &state.Store{}, provider, provider, provider, provider, nil)
+
+	rec := httptest.NewRecorder()
+	servers.Public.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the JSON document, got %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Fatalf("expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	registered := map[string]bool{}
+	for _, pattern := range registeredPublicPatterns(t) {
+		registered[pattern] = true
+		method, path, _ := strings.Cut(pattern, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("%s is served but missing from the OpenAPI document", pattern)
+		}
+		if _, ok := routeSchemas[pattern]; !ok {
+			t.Errorf("%s has no entry in routeSchemas", pattern)
+		}
+	}
+	for pattern := range routeSchemas {
+		if !registered[pattern] {
+			t.Errorf("routeSchemas describes %s, which is not registered", pattern)
+		}
+	}
+	for _, name := range []string{"InstanceResource", "BlockStorageResource", "InstanceIterator", "ProblemResponse"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("expected component %s, got %d components", name, len(doc.Components.Schemas))
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil)
+	req.Header.Set("Accept", openAPIYAMLContentType)
+	servers.Public.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != openAPIYAMLContentType || !strings.HasPrefix(rec.Body.String(), `"components":`) {
+		t.Fatalf("expected the YAML document, got %d %q: %.200s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestMarshalYAMLNestsBlocks(t *testing.T) {
+	t.Parallel()
+
+	got := string(marshalYAML(map[string]any{
+		"b": []any{map[string]any{"name": "x", "required": true}},
+		"a": map[string]any{},
+		"c": []string{"one"},
+	}))
+	want := "\"a\": {}\n\"b\":\n  -\n    \"name\": \"x\"\n    \"required\": true\n\"c\":\n  - \"one\"\n"
+	if got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,107 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const (
+	operationListDefaultLimit = 50
+	operationListMaxLimit     = 1000
+)
+
+// operationPhases are the phases an operation can be recorded in.
+var operationPhases = []string{"accepted", "running", "succeeded", "failed", "interrupted"}
+
+// operationListStore is the part of *state.Store the operation listing uses.
+type operationListStore interface {
+	ListOperationsBySecaRef(ctx context.Context, secaRef string, filter state.OperationFilter) ([]state.OperationRecord, error)
+}
+
+type operationResponse struct {
+	OperationID      string `json:"operationId"`
+	Phase            string `json:"phase"`
+	ProviderActionID string `json:"providerActionId,omitempty"`
+	Error            string `json:"error,omitempty"`
+	CreatedAt        string `json:"createdAt"`
+	UpdatedAt        string `json:"updatedAt"`
+}
+
+type operationList struct {
+	Items     []operationResponse `json:"items"`
+	SkipToken string              `json:"skipToken,omitempty"`
+}
+
+// listResourceOperations lists the operations recorded for one resource,
+// newest first, so clients can see why an accepted change did not land. The
+// history outlives the resource, so a deleted resource still lists the
+// operations that deleted it. A full page carries a skipToken.
+func listResourceOperations(store operationListStore, kind string, ref func(tenant, workspace, name string) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, workspace, name, ok := scopedNameFromPath(w, r, kind+" name is required")
+		if !ok {
+			return
+		}
+		filter, problem := parseOperationFilter(r.URL.Query())
+		if problem != nil {
+			respondValidationProblem(w, r.URL.Path, *problem)
+			return
+		}
+		operations, err := store.ListOperationsBySecaRef(r.Context(), ref(tenant, workspace, name), filter)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list operations", r.URL.Path)
+			return
+		}
+		list := operationList{Items: make([]operationResponse, 0, len(operations))}
+		for _, operation := range operations {
+			list.Items = append(list.Items, operationResponse{
+				OperationID:      operation.OperationID,
+				Phase:            operation.Phase,
+				ProviderActionID: operation.ProviderActionID,
+				Error:            operation.ErrorText,
+				CreatedAt:        operation.CreatedAt.Format(time.RFC3339Nano),
+				UpdatedAt:        operation.UpdatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		if len(operations) == filter.Limit {
+			list.SkipToken = strconv.FormatInt(operations[len(operations)-1].ID, 10)
+		}
+		respondJSON(w, http.StatusOK, list)
+	}
+}
+
+// parseOperationFilter reads phase, limit and skipToken.
+func parseOperationFilter(query url.Values) (state.OperationFilter, *fieldProblem) {
+	filter := state.OperationFilter{Limit: operationListDefaultLimit}
+	if raw := strings.ToLower(strings.TrimSpace(query.Get("phase"))); raw != "" {
+		if !slices.Contains(operationPhases, raw) {
+			problem := fieldParameter("phase", "phase must be one of %s", strings.Join(operationPhases, ", "))
+			return filter, &problem
+		}
+		filter.Phase = raw
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > operationListMaxLimit {
+			problem := fieldParameter("limit", "limit must be between 1 and %d", operationListMaxLimit)
+			return filter, &problem
+		}
+		filter.Limit = limit
+	}
+	if raw := query.Get("skipToken"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID < 1 {
+			problem := fieldParameter("skipToken", "skipToken is invalid")
+			return filter, &problem
+		}
+		filter.BeforeID = beforeID
+	}
+	return filter, nil
+}
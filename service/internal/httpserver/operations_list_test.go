@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeOperationListStore struct {
+	secaRef    string
+	filter     state.OperationFilter
+	operations []state.OperationRecord
+}
+
+func (s *fakeOperationListStore) ListOperationsBySecaRef(_ context.Context, secaRef string, filter state.OperationFilter) ([]state.OperationRecord, error) {
+	s.secaRef, s.filter = secaRef, filter
+	return s.operations, nil
+}
+
+func TestListResourceOperationsFiltersAndPaginates(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	store := &fakeOperationListStore{operations: []state.OperationRecord{
+		{ID: 31, OperationID: "op-b", Phase: "failed", ProviderActionID: "9002", ErrorText: "server is locked", CreatedAt: created, UpdatedAt: created.Add(time.Minute)},
+		{ID: 30, OperationID: "op-a", Phase: "failed", CreatedAt: created.Add(-time.Hour), UpdatedAt: created.Add(-time.Hour)},
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/operations", listResourceOperations(store, "instance", computeInstanceRef))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/acme/workspaces/prod/instances/Web-1/operations?phase=failed&limit=2&skipToken=40", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if store.secaRef != computeInstanceRef("acme", "prod", "web-1") {
+		t.Fatalf("unexpected seca ref %q", store.secaRef)
+	}
+	if want := (state.OperationFilter{Phase: "failed", BeforeID: 40, Limit: 2}); store.filter != want {
+		t.Fatalf("expected filter %+v, got %+v", want, store.filter)
+	}
+	var list operationList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 2 || list.SkipToken != "30" {
+		t.Fatalf("unexpected page: %+v", list)
+	}
+	if got := list.Items[0]; got.OperationID != "op-b" || got.ProviderActionID != "9002" || got.Error != "server is locked" || got.UpdatedAt != "2026-03-04T05:07:07Z" {
+		t.Fatalf("unexpected newest operation: %+v", got)
+	}
+}
+
+func TestListResourceOperationsRejectsBadQuery(t *testing.T) {
+	t.Parallel()
+
+	handler := listResourceOperations(&fakeOperationListStore{}, "instance", computeInstanceRef)
+	for _, query := range []string{"phase=pending", "limit=0", "limit=5000", "skipToken=abc"} {
+		req := httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/acme/workspaces/prod/instances/web-1/operations?"+query, nil)
+		req.SetPathValue("tenant", "acme")
+		req.SetPathValue("workspace", "prod")
+		req.SetPathValue("name", "web-1")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// versionPrecondition is the resourceVersion a client expects a resource to be
+// at before it is changed. It comes from If-Match, answered with 412 when it
+// does not hold, or from metadata.resourceVersion in a PUT body, answered with
+// 409 like other state conflicts. A zero version means the write is
+// unconditional.
+type versionPrecondition struct {
+	version    int64
+	fromHeader bool
+	anyVersion bool
+}
+
+// parseVersionPrecondition reads If-Match and, for PUT, the resourceVersion
+// the client echoed back in the body. When both are given they must agree.
+func parseVersionPrecondition(w http.ResponseWriter, r *http.Request, bodyVersion int64) (versionPrecondition, bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" {
+		return versionPrecondition{version: max(bodyVersion, 0)}, true
+	}
+	if raw == "*" {
+		return versionPrecondition{version: max(bodyVersion, 0), fromHeader: true, anyVersion: true}, true
+	}
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(raw, "W/"), `"`), 10, 64)
+	if err != nil || version <= 0 {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "If-Match must be a resourceVersion", r.URL.Path)
+		return versionPrecondition{}, false
+	}
+	if bodyVersion > 0 && bodyVersion != version {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "If-Match and metadata.resourceVersion disagree", r.URL.Path)
+		return versionPrecondition{}, false
+	}
+	return versionPrecondition{version: version, fromHeader: true}, true
+}
+
+func (p versionPrecondition) conditional() bool {
+	return p.version != 0 || p.anyVersion
+}
+
+// check compares the precondition with the stored resource, which exists
+// when found is true, and writes the error response when it does not hold.
+func (p versionPrecondition) check(w http.ResponseWriter, r *http.Request, found bool, current int64) bool {
+	if !p.conditional() {
+		return true
+	}
+	if !found {
+		p.respondMismatch(w, r, "resource does not exist")
+		return false
+	}
+	if p.version != 0 && p.version != current {
+		p.respondMismatch(w, r, fmt.Sprintf("resourceVersion %d does not match current version %d", p.version, current))
+		return false
+	}
+	return true
+}
+
+func (p versionPrecondition) checkBinding(w http.ResponseWriter, r *http.Request, binding *state.ResourceBinding) bool {
+	if binding == nil {
+		return p.check(w, r, false, 0)
+	}
+	return p.check(w, r, true, binding.ResourceVersion)
+}
+
+// claimBinding moves a conditionally written binding to its next
+// resourceVersion before the handler changes the provider, so a request that
+// lost the race is refused while the provider is still untouched. The
+// returned precondition expects the claimed version and is the one the final
+// write or delete must use. Unconditional requests and new bindings are
+// returned unchanged.
+func (p versionPrecondition) claimBinding(w http.ResponseWriter, r *http.Request, store *state.Store, binding *state.ResourceBinding) (versionPrecondition, bool) {
+	if p.version == 0 || binding == nil {
+		return p, true
+	}
+	claimed, err := store.UpsertResourceBindingIfVersion(r.Context(), *binding, p.version)
+	if err != nil {
+		if !p.respondStoreError(w, r, err) {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save resource binding", r.URL.Path)
+		}
+		return p, false
+	}
+	p.version = claimed.ResourceVersion
+	return p, true
+}
+
+// respondStoreError answers ErrVersionConflict, raised when another write won
+// the race after check passed, and reports whether err was one.
+func (p versionPrecondition) respondStoreError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, state.ErrVersionConflict) {
+		return false
+	}
+	p.respondMismatch(w, r, "resource was modified concurrently")
+	return true
+}
+
+func (p versionPrecondition) respondMismatch(w http.ResponseWriter, r *http.Request, detail string) {
+	if p.fromHeader {
+		respondProblem(w, http.StatusPreconditionFailed, "http://secapi.cloud/errors/precondition-failed", "Precondition Failed", detail, r.URL.Path)
+		return
+	}
+	respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", detail, r.URL.Path)
+}
@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVersionPrecondition(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		ifMatch     string
+		bodyVersion int64
+		wantOK      bool
+		want        versionPrecondition
+	}{
+		{name: "unconditional", wantOK: true},
+		{name: "body only", bodyVersion: 3, wantOK: true, want: versionPrecondition{version: 3}},
+		{name: "quoted header", ifMatch: `"4"`, wantOK: true, want: versionPrecondition{version: 4, fromHeader: true}},
+		{name: "weak header", ifMatch: `W/"5"`, wantOK: true, want: versionPrecondition{version: 5, fromHeader: true}},
+		{name: "header matches body", ifMatch: "6", bodyVersion: 6, wantOK: true, want: versionPrecondition{version: 6, fromHeader: true}},
+		{name: "wildcard", ifMatch: "*", wantOK: true, want: versionPrecondition{fromHeader: true, anyVersion: true}},
+		{name: "header disagrees with body", ifMatch: "6", bodyVersion: 7},
+		{name: "not a version", ifMatch: `"abc"`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/v1/tenants/t/roles/r", nil)
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		got, ok := parseVersionPrecondition(rec, req, tc.bodyVersion)
+		if ok != tc.wantOK {
+			t.Fatalf("%s: expected ok=%t, got %t (%d)", tc.name, tc.wantOK, ok, rec.Code)
+		}
+		if !ok {
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s: expected 400, got %d", tc.name, rec.Code)
+			}
+			continue
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %+v, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestVersionPreconditionCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		precondition versionPrecondition
+		found        bool
+		current      int64
+		wantCode     int
+	}{
+		{name: "unconditional create", found: false, wantCode: 0},
+		{name: "matching header", precondition: versionPrecondition{version: 2, fromHeader: true}, found: true, current: 2, wantCode: 0},
+		{name: "stale header", precondition: versionPrecondition{version: 1, fromHeader: true}, found: true, current: 2, wantCode: http.StatusPreconditionFailed},
+		{name: "stale body", precondition: versionPrecondition{version: 1}, found: true, current: 2, wantCode: http.StatusConflict},
+		{name: "missing resource", precondition: versionPrecondition{version: 1, fromHeader: true}, wantCode: http.StatusPreconditionFailed},
+		{name: "wildcard on missing resource", precondition: versionPrecondition{fromHeader: true, anyVersion: true}, wantCode: http.StatusPreconditionFailed},
+		{name: "wildcard on existing resource", precondition: versionPrecondition{fromHeader: true, anyVersion: true}, found: true, current: 9, wantCode: 0},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		ok := tc.precondition.check(rec, httptest.NewRequest(http.MethodPut, "/x", nil), tc.found, tc.current)
+		if ok != (tc.wantCode == 0) {
+			t.Fatalf("%s: expected ok=%t, got %t", tc.name, tc.wantCode == 0, ok)
+		}
+		if !ok && rec.Code != tc.wantCode {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.wantCode, rec.Code)
+		}
+	}
+}
+
+func TestWorkspacePutHonoursResourceVersion(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	handler := http.NewServeMux()
+	handler.HandleFunc("PUT /workspace/v1/tenants/{tenant}/workspaces/{name}", putWorkspace(store, fakeRegionProvider{}))
+	handler.HandleFunc("DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}", deleteWorkspace(store, &fakeComputeProvider{}, nil))
+	path := "/workspace/v1/tenants/" + tenant + "/workspaces/ws"
+
+	put := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	version := func(rec *httptest.ResponseRecorder) int64 {
+		t.Helper()
+		var out workspaceResource
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode workspace: %v", err)
+		}
+		return out.Metadata.ResourceVersion
+	}
+
+	if rec := put(`"1"`, `{}`); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for If-Match on a missing workspace, got %d", rec.Code)
+	}
+	created := put("", `{}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", created.Code, created.Body.String())
+	}
+	first := version(created)
+
+	updated := put(fmt.Sprintf(`"%d"`, first), `{}`)
+	if updated.Code != http.StatusOK || version(updated) != first+1 {
+		t.Fatalf("expected matching If-Match to bump the version to %d, got %d: %s", first+1, updated.Code, updated.Body.String())
+	}
+	if rec := put(fmt.Sprintf(`"%d"`, first), `{}`); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale If-Match, got %d", rec.Code)
+	}
+	if rec := put("", fmt.Sprintf(`{"metadata":{"resourceVersion":%d}}`, first)); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a stale metadata.resourceVersion, got %d", rec.Code)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, path, nil)
+	del.Header.Set("If-Match", fmt.Sprintf(`"%d"`, first))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, del)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale delete, got %d", rec.Code)
+	}
+	if ws, err := store.GetWorkspace(context.Background(), tenant, "ws"); err != nil || ws == nil {
+		t.Fatalf("expected workspace to survive a stale delete, got %v, %v", ws, err)
+	}
+}
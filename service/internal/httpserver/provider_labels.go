@@ -3,6 +3,9 @@ package httpserver
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +16,7 @@ const (
 	secaLabelKind      = "seca.kind"
 	secaLabelName      = "seca.name"
 	secaLabelRef       = "seca.ref"
+	secaLabelSKU       = "seca.sku"
 )
 
 func withSecaProviderLabels(
@@ -45,3 +49,96 @@ func compactLabelValue(value string) string {
 	sum := sha1.Sum([]byte(value))
 	return "sha1-" + hex.EncodeToString(sum[:8])
 }
+
+// providerLabelsInScope reports whether provider labels place a resource in
+// the given tenant/workspace. Resources without SECA labels belong to nobody.
+func providerLabelsInScope(labels map[string]string, tenant, workspace string) bool {
+	if labels[secaLabelManaged] != "true" {
+		return false
+	}
+	return strings.EqualFold(labels[secaLabelTenant], compactLabelValue(tenant)) &&
+		strings.EqualFold(labels[secaLabelWorkspace], compactLabelValue(workspace))
+}
+
+// reservedLabelProblems reports user labels under the seca. prefix, which is
+// reserved for the system labels set by withSecaProviderLabels.
+func reservedLabelProblems(labels map[string]string) []fieldProblem {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if strings.HasPrefix(k, "seca.") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	problems := make([]fieldProblem, 0, len(keys))
+	for _, k := range keys {
+		problems = append(problems, fieldPointer("/labels/"+escapeJSONPointerToken(k), "label %q uses the reserved seca. prefix", k))
+	}
+	return problems
+}
+
+// userProviderLabels returns the labels a caller set, without the system
+// labels added by withSecaProviderLabels.
+func userProviderLabels(labels map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range labels {
+		if strings.HasPrefix(k, "seca.") {
+			continue
+		}
+		out[k] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// responseLabels returns the labels to show on a resource. System labels are
+// only included when the caller asked for them with ?includeSystemLabels=true.
+func responseLabels(labels map[string]string, includeSystem bool) map[string]string {
+	if !includeSystem {
+		return userProviderLabels(labels)
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+// includeSystemLabelsFromQuery parses the includeSystemLabels query flag and
+// answers 400 when it is not a boolean.
+func includeSystemLabelsFromQuery(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("includeSystemLabels"))
+	if raw == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "includeSystemLabels must be a boolean", r.URL.Path)
+		return false, false
+	}
+	return parsed, true
+}
+
+// dryRunState is the status state of a resource returned by a PUT with
+// ?dryRun=true, which validates the request without creating anything.
+const dryRunState = "dry-run"
+
+// dryRunFromQuery parses the dryRun query flag of PUT requests and answers
+// 400 when it is not a boolean.
+func dryRunFromQuery(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("dryRun"))
+	if raw == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "dryRun must be a boolean", r.URL.Path)
+		return false, false
+	}
+	return parsed, true
+}
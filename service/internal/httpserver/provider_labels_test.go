@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestResourceLabelsRoundTripThroughProviderLabels(t *testing.T) {
+	t.Parallel()
+
+	user := map[string]string{"team": "payments", "env": "prod"}
+	stored := withSecaProviderLabels(user, "t1", "ws1", "instance", "vm1", computeInstanceRef("t1", "ws1", "vm1"))
+	stored["seca.extra"] = "provider-side"
+
+	instance := hetzner.Instance{Name: "vm1", SKUName: "cx22", ImageName: "ubuntu-24.04", Labels: stored}
+	volume := hetzner.BlockStorage{Name: "vol1", SizeGB: 10, Labels: stored}
+	for name, labels := range map[string]map[string]string{
+		"instance get":       toInstanceResource("t1", "ws1", instance, verbGet, "active", nil, false).Labels,
+		"instance list":      toInstanceResource("t1", "ws1", instance, verbList, "active", nil, false).Labels,
+		"block storage get":  toBlockStorageResource("t1", "ws1", volume, verbGet, "active", nil, false).Labels,
+		"block storage list": toBlockStorageResource("t1", "ws1", volume, verbList, "active", nil, false).Labels,
+		"instance put":       toInstanceResource("t1", "ws1", instance, verbCreate, "creating", nil, false).Labels,
+		"block storage put":  toBlockStorageResource("t1", "ws1", volume, verbCreate, "creating", nil, false).Labels,
+	} {
+		if len(labels) != len(user) || labels["team"] != "payments" || labels["env"] != "prod" {
+			t.Fatalf("%s: expected only user labels, got %v", name, labels)
+		}
+	}
+
+	withSystem := toInstanceResource("t1", "ws1", instance, verbGet, "active", nil, true)
+	if withSystem.Labels["seca.extra"] != "provider-side" || withSystem.Labels[secaLabelTenant] != "t1" {
+		t.Fatalf("expected system labels with includeSystemLabels, got %v", withSystem.Labels)
+	}
+
+	raw, err := json.Marshal(toBlockStorageResource("t1", "ws1", volume, verbGet, "active", nil, false))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Labels["team"] != "payments" {
+		t.Fatalf("expected labels in JSON body, got %s", raw)
+	}
+}
+
+func TestIncludeSystemLabelsFromQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		query  string
+		want   bool
+		wantOK bool
+	}{
+		{query: "", want: false, wantOK: true},
+		{query: "?includeSystemLabels=true", want: true, wantOK: true},
+		{query: "?includeSystemLabels=false", want: false, wantOK: true},
+		{query: "?includeSystemLabels=maybe", want: false, wantOK: false},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/compute/v1/tenants/t1/workspaces/ws1/instances"+tc.query, nil)
+		got, ok := includeSystemLabelsFromQuery(rec, req)
+		if got != tc.want || ok != tc.wantOK {
+			t.Fatalf("%q: expected (%t, %t), got (%t, %t)", tc.query, tc.want, tc.wantOK, got, ok)
+		}
+		if !ok && rec.Code != 400 {
+			t.Fatalf("%q: expected 400, got %d", tc.query, rec.Code)
+		}
+	}
+}
+
+func TestReservedLabelProblemsFlagsSystemPrefix(t *testing.T) {
+	t.Parallel()
+
+	problems := reservedLabelProblems(map[string]string{"team": "payments", "seca.tenant": "other", "seca.internet-gateway/sku": "cx22"})
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %+v", problems)
+	}
+	if problems[0].source.Pointer != "/labels/seca.internet-gateway~1sku" || problems[1].source.Pointer != "/labels/seca.tenant" {
+		t.Fatalf("unexpected pointers: %+v", problems)
+	}
+	if got := reservedLabelProblems(map[string]string{"team": "payments"}); len(got) != 0 {
+		t.Fatalf("expected user labels to pass, got %+v", got)
+	}
+}
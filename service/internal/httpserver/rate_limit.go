@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitClassRead  = "read"
+	rateLimitClassWrite = "write"
+)
+
+// rateLimiter decides whether one more request under key may pass now. When
+// it may not, it returns how long until it would. The in-memory
+// tokenBucketLimiter counts per process; a shared store can implement this
+// to limit across replicas.
+type rateLimiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// tokenBucketLimiter refills every key's bucket at rate tokens per second up
+// to burst tokens.
+type tokenBucketLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucketLimiter returns nil when rate is 0, which disables the limit.
+// A burst of 0 means a burst of rate.
+func newTokenBucketLimiter(rate, burst int) rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucketLimiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+func (l *tokenBucketLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// requireRateLimit answers 429 with Retry-After once the tenant in the path
+// has used up its read (GET and HEAD) or write budget. A nil limiter lets
+// every request of its class through; onReject sees every rejection.
+func requireRateLimit(reads, writes rateLimiter, onReject func(tenant, class string), next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		limiter, class := writes, rateLimitClassWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			limiter, class = reads, rateLimitClassRead
+		}
+		if tenant == "" || limiter == nil {
+			next(w, r)
+			return
+		}
+		if ok, wait := limiter.Allow(tenant); !ok {
+			onReject(tenant, class)
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			respondProblem(w, http.StatusTooManyRequests, "http://secapi.cloud/errors/rate-limited", "Too Many Requests", "tenant "+class+" rate limit exceeded", r.URL.Path)
+			return
+		}
+		next(w, r)
+	}
+}
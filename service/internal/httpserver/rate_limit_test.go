@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestRespondFromErrorPropagatesRetryAfterOnRateLimit(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "12")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"code":"rate_limit_exceeded","message":"limit reached"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	client := hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test"), hcloud.WithRetryOpts(hcloud.RetryOpts{MaxRetries: 0}))
+	_, _, err := client.Location.GetByName(context.Background(), "fsn1")
+
+	rec := httptest.NewRecorder()
+	respondFromError(rec, err, "/v1/regions/fsn1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "12" {
+		t.Fatalf("expected Retry-After 12, got %q", got)
+	}
+}
+
+func TestRespondFromErrorAnswersGatewayTimeoutForProviderTimeouts(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	respondFromError(rec, fmt.Errorf("start instance: %w", hetzner.ErrTimeout), "/v1/instances/vm")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRetryAfterSecondsRoundsUp(t *testing.T) {
+	t.Parallel()
+
+	for wait, want := range map[time.Duration]string{
+		0:                       "1",
+		1500 * time.Millisecond: "2",
+		30 * time.Second:        "30",
+	} {
+		if got := retryAfterSeconds(wait); got != want {
+			t.Fatalf("%s: expected %q, got %q", wait, want, got)
+		}
+	}
+}
+
+func TestRequireRateLimitSeparatesTenantsAndClasses(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	writes := newTokenBucketLimiter(1, 2).(*tokenBucketLimiter)
+	writes.now = func() time.Time { return now }
+	var rejected []string
+	handler := requireRateLimit(nil, writes, func(tenant, class string) {
+		rejected = append(rejected, tenant+"/"+class)
+	}, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/tenants/{tenant}/roles/{name}", handler)
+	serve := func(method, tenant string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, "/v1/tenants/"+tenant+"/roles/r1", nil))
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(http.MethodPut, "acme"); rec.Code != http.StatusNoContent {
+			t.Fatalf("write %d: expected the burst to pass, got %d", i, rec.Code)
+		}
+	}
+	rec := serve(http.MethodPut, "acme")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After 1, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve(http.MethodGet, "acme"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected reads to be unlimited, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPut, "other"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected another tenant to have its own bucket, got %d", rec.Code)
+	}
+	now = now.Add(time.Second)
+	if rec := serve(http.MethodPut, "acme"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the bucket to refill, got %d", rec.Code)
+	}
+	if len(rejected) != 1 || rejected[0] != "acme/write" {
+		t.Fatalf("expected one rejected write for acme, got %v", rejected)
+	}
+}
@@ -0,0 +1,190 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBodyBytes caps request bodies when no limit was configured.
+const defaultMaxBodyBytes = 1 << 20
+
+type bodyDecodingKey struct{}
+
+// bodyDecoding is how decodeJSONBody reads the requests of a listener.
+type bodyDecoding struct {
+	maxBytes int64
+	strict   bool
+}
+
+// withBodyDecoding makes every request served by next decode its body with
+// the given size limit; strict rejects fields the request types do not know.
+func withBodyDecoding(maxBytes int64, strict bool, next http.Handler) http.Handler {
+	options := bodyDecoding{maxBytes: maxBytes, strict: strict}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyDecodingKey{}, options)))
+	})
+}
+
+// decodeJSONBody decodes the application/json request body into out and
+// answers 415, 413 or 400 itself when it cannot.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, out any) bool {
+	return decodeBody(w, r, out, false)
+}
+
+// decodeOptionalJSONBody is decodeJSONBody for requests whose body may be
+// left out entirely.
+func decodeOptionalJSONBody(w http.ResponseWriter, r *http.Request, out any) bool {
+	return decodeBody(w, r, out, true)
+}
+
+// bodyDecodingOptions returns how the listener of r decodes bodies.
+func bodyDecodingOptions(r *http.Request) bodyDecoding {
+	options, ok := r.Context().Value(bodyDecodingKey{}).(bodyDecoding)
+	if !ok || options.maxBytes <= 0 {
+		options.maxBytes = defaultMaxBodyBytes
+	}
+	return options
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, out any, optional bool) bool {
+	options := bodyDecodingOptions(r)
+	contentType := r.Header.Get("Content-Type")
+	if optional && contentType == "" && r.ContentLength <= 0 {
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, options.maxBytes))
+		if err != nil {
+			respondBodyReadError(w, r, err, options.maxBytes)
+			return false
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			return true
+		}
+	}
+	if !isJSONContentType(contentType) {
+		respondProblem(w, http.StatusUnsupportedMediaType, "http://secapi.cloud/errors/invalid-request", "Unsupported Media Type", "Content-Type must be application/json", r.URL.Path)
+		return false
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, options.maxBytes))
+	if err != nil {
+		respondBodyReadError(w, r, err, options.maxBytes)
+		return false
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if optional {
+			return true
+		}
+		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "request body is required", r.URL.Path)
+		return false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if options.strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(out); err != nil {
+		detail, source := describeDecodeError(raw, err)
+		respondProblemWithSources(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", detail, r.URL.Path, []problemSource{source})
+		return false
+	}
+	return true
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func respondBodyReadError(w http.ResponseWriter, r *http.Request, err error, maxBytes int64) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondProblem(w, http.StatusRequestEntityTooLarge, "http://secapi.cloud/errors/invalid-request", "Request Entity Too Large", fmt.Sprintf("request body exceeds %d bytes", maxBytes), r.URL.Path)
+		return
+	}
+	respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "failed to read request body", r.URL.Path)
+}
+
+// describeDecodeError turns a decode error into a problem detail and the
+// JSON pointer of the offending field, when there is one.
+func describeDecodeError(raw []byte, err error) (string, problemSource) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid json body: %s at offset %d", syntaxErr.Error(), syntaxErr.Offset), problemSource{}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "invalid json body: unexpected end of input", problemSource{}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		pointer := "/" + strings.ReplaceAll(typeErr.Field, ".", "/")
+		return fmt.Sprintf("%s must be %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value), problemSource{Pointer: pointer}
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name, _ = strconv.Unquote(name)
+		pointer := unknownFieldPointer(raw, name)
+		field := strings.ReplaceAll(strings.TrimPrefix(pointer, "/"), "/", ".")
+		if field == "" {
+			field = name
+		}
+		return fmt.Sprintf("unknown field %s", field), problemSource{Pointer: pointer}
+	}
+	return "invalid json body", problemSource{}
+}
+
+// jsonTypeName names a Go kind the way a JSON client knows it.
+func jsonTypeName(kind string) string {
+	switch {
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	}
+	return "an object"
+}
+
+// unknownFieldPointer locates the first member called name in the body. The
+// decoder reports only the member name, not where it sits.
+func unknownFieldPointer(raw []byte, name string) string {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ""
+	}
+	pointer, _ := findMember(doc, "", name)
+	return pointer
+}
+
+func findMember(doc any, prefix, name string) (string, bool) {
+	switch value := doc.(type) {
+	case map[string]any:
+		if _, ok := value[name]; ok {
+			return prefix + "/" + escapeJSONPointerToken(name), true
+		}
+		for key, child := range value {
+			if pointer, ok := findMember(child, prefix+"/"+escapeJSONPointerToken(key), name); ok {
+				return pointer, true
+			}
+		}
+	case []any:
+		for i, child := range value {
+			if pointer, ok := findMember(child, prefix+"/"+strconv.Itoa(i), name); ok {
+				return pointer, true
+			}
+		}
+	}
+	return "", false
+}
+
+func escapeJSONPointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,79 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonRequest builds a request carrying body as application/json.
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestDecodeJSONBodyRejectsBadRequests(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		Spec struct {
+			SizeGB int `json:"sizeGB"`
+		} `json:"spec"`
+	}
+	handler := func(strict bool, maxBytes int64) http.Handler {
+		return withBodyDecoding(maxBytes, strict, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req payload
+			if !decodeJSONBody(w, r, &req) {
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	xml := httptest.NewRequest(http.MethodPut, "/x", strings.NewReader("<spec/>"))
+	xml.Header.Set("Content-Type", "application/xml")
+
+	cases := []struct {
+		name    string
+		strict  bool
+		req     *http.Request
+		status  int
+		pointer string
+		detail  string
+	}{
+		{name: "valid", req: jsonRequest(http.MethodPut, "/x", `{"spec":{"sizeGB":10}}`), status: http.StatusNoContent},
+		{name: "lenient unknown field", req: jsonRequest(http.MethodPut, "/x", `{"spec":{"sizeGB":10,"color":"red"}}`), status: http.StatusNoContent},
+		{name: "strict unknown field", strict: true, req: jsonRequest(http.MethodPut, "/x", `{"spec":{"sizeGB":10,"color":"red"}}`), status: http.StatusBadRequest, pointer: "/spec/color", detail: "unknown field spec.color"},
+		{name: "wrong type", req: jsonRequest(http.MethodPut, "/x", `{"spec":{"sizeGB":"ten"}}`), status: http.StatusBadRequest, pointer: "/spec/sizeGB", detail: "spec.sizeGB must be a number, got string"},
+		{name: "syntax error", req: jsonRequest(http.MethodPut, "/x", `{"spec":}`), status: http.StatusBadRequest, detail: "at offset 9"},
+		{name: "empty body", req: jsonRequest(http.MethodPut, "/x", ""), status: http.StatusBadRequest, detail: "request body is required"},
+		{name: "xml", req: xml, status: http.StatusUnsupportedMediaType},
+		{name: "too large", req: jsonRequest(http.MethodPut, "/x", `{"spec":{"sizeGB":10},"pad":"`+strings.Repeat("x", 64)+`"}`), status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		maxBytes := int64(defaultMaxBodyBytes)
+		if tc.name == "too large" {
+			maxBytes = 32
+		}
+		rec := httptest.NewRecorder()
+		handler(tc.strict, maxBytes).ServeHTTP(rec, tc.req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.status, rec.Code, rec.Body.String())
+		}
+		if tc.status == http.StatusNoContent {
+			continue
+		}
+		var problem problemResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: decode problem: %v", tc.name, err)
+		}
+		if !strings.Contains(problem.Detail, tc.detail) {
+			t.Fatalf("%s: expected detail containing %q, got %q", tc.name, tc.detail, problem.Detail)
+		}
+		if tc.pointer != "" && (len(problem.Sources) != 1 || problem.Sources[0].Pointer != tc.pointer) {
+			t.Fatalf("%s: expected pointer %s, got %+v", tc.name, tc.pointer, problem.Sources)
+		}
+	}
+}
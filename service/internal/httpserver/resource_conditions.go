@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+)
+
+// statusCondition explains the state of a resource beyond its state value.
+type statusCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// failedActionState returns the state and conditions a resource reports. A
+// binding the operation reconciler marked errored turns stateValue into error
+// with a condition carrying the latest failed operation, unless the resource
+// is already being deleted.
+func failedActionState(ctx context.Context, store *state.Store, binding *state.ResourceBinding, stateValue string) (string, []statusCondition) {
+	if binding == nil || binding.Status != state.BindingStatusError || stateValue == "deleting" {
+		return stateValue, nil
+	}
+	condition := statusCondition{Type: "ProviderActionFailed", Status: "True", Message: "the last provider action on the resource failed"}
+	operations, err := store.ListOperationsBySecaRef(ctx, binding.SecaRef, state.OperationFilter{Phase: "failed", Limit: 1})
+	if err != nil {
+		tracing.Logf(ctx, "failed operations of %s: %v", binding.SecaRef, err)
+	}
+	if len(operations) > 0 {
+		if operations[0].ErrorText != "" {
+			condition.Message = operations[0].ErrorText
+		}
+		condition.LastTransitionTime = operations[0].UpdatedAt.Format(time.RFC3339)
+	}
+	return "error", []statusCondition{condition}
+}
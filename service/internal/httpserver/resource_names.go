@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxResourceNameLength is the RFC 1035 label limit Hetzner also applies.
+const maxResourceNameLength = 63
+
+// validatedPathNames are the path parameters that name SECA resources.
+var validatedPathNames = []string{"tenant", "workspace", "network", "name"}
+
+// validateResourceName enforces a lowercase RFC 1035 label: at most 63
+// lowercase letters, digits and hyphens, not starting or ending with a
+// hyphen. Mixed case is rejected rather than lowered so that every handler
+// and binding key sees the same name.
+func validateResourceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if len(name) > maxResourceNameLength {
+		return fmt.Errorf("must be at most %d characters", maxResourceNameLength)
+	}
+	if strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return fmt.Errorf("must not start or end with a hyphen")
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("must contain only lowercase letters, digits and hyphens")
+		}
+	}
+	return nil
+}
+
+// requireValidPathNames answers 400 naming every path parameter that is not
+// a valid resource name. Catalog names such as SKUs and images may carry
+// dots and are only checked when a tenant image is created.
+func requireValidPathNames(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var problems []fieldProblem
+		for _, param := range validatedPathNames {
+			value := r.PathValue(param)
+			if value == "" || (param == "name" && catalogNameRoute(r)) {
+				continue
+			}
+			if err := validateResourceName(value); err != nil {
+				problems = append(problems, fieldParameter(param, "%s %q %v", param, value, err))
+			}
+		}
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func catalogNameRoute(r *http.Request) bool {
+	if strings.Contains(r.Pattern, "/skus/{name}") {
+		return true
+	}
+	return strings.Contains(r.Pattern, "/images/{name}") && r.Method != http.MethodPut
+}
@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateResourceName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"a", "web-1", "1st", strings.Repeat("a", 63)} {
+		if err := validateResourceName(name); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "My-Server", "my_server", "web!", "-web", "web-", "web.1", strings.Repeat("a", 64)} {
+		if err := validateResourceName(name); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestRequireValidPathNamesReportsEveryParameter(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	mux.HandleFunc("PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", requireValidPathNames(ok))
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/images/{name}", requireValidPathNames(ok))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/compute/v1/tenants/t1/workspaces/WS/instances/My_Server", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if len(problem.Sources) != 2 || problem.Sources[0].Parameter != "workspace" || problem.Sources[1].Parameter != "name" {
+		t.Fatalf("expected workspace and name sources, got %+v", problem.Sources)
+	}
+	if !strings.Contains(problem.Detail, `"My_Server"`) {
+		t.Fatalf("expected the offending value in the detail, got %q", problem.Detail)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/storage/v1/tenants/t1/images/ubuntu-24.04", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected catalog image names to pass, got %d", rec.Code)
+	}
+}
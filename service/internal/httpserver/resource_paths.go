@@ -4,18 +4,27 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
 )
 
 func scopeFromPath(w http.ResponseWriter, r *http.Request) (string, string, bool) {
 	tenant := r.PathValue("tenant")
 	workspace := r.PathValue("workspace")
 	if tenant == "" || workspace == "" {
-		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and workspace are required", r.URL.Path)
+		problems := []fieldProblem{}
+		if tenant == "" {
+			problems = append(problems, fieldParameter("tenant", "tenant is required"))
+		}
+		if workspace == "" {
+			problems = append(problems, fieldParameter("workspace", "workspace is required"))
+		}
+		respondValidationProblem(w, r.URL.Path, problems...)
 		return "", "", false
 	}
 	return tenant, workspace, true
@@ -28,7 +37,7 @@ func scopedNameFromPath(w http.ResponseWriter, r *http.Request, nameErr string)
 	}
 	name := strings.ToLower(r.PathValue("name"))
 	if name == "" {
-		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", nameErr, r.URL.Path)
+		respondValidationProblem(w, r.URL.Path, fieldParameter("name", "%s", nameErr))
 		return "", "", "", false
 	}
 	return tenant, workspace, name, true
@@ -41,17 +50,21 @@ func scopedNetworkNameFromPath(w http.ResponseWriter, r *http.Request, nameErr s
 	}
 	network := strings.ToLower(r.PathValue("network"))
 	if network == "" {
-		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "network name is required", r.URL.Path)
+		respondValidationProblem(w, r.URL.Path, fieldParameter("network", "network name is required"))
 		return "", "", "", "", false
 	}
 	name := strings.ToLower(r.PathValue("name"))
 	if name == "" {
-		respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", nameErr, r.URL.Path)
+		respondValidationProblem(w, r.URL.Path, fieldParameter("name", "%s", nameErr))
 		return "", "", "", "", false
 	}
 	return tenant, workspace, network, name, true
 }
 
+// workspaceExecutionContext is the single gate for workspace-scoped handlers:
+// it answers 404 for workspaces that were never created or are soft-deleted,
+// so a mistyped path never provisions Hetzner resources, and otherwise returns
+// a context carrying the workspace Hetzner credential.
 func workspaceExecutionContext(w http.ResponseWriter, r *http.Request, store *state.Store, tenant, workspace string) (context.Context, bool) {
 	ws, err := store.GetWorkspace(r.Context(), tenant, workspace)
 	if err != nil {
@@ -72,7 +85,9 @@ func workspaceExecutionContext(w http.ResponseWriter, r *http.Request, store *st
 		return nil, false
 	}
 
-	cred, err := store.GetWorkspaceProviderCredential(r.Context(), tenant, workspace, "hetzner")
+	cred, revoked, err := workspaceCredentials.resolve(r.Context(), store.CredentialGenerations(), tenant, workspace, "hetzner", func(ctx context.Context) (*state.WorkspaceProviderCredential, error) {
+		return store.GetWorkspaceProviderCredential(ctx, tenant, workspace, "hetzner")
+	})
 	if err != nil {
 		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve workspace credentials", r.URL.Path)
 		return nil, false
@@ -81,11 +96,104 @@ func workspaceExecutionContext(w http.ResponseWriter, r *http.Request, store *st
 		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "workspace has no hetzner credentials", r.URL.Path)
 		return nil, false
 	}
-	ctx := hetzner.WithWorkspaceCredential(r.Context(), hetzner.WorkspaceCredential{
+	ctx := hetzner.WithWorkspaceCredential(revocableContext(r.Context(), revoked), hetzner.WorkspaceCredential{
 		Token:       cred.APIToken,
 		CloudAPIURL: cred.APIEndpoint,
 	})
-	return ctx, true
+	ctx = hetzner.WithAuditScope(ctx, tenant, workspace)
+	return withBoundResourceIDs(ctx, store, tenant, workspace), true
+}
+
+// withBoundResourceIDs pins the Hetzner IDs the workspace bindings recorded,
+// so instances, block storages, networks and security groups are looked up
+// by ID rather than by a name anyone with console access can change. Only the
+// binding of a name the request looks up is read. Bindings written before IDs
+// were recorded carry the name instead; they are rewritten with the ID the
+// next time the resource is read. Failing to read a binding falls back to a
+// name lookup.
+func withBoundResourceIDs(ctx context.Context, store *state.Store, tenant, workspace string) context.Context {
+	return hetzner.WithResourceIDLookup(ctx, func(ctx context.Context, kind, name string) (int64, bool) {
+		var ref string
+		switch kind {
+		case hetzner.ResourceServer:
+			ref = computeInstanceRef(tenant, workspace, name)
+		case hetzner.ResourceVolume:
+			ref = blockStorageRef(tenant, workspace, name)
+		case hetzner.ResourceNetwork:
+			ref = networkBindingRef(tenant, workspace, name)
+		case hetzner.ResourceFirewall:
+			ref = securityGroupRef(tenant, workspace, name)
+		default:
+			return 0, false
+		}
+		binding, err := store.GetResourceBinding(ctx, ref)
+		if err != nil {
+			tracing.Logf(ctx, "resource id of %s: %v", ref, err)
+			return 0, false
+		}
+		if binding == nil {
+			return 0, false
+		}
+		if kind == hetzner.ResourceFirewall {
+			payload, err := parseSecurityGroupBinding(binding.ProviderRef)
+			return payload.ProviderID, err == nil && payload.ProviderID > 0
+		}
+		return providerRefID(binding.ProviderRef, kind)
+	})
+}
+
+// boundNames holds the names the workspace bindings of one kind are bound
+// to, keyed by the Hetzner ID they recorded.
+type boundNames struct {
+	byID  map[int64]string
+	names map[string]struct{}
+}
+
+// boundNamesByID reads the bound names of kind, so a listing shows a resource
+// renamed in the Hetzner console under its SECA name instead of as a new
+// resource. Failing to list bindings leaves the names Hetzner reports.
+func boundNamesByID(ctx context.Context, store *state.Store, tenant, workspace, kind, providerKind string) boundNames {
+	bound := boundNames{byID: map[int64]string{}, names: map[string]struct{}{}}
+	bindings, err := store.ListResourceBindings(ctx, tenant, workspace, kind)
+	if err != nil {
+		tracing.Logf(ctx, "bound names of %s/%s: %v", tenant, workspace, err)
+		return bound
+	}
+	for _, binding := range bindings {
+		if id, ok := providerRefID(binding.ProviderRef, providerKind); ok {
+			name := resourceNameFromRef(binding.SecaRef)
+			bound.byID[id] = name
+			bound.names[name] = struct{}{}
+		}
+	}
+	return bound
+}
+
+// listed returns the name a listed resource is served under: its bound name,
+// or its Hetzner name unless that name is bound to another resource, in
+// which case it is not listed at all.
+func (b boundNames) listed(id int64, name string) (string, bool) {
+	if bound, ok := b.byID[id]; ok {
+		return bound, true
+	}
+	if _, taken := b.names[strings.ToLower(name)]; taken {
+		return "", false
+	}
+	return name, true
+}
+
+// providerRefID returns the numeric ID of a "hetzner.cloud/<kind>/<id>"
+// provider reference. Name-only references report false.
+func providerRefID(ref, kind string) (int64, bool) {
+	value, found := strings.CutPrefix(strings.TrimSpace(ref), "hetzner.cloud/"+kind+"/")
+	if !found {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
 }
 
 func waitForActiveWorkspace(ctx context.Context, store *state.Store, tenant, workspace string, ws *state.WorkspaceResource, timeout, interval time.Duration) (*state.WorkspaceResource, error) {
@@ -153,14 +261,14 @@ func defaultRegion(value string) string {
 	return strings.ToLower(value)
 }
 
-func normalizeProviderBlockStorageSizeGB(size int) int {
+func normalizeProviderBlockStorageSizeGB(size, maxSizeGB int) int {
 	// Hetzner volume limits are stricter than conformance generated values.
 	// Keep API-facing spec as requested, but normalize provider call values.
 	if size < 10 {
 		return 10
 	}
-	if size > 100 {
-		return 100
+	if maxSizeGB > 0 && size > maxSizeGB {
+		return maxSizeGB
 	}
 	return size
 }
@@ -187,6 +295,13 @@ func volumeProviderRef(id int64, name string) string {
 	return "hetzner.cloud/volumes/" + strings.ToLower(strings.TrimSpace(name))
 }
 
+func networkProviderRef(id int64, name string) string {
+	if id > 0 {
+		return fmt.Sprintf("hetzner.cloud/networks/%d", id)
+	}
+	return "hetzner.cloud/networks/" + strings.ToLower(strings.TrimSpace(name))
+}
+
 func operationID(prefix, name string) string {
 	return fmt.Sprintf("%s-%s-%d", prefix, name, time.Now().UnixNano())
 }
@@ -0,0 +1,224 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// roleGrantsTTL bounds how long role and role-assignment changes take to
+// reach the enforcement middleware.
+const roleGrantsTTL = 10 * time.Second
+
+// authResourceLister lists a tenant's roles or role assignments; it is
+// satisfied by (*state.Store).ListRoles and ListRoleAssignments.
+type authResourceLister func(ctx context.Context, tenant string) ([]state.AuthResource, error)
+
+type rolePermission struct {
+	Provider  string   `json:"provider"`
+	Resources []string `json:"resources"`
+	Verb      []string `json:"verb"`
+}
+
+type roleSpec struct {
+	Permissions []rolePermission `json:"permissions"`
+}
+
+type roleAssignmentScope struct {
+	Workspaces []string `json:"workspaces"`
+}
+
+type roleAssignmentSpec struct {
+	Subs   []string              `json:"subs"`
+	Roles  []string              `json:"roles"`
+	Scopes []roleAssignmentScope `json:"scopes"`
+}
+
+// subjectGrant is one role assignment resolved to the permissions of its
+// roles. An empty workspace list applies the grant to every workspace.
+type subjectGrant struct {
+	workspaces  []string
+	permissions []rolePermission
+}
+
+type tenantGrants struct {
+	bySubject map[string][]subjectGrant
+	loadedAt  time.Time
+}
+
+// roleGrantsCache keeps each tenant's role assignments resolved per subject
+// for roleGrantsTTL.
+type roleGrantsCache struct {
+	listRoles       authResourceLister
+	listAssignments authResourceLister
+	ttl             time.Duration
+	now             func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]tenantGrants
+}
+
+func newRoleGrantsCache(listRoles, listAssignments authResourceLister, ttl time.Duration) *roleGrantsCache {
+	return &roleGrantsCache{
+		listRoles:       listRoles,
+		listAssignments: listAssignments,
+		ttl:             ttl,
+		now:             time.Now,
+		tenants:         map[string]tenantGrants{},
+	}
+}
+
+// grants returns the subject's grants and whether the tenant has any role
+// assignment at all.
+func (c *roleGrantsCache) grants(ctx context.Context, tenant, subject string) ([]subjectGrant, bool, error) {
+	c.mu.Lock()
+	cached, ok := c.tenants[tenant]
+	c.mu.Unlock()
+	if !ok || c.now().Sub(cached.loadedAt) >= c.ttl {
+		loaded, err := c.load(ctx, tenant)
+		if err != nil {
+			return nil, false, err
+		}
+		c.mu.Lock()
+		c.tenants[tenant] = loaded
+		c.mu.Unlock()
+		cached = loaded
+	}
+	return cached.bySubject[subject], len(cached.bySubject) > 0, nil
+}
+
+func (c *roleGrantsCache) load(ctx context.Context, tenant string) (tenantGrants, error) {
+	roles, err := c.listRoles(ctx, tenant)
+	if err != nil {
+		return tenantGrants{}, err
+	}
+	assignments, err := c.listAssignments(ctx, tenant)
+	if err != nil {
+		return tenantGrants{}, err
+	}
+	permissions := make(map[string][]rolePermission, len(roles))
+	for _, role := range roles {
+		var spec roleSpec
+		if decodeAuthSpec(role.Spec, &spec) == nil {
+			permissions[strings.ToLower(role.Name)] = spec.Permissions
+		}
+	}
+	out := tenantGrants{bySubject: map[string][]subjectGrant{}, loadedAt: c.now()}
+	for _, assignment := range assignments {
+		var spec roleAssignmentSpec
+		if decodeAuthSpec(assignment.Spec, &spec) != nil {
+			continue
+		}
+		grant := subjectGrant{}
+		for _, scope := range spec.Scopes {
+			grant.workspaces = append(grant.workspaces, scope.Workspaces...)
+		}
+		for _, role := range spec.Roles {
+			grant.permissions = append(grant.permissions, permissions[resourceNameFromRef(role)]...)
+		}
+		for _, subject := range spec.Subs {
+			out.bySubject[subject] = append(out.bySubject[subject], grant)
+		}
+	}
+	return out, nil
+}
+
+// requireRolePermission gates a provider route on the caller's role
+// assignments. Until a tenant assigns its first role every subject keeps full
+// access; from then on a subject may only do what its assignments allow, and
+// one without any assignment is refused.
+func requireRolePermission(cache *roleGrantsCache, provider string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := authenticatedSubject(r.Context())
+		tenant := r.PathValue("tenant")
+		if !ok || tenant == "" {
+			next(w, r)
+			return
+		}
+		grants, enforced, err := cache.grants(r.Context(), tenant, subject)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load role assignments", r.URL.Path)
+			return
+		}
+		resource, verb := routePermission(r)
+		if enforced && !grantsAllow(grants, provider, resource, verb, r.PathValue("workspace")) {
+			respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", fmt.Sprintf("missing permission %s %s %s", provider, resource, verb), r.URL.Path)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// routePermission derives the resource and verb a request needs from the
+// pattern it matched: ".../instances" is list, ".../instances/{name}" is
+// the method itself and ".../instances/{name}/start" is post on instances.
+// A collection action such as ".../instances:stop" is post on instances too,
+// and so is ".../workspaces/{action}", which serves "{name}:restore".
+func routePermission(r *http.Request) (resource, verb string) {
+	_, pattern, _ := strings.Cut(r.Pattern, " ")
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	last := len(segments) - 1
+	segments[last], _, _ = strings.Cut(segments[last], ":")
+	verb = strings.ToLower(r.Method)
+	if verb == "head" {
+		verb = "get"
+	}
+	switch {
+	case last >= 1 && (segments[last] == "{name}" || segments[last] == "{action}"):
+		return segments[last-1], verb
+	case last >= 2 && segments[last-1] == "{name}":
+		return segments[last-2], verb
+	case verb == "get":
+		return segments[last], "list"
+	default:
+		return segments[last], verb
+	}
+}
+
+func grantsAllow(grants []subjectGrant, provider, resource, verb, workspace string) bool {
+	for _, grant := range grants {
+		if workspace != "" && len(grant.workspaces) > 0 && !matchesAny(grant.workspaces, workspace) {
+			continue
+		}
+		for _, permission := range grant.permissions {
+			if matchesAny([]string{permission.Provider}, provider) &&
+				(len(permission.Resources) == 0 || matchesAny(permission.Resources, resource)) &&
+				matchesAny(permission.Verb, verb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether value matches one of the patterns, ignoring
+// case. "*" matches everything; other patterns use path.Match, so
+// "seca.compute/*" matches "seca.compute/v1".
+func matchesAny(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*" || pattern == value {
+			return true
+		}
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeAuthSpec(spec map[string]any, out any) error {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
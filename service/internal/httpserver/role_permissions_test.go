@@ -0,0 +1,133 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestRequireRolePermissionLimitsAssignedSubjects(t *testing.T) {
+	t.Parallel()
+
+	roles := func(context.Context, string) ([]state.AuthResource, error) {
+		return []state.AuthResource{{Name: "viewer", Spec: map[string]any{
+			"permissions": []any{map[string]any{"provider": "seca.compute/*", "verb": []any{"GET", "LIST"}}},
+		}}}, nil
+	}
+	assignments := func(_ context.Context, tenant string) ([]state.AuthResource, error) {
+		if tenant != "t1" {
+			return nil, nil
+		}
+		return []state.AuthResource{{Name: "viewers", Spec: map[string]any{
+			"subs":   []any{"viewer-token"},
+			"roles":  []any{"roles/viewer"},
+			"scopes": []any{map[string]any{"workspaces": []any{"ws1"}}},
+		}}}, nil
+	}
+	cache := newRoleGrantsCache(roles, assignments, time.Minute)
+	lookup := func(_ context.Context, token string) (*state.TenantAPIToken, error) {
+		tenant, name, _ := strings.Cut(token, ":")
+		return &state.TenantAPIToken{Tenant: tenant, Name: name}, nil
+	}
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	guard := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireTenantAuth(lookup, requireRolePermission(cache, "seca.compute/v1", next))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances", guard(ok))
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", guard(ok))
+	mux.HandleFunc("DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", guard(ok))
+	mux.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/start", guard(ok))
+	mux.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:stop", guard(ok))
+
+	cases := []struct {
+		token, method, path string
+		want                int
+	}{
+		{token: "t1:viewer-token", method: http.MethodGet, path: "/compute/v1/tenants/t1/workspaces/ws1/instances", want: http.StatusOK},
+		{token: "t1:viewer-token", method: http.MethodGet, path: "/compute/v1/tenants/t1/workspaces/ws1/instances/vm1", want: http.StatusOK},
+		{token: "t1:viewer-token", method: http.MethodDelete, path: "/compute/v1/tenants/t1/workspaces/ws1/instances/vm1", want: http.StatusForbidden},
+		{token: "t1:viewer-token", method: http.MethodPost, path: "/compute/v1/tenants/t1/workspaces/ws1/instances/vm1/start", want: http.StatusForbidden},
+		{token: "t1:viewer-token", method: http.MethodGet, path: "/compute/v1/tenants/t1/workspaces/ws2/instances", want: http.StatusForbidden},
+		{token: "t1:unassigned-token", method: http.MethodGet, path: "/compute/v1/tenants/t1/workspaces/ws1/instances", want: http.StatusForbidden},
+		{token: "t2:unassigned-token", method: http.MethodDelete, path: "/compute/v1/tenants/t2/workspaces/ws1/instances/vm1", want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s %s: expected %d, got %d: %s", tc.token, tc.method, tc.path, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	for _, tc := range []struct{ method, path, detail string }{
+		{method: http.MethodDelete, path: "/compute/v1/tenants/t1/workspaces/ws1/instances/vm1", detail: "missing permission seca.compute/v1 instances delete"},
+		{method: http.MethodPost, path: "/compute/v1/tenants/t1/workspaces/ws1/instances:stop", detail: "missing permission seca.compute/v1 instances post"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer t1:viewer-token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var problem problemResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Detail != tc.detail {
+			t.Fatalf("%s %s: expected the missing permission in the detail, got %s (%v)", tc.method, tc.path, rec.Body.String(), err)
+		}
+	}
+}
+
+func TestRequireRolePermissionLetsNarrowRolesRestore(t *testing.T) {
+	t.Parallel()
+
+	roles := func(context.Context, string) ([]state.AuthResource, error) {
+		return []state.AuthResource{
+			{Name: "workspace-restorer", Spec: map[string]any{
+				"permissions": []any{map[string]any{"provider": "seca.workspace/v1", "resources": []any{"workspaces"}, "verb": []any{"post"}}},
+			}},
+			{Name: "role-restorer", Spec: map[string]any{
+				"permissions": []any{map[string]any{"provider": "seca.authorization/v1", "resources": []any{"roles"}, "verb": []any{"post"}}},
+			}},
+		}, nil
+	}
+	assignments := func(context.Context, string) ([]state.AuthResource, error) {
+		return []state.AuthResource{
+			{Name: "workspaces", Spec: map[string]any{"subs": []any{"ws-token"}, "roles": []any{"workspace-restorer"}}},
+			{Name: "roles", Spec: map[string]any{"subs": []any{"role-token"}, "roles": []any{"role-restorer"}}},
+		}, nil
+	}
+	cache := newRoleGrantsCache(roles, assignments, time.Minute)
+	lookup := func(_ context.Context, token string) (*state.TenantAPIToken, error) {
+		return &state.TenantAPIToken{Tenant: "t1", Name: token}, nil
+	}
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /workspace/v1/tenants/{tenant}/workspaces/{action}", requireTenantAuth(lookup, requireRolePermission(cache, "seca.workspace/v1", ok)))
+	mux.HandleFunc("POST /v1/tenants/{tenant}/roles/{action}", requireTenantAuth(lookup, requireRolePermission(cache, "seca.authorization/v1", ok)))
+	mux.HandleFunc("POST /v1/tenants/{tenant}/role-assignments/{action}", requireTenantAuth(lookup, requireRolePermission(cache, "seca.authorization/v1", ok)))
+
+	cases := []struct {
+		token, path string
+		want        int
+	}{
+		{token: "ws-token", path: "/workspace/v1/tenants/t1/workspaces/ws1:restore", want: http.StatusOK},
+		{token: "ws-token", path: "/v1/tenants/t1/roles/viewer:restore", want: http.StatusForbidden},
+		{token: "role-token", path: "/v1/tenants/t1/roles/viewer:restore", want: http.StatusOK},
+		{token: "role-token", path: "/v1/tenants/t1/role-assignments/viewers:restore", want: http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d: %s", tc.token, tc.path, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+}
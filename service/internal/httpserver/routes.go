@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// problemFallbacks serves mux and turns the 404 and 405 answers the mux
+// writes for unmatched requests into problem+json. The Allow header the mux
+// sets on 405 is kept; handlers' own 404s pass through untouched.
+func problemFallbacks(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(&fallbackWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// fallbackWriter relies on the mux setting r.Pattern before it calls the
+// handler: an empty pattern means the mux is answering by itself.
+type fallbackWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *fallbackWriter) WriteHeader(code int) {
+	if w.r.Pattern != "" || (code != http.StatusNotFound && code != http.StatusMethodNotAllowed) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.replaced = true
+	header := w.ResponseWriter.Header()
+	header.Del("X-Content-Type-Options")
+	if code == http.StatusNotFound {
+		respondProblem(w.ResponseWriter, code, "http://secapi.cloud/errors/resource-not-found", "Not Found", "no route matches "+w.r.URL.Path, w.r.URL.Path)
+		return
+	}
+	respondProblem(w.ResponseWriter, code, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", allowedMethodsDetail(header.Get("Allow")), w.r.URL.Path)
+}
+
+// Write drops the mux's plain-text body once a problem has been written.
+func (w *fallbackWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *fallbackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func allowedMethodsDetail(allow string) string {
+	methods := strings.Split(allow, ", ")
+	if len(methods) == 1 {
+		return "Only " + methods[0] + " is supported"
+	}
+	return "Only " + strings.Join(methods[:len(methods)-1], ", ") + " and " + methods[len(methods)-1] + " are supported"
+}
@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemFallbacksAnswerUnmatchedRequestsWithProblems(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/regions/{name}", getRegion(fakeRegionProvider{}))
+	mux.HandleFunc("DELETE /v1/regions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "region not found", r.URL.Path)
+	})
+	handler := problemFallbacks(mux)
+
+	cases := []struct {
+		method, path string
+		want         int
+		wantAllow    string
+		wantDetail   string
+	}{
+		{method: http.MethodPut, path: "/v1/regions/fsn1", want: http.StatusMethodNotAllowed, wantAllow: "DELETE, GET, HEAD", wantDetail: "Only DELETE, GET and HEAD are supported"},
+		{method: http.MethodGet, path: "/v1/nope", want: http.StatusNotFound, wantDetail: "no route matches /v1/nope"},
+		{method: http.MethodDelete, path: "/v1/regions/fsn1", want: http.StatusNotFound, wantDetail: "region not found"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+		}
+		if got := rec.Header().Get("Allow"); got != tc.wantAllow {
+			t.Fatalf("%s %s: expected Allow %q, got %q", tc.method, tc.path, tc.wantAllow, got)
+		}
+		var problem problemResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Status != tc.want || problem.Detail != tc.wantDetail {
+			t.Fatalf("%s %s: expected problem %q, got %s (%v)", tc.method, tc.path, tc.wantDetail, rec.Body.String(), err)
+		}
+	}
+}
+
+func TestProblemFallbacksServeHeadLikeGet(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/regions/{name}", getRegion(fakeRegionProvider{}))
+	srv := httptest.NewServer(problemFallbacks(mux))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Head(srv.URL + "/v1/regions/fsn1")
+	if err != nil {
+		t.Fatalf("HEAD: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || len(body) != 0 {
+		t.Fatalf("expected 200 without a body, got %d with %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("expected the GET content type, got %q", got)
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/faults"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/metrics"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
@@ -24,45 +27,132 @@ type CatalogProvider interface {
 	ListComputeSKUs(ctx context.Context) ([]hetzner.ComputeSKU, error)
 	GetComputeSKU(ctx context.Context, name string) (*hetzner.ComputeSKU, error)
 	ListCatalogImages(ctx context.Context) ([]hetzner.CatalogImage, error)
-	GetCatalogImage(ctx context.Context, name string) (*hetzner.CatalogImage, error)
+	GetCatalogImage(ctx context.Context, name, architecture string) (*hetzner.CatalogImage, error)
+	GetVolumePricing(ctx context.Context) (*hetzner.VolumePricing, error)
+	VolumeCapacityByLocation(ctx context.Context) ([]hetzner.VolumeCapacity, error)
 }
 
 type ComputeStorageProvider interface {
 	ListInstances(ctx context.Context) ([]hetzner.Instance, error)
+	ListInstancesByLabels(ctx context.Context, labels map[string]string) ([]hetzner.Instance, error)
 	GetInstance(ctx context.Context, name string) (*hetzner.Instance, error)
 	CreateOrUpdateInstance(ctx context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, string, error)
+	ValidateInstanceCreate(ctx context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, error)
 	DeleteInstance(ctx context.Context, name string) (bool, string, error)
+	WaitForAction(ctx context.Context, id int64) error
 	StartInstance(ctx context.Context, name string) (bool, string, error)
 	StopInstance(ctx context.Context, name string) (bool, string, error)
 	RestartInstance(ctx context.Context, name string) (bool, string, error)
+	SetInstanceBackups(ctx context.Context, name string, enabled bool) (bool, string, error)
+	ServerTypeForRegion(ctx context.Context, preferred, region, fallbackArchitecture string) (string, error)
 	AttachInstanceToNetwork(ctx context.Context, instanceName, networkName string) (bool, string, error)
+	AttachInstanceToNetworkWithIP(ctx context.Context, instanceName, networkName, ip string) (bool, string, error)
+	DetachInstanceFromNetwork(ctx context.Context, instanceName, networkName string) (bool, error)
 	SyncInstanceNetworks(ctx context.Context, instanceName string, networkNames []string) error
 	GetInstancePrivateIPv4(ctx context.Context, instanceName, networkName string) (string, error)
+	SyncInstanceSecurityGroups(ctx context.Context, instanceName string, securityGroupNames []string, managed func(hetzner.SecurityGroup) bool) error
+	GetInstanceMetrics(ctx context.Context, req hetzner.InstanceMetricsRequest) (*hetzner.InstanceMetrics, error)
+
+	ListPlacementGroups(ctx context.Context) ([]hetzner.PlacementGroup, error)
+	GetPlacementGroup(ctx context.Context, name string) (*hetzner.PlacementGroup, error)
+	CreateOrUpdatePlacementGroup(ctx context.Context, req hetzner.PlacementGroupCreateRequest) (*hetzner.PlacementGroup, bool, error)
+	DeletePlacementGroup(ctx context.Context, name string) (bool, error)
 
 	ListBlockStorages(ctx context.Context) ([]hetzner.BlockStorage, error)
+	ListBlockStoragesByLabels(ctx context.Context, labels map[string]string) ([]hetzner.BlockStorage, error)
 	GetBlockStorage(ctx context.Context, name string) (*hetzner.BlockStorage, error)
 	CreateOrUpdateBlockStorage(ctx context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, string, error)
-	DeleteBlockStorage(ctx context.Context, name string) (bool, error)
-	AttachBlockStorage(ctx context.Context, name, instanceName string) (bool, string, error)
+	ValidateBlockStorageCreate(ctx context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, error)
+	DeleteBlockStorage(ctx context.Context, name string, force bool) (bool, error)
+	AttachBlockStorage(ctx context.Context, name, instanceName string, automount bool) (bool, string, error)
 	DetachBlockStorage(ctx context.Context, name string) (bool, string, error)
+
+	CreateImageSnapshot(ctx context.Context, req hetzner.ImageSnapshotCreateRequest) (*hetzner.ImageSnapshot, string, error)
+	FindImageSnapshot(ctx context.Context, labels map[string]string) (*hetzner.ImageSnapshot, error)
+	DeleteImageSnapshot(ctx context.Context, id int64) (bool, error)
 }
 
 type NetworkProvider interface {
 	ListNetworks(ctx context.Context) ([]hetzner.Network, error)
+	ListNetworksByLabels(ctx context.Context, labels map[string]string) ([]hetzner.Network, error)
 	GetNetwork(ctx context.Context, name string) (*hetzner.Network, error)
 	CreateOrUpdateNetwork(ctx context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error)
+	ValidateNetworkCreate(ctx context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error)
 	DeleteNetwork(ctx context.Context, name string) (bool, error)
 	UpsertNetworkRoute(ctx context.Context, networkName, destinationCIDR, gatewayIP string) error
 	DeleteNetworkRoute(ctx context.Context, networkName, destinationCIDR string) error
+	AddSubnet(ctx context.Context, req hetzner.NetworkSubnetRequest) (bool, error)
+	ValidateSubnetCreate(ctx context.Context, req hetzner.NetworkSubnetRequest) error
+	RemoveSubnet(ctx context.Context, networkName, cidr string) (bool, error)
 
 	ListSecurityGroups(ctx context.Context) ([]hetzner.SecurityGroup, error)
+	ListSecurityGroupsByLabels(ctx context.Context, labels map[string]string) ([]hetzner.SecurityGroup, error)
 	GetSecurityGroup(ctx context.Context, name string) (*hetzner.SecurityGroup, error)
+	GetSecurityGroupByID(ctx context.Context, id int64) (*hetzner.SecurityGroup, error)
+	ManageSecurityGroup(ctx context.Context, id int64, name string, labels map[string]string) (*hetzner.SecurityGroup, error)
 	CreateOrUpdateSecurityGroup(ctx context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error)
+	ValidateSecurityGroupCreate(ctx context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error)
 	DeleteSecurityGroup(ctx context.Context, name string) (bool, error)
+
+	ListPublicIPs(ctx context.Context) ([]hetzner.PublicIP, error)
+	ListPublicIPsByLabels(ctx context.Context, labels map[string]string) ([]hetzner.PublicIP, error)
+	GetPublicIP(ctx context.Context, name string) (*hetzner.PublicIP, error)
+	CreateOrUpdatePublicIP(ctx context.Context, req hetzner.PublicIPCreateRequest) (*hetzner.PublicIP, bool, error)
+	DeletePublicIP(ctx context.Context, name string) (bool, error)
+	AssignPublicIP(ctx context.Context, name, instanceName string) error
+	UnassignPublicIP(ctx context.Context, name string) error
+
+	ListLoadBalancers(ctx context.Context) ([]hetzner.LoadBalancer, error)
+	GetLoadBalancer(ctx context.Context, name string) (*hetzner.LoadBalancer, error)
+	CreateOrUpdateLoadBalancer(ctx context.Context, req hetzner.LoadBalancerCreateRequest) (*hetzner.LoadBalancer, bool, error)
+	DeleteLoadBalancer(ctx context.Context, name string) (bool, error)
+}
+
+// WarmupReporter is implemented by providers that pre-fetch catalog data at
+// startup; readyz surfaces its status when available.
+type WarmupReporter interface {
+	WarmupStatus() hetzner.WarmupStatus
+}
+
+// ProviderHealthChecker is implemented by providers that can probe their
+// backend; readyz reports the result per dependency.
+type ProviderHealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// GlobalCredentialManager is implemented by providers whose global token can
+// be replaced at runtime; the admin API stores and applies it.
+type GlobalCredentialManager interface {
+	ValidateToken(ctx context.Context, token string) error
+	SetGlobalToken(token string)
+}
+
+// CatalogCacheInvalidator is implemented by providers that cache catalog
+// data; the admin API exposes it to drop the cache on demand.
+type CatalogCacheInvalidator interface {
+	InvalidateCatalogCache()
 }
 
 type statusResponse struct {
-	Status string `json:"status"`
+	Status  string                `json:"status"`
+	DB      string                `json:"db,omitempty"`
+	DBPool  *dbPoolStatusResponse `json:"dbPool,omitempty"`
+	Hetzner string                `json:"hetzner,omitempty"`
+	Warmup  *warmupStatusResponse `json:"warmup,omitempty"`
+}
+
+type dbPoolStatusResponse struct {
+	Acquired int32 `json:"acquired"`
+	Idle     int32 `json:"idle"`
+	Total    int32 `json:"total"`
+	Max      int32 `json:"max"`
+}
+
+type warmupStatusResponse struct {
+	State      string   `json:"state"`
+	StartedAt  string   `json:"startedAt,omitempty"`
+	FinishedAt string   `json:"finishedAt,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
 }
 
 type problemResponse struct {
@@ -79,27 +169,78 @@ type problemSource struct {
 	Parameter string `json:"parameter"`
 }
 
+// resourceVerb is the SECA operation a response describes. It is not the
+// HTTP method: a PUT reports create or update, and list items report list.
+type resourceVerb string
+
+const (
+	verbGet    resourceVerb = "get"
+	verbList   resourceVerb = "list"
+	verbCreate resourceVerb = "create"
+	verbUpdate resourceVerb = "update"
+	verbDelete resourceVerb = "delete"
+)
+
+// upsertVerb returns the verb for a PUT response.
+func upsertVerb(created bool) resourceVerb {
+	if created {
+		return verbCreate
+	}
+	return verbUpdate
+}
+
 type responseMetaObject struct {
-	Provider string `json:"provider"`
-	Resource string `json:"resource"`
-	Verb     string `json:"verb"`
+	Provider string       `json:"provider"`
+	Resource string       `json:"resource"`
+	Verb     resourceVerb `json:"verb"`
 }
 
 type resourceMetadata struct {
-	Name            string `json:"name"`
-	Provider        string `json:"provider"`
-	Resource        string `json:"resource"`
-	Verb            string `json:"verb"`
-	CreatedAt       string `json:"createdAt,omitempty"`
-	LastModifiedAt  string `json:"lastModifiedAt,omitempty"`
-	ResourceVersion int64  `json:"resourceVersion,omitempty"`
-	APIVersion      string `json:"apiVersion"`
-	Kind            string `json:"kind"`
-	Ref             string `json:"ref"`
-	Tenant          string `json:"tenant,omitempty"`
-	Workspace       string `json:"workspace,omitempty"`
-	Network         string `json:"network,omitempty"`
-	Region          string `json:"region,omitempty"`
+	Name            string       `json:"name"`
+	Provider        string       `json:"provider"`
+	Resource        string       `json:"resource"`
+	Verb            resourceVerb `json:"verb"`
+	CreatedAt       string       `json:"createdAt,omitempty"`
+	LastModifiedAt  string       `json:"lastModifiedAt,omitempty"`
+	ResourceVersion int64        `json:"resourceVersion,omitempty"`
+	APIVersion      string       `json:"apiVersion"`
+	Kind            string       `json:"kind"`
+	Ref             string       `json:"ref"`
+	Tenant          string       `json:"tenant,omitempty"`
+	Workspace       string       `json:"workspace,omitempty"`
+	Network         string       `json:"network,omitempty"`
+	Region          string       `json:"region,omitempty"`
+}
+
+// catalogTimestamp dates resources that carry no time of their own, such as
+// regions and SKUs. It is fixed at process start so repeated reads agree.
+var catalogTimestamp = time.Now().UTC().Format(time.RFC3339)
+
+// providerTimestamp formats a creation time reported by Hetzner, falling back
+// to catalogTimestamp for resources served without one.
+func providerTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return catalogTimestamp
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// stampLastModified takes lastModifiedAt from the binding, which only moves
+// when the proxy changes the resource.
+// storedBinding picks ref out of the bindings a listing synced, or nil when
+// syncing failed or did not return it.
+func storedBinding(stored map[string]state.ResourceBinding, ref string) *state.ResourceBinding {
+	binding, ok := stored[ref]
+	if !ok {
+		return nil
+	}
+	return &binding
+}
+
+func stampLastModified(metadata *resourceMetadata, binding *state.ResourceBinding) {
+	if binding != nil && !binding.UpdatedAt.IsZero() {
+		metadata.LastModifiedAt = binding.UpdatedAt.UTC().Format(time.RFC3339)
+	}
 }
 
 type regionIterator struct {
@@ -114,6 +255,9 @@ type regionResource struct {
 
 type regionSpec struct {
 	AvailableZones []string           `json:"availableZones"`
+	NetworkZone    string             `json:"networkZone,omitempty"`
+	Architectures  []string           `json:"architectures,omitempty"`
+	BlockStorage   bool               `json:"blockStorage"`
 	Providers      []regionSpecVendor `json:"providers"`
 }
 
@@ -134,8 +278,56 @@ type computeSKUResource struct {
 }
 
 type computeSKUSpec struct {
-	VCPU int `json:"vCPU"`
-	RAM  int `json:"ram"`
+	VCPU         int        `json:"vCPU"`
+	RAM          int        `json:"ram"`
+	Architecture string     `json:"architecture,omitempty"`
+	DiskGB       int        `json:"diskGB,omitempty"`
+	Deprecated   bool       `json:"deprecated"`
+	Prices       []skuPrice `json:"prices,omitempty"`
+}
+
+type skuPrice struct {
+	Location             string `json:"location"`
+	Currency             string `json:"currency"`
+	HourlyNet            string `json:"hourlyNet"`
+	HourlyGross          string `json:"hourlyGross"`
+	MonthlyNet           string `json:"monthlyNet"`
+	MonthlyGross         string `json:"monthlyGross"`
+	IncludedTrafficBytes uint64 `json:"includedTrafficBytes,omitempty"`
+}
+
+type storageSKUIterator struct {
+	Items    []storageSKUResource `json:"items"`
+	Metadata responseMetaObject   `json:"metadata"`
+}
+
+type storageSKUResource struct {
+	Metadata resourceMetadata `json:"metadata"`
+	Spec     storageSKUSpec   `json:"spec"`
+}
+
+type storageSKUSpec struct {
+	Type                   string `json:"type"`
+	MinSizeGB              int    `json:"minSizeGB"`
+	MaxSizeGB              int    `json:"maxSizeGB"`
+	Currency               string `json:"currency,omitempty"`
+	PricePerGBMonthlyNet   string `json:"pricePerGBMonthlyNet,omitempty"`
+	PricePerGBMonthlyGross string `json:"pricePerGBMonthlyGross,omitempty"`
+}
+
+type networkSKUIterator struct {
+	Items    []networkSKUResource `json:"items"`
+	Metadata responseMetaObject   `json:"metadata"`
+}
+
+type networkSKUResource struct {
+	Metadata resourceMetadata `json:"metadata"`
+	Spec     networkSKUSpec   `json:"spec"`
+}
+
+type networkSKUSpec struct {
+	Type    string `json:"type"`
+	IPRange string `json:"ipRange"`
 }
 
 type imageIterator struct {
@@ -153,6 +345,8 @@ type imageResource struct {
 type imageSpec struct {
 	BlockStorageRef refObject `json:"blockStorageRef"`
 	CPUArchitecture string    `json:"cpuArchitecture"`
+	OSFlavor        string    `json:"osFlavor,omitempty"`
+	OSVersion       string    `json:"osVersion,omitempty"`
 }
 
 type imageStatus struct {
@@ -218,66 +412,250 @@ func New(
 	catalogProvider CatalogProvider,
 	computeStorageProvider ComputeStorageProvider,
 	networkProvider NetworkProvider,
+	m *metrics.Metrics,
 ) Servers {
+	authenticated := func(next http.HandlerFunc) http.HandlerFunc {
+		if !cfg.PublicAuth {
+			return next
+		}
+		return requireTenantAuth(store.LookupTenantAPIToken, next)
+	}
+	knownTenant := func(next http.HandlerFunc) http.HandlerFunc {
+		if !cfg.TenantRegistry {
+			return next
+		}
+		return requireKnownTenant(store.GetTenant, next)
+	}
+	roleGrants := newRoleGrantsCache(store.ListRoles, store.ListRoleAssignments, roleGrantsTTL)
+	entitlements := newTenantEntitlementsCache(store.GetTenantEntitlements, tenantEntitlementsTTL)
+	readLimiter := newTokenBucketLimiter(cfg.RateLimitReadRPS, cfg.RateLimitReadBurst)
+	writeLimiter := newTokenBucketLimiter(cfg.RateLimitWriteRPS, cfg.RateLimitWriteBurst)
+	rateLimited := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireRateLimit(readLimiter, writeLimiter, m.ObserveRateLimited, next)
+	}
+	entitled := func(provider string, next http.HandlerFunc) http.HandlerFunc {
+		return authenticated(requireValidPathNames(rateLimited(knownTenant(requireProviderEntitlement(entitlements, provider, requireRolePermission(roleGrants, provider, withIdempotency(store, cfg.IdempotencyKeyTTL, next)))))))
+	}
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireAdminAuth(cfg.AdminToken, requireValidPathNames(next))
+	}
+
+	warmupReporter, _ := regionProvider.(WarmupReporter)
+	var healthChecker ProviderHealthChecker
+	if cfg.ReadinessHetzner {
+		healthChecker, _ = regionProvider.(ProviderHealthChecker)
+	}
+	catalogInvalidator, _ := catalogProvider.(CatalogCacheInvalidator)
+	globalCredentials, _ := regionProvider.(GlobalCredentialManager)
+	// The reject strategy applies no defaults: instances need an explicit image.
+	defaultInstanceImage := cfg.DefaultImage
+	if cfg.DefaultSKUStrategy == config.SKUStrategyReject {
+		defaultInstanceImage = ""
+	}
+	var injector *faults.Injector
+	if cfg.FaultInjection {
+		injector = faults.NewInjector()
+		store.InjectQueryFaults(func(ctx context.Context, query string) error {
+			return injector.Inject(ctx, faults.LayerStore, query)
+		})
+		regionProvider = faultingRegionProvider{next: regionProvider, faults: injector}
+		catalogProvider = faultingCatalogProvider{next: catalogProvider, faults: injector}
+		computeStorageProvider = faultingComputeStorageProvider{next: computeStorageProvider, faults: injector}
+		networkProvider = faultingNetworkProvider{next: networkProvider, faults: injector}
+	}
+	if m != nil {
+		store.ObserveQueryErrors(m.ObserveStoreQueryError)
+		m.WatchOperations(store.CountActiveOperationsByPhase)
+		m.WatchDBPool(func() (int32, int32, int32) {
+			stats := store.PoolStats()
+			return stats.Acquired, stats.Idle, stats.Total
+		})
+	}
+
+	putImageHandler, deleteImageHandler := imageWriteHandlers(catalogProvider, computeStorageProvider, store, cfg.ConformanceFlags.ImageStub)
+
 	publicMux := http.NewServeMux()
-	publicMux.HandleFunc("/healthz", healthz)
-	publicMux.HandleFunc("/readyz", readyz(store))
-	publicMux.HandleFunc("/.wellknown/secapi", wellknown(cfg))
-	publicMux.HandleFunc("/v1/regions", listRegions(regionProvider))
-	publicMux.HandleFunc("/v1/regions/{name}", getRegion(regionProvider))
-	publicMux.HandleFunc("/v1/tenants/{tenant}/roles", listRoles(store))
-	publicMux.HandleFunc("/v1/tenants/{tenant}/roles/{name}", roleCRUD(store))
-	publicMux.HandleFunc("/v1/tenants/{tenant}/role-assignments", listRoleAssignments(store))
-	publicMux.HandleFunc("/v1/tenants/{tenant}/role-assignments/{name}", roleAssignmentCRUD(store))
-	publicMux.HandleFunc("/workspace/v1/tenants/{tenant}/workspaces", listWorkspaces(store))
-	publicMux.HandleFunc("/workspace/v1/tenants/{tenant}/workspaces/{name}", workspaceCRUD(store))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/skus", listComputeSKUs(catalogProvider))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/skus/{name}", getComputeSKU(catalogProvider))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/skus", listStorageSKUs())
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/skus/{name}", getStorageSKU())
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/skus", listNetworkSKUs())
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/skus/{name}", getNetworkSKU())
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks", listNetworksProvider(networkProvider, store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", networkCRUDProvider(networkProvider, store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables", listRouteTables(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}", routeTableCRUD(store, computeStorageProvider, networkProvider, cfg))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets", listSubnets(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}", subnetCRUD(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/nics", listNICs(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", nicCRUD(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips", listPublicIPs(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", publicIPCRUD(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups", listSecurityGroups(networkProvider, store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", securityGroupCRUD(networkProvider, store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways", listInternetGateways(store))
-	publicMux.HandleFunc("/network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}", internetGatewayCRUD(store, computeStorageProvider, cfg))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/images", listImages(catalogProvider))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/images/{name}", imageCRUD(catalogProvider, cfg.ConformanceMode))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/workspaces/{workspace}/instances", listInstances(computeStorageProvider, store))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", instanceCRUD(computeStorageProvider, store))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/start", startInstance(computeStorageProvider, store))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/stop", stopInstance(computeStorageProvider, store))
-	publicMux.HandleFunc("/compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/restart", restartInstance(computeStorageProvider, store))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages", listBlockStorages(computeStorageProvider, store))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", blockStorageCRUD(computeStorageProvider, store))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/attach", attachBlockStorage(computeStorageProvider, store))
-	publicMux.HandleFunc("/storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/detach", detachBlockStorage(computeStorageProvider, store))
+	publicRoutes := &routeRegistry{mux: publicMux}
+	openAPI := &openAPIDocument{routes: publicRoutes, baseURL: cfg.PublicBaseURL}
+	publicRoutes.HandleFunc("GET /healthz", healthz)
+	publicRoutes.HandleFunc("GET /readyz", readyz(store.Ping, store.PoolStats, healthChecker, warmupReporter))
+	publicRoutes.HandleFunc("GET /openapi.json", openAPI.serveJSON)
+	publicRoutes.HandleFunc("GET /openapi.yaml", openAPI.serveYAML)
+	publicRoutes.HandleFunc("GET /.wellknown/secapi", wellknown(cfg))
+	publicRoutes.HandleFunc("GET /v1/tenants/{tenant}/.wellknown/secapi", requireValidPathNames(knownTenant(tenantWellknown(cfg, store, entitlements))))
+	publicRoutes.HandleFunc("GET /v1/limits", authenticated(limits()))
+	publicRoutes.HandleFunc("GET /v1/regions", authenticated(listRegions(regionProvider)))
+	publicRoutes.HandleFunc("GET /v1/regions/{name}", authenticated(getRegion(regionProvider)))
+	publicRoutes.HandleFunc("GET /v1/tenants/{tenant}/roles", entitled("seca.authorization/v1", listRoles(store)))
+	publicRoutes.HandleFunc("GET /v1/tenants/{tenant}/roles/{name}", entitled("seca.authorization/v1", getAuthResourceHandler(store, "roles", "role")))
+	publicRoutes.HandleFunc("PUT /v1/tenants/{tenant}/roles/{name}", entitled("seca.authorization/v1", putAuthResourceHandler(store, "roles", "role")))
+	publicRoutes.HandleFunc("DELETE /v1/tenants/{tenant}/roles/{name}", entitled("seca.authorization/v1", deleteAuthResourceHandler(store, "roles", "role")))
+	publicRoutes.HandleFunc("POST /v1/tenants/{tenant}/roles/{action}", entitled("seca.authorization/v1", restoreAuthResourceHandler(store, "roles", "role", cfg.SoftDeleteRetention)))
+	publicRoutes.HandleFunc("GET /v1/tenants/{tenant}/role-assignments", entitled("seca.authorization/v1", listRoleAssignments(store)))
+	publicRoutes.HandleFunc("GET /v1/tenants/{tenant}/role-assignments/{name}", entitled("seca.authorization/v1", getAuthResourceHandler(store, "role-assignments", "role-assignment")))
+	publicRoutes.HandleFunc("PUT /v1/tenants/{tenant}/role-assignments/{name}", entitled("seca.authorization/v1", putAuthResourceHandler(store, "role-assignments", "role-assignment")))
+	publicRoutes.HandleFunc("DELETE /v1/tenants/{tenant}/role-assignments/{name}", entitled("seca.authorization/v1", deleteAuthResourceHandler(store, "role-assignments", "role-assignment")))
+	publicRoutes.HandleFunc("POST /v1/tenants/{tenant}/role-assignments/{action}", entitled("seca.authorization/v1", restoreAuthResourceHandler(store, "role-assignments", "role-assignment", cfg.SoftDeleteRetention)))
+	publicRoutes.HandleFunc("GET /workspace/v1/tenants/{tenant}/workspaces", entitled("seca.workspace/v1", listWorkspaces(store)))
+	publicRoutes.HandleFunc("GET /workspace/v1/tenants/{tenant}/workspaces/{name}", entitled("seca.workspace/v1", getWorkspace(store)))
+	publicRoutes.HandleFunc("PUT /workspace/v1/tenants/{tenant}/workspaces/{name}", entitled("seca.workspace/v1", putWorkspace(store, regionProvider)))
+	publicRoutes.HandleFunc("DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}", entitled("seca.workspace/v1", deleteWorkspace(store, computeStorageProvider, networkProvider)))
+	publicRoutes.HandleFunc("POST /workspace/v1/tenants/{tenant}/workspaces/{action}", entitled("seca.workspace/v1", restoreWorkspace(store, cfg.SoftDeleteRetention)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/skus", entitled("seca.compute/v1", listComputeSKUs(catalogProvider)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/skus/{name}", entitled("seca.compute/v1", getComputeSKU(catalogProvider)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/skus", entitled("seca.storage/v1", listStorageSKUs(catalogProvider)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/skus/{name}", entitled("seca.storage/v1", getStorageSKU(catalogProvider)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/skus/hcloud-volume/availability", entitled("seca.storage/v1", getVolumeAvailability(catalogProvider)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/skus", entitled("seca.network/v1", listNetworkSKUs()))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/skus/{name}", entitled("seca.network/v1", getNetworkSKU()))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks", entitled("seca.network/v1", listNetworksProvider(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", entitled("seca.network/v1", getNetworkProvider(networkProvider, store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", entitled("seca.network/v1", putNetworkProvider(networkProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{name}", entitled("seca.network/v1", deleteNetworkProvider(networkProvider, computeStorageProvider, store, cfg)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables", entitled("seca.network/v1", listRouteTables(store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}", entitled("seca.network/v1", getRouteTable(store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}", entitled("seca.network/v1", putRouteTable(store, computeStorageProvider, networkProvider, cfg)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/route-tables/{name}", entitled("seca.network/v1", deleteRouteTable(store, computeStorageProvider, networkProvider, cfg)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets", entitled("seca.network/v1", listSubnets(store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}", entitled("seca.network/v1", getSubnet(store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}", entitled("seca.network/v1", putSubnet(networkProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/networks/{network}/subnets/{name}", entitled("seca.network/v1", deleteSubnet(networkProvider, computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/nics", entitled("seca.network/v1", listNICs(store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", entitled("seca.network/v1", getNIC(store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", entitled("seca.network/v1", putNIC(computeStorageProvider, networkProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/nics/{name}", entitled("seca.network/v1", deleteNIC(computeStorageProvider, networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips", entitled("seca.network/v1", listPublicIPs(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", entitled("seca.network/v1", getPublicIP(networkProvider, store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", entitled("seca.network/v1", putPublicIP(networkProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/public-ips/{name}", entitled("seca.network/v1", deletePublicIP(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers", entitled("seca.network/v1", listLoadBalancers(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}", entitled("seca.network/v1", getLoadBalancer(networkProvider, store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}", entitled("seca.network/v1", putLoadBalancer(networkProvider, computeStorageProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/load-balancers/{name}", entitled("seca.network/v1", deleteLoadBalancer(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups", entitled("seca.network/v1", listSecurityGroups(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", entitled("seca.network/v1", getSecurityGroup(networkProvider, store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", entitled("seca.network/v1", putSecurityGroup(networkProvider, store)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{name}", entitled("seca.network/v1", deleteSecurityGroup(networkProvider, store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways", entitled("seca.network/v1", listInternetGateways(store)))
+	publicRoutes.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}", entitled("seca.network/v1", getInternetGateway(store)))
+	publicRoutes.HandleFunc("PUT /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}", entitled("seca.network/v1", putInternetGateway(store, computeStorageProvider, cfg)))
+	publicRoutes.HandleFunc("DELETE /network/v1/tenants/{tenant}/workspaces/{workspace}/internet-gateways/{name}", entitled("seca.network/v1", deleteInternetGateway(store, computeStorageProvider, cfg)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/images", entitled("seca.storage/v1", listImages(catalogProvider, store)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/images/{name}", entitled("seca.storage/v1", getImage(catalogProvider, computeStorageProvider, store)))
+	publicRoutes.HandleFunc("PUT /storage/v1/tenants/{tenant}/images/{name}", entitled("seca.storage/v1", putImageHandler))
+	publicRoutes.HandleFunc("DELETE /storage/v1/tenants/{tenant}/images/{name}", entitled("seca.storage/v1", deleteImageHandler))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances", entitled("seca.compute/v1", listInstances(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:start", entitled("seca.compute/v1", startWorkspaceInstances(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:stop", entitled("seca.compute/v1", stopWorkspaceInstances(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances:restart", entitled("seca.compute/v1", restartWorkspaceInstances(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", entitled("seca.compute/v1", getInstance(computeStorageProvider, store, cfg.InstanceUserDataRead)))
+	publicRoutes.HandleFunc("PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", entitled("seca.compute/v1", putInstance(computeStorageProvider, networkProvider, store, cfg.InstanceImageRebuild, cfg.InstanceUserDataRead, defaultInstanceImage)))
+	publicRoutes.HandleFunc("DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", entitled("seca.compute/v1", deleteInstance(computeStorageProvider, store, cfg.InstanceDeleteWait)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/metrics", entitled("seca.compute/v1", getInstanceMetrics(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/operations", entitled("seca.compute/v1", listResourceOperations(store, "instance", computeInstanceRef)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/snapshot", entitled("seca.compute/v1", snapshotInstance(catalogProvider, computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/start", entitled("seca.compute/v1", startInstance(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/stop", entitled("seca.compute/v1", stopInstance(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}/restart", entitled("seca.compute/v1", restartInstance(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups", entitled("seca.compute/v1", listPlacementGroups(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}", entitled("seca.compute/v1", getPlacementGroup(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}", entitled("seca.compute/v1", putPlacementGroup(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("DELETE /compute/v1/tenants/{tenant}/workspaces/{workspace}/placement-groups/{name}", entitled("seca.compute/v1", deletePlacementGroup(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages", entitled("seca.storage/v1", listBlockStorages(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", entitled("seca.storage/v1", getBlockStorage(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("PUT /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", entitled("seca.storage/v1", putBlockStorage(computeStorageProvider, store, cfg.VolumeMaxSizeGB)))
+	publicRoutes.HandleFunc("DELETE /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}", entitled("seca.storage/v1", deleteBlockStorage(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("GET /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/operations", entitled("seca.storage/v1", listResourceOperations(store, "block storage", blockStorageRef)))
+	publicRoutes.HandleFunc("POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/attach", entitled("seca.storage/v1", attachBlockStorage(computeStorageProvider, store)))
+	publicRoutes.HandleFunc("POST /storage/v1/tenants/{tenant}/workspaces/{workspace}/block-storages/{name}/detach", entitled("seca.storage/v1", detachBlockStorage(computeStorageProvider, store)))
 
 	adminMux := http.NewServeMux()
 	adminMux.HandleFunc(
-		"/admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner",
-		requireAdminAuth(cfg.AdminToken, adminWorkspaceHetznerBinding(store, regionProvider)),
+		"GET /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner",
+		admin(adminGetWorkspaceHetznerBinding(store)),
+	)
+	adminMux.HandleFunc(
+		"PUT /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner",
+		admin(adminPutWorkspaceHetznerBinding(store, regionProvider)),
+	)
+	adminMux.HandleFunc(
+		"DELETE /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner",
+		admin(adminDeleteWorkspaceHetznerBinding(store)),
+	)
+	adminMux.HandleFunc(
+		"POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/providers/hetzner/rotate",
+		admin(adminRotateWorkspaceHetznerBinding(store, regionProvider)),
+	)
+	adminMux.HandleFunc("GET /admin/v1/tenants/{tenant}/providers/hetzner", admin(adminListHetznerBindings(store)))
+	adminMux.HandleFunc("GET /admin/v1/tenants", admin(adminListTenants(store)))
+	adminMux.HandleFunc("PUT /admin/v1/tenants/{tenant}", admin(adminPutTenant(store)))
+	adminMux.HandleFunc("DELETE /admin/v1/tenants/{tenant}", admin(adminDeleteTenant(store)))
+	adminMux.HandleFunc("GET /admin/v1/tenants/{tenant}/tokens", admin(adminListTenantTokens(store)))
+	adminMux.HandleFunc("PUT /admin/v1/tenants/{tenant}/tokens/{name}", admin(adminPutTenantToken(store)))
+	adminMux.HandleFunc("DELETE /admin/v1/tenants/{tenant}/tokens/{name}", admin(adminDeleteTenantToken(store)))
+	adminMux.HandleFunc("GET /admin/v1/tenants/{tenant}/entitlements", admin(adminGetTenantEntitlements(store)))
+	adminMux.HandleFunc("PUT /admin/v1/tenants/{tenant}/entitlements", admin(adminPutTenantEntitlements(store, entitlements)))
+	adminMux.HandleFunc("DELETE /admin/v1/tenants/{tenant}/entitlements", admin(adminDeleteTenantEntitlements(store, entitlements)))
+	adminMux.HandleFunc(
+		"POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/security-groups/{action}",
+		admin(adminSecurityGroupAction(store, networkProvider)),
+	)
+	adminMux.HandleFunc(
+		"POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/rebuild-bindings",
+		admin(adminRebuildWorkspaceBindings(store, computeStorageProvider, networkProvider)),
+	)
+	adminMux.HandleFunc(
+		"GET /admin/v1/tenants/{tenant}/workspaces/{workspace}/bindings",
+		admin(adminListWorkspaceBindings(store, computeStorageProvider, networkProvider)),
+	)
+	adminMux.HandleFunc(
+		"DELETE /admin/v1/tenants/{tenant}/workspaces/{workspace}/bindings/{secaRef...}",
+		admin(adminDeleteWorkspaceBinding(store)),
+	)
+	adminMux.HandleFunc(
+		"POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/bindings:reconcile",
+		admin(adminRebuildWorkspaceBindings(store, computeStorageProvider, networkProvider)),
+	)
+	adminMux.HandleFunc(
+		"POST /admin/v1/tenants/{tenant}/workspaces/{workspace}/cleanup",
+		admin(adminCleanupWorkspace(store, computeStorageProvider, networkProvider)),
 	)
+	adminMux.HandleFunc("GET /admin/v1/audit", admin(adminListAudit(store)))
+	adminMux.HandleFunc("POST /admin/v1/credentials/rotate", admin(adminRotateCredentialsKey(store)))
+	if catalogInvalidator != nil {
+		adminMux.HandleFunc("DELETE /admin/v1/catalog-cache", admin(adminCatalogCache(catalogInvalidator)))
+	}
+	if globalCredentials != nil {
+		adminMux.HandleFunc("GET /admin/v1/providers/hetzner/credentials", admin(adminGetGlobalHetznerCredential(store)))
+		adminMux.HandleFunc("PUT /admin/v1/providers/hetzner/credentials", admin(adminPutGlobalHetznerCredential(store, globalCredentials)))
+		adminMux.HandleFunc("DELETE /admin/v1/providers/hetzner/credentials", admin(adminDeleteGlobalHetznerCredential(store, globalCredentials)))
+	}
+	if injector != nil {
+		adminMux.HandleFunc("GET /admin/v1/fault-rules", admin(adminListFaultRules(injector)))
+		adminMux.HandleFunc("POST /admin/v1/fault-rules", admin(adminAddFaultRule(injector)))
+		adminMux.HandleFunc("DELETE /admin/v1/fault-rules/{id}", admin(adminDeleteFaultRule(injector)))
+	}
+
+	publicHandler := withAcceptNegotiation(withConditionalGET(withBodyDecoding(int64(cfg.MaxBodyBytes), cfg.ConformanceMode, withConformanceFlags(cfg.ConformanceFlags, cfg.AdminToken, traceRequests(problemFallbacks(publicMux))))))
+	if m != nil {
+		adminMux.Handle("GET /metrics", m.Handler())
+		publicHandler = instrumentRequests(m, publicHandler)
+	}
+	adminHandler := withBodyDecoding(int64(cfg.MaxBodyBytes), false, traceRequests(problemFallbacks(adminMux)))
+	if cfg.LegacyProblemType {
+		publicHandler = withLegacyProblemContentType(publicHandler)
+		adminHandler = withLegacyProblemContentType(adminHandler)
+	}
 
 	return Servers{
 		Public: &http.Server{
 			Addr:              cfg.ListenAddr,
-			Handler:           publicMux,
+			Handler:           publicHandler,
 			ReadHeaderTimeout: 10 * time.Second,
 		},
 		Admin: &http.Server{
 			Addr:              cfg.AdminListenAddr,
-			Handler:           adminMux,
+			Handler:           adminHandler,
 			ReadHeaderTimeout: 10 * time.Second,
 		},
 	}
@@ -287,22 +665,54 @@ func healthz(w http.ResponseWriter, _ *http.Request) {
 	respondJSON(w, http.StatusOK, statusResponse{Status: "ok"})
 }
 
-func readyz(store *state.Store) http.HandlerFunc {
+// readyz answers 503 only when the database is down. An unreachable or
+// unconfigured Hetzner API is reported but keeps the instance ready, since
+// workspaces with their own credentials may still be served.
+func readyz(ping func(ctx context.Context) error, poolStats func() state.PoolStats, health ProviderHealthChecker, warmup WarmupReporter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := store.Ping(r.Context()); err != nil {
-			respondJSON(w, http.StatusServiceUnavailable, statusResponse{Status: "db_unavailable"})
+		resp := statusResponse{Status: "ready", DB: "ok", Warmup: toWarmupStatusResponse(warmup)}
+		if poolStats != nil {
+			stats := poolStats()
+			resp.DBPool = &dbPoolStatusResponse{Acquired: stats.Acquired, Idle: stats.Idle, Total: stats.Total, Max: stats.Max}
+		}
+		if health != nil {
+			resp.Hetzner = "ok"
+			if err := health.CheckHealth(r.Context()); errors.Is(err, hetzner.ErrNotConfigured) {
+				resp.Hetzner = "not_configured"
+			} else if err != nil {
+				resp.Hetzner = "degraded"
+				resp.Status = "degraded"
+			}
+		}
+		if err := ping(r.Context()); err != nil {
+			resp.Status = "db_unavailable"
+			resp.DB = "unavailable"
+			respondJSON(w, http.StatusServiceUnavailable, resp)
 			return
 		}
-		respondJSON(w, http.StatusOK, statusResponse{Status: "ready"})
+		// Warm-up only pre-fills caches; a failed or pending warm-up is reported
+		// but never turns the instance unready.
+		respondJSON(w, http.StatusOK, resp)
 	}
 }
 
+func toWarmupStatusResponse(warmup WarmupReporter) *warmupStatusResponse {
+	if warmup == nil {
+		return nil
+	}
+	status := warmup.WarmupStatus()
+	out := &warmupStatusResponse{State: status.State, Errors: status.Errors}
+	if !status.StartedAt.IsZero() {
+		out.StartedAt = status.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if !status.FinishedAt.IsZero() {
+		out.FinishedAt = status.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
 func wellknown(cfg config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		base := strings.TrimRight(cfg.PublicBaseURL, "/")
 		respondJSON(w, http.StatusOK, wellknownResponse{
 			Version: "v1",
@@ -317,30 +727,21 @@ func wellknown(cfg config.Config) http.HandlerFunc {
 
 func listRegions(regionProvider RegionProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		regions, err := regionProvider.ListRegions(r.Context())
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
 		items := make([]regionResource, 0, len(regions))
 		for _, region := range regions {
-			items = append(items, toRegionResource(region, now, http.MethodGet))
+			items = append(items, toRegionResource(region, catalogTimestamp, verbList))
 		}
-		respondJSON(w, http.StatusOK, regionIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.region/v1", Resource: "regions", Verb: http.MethodGet}})
+		respondJSON(w, http.StatusOK, regionIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.region/v1", Resource: "regions", Verb: verbList}})
 	}
 }
 
 func getRegion(regionProvider RegionProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		name := r.PathValue("name")
 		if name == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "region name is required", r.URL.Path)
@@ -355,49 +756,51 @@ func getRegion(regionProvider RegionProvider) http.HandlerFunc {
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "region not found", r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, toRegionResource(*region, now, http.MethodGet))
+		respondJSON(w, http.StatusOK, toRegionResource(*region, catalogTimestamp, verbGet))
 	}
 }
 
 func listComputeSKUs(catalogProvider CatalogProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		if tenant == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
 			return
 		}
-		skus, err := catalogProvider.ListComputeSKUs(r.Context())
+		includeDeprecated := false
+		if raw := r.URL.Query().Get("includeDeprecated"); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "includeDeprecated must be true or false", r.URL.Path)
+				return
+			}
+			includeDeprecated = parsed
+		}
+		skus, err := catalogProvider.ListComputeSKUs(catalogRequestContext(r))
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
 		items := make([]computeSKUResource, 0, len(skus))
 		for _, sku := range skus {
-			items = append(items, computeSKUResource{Metadata: resourceMetadata{Name: sku.Name, Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/skus/" + sku.Name, Verb: http.MethodGet, CreatedAt: now, LastModifiedAt: now, ResourceVersion: 1, APIVersion: "v1", Kind: "instance-sku", Ref: "seca.compute/v1/tenants/" + tenant + "/skus/" + sku.Name, Tenant: tenant, Region: "global"}, Spec: computeSKUSpec{VCPU: sku.VCPU, RAM: sku.RAMGiB}})
+			if sku.Deprecated && !includeDeprecated {
+				continue
+			}
+			items = append(items, toComputeSKUResource(tenant, sku, verbList))
 		}
-		respondJSON(w, http.StatusOK, computeSKUIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/skus", Verb: http.MethodGet}})
+		respondJSON(w, http.StatusOK, computeSKUIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/skus", Verb: verbList}})
 	}
 }
 
 func getComputeSKU(catalogProvider CatalogProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		name := strings.ToLower(r.PathValue("name"))
 		if tenant == "" || name == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and sku name are required", r.URL.Path)
 			return
 		}
-		sku, err := catalogProvider.GetComputeSKU(r.Context(), name)
+		sku, err := catalogProvider.GetComputeSKU(catalogRequestContext(r), name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -406,383 +809,234 @@ func getComputeSKU(catalogProvider CatalogProvider) http.HandlerFunc {
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "compute sku not found", r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, computeSKUResource{Metadata: resourceMetadata{Name: sku.Name, Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/skus/" + sku.Name, Verb: http.MethodGet, CreatedAt: now, LastModifiedAt: now, ResourceVersion: 1, APIVersion: "v1", Kind: "instance-sku", Ref: "seca.compute/v1/tenants/" + tenant + "/skus/" + sku.Name, Tenant: tenant, Region: "global"}, Spec: computeSKUSpec{VCPU: sku.VCPU, RAM: sku.RAMGiB}})
+		respondJSON(w, http.StatusOK, toComputeSKUResource(tenant, *sku, verbGet))
 	}
 }
 
-func listStorageSKUs() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
-		tenant := r.PathValue("tenant")
-		if tenant == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
-			return
-		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		items := []computeSKUResource{
-			{
-				Metadata: resourceMetadata{
-					Name:            "hcloud-volume",
-					Provider:        "seca.storage/v1",
-					Resource:        "tenants/" + tenant + "/skus/hcloud-volume",
-					Verb:            http.MethodGet,
-					CreatedAt:       now,
-					LastModifiedAt:  now,
-					ResourceVersion: 1,
-					APIVersion:      "v1",
-					Kind:            "storage-sku",
-					Ref:             "seca.storage/v1/tenants/" + tenant + "/skus/hcloud-volume",
-					Tenant:          tenant,
-					Region:          "global",
-				},
-				Spec: computeSKUSpec{VCPU: 0, RAM: 0},
-			},
-		}
-		respondJSON(w, http.StatusOK, computeSKUIterator{
-			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/skus", Verb: http.MethodGet},
+func toComputeSKUResource(tenant string, sku hetzner.ComputeSKU, verb resourceVerb) computeSKUResource {
+	prices := make([]skuPrice, 0, len(sku.Prices))
+	for _, price := range sku.Prices {
+		prices = append(prices, skuPrice{
+			Location:             price.Location,
+			Currency:             price.Currency,
+			HourlyNet:            price.HourlyNet,
+			HourlyGross:          price.HourlyGross,
+			MonthlyNet:           price.MonthlyNet,
+			MonthlyGross:         price.MonthlyGross,
+			IncludedTrafficBytes: price.IncludedTrafficBytes,
 		})
 	}
-}
-
-func getStorageSKU() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
-		tenant := r.PathValue("tenant")
-		name := strings.ToLower(r.PathValue("name"))
-		if tenant == "" || name == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and sku name are required", r.URL.Path)
-			return
-		}
-		if name != "hcloud-volume" {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "storage sku not found", r.URL.Path)
-			return
-		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, computeSKUResource{
-			Metadata: resourceMetadata{
-				Name:            "hcloud-volume",
-				Provider:        "seca.storage/v1",
-				Resource:        "tenants/" + tenant + "/skus/hcloud-volume",
-				Verb:            http.MethodGet,
-				CreatedAt:       now,
-				LastModifiedAt:  now,
-				ResourceVersion: 1,
-				APIVersion:      "v1",
-				Kind:            "storage-sku",
-				Ref:             "seca.storage/v1/tenants/" + tenant + "/skus/hcloud-volume",
-				Tenant:          tenant,
-				Region:          "global",
-			},
-			Spec: computeSKUSpec{VCPU: 0, RAM: 0},
-		})
+	return computeSKUResource{
+		Metadata: resourceMetadata{Name: sku.Name, Provider: "seca.compute/v1", Resource: "tenants/" + tenant + "/skus/" + sku.Name, Verb: verb, CreatedAt: catalogTimestamp, LastModifiedAt: catalogTimestamp, ResourceVersion: 1, APIVersion: "v1", Kind: "instance-sku", Ref: "seca.compute/v1/tenants/" + tenant + "/skus/" + sku.Name, Tenant: tenant, Region: "global"},
+		Spec: computeSKUSpec{
+			VCPU:         sku.VCPU,
+			RAM:          sku.RAMGiB,
+			Architecture: sku.Architecture,
+			DiskGB:       sku.DiskGB,
+			Deprecated:   sku.Deprecated,
+			Prices:       prices,
+		},
 	}
 }
 
-func listNetworkSKUs() http.HandlerFunc {
+func listStorageSKUs(catalogProvider CatalogProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		if tenant == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		items := []computeSKUResource{
-			{
-				Metadata: resourceMetadata{
-					Name:            "hcloud-network",
-					Provider:        "seca.network/v1",
-					Resource:        "tenants/" + tenant + "/skus/hcloud-network",
-					Verb:            http.MethodGet,
-					CreatedAt:       now,
-					LastModifiedAt:  now,
-					ResourceVersion: 1,
-					APIVersion:      "v1",
-					Kind:            "network-sku",
-					Ref:             "seca.network/v1/tenants/" + tenant + "/skus/hcloud-network",
-					Tenant:          tenant,
-					Region:          "global",
-				},
-				Spec: computeSKUSpec{VCPU: 0, RAM: 0},
-			},
+		pricing, err := catalogProvider.GetVolumePricing(catalogRequestContext(r))
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
 		}
-		respondJSON(w, http.StatusOK, computeSKUIterator{
-			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/skus", Verb: http.MethodGet},
+		respondJSON(w, http.StatusOK, storageSKUIterator{
+			Items:    []storageSKUResource{toStorageSKUResource(tenant, pricing, verbList)},
+			Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/skus", Verb: verbList},
 		})
 	}
 }
 
-func getNetworkSKU() http.HandlerFunc {
+func getStorageSKU(catalogProvider CatalogProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		name := strings.ToLower(r.PathValue("name"))
 		if tenant == "" || name == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and sku name are required", r.URL.Path)
 			return
 		}
-		if name != "hcloud-network" {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "network sku not found", r.URL.Path)
-			return
-		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, computeSKUResource{
-			Metadata: resourceMetadata{
-				Name:            "hcloud-network",
-				Provider:        "seca.network/v1",
-				Resource:        "tenants/" + tenant + "/skus/hcloud-network",
-				Verb:            http.MethodGet,
-				CreatedAt:       now,
-				LastModifiedAt:  now,
-				ResourceVersion: 1,
-				APIVersion:      "v1",
-				Kind:            "network-sku",
-				Ref:             "seca.network/v1/tenants/" + tenant + "/skus/hcloud-network",
-				Tenant:          tenant,
-				Region:          "global",
-			},
-			Spec: computeSKUSpec{VCPU: 0, RAM: 0},
-		})
-	}
-}
-
-func listImages(catalogProvider CatalogProvider) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
-		tenant := r.PathValue("tenant")
-		if tenant == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+		if name != "hcloud-volume" {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "storage sku not found", r.URL.Path)
 			return
 		}
-		images, err := catalogProvider.ListCatalogImages(r.Context())
+		pricing, err := catalogProvider.GetVolumePricing(catalogRequestContext(r))
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		items := make([]imageResource, 0, len(images)+8)
-		for _, rec := range runtimeResourceState.listImagesByTenant(tenant) {
-			items = append(items, toRuntimeImageResource(rec, http.MethodGet, "active"))
-		}
-		for _, img := range images {
-			if _, exists := runtimeResourceState.getImage(imageRef(tenant, img.Name)); exists {
-				continue
-			}
-			items = append(items, imageResource{
-				Metadata: resourceMetadata{
-					Name:            img.Name,
-					Provider:        "seca.storage/v1",
-					Resource:        "tenants/" + tenant + "/images/" + img.Name,
-					Verb:            http.MethodGet,
-					CreatedAt:       now,
-					LastModifiedAt:  now,
-					ResourceVersion: 1,
-					APIVersion:      "v1",
-					Kind:            "image",
-					Ref:             "seca.storage/v1/tenants/" + tenant + "/images/" + img.Name,
-					Tenant:          tenant,
-					Region:          "global",
-				},
-				Spec:   imageSpec{BlockStorageRef: refObject{Resource: "block-storages/" + img.Name}, CPUArchitecture: normalizeArchitecture(img.Architecture)},
-				Status: imageStatus{State: "active"},
-			})
-		}
-		respondJSON(w, http.StatusOK, imageIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/images", Verb: http.MethodGet}})
+		respondJSON(w, http.StatusOK, toStorageSKUResource(tenant, pricing, verbGet))
 	}
 }
 
-func imageCRUD(catalogProvider CatalogProvider, conformanceMode bool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getImage(catalogProvider)(w, r)
-		case http.MethodPut:
-			putImage(conformanceMode)(w, r)
-		case http.MethodDelete:
-			deleteImage(conformanceMode)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
+// toStorageSKUResource describes Hetzner volumes, which come in a single
+// network-attached SSD type between 10 GB and 10 TB. The price is left out
+// when the static catalog is in use.
+func toStorageSKUResource(tenant string, pricing *hetzner.VolumePricing, verb resourceVerb) storageSKUResource {
+	spec := storageSKUSpec{Type: "network-ssd", MinSizeGB: 10, MaxSizeGB: 10240}
+	if pricing != nil {
+		spec.Currency = pricing.Currency
+		spec.PricePerGBMonthlyNet = pricing.PerGBMonthlyNet
+		spec.PricePerGBMonthlyGross = pricing.PerGBMonthlyGross
 	}
-}
-
-func getImage(catalogProvider CatalogProvider) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		tenant := r.PathValue("tenant")
-		name := strings.ToLower(r.PathValue("name"))
-		if tenant == "" || name == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
-			return
-		}
-		if rec, ok := runtimeResourceState.getImage(imageRef(tenant, name)); ok {
-			respondJSON(w, http.StatusOK, toRuntimeImageResource(rec, http.MethodGet, "active"))
-			return
-		}
-		img, err := catalogProvider.GetCatalogImage(r.Context(), name)
-		if err != nil {
-			respondFromError(w, err, r.URL.Path)
-			return
-		}
-		if img == nil {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "image not found", r.URL.Path)
-			return
-		}
-		now := time.Now().UTC().Format(time.RFC3339)
-		respondJSON(w, http.StatusOK, imageResource{
-			Metadata: resourceMetadata{
-				Name:            img.Name,
-				Provider:        "seca.storage/v1",
-				Resource:        "tenants/" + tenant + "/images/" + img.Name,
-				Verb:            http.MethodGet,
-				CreatedAt:       now,
-				LastModifiedAt:  now,
-				ResourceVersion: 1,
-				APIVersion:      "v1",
-				Kind:            "image",
-				Ref:             "seca.storage/v1/tenants/" + tenant + "/images/" + img.Name,
-				Tenant:          tenant,
-				Region:          "global",
-			},
-			Spec:   imageSpec{BlockStorageRef: refObject{Resource: "block-storages/" + img.Name}, CPUArchitecture: normalizeArchitecture(img.Architecture)},
-			Status: imageStatus{State: "active"},
-		})
+	return storageSKUResource{
+		Metadata: resourceMetadata{
+			Name:            "hcloud-volume",
+			Provider:        "seca.storage/v1",
+			Resource:        "tenants/" + tenant + "/skus/hcloud-volume",
+			Verb:            verb,
+			CreatedAt:       catalogTimestamp,
+			LastModifiedAt:  catalogTimestamp,
+			ResourceVersion: 1,
+			APIVersion:      "v1",
+			Kind:            "storage-sku",
+			Ref:             "seca.storage/v1/tenants/" + tenant + "/skus/hcloud-volume",
+			Tenant:          tenant,
+			Region:          "global",
+		},
+		Spec: spec,
 	}
 }
 
-func putImage(conformanceMode bool) http.HandlerFunc {
+func listNetworkSKUs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !conformanceMode {
-			respondProblem(w, http.StatusNotImplemented, "http://secapi.cloud/errors/not-implemented", "Not Implemented", "image upload workflow is not implemented", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
-		name := strings.ToLower(r.PathValue("name"))
-		if tenant == "" || name == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
-			return
-		}
-		var req imageResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
-			return
-		}
-		if strings.TrimSpace(req.Spec.BlockStorageRef.Resource) == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.blockStorageRef is required", r.URL.Path)
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
 			return
 		}
-		cpuArch := normalizeArchitecture(req.Spec.CPUArchitecture)
-		region := strings.TrimSpace(req.Metadata.Region)
-		if region == "" {
-			region = "global"
-		}
-
-		now := time.Now().UTC().Format(time.RFC3339)
-		rec, created := runtimeResourceState.upsertImage(imageRef(tenant, name), imageRuntimeRecord{
-			Tenant:         tenant,
-			Name:           name,
-			Region:         region,
-			Labels:         req.Labels,
-			Spec:           imageSpec{BlockStorageRef: req.Spec.BlockStorageRef, CPUArchitecture: cpuArch},
-			CreatedAt:      now,
-			LastModifiedAt: now,
+		respondJSON(w, http.StatusOK, networkSKUIterator{
+			Items:    []networkSKUResource{toNetworkSKUResource(tenant, verbList)},
+			Metadata: responseMetaObject{Provider: "seca.network/v1", Resource: "tenants/" + tenant + "/skus", Verb: verbList},
 		})
-		stateValue := "updating"
-		code := http.StatusOK
-		if created {
-			stateValue = "creating"
-			code = http.StatusCreated
-		}
-		respondJSON(w, code, toRuntimeImageResource(rec, http.MethodPut, stateValue))
 	}
 }
 
-func deleteImage(conformanceMode bool) http.HandlerFunc {
+func getNetworkSKU() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !conformanceMode {
-			respondProblem(w, http.StatusNotImplemented, "http://secapi.cloud/errors/not-implemented", "Not Implemented", "image upload workflow is not implemented", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		name := strings.ToLower(r.PathValue("name"))
 		if tenant == "" || name == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and sku name are required", r.URL.Path)
 			return
 		}
-		if _, ok := runtimeResourceState.getImage(imageRef(tenant, name)); !ok {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "image not found", r.URL.Path)
+		if name != "hcloud-network" {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "network sku not found", r.URL.Path)
 			return
 		}
-		runtimeResourceState.deleteImage(imageRef(tenant, name))
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		respondJSON(w, http.StatusOK, toNetworkSKUResource(tenant, verbGet))
 	}
 }
 
-func imageRef(tenant, name string) string {
-	return strings.ToLower(strings.TrimSpace(tenant)) + "/" + strings.ToLower(strings.TrimSpace(name))
-}
-
-func toRuntimeImageResource(rec imageRuntimeRecord, verb, state string) imageResource {
-	return imageResource{
+// toNetworkSKUResource describes Hetzner private networks, which are free
+// and take their ranges from the RFC 1918 space.
+func toNetworkSKUResource(tenant string, verb resourceVerb) networkSKUResource {
+	return networkSKUResource{
 		Metadata: resourceMetadata{
-			Name:            rec.Name,
-			Provider:        "seca.storage/v1",
-			Resource:        "tenants/" + rec.Tenant + "/images/" + rec.Name,
+			Name:            "hcloud-network",
+			Provider:        "seca.network/v1",
+			Resource:        "tenants/" + tenant + "/skus/hcloud-network",
 			Verb:            verb,
-			CreatedAt:       rec.CreatedAt,
-			LastModifiedAt:  rec.LastModifiedAt,
-			ResourceVersion: rec.ResourceVersion,
+			CreatedAt:       catalogTimestamp,
+			LastModifiedAt:  catalogTimestamp,
+			ResourceVersion: 1,
 			APIVersion:      "v1",
-			Kind:            "image",
-			Ref:             "seca.storage/v1/tenants/" + rec.Tenant + "/images/" + rec.Name,
-			Tenant:          rec.Tenant,
-			Region:          rec.Region,
+			Kind:            "network-sku",
+			Ref:             "seca.network/v1/tenants/" + tenant + "/skus/hcloud-network",
+			Tenant:          tenant,
+			Region:          "global",
 		},
-		Labels: rec.Labels,
-		Spec:   rec.Spec,
-		Status: imageStatus{State: state},
+		Spec: networkSKUSpec{Type: "private", IPRange: "10.0.0.0/8"},
 	}
 }
 
-func toRegionResource(region hetzner.Region, now, verb string) regionResource {
+func toRegionResource(region hetzner.Region, now string, verb resourceVerb) regionResource {
 	providers := make([]regionSpecVendor, 0, len(region.Providers))
 	for _, provider := range region.Providers {
 		providers = append(providers, regionSpecVendor{Name: provider.Name, Version: provider.Version, URL: provider.URL})
 	}
-	return regionResource{Metadata: resourceMetadata{Name: region.Name, Provider: "seca.region/v1", Resource: "regions/" + region.Name, Verb: verb, CreatedAt: now, LastModifiedAt: now, ResourceVersion: 1, APIVersion: "v1", Kind: "region", Ref: "seca.region/v1/regions/" + region.Name}, Spec: regionSpec{AvailableZones: region.Zones, Providers: providers}}
+	architectures := make([]string, 0, len(region.Architectures))
+	for _, arch := range region.Architectures {
+		architectures = append(architectures, normalizeArchitecture(arch))
+	}
+	return regionResource{Metadata: resourceMetadata{Name: region.Name, Provider: "seca.region/v1", Resource: "regions/" + region.Name, Verb: verb, CreatedAt: now, LastModifiedAt: now, ResourceVersion: 1, APIVersion: "v1", Kind: "region", Ref: "seca.region/v1/regions/" + region.Name}, Spec: regionSpec{
+		AvailableZones: region.Zones,
+		NetworkZone:    region.NetworkZone,
+		Architectures:  architectures,
+		BlockStorage:   region.BlockStorage,
+		Providers:      providers,
+	}}
 }
 
 func normalizeArchitecture(arch string) string {
-	switch strings.ToLower(arch) {
+	if normalized, ok := canonicalArchitecture(arch); ok {
+		return normalized
+	}
+	return "amd64"
+}
+
+// canonicalArchitecture maps the architecture spellings SECA clients and
+// Hetzner use to amd64 or arm64, reporting false for anything else.
+func canonicalArchitecture(arch string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(arch)) {
 	case "x86", "x86_64", "amd64":
-		return "amd64"
+		return "amd64", true
 	case "arm", "arm64", "aarch64":
-		return "arm64"
+		return "arm64", true
+	default:
+		return "", false
+	}
+}
+
+// hetznerArchitecture is the Hetzner name of a canonical architecture.
+func hetznerArchitecture(arch string) string {
+	switch arch {
+	case "amd64":
+		return "x86"
+	case "arm64":
+		return "arm"
 	default:
-		return "amd64"
+		return ""
 	}
 }
 
 func respondFromError(w http.ResponseWriter, err error, instance string) {
 	if errors.Is(err, hetzner.ErrNotConfigured) {
-		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "hetzner token is not configured", instance)
+		respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", "no hetzner token available: bind workspace credentials or set SECA_HETZNER_TOKEN", instance)
+		return
+	}
+	if errors.Is(err, hetzner.ErrTimeout) {
+		respondProblem(w, http.StatusGatewayTimeout, "http://secapi.cloud/errors/provider-unavailable", "Gateway Timeout", err.Error(), instance)
+		return
+	}
+	if errors.Is(err, hetzner.ErrShuttingDown) {
+		respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/service-unavailable", "Service Unavailable", "the proxy is shutting down; retry the request", instance)
+		return
+	}
+	if errors.Is(err, hetzner.ErrCredentialRevoked) {
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "workspace has no hetzner credentials", instance)
+		return
+	}
+	switch {
+	case errors.Is(err, state.ErrNotFound):
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "resource not found", instance)
+		return
+	case errors.Is(err, state.ErrConflict):
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "resource was modified concurrently", instance)
+		return
+	case errors.Is(err, state.ErrUnavailable):
+		logResponseError(w, "state store unavailable on %s: %v", instance, err)
+		respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/service-unavailable", "Service Unavailable", "state store unavailable", instance)
 		return
 	}
 	var providerErr hetzner.ProviderError
@@ -792,6 +1046,10 @@ func respondFromError(w http.ResponseWriter, err error, instance string) {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", providerErr.Message, instance)
 		case "not_found":
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", providerErr.Message, instance)
+		case "conflict":
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", providerErr.Message, instance)
+		case "unavailable":
+			respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", providerErr.Message, instance)
 		default:
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", providerErr.Message, instance)
 		}
@@ -811,6 +1069,9 @@ func respondFromError(w http.ResponseWriter, err error, instance string) {
 		case hcloud.ErrorCodeInvalidInput, hcloud.ErrorCodeJSONError, hcloud.ErrorCodeInvalidServerType, hcloud.ErrorCodeServerNotStopped:
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", apiErr.Message, instance)
 		case hcloud.ErrorCodeRateLimitExceeded, hcloud.ErrorCodeResourceLimitExceeded:
+			if wait, ok := hetzner.RetryAfter(err); ok {
+				w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			}
 			respondProblem(w, http.StatusTooManyRequests, "http://secapi.cloud/errors/rate-limited", "Too Many Requests", apiErr.Message, instance)
 		case hcloud.ErrorCodeResourceUnavailable, hcloud.ErrorCodeMaintenance, hcloud.ErrorCodeRobotUnavailable, hcloud.ErrorCodeTimeout, hcloud.ErrorCodeNoSpaceLeftInLocation:
 			respondProblem(w, http.StatusServiceUnavailable, "http://secapi.cloud/errors/provider-unavailable", "Service Unavailable", apiErr.Message, instance)
@@ -821,15 +1082,45 @@ func respondFromError(w http.ResponseWriter, err error, instance string) {
 		}
 		return
 	}
-	respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", err.Error(), instance)
+	// Anything else is an internal failure such as a database error; its text
+	// stays in the log.
+	logResponseError(w, "internal error on %s: %v", instance, err)
+	respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal-server-error", "Internal Server Error", "internal error", instance)
+}
+
+// retryAfterSeconds formats wait as a Retry-After value, rounding up so
+// clients never retry early.
+func retryAfterSeconds(wait time.Duration) string {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	return strconv.FormatInt(max(seconds, 1), 10)
 }
 
 func respondProblem(w http.ResponseWriter, code int, errType, title, detail, instance string) {
-	respondJSON(w, code, problemResponse{Type: errType, Title: title, Status: code, Detail: detail, Instance: instance, Sources: []problemSource{}})
+	respondProblemWithSources(w, code, errType, title, detail, instance, nil)
+}
+
+func respondProblemWithSources(w http.ResponseWriter, code int, errType, title, detail, instance string, sources []problemSource) {
+	if sources == nil {
+		sources = []problemSource{}
+	}
+	respondProblemJSON(w, code, problemResponse{Type: errType, Title: title, Status: code, Detail: detail, Instance: problemInstance(w, instance), Sources: sources})
+}
+
+// problemContentType is the RFC 9457 media type of every error body.
+const problemContentType = "application/problem+json"
+
+// respondProblemJSON writes payload, a problemResponse or a type embedding
+// one, as a problem document.
+func respondProblemJSON(w http.ResponseWriter, code int, payload any) {
+	writeJSON(w, code, problemContentType, payload)
 }
 
 func respondJSON(w http.ResponseWriter, code int, payload any) {
-	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, code, "application/json", payload)
+}
+
+func writeJSON(w http.ResponseWriter, code int, contentType string, payload any) {
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
 	_ = json.NewEncoder(w).Encode(payload)
 }
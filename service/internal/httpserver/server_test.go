@@ -3,12 +3,16 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
 
 func TestHealthz(t *testing.T) {
@@ -54,6 +58,39 @@ func (f fakeRegionProvider) GetRegion(_ context.Context, name string) (*hetzner.
 	return &region, nil
 }
 
+type fakeHealthChecker struct{ err error }
+
+func (f fakeHealthChecker) CheckHealth(context.Context) error { return f.err }
+
+func TestReadyzReportsDependencies(t *testing.T) {
+	dbDown := errors.New("connection refused")
+	cases := []struct {
+		name       string
+		dbErr      error
+		health     ProviderHealthChecker
+		wantCode   int
+		wantStatus statusResponse
+	}{
+		{name: "healthy", health: fakeHealthChecker{}, wantCode: http.StatusOK, wantStatus: statusResponse{Status: "ready", DB: "ok", Hetzner: "ok"}},
+		{name: "hetzner down", health: fakeHealthChecker{err: errors.New("timeout")}, wantCode: http.StatusOK, wantStatus: statusResponse{Status: "degraded", DB: "ok", Hetzner: "degraded"}},
+		{name: "no token", health: fakeHealthChecker{err: hetzner.ErrNotConfigured}, wantCode: http.StatusOK, wantStatus: statusResponse{Status: "ready", DB: "ok", Hetzner: "not_configured"}},
+		{name: "db down", dbErr: dbDown, health: fakeHealthChecker{}, wantCode: http.StatusServiceUnavailable, wantStatus: statusResponse{Status: "db_unavailable", DB: "unavailable", Hetzner: "ok"}},
+		{name: "check disabled", wantCode: http.StatusOK, wantStatus: statusResponse{Status: "ready", DB: "ok"}},
+	}
+	for _, tc := range cases {
+		ping := func(context.Context) error { return tc.dbErr }
+		w := httptest.NewRecorder()
+		readyz(ping, nil, tc.health, nil)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var got statusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: decode body: %v", tc.name, err)
+		}
+		if w.Code != tc.wantCode || got != tc.wantStatus {
+			t.Fatalf("%s: expected %d %+v, got %d %+v", tc.name, tc.wantCode, tc.wantStatus, w.Code, got)
+		}
+	}
+}
+
 func TestWellknown(t *testing.T) {
 	cfg := config.Config{PublicBaseURL: "http://localhost:8080"}
 	handler := wellknown(cfg)
@@ -100,3 +137,192 @@ func TestListRegions(t *testing.T) {
 		t.Fatalf("unexpected region name: %s", payload.Items[0].Metadata.Name)
 	}
 }
+
+type fakeCatalogProvider struct{}
+
+func (fakeCatalogProvider) ListComputeSKUs(context.Context) ([]hetzner.ComputeSKU, error) {
+	return []hetzner.ComputeSKU{{Name: "cx22", VCPU: 2, RAMGiB: 4}}, nil
+}
+
+func (fakeCatalogProvider) GetComputeSKU(_ context.Context, name string) (*hetzner.ComputeSKU, error) {
+	if name != "cx22" {
+		return nil, nil
+	}
+	return &hetzner.ComputeSKU{Name: "cx22", VCPU: 2, RAMGiB: 4}, nil
+}
+
+func (fakeCatalogProvider) ListCatalogImages(context.Context) ([]hetzner.CatalogImage, error) {
+	return []hetzner.CatalogImage{{Name: "ubuntu-24.04", Architecture: "x86"}}, nil
+}
+
+func (fakeCatalogProvider) GetCatalogImage(_ context.Context, name, _ string) (*hetzner.CatalogImage, error) {
+	if name != "ubuntu-24.04" {
+		return nil, nil
+	}
+	return &hetzner.CatalogImage{Name: "ubuntu-24.04", Architecture: "x86"}, nil
+}
+
+func (fakeCatalogProvider) GetVolumePricing(context.Context) (*hetzner.VolumePricing, error) {
+	return &hetzner.VolumePricing{Currency: "EUR", PerGBMonthlyNet: "0.0440", PerGBMonthlyGross: "0.0524"}, nil
+}
+
+func (fakeCatalogProvider) VolumeCapacityByLocation(context.Context) ([]hetzner.VolumeCapacity, error) {
+	return []hetzner.VolumeCapacity{{Region: "fsn1", NetworkZone: "eu-central", Available: true}}, nil
+}
+
+func TestResponseVerbsPerRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/regions", listRegions(fakeRegionProvider{}))
+	mux.HandleFunc("/v1/regions/{name}", getRegion(fakeRegionProvider{}))
+	mux.HandleFunc("/compute/v1/tenants/{tenant}/skus", listComputeSKUs(fakeCatalogProvider{}))
+	mux.HandleFunc("/compute/v1/tenants/{tenant}/skus/{name}", getComputeSKU(fakeCatalogProvider{}))
+	mux.HandleFunc("/storage/v1/tenants/{tenant}/skus", listStorageSKUs(fakeCatalogProvider{}))
+	mux.HandleFunc("/storage/v1/tenants/{tenant}/skus/{name}", getStorageSKU(fakeCatalogProvider{}))
+	mux.HandleFunc("/network/v1/tenants/{tenant}/skus", listNetworkSKUs())
+	mux.HandleFunc("/network/v1/tenants/{tenant}/skus/{name}", getNetworkSKU())
+	mux.HandleFunc("/storage/v1/tenants/{tenant}/images", listImages(fakeCatalogProvider{}, nil))
+	putImageHandler, _ := imageWriteHandlers(fakeCatalogProvider{}, nil, nil, true)
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/images/{name}", getImage(fakeCatalogProvider{}, nil, nil))
+	mux.HandleFunc("PUT /storage/v1/tenants/{tenant}/images/{name}", putImageHandler)
+
+	imageBody := `{"spec":{"blockStorageRef":{"resource":"block-storages/disk"},"cpuArchitecture":"amd64"}}`
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		verb   resourceVerb
+		list   bool
+	}{
+		{method: http.MethodGet, path: "/v1/regions", status: http.StatusOK, verb: verbList, list: true},
+		{method: http.MethodGet, path: "/v1/regions/fsn1", status: http.StatusOK, verb: verbGet},
+		{method: http.MethodGet, path: "/compute/v1/tenants/verbs/skus", status: http.StatusOK, verb: verbList, list: true},
+		{method: http.MethodGet, path: "/compute/v1/tenants/verbs/skus/cx22", status: http.StatusOK, verb: verbGet},
+		{method: http.MethodGet, path: "/storage/v1/tenants/verbs/skus", status: http.StatusOK, verb: verbList, list: true},
+		{method: http.MethodGet, path: "/storage/v1/tenants/verbs/skus/hcloud-volume", status: http.StatusOK, verb: verbGet},
+		{method: http.MethodGet, path: "/network/v1/tenants/verbs/skus", status: http.StatusOK, verb: verbList, list: true},
+		{method: http.MethodGet, path: "/network/v1/tenants/verbs/skus/hcloud-network", status: http.StatusOK, verb: verbGet},
+		{method: http.MethodGet, path: "/storage/v1/tenants/verbs/images", status: http.StatusOK, verb: verbList, list: true},
+		{method: http.MethodGet, path: "/storage/v1/tenants/verbs/images/ubuntu-24.04", status: http.StatusOK, verb: verbGet},
+		{method: http.MethodPut, path: "/storage/v1/tenants/verbs/images/custom", body: imageBody, status: http.StatusCreated, verb: verbCreate},
+		{method: http.MethodPut, path: "/storage/v1/tenants/verbs/images/custom", body: imageBody, status: http.StatusOK, verb: verbUpdate},
+		{method: http.MethodGet, path: "/storage/v1/tenants/verbs/images/custom", status: http.StatusOK, verb: verbGet},
+	}
+	t.Cleanup(func() { runtimeResourceState.deleteImage(imageRef("verbs", "custom")) })
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tc.method, tc.path, tc.status, w.Code, w.Body.String())
+		}
+		var payload struct {
+			Metadata struct {
+				Verb resourceVerb `json:"verb"`
+			} `json:"metadata"`
+			Items []struct {
+				Metadata struct {
+					Verb resourceVerb `json:"verb"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&payload); err != nil {
+			t.Fatalf("%s %s: decode response: %v", tc.method, tc.path, err)
+		}
+		if payload.Metadata.Verb != tc.verb {
+			t.Fatalf("%s %s: expected verb %q, got %q", tc.method, tc.path, tc.verb, payload.Metadata.Verb)
+		}
+		if tc.list && len(payload.Items) == 0 {
+			t.Fatalf("%s %s: expected list items", tc.method, tc.path)
+		}
+		for _, item := range payload.Items {
+			if item.Metadata.Verb != verbList {
+				t.Fatalf("%s %s: expected item verb %q, got %q", tc.method, tc.path, verbList, item.Metadata.Verb)
+			}
+		}
+	}
+}
+
+type deprecatedSKUCatalog struct{ fakeCatalogProvider }
+
+func (deprecatedSKUCatalog) ListComputeSKUs(context.Context) ([]hetzner.ComputeSKU, error) {
+	return []hetzner.ComputeSKU{
+		{Name: "cx22", VCPU: 2, RAMGiB: 4, DiskGB: 40, Prices: []hetzner.SKUPrice{{Location: "fsn1", Currency: "EUR", MonthlyNet: "3.79"}}},
+		{Name: "cx11", VCPU: 1, RAMGiB: 2, DiskGB: 20, Deprecated: true},
+	}, nil
+}
+
+func TestListComputeSKUsHidesDeprecatedUnlessRequested(t *testing.T) {
+	t.Parallel()
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("GET /compute/v1/tenants/{tenant}/skus", listComputeSKUs(deprecatedSKUCatalog{}))
+	list := func(query string) computeSKUIterator {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/t1/skus"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var out computeSKUIterator
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode skus: %v", err)
+		}
+		return out
+	}
+
+	current := list("")
+	if len(current.Items) != 1 || current.Items[0].Metadata.Name != "cx22" {
+		t.Fatalf("expected only cx22, got %+v", current.Items)
+	}
+	spec := current.Items[0].Spec
+	if spec.DiskGB != 40 || len(spec.Prices) != 1 || spec.Prices[0].MonthlyNet != "3.79" {
+		t.Fatalf("expected disk and price in the spec, got %+v", spec)
+	}
+	if all := list("?includeDeprecated=true"); len(all.Items) != 2 || !all.Items[1].Spec.Deprecated {
+		t.Fatalf("expected deprecated cx11 with includeDeprecated, got %+v", all.Items)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/t1/skus?includeDeprecated=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid includeDeprecated, got %d", rec.Code)
+	}
+}
+
+func TestRespondFromErrorMapsProviderConflicts(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	respondFromError(rec, hetzner.ProviderError{Code: "conflict", Message: "subnet cidr 10.0.0.0/24 overlaps existing subnet 10.0.0.0/16"}, "/network/v1/tenants/t1/workspaces/ws1/networks/net1/subnets/sub1")
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusConflict || problem.Type != "http://secapi.cloud/errors/resource-conflict" || !strings.Contains(problem.Detail, "overlaps") {
+		t.Fatalf("expected a 409 resource conflict with the provider message, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRespondFromErrorMapsStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := map[error]int{
+		state.ErrNotFound:    http.StatusNotFound,
+		state.ErrConflict:    http.StatusConflict,
+		state.ErrUnavailable: http.StatusServiceUnavailable,
+	}
+	for sentinel, want := range cases {
+		rec := httptest.NewRecorder()
+		respondFromError(rec, fmt.Errorf("get workspace: %w: connection reset by peer", sentinel), "/v1/tenants/t1/workspaces/ws1")
+		if rec.Code != want {
+			t.Fatalf("%v: expected %d, got %d", sentinel, want, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "connection reset") {
+			t.Fatalf("%v: expected the database error to stay out of the response, got %s", sentinel, rec.Body.String())
+		}
+	}
+}
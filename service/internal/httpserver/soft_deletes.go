@@ -0,0 +1,114 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// restoreWindowStart is the earliest deletion a restore still undoes. A
+// retention of 0 keeps soft-deleted rows forever, so every deletion can be
+// undone.
+func restoreWindowStart(retention time.Duration) time.Time {
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-retention)
+}
+
+// restoreTarget returns the name in a POST .../{name}:restore path. The mux
+// cannot match a literal suffix after a wildcard, so the action is split off
+// the last segment; other actions answer 404.
+func restoreTarget(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name, action, _ := strings.Cut(r.PathValue("action"), ":")
+	if action != "restore" {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "unknown action", r.URL.Path)
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(name)), true
+}
+
+// respondNotRestorable explains why a restore found nothing to restore: 404
+// when the resource never existed or was purged, 409 when it is not deleted
+// and 410 when it was deleted before the restore window.
+func respondNotRestorable(w http.ResponseWriter, r *http.Request, deletion state.Deletion, retention time.Duration, kind string) {
+	switch {
+	case !deletion.Found:
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", kind+" not found", r.URL.Path)
+	case !deletion.Deleted():
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", kind+" is not deleted", r.URL.Path)
+	default:
+		respondProblem(w, http.StatusGone, "http://secapi.cloud/errors/resource-gone", "Gone",
+			fmt.Sprintf("%s was deleted at %s, more than %s ago, and can no longer be restored", kind, deletion.DeletedAt.UTC().Format(time.RFC3339), retention), r.URL.Path)
+	}
+}
+
+// restoreWorkspace undoes the soft delete of a workspace within the
+// retention window. It restores the workspace record only; resources a
+// forced delete purged from Hetzner stay gone.
+func restoreWorkspace(store *state.Store, retention time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, ok := restoreTarget(w, r)
+		if !ok {
+			return
+		}
+		tenant := r.PathValue("tenant")
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and workspace name are required", r.URL.Path)
+			return
+		}
+		restored, err := store.RestoreWorkspace(r.Context(), tenant, name, restoreWindowStart(retention))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to restore workspace", r.URL.Path)
+			return
+		}
+		if restored == nil {
+			deletion, err := store.WorkspaceDeletion(r.Context(), tenant, name)
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to get workspace", r.URL.Path)
+				return
+			}
+			respondNotRestorable(w, r, deletion, retention, "workspace")
+			return
+		}
+		respondJSON(w, http.StatusOK, toWorkspaceResource(*restored, verbUpdate, false))
+	}
+}
+
+// restoreAuthResourceHandler is restoreWorkspace for roles and role
+// assignments.
+func restoreAuthResourceHandler(store *state.Store, collection, kind string, retention time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, ok := restoreTarget(w, r)
+		if !ok {
+			return
+		}
+		tenant := r.PathValue("tenant")
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and name are required", r.URL.Path)
+			return
+		}
+		restore, deletion := store.RestoreRole, store.RoleDeletion
+		if collection == "role-assignments" {
+			restore, deletion = store.RestoreRoleAssignment, store.RoleAssignmentDeletion
+		}
+		restored, err := restore(r.Context(), tenant, name, restoreWindowStart(retention))
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if restored == nil {
+			current, err := deletion(r.Context(), tenant, name)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			respondNotRestorable(w, r, current, retention, kind)
+			return
+		}
+		respondJSON(w, http.StatusOK, toAuthResource(collection, kind, verbUpdate, *restored))
+	}
+}
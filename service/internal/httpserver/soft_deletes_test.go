@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreUndoesSoftDeletesWithinRetention(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /workspace/v1/tenants/{tenant}/workspaces/{name}", putWorkspace(store, fakeRegionProvider{}))
+	mux.HandleFunc("GET /workspace/v1/tenants/{tenant}/workspaces/{name}", getWorkspace(store))
+	mux.HandleFunc("DELETE /workspace/v1/tenants/{tenant}/workspaces/{name}", deleteWorkspace(store, &fakeComputeProvider{}, nil))
+	mux.HandleFunc("POST /workspace/v1/tenants/{tenant}/workspaces/{action}", restoreWorkspace(store, time.Hour))
+	mux.HandleFunc("PUT /v1/tenants/{tenant}/roles/{name}", putAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("GET /v1/tenants/{tenant}/roles/{name}", getAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("DELETE /v1/tenants/{tenant}/roles/{name}", deleteAuthResourceHandler(store, "roles", "role"))
+	mux.HandleFunc("POST /v1/tenants/{tenant}/roles/{action}", restoreAuthResourceHandler(store, "roles", "role", time.Hour))
+	expiredMux := http.NewServeMux()
+	expiredMux.HandleFunc("POST /workspace/v1/tenants/{tenant}/workspaces/{action}", restoreWorkspace(store, time.Nanosecond))
+	serve := func(handler http.Handler, method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(`{"spec":{}}`)))
+		return rec.Code
+	}
+
+	for _, path := range []string{
+		"/workspace/v1/tenants/" + tenant + "/workspaces/ws",
+		"/v1/tenants/" + tenant + "/roles/viewer",
+	} {
+		if code := serve(mux, http.MethodPost, path+":restore"); code != http.StatusNotFound {
+			t.Fatalf("restore %s: expected 404 before it exists, got %d", path, code)
+		}
+		if code := serve(mux, http.MethodPut, path); code != http.StatusCreated {
+			t.Fatalf("PUT %s: expected 201, got %d", path, code)
+		}
+		if code := serve(mux, http.MethodPost, path+":restore"); code != http.StatusConflict {
+			t.Fatalf("restore %s: expected 409 while it is live, got %d", path, code)
+		}
+		if code := serve(mux, http.MethodDelete, path); code != http.StatusAccepted {
+			t.Fatalf("DELETE %s: expected 202, got %d", path, code)
+		}
+		if code := serve(mux, http.MethodPost, path+":restore"); code != http.StatusOK {
+			t.Fatalf("restore %s: expected 200, got %d", path, code)
+		}
+		if code := serve(mux, http.MethodGet, path); code != http.StatusOK {
+			t.Fatalf("GET %s: expected the restored resource, got %d", path, code)
+		}
+	}
+
+	path := "/workspace/v1/tenants/" + tenant + "/workspaces/ws"
+	if code := serve(mux, http.MethodDelete, path); code != http.StatusAccepted {
+		t.Fatalf("DELETE %s: expected 202, got %d", path, code)
+	}
+	if code := serve(expiredMux, http.MethodPost, path+":restore"); code != http.StatusGone {
+		t.Fatalf("restore %s: expected 410 past the retention window, got %d", path, code)
+	}
+}
@@ -0,0 +1,165 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+// pendingAttachmentTTL bounds how long an accepted attach is counted against
+// the instance before the provider reports the volume as attached.
+const pendingAttachmentTTL = 5 * time.Minute
+
+var instanceAttachments = newInstanceAttachmentTracker()
+
+// instanceAttachmentTracker serializes attach requests per instance and
+// remembers accepted attaches whose provider action has not finished yet, so
+// two requests racing for the last slot cannot both pass the limit check.
+// The state is per process: the provider waits for each attach before the
+// request answers, so nothing outlives a restart, and an attach racing on
+// another replica is still rejected by Hetzner's own per-server limit.
+type instanceAttachmentTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	locks   map[string]*attachmentLock
+	pending map[string]map[int64]time.Time
+}
+
+// attachmentLock is a per-instance lock with the number of requests holding
+// or waiting for it, so the entry can be dropped once nobody needs it.
+type attachmentLock struct {
+	sync.Mutex
+	users int
+}
+
+type attachmentLimitProblem struct {
+	problemResponse
+	Limit   int `json:"limit"`
+	Current int `json:"current"`
+}
+
+func newInstanceAttachmentTracker() *instanceAttachmentTracker {
+	return &instanceAttachmentTracker{
+		now:     time.Now,
+		locks:   map[string]*attachmentLock{},
+		pending: map[string]map[int64]time.Time{},
+	}
+}
+
+func instanceAttachmentKey(tenant, workspace, instance string) string {
+	return strings.ToLower(strings.TrimSpace(tenant)) + "/" +
+		strings.ToLower(strings.TrimSpace(workspace)) + "/" +
+		strings.ToLower(strings.TrimSpace(instance))
+}
+
+// lock acquires the per-instance attach lock and returns its release func.
+// The last release removes the lock, along with pending entries that have
+// already expired.
+func (t *instanceAttachmentTracker) lock(key string) func() {
+	t.mu.Lock()
+	l, ok := t.locks[key]
+	if !ok {
+		l = &attachmentLock{}
+		t.locks[key] = l
+	}
+	l.users++
+	t.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		l.users--
+		if l.users == 0 {
+			delete(t.locks, key)
+		}
+		t.prunePending(key)
+	}
+}
+
+// prunePending drops expired pending attaches of key, and key itself once
+// it has none left. t.mu must be held.
+func (t *instanceAttachmentTracker) prunePending(key string) {
+	now := t.now()
+	for id, acceptedAt := range t.pending[key] {
+		if now.Sub(acceptedAt) > pendingAttachmentTTL {
+			delete(t.pending[key], id)
+		}
+	}
+	if len(t.pending[key]) == 0 {
+		delete(t.pending, key)
+	}
+}
+
+// count returns the attached volumes plus accepted attaches the provider does
+// not report yet. Pending entries that show up as attached, or that outlived
+// pendingAttachmentTTL, are dropped.
+func (t *instanceAttachmentTracker) count(key string, attached []int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen := make(map[int64]struct{}, len(attached))
+	for _, id := range attached {
+		seen[id] = struct{}{}
+	}
+	total := len(seen)
+	now := t.now()
+	for id, acceptedAt := range t.pending[key] {
+		if _, ok := seen[id]; ok || now.Sub(acceptedAt) > pendingAttachmentTTL {
+			delete(t.pending[key], id)
+			continue
+		}
+		total++
+	}
+	return total
+}
+
+func (t *instanceAttachmentTracker) addPending(key string, volumeID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[key] == nil {
+		t.pending[key] = map[int64]time.Time{}
+	}
+	t.pending[key][volumeID] = t.now()
+}
+
+func (t *instanceAttachmentTracker) forgetVolume(volumeID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, pending := range t.pending {
+		delete(pending, volumeID)
+		if len(pending) == 0 {
+			delete(t.pending, key)
+		}
+	}
+}
+
+func respondAttachmentLimitExceeded(w http.ResponseWriter, instanceName string, current int, instance string) {
+	respondProblemJSON(w, http.StatusConflict, attachmentLimitProblem{
+		problemResponse: problemResponse{
+			Type:     "http://secapi.cloud/errors/attachment-limit-exceeded",
+			Title:    "Conflict",
+			Status:   http.StatusConflict,
+			Detail:   fmt.Sprintf("instance %q already has %d of %d block storages attached", instanceName, current, hetzner.MaxVolumesPerServer),
+			Instance: instance,
+			Sources:  []problemSource{},
+		},
+		Limit:   hetzner.MaxVolumesPerServer,
+		Current: current,
+	})
+}
+
+type limitsResponse struct {
+	BlockStorageAttachmentsPerInstance int `json:"blockStorageAttachmentsPerInstance"`
+}
+
+func limits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, limitsResponse{
+			BlockStorageAttachmentsPerInstance: hetzner.MaxVolumesPerServer,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstanceAttachmentTrackerCountsPendingAttaches(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newInstanceAttachmentTracker()
+	tracker.now = func() time.Time { return now }
+	key := instanceAttachmentKey("t1", "ws1", "vm1")
+
+	tracker.addPending(key, 3)
+	if got := tracker.count(key, []int64{1, 2}); got != 3 {
+		t.Fatalf("expected pending attach to be counted, got %d", got)
+	}
+	if got := tracker.count(key, []int64{1, 2, 3}); got != 3 {
+		t.Fatalf("expected finished attach to be counted once, got %d", got)
+	}
+
+	tracker.addPending(key, 4)
+	now = now.Add(pendingAttachmentTTL + time.Second)
+	if got := tracker.count(key, []int64{1, 2, 3}); got != 3 {
+		t.Fatalf("expected stale pending attach to expire, got %d", got)
+	}
+}
+
+func TestInstanceAttachmentTrackerDropsReleasedLocks(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newInstanceAttachmentTracker()
+	tracker.now = func() time.Time { return now }
+	key := instanceAttachmentKey("t1", "ws1", "vm1")
+
+	unlock := tracker.lock(key)
+	tracker.addPending(key, 3)
+	unlock()
+	if len(tracker.locks) != 0 {
+		t.Fatalf("expected the released lock to be dropped, got %v", tracker.locks)
+	}
+	if got := tracker.count(key, nil); got != 1 {
+		t.Fatalf("expected the pending attach to survive the release, got %d", got)
+	}
+
+	now = now.Add(pendingAttachmentTTL + time.Second)
+	tracker.lock(key)()
+	if len(tracker.locks) != 0 || len(tracker.pending) != 0 {
+		t.Fatalf("expected expired state to be dropped, got locks %v pending %v", tracker.locks, tracker.pending)
+	}
+}
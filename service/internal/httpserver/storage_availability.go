@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+)
+
+type volumeAvailabilityIterator struct {
+	Items    []volumeAvailability `json:"items"`
+	Metadata responseMetaObject   `json:"metadata"`
+}
+
+type volumeAvailability struct {
+	Region      string     `json:"region"`
+	NetworkZone string     `json:"networkZone,omitempty"`
+	Available   bool       `json:"available"`
+	ExhaustedAt *time.Time `json:"exhaustedAt,omitempty"`
+}
+
+// getVolumeAvailability lists per region whether hcloud-volume block storage
+// can currently be created there.
+func getVolumeAvailability(catalogProvider CatalogProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		capacities, err := catalogProvider.VolumeCapacityByLocation(catalogRequestContext(r))
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		items := make([]volumeAvailability, 0, len(capacities))
+		for _, capacity := range capacities {
+			item := volumeAvailability{Region: capacity.Region, NetworkZone: capacity.NetworkZone, Available: capacity.Available}
+			if !capacity.ExhaustedAt.IsZero() {
+				exhaustedAt := capacity.ExhaustedAt.UTC()
+				item.ExhaustedAt = &exhaustedAt
+			}
+			items = append(items, item)
+		}
+		respondJSON(w, http.StatusOK, volumeAvailabilityIterator{
+			Items:    items,
+			Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/skus/hcloud-volume/availability", Verb: verbList},
+		})
+	}
+}
@@ -1,12 +1,13 @@
 package httpserver
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
-	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 type blockStorageIterator struct {
@@ -16,6 +17,7 @@ type blockStorageIterator struct {
 
 type blockStorageResource struct {
 	Metadata resourceMetadata   `json:"metadata"`
+	Labels   map[string]string  `json:"labels,omitempty"`
 	Spec     blockStorageSpec   `json:"spec"`
 	Status   blockStorageStatus `json:"status"`
 }
@@ -28,7 +30,10 @@ type blockStorageSpec struct {
 type blockStorageStatus struct {
 	State      string     `json:"state"`
 	AttachedTo *refObject `json:"attachedTo,omitempty"`
+	DevicePath *string    `json:"devicePath,omitempty"`
 	SizeGB     int        `json:"sizeGB"`
+	// Conditions carry the error of the last Hetzner action when it failed.
+	Conditions []statusCondition `json:"conditions,omitempty"`
 }
 
 type blockStorageUpsertRequest struct {
@@ -46,15 +51,26 @@ type blockStorageUpsertRequest struct {
 
 type attachBlockStorageRequest struct {
 	InstanceRef refObject `json:"instanceRef"`
+	// Automount asks Hetzner to mount the volume's filesystem on the
+	// instance as well.
+	Automount bool `json:"automount,omitempty"`
+}
+
+// attachBlockStorageResponse tells the caller where the attached volume shows
+// up on the instance, so cloud-init or the user can mount it by that path.
+type attachBlockStorageResponse struct {
+	Status     string     `json:"status"`
+	AttachedTo *refObject `json:"attachedTo,omitempty"`
+	DevicePath string     `json:"devicePath,omitempty"`
 }
 
 func listBlockStorages(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
+		tenant, workspace, ok := scopeFromPath(w, r)
+		if !ok {
 			return
 		}
-		tenant, workspace, ok := scopeFromPath(w, r)
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
 		if !ok {
 			return
 		}
@@ -67,56 +83,64 @@ func listBlockStorages(provider ComputeStorageProvider, store *state.Store) http
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		items := make([]blockStorageResource, 0, len(volumes))
+		boundNames := boundNamesByID(ctx, store, tenant, workspace, "block-storage", hetzner.ResourceVolume)
+		scoped := make([]hetzner.BlockStorage, 0, len(volumes))
+		bindings := make([]state.ResourceBinding, 0, len(volumes))
 		for _, volume := range volumes {
-			spec, ok := runtimeResourceState.getBlockStorageSpec(blockStorageRef(tenant, workspace, volume.Name))
-			if ok {
-				items = append(items, toBlockStorageResource(tenant, workspace, volume, http.MethodGet, "active", &spec))
-			} else {
-				items = append(items, toBlockStorageResource(tenant, workspace, volume, http.MethodGet, "active", nil))
+			if !providerLabelsInScope(volume.Labels, tenant, workspace) {
+				continue
+			}
+			name, ok := boundNames.listed(volume.ID, volume.Name)
+			if !ok {
+				continue
 			}
-			_ = store.UpsertResourceBinding(ctx, state.ResourceBinding{
-				Tenant:      tenant,
-				Workspace:   workspace,
-				Kind:        "block-storage",
+			volume.Name = name
+			scoped = append(scoped, volume)
+			bindings = append(bindings, state.ResourceBinding{
 				SecaRef:     blockStorageRef(tenant, workspace, volume.Name),
 				ProviderRef: volumeProviderRef(volume.ID, volume.Name),
 				Status:      "active",
 			})
 		}
+		stored, err := store.SyncResourceBindings(ctx, tenant, workspace, "block-storage", bindings)
+		if err != nil {
+			tracing.Logf(ctx, "list block storages of %s/%s: %v", tenant, workspace, err)
+		}
+		items := make([]blockStorageResource, 0, len(scoped))
+		for _, volume := range scoped {
+			binding := storedBinding(stored, blockStorageRef(tenant, workspace, volume.Name))
+			var specOverride *blockStorageSpec
+			if spec, ok := runtimeResourceState.getBlockStorageSpec(blockStorageRef(tenant, workspace, volume.Name)); ok {
+				specOverride = &spec
+			}
+			stateValue, conditions := failedActionState(ctx, store, binding, blockStorageStateValue(tenant, workspace, volume))
+			resource := toBlockStorageResource(tenant, workspace, volume, verbList, stateValue, specOverride, systemLabels)
+			resource.Status.Conditions = conditions
+			stampLastModified(&resource.Metadata, binding)
+			items = append(items, resource)
+		}
 		respondJSON(w, http.StatusOK, blockStorageIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/block-storages", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/workspaces/" + workspace + "/block-storages", Verb: verbList},
 		})
 	}
 }
 
-func blockStorageCRUD(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getBlockStorage(provider, store)(w, r)
-		case http.MethodPut:
-			putBlockStorage(provider, store)(w, r)
-		case http.MethodDelete:
-			deleteBlockStorage(provider, store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getBlockStorage(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "block storage name is required")
 		if !ok {
 			return
 		}
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
-		volume, err := provider.GetBlockStorage(ctx, name)
+		volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -125,56 +149,108 @@ func getBlockStorage(provider ComputeStorageProvider, store *state.Store) http.H
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		binding, err := store.SyncResourceBinding(ctx, state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        "block-storage",
 			SecaRef:     blockStorageRef(tenant, workspace, name),
 			ProviderRef: volumeProviderRef(volume.ID, volume.Name),
 			Status:      "active",
-		}); err != nil {
+		})
+		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		spec, ok := runtimeResourceState.getBlockStorageSpec(blockStorageRef(tenant, workspace, name))
-		if ok {
-			respondJSON(w, http.StatusOK, toBlockStorageResource(tenant, workspace, *volume, http.MethodGet, "active", &spec))
-			return
+		var specOverride *blockStorageSpec
+		if spec, ok := runtimeResourceState.getBlockStorageSpec(blockStorageRef(tenant, workspace, name)); ok {
+			specOverride = &spec
 		}
-		respondJSON(w, http.StatusOK, toBlockStorageResource(tenant, workspace, *volume, http.MethodGet, "active", nil))
+		stateValue, conditions := failedActionState(ctx, store, binding, blockStorageStateValue(tenant, workspace, *volume))
+		resource := toBlockStorageResource(tenant, workspace, *volume, verbGet, stateValue, specOverride, systemLabels)
+		resource.Status.Conditions = conditions
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, http.StatusOK, resource)
 	}
 }
 
-func putBlockStorage(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+// putBlockStorage creates the volume or grows an existing one to spec.sizeGB.
+// Hetzner cannot shrink volumes, so a smaller size is rejected.
+func putBlockStorage(provider ComputeStorageProvider, store *state.Store, maxSizeGB int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "block storage name is required")
 		if !ok {
 			return
 		}
+		systemLabels, ok := includeSystemLabelsFromQuery(w, r)
+		if !ok {
+			return
+		}
+		dryRun, ok := dryRunFromQuery(w, r)
+		if !ok {
+			return
+		}
 		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
 		if !ok {
 			return
 		}
 		var reqBody blockStorageUpsertRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &reqBody) {
 			return
 		}
 		requestedSizeGB := reqBody.Spec.SizeGB
+		var problems []fieldProblem
 		if requestedSizeGB <= 0 {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.sizeGB must be > 0", r.URL.Path)
-			return
+			problems = append(problems, fieldPointer("/spec/sizeGB", "spec.sizeGB must be > 0"))
 		}
 		if reqBody.Spec.SkuRef == nil || reqBody.Spec.SkuRef.Resource == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.skuRef.resource is required", r.URL.Path)
+			problems = append(problems, fieldPointer("/spec/skuRef/resource", "spec.skuRef.resource is required"))
+		}
+		problems = append(problems, reservedLabelProblems(reqBody.Labels)...)
+		if len(problems) > 0 {
+			respondValidationProblem(w, r.URL.Path, problems...)
+			return
+		}
+		if reqBody.Spec.SourceImageRef != nil {
+			respondSourceImageNotImplemented(w, r.URL.Path)
+			return
+		}
+		if _, ok := resourceRegion(w, r, store, tenant, workspace, reqBody.Metadata.Region); !ok {
+			return
+		}
+		existing, err := provider.GetBlockStorage(ctx, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if existing != nil && !providerLabelsInScope(existing.Labels, tenant, workspace) {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", "block storage name is already in use outside this workspace", r.URL.Path)
+			return
+		}
+		skuName := compactLabelValue(resourceNameFromRef(reqBody.Spec.SkuRef.Resource))
+		if currentSKU := currentBlockStorageSKU(blockStorageRef(tenant, workspace, name), existing); currentSKU != "" {
+			violation, err := findImmutableFieldViolation("block-storage",
+				blockStorageSpec{SkuRef: refObject{Resource: "skus/" + currentSKU}},
+				blockStorageSpec{SkuRef: refObject{Resource: "skus/" + skuName}},
+			)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if violation != nil {
+				respondImmutableFieldViolation(w, *violation, r.URL.Path)
+				return
+			}
+		}
+		providerSizeGB := normalizeProviderBlockStorageSizeGB(requestedSizeGB, maxSizeGB)
+		if existing != nil && requestedSizeGB < existing.SizeGB && providerSizeGB < existing.SizeGB {
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/sizeGB", "spec.sizeGB cannot shrink block storage from %d GB to %d GB", existing.SizeGB, requestedSizeGB))
 			return
 		}
-		providerSizeGB := normalizeProviderBlockStorageSizeGB(requestedSizeGB)
 		attachTo := ""
 		if reqBody.Spec.AttachedTo != nil {
 			attachTo = resourceNameFromRef(reqBody.Spec.AttachedTo.Resource)
 		}
-		volume, created, actionID, err := provider.CreateOrUpdateBlockStorage(ctx, hetzner.BlockStorageCreateRequest{
+		createReq := hetzner.BlockStorageCreateRequest{
 			Name:     name,
 			SizeGB:   providerSizeGB,
 			Region:   reqBody.Metadata.Region,
@@ -187,25 +263,41 @@ func putBlockStorage(provider ComputeStorageProvider, store *state.Store) http.H
 				name,
 				blockStorageRef(tenant, workspace, name),
 			),
-		})
+		}
+		createReq.Labels[secaLabelSKU] = skuName
+		if dryRun {
+			volume, created, err := provider.ValidateBlockStorageCreate(ctx, createReq)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			spec := blockStorageSpec{
+				SizeGB: requestedSizeGB,
+				SkuRef: *reqBody.Spec.SkuRef,
+			}
+			respondJSON(w, http.StatusOK, toBlockStorageResource(tenant, workspace, *volume, upsertVerb(created), dryRunState, &spec, systemLabels))
+			return
+		}
+		volume, created, actionID, err := provider.CreateOrUpdateBlockStorage(ctx, createReq)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		if err := store.UpsertResourceBinding(ctx, state.ResourceBinding{
+		binding, err := store.UpsertResourceBindingIfVersion(ctx, state.ResourceBinding{
 			Tenant:      tenant,
 			Workspace:   workspace,
 			Kind:        "block-storage",
 			SecaRef:     blockStorageRef(tenant, workspace, name),
 			ProviderRef: volumeProviderRef(volume.ID, volume.Name),
 			Status:      "active",
-		}); err != nil {
+		}, 0)
+		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if actionID != "" {
 			if err := store.CreateOperation(ctx, state.OperationRecord{
-				OperationID:      operationID("block-storage-upsert", name),
+				OperationID:      operationID(blockStorageUpsertOperation(created), name),
 				SecaRef:          blockStorageRef(tenant, workspace, name),
 				ProviderActionID: actionID,
 				Phase:            "accepted",
@@ -225,10 +317,28 @@ func putBlockStorage(provider ComputeStorageProvider, store *state.Store) http.H
 			SkuRef: *reqBody.Spec.SkuRef,
 		}
 		runtimeResourceState.setBlockStorageSpec(blockStorageRef(tenant, workspace, name), spec)
-		respondJSON(w, code, toBlockStorageResource(tenant, workspace, *volume, http.MethodPut, stateValue, &spec))
+		resource := toBlockStorageResource(tenant, workspace, *volume, upsertVerb(created), stateValue, &spec, systemLabels)
+		stampLastModified(&resource.Metadata, binding)
+		respondJSON(w, code, resource)
 	}
 }
 
+// currentBlockStorageSKU is the SKU name volume was created with, compacted
+// like a label value. The seca.sku label keeps it across restarts; volumes
+// created before the label existed only have the spec cached by this process.
+func currentBlockStorageSKU(ref string, volume *hetzner.BlockStorage) string {
+	if volume == nil {
+		return ""
+	}
+	if sku := volume.Labels[secaLabelSKU]; sku != "" {
+		return sku
+	}
+	if spec, ok := runtimeResourceState.getBlockStorageSpec(ref); ok {
+		return compactLabelValue(resourceNameFromRef(spec.SkuRef.Resource))
+	}
+	return ""
+}
+
 func deleteBlockStorage(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "block storage name is required")
@@ -239,27 +349,38 @@ func deleteBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 		if !ok {
 			return
 		}
-		deleted, err := provider.DeleteBlockStorage(ctx, name)
+		volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		ref := blockStorageRef(tenant, workspace, name)
+		if volume == nil {
+			respondDeleteNotFound(w, r, ref, "block storage not found")
+			return
+		}
+		force, ok := forceFromQuery(w, r)
+		if !ok {
+			return
+		}
+		deleted, err := provider.DeleteBlockStorage(ctx, name, force)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
 		if !deleted {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "block storage not found")
 			return
 		}
-		_ = store.DeleteResourceBinding(ctx, blockStorageRef(tenant, workspace, name))
-		runtimeResourceState.deleteBlockStorageSpec(blockStorageRef(tenant, workspace, name))
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		instanceAttachments.forgetVolume(volume.ID)
+		_ = store.DeleteResourceBinding(ctx, ref)
+		runtimeResourceState.deleteBlockStorageSpec(ref)
+		respondDeleteAccepted(w, "")
 	}
 }
 
 func attachBlockStorage(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only POST is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "block storage name is required")
 		if !ok {
 			return
@@ -269,16 +390,58 @@ func attachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 			return
 		}
 		var reqBody attachBlockStorageRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &reqBody) {
 			return
 		}
 		instanceName := resourceNameFromRef(reqBody.InstanceRef.Resource)
 		if instanceName == "" {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "instanceRef.resource is required", r.URL.Path)
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/instanceRef/resource", "instanceRef.resource is required"))
 			return
 		}
-		found, actionID, err := provider.AttachBlockStorage(ctx, name, instanceName)
+		volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if volume == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
+			return
+		}
+		instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, instanceName)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if instance == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			return
+		}
+		attachmentKey := instanceAttachmentKey(tenant, workspace, instanceName)
+		unlock := instanceAttachments.lock(attachmentKey)
+		defer unlock()
+		// Re-read under the lock so the count reflects attaches that finished
+		// while this request was waiting.
+		instance, err = getWorkspaceInstance(ctx, provider, tenant, workspace, instanceName)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if instance == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+			return
+		}
+		alreadyAttached := false
+		for _, id := range instance.VolumeIDs {
+			if id == volume.ID {
+				alreadyAttached = true
+				break
+			}
+		}
+		if current := instanceAttachments.count(attachmentKey, instance.VolumeIDs); !alreadyAttached && current >= hetzner.MaxVolumesPerServer {
+			respondAttachmentLimitExceeded(w, instanceName, current, r.URL.Path)
+			return
+		}
+		found, actionID, err := provider.AttachBlockStorage(ctx, name, instanceName, reqBody.Automount)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
 			return
@@ -287,6 +450,9 @@ func attachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
 			return
 		}
+		if !alreadyAttached {
+			instanceAttachments.addPending(attachmentKey, volume.ID)
+		}
 		if err := store.CreateOperation(ctx, state.OperationRecord{
 			OperationID:      operationID("block-storage-attach", name),
 			SecaRef:          blockStorageRef(tenant, workspace, name),
@@ -296,16 +462,23 @@ func attachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 			respondFromError(w, err, r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		// The provider waited for the attach, so the volume now reports its
+		// device path on the instance.
+		attached, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		response := attachBlockStorageResponse{Status: "accepted", AttachedTo: &refObject{Resource: "instances/" + instanceName}}
+		if attached != nil {
+			response.DevicePath = attached.DevicePath
+		}
+		respondJSON(w, http.StatusAccepted, response)
 	}
 }
 
 func detachBlockStorage(provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only POST is supported", r.URL.Path)
-			return
-		}
 		tenant, workspace, name, ok := scopedNameFromPath(w, r, "block storage name is required")
 		if !ok {
 			return
@@ -314,6 +487,15 @@ func detachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 		if !ok {
 			return
 		}
+		volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, name)
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if volume == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
+			return
+		}
 		found, actionID, err := provider.DetachBlockStorage(ctx, name)
 		if err != nil {
 			respondFromError(w, err, r.URL.Path)
@@ -323,6 +505,7 @@ func detachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path)
 			return
 		}
+		instanceAttachments.forgetVolume(volume.ID)
 		if err := store.CreateOperation(ctx, state.OperationRecord{
 			OperationID:      operationID("block-storage-detach", name),
 			SecaRef:          blockStorageRef(tenant, workspace, name),
@@ -336,11 +519,54 @@ func detachBlockStorage(provider ComputeStorageProvider, store *state.Store) htt
 	}
 }
 
-func toBlockStorageResource(tenant, workspace string, volume hetzner.BlockStorage, verb, state string, specOverride *blockStorageSpec) blockStorageResource {
-	now := time.Now().UTC().Format(time.RFC3339)
+// getWorkspaceBlockStorage is the volume counterpart of getWorkspaceInstance.
+func getWorkspaceBlockStorage(ctx context.Context, provider ComputeStorageProvider, tenant, workspace, name string) (*hetzner.BlockStorage, error) {
+	volume, err := provider.GetBlockStorage(ctx, name)
+	if err != nil || volume == nil {
+		return nil, err
+	}
+	if !providerLabelsInScope(volume.Labels, tenant, workspace) {
+		return nil, nil
+	}
+	return volume, nil
+}
+
+func blockStorageUpsertOperation(created bool) string {
+	if created {
+		return "block-storage-upsert"
+	}
+	return "block-storage-resize"
+}
+
+// respondSourceImageNotImplemented answers 501 for a block storage with
+// spec.sourceImageRef. Hetzner creates volumes empty, and creating one that
+// silently lacks the image would look like success.
+func respondSourceImageNotImplemented(w http.ResponseWriter, instance string) {
+	respondProblemWithSources(w, http.StatusNotImplemented, "http://secapi.cloud/errors/not-implemented", "Not Implemented",
+		"block storages cannot be created from an image: Hetzner volumes are always created empty. Create an instance with spec.imageRef instead, or create an empty block storage and copy the data from an instance",
+		instance, []problemSource{{Pointer: "/spec/sourceImageRef"}})
+}
+
+// blockStorageStateValue reports a volume Hetzner is still creating as
+// creating and one with an accepted delete as deleting.
+func blockStorageStateValue(tenant, workspace string, volume hetzner.BlockStorage) string {
+	ref := blockStorageRef(tenant, workspace, volume.Name)
+	if _, deleting := pendingDeletes.inProgress(ref, volumeProviderRef(volume.ID, volume.Name)); deleting {
+		return "deleting"
+	}
+	if hcloud.VolumeStatus(volume.Status) == hcloud.VolumeStatusCreating {
+		return "creating"
+	}
+	return "active"
+}
+
+func toBlockStorageResource(tenant, workspace string, volume hetzner.BlockStorage, verb resourceVerb, state string, specOverride *blockStorageSpec, systemLabels bool) blockStorageResource {
+	createdAt := providerTimestamp(volume.CreatedAt)
 	var attachedTo *refObject
+	var devicePath *string
 	if volume.AttachedTo != "" {
 		attachedTo = &refObject{Resource: "instances/" + volume.AttachedTo}
+		devicePath = stringPtrOrNil(volume.DevicePath)
 	}
 	spec := blockStorageSpec{
 		SizeGB: volume.SizeGB,
@@ -355,8 +581,8 @@ func toBlockStorageResource(tenant, workspace string, volume hetzner.BlockStorag
 			Provider:        "seca.storage/v1",
 			Resource:        "tenants/" + tenant + "/workspaces/" + workspace + "/block-storages/" + volume.Name,
 			Verb:            verb,
-			CreatedAt:       now,
-			LastModifiedAt:  now,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  createdAt,
 			ResourceVersion: 1,
 			APIVersion:      "v1",
 			Kind:            "block-storage",
@@ -365,10 +591,12 @@ func toBlockStorageResource(tenant, workspace string, volume hetzner.BlockStorag
 			Workspace:       workspace,
 			Region:          defaultRegion(volume.Region),
 		},
-		Spec: spec,
+		Labels: responseLabels(volume.Labels, systemLabels),
+		Spec:   spec,
 		Status: blockStorageStatus{
 			State:      state,
 			AttachedTo: attachedTo,
+			DevicePath: devicePath,
 			SizeGB:     volume.SizeGB,
 		},
 	}
@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeProviderBlockStorageSizeGB(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		size, max, want int
+	}{
+		{size: 1, max: 10240, want: 10},
+		{size: 50, max: 10240, want: 50},
+		{size: 500, max: 10240, want: 500},
+		{size: 500, max: 100, want: 100},
+		{size: 20000, max: 0, want: 20000},
+	}
+	for _, tc := range cases {
+		if got := normalizeProviderBlockStorageSizeGB(tc.size, tc.max); got != tc.want {
+			t.Fatalf("size %d max %d: expected %d, got %d", tc.size, tc.max, tc.want, got)
+		}
+	}
+}
+
+func TestRespondSourceImageNotImplementedPointsAtSourceImageRef(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	respondSourceImageNotImplemented(rec, "/storage/v1/tenants/t1/workspaces/ws1/block-storages/vol1")
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", rec.Code)
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(problem.Sources) != 1 || problem.Sources[0].Pointer != "/spec/sourceImageRef" || !strings.Contains(problem.Detail, "spec.imageRef") {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+}
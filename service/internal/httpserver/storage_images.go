@@ -0,0 +1,712 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const resourceBindingKindImage = "image"
+
+// imageBindingPayload is what a tenant image binding records. The binding
+// lives in the workspace whose Hetzner project holds the snapshot.
+type imageBindingPayload struct {
+	Name       string            `json:"name"`
+	Region     string            `json:"region"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Spec       imageSpec         `json:"spec"`
+	SnapshotID int64             `json:"snapshotId"`
+	// Stub marks an image recorded under the imageStub conformance flag,
+	// which has no snapshot behind it.
+	Stub bool `json:"stub,omitempty"`
+}
+
+func listImages(catalogProvider CatalogProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondValidationProblem(w, r.URL.Path, fieldParameter("tenant", "tenant is required"))
+			return
+		}
+		filter, problem := parseImageFilter(r)
+		if problem != nil {
+			respondValidationProblem(w, r.URL.Path, *problem)
+			return
+		}
+		images, err := catalogProvider.ListCatalogImages(catalogRequestContext(r))
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		items := make([]imageResource, 0, len(images)+8)
+		tenantImages := map[string]struct{}{}
+		if store == nil {
+			for _, rec := range runtimeResourceState.listImagesByTenant(tenant) {
+				tenantImages[rec.Name] = struct{}{}
+				if filter.matches(rec.Spec.CPUArchitecture, rec.Labels) {
+					items = append(items, toRuntimeImageResource(rec, verbList, "active"))
+				}
+			}
+		} else {
+			bindings, err := store.ListTenantResourceBindings(r.Context(), tenant, resourceBindingKindImage)
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list images", r.URL.Path)
+				return
+			}
+			for _, binding := range bindings {
+				payload, err := parseImageBinding(binding.ProviderRef)
+				if err != nil {
+					continue
+				}
+				tenantImages[payload.Name] = struct{}{}
+				if filter.matches(payload.Spec.CPUArchitecture, payload.Labels) {
+					items = append(items, toImageResourceFromBinding(binding, payload, tenant, verbList, binding.Status))
+				}
+			}
+		}
+		for _, img := range images {
+			if _, exists := tenantImages[img.Name]; exists {
+				continue
+			}
+			if filter.matches(normalizeArchitecture(img.Architecture), img.Labels) {
+				items = append(items, toCatalogImageResource(tenant, img, verbList))
+			}
+		}
+		respondJSON(w, http.StatusOK, imageIterator{Items: items, Metadata: responseMetaObject{Provider: "seca.storage/v1", Resource: "tenants/" + tenant + "/images", Verb: verbList}})
+	}
+}
+
+// imageWriteHandlers returns the PUT and DELETE handlers for images. Catalog
+// images are read-only and tenant images are Hetzner snapshots; the imageStub
+// conformance flag keeps tenant images in memory instead, since the suite
+// uploads images without a server to snapshot. imageStub is the default for
+// requests that carry no conformance flags.
+func imageWriteHandlers(catalogProvider CatalogProvider, provider ComputeStorageProvider, store *state.Store, imageStub bool) (put, remove http.HandlerFunc) {
+	defaults := config.ConformanceFlags{ImageStub: imageStub}
+	stubPut, stubDelete := putRuntimeImage(store), deleteRuntimeImage(store)
+	snapshotPut, snapshotDelete := putImage(catalogProvider, provider, store), deleteImage(catalogProvider, provider, store)
+	put = func(w http.ResponseWriter, r *http.Request) {
+		if conformanceFlags(r, defaults).ImageStub {
+			stubPut(w, r)
+			return
+		}
+		snapshotPut(w, r)
+	}
+	remove = func(w http.ResponseWriter, r *http.Request) {
+		if conformanceFlags(r, defaults).ImageStub {
+			stubDelete(w, r)
+			return
+		}
+		snapshotDelete(w, r)
+	}
+	return put, remove
+}
+
+func getImage(catalogProvider CatalogProvider, provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(r.PathValue("name"))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			return
+		}
+		filter, problem := parseImageFilter(r)
+		if problem != nil {
+			respondValidationProblem(w, r.URL.Path, *problem)
+			return
+		}
+		if store == nil {
+			if rec, ok := runtimeResourceState.getImage(imageRef(tenant, name)); ok {
+				respondJSON(w, http.StatusOK, toRuntimeImageResource(rec, verbGet, "active"))
+				return
+			}
+		} else {
+			binding, err := store.GetResourceBinding(r.Context(), tenantImageRef(tenant, name))
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+				return
+			}
+			if binding != nil {
+				getTenantImage(w, r, provider, store, tenant, name, *binding)
+				return
+			}
+		}
+		img, err := catalogProvider.GetCatalogImage(catalogRequestContext(r), name, hetznerArchitecture(filter.architecture))
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if img == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "image not found", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toCatalogImageResource(tenant, *img, verbGet))
+	}
+}
+
+// imageFilter narrows the images list to one architecture and a label
+// selector. The zero value matches every image.
+type imageFilter struct {
+	architecture string
+	labels       labelSelector
+}
+
+func parseImageFilter(r *http.Request) (imageFilter, *fieldProblem) {
+	var filter imageFilter
+	query := r.URL.Query()
+	if raw := query.Get("cpuArchitecture"); raw != "" {
+		normalized, ok := canonicalArchitecture(raw)
+		if !ok {
+			problem := fieldParameter("cpuArchitecture", "cpuArchitecture must be amd64 or arm64")
+			return filter, &problem
+		}
+		filter.architecture = normalized
+	}
+	selector, err := parseLabelSelector(query.Get("labels"))
+	if err != nil {
+		problem := fieldParameter("labels", "%v", err)
+		return filter, &problem
+	}
+	filter.labels = selector
+	return filter, nil
+}
+
+// matches reports whether an image of architecture (amd64 or arm64) with
+// labels passes the filter.
+func (f imageFilter) matches(architecture string, labels map[string]string) bool {
+	if f.architecture != "" && normalizeArchitecture(architecture) != f.architecture {
+		return false
+	}
+	return f.labels.matches(labels)
+}
+
+// toCatalogImageResource renders a read-only Hetzner catalog image, with the
+// OS flavor and version Hetzner reports so clients can pick an image without
+// parsing its name.
+func toCatalogImageResource(tenant string, img hetzner.CatalogImage, verb resourceVerb) imageResource {
+	return imageResource{
+		Metadata: resourceMetadata{
+			Name:            img.Name,
+			Provider:        "seca.storage/v1",
+			Resource:        "tenants/" + tenant + "/images/" + img.Name,
+			Verb:            verb,
+			CreatedAt:       providerTimestamp(img.CreatedAt),
+			LastModifiedAt:  providerTimestamp(img.CreatedAt),
+			ResourceVersion: 1,
+			APIVersion:      "v1",
+			Kind:            "image",
+			Ref:             "seca.storage/v1/tenants/" + tenant + "/images/" + img.Name,
+			Tenant:          tenant,
+			Region:          "global",
+		},
+		Labels: img.Labels,
+		Spec: imageSpec{
+			BlockStorageRef: refObject{Resource: "block-storages/" + img.Name},
+			CPUArchitecture: normalizeArchitecture(img.Architecture),
+			OSFlavor:        img.OSFlavor,
+			OSVersion:       img.OSVersion,
+		},
+		Status: imageStatus{State: "active"},
+	}
+}
+
+// getTenantImage looks the snapshot up by its SECA labels and records the
+// state it reports, so listings follow once the snapshot action completes.
+func getTenantImage(w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, store *state.Store, tenant, name string, binding state.ResourceBinding) {
+	payload, err := parseImageBinding(binding.ProviderRef)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid image payload", r.URL.Path)
+		return
+	}
+	if payload.Stub {
+		respondJSON(w, http.StatusOK, toImageResourceFromBinding(binding, payload, tenant, verbGet, binding.Status))
+		return
+	}
+	ctx, ok := workspaceExecutionContext(w, r, store, tenant, binding.Workspace)
+	if !ok {
+		return
+	}
+	snapshot, err := provider.FindImageSnapshot(ctx, tenantImageSelector(tenant, name))
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return
+	}
+	if snapshot == nil {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "image snapshot not found", r.URL.Path)
+		return
+	}
+	stateValue := imageSnapshotState(*snapshot)
+	if stateValue != binding.Status {
+		binding.Status = stateValue
+		if updated, err := store.UpsertResourceBindingIfVersion(r.Context(), binding, binding.ResourceVersion); err == nil {
+			binding = *updated
+		}
+	}
+	respondJSON(w, http.StatusOK, toImageResourceFromBinding(binding, payload, tenant, verbGet, stateValue))
+}
+
+// putImage snapshots the server behind spec.blockStorageRef into a tenant
+// image. The snapshot is immutable, so updates only change labels.
+func putImage(catalogProvider CatalogProvider, provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(r.PathValue("name"))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			return
+		}
+		var req imageResource
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if strings.TrimSpace(req.Spec.BlockStorageRef.Resource) == "" {
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/blockStorageRef/resource", "spec.blockStorageRef is required"))
+			return
+		}
+		catalogImage, err := catalogProvider.GetCatalogImage(catalogRequestContext(r), name, "")
+		if err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return
+		}
+		if catalogImage != nil {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is a read-only catalog image", name), r.URL.Path)
+			return
+		}
+		ref := tenantImageRef(tenant, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+			return
+		}
+		if binding != nil {
+			updateTenantImage(w, r, store, tenant, req, *binding)
+			return
+		}
+
+		workspace, sourceKind, sourceName := imageSourceFromRef(req.Spec.BlockStorageRef.Resource, req.Metadata.Workspace)
+		if workspace == "" || sourceName == "" || (sourceKind != "block-storages" && sourceKind != "instances") {
+			respondProblemWithSources(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "spec.blockStorageRef must name a block storage or instance of a workspace", r.URL.Path, []problemSource{{Pointer: "/spec/blockStorageRef"}})
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, workspace)
+		if !ok {
+			return
+		}
+		snapshotReq := hetzner.ImageSnapshotCreateRequest{
+			Description: name,
+			Labels:      withSecaProviderLabels(req.Labels, tenant, workspace, resourceBindingKindImage, name, ref),
+		}
+		if sourceKind == "block-storages" {
+			volume, err := getWorkspaceBlockStorage(ctx, provider, tenant, workspace, sourceName)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if volume == nil {
+				respondProblemWithSources(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "block storage not found", r.URL.Path, []problemSource{{Pointer: "/spec/blockStorageRef"}})
+				return
+			}
+			snapshotReq.BlockStorageName = volume.Name
+		} else {
+			instance, err := getWorkspaceInstance(ctx, provider, tenant, workspace, sourceName)
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if instance == nil {
+				respondProblemWithSources(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path, []problemSource{{Pointer: "/spec/blockStorageRef"}})
+				return
+			}
+			snapshotReq.InstanceName = instance.Name
+		}
+		image, _, _, ok := createTenantImage(ctx, w, r, provider, store, tenant, workspace, name, req, snapshotReq)
+		if !ok {
+			return
+		}
+		respondJSON(w, http.StatusCreated, image)
+	}
+}
+
+// createTenantImage snapshots the server or volume named in snapshotReq,
+// records the image binding and its create operation, and returns the image
+// with the operation and provider action ids. It answers the request itself
+// on failure.
+func createTenantImage(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, store *state.Store, tenant, workspace, name string, req imageResource, snapshotReq hetzner.ImageSnapshotCreateRequest) (imageResource, string, string, bool) {
+	ref := tenantImageRef(tenant, name)
+	snapshot, actionID, err := provider.CreateImageSnapshot(ctx, snapshotReq)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return imageResource{}, "", "", false
+	}
+
+	payload := imageBindingPayload{
+		Name:       name,
+		Region:     defaultRegion(strings.TrimSpace(req.Metadata.Region)),
+		Labels:     req.Labels,
+		Spec:       imageSpec{BlockStorageRef: req.Spec.BlockStorageRef, CPUArchitecture: normalizeArchitecture(snapshot.Architecture)},
+		SnapshotID: snapshot.ID,
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode image", r.URL.Path)
+		return imageResource{}, "", "", false
+	}
+	stateValue := imageSnapshotState(*snapshot)
+	stored, err := store.UpsertResourceBindingIfVersion(r.Context(), state.ResourceBinding{
+		Tenant:      tenant,
+		Workspace:   workspace,
+		Kind:        resourceBindingKindImage,
+		SecaRef:     ref,
+		ProviderRef: string(raw),
+		Status:      stateValue,
+	}, 0)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to persist image", r.URL.Path)
+		return imageResource{}, "", "", false
+	}
+	operation := ""
+	if actionID != "" {
+		operation = operationID("image-create", name)
+		if err := store.CreateOperation(r.Context(), state.OperationRecord{
+			OperationID:      operation,
+			SecaRef:          ref,
+			ProviderActionID: actionID,
+			Phase:            "accepted",
+		}); err != nil {
+			respondFromError(w, err, r.URL.Path)
+			return imageResource{}, "", "", false
+		}
+	}
+	return toImageResourceFromBinding(*stored, payload, tenant, verbCreate, stateValue), operation, actionID, true
+}
+
+func updateTenantImage(w http.ResponseWriter, r *http.Request, store *state.Store, tenant string, req imageResource, binding state.ResourceBinding) {
+	payload, err := parseImageBinding(binding.ProviderRef)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid image payload", r.URL.Path)
+		return
+	}
+	violation, err := findImmutableFieldViolation(resourceBindingKindImage, payload.Spec, req.Spec)
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return
+	}
+	if violation != nil {
+		respondImmutableFieldViolation(w, *violation, r.URL.Path)
+		return
+	}
+	payload.Labels = req.Labels
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode image", r.URL.Path)
+		return
+	}
+	binding.ProviderRef = string(raw)
+	stored, err := store.UpsertResourceBindingIfVersion(r.Context(), binding, 0)
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to persist image", r.URL.Path)
+		return
+	}
+	respondJSON(w, http.StatusOK, toImageResourceFromBinding(*stored, payload, tenant, verbUpdate, stored.Status))
+}
+
+func deleteImage(catalogProvider CatalogProvider, provider ComputeStorageProvider, store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(r.PathValue("name"))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			return
+		}
+		ref := tenantImageRef(tenant, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			catalogImage, err := catalogProvider.GetCatalogImage(catalogRequestContext(r), name, "")
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			if catalogImage != nil {
+				respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is a read-only catalog image", name), r.URL.Path)
+				return
+			}
+			respondDeleteNotFound(w, r, ref, "image not found")
+			return
+		}
+		payload, err := parseImageBinding(binding.ProviderRef)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "invalid image payload", r.URL.Path)
+			return
+		}
+		ctx, ok := workspaceExecutionContext(w, r, store, tenant, binding.Workspace)
+		if !ok {
+			return
+		}
+		if payload.SnapshotID > 0 {
+			if _, err := provider.DeleteImageSnapshot(ctx, payload.SnapshotID); err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+		}
+		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete image", r.URL.Path)
+			return
+		}
+		respondDeleteAccepted(w, "")
+	}
+}
+
+// resolveTenantImageID returns the snapshot behind a tenant image for an
+// instance create, or 0 when imageName is not a tenant image. Snapshots are
+// project scoped, so the lookup runs with the instance workspace credential.
+func resolveTenantImageID(ctx context.Context, w http.ResponseWriter, r *http.Request, provider ComputeStorageProvider, store *state.Store, tenant, imageName string) (int64, bool) {
+	binding, err := store.GetResourceBinding(r.Context(), tenantImageRef(tenant, imageName))
+	if err != nil {
+		respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve image", r.URL.Path)
+		return 0, false
+	}
+	if binding == nil {
+		return 0, true
+	}
+	if payload, err := parseImageBinding(binding.ProviderRef); err == nil && payload.Stub {
+		return 0, true
+	}
+	snapshot, err := provider.FindImageSnapshot(ctx, tenantImageSelector(tenant, imageName))
+	if err != nil {
+		respondFromError(w, err, r.URL.Path)
+		return 0, false
+	}
+	if snapshot == nil {
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is not available in this workspace", imageName), r.URL.Path)
+		return 0, false
+	}
+	if imageSnapshotState(*snapshot) != "active" {
+		respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is still being created", imageName), r.URL.Path)
+		return 0, false
+	}
+	return snapshot.ID, true
+}
+
+// tenantImageSelector matches the system labels putImage puts on a snapshot.
+func tenantImageSelector(tenant, name string) map[string]string {
+	return map[string]string{
+		secaLabelManaged: "true",
+		secaLabelTenant:  compactLabelValue(tenant),
+		secaLabelKind:    resourceBindingKindImage,
+		secaLabelName:    compactLabelValue(name),
+	}
+}
+
+func imageSnapshotState(snapshot hetzner.ImageSnapshot) string {
+	if snapshot.Status == "available" {
+		return "active"
+	}
+	return "creating"
+}
+
+// imageSourceFromRef splits spec.blockStorageRef into the workspace and the
+// block storage or instance it names. Short references such as
+// "block-storages/<name>" take the workspace from metadata.workspace.
+func imageSourceFromRef(ref, workspace string) (string, string, string) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(ref), "/"), "/")
+	if len(parts) < 2 {
+		return "", "", ""
+	}
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "workspaces") {
+			workspace = parts[i+1]
+			break
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(workspace)), strings.ToLower(parts[len(parts)-2]), strings.ToLower(parts[len(parts)-1])
+}
+
+// putRuntimeImage records an image without snapshotting anything, for the
+// imageStub conformance flag. It is stored as an image binding, so it reads
+// back the same after a restart; only without a store does it live in memory.
+func putRuntimeImage(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(r.PathValue("name"))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			return
+		}
+		var req imageResource
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if strings.TrimSpace(req.Spec.BlockStorageRef.Resource) == "" {
+			respondValidationProblem(w, r.URL.Path, fieldPointer("/spec/blockStorageRef/resource", "spec.blockStorageRef is required"))
+			return
+		}
+		cpuArch := normalizeArchitecture(req.Spec.CPUArchitecture)
+		region := strings.TrimSpace(req.Metadata.Region)
+		if region == "" {
+			region = "global"
+		}
+		spec := imageSpec{BlockStorageRef: req.Spec.BlockStorageRef, CPUArchitecture: cpuArch}
+
+		if store == nil {
+			now := time.Now().UTC().Format(time.RFC3339)
+			rec, created := runtimeResourceState.upsertImage(imageRef(tenant, name), imageRuntimeRecord{
+				Tenant:         tenant,
+				Name:           name,
+				Region:         region,
+				Labels:         req.Labels,
+				Spec:           spec,
+				CreatedAt:      now,
+				LastModifiedAt: now,
+			})
+			stateValue, code := upsertStateAndCode(created)
+			respondJSON(w, code, toRuntimeImageResource(rec, upsertVerb(created), stateValue))
+			return
+		}
+
+		ref := tenantImageRef(tenant, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+			return
+		}
+		created := binding == nil
+		if created {
+			workspace, _, _ := imageSourceFromRef(req.Spec.BlockStorageRef.Resource, req.Metadata.Workspace)
+			binding = &state.ResourceBinding{Tenant: tenant, Workspace: workspace, Kind: resourceBindingKindImage, SecaRef: ref, Status: "active"}
+		} else if payload, err := parseImageBinding(binding.ProviderRef); err == nil && !payload.Stub {
+			updateTenantImage(w, r, store, tenant, req, *binding)
+			return
+		}
+		payload := imageBindingPayload{Name: name, Region: region, Labels: req.Labels, Spec: spec, Stub: true}
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to encode image", r.URL.Path)
+			return
+		}
+		binding.ProviderRef = string(raw)
+		stored, err := store.UpsertResourceBindingIfVersion(r.Context(), *binding, 0)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to persist image", r.URL.Path)
+			return
+		}
+		stateValue, code := upsertStateAndCode(created)
+		respondJSON(w, code, toImageResourceFromBinding(*stored, payload, tenant, upsertVerb(created), stateValue))
+	}
+}
+
+func deleteRuntimeImage(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(r.PathValue("name"))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and image name are required", r.URL.Path)
+			return
+		}
+		if store == nil {
+			if _, ok := runtimeResourceState.getImage(imageRef(tenant, name)); !ok {
+				respondDeleteNotFound(w, r, imageRef(tenant, name), "image not found")
+				return
+			}
+			runtimeResourceState.deleteImage(imageRef(tenant, name))
+			respondDeleteAccepted(w, "")
+			return
+		}
+		ref := tenantImageRef(tenant, name)
+		binding, err := store.GetResourceBinding(r.Context(), ref)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load image", r.URL.Path)
+			return
+		}
+		if binding == nil {
+			respondDeleteNotFound(w, r, ref, "image not found")
+			return
+		}
+		if payload, err := parseImageBinding(binding.ProviderRef); err != nil || !payload.Stub {
+			respondProblem(w, http.StatusConflict, "http://secapi.cloud/errors/resource-conflict", "Conflict", fmt.Sprintf("image %q is a snapshot image", name), r.URL.Path)
+			return
+		}
+		if err := store.DeleteResourceBinding(r.Context(), ref); err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete image", r.URL.Path)
+			return
+		}
+		respondDeleteAccepted(w, "")
+	}
+}
+
+func imageRef(tenant, name string) string {
+	return strings.ToLower(strings.TrimSpace(tenant)) + "/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+func tenantImageRef(tenant, name string) string {
+	return "seca.storage/v1/tenants/" + strings.ToLower(strings.TrimSpace(tenant)) +
+		"/images/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+func parseImageBinding(raw string) (imageBindingPayload, error) {
+	var payload imageBindingPayload
+	err := json.Unmarshal([]byte(raw), &payload)
+	return payload, err
+}
+
+func toImageResourceFromBinding(binding state.ResourceBinding, payload imageBindingPayload, tenant string, verb resourceVerb, stateValue string) imageResource {
+	createdAt := catalogTimestamp
+	updatedAt := createdAt
+	if !binding.CreatedAt.IsZero() {
+		createdAt = binding.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if !binding.UpdatedAt.IsZero() {
+		updatedAt = binding.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return imageResource{
+		Metadata: resourceMetadata{
+			Name:            payload.Name,
+			Provider:        "seca.storage/v1",
+			Resource:        "tenants/" + tenant + "/images/" + payload.Name,
+			Verb:            verb,
+			CreatedAt:       createdAt,
+			LastModifiedAt:  updatedAt,
+			ResourceVersion: binding.ResourceVersion,
+			APIVersion:      "v1",
+			Kind:            "image",
+			Ref:             "seca.storage/v1/tenants/" + tenant + "/images/" + payload.Name,
+			Tenant:          tenant,
+			Region:          defaultRegion(payload.Region),
+		},
+		Labels: payload.Labels,
+		Spec:   payload.Spec,
+		Status: imageStatus{State: stateValue},
+	}
+}
+
+func toRuntimeImageResource(rec imageRuntimeRecord, verb resourceVerb, state string) imageResource {
+	return imageResource{
+		Metadata: resourceMetadata{
+			Name:            rec.Name,
+			Provider:        "seca.storage/v1",
+			Resource:        "tenants/" + rec.Tenant + "/images/" + rec.Name,
+			Verb:            verb,
+			CreatedAt:       rec.CreatedAt,
+			LastModifiedAt:  rec.LastModifiedAt,
+			ResourceVersion: rec.ResourceVersion,
+			APIVersion:      "v1",
+			Kind:            "image",
+			Ref:             "seca.storage/v1/tenants/" + rec.Tenant + "/images/" + rec.Name,
+			Tenant:          rec.Tenant,
+			Region:          rec.Region,
+		},
+		Labels: rec.Labels,
+		Spec:   rec.Spec,
+		Status: imageStatus{State: state},
+	}
+}
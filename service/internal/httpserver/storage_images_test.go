@@ -0,0 +1,155 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func TestImageSourceFromRef(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		ref, workspace                    string
+		wantWorkspace, wantKind, wantName string
+	}{
+		{ref: "seca.storage/v1/tenants/t/workspaces/ws1/block-storages/Data", wantWorkspace: "ws1", wantKind: "block-storages", wantName: "data"},
+		{ref: "block-storages/data", workspace: "ws2", wantWorkspace: "ws2", wantKind: "block-storages", wantName: "data"},
+		{ref: "tenants/t/workspaces/ws1/instances/vm1", workspace: "ignored", wantWorkspace: "ws1", wantKind: "instances", wantName: "vm1"},
+		{ref: "data"},
+	}
+	for _, tc := range cases {
+		workspace, kind, name := imageSourceFromRef(tc.ref, tc.workspace)
+		if workspace != tc.wantWorkspace || kind != tc.wantKind || name != tc.wantName {
+			t.Fatalf("%s: expected (%q, %q, %q), got (%q, %q, %q)", tc.ref, tc.wantWorkspace, tc.wantKind, tc.wantName, workspace, kind, name)
+		}
+	}
+}
+
+func TestPutImageRefusesCatalogNames(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /storage/v1/tenants/{tenant}/images/{name}", putImage(fakeCatalogProvider{}, nil, nil))
+	body := `{"spec":{"blockStorageRef":{"resource":"block-storages/data"}}}`
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, jsonRequest(http.MethodPut, "/storage/v1/tenants/t/images/ubuntu-24.04", body))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a catalog image name, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+type multiArchCatalogProvider struct {
+	fakeCatalogProvider
+}
+
+func (multiArchCatalogProvider) ListCatalogImages(context.Context) ([]hetzner.CatalogImage, error) {
+	return []hetzner.CatalogImage{
+		{Name: "debian-12", Architecture: "arm", OSFlavor: "debian", OSVersion: "12"},
+		{Name: "ubuntu-24.04", Architecture: "arm", OSFlavor: "ubuntu", OSVersion: "24.04", Labels: map[string]string{"channel": "lts"}},
+		{Name: "ubuntu-24.04", Architecture: "x86", OSFlavor: "ubuntu", OSVersion: "24.04", Labels: map[string]string{"channel": "lts"}},
+	}, nil
+}
+
+func TestListImagesFiltersByArchitectureAndLabels(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/images", listImages(multiArchCatalogProvider{}, nil))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/storage/v1/tenants/filters/images?cpuArchitecture=aarch64&labels=channel=lts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list imageIterator
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected one image, got %+v", list.Items)
+	}
+	if spec := list.Items[0].Spec; spec.CPUArchitecture != "arm64" || spec.OSFlavor != "ubuntu" || spec.OSVersion != "24.04" {
+		t.Fatalf("unexpected image spec %+v", spec)
+	}
+
+	for _, query := range []string{"cpuArchitecture=riscv64", "labels=channel=lts,=x"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/storage/v1/tenants/filters/images?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"os": "ubuntu", "channel": "lts"}
+	cases := map[string]bool{
+		"os=ubuntu":             true,
+		"os=ubuntu,channel=lts": true,
+		"os!=debian":            true,
+		"channel":               true,
+		"!deprecated":           true,
+		"os=debian":             false,
+		"!channel":              false,
+		"deprecated":            false,
+	}
+	for raw, want := range cases {
+		selector, err := parseLabelSelector(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		if got := selector.matches(labels); got != want {
+			t.Fatalf("%s: expected %v, got %v", raw, want, got)
+		}
+	}
+}
+
+// TestStubImageSurvivesRestart creates a stub image, drops the in-memory
+// state as a restart would and expects the image to read back unchanged.
+func TestStubImageSurvivesRestart(t *testing.T) {
+	store := testStore(t)
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	putHandler, deleteHandler := imageWriteHandlers(fakeCatalogProvider{}, nil, store, true)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /storage/v1/tenants/{tenant}/images/{name}", putHandler)
+	mux.HandleFunc("DELETE /storage/v1/tenants/{tenant}/images/{name}", deleteHandler)
+	mux.HandleFunc("GET /storage/v1/tenants/{tenant}/images/{name}", getImage(fakeCatalogProvider{}, nil, store))
+	do := func(method, body string, want int) imageResource {
+		t.Helper()
+		req := httptest.NewRequest(method, "/storage/v1/tenants/"+tenant+"/images/custom", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", method, want, rec.Code, rec.Body.String())
+		}
+		var image imageResource
+		_ = json.Unmarshal(rec.Body.Bytes(), &image)
+		return image
+	}
+
+	do(http.MethodPut, `{"labels":{"team":"a"},"spec":{"blockStorageRef":{"resource":"block-storages/data"},"cpuArchitecture":"arm64"}}`, http.StatusCreated)
+	before := do(http.MethodGet, "", http.StatusOK)
+
+	runtimeResourceState.mu.Lock()
+	runtimeResourceState.images = map[string]imageRuntimeRecord{}
+	runtimeResourceState.mu.Unlock()
+
+	after := do(http.MethodGet, "", http.StatusOK)
+	if after.Metadata.ResourceVersion != before.Metadata.ResourceVersion || after.Metadata.CreatedAt != before.Metadata.CreatedAt ||
+		after.Labels["team"] != "a" || after.Spec.CPUArchitecture != "arm64" || after.Status.State != "active" {
+		t.Fatalf("expected the image to read back unchanged, got %+v, before %+v", after, before)
+	}
+	do(http.MethodDelete, "", http.StatusAccepted)
+	do(http.MethodGet, "", http.StatusNotFound)
+}
@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// minTenantTokenLength rejects trivially short operator-chosen tokens.
+const minTenantTokenLength = 8
+
+// tenantTokenLookup resolves a presented bearer token, returning nil when no
+// tenant token matches. It is satisfied by (*state.Store).LookupTenantAPIToken.
+type tenantTokenLookup func(ctx context.Context, token string) (*state.TenantAPIToken, error)
+
+type authenticatedCallerKey struct{}
+
+// authenticatedCaller is the tenant and token name a request authenticated
+// as; the token name is the subject role assignments refer to.
+type authenticatedCaller struct {
+	tenant  string
+	subject string
+}
+
+type tenantTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type tenantTokenResponse struct {
+	Tenant    string `json:"tenant"`
+	Name      string `json:"name"`
+	Token     string `json:"token,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type tenantTokenList struct {
+	Items []tenantTokenResponse `json:"items"`
+}
+
+// requireTenantAuth admits requests carrying a tenant API token. Routes with
+// a {tenant} wildcard additionally require the token to belong to that
+// tenant; the authenticated tenant is available via authenticatedTenant.
+func requireTenantAuth(lookup tenantTokenLookup, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondProblem(w, http.StatusUnauthorized, "http://secapi.cloud/errors/unauthorized", "Unauthorized", "missing bearer token", r.URL.Path)
+			return
+		}
+		record, err := lookup(r.Context(), token)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to verify bearer token", r.URL.Path)
+			return
+		}
+		if record == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondProblem(w, http.StatusUnauthorized, "http://secapi.cloud/errors/unauthorized", "Unauthorized", "invalid bearer token", r.URL.Path)
+			return
+		}
+		if tenant := r.PathValue("tenant"); tenant != "" && tenant != record.Tenant {
+			respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", fmt.Sprintf("token is not valid for tenant %s", tenant), r.URL.Path)
+			return
+		}
+		caller := authenticatedCaller{tenant: record.Tenant, subject: record.Name}
+		next(w, r.WithContext(context.WithValue(r.Context(), authenticatedCallerKey{}, caller)))
+	}
+}
+
+// authenticatedTenant returns the tenant whose token authenticated the
+// request, if any.
+func authenticatedTenant(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(authenticatedCallerKey{}).(authenticatedCaller)
+	return caller.tenant, ok
+}
+
+// authenticatedSubject returns the name of the token that authenticated the
+// request, if any.
+func authenticatedSubject(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(authenticatedCallerKey{}).(authenticatedCaller)
+	return caller.subject, ok
+}
+
+func bearerToken(authHeader string) (string, bool) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(authHeader, prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
+	return token, token != ""
+}
+
+func adminListTenantTokens(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := store.ListTenantAPITokens(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list tenant tokens", r.URL.Path)
+			return
+		}
+		items := make([]tenantTokenResponse, 0, len(tokens))
+		for _, token := range tokens {
+			items = append(items, toTenantTokenResponse(token, ""))
+		}
+		respondJSON(w, http.StatusOK, tenantTokenList{Items: items})
+	}
+}
+
+// adminPutTenantToken stores the token from the body, or generates one when
+// the body has none. The plaintext token is only ever returned here.
+func adminPutTenantToken(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		name := strings.ToLower(strings.TrimSpace(r.PathValue("name")))
+		if tenant == "" || name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and token name are required", r.URL.Path)
+			return
+		}
+		var req tenantTokenRequest
+		if !decodeOptionalJSONBody(w, r, &req) {
+			return
+		}
+		token := strings.TrimSpace(req.Token)
+		if token == "" {
+			generated, err := generateTenantToken()
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to generate tenant token", r.URL.Path)
+				return
+			}
+			token = generated
+		}
+		if len(token) < minTenantTokenLength {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", fmt.Sprintf("token must be at least %d characters", minTenantTokenLength), r.URL.Path)
+			return
+		}
+		stored, err := store.UpsertTenantAPIToken(r.Context(), tenant, name, token)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save tenant token", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toTenantTokenResponse(*stored, token))
+	}
+}
+
+func adminDeleteTenantToken(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := store.DeleteTenantAPIToken(r.Context(), r.PathValue("tenant"), strings.ToLower(r.PathValue("name")))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete tenant token", r.URL.Path)
+			return
+		}
+		if !deleted {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "tenant token not found", r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func generateTenantToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "seca_" + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func toTenantTokenResponse(token state.TenantAPIToken, plaintext string) tenantTokenResponse {
+	return tenantTokenResponse{
+		Tenant:    token.Tenant,
+		Name:      token.Name,
+		Token:     plaintext,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: token.UpdatedAt.Format(time.RFC3339),
+	}
+}
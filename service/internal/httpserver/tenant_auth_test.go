@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestRequireTenantAuthScopesTokensToTheirTenant(t *testing.T) {
+	t.Parallel()
+
+	lookup := func(_ context.Context, token string) (*state.TenantAPIToken, error) {
+		if token != "t1-token-0123456789" {
+			return nil, nil
+		}
+		return &state.TenantAPIToken{Tenant: "t1", Name: "ci"}, nil
+	}
+	var seen string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /workspace/v1/tenants/{tenant}/workspaces", requireTenantAuth(lookup, func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = authenticatedTenant(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		name, tenant, auth string
+		want               int
+	}{
+		{name: "missing", tenant: "t1", want: http.StatusUnauthorized},
+		{name: "not bearer", tenant: "t1", auth: "Basic dDE6eA==", want: http.StatusUnauthorized},
+		{name: "unknown token", tenant: "t1", auth: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "other tenant", tenant: "t2", auth: "Bearer t1-token-0123456789", want: http.StatusForbidden},
+		{name: "own tenant", tenant: "t1", auth: "Bearer t1-token-0123456789", want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/workspace/v1/tenants/"+tc.tenant+"/workspaces", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.want, rec.Code, rec.Body.String())
+		}
+		if tc.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: expected a WWW-Authenticate challenge", tc.name)
+		}
+	}
+	if seen != "t1" {
+		t.Fatalf("expected the authenticated tenant in the context, got %q", seen)
+	}
+}
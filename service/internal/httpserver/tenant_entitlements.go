@@ -0,0 +1,271 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+const regionProviderName = "seca.region/v1"
+
+// tenantEntitlementsTTL bounds how long an entitlement change takes to reach
+// the enforcement middleware.
+const tenantEntitlementsTTL = 10 * time.Second
+
+// tenantProviderEndpoints are the providers a tenant-scoped discovery
+// document can advertise. The region provider is not tenant scoped and is
+// always advertised; every other provider is subject to entitlements.
+var tenantProviderEndpoints = []wellknownEndpoint{
+	{Provider: regionProviderName, URL: "/v1"},
+	{Provider: "seca.authorization/v1", URL: "/v1"},
+	{Provider: "seca.workspace/v1", URL: "/workspace/v1"},
+	{Provider: "seca.compute/v1", URL: "/compute/v1"},
+	{Provider: "seca.storage/v1", URL: "/storage/v1"},
+	{Provider: "seca.network/v1", URL: "/network/v1"},
+}
+
+type tenantEntitlementsRequest struct {
+	Providers []string `json:"providers"`
+}
+
+type tenantEntitlementsResponse struct {
+	Tenant    string   `json:"tenant"`
+	Providers []string `json:"providers"`
+	Default   bool     `json:"default"`
+}
+
+// providerEntitled reports whether the tenant may use provider. A tenant
+// without stored entitlements may use every provider.
+func providerEntitled(entitlements *state.TenantEntitlements, provider string) bool {
+	if entitlements == nil || provider == regionProviderName {
+		return true
+	}
+	for _, entitled := range entitlements.Providers {
+		if entitled == provider {
+			return true
+		}
+	}
+	return false
+}
+
+func tenantWellknownEndpoints(base string, entitlements *state.TenantEntitlements) []wellknownEndpoint {
+	out := make([]wellknownEndpoint, 0, len(tenantProviderEndpoints))
+	for _, endpoint := range tenantProviderEndpoints {
+		if !providerEntitled(entitlements, endpoint.Provider) {
+			continue
+		}
+		out = append(out, wellknownEndpoint{Provider: endpoint.Provider, URL: base + endpoint.URL})
+	}
+	return out
+}
+
+// normalizeEntitledProviders validates the requested providers and returns
+// them deduplicated in discovery order.
+func normalizeEntitledProviders(raw []string) ([]string, error) {
+	requested := make(map[string]struct{}, len(raw))
+	for _, provider := range raw {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		known := false
+		for _, endpoint := range tenantProviderEndpoints {
+			if endpoint.Provider == provider && provider != regionProviderName {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown provider %q", provider)
+		}
+		requested[provider] = struct{}{}
+	}
+	out := make([]string, 0, len(requested))
+	for _, endpoint := range tenantProviderEndpoints {
+		if _, ok := requested[endpoint.Provider]; ok {
+			out = append(out, endpoint.Provider)
+		}
+	}
+	return out, nil
+}
+
+func toTenantEntitlementsResponse(tenant string, entitlements *state.TenantEntitlements) tenantEntitlementsResponse {
+	if entitlements == nil {
+		all := make([]string, 0, len(tenantProviderEndpoints))
+		for _, endpoint := range tenantProviderEndpoints {
+			if endpoint.Provider != regionProviderName {
+				all = append(all, endpoint.Provider)
+			}
+		}
+		return tenantEntitlementsResponse{Tenant: tenant, Providers: all, Default: true}
+	}
+	return tenantEntitlementsResponse{Tenant: tenant, Providers: entitlements.Providers}
+}
+
+// tenantWellknown serves the discovery document for a single tenant, listing
+// only the providers the tenant is entitled to. With the tenant registry on,
+// requireKnownTenant decides which tenants are known; otherwise a tenant is
+// known once it has stored entitlements or at least one workspace.
+func tenantWellknown(cfg config.Config, store *state.Store, cache *tenantEntitlementsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		entitlements, err := cache.get(r.Context(), tenant)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load tenant entitlements", r.URL.Path)
+			return
+		}
+		if entitlements == nil && !cfg.TenantRegistry {
+			workspaces, err := store.ListWorkspaces(r.Context(), tenant)
+			if err != nil {
+				respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to resolve tenant", r.URL.Path)
+				return
+			}
+			if len(workspaces) == 0 {
+				respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "tenant not found", r.URL.Path)
+				return
+			}
+		}
+		respondJSON(w, http.StatusOK, wellknownResponse{
+			Version:   "v1",
+			Endpoints: tenantWellknownEndpoints(strings.TrimRight(cfg.PublicBaseURL, "/"), entitlements),
+		})
+	}
+}
+
+type cachedEntitlements struct {
+	entitlements *state.TenantEntitlements
+	loadedAt     time.Time
+}
+
+// tenantEntitlementsCache keeps each tenant's entitlements for
+// tenantEntitlementsTTL, so public requests do not each read them from the
+// store.
+type tenantEntitlementsCache struct {
+	load func(ctx context.Context, tenant string) (*state.TenantEntitlements, error)
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]cachedEntitlements
+}
+
+func newTenantEntitlementsCache(load func(ctx context.Context, tenant string) (*state.TenantEntitlements, error), ttl time.Duration) *tenantEntitlementsCache {
+	return &tenantEntitlementsCache{
+		load:    load,
+		ttl:     ttl,
+		now:     time.Now,
+		tenants: map[string]cachedEntitlements{},
+	}
+}
+
+func (c *tenantEntitlementsCache) get(ctx context.Context, tenant string) (*state.TenantEntitlements, error) {
+	c.mu.Lock()
+	cached, ok := c.tenants[tenant]
+	c.mu.Unlock()
+	if ok && c.now().Sub(cached.loadedAt) < c.ttl {
+		return cached.entitlements, nil
+	}
+	entitlements, err := c.load(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.tenants[tenant] = cachedEntitlements{entitlements: entitlements, loadedAt: c.now()}
+	c.mu.Unlock()
+	return entitlements, nil
+}
+
+// invalidate drops the cached entitlements of tenant, so a change made
+// through the admin API applies to the next request.
+func (c *tenantEntitlementsCache) invalidate(tenant string) {
+	c.mu.Lock()
+	delete(c.tenants, tenant)
+	c.mu.Unlock()
+}
+
+// requireProviderEntitlement rejects requests for a provider the path tenant
+// is not entitled to, so the routes agree with the tenant discovery document.
+func requireProviderEntitlement(cache *tenantEntitlementsCache, provider string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			next(w, r)
+			return
+		}
+		entitlements, err := cache.get(r.Context(), tenant)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load tenant entitlements", r.URL.Path)
+			return
+		}
+		if !providerEntitled(entitlements, provider) {
+			respondProblem(w, http.StatusForbidden, "http://secapi.cloud/errors/forbidden", "Forbidden", fmt.Sprintf("tenant is not entitled to provider %s", provider), r.URL.Path)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func adminGetTenantEntitlements(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		entitlements, err := store.GetTenantEntitlements(r.Context(), tenant)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load tenant entitlements", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toTenantEntitlementsResponse(tenant, entitlements))
+	}
+}
+
+func adminPutTenantEntitlements(store *state.Store, cache *tenantEntitlementsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		var req tenantEntitlementsRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		providers, err := normalizeEntitledProviders(req.Providers)
+		if err != nil {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", err.Error(), r.URL.Path)
+			return
+		}
+		entitlements, err := store.UpsertTenantEntitlements(r.Context(), tenant, providers)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save tenant entitlements", r.URL.Path)
+			return
+		}
+		cache.invalidate(tenant)
+		respondJSON(w, http.StatusOK, toTenantEntitlementsResponse(tenant, entitlements))
+	}
+}
+
+func adminDeleteTenantEntitlements(store *state.Store, cache *tenantEntitlementsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.PathValue("tenant")
+		if tenant == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		if _, err := store.DeleteTenantEntitlements(r.Context(), tenant); err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete tenant entitlements", r.URL.Path)
+			return
+		}
+		cache.invalidate(tenant)
+		respondJSON(w, http.StatusOK, toTenantEntitlementsResponse(tenant, nil))
+	}
+}
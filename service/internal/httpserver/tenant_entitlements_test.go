@@ -0,0 +1,145 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestTenantWellknownEndpointsFollowEntitlements(t *testing.T) {
+	t.Parallel()
+
+	all := tenantWellknownEndpoints("http://proxy", nil)
+	if len(all) != len(tenantProviderEndpoints) {
+		t.Fatalf("expected every provider without entitlements, got %d", len(all))
+	}
+
+	computeOnly := &state.TenantEntitlements{Tenant: "t1", Providers: []string{"seca.compute/v1", "seca.storage/v1"}}
+	got := tenantWellknownEndpoints("http://proxy", computeOnly)
+	want := []wellknownEndpoint{
+		{Provider: "seca.region/v1", URL: "http://proxy/v1"},
+		{Provider: "seca.compute/v1", URL: "http://proxy/compute/v1"},
+		{Provider: "seca.storage/v1", URL: "http://proxy/storage/v1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected endpoints: %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("endpoint %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if providerEntitled(computeOnly, "seca.network/v1") {
+		t.Fatal("expected network provider to be disabled")
+	}
+	if !providerEntitled(computeOnly, "seca.region/v1") {
+		t.Fatal("expected region provider to stay enabled")
+	}
+}
+
+func TestNormalizeEntitledProviders(t *testing.T) {
+	t.Parallel()
+
+	got, err := normalizeEntitledProviders([]string{" seca.storage/v1", "SECA.compute/v1", "seca.storage/v1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "seca.compute/v1" || got[1] != "seca.storage/v1" {
+		t.Fatalf("unexpected providers: %v", got)
+	}
+	for _, invalid := range []string{"seca.region/v1", "seca.unknown/v1"} {
+		if _, err := normalizeEntitledProviders([]string{invalid}); err == nil {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestRequireProviderEntitlementCachesEntitlements(t *testing.T) {
+	t.Parallel()
+
+	loads := 0
+	cache := newTenantEntitlementsCache(func(context.Context, string) (*state.TenantEntitlements, error) {
+		loads++
+		return &state.TenantEntitlements{Tenant: "t1", Providers: []string{"seca.compute/v1"}}, nil
+	}, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances", requireProviderEntitlement(cache, "seca.compute/v1", ok))
+	mux.HandleFunc("GET /network/v1/tenants/{tenant}/workspaces/{workspace}/networks", requireProviderEntitlement(cache, "seca.network/v1", ok))
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{path: "/compute/v1/tenants/t1/workspaces/ws1/instances", want: http.StatusOK},
+		{path: "/network/v1/tenants/t1/workspaces/ws1/networks", want: http.StatusForbidden},
+		{path: "/compute/v1/tenants/t1/workspaces/ws1/instances", want: http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.path, tc.want, rec.Code)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected one load within the ttl, got %d", loads)
+	}
+
+	now = now.Add(time.Minute)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/t1/workspaces/ws1/instances", nil))
+	if loads != 2 {
+		t.Fatalf("expected a reload once the ttl passed, got %d loads", loads)
+	}
+}
+
+func TestTenantEntitlementsCacheInvalidateReloadsWithinTheTTL(t *testing.T) {
+	t.Parallel()
+
+	providers := []string{"seca.compute/v1"}
+	cache := newTenantEntitlementsCache(func(_ context.Context, tenant string) (*state.TenantEntitlements, error) {
+		return &state.TenantEntitlements{Tenant: tenant, Providers: providers}, nil
+	}, time.Minute)
+	if entitlements, _ := cache.get(context.Background(), "t1"); providerEntitled(entitlements, "seca.network/v1") {
+		t.Fatal("expected t1 not to be entitled to the network provider yet")
+	}
+	providers = []string{"seca.compute/v1", "seca.network/v1"}
+	if entitlements, _ := cache.get(context.Background(), "t1"); providerEntitled(entitlements, "seca.network/v1") {
+		t.Fatal("expected the cached entitlements within the ttl")
+	}
+	cache.invalidate("t1")
+	if entitlements, _ := cache.get(context.Background(), "t1"); !providerEntitled(entitlements, "seca.network/v1") {
+		t.Fatalf("expected the change to apply once invalidated, got %+v", entitlements)
+	}
+}
+
+func TestTenantWellknownFollowsTheTenantRegistry(t *testing.T) {
+	t.Parallel()
+
+	lookup := func(_ context.Context, name string) (*state.Tenant, error) {
+		if name == "t1" {
+			return &state.Tenant{Name: "t1"}, nil
+		}
+		return nil, nil
+	}
+	cache := newTenantEntitlementsCache(func(context.Context, string) (*state.TenantEntitlements, error) {
+		return nil, nil
+	}, time.Minute)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/{tenant}/.wellknown/secapi", requireKnownTenant(lookup, tenantWellknown(config.Config{TenantRegistry: true}, nil, cache)))
+
+	for tenant, want := range map[string]int{"t1": http.StatusOK, "unknown": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tenants/"+tenant+"/.wellknown/secapi", nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", tenant, want, rec.Code, rec.Body.String())
+		}
+	}
+}
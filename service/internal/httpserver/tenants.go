@@ -0,0 +1,102 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// tenantLookup resolves a registered tenant, returning nil when it is not
+// registered. It is satisfied by (*state.Store).GetTenant.
+type tenantLookup func(ctx context.Context, name string) (*state.Tenant, error)
+
+type tenantResponse struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type tenantList struct {
+	Items []tenantResponse `json:"items"`
+}
+
+// requireKnownTenant answers 404 for routes whose {tenant} is not in the
+// tenant registry, so requests for unknown tenants neither read empty
+// collections nor create resources.
+func requireKnownTenant(lookup tenantLookup, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("tenant")
+		if name == "" {
+			next(w, r)
+			return
+		}
+		tenant, err := lookup(r.Context(), name)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to load tenant", r.URL.Path)
+			return
+		}
+		if tenant == nil {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "tenant not found", r.URL.Path)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func adminListTenants(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenants, err := store.ListTenants(r.Context())
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list tenants", r.URL.Path)
+			return
+		}
+		items := make([]tenantResponse, 0, len(tenants))
+		for _, tenant := range tenants {
+			items = append(items, toTenantResponse(tenant))
+		}
+		respondJSON(w, http.StatusOK, tenantList{Items: items})
+	}
+}
+
+func adminPutTenant(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("tenant")
+		if name == "" {
+			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
+			return
+		}
+		tenant, err := store.UpsertTenant(r.Context(), name)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save tenant", r.URL.Path)
+			return
+		}
+		respondJSON(w, http.StatusOK, toTenantResponse(*tenant))
+	}
+}
+
+// adminDeleteTenant unregisters the tenant. Its workspaces, tokens and
+// entitlements stay in place and become reachable again on re-registration.
+func adminDeleteTenant(store *state.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := store.DeleteTenant(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete tenant", r.URL.Path)
+			return
+		}
+		if !deleted {
+			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "tenant not found", r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func toTenantResponse(tenant state.Tenant) tenantResponse {
+	return tenantResponse{
+		Name:      tenant.Name,
+		CreatedAt: tenant.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: tenant.UpdatedAt.Format(time.RFC3339),
+	}
+}
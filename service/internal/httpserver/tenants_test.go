@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+func TestRequireKnownTenant(t *testing.T) {
+	t.Parallel()
+
+	lookup := func(_ context.Context, name string) (*state.Tenant, error) {
+		switch name {
+		case "t1":
+			return &state.Tenant{Name: "t1"}, nil
+		case "broken":
+			return nil, errors.New(`get tenant: ERROR: relation "tenants" does not exist (SQLSTATE 42P01)`)
+		}
+		return nil, nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/{tenant}/roles", requireKnownTenant(lookup, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	get := func(tenant string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tenants/"+tenant+"/roles", nil))
+		return rec
+	}
+
+	if rec := get("t1"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a registered tenant, got %d", rec.Code)
+	}
+	if rec := get("unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown tenant, got %d", rec.Code)
+	}
+	rec := get("broken")
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "SQLSTATE") {
+		t.Fatalf("expected a sanitized 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRespondFromErrorHidesInternalErrors(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	respondFromError(rec, fmt.Errorf("get role: %w", errors.New("failed to connect to `host=db user=postgres`")), "/v1/tenants/t1/roles/r1")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "postgres") {
+		t.Fatalf("problem detail leaked the store error: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestKeyPair(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func servedCommonName(t *testing.T, server *http.Server) string {
+	t.Helper()
+	cert, err := server.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestConfigureTLSReloadsRotatedCertificate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir, "first")
+	server := &http.Server{}
+	reloader, err := ConfigureTLS(server, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("configure tls: %v", err)
+	}
+	if server.TLSConfig.MinVersion != tls.VersionTLS12 || server.TLSConfig.NextProtos[0] != "h2" {
+		t.Fatalf("unexpected tls config: min=%x protos=%v", server.TLSConfig.MinVersion, server.TLSConfig.NextProtos)
+	}
+	if got := servedCommonName(t, server); got != "first" {
+		t.Fatalf("expected first certificate, got %q", got)
+	}
+
+	writeTestKeyPair(t, dir, "second")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := servedCommonName(t, server); got != "second" {
+		t.Fatalf("expected rotated certificate, got %q", got)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("expected reload of a broken key to fail")
+	}
+	if got := servedCommonName(t, server); got != "second" {
+		t.Fatalf("expected the previous certificate to stay in use, got %q", got)
+	}
+}
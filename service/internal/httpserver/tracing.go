@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceIDHeader carries the trace ID of a sampled request back to the client.
+const traceIDHeader = "X-Trace-Id"
+
+// traceRequests opens a server span per request, continuing the caller's
+// trace when it sends a traceparent header. It wraps the mux directly so the
+// span can be named by the matched pattern and carry the tenant, workspace and
+// resource name from the path. A sampled request gets its trace ID in
+// X-Trace-Id, which respondProblem also appends to the problem instance.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+		defer span.End()
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			w.Header().Set(traceIDHeader, traceID)
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(recorder, r)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+		for attr, wildcard := range map[string]string{"seca.tenant": "tenant", "seca.workspace": "workspace", "seca.resource": "name"} {
+			if value := r.PathValue(wildcard); value != "" {
+				span.SetAttributes(attribute.String(attr, value))
+			}
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// problemInstance appends the trace ID of the request being answered to a
+// problem instance, so a failed call can be looked up in the trace backend.
+func problemInstance(w http.ResponseWriter, instance string) string {
+	if traceID := w.Header().Get(traceIDHeader); traceID != "" && instance != "" {
+		return instance + "#trace=" + traceID
+	}
+	return instance
+}
+
+// logResponseError logs like log.Printf and appends the trace ID of the
+// request being answered, for handlers that no longer hold its context.
+func logResponseError(w http.ResponseWriter, format string, args ...any) {
+	if traceID := w.Header().Get(traceIDHeader); traceID != "" {
+		log.Printf(format+" trace_id=%s", append(args, traceID)...)
+		return
+	}
+	log.Printf(format, args...)
+}
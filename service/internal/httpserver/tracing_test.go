@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequestsNamesSpanByRouteAndTagsProblems(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", func(w http.ResponseWriter, r *http.Request) {
+		respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "instance not found", r.URL.Path)
+	})
+	rec := httptest.NewRecorder()
+	traceRequests(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/compute/v1/tenants/acme/workspaces/prod/instances/vm-1", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}" {
+		t.Fatalf("unexpected span name %q", span.Name())
+	}
+	attrs := attribute.NewSet(span.Attributes()...)
+	for key, want := range map[attribute.Key]string{"seca.tenant": "acme", "seca.workspace": "prod", "seca.resource": "vm-1"} {
+		if got, _ := attrs.Value(key); got.AsString() != want {
+			t.Fatalf("expected %s=%q, got %q", key, want, got.AsString())
+		}
+	}
+	traceID := span.SpanContext().TraceID().String()
+	if rec.Header().Get(traceIDHeader) != traceID {
+		t.Fatalf("expected %s %s, got %q", traceIDHeader, traceID, rec.Header().Get(traceIDHeader))
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := "/compute/v1/tenants/acme/workspaces/prod/instances/vm-1#trace=" + traceID; problem.Instance != want {
+		t.Fatalf("expected instance %q, got %q", want, problem.Instance)
+	}
+}
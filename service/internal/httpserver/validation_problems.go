@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// fieldProblem is one invalid body field or request parameter and why it is
+// invalid.
+type fieldProblem struct {
+	source problemSource
+	detail string
+}
+
+// fieldPointer reports a body field by its JSON pointer.
+func fieldPointer(pointer, format string, args ...any) fieldProblem {
+	return fieldProblem{source: problemSource{Pointer: pointer}, detail: fmt.Sprintf(format, args...)}
+}
+
+// fieldParameter reports a path or query parameter by name.
+func fieldParameter(name, format string, args ...any) fieldProblem {
+	return fieldProblem{source: problemSource{Parameter: name}, detail: fmt.Sprintf(format, args...)}
+}
+
+// respondValidationProblem answers 400 with one source per problem, so a
+// client sees every invalid field at once. The detail joins the messages.
+func respondValidationProblem(w http.ResponseWriter, instance string, problems ...fieldProblem) {
+	details := make([]string, 0, len(problems))
+	sources := make([]problemSource, 0, len(problems))
+	for _, problem := range problems {
+		details = append(details, problem.detail)
+		sources = append(sources, problem.source)
+	}
+	respondProblemWithSources(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", strings.Join(details, "; "), instance, sources)
+}
@@ -1,11 +1,12 @@
 package httpserver
 
 import (
-	"encoding/json"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
 )
 
@@ -22,16 +23,40 @@ type workspaceResource struct {
 }
 
 type workspaceStatusObject struct {
-	State         string `json:"state"`
-	ResourceCount *int   `json:"resourceCount,omitempty"`
+	State          string         `json:"state"`
+	ResourceCount  *int           `json:"resourceCount,omitempty"`
+	ResourceCounts map[string]int `json:"resourceCounts,omitempty"`
+}
+
+// setResourceCounts reports the workspace's resources per binding kind and
+// their total.
+func (s *workspaceStatusObject) setResourceCounts(byKind map[string]int) {
+	total := 0
+	for _, count := range byKind {
+		total += count
+	}
+	s.ResourceCount = &total
+	s.ResourceCounts = byKind
+}
+
+// workspaceResourceCounts groups the blocking binding counts by workspace and
+// kind.
+func workspaceResourceCounts(counts []state.ResourceBindingCount) map[string]map[string]int {
+	out := map[string]map[string]int{}
+	for _, count := range counts {
+		if count.Kind == resourceBindingKindNetworkRouteTableRef {
+			continue
+		}
+		if out[count.Workspace] == nil {
+			out[count.Workspace] = map[string]int{}
+		}
+		out[count.Workspace][count.Kind] += count.Count
+	}
+	return out
 }
 
 func listWorkspaces(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET is supported", r.URL.Path)
-			return
-		}
 		tenant := r.PathValue("tenant")
 		if tenant == "" {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant is required", r.URL.Path)
@@ -42,32 +67,25 @@ func listWorkspaces(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list workspaces", r.URL.Path)
 			return
 		}
+		counts, err := store.CountTenantResourceBindings(r.Context(), tenant)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to count workspace resources", r.URL.Path)
+			return
+		}
+		resourceCounts := workspaceResourceCounts(counts)
 		items := make([]workspaceResource, 0, len(workspaces))
 		for _, item := range workspaces {
-			items = append(items, toWorkspaceResource(item, http.MethodGet, false))
+			resource := toWorkspaceResource(item, verbList, false)
+			resource.Status.setResourceCounts(resourceCounts[item.Name])
+			items = append(items, resource)
 		}
 		respondJSON(w, http.StatusOK, workspaceIterator{
 			Items:    items,
-			Metadata: responseMetaObject{Provider: "seca.workspace/v1", Resource: "tenants/" + tenant + "/workspaces", Verb: http.MethodGet},
+			Metadata: responseMetaObject{Provider: "seca.workspace/v1", Resource: "tenants/" + tenant + "/workspaces", Verb: verbList},
 		})
 	}
 }
 
-func workspaceCRUD(store *state.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getWorkspace(store)(w, r)
-		case http.MethodPut:
-			putWorkspace(store)(w, r)
-		case http.MethodDelete:
-			deleteWorkspace(store)(w, r)
-		default:
-			respondProblem(w, http.StatusMethodNotAllowed, "http://secapi.cloud/errors/invalid-request", "Method Not Allowed", "Only GET, PUT and DELETE are supported", r.URL.Path)
-		}
-	}
-}
-
 func getWorkspace(store *state.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant := r.PathValue("tenant")
@@ -85,11 +103,18 @@ func getWorkspace(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "workspace not found", r.URL.Path)
 			return
 		}
-		respondJSON(w, http.StatusOK, toWorkspaceResource(*item, http.MethodGet, true))
+		counts, err := store.CountTenantResourceBindings(r.Context(), tenant)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to count workspace resources", r.URL.Path)
+			return
+		}
+		resource := toWorkspaceResource(*item, verbGet, true)
+		resource.Status.setResourceCounts(workspaceResourceCounts(counts)[name])
+		respondJSON(w, http.StatusOK, resource)
 	}
 }
 
-func putWorkspace(store *state.Store) http.HandlerFunc {
+func putWorkspace(store *state.Store, regionProvider RegionProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant := r.PathValue("tenant")
 		name := strings.ToLower(r.PathValue("name"))
@@ -98,8 +123,11 @@ func putWorkspace(store *state.Store) http.HandlerFunc {
 			return
 		}
 		var req workspaceResource
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "invalid json body", r.URL.Path)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, req.Metadata.ResourceVersion)
+		if !ok {
 			return
 		}
 
@@ -113,6 +141,24 @@ func putWorkspace(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to check existing workspace", r.URL.Path)
 			return
 		}
+		if !precondition.check(w, r, existing != nil, workspaceVersion(existing)) {
+			return
+		}
+		// An unchanged region was checked when it was set, so updates do not
+		// depend on Hetzner being reachable.
+		if existing == nil || !strings.EqualFold(existing.Region, region) {
+			regions, err := regionProvider.ListRegions(r.Context())
+			if err != nil {
+				respondFromError(w, err, r.URL.Path)
+				return
+			}
+			name, problem := matchWorkspaceRegion(region, regions)
+			if problem != nil {
+				respondValidationProblem(w, r.URL.Path, *problem)
+				return
+			}
+			region = name
+		}
 		statusState := "creating"
 		code := http.StatusCreated
 		if existing != nil {
@@ -127,16 +173,37 @@ func putWorkspace(store *state.Store) http.HandlerFunc {
 			Spec:   req.Spec,
 			Status: map[string]any{"state": statusState},
 		}
-		saved, err := store.UpsertWorkspace(r.Context(), desired)
+		saved, err := store.UpsertWorkspaceIfVersion(r.Context(), desired, precondition.version)
 		if err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to save workspace", r.URL.Path)
 			return
 		}
-		respondJSON(w, code, toWorkspaceResource(*saved, http.MethodPut, false))
+		respondJSON(w, code, toWorkspaceResource(*saved, upsertVerb(existing == nil), false))
 	}
 }
 
-func deleteWorkspace(store *state.Store) http.HandlerFunc {
+// matchWorkspaceRegion returns the name of the region matching region
+// case-insensitively, or a problem listing the valid ones.
+func matchWorkspaceRegion(region string, regions []hetzner.Region) (string, *fieldProblem) {
+	names := make([]string, 0, len(regions))
+	for _, candidate := range regions {
+		if strings.EqualFold(candidate.Name, region) {
+			return candidate.Name, nil
+		}
+		names = append(names, candidate.Name)
+	}
+	slices.Sort(names)
+	problem := fieldPointer("/metadata/region", "metadata.region %q is not a region; valid regions: %s", region, strings.Join(names, ", "))
+	return "", &problem
+}
+
+// deleteWorkspace refuses while resources remain in the workspace. With
+// force=true it accepts the delete and removes those resources in the
+// background.
+func deleteWorkspace(store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tenant := r.PathValue("tenant")
 		name := strings.ToLower(r.PathValue("name"))
@@ -144,20 +211,73 @@ func deleteWorkspace(store *state.Store) http.HandlerFunc {
 			respondProblem(w, http.StatusBadRequest, "http://secapi.cloud/errors/invalid-request", "Bad Request", "tenant and workspace name are required", r.URL.Path)
 			return
 		}
-		deleted, err := store.SoftDeleteWorkspace(r.Context(), tenant, name)
+		force, ok := forceFromQuery(w, r)
+		if !ok {
+			return
+		}
+		precondition, ok := parseVersionPrecondition(w, r, 0)
+		if !ok {
+			return
+		}
+		ref := "seca.workspace/v1/tenants/" + tenant + "/workspaces/" + name
+		existing, err := store.GetWorkspace(r.Context(), tenant, name)
+		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to check existing workspace", r.URL.Path)
+			return
+		}
+		if precondition.conditional() {
+			if !precondition.check(w, r, existing != nil, workspaceVersion(existing)) {
+				return
+			}
+		}
+		if existing == nil {
+			respondDeleteNotFound(w, r, ref, "workspace not found")
+			return
+		}
+		bindings, err := store.ListWorkspaceResourceBindings(r.Context(), tenant, name)
 		if err != nil {
+			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to list workspace resources", r.URL.Path)
+			return
+		}
+		dependents := workspaceDependents(bindings)
+		if len(dependents) > 0 && !force {
+			respondDependentsConflict(w, r, "workspace "+name, dependents)
+			return
+		}
+		ctx := r.Context()
+		if len(dependents) > 0 {
+			ctx, ok = workspaceExecutionContext(w, r, store, tenant, name)
+			if !ok {
+				return
+			}
+		}
+		deleted, err := store.SoftDeleteWorkspaceIfVersion(r.Context(), tenant, name, precondition.version)
+		if err != nil {
+			if precondition.respondStoreError(w, r, err) {
+				return
+			}
 			respondProblem(w, http.StatusInternalServerError, "http://secapi.cloud/errors/internal", "Internal Server Error", "failed to delete workspace", r.URL.Path)
 			return
 		}
 		if !deleted {
-			respondProblem(w, http.StatusNotFound, "http://secapi.cloud/errors/resource-not-found", "Not Found", "workspace not found", r.URL.Path)
+			respondDeleteNotFound(w, r, ref, "workspace not found")
 			return
 		}
-		respondJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+		if len(dependents) > 0 {
+			startWorkspacePurge(ctx, store, computeProvider, networkProvider, tenant, name, bindings)
+		}
+		respondDeleteAccepted(w, "")
+	}
+}
+
+func workspaceVersion(item *state.WorkspaceResource) int64 {
+	if item == nil {
+		return 0
 	}
+	return item.ResourceVersion
 }
 
-func toWorkspaceResource(item state.WorkspaceResource, verb string, forceActive bool) workspaceResource {
+func toWorkspaceResource(item state.WorkspaceResource, verb resourceVerb, forceActive bool) workspaceResource {
 	stateValue, _ := item.Status["state"].(string)
 	if stateValue == "" {
 		stateValue = "active"
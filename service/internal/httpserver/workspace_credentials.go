@@ -0,0 +1,88 @@
+package httpserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// workspaceCredentialTTL bounds how long a decrypted credential is reused
+// without a database read. Local changes invalidate entries immediately
+// through the store generation; the TTL only covers writes made by other
+// replicas.
+const workspaceCredentialTTL = 30 * time.Second
+
+var workspaceCredentials = newWorkspaceCredentialCache(workspaceCredentialTTL)
+
+type workspaceCredentialCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cachedWorkspaceCredential
+}
+
+type cachedWorkspaceCredential struct {
+	generations *state.CredentialGenerations
+	generation  uint64
+	credential  *state.WorkspaceProviderCredential
+	loadedAt    time.Time
+}
+
+func newWorkspaceCredentialCache(ttl time.Duration) *workspaceCredentialCache {
+	return &workspaceCredentialCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cachedWorkspaceCredential{},
+	}
+}
+
+// resolve returns the credential for the workspace together with a channel
+// that is closed once the credential changes. Entries built from an older
+// generation are never returned.
+func (c *workspaceCredentialCache) resolve(ctx context.Context, generations *state.CredentialGenerations, tenant, workspace, provider string, load func(context.Context) (*state.WorkspaceProviderCredential, error)) (*state.WorkspaceProviderCredential, <-chan struct{}, error) {
+	generation, changed := generations.Current(tenant, workspace, provider)
+	key := strings.ToLower(tenant + "/" + workspace + "/" + provider)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.generations == generations && entry.generation == generation && c.now().Sub(entry.loadedAt) < c.ttl {
+		return entry.credential, changed, nil
+	}
+
+	cred, err := load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cachedWorkspaceCredential{
+		generations: generations,
+		generation:  generation,
+		credential:  cred,
+		loadedAt:    c.now(),
+	}
+	c.mu.Unlock()
+	return cred, changed, nil
+}
+
+// revocableContext derives a context that is cancelled with
+// hetzner.ErrCredentialRevoked as soon as revoked is closed, so provider
+// calls that are still running stop using the old token.
+func revocableContext(parent context.Context, revoked <-chan struct{}) context.Context {
+	if revoked == nil {
+		return parent
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	go func() {
+		select {
+		case <-revoked:
+			cancel(hetzner.ErrCredentialRevoked)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx
+}
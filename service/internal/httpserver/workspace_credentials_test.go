@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeCredentialStore struct {
+	generations *state.CredentialGenerations
+	credential  *state.WorkspaceProviderCredential
+	loads       int
+}
+
+func (f *fakeCredentialStore) load(context.Context) (*state.WorkspaceProviderCredential, error) {
+	f.loads++
+	return f.credential, nil
+}
+
+func (f *fakeCredentialStore) delete() {
+	f.credential = nil
+	f.generations.Bump("t1", "ws1", "hetzner")
+}
+
+func TestWorkspaceCredentialCacheCutsOffDeletedCredential(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeCredentialStore{
+		generations: state.NewCredentialGenerations(),
+		credential:  &state.WorkspaceProviderCredential{Tenant: "t1", Workspace: "ws1", Provider: "hetzner", APIToken: "secret"},
+	}
+	cache := newWorkspaceCredentialCache(time.Hour)
+
+	cred, revoked, err := cache.resolve(context.Background(), fake.generations, "t1", "ws1", "hetzner", fake.load)
+	if err != nil || cred == nil {
+		t.Fatalf("expected credential, got %v (err %v)", cred, err)
+	}
+	inFlight := revocableContext(context.Background(), revoked)
+
+	if _, _, err := cache.resolve(context.Background(), fake.generations, "t1", "ws1", "hetzner", fake.load); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.loads != 1 {
+		t.Fatalf("expected cached credential to be reused, got %d loads", fake.loads)
+	}
+
+	fake.delete()
+
+	select {
+	case <-inFlight.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("in-flight context was not cancelled after deletion")
+	}
+	if !errors.Is(context.Cause(inFlight), hetzner.ErrCredentialRevoked) {
+		t.Fatalf("unexpected cancellation cause: %v", context.Cause(inFlight))
+	}
+
+	cred, _, err = cache.resolve(context.Background(), fake.generations, "t1", "ws1", "hetzner", fake.load)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cred != nil {
+		t.Fatalf("expected deleted credential to be dropped, got %+v", *cred)
+	}
+	if fake.loads != 2 {
+		t.Fatalf("expected reload after generation change, got %d loads", fake.loads)
+	}
+}
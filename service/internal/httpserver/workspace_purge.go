@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+)
+
+// workspacePurgeTimeout bounds the background cleanup that follows a forced
+// workspace delete.
+const workspacePurgeTimeout = 15 * time.Minute
+
+// workspacePurgeOrder lists binding kinds in the order a forced workspace
+// delete removes them: load balancers and servers first, so volumes, IPs,
+// networks and firewalls are no longer in use, and store-only kinds last.
+var workspacePurgeOrder = []string{
+	resourceBindingKindLoadBalancer,
+	"instance",
+	resourceBindingKindInternetGateway,
+	"block-storage",
+	resourceBindingKindPublicIP,
+	resourceBindingKindSecurityGroup,
+	resourceBindingKindPlacementGroup,
+	resourceBindingKindImage,
+	resourceBindingKindNIC,
+	resourceBindingKindRouteTable,
+	resourceBindingKindSubnet,
+	resourceBindingKindNetworkRouteTableRef,
+	resourceBindingKindNetwork,
+}
+
+// workspaceDependents returns the bindings that keep a workspace from being
+// deleted. Network route table refs only annotate a network and never block.
+func workspaceDependents(bindings []state.ResourceBinding) []networkDependent {
+	dependents := make([]networkDependent, 0, len(bindings))
+	for _, binding := range bindings {
+		if binding.Kind == resourceBindingKindNetworkRouteTableRef {
+			continue
+		}
+		dependents = append(dependents, networkDependent{ref: binding.SecaRef})
+	}
+	return dependents
+}
+
+// startWorkspacePurge deletes the workspace's Hetzner resources in the
+// background once the delete has been accepted. Failures are logged; the
+// bindings of resources that could not be deleted stay in place.
+func startWorkspacePurge(ctx context.Context, store *state.Store, computeProvider ComputeStorageProvider, networkProvider NetworkProvider, tenant, workspace string, bindings []state.ResourceBinding) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), workspacePurgeTimeout)
+	ctx, done := hetzner.TrackInFlight(ctx)
+	go func() {
+		defer done()
+		defer cancel()
+		if err := purgeWorkspaceResources(ctx, store.DeleteResourceBinding, computeProvider, networkProvider, tenant, workspace, bindings); err != nil {
+			tracing.Logf(ctx, "purge workspace %s/%s: %v", tenant, workspace, err)
+		}
+	}()
+}
+
+// purgeWorkspaceResources deletes the resource behind every binding in
+// workspacePurgeOrder, drops each binding whose resource is gone, and finally
+// deletes the workspace's networks. It keeps going past failures and returns
+// them joined.
+func purgeWorkspaceResources(ctx context.Context, deleteBinding func(ctx context.Context, secaRef string) error, computeProvider ComputeStorageProvider, networkProvider NetworkProvider, tenant, workspace string, bindings []state.ResourceBinding) error {
+	byKind := map[string][]state.ResourceBinding{}
+	for _, binding := range bindings {
+		byKind[binding.Kind] = append(byKind[binding.Kind], binding)
+	}
+	var errs []error
+	for _, kind := range workspacePurgeOrder {
+		for _, binding := range byKind[kind] {
+			if err := purgeWorkspaceBinding(ctx, computeProvider, networkProvider, workspace, binding); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", binding.SecaRef, err))
+				continue
+			}
+			if err := deleteBinding(ctx, binding.SecaRef); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	networks, err := networkProvider.ListNetworks(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list networks: %w", err))
+		return errors.Join(errs...)
+	}
+	for _, network := range networks {
+		if !providerLabelsInScope(network.Labels, tenant, workspace) {
+			continue
+		}
+		if _, err := networkProvider.DeleteNetwork(ctx, network.Name); err != nil {
+			errs = append(errs, fmt.Errorf("network %s: %w", network.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func purgeWorkspaceBinding(ctx context.Context, computeProvider ComputeStorageProvider, networkProvider NetworkProvider, workspace string, binding state.ResourceBinding) error {
+	name := resourceNameFromRef(binding.SecaRef)
+	switch binding.Kind {
+	case resourceBindingKindLoadBalancer:
+		_, err := networkProvider.DeleteLoadBalancer(ctx, name)
+		return err
+	case "instance":
+		_, actionID, err := computeProvider.DeleteInstance(ctx, name)
+		if err != nil || actionID == "" {
+			return err
+		}
+		id, err := strconv.ParseInt(actionID, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return computeProvider.WaitForAction(ctx, id)
+	case resourceBindingKindInternetGateway:
+		_, _, err := computeProvider.DeleteInstance(ctx, internetGatewayInstanceName(workspace, name))
+		return err
+	case "block-storage":
+		_, err := computeProvider.DeleteBlockStorage(ctx, name, true)
+		return err
+	case resourceBindingKindPublicIP:
+		_, err := networkProvider.DeletePublicIP(ctx, name)
+		return err
+	case resourceBindingKindSecurityGroup:
+		_, err := networkProvider.DeleteSecurityGroup(ctx, name)
+		return err
+	case resourceBindingKindPlacementGroup:
+		_, err := computeProvider.DeletePlacementGroup(ctx, name)
+		return err
+	case resourceBindingKindImage:
+		payload, err := parseImageBinding(binding.ProviderRef)
+		if err != nil || payload.SnapshotID == 0 {
+			return nil
+		}
+		_, err = computeProvider.DeleteImageSnapshot(ctx, payload.SnapshotID)
+		return err
+	default:
+		return nil
+	}
+}
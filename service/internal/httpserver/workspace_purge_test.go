@@ -0,0 +1,93 @@
+package httpserver
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// fakePurgeNetworkProvider implements the NetworkProvider calls a workspace
+// purge makes; any other call panics on the nil embedded interface.
+type fakePurgeNetworkProvider struct {
+	NetworkProvider
+	networks []hetzner.Network
+	deleted  []string
+}
+
+func (f *fakePurgeNetworkProvider) ListNetworks(context.Context) ([]hetzner.Network, error) {
+	return f.networks, nil
+}
+
+func (f *fakePurgeNetworkProvider) DeleteNetwork(_ context.Context, name string) (bool, error) {
+	f.deleted = append(f.deleted, "network/"+name)
+	return true, nil
+}
+
+func (f *fakePurgeNetworkProvider) DeletePublicIP(_ context.Context, name string) (bool, error) {
+	f.deleted = append(f.deleted, "public-ip/"+name)
+	return true, nil
+}
+
+func (f *fakePurgeNetworkProvider) DeleteSecurityGroup(_ context.Context, name string) (bool, error) {
+	f.deleted = append(f.deleted, "security-group/"+name)
+	return true, nil
+}
+
+func TestPurgeWorkspaceResourcesDeletesServersFirstAndNetworksLast(t *testing.T) {
+	t.Parallel()
+
+	prefix := "seca.network/v1/tenants/t1/workspaces/ws1/"
+	bindings := []state.ResourceBinding{
+		{Kind: resourceBindingKindSecurityGroup, SecaRef: prefix + "security-groups/web"},
+		{Kind: resourceBindingKindSubnet, SecaRef: prefix + "networks/net/subnets/a"},
+		{Kind: resourceBindingKindPublicIP, SecaRef: prefix + "public-ips/ip1"},
+		{Kind: "instance", SecaRef: "seca.compute/v1/tenants/t1/workspaces/ws1/instances/vm1"},
+	}
+	network := &fakePurgeNetworkProvider{networks: []hetzner.Network{
+		{Name: "net", Labels: withSecaProviderLabels(nil, "t1", "ws1", "network", "net", prefix+"networks/net")},
+		{Name: "other", Labels: withSecaProviderLabels(nil, "t1", "ws2", "network", "other", "")},
+	}}
+	compute := &fakeComputeProvider{}
+	var dropped []string
+	deleteBinding := func(_ context.Context, ref string) error {
+		dropped = append(dropped, ref)
+		return nil
+	}
+
+	if err := purgeWorkspaceResources(context.Background(), deleteBinding, compute, network, "t1", "ws1", bindings); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if compute.deleteName != "vm1" {
+		t.Fatalf("expected instance vm1 to be deleted, got %q", compute.deleteName)
+	}
+	wantDeleted := []string{"public-ip/ip1", "security-group/web", "network/net"}
+	if !slices.Equal(network.deleted, wantDeleted) {
+		t.Fatalf("expected provider deletes %v, got %v", wantDeleted, network.deleted)
+	}
+	wantDropped := []string{bindings[3].SecaRef, bindings[2].SecaRef, bindings[0].SecaRef, bindings[1].SecaRef}
+	if !slices.Equal(dropped, wantDropped) {
+		t.Fatalf("expected bindings dropped in order %v, got %v", wantDropped, dropped)
+	}
+}
+
+func TestWorkspaceResourceCountsSkipsRouteTableRefs(t *testing.T) {
+	t.Parallel()
+
+	counts := workspaceResourceCounts([]state.ResourceBindingCount{
+		{Workspace: "ws1", Kind: "instance", Count: 2},
+		{Workspace: "ws1", Kind: resourceBindingKindNetworkRouteTableRef, Count: 1},
+		{Workspace: "ws2", Kind: resourceBindingKindSubnet, Count: 3},
+	})
+	if len(counts["ws1"]) != 1 || counts["ws1"]["instance"] != 2 || counts["ws2"][resourceBindingKindSubnet] != 3 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+
+	var status workspaceStatusObject
+	status.setResourceCounts(counts["ws3"])
+	if status.ResourceCount == nil || *status.ResourceCount != 0 {
+		t.Fatalf("expected a zero resourceCount for an empty workspace, got %v", status.ResourceCount)
+	}
+}
@@ -0,0 +1,135 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// testStore connects to SECA_TEST_DATABASE_URL and applies migrations. Tests
+// using it are skipped when no test database is configured.
+func testStore(t *testing.T) *state.Store {
+	t.Helper()
+	databaseURL := os.Getenv("SECA_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("SECA_TEST_DATABASE_URL is not set")
+	}
+	if err := state.MigrateUp(databaseURL, "../../db/migrations"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	store, err := state.New(context.Background(), databaseURL, base64.StdEncoding.EncodeToString(key), state.PoolConfig{})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+func putInstanceInWorkspace(t *testing.T, store *state.Store, provider *fakeComputeProvider, tenant, workspace string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /compute/v1/tenants/{tenant}/workspaces/{workspace}/instances/{name}", putInstance(provider, nil, store, false, false, "ubuntu-24.04"))
+	body := `{"spec":{"skuRef":"skus/cx22","bootVolume":{"deviceRef":"images/ubuntu-24.04"}}}`
+	path := fmt.Sprintf("/compute/v1/tenants/%s/workspaces/%s/instances/vm-1", tenant, workspace)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+	return rec
+}
+
+func assertWorkspaceNotFound(t *testing.T, rec *httptest.ResponseRecorder, provider *fakeComputeProvider) {
+	t.Helper()
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var problem problemResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Detail != "workspace not found" {
+		t.Fatalf("expected workspace not found problem, got %s", rec.Body.String())
+	}
+	if provider.createReq != nil {
+		t.Fatalf("expected no Hetzner call for an unknown workspace, got %+v", provider.createReq)
+	}
+}
+
+func TestWorkspaceScopedPutRejectsMissingWorkspace(t *testing.T) {
+	store := testStore(t)
+	provider := &fakeComputeProvider{}
+
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	assertWorkspaceNotFound(t, putInstanceInWorkspace(t, store, provider, tenant, "typo"), provider)
+}
+
+func TestWorkspaceScopedPutRejectsSoftDeletedWorkspace(t *testing.T) {
+	store := testStore(t)
+	provider := &fakeComputeProvider{}
+	ctx := context.Background()
+
+	tenant := fmt.Sprintf("tenant-%d", time.Now().UnixNano())
+	if _, err := store.UpsertWorkspace(ctx, state.WorkspaceResource{
+		Tenant: tenant,
+		Name:   "ws",
+		Region: "fsn1",
+		Status: map[string]any{"state": "active"},
+	}); err != nil {
+		t.Fatalf("create workspace: %v", err)
+	}
+	if deleted, err := store.SoftDeleteWorkspace(ctx, tenant, "ws"); err != nil || !deleted {
+		t.Fatalf("soft-delete workspace: deleted=%t err=%v", deleted, err)
+	}
+
+	assertWorkspaceNotFound(t, putInstanceInWorkspace(t, store, provider, tenant, "ws"), provider)
+}
+
+func TestMatchWorkspaceRegionIgnoresCase(t *testing.T) {
+	t.Parallel()
+
+	regions, _ := fakeRegionProvider{}.ListRegions(context.Background())
+	if name, problem := matchWorkspaceRegion("FSN1", regions); problem != nil || name != "fsn1" {
+		t.Fatalf("expected FSN1 to match fsn1, got %q (%+v)", name, problem)
+	}
+	_, problem := matchWorkspaceRegion("mars1", regions)
+	if problem == nil || problem.source.Pointer != "/metadata/region" || !strings.Contains(problem.detail, "valid regions: fsn1") {
+		t.Fatalf("expected a problem listing the valid regions, got %+v", problem)
+	}
+}
+
+func TestRegionOverrideConflict(t *testing.T) {
+	t.Parallel()
+
+	for requested, wantConflict := range map[string]bool{
+		"":       false,
+		"fsn1":   false,
+		" FSN1 ": false,
+		"nbg1":   true,
+	} {
+		if got := regionOverrideConflict(requested, "fsn1") != ""; got != wantConflict {
+			t.Fatalf("%q: expected conflict %t, got %t", requested, wantConflict, got)
+		}
+	}
+}
+
+func TestProviderRefIDIgnoresNameOnlyRefs(t *testing.T) {
+	t.Parallel()
+
+	if id, ok := providerRefID(serverProviderRef(42, "vm1"), "servers"); !ok || id != 42 {
+		t.Fatalf("expected id 42, got %d %v", id, ok)
+	}
+	for _, ref := range []string{serverProviderRef(0, "vm1"), volumeProviderRef(42, "data"), "hetzner.cloud/servers/-3", ""} {
+		if id, ok := providerRefID(ref, "servers"); ok {
+			t.Fatalf("expected no server id in %q, got %d", ref, id)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package metrics exposes Prometheus metrics for the proxy: SECA HTTP
+// requests, Hetzner API calls, store query errors, rate-limited requests,
+// pending operations and the database connection pool.
+// A nil *Metrics records nothing, so callers need not check whether metrics
+// are enabled.
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "secapi_proxy"
+
+// operationsScrapeTimeout bounds the database query behind the operations
+// gauge so a slow database cannot stall a scrape.
+const operationsScrapeTimeout = 2 * time.Second
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	hetznerCalls    *prometheus.CounterVec
+	hetznerDuration *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+	rateLimited     *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "SECA API requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "SECA API request latency, by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		hetznerCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "hetzner_api_calls_total",
+			Help:      "Hetzner API calls, by operation and error code (ok on success).",
+		}, []string{"operation", "code"}),
+		hetznerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "hetzner_api_call_duration_seconds",
+			Help:      "Hetzner API call latency, by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_query_errors_total",
+			Help:      "Failed state store queries, by query name.",
+		}, []string{"query"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_requests_total",
+			Help:      "SECA API requests rejected by the per-tenant rate limit, by tenant and class (read or write).",
+		}, []string{"tenant", "class"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.hetznerCalls,
+		m.hetznerDuration,
+		m.storeErrors,
+		m.rateLimited,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) ObserveHetznerCall(operation, code string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.hetznerCalls.WithLabelValues(operation, code).Inc()
+	m.hetznerDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) ObserveStoreQueryError(query string) {
+	if m == nil {
+		return
+	}
+	m.storeErrors.WithLabelValues(query).Inc()
+}
+
+func (m *Metrics) ObserveRateLimited(tenant, class string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.WithLabelValues(tenant, class).Inc()
+}
+
+// WatchOperations registers a gauge of pending operations by phase, read
+// from count on every scrape.
+func (m *Metrics) WatchOperations(count func(ctx context.Context) (map[string]int64, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(operationsCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "operations_active"),
+			"Operations that have not reached a final phase, by phase.",
+			[]string{"phase"}, nil,
+		),
+	})
+}
+
+// WatchDBPool registers gauges of the database connection pool, read from
+// stats on every scrape.
+func (m *Metrics) WatchDBPool(stats func() (acquired, idle, total int32)) {
+	if m == nil {
+		return
+	}
+	for _, gauge := range []struct {
+		name, help string
+		value      func() float64
+	}{
+		{"db_pool_acquired_connections", "Database connections currently in use.", func() float64 { acquired, _, _ := stats(); return float64(acquired) }},
+		{"db_pool_idle_connections", "Idle database connections in the pool.", func() float64 { _, idle, _ := stats(); return float64(idle) }},
+		{"db_pool_total_connections", "Database connections open in the pool.", func() float64 { _, _, total := stats(); return float64(total) }},
+	} {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      gauge.name,
+			Help:      gauge.help,
+		}, gauge.value))
+	}
+}
+
+type operationsCollector struct {
+	count func(ctx context.Context) (map[string]int64, error)
+	desc  *prometheus.Desc
+}
+
+func (c operationsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c operationsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationsScrapeTimeout)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		log.Printf("metrics: count operations: %v", err)
+		return
+	}
+	for phase, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
@@ -0,0 +1,778 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func cloneInstance(instance *hetzner.Instance) *hetzner.Instance {
+	copied := *instance
+	copied.Labels = maps.Clone(instance.Labels)
+	copied.VolumeIDs = slices.Clone(instance.VolumeIDs)
+	return &copied
+}
+
+func instanceName(instance *hetzner.Instance) string { return instance.Name }
+
+func (p *Provider) ListInstances(context.Context) ([]hetzner.Instance, error) {
+	return p.ListInstancesByLabels(context.Background(), nil)
+}
+
+func (p *Provider) ListInstancesByLabels(_ context.Context, labels map[string]string) ([]hetzner.Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListInstances", labels); err != nil {
+		return nil, err
+	}
+	out := []hetzner.Instance{}
+	for _, instance := range sortedValues(p.instances, instanceName) {
+		if hasLabels(instance.Labels, labels) {
+			out = append(out, *cloneInstance(&instance))
+		}
+	}
+	return out, nil
+}
+
+func (p *Provider) GetInstance(_ context.Context, name string) (*hetzner.Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetInstance", name); err != nil {
+		return nil, err
+	}
+	instance, ok := p.instances[name]
+	if !ok {
+		return nil, nil
+	}
+	return cloneInstance(instance), nil
+}
+
+// SetInstanceLabel labels the server outside the API, the way a change in
+// the Hetzner console would.
+func (p *Provider) SetInstanceLabel(name, key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if instance, ok := p.instances[name]; ok {
+		if instance.Labels == nil {
+			instance.Labels = map[string]string{}
+		}
+		instance.Labels[key] = value
+	}
+}
+
+// RenameInstance renames the server outside the API, the way a change in
+// the Hetzner console would.
+func (p *Provider) RenameInstance(name, newName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if instance, ok := p.instances[name]; ok {
+		delete(p.instances, name)
+		instance.Name = newName
+		p.instances[newName] = instance
+	}
+}
+
+// checkInstanceCreate validates req and returns the instance it would leave
+// behind. The caller must hold p.mu.
+func (p *Provider) checkInstanceCreate(req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, false, invalidRequest("instance name is required")
+	}
+	if p.sku(req.SKUName) == nil {
+		return nil, false, invalidRequest("server type %q not found", req.SKUName)
+	}
+	if _, ok := p.placement[req.PlacementGroup]; req.PlacementGroup != "" && !ok {
+		return nil, false, notFound("placement group %q not found", req.PlacementGroup)
+	}
+	if existing, ok := p.instances[req.Name]; ok {
+		updated := cloneInstance(existing)
+		updated.SKUName = req.SKUName
+		if req.ImageName != "" {
+			updated.ImageName = req.ImageName
+		}
+		updated.Labels = maps.Clone(req.Labels)
+		updated.PlacementGroup = req.PlacementGroup
+		return updated, false, nil
+	}
+	if req.BootVolume != "" {
+		if _, ok := p.volumes[req.BootVolume]; !ok {
+			return nil, false, notFound("volume %q not found", req.BootVolume)
+		}
+	}
+	region := req.Region
+	if region == "" {
+		region = p.regions[0].Name
+	}
+	return &hetzner.Instance{
+		Name:           req.Name,
+		SKUName:        req.SKUName,
+		ImageName:      req.ImageName,
+		Region:         region,
+		Zone:           req.Zone,
+		PowerState:     "on",
+		Status:         "running",
+		Labels:         maps.Clone(req.Labels),
+		PlacementGroup: req.PlacementGroup,
+	}, true, nil
+}
+
+func (p *Provider) ValidateInstanceCreate(_ context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ValidateInstanceCreate", req); err != nil {
+		return nil, false, err
+	}
+	return p.checkInstanceCreate(req)
+}
+
+// CreateOrUpdateInstance creates a running server, or changes the server type,
+// image, labels and placement group of an existing one.
+func (p *Provider) CreateOrUpdateInstance(_ context.Context, req hetzner.InstanceCreateRequest) (*hetzner.Instance, bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdateInstance", req); err != nil {
+		return nil, false, "", err
+	}
+	instance, created, err := p.checkInstanceCreate(req)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if created {
+		instance.ID = p.newID()
+		instance.CreatedAt = p.createdAt(instance.ID)
+		if req.PublicNetwork == nil || req.PublicNetwork.IPv4 {
+			instance.PublicIPv4 = publicIPv4(instance.ID)
+		}
+		if req.BootVolume != "" {
+			volume := p.volumes[req.BootVolume]
+			volume.AttachedTo = instance.Name
+			instance.VolumeIDs = append(instance.VolumeIDs, volume.ID)
+		}
+	}
+	command := "change_server_type"
+	if previous, ok := p.instances[instance.Name]; ok && previous.PlacementGroup != instance.PlacementGroup {
+		command = "add_server_to_placement_group"
+	}
+	p.instances[instance.Name] = instance
+	p.placeInstance(instance)
+	if created {
+		command = "create_server"
+	}
+	return cloneInstance(instance), created, p.finishedAction(command), nil
+}
+
+// DeleteInstance deletes the server, detaching its volumes and networks.
+func (p *Provider) DeleteInstance(_ context.Context, name string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteInstance", name); err != nil {
+		return false, "", err
+	}
+	instance, ok := p.instances[name]
+	if !ok {
+		return false, "", nil
+	}
+	for _, volume := range p.volumes {
+		if volume.AttachedTo == name {
+			volume.AttachedTo = ""
+		}
+	}
+	for _, group := range p.securityGroups {
+		group.ServerIDs = slices.DeleteFunc(group.ServerIDs, func(id int64) bool { return id == instance.ID })
+	}
+	for _, group := range p.placement {
+		group.ServerIDs = slices.DeleteFunc(group.ServerIDs, func(id int64) bool { return id == instance.ID })
+	}
+	for _, ip := range p.publicIPs {
+		if ip.ServerID == instance.ID {
+			ip.ServerID = 0
+		}
+	}
+	for _, lb := range p.loadBalancers {
+		lb.Targets = slices.DeleteFunc(lb.Targets, func(target hetzner.LoadBalancerTarget) bool { return target.ServerID == instance.ID })
+	}
+	delete(p.attachments, name)
+	delete(p.instances, name)
+	return true, p.finishedAction("delete_server"), nil
+}
+
+// setStatus moves an existing server to status and reports whether it exists.
+// The caller must hold p.mu.
+func (p *Provider) setStatus(method, name, status string) (bool, string, error) {
+	if err := p.call(method, name); err != nil {
+		return false, "", err
+	}
+	instance, ok := p.instances[name]
+	if !ok {
+		return false, "", nil
+	}
+	instance.Status = status
+	instance.PowerState = "off"
+	if status == "running" {
+		instance.PowerState = "on"
+	}
+	return true, p.finishedAction(strings.ToLower(method)), nil
+}
+
+func (p *Provider) StartInstance(_ context.Context, name string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.setStatus("StartInstance", name, "running")
+}
+
+func (p *Provider) StopInstance(_ context.Context, name string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.setStatus("StopInstance", name, "off")
+}
+
+func (p *Provider) RestartInstance(_ context.Context, name string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.setStatus("RestartInstance", name, "running")
+}
+
+func (p *Provider) SetInstanceBackups(_ context.Context, name string, enabled bool) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("SetInstanceBackups", name, enabled); err != nil {
+		return false, "", err
+	}
+	instance, ok := p.instances[name]
+	if !ok {
+		return false, "", nil
+	}
+	instance.BackupWindow = ""
+	if enabled {
+		instance.BackupWindow = "22-02"
+	}
+	return true, p.finishedAction("enable_backup"), nil
+}
+
+// ServerTypeForRegion returns preferred when it is a known server type and
+// otherwise the first one of fallbackArchitecture.
+func (p *Provider) ServerTypeForRegion(_ context.Context, preferred, region, fallbackArchitecture string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ServerTypeForRegion", preferred, region, fallbackArchitecture); err != nil {
+		return "", err
+	}
+	if p.sku(preferred) != nil {
+		return preferred, nil
+	}
+	for _, sku := range p.skus {
+		if sku.Architecture == fallbackArchitecture {
+			return sku.Name, nil
+		}
+	}
+	return "", conflict("no %s server type is offered in region %q", fallbackArchitecture, region)
+}
+
+func (p *Provider) AttachInstanceToNetwork(ctx context.Context, instanceName, networkName string) (bool, string, error) {
+	return p.AttachInstanceToNetworkWithIP(ctx, instanceName, networkName, "")
+}
+
+// AttachInstanceToNetworkWithIP attaches the server to the network with ip, or
+// with the next free address of the network range when ip is empty.
+func (p *Provider) AttachInstanceToNetworkWithIP(_ context.Context, instanceName, networkName, ip string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("AttachInstanceToNetwork", instanceName, networkName, ip); err != nil {
+		return false, "", err
+	}
+	if _, ok := p.instances[instanceName]; !ok {
+		return false, "", notFound("instance %q not found", instanceName)
+	}
+	network, ok := p.networks[networkName]
+	if !ok {
+		return false, "", notFound("network %q not found", networkName)
+	}
+	if current, ok := p.attachments[instanceName][networkName]; ok {
+		if ip != "" && ip != current {
+			return false, "", invalidRequest("instance %q is already attached to network %q with %s", instanceName, networkName, current)
+		}
+		return true, "", nil
+	}
+	if ip == "" {
+		ip = p.nextPrivateIP(network)
+	}
+	if p.attachments[instanceName] == nil {
+		p.attachments[instanceName] = map[string]string{}
+	}
+	p.attachments[instanceName][networkName] = ip
+	return true, p.finishedAction("attach_to_network"), nil
+}
+
+// nextPrivateIP picks the lowest host address of the network range that no
+// server holds, starting at .2 like Hetzner. The caller must hold p.mu.
+func (p *Provider) nextPrivateIP(network *hetzner.Network) string {
+	prefix, err := netip.ParsePrefix(network.CIDR)
+	if err != nil {
+		return ""
+	}
+	used := map[string]bool{}
+	for _, networks := range p.attachments {
+		if ip, ok := networks[network.Name]; ok {
+			used[ip] = true
+		}
+	}
+	addr := prefix.Addr().Next().Next()
+	for prefix.Contains(addr) && used[addr.String()] {
+		addr = addr.Next()
+	}
+	return addr.String()
+}
+
+func (p *Provider) DetachInstanceFromNetwork(_ context.Context, instanceName, networkName string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DetachInstanceFromNetwork", instanceName, networkName); err != nil {
+		return false, err
+	}
+	if _, ok := p.attachments[instanceName][networkName]; !ok {
+		return false, nil
+	}
+	delete(p.attachments[instanceName], networkName)
+	return true, nil
+}
+
+// SyncInstanceNetworks attaches the server to exactly networkNames.
+func (p *Provider) SyncInstanceNetworks(ctx context.Context, instanceName string, networkNames []string) error {
+	p.mu.Lock()
+	if err := p.call("SyncInstanceNetworks", instanceName, slices.Clone(networkNames)); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	for networkName := range p.attachments[instanceName] {
+		if !slices.Contains(networkNames, networkName) {
+			delete(p.attachments[instanceName], networkName)
+		}
+	}
+	p.mu.Unlock()
+	for _, networkName := range networkNames {
+		if _, _, err := p.AttachInstanceToNetworkWithIP(ctx, instanceName, networkName, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Provider) GetInstancePrivateIPv4(_ context.Context, instanceName, networkName string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetInstancePrivateIPv4", instanceName, networkName); err != nil {
+		return "", err
+	}
+	return p.attachments[instanceName][networkName], nil
+}
+
+// SyncInstanceSecurityGroups applies securityGroupNames to the server and
+// removes the other groups managed reports as owned by the caller.
+func (p *Provider) SyncInstanceSecurityGroups(_ context.Context, instanceName string, securityGroupNames []string, managed func(hetzner.SecurityGroup) bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("SyncInstanceSecurityGroups", instanceName, slices.Clone(securityGroupNames)); err != nil {
+		return err
+	}
+	instance, ok := p.instances[instanceName]
+	if !ok {
+		return notFound("instance %q not found", instanceName)
+	}
+	for _, name := range securityGroupNames {
+		if _, ok := p.securityGroups[name]; !ok {
+			return notFound("security group %q not found", name)
+		}
+	}
+	for name, group := range p.securityGroups {
+		applied := slices.Contains(group.ServerIDs, instance.ID)
+		switch want := slices.Contains(securityGroupNames, name); {
+		case want && !applied:
+			group.ServerIDs = append(group.ServerIDs, instance.ID)
+		case !want && applied && managed(*group):
+			group.ServerIDs = slices.DeleteFunc(group.ServerIDs, func(id int64) bool { return id == instance.ID })
+		}
+	}
+	return nil
+}
+
+// GetInstanceMetrics returns the requested window without samples.
+func (p *Provider) GetInstanceMetrics(_ context.Context, req hetzner.InstanceMetricsRequest) (*hetzner.InstanceMetrics, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetInstanceMetrics", req); err != nil {
+		return nil, err
+	}
+	if _, ok := p.instances[req.Name]; !ok {
+		return nil, notFound("instance %q not found", req.Name)
+	}
+	return &hetzner.InstanceMetrics{Start: req.Start, End: req.End, StepSeconds: req.StepSeconds, Series: map[string][]hetzner.MetricSample{}}, nil
+}
+
+// Block storages.
+
+func cloneVolume(volume *hetzner.BlockStorage) *hetzner.BlockStorage {
+	copied := *volume
+	copied.Labels = maps.Clone(volume.Labels)
+	return &copied
+}
+
+func volumeName(volume *hetzner.BlockStorage) string { return volume.Name }
+
+func (p *Provider) ListBlockStorages(context.Context) ([]hetzner.BlockStorage, error) {
+	return p.ListBlockStoragesByLabels(context.Background(), nil)
+}
+
+func (p *Provider) ListBlockStoragesByLabels(_ context.Context, labels map[string]string) ([]hetzner.BlockStorage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListBlockStorages", labels); err != nil {
+		return nil, err
+	}
+	out := []hetzner.BlockStorage{}
+	for _, volume := range sortedValues(p.volumes, volumeName) {
+		if hasLabels(volume.Labels, labels) {
+			out = append(out, *cloneVolume(&volume))
+		}
+	}
+	return out, nil
+}
+
+func (p *Provider) GetBlockStorage(_ context.Context, name string) (*hetzner.BlockStorage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetBlockStorage", name); err != nil {
+		return nil, err
+	}
+	volume, ok := p.volumes[name]
+	if !ok {
+		return nil, nil
+	}
+	return cloneVolume(volume), nil
+}
+
+// SetBlockStorageLabel labels the volume outside the API, the way a change
+// in the Hetzner console would.
+func (p *Provider) SetBlockStorageLabel(name, key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if volume, ok := p.volumes[name]; ok {
+		if volume.Labels == nil {
+			volume.Labels = map[string]string{}
+		}
+		volume.Labels[key] = value
+	}
+}
+
+// checkBlockStorageCreate validates req and returns the volume it would leave
+// behind. The caller must hold p.mu.
+func (p *Provider) checkBlockStorageCreate(req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, error) {
+	if req.SizeGB < 10 {
+		return nil, false, invalidRequest("volume size must be at least 10 GB")
+	}
+	if req.AttachTo != "" {
+		if _, ok := p.instances[req.AttachTo]; !ok {
+			return nil, false, notFound("instance %q not found", req.AttachTo)
+		}
+	}
+	if existing, ok := p.volumes[req.Name]; ok {
+		if req.SizeGB < existing.SizeGB {
+			return nil, false, invalidRequest("volume %q cannot shrink from %d GB to %d GB", req.Name, existing.SizeGB, req.SizeGB)
+		}
+		updated := cloneVolume(existing)
+		updated.SizeGB = req.SizeGB
+		updated.Labels = maps.Clone(req.Labels)
+		return updated, false, nil
+	}
+	region := req.Region
+	if region == "" {
+		region = p.regions[0].Name
+	}
+	return &hetzner.BlockStorage{
+		Name:       req.Name,
+		SizeGB:     req.SizeGB,
+		Region:     region,
+		AttachedTo: req.AttachTo,
+		Status:     "available",
+		Labels:     maps.Clone(req.Labels),
+	}, true, nil
+}
+
+func (p *Provider) ValidateBlockStorageCreate(_ context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ValidateBlockStorageCreate", req); err != nil {
+		return nil, false, err
+	}
+	return p.checkBlockStorageCreate(req)
+}
+
+// CreateOrUpdateBlockStorage creates the volume, or grows and relabels an
+// existing one.
+func (p *Provider) CreateOrUpdateBlockStorage(_ context.Context, req hetzner.BlockStorageCreateRequest) (*hetzner.BlockStorage, bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdateBlockStorage", req); err != nil {
+		return nil, false, "", err
+	}
+	volume, created, err := p.checkBlockStorageCreate(req)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if created {
+		volume.ID = p.newID()
+		volume.CreatedAt = p.createdAt(volume.ID)
+		volume.DevicePath = fmt.Sprintf("/dev/disk/by-id/scsi-0HC_Volume_%d", volume.ID)
+		if instance, ok := p.instances[volume.AttachedTo]; ok {
+			instance.VolumeIDs = append(instance.VolumeIDs, volume.ID)
+		}
+	}
+	p.volumes[volume.Name] = volume
+	command := "resize_volume"
+	if created {
+		command = "create_volume"
+	}
+	return cloneVolume(volume), created, p.finishedAction(command), nil
+}
+
+// DeleteBlockStorage deletes the volume; an attached one is a conflict unless
+// force detaches it first.
+func (p *Provider) DeleteBlockStorage(_ context.Context, name string, force bool) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteBlockStorage", name, force); err != nil {
+		return false, err
+	}
+	volume, ok := p.volumes[name]
+	if !ok {
+		return false, nil
+	}
+	if volume.AttachedTo != "" {
+		if !force {
+			return false, conflict("volume is attached to instance %s, detach first", volume.AttachedTo)
+		}
+		p.detachVolume(volume)
+	}
+	delete(p.volumes, name)
+	return true, nil
+}
+
+func (p *Provider) AttachBlockStorage(_ context.Context, name, instanceName string, automount bool) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("AttachBlockStorage", name, instanceName, automount); err != nil {
+		return false, "", err
+	}
+	volume, ok := p.volumes[name]
+	if !ok {
+		return false, "", nil
+	}
+	instance, ok := p.instances[instanceName]
+	if !ok {
+		return false, "", notFound("instance %q not found", instanceName)
+	}
+	if volume.AttachedTo == instanceName {
+		return true, "", nil
+	}
+	if volume.AttachedTo != "" {
+		return false, "", conflict("volume is attached to instance %s, detach first", volume.AttachedTo)
+	}
+	volume.AttachedTo = instanceName
+	instance.VolumeIDs = append(instance.VolumeIDs, volume.ID)
+	return true, p.finishedAction("attach_volume"), nil
+}
+
+// DetachBlockStorage detaches the volume; a detached one is left alone and no
+// action is returned.
+func (p *Provider) DetachBlockStorage(_ context.Context, name string) (bool, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DetachBlockStorage", name); err != nil {
+		return false, "", err
+	}
+	volume, ok := p.volumes[name]
+	if !ok {
+		return false, "", nil
+	}
+	if volume.AttachedTo == "" {
+		return true, "", nil
+	}
+	p.detachVolume(volume)
+	return true, p.finishedAction("detach_volume"), nil
+}
+
+// detachVolume drops the volume from the server holding it. The caller must
+// hold p.mu.
+func (p *Provider) detachVolume(volume *hetzner.BlockStorage) {
+	if instance, ok := p.instances[volume.AttachedTo]; ok {
+		instance.VolumeIDs = slices.DeleteFunc(instance.VolumeIDs, func(id int64) bool { return id == volume.ID })
+	}
+	volume.AttachedTo = ""
+}
+
+// Image snapshots.
+
+// CreateImageSnapshot snapshots the instance or the server the block storage
+// is attached to. The snapshot is available right away.
+func (p *Provider) CreateImageSnapshot(_ context.Context, req hetzner.ImageSnapshotCreateRequest) (*hetzner.ImageSnapshot, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateImageSnapshot", req); err != nil {
+		return nil, "", err
+	}
+	instanceName := req.InstanceName
+	if req.BlockStorageName != "" {
+		volume, ok := p.volumes[req.BlockStorageName]
+		if !ok {
+			return nil, "", notFound("volume %q not found", req.BlockStorageName)
+		}
+		if volume.AttachedTo == "" {
+			return nil, "", conflict("volume %q is not attached to an instance", req.BlockStorageName)
+		}
+		instanceName = volume.AttachedTo
+	}
+	instance, ok := p.instances[instanceName]
+	if !ok {
+		return nil, "", notFound("instance %q not found", instanceName)
+	}
+	architecture := "x86"
+	if sku := p.sku(instance.SKUName); sku != nil {
+		architecture = sku.Architecture
+	}
+	id := p.newID()
+	snapshot := &hetzner.ImageSnapshot{
+		ID:           id,
+		Description:  req.Description,
+		Status:       "available",
+		Architecture: architecture,
+		Labels:       maps.Clone(req.Labels),
+		CreatedAt:    p.createdAt(id),
+	}
+	p.snapshots[id] = snapshot
+	copied := *snapshot
+	return &copied, p.finishedAction("create_image"), nil
+}
+
+func (p *Provider) FindImageSnapshot(_ context.Context, labels map[string]string) (*hetzner.ImageSnapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("FindImageSnapshot", labels); err != nil {
+		return nil, err
+	}
+	ids := slices.Sorted(maps.Keys(p.snapshots))
+	for _, id := range ids {
+		if snapshot := p.snapshots[id]; hasLabels(snapshot.Labels, labels) {
+			copied := *snapshot
+			copied.Labels = maps.Clone(snapshot.Labels)
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Provider) DeleteImageSnapshot(_ context.Context, id int64) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteImageSnapshot", id); err != nil {
+		return false, err
+	}
+	if _, ok := p.snapshots[id]; !ok {
+		return false, nil
+	}
+	delete(p.snapshots, id)
+	return true, nil
+}
+
+// Placement groups.
+
+func clonePlacementGroup(group *hetzner.PlacementGroup) *hetzner.PlacementGroup {
+	copied := *group
+	copied.Labels = maps.Clone(group.Labels)
+	copied.ServerIDs = slices.Clone(group.ServerIDs)
+	return &copied
+}
+
+func placementGroupName(group *hetzner.PlacementGroup) string { return group.Name }
+
+// placeInstance records instance as the only member of its placement group
+// among all groups. The caller must hold p.mu.
+func (p *Provider) placeInstance(instance *hetzner.Instance) {
+	for name, group := range p.placement {
+		group.ServerIDs = slices.DeleteFunc(group.ServerIDs, func(id int64) bool { return id == instance.ID })
+		if name == instance.PlacementGroup {
+			group.ServerIDs = append(group.ServerIDs, instance.ID)
+		}
+	}
+}
+
+func (p *Provider) ListPlacementGroups(context.Context) ([]hetzner.PlacementGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListPlacementGroups"); err != nil {
+		return nil, err
+	}
+	out := []hetzner.PlacementGroup{}
+	for _, group := range sortedValues(p.placement, placementGroupName) {
+		out = append(out, *clonePlacementGroup(&group))
+	}
+	return out, nil
+}
+
+func (p *Provider) GetPlacementGroup(_ context.Context, name string) (*hetzner.PlacementGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetPlacementGroup", name); err != nil {
+		return nil, err
+	}
+	group, ok := p.placement[name]
+	if !ok {
+		return nil, nil
+	}
+	return clonePlacementGroup(group), nil
+}
+
+func (p *Provider) CreateOrUpdatePlacementGroup(_ context.Context, req hetzner.PlacementGroupCreateRequest) (*hetzner.PlacementGroup, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdatePlacementGroup", req); err != nil {
+		return nil, false, err
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, false, invalidRequest("placement group name is required")
+	}
+	if existing, ok := p.placement[req.Name]; ok {
+		existing.Labels = maps.Clone(req.Labels)
+		return clonePlacementGroup(existing), false, nil
+	}
+	id := p.newID()
+	group := &hetzner.PlacementGroup{ID: id, Name: req.Name, Labels: maps.Clone(req.Labels), CreatedAt: p.createdAt(id)}
+	p.placement[req.Name] = group
+	return clonePlacementGroup(group), true, nil
+}
+
+// DeletePlacementGroup fails with a conflict while the group holds servers,
+// as Hetzner does.
+func (p *Provider) DeletePlacementGroup(_ context.Context, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeletePlacementGroup", name); err != nil {
+		return false, err
+	}
+	group, ok := p.placement[name]
+	if !ok {
+		return false, nil
+	}
+	if len(group.ServerIDs) > 0 {
+		return false, conflict("placement group %q still has servers", name)
+	}
+	delete(p.placement, name)
+	return true, nil
+}
+
+func publicIPv4(id int64) string {
+	return netip.AddrFrom4([4]byte{203, 0, 113, byte(id%254 + 1)}).String()
+}
@@ -0,0 +1,300 @@
+// Package fake is an in-memory stand-in for the Hetzner region service. It
+// implements the provider interfaces of the HTTP server and the reconcilers
+// with deterministic IDs, addresses and timestamps, records every call and
+// can be told to fail any method, so handlers can be tested and the proxy run
+// locally without a Hetzner project.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+// Epoch is the creation time of the first resource; every later resource is
+// created one second after the previous one.
+var Epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Call is one provider call with its arguments in declaration order, leaving
+// out the context.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Provider keeps instances, volumes, networks, firewalls, floating IPs, load
+// balancers and snapshots in memory. Every action it starts has already succeeded. The zero
+// value is not usable; call New.
+type Provider struct {
+	mu sync.Mutex
+
+	regions []hetzner.Region
+	skus    []hetzner.ComputeSKU
+	images  []hetzner.CatalogImage
+
+	nextID         int64
+	instances      map[string]*hetzner.Instance
+	volumes        map[string]*hetzner.BlockStorage
+	networks       map[string]*hetzner.Network
+	subnets        map[string][]string
+	routes         map[string]map[string]string
+	attachments    map[string]map[string]string
+	securityGroups map[string]*hetzner.SecurityGroup
+	publicIPs      map[string]*hetzner.PublicIP
+	placement      map[string]*hetzner.PlacementGroup
+	loadBalancers  map[string]*hetzner.LoadBalancer
+	snapshots      map[int64]*hetzner.ImageSnapshot
+	actions        map[int64]*hetzner.Action
+
+	calls []Call
+	fails map[string]error
+}
+
+// New returns a provider offering the regions fsn1 and nbg1, the server types
+// cx22 and cax11 and the images ubuntu-24.04 and debian-12, with no resources.
+func New() *Provider {
+	providers := []hetzner.Provider{{Name: "hetzner.cloud", Version: "v1", URL: "https://api.hetzner.cloud/v1"}}
+	return &Provider{
+		regions: []hetzner.Region{
+			{Name: "fsn1", City: "Falkenstein", Country: "DE", Zones: []string{"fsn1-dc14"}, NetworkZone: "eu-central", Architectures: []string{"arm", "x86"}, BlockStorage: true, Providers: providers},
+			{Name: "nbg1", City: "Nuremberg", Country: "DE", Zones: []string{"nbg1-dc3"}, NetworkZone: "eu-central", Architectures: []string{"arm", "x86"}, BlockStorage: true, Providers: providers},
+		},
+		skus: []hetzner.ComputeSKU{
+			{Name: "cax11", VCPU: 2, RAMGiB: 4, Architecture: "arm", DiskGB: 40},
+			{Name: "cx22", VCPU: 2, RAMGiB: 4, Architecture: "x86", DiskGB: 40},
+		},
+		images: []hetzner.CatalogImage{
+			{Name: "debian-12", Type: "system", Architecture: "x86", OSFlavor: "debian", OSVersion: "12", Description: "Debian 12", Status: "available", CreatedAt: Epoch},
+			{Name: "ubuntu-24.04", Type: "system", Architecture: "x86", OSFlavor: "ubuntu", OSVersion: "24.04", Description: "Ubuntu 24.04", Status: "available", CreatedAt: Epoch},
+		},
+		instances:      map[string]*hetzner.Instance{},
+		volumes:        map[string]*hetzner.BlockStorage{},
+		networks:       map[string]*hetzner.Network{},
+		subnets:        map[string][]string{},
+		routes:         map[string]map[string]string{},
+		attachments:    map[string]map[string]string{},
+		securityGroups: map[string]*hetzner.SecurityGroup{},
+		publicIPs:      map[string]*hetzner.PublicIP{},
+		placement:      map[string]*hetzner.PlacementGroup{},
+		loadBalancers:  map[string]*hetzner.LoadBalancer{},
+		snapshots:      map[int64]*hetzner.ImageSnapshot{},
+		actions:        map[int64]*hetzner.Action{},
+		fails:          map[string]error{},
+	}
+}
+
+// Fail makes every later call of method return err; a nil err clears it.
+func (p *Provider) Fail(method string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.fails, method)
+		return
+	}
+	p.fails[method] = err
+}
+
+// Calls returns the recorded calls of method, or of every method when method
+// is empty, oldest first.
+func (p *Provider) Calls(method string) []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := []Call{}
+	for _, call := range p.calls {
+		if method == "" || call.Method == method {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+// Called reports whether method was called at least once.
+func (p *Provider) Called(method string) bool {
+	return len(p.Calls(method)) > 0
+}
+
+// ResetCalls forgets the recorded calls but keeps the resources and failures.
+func (p *Provider) ResetCalls() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = nil
+}
+
+// call records a call and returns the failure programmed for it. The caller
+// must hold p.mu.
+func (p *Provider) call(method string, args ...any) error {
+	p.calls = append(p.calls, Call{Method: method, Args: args})
+	return p.fails[method]
+}
+
+// newID hands out the next resource ID. The caller must hold p.mu.
+func (p *Provider) newID() int64 {
+	p.nextID++
+	return p.nextID
+}
+
+func (p *Provider) createdAt(id int64) time.Time {
+	return Epoch.Add(time.Duration(id) * time.Second)
+}
+
+// finishedAction records an action that has already succeeded and returns its
+// ID. The caller must hold p.mu.
+func (p *Provider) finishedAction(command string) string {
+	id := p.newID()
+	p.actions[id] = &hetzner.Action{ID: id, Command: command, Status: hetzner.ActionStatusSuccess}
+	return strconv.FormatInt(id, 10)
+}
+
+func notFound(format string, args ...any) error {
+	return hetzner.ProviderError{Code: "not_found", Message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...any) error {
+	return hetzner.ProviderError{Code: "conflict", Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidRequest(format string, args ...any) error {
+	return hetzner.ProviderError{Code: "invalid_request", Message: fmt.Sprintf(format, args...)}
+}
+
+func hasLabels(have, want map[string]string) bool {
+	for key, value := range want {
+		if have[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedValues[T any](items map[string]*T, name func(*T) string) []T {
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		out = append(out, *item)
+	}
+	sort.Slice(out, func(i, j int) bool { return name(&out[i]) < name(&out[j]) })
+	return out
+}
+
+// RegionProvider and CatalogProvider.
+
+func (p *Provider) ListRegions(context.Context) ([]hetzner.Region, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListRegions"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(p.regions), nil
+}
+
+func (p *Provider) GetRegion(_ context.Context, name string) (*hetzner.Region, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetRegion", name); err != nil {
+		return nil, err
+	}
+	for _, region := range p.regions {
+		if region.Name == name {
+			return &region, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Provider) ListComputeSKUs(context.Context) ([]hetzner.ComputeSKU, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListComputeSKUs"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(p.skus), nil
+}
+
+func (p *Provider) GetComputeSKU(_ context.Context, name string) (*hetzner.ComputeSKU, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetComputeSKU", name); err != nil {
+		return nil, err
+	}
+	return p.sku(name), nil
+}
+
+func (p *Provider) sku(name string) *hetzner.ComputeSKU {
+	for _, sku := range p.skus {
+		if sku.Name == name {
+			return &sku
+		}
+	}
+	return nil
+}
+
+func (p *Provider) ListCatalogImages(context.Context) ([]hetzner.CatalogImage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListCatalogImages"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(p.images), nil
+}
+
+func (p *Provider) GetCatalogImage(_ context.Context, name, architecture string) (*hetzner.CatalogImage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetCatalogImage", name, architecture); err != nil {
+		return nil, err
+	}
+	return hetzner.PickCatalogImage(p.images, name, architecture), nil
+}
+
+func (p *Provider) GetVolumePricing(context.Context) (*hetzner.VolumePricing, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetVolumePricing"); err != nil {
+		return nil, err
+	}
+	return &hetzner.VolumePricing{Currency: "EUR", PerGBMonthlyNet: "0.0440", PerGBMonthlyGross: "0.0524"}, nil
+}
+
+// VolumeCapacityByLocation reports every region offering block storage as
+// available; the fake never runs out of space.
+func (p *Provider) VolumeCapacityByLocation(context.Context) ([]hetzner.VolumeCapacity, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("VolumeCapacityByLocation"); err != nil {
+		return nil, err
+	}
+	out := make([]hetzner.VolumeCapacity, 0, len(p.regions))
+	for _, region := range p.regions {
+		out = append(out, hetzner.VolumeCapacity{Region: region.Name, NetworkZone: region.NetworkZone, Available: region.BlockStorage})
+	}
+	return out, nil
+}
+
+// Actions.
+
+// GetAction returns the action with the given ID, or nil when this provider
+// did not start it.
+func (p *Provider) GetAction(_ context.Context, id int64) (*hetzner.Action, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetAction", id); err != nil {
+		return nil, err
+	}
+	action, ok := p.actions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *action
+	return &copied, nil
+}
+
+func (p *Provider) WaitForAction(_ context.Context, id int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.call("WaitForAction", id)
+}
@@ -0,0 +1,605 @@
+package fake
+
+import (
+	"context"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+)
+
+func cloneNetwork(network *hetzner.Network) *hetzner.Network {
+	copied := *network
+	copied.Labels = maps.Clone(network.Labels)
+	return &copied
+}
+
+func networkName(network *hetzner.Network) string { return network.Name }
+
+func (p *Provider) ListNetworks(context.Context) ([]hetzner.Network, error) {
+	return p.ListNetworksByLabels(context.Background(), nil)
+}
+
+func (p *Provider) ListNetworksByLabels(_ context.Context, labels map[string]string) ([]hetzner.Network, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListNetworks", labels); err != nil {
+		return nil, err
+	}
+	out := []hetzner.Network{}
+	for _, network := range sortedValues(p.networks, networkName) {
+		if hasLabels(network.Labels, labels) {
+			out = append(out, *cloneNetwork(&network))
+		}
+	}
+	return out, nil
+}
+
+func (p *Provider) GetNetwork(_ context.Context, name string) (*hetzner.Network, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetNetwork", name); err != nil {
+		return nil, err
+	}
+	network, ok := p.networks[name]
+	if !ok {
+		return nil, nil
+	}
+	return cloneNetwork(network), nil
+}
+
+// checkNetworkCreate validates req and returns the network it would leave
+// behind. The caller must hold p.mu.
+func (p *Provider) checkNetworkCreate(req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error) {
+	if _, err := netip.ParsePrefix(req.CIDR); err != nil {
+		return nil, false, invalidRequest("invalid network range %q", req.CIDR)
+	}
+	if existing, ok := p.networks[req.Name]; ok {
+		if existing.CIDR != req.CIDR {
+			return nil, false, conflict("network %q already uses range %s", req.Name, existing.CIDR)
+		}
+		updated := cloneNetwork(existing)
+		updated.Labels = maps.Clone(req.Labels)
+		return updated, false, nil
+	}
+	if _, ipRange, err := net.ParseCIDR(req.CIDR); err == nil {
+		if problem := hetzner.NetworkRangeProblem(ipRange); problem != "" {
+			return nil, false, invalidRequest("%s", problem)
+		}
+	}
+	return &hetzner.Network{Name: req.Name, CIDR: req.CIDR, Zone: "eu-central", Labels: maps.Clone(req.Labels)}, true, nil
+}
+
+func (p *Provider) ValidateNetworkCreate(_ context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ValidateNetworkCreate", req); err != nil {
+		return nil, false, err
+	}
+	return p.checkNetworkCreate(req)
+}
+
+// CreateOrUpdateNetwork creates the network or relabels an existing one. The
+// range of an existing network cannot change.
+func (p *Provider) CreateOrUpdateNetwork(_ context.Context, req hetzner.NetworkCreateRequest) (*hetzner.Network, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdateNetwork", req); err != nil {
+		return nil, false, err
+	}
+	network, created, err := p.checkNetworkCreate(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		network.ID = p.newID()
+		network.CreatedAt = p.createdAt(network.ID)
+	}
+	p.networks[network.Name] = network
+	return cloneNetwork(network), created, nil
+}
+
+// DeleteNetwork deletes the network with its subnets and routes; it is a
+// conflict while a server is attached.
+func (p *Provider) DeleteNetwork(_ context.Context, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteNetwork", name); err != nil {
+		return false, err
+	}
+	if _, ok := p.networks[name]; !ok {
+		return false, nil
+	}
+	for instance, networks := range p.attachments {
+		if _, ok := networks[name]; ok {
+			return false, conflict("network %q is still attached to instance %s", name, instance)
+		}
+	}
+	delete(p.networks, name)
+	delete(p.subnets, name)
+	delete(p.routes, name)
+	return true, nil
+}
+
+func (p *Provider) UpsertNetworkRoute(_ context.Context, networkName, destinationCIDR, gatewayIP string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("UpsertNetworkRoute", networkName, destinationCIDR, gatewayIP); err != nil {
+		return err
+	}
+	if _, ok := p.networks[networkName]; !ok {
+		return notFound("network %q not found", networkName)
+	}
+	if p.routes[networkName] == nil {
+		p.routes[networkName] = map[string]string{}
+	}
+	p.routes[networkName][destinationCIDR] = gatewayIP
+	return nil
+}
+
+func (p *Provider) DeleteNetworkRoute(_ context.Context, networkName, destinationCIDR string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteNetworkRoute", networkName, destinationCIDR); err != nil {
+		return err
+	}
+	delete(p.routes[networkName], destinationCIDR)
+	return nil
+}
+
+// Routes returns the routes of the network keyed by destination range.
+func (p *Provider) Routes(networkName string) map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.routes[networkName])
+}
+
+// checkSubnet validates req against the network range. The caller must hold
+// p.mu.
+func (p *Provider) checkSubnet(req hetzner.NetworkSubnetRequest) error {
+	network, ok := p.networks[req.NetworkName]
+	if !ok {
+		return notFound("network %q not found", req.NetworkName)
+	}
+	subnet, err := netip.ParsePrefix(req.CIDR)
+	if err != nil {
+		return invalidRequest("invalid subnet range %q", req.CIDR)
+	}
+	parent, err := netip.ParsePrefix(network.CIDR)
+	if err != nil || !parent.Contains(subnet.Addr()) || subnet.Bits() < parent.Bits() {
+		return invalidRequest("subnet %s is outside network range %s", req.CIDR, network.CIDR)
+	}
+	return nil
+}
+
+func (p *Provider) ValidateSubnetCreate(_ context.Context, req hetzner.NetworkSubnetRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ValidateSubnetCreate", req); err != nil {
+		return err
+	}
+	return p.checkSubnet(req)
+}
+
+func (p *Provider) AddSubnet(_ context.Context, req hetzner.NetworkSubnetRequest) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("AddSubnet", req); err != nil {
+		return false, err
+	}
+	if err := p.checkSubnet(req); err != nil {
+		return false, err
+	}
+	if slices.Contains(p.subnets[req.NetworkName], req.CIDR) {
+		return false, nil
+	}
+	p.subnets[req.NetworkName] = append(p.subnets[req.NetworkName], req.CIDR)
+	return true, nil
+}
+
+func (p *Provider) RemoveSubnet(_ context.Context, networkName, cidr string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("RemoveSubnet", networkName, cidr); err != nil {
+		return false, err
+	}
+	subnets := p.subnets[networkName]
+	if !slices.Contains(subnets, cidr) {
+		return false, nil
+	}
+	p.subnets[networkName] = slices.DeleteFunc(subnets, func(s string) bool { return s == cidr })
+	return true, nil
+}
+
+// Subnets returns the subnet ranges of the network in the order they were
+// added.
+func (p *Provider) Subnets(networkName string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.subnets[networkName])
+}
+
+// Security groups.
+
+func cloneSecurityGroup(group *hetzner.SecurityGroup) *hetzner.SecurityGroup {
+	copied := *group
+	copied.Labels = maps.Clone(group.Labels)
+	copied.Rules = slices.Clone(group.Rules)
+	copied.ServerIDs = slices.Clone(group.ServerIDs)
+	return &copied
+}
+
+func securityGroupName(group *hetzner.SecurityGroup) string { return group.Name }
+
+func (p *Provider) ListSecurityGroups(context.Context) ([]hetzner.SecurityGroup, error) {
+	return p.ListSecurityGroupsByLabels(context.Background(), nil)
+}
+
+func (p *Provider) ListSecurityGroupsByLabels(_ context.Context, labels map[string]string) ([]hetzner.SecurityGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListSecurityGroups", labels); err != nil {
+		return nil, err
+	}
+	out := []hetzner.SecurityGroup{}
+	for _, group := range sortedValues(p.securityGroups, securityGroupName) {
+		if hasLabels(group.Labels, labels) {
+			out = append(out, *cloneSecurityGroup(&group))
+		}
+	}
+	return out, nil
+}
+
+func (p *Provider) GetSecurityGroup(_ context.Context, name string) (*hetzner.SecurityGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetSecurityGroup", name); err != nil {
+		return nil, err
+	}
+	group, ok := p.securityGroups[name]
+	if !ok {
+		return nil, nil
+	}
+	return cloneSecurityGroup(group), nil
+}
+
+func (p *Provider) GetSecurityGroupByID(_ context.Context, id int64) (*hetzner.SecurityGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetSecurityGroupByID", id); err != nil {
+		return nil, err
+	}
+	for _, group := range p.securityGroups {
+		if group.ID == id {
+			return cloneSecurityGroup(group), nil
+		}
+	}
+	return nil, nil
+}
+
+// ManageSecurityGroup renames and relabels the firewall with the given ID and
+// keeps its rules.
+func (p *Provider) ManageSecurityGroup(_ context.Context, id int64, name string, labels map[string]string) (*hetzner.SecurityGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ManageSecurityGroup", id, name, labels); err != nil {
+		return nil, err
+	}
+	for current, group := range p.securityGroups {
+		if group.ID != id {
+			continue
+		}
+		if other, ok := p.securityGroups[name]; ok && other.ID != id {
+			return nil, conflict("security group %q already exists", name)
+		}
+		delete(p.securityGroups, current)
+		group.Name = name
+		group.Labels = maps.Clone(labels)
+		p.securityGroups[name] = group
+		return cloneSecurityGroup(group), nil
+	}
+	return nil, notFound("security group %d not found", id)
+}
+
+// checkSecurityGroupCreate validates req and returns the firewall it would
+// leave behind. The caller must hold p.mu.
+func (p *Provider) checkSecurityGroupCreate(req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error) {
+	for _, rule := range req.Rules {
+		if direction := strings.ToLower(rule.Direction); direction != "in" && direction != "out" {
+			return nil, false, invalidRequest("invalid rule direction %q", rule.Direction)
+		}
+	}
+	if existing, ok := p.securityGroups[req.Name]; ok {
+		updated := cloneSecurityGroup(existing)
+		updated.Labels = maps.Clone(req.Labels)
+		updated.Rules = slices.Clone(req.Rules)
+		return updated, false, nil
+	}
+	return &hetzner.SecurityGroup{Name: req.Name, Labels: maps.Clone(req.Labels), Rules: slices.Clone(req.Rules)}, true, nil
+}
+
+func (p *Provider) ValidateSecurityGroupCreate(_ context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ValidateSecurityGroupCreate", req); err != nil {
+		return nil, false, err
+	}
+	return p.checkSecurityGroupCreate(req)
+}
+
+// CreateOrUpdateSecurityGroup creates the firewall, or replaces the rules and
+// labels of an existing one.
+func (p *Provider) CreateOrUpdateSecurityGroup(_ context.Context, req hetzner.SecurityGroupCreateRequest) (*hetzner.SecurityGroup, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdateSecurityGroup", req); err != nil {
+		return nil, false, err
+	}
+	group, created, err := p.checkSecurityGroupCreate(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		group.ID = p.newID()
+		group.CreatedAt = p.createdAt(group.ID)
+	}
+	p.securityGroups[group.Name] = group
+	return cloneSecurityGroup(group), created, nil
+}
+
+// DeleteSecurityGroup deletes the firewall; it is a conflict while it is
+// applied to a server.
+func (p *Provider) DeleteSecurityGroup(_ context.Context, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteSecurityGroup", name); err != nil {
+		return false, err
+	}
+	group, ok := p.securityGroups[name]
+	if !ok {
+		return false, nil
+	}
+	if len(group.ServerIDs) > 0 {
+		return false, conflict("security group %q is still applied to %d servers", name, len(group.ServerIDs))
+	}
+	delete(p.securityGroups, name)
+	return true, nil
+}
+
+// Public IPs.
+
+func clonePublicIP(ip *hetzner.PublicIP) *hetzner.PublicIP {
+	copied := *ip
+	copied.Labels = maps.Clone(ip.Labels)
+	return &copied
+}
+
+func publicIPName(ip *hetzner.PublicIP) string { return ip.Name }
+
+func (p *Provider) ListPublicIPs(context.Context) ([]hetzner.PublicIP, error) {
+	return p.ListPublicIPsByLabels(context.Background(), nil)
+}
+
+func (p *Provider) ListPublicIPsByLabels(_ context.Context, labels map[string]string) ([]hetzner.PublicIP, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListPublicIPs", labels); err != nil {
+		return nil, err
+	}
+	out := []hetzner.PublicIP{}
+	for _, ip := range sortedValues(p.publicIPs, publicIPName) {
+		if hasLabels(ip.Labels, labels) {
+			out = append(out, *clonePublicIP(&ip))
+		}
+	}
+	return out, nil
+}
+
+func (p *Provider) GetPublicIP(_ context.Context, name string) (*hetzner.PublicIP, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetPublicIP", name); err != nil {
+		return nil, err
+	}
+	ip, ok := p.publicIPs[name]
+	if !ok {
+		return nil, nil
+	}
+	return clonePublicIP(ip), nil
+}
+
+// CreateOrUpdatePublicIP allocates an address from the documentation ranges,
+// or relabels an existing floating IP. Its version and region cannot change.
+// Like the conformance location fallback, a region that is not offered gets
+// an address in the first region.
+func (p *Provider) CreateOrUpdatePublicIP(_ context.Context, req hetzner.PublicIPCreateRequest) (*hetzner.PublicIP, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdatePublicIP", req); err != nil {
+		return nil, false, err
+	}
+	var version string
+	switch strings.ToLower(strings.TrimSpace(req.Version)) {
+	case "ipv4", "v4", "4":
+		version = "IPv4"
+	case "ipv6", "v6", "6":
+		version = "IPv6"
+	default:
+		return nil, false, invalidRequest("unsupported public ip version %q", req.Version)
+	}
+	if existing, ok := p.publicIPs[req.Name]; ok {
+		if existing.Version != version {
+			return nil, false, conflict("public ip %q is %s", req.Name, existing.Version)
+		}
+		existing.Labels = maps.Clone(req.Labels)
+		return clonePublicIP(existing), false, nil
+	}
+	region := p.regions[0].Name
+	for _, offered := range p.regions {
+		if strings.EqualFold(offered.Name, req.Region) {
+			region = offered.Name
+		}
+	}
+	id := p.newID()
+	address := publicIPv4(id)
+	if version == "IPv6" {
+		address = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: byte(id)}).String()
+	}
+	ip := &hetzner.PublicIP{ID: id, Name: req.Name, Version: version, Address: address, Region: region, Labels: maps.Clone(req.Labels), CreatedAt: p.createdAt(id)}
+	p.publicIPs[req.Name] = ip
+	return clonePublicIP(ip), true, nil
+}
+
+func (p *Provider) DeletePublicIP(_ context.Context, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeletePublicIP", name); err != nil {
+		return false, err
+	}
+	if _, ok := p.publicIPs[name]; !ok {
+		return false, nil
+	}
+	delete(p.publicIPs, name)
+	return true, nil
+}
+
+// AssignPublicIP routes the floating IP to the instance, or fails with a
+// conflict while another instance holds it.
+func (p *Provider) AssignPublicIP(_ context.Context, name, instanceName string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("AssignPublicIP", name, instanceName); err != nil {
+		return err
+	}
+	ip, ok := p.publicIPs[name]
+	if !ok {
+		return notFound("public ip %q not found", name)
+	}
+	instance, ok := p.instances[instanceName]
+	if !ok {
+		return notFound("instance %q not found", instanceName)
+	}
+	if ip.ServerID != 0 && ip.ServerID != instance.ID {
+		return conflict("public ip %q is assigned to server %d", name, ip.ServerID)
+	}
+	ip.ServerID = instance.ID
+	return nil
+}
+
+func (p *Provider) UnassignPublicIP(_ context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("UnassignPublicIP", name); err != nil {
+		return err
+	}
+	if ip, ok := p.publicIPs[name]; ok {
+		ip.ServerID = 0
+	}
+	return nil
+}
+
+// Load balancers.
+
+func loadBalancerName(lb *hetzner.LoadBalancer) string { return lb.Name }
+
+// describeLoadBalancer copies lb and reports a target as healthy while its
+// instance is running, like a health check against a listening server would.
+// The caller must hold p.mu.
+func (p *Provider) describeLoadBalancer(lb *hetzner.LoadBalancer) *hetzner.LoadBalancer {
+	copied := *lb
+	copied.Labels = maps.Clone(lb.Labels)
+	copied.Services = slices.Clone(lb.Services)
+	copied.Targets = slices.Clone(lb.Targets)
+	for i, target := range copied.Targets {
+		copied.Targets[i].Health = "unhealthy"
+		if instance, ok := p.instances[target.ServerName]; ok && instance.Status == "running" {
+			copied.Targets[i].Health = "healthy"
+		}
+	}
+	return &copied
+}
+
+func (p *Provider) ListLoadBalancers(context.Context) ([]hetzner.LoadBalancer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("ListLoadBalancers"); err != nil {
+		return nil, err
+	}
+	out := []hetzner.LoadBalancer{}
+	for _, lb := range sortedValues(p.loadBalancers, loadBalancerName) {
+		out = append(out, *p.describeLoadBalancer(&lb))
+	}
+	return out, nil
+}
+
+func (p *Provider) GetLoadBalancer(_ context.Context, name string) (*hetzner.LoadBalancer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("GetLoadBalancer", name); err != nil {
+		return nil, err
+	}
+	lb, ok := p.loadBalancers[name]
+	if !ok {
+		return nil, nil
+	}
+	return p.describeLoadBalancer(lb), nil
+}
+
+// CreateOrUpdateLoadBalancer creates a load balancer or converges an existing
+// one on req. Targets naming no instance are skipped, as Hetzner would.
+func (p *Provider) CreateOrUpdateLoadBalancer(_ context.Context, req hetzner.LoadBalancerCreateRequest) (*hetzner.LoadBalancer, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("CreateOrUpdateLoadBalancer", req); err != nil {
+		return nil, false, err
+	}
+	if req.Network != "" {
+		if _, ok := p.networks[req.Network]; !ok {
+			return nil, false, notFound("network %q not found", req.Network)
+		}
+	}
+	lb, ok := p.loadBalancers[req.Name]
+	created := !ok
+	if created {
+		if req.Region == "" {
+			return nil, false, invalidRequest("load balancer region is required")
+		}
+		id := p.newID()
+		lb = &hetzner.LoadBalancer{
+			ID:         id,
+			Name:       req.Name,
+			Region:     req.Region,
+			PublicIPv4: publicIPv4(id),
+			PublicIPv6: netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: byte(id)}).String(),
+			CreatedAt:  p.createdAt(id),
+		}
+		p.loadBalancers[req.Name] = lb
+	}
+	lb.Labels = maps.Clone(req.Labels)
+	lb.Network = req.Network
+	lb.Services = slices.Clone(req.Services)
+	lb.Targets = nil
+	for _, name := range req.Targets {
+		if instance, ok := p.instances[name]; ok {
+			lb.Targets = append(lb.Targets, hetzner.LoadBalancerTarget{ServerID: instance.ID, ServerName: name})
+		}
+	}
+	slices.SortFunc(lb.Targets, func(a, b hetzner.LoadBalancerTarget) int { return strings.Compare(a.ServerName, b.ServerName) })
+	return p.describeLoadBalancer(lb), created, nil
+}
+
+func (p *Provider) DeleteLoadBalancer(_ context.Context, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call("DeleteLoadBalancer", name); err != nil {
+		return false, err
+	}
+	if _, ok := p.loadBalancers[name]; !ok {
+		return false, nil
+	}
+	delete(p.loadBalancers, name)
+	return true, nil
+}
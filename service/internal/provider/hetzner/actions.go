@@ -0,0 +1,59 @@
+package hetzner
+
+import (
+	"context"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	ActionStatusRunning = "running"
+	ActionStatusSuccess = "success"
+	ActionStatusError   = "error"
+)
+
+// Action is the progress of an asynchronous Hetzner action.
+type Action struct {
+	ID           int64
+	Command      string
+	Status       string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// GetAction returns the action with the given ID, or nil when the project
+// behind ctx does not know it.
+func (s *RegionService) GetAction(ctx context.Context, id int64) (*Action, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	action, _, err := s.clientFor(ctx).Action.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if action == nil {
+		return nil, nil
+	}
+	return actionFromHCloud(action), nil
+}
+
+// WaitForAction blocks until the action finishes and returns its error when
+// it failed. It gives up with the context error once ctx is done, with
+// ErrTimeout after the configured action wait, or with ErrShuttingDown when
+// shutdown stops waiting.
+func (s *RegionService) WaitForAction(ctx context.Context, id int64) error {
+	if !s.configuredFor(ctx) {
+		return ErrNotConfigured
+	}
+	return s.waitForActions(ctx, &hcloud.Action{ID: id, Status: hcloud.ActionStatusRunning})
+}
+
+func actionFromHCloud(action *hcloud.Action) *Action {
+	return &Action{
+		ID:           action.ID,
+		Command:      action.Command,
+		Status:       string(action.Status),
+		ErrorCode:    action.ErrorCode,
+		ErrorMessage: action.ErrorMessage,
+	}
+}
@@ -0,0 +1,167 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+)
+
+// auditWriteTimeout bounds how long recording one audit entry may hold up the
+// Hetzner call it describes.
+const auditWriteTimeout = 5 * time.Second
+
+// AuditEntry describes one mutating call sent to the Hetzner API.
+type AuditEntry struct {
+	Time       time.Time
+	Tenant     string
+	Workspace  string
+	Kind       string
+	Operation  string
+	Resource   string
+	ActionID   string
+	StatusCode int
+	Error      string
+}
+
+// AuditRecorder persists audit entries. Recording is best effort: a failure
+// is logged and never fails the Hetzner call.
+type AuditRecorder interface {
+	RecordHetznerCall(ctx context.Context, entry AuditEntry) error
+}
+
+type auditScopeContextKey struct{}
+
+type auditScope struct {
+	tenant    string
+	workspace string
+}
+
+// WithAuditScope attributes the Hetzner calls made with ctx to the workspace.
+func WithAuditScope(ctx context.Context, tenant, workspace string) context.Context {
+	return context.WithValue(ctx, auditScopeContextKey{}, auditScope{tenant: tenant, workspace: workspace})
+}
+
+// auditTransport records every request that is not a read. Reads are left
+// out: they change nothing and would drown the log.
+type auditTransport struct {
+	base     http.RoundTripper
+	recorder AuditRecorder
+	now      func() time.Time
+}
+
+func auditCalls(base http.RoundTripper, recorder AuditRecorder) http.RoundTripper {
+	if recorder == nil {
+		return base
+	}
+	return auditTransport{base: base, recorder: recorder, now: time.Now}
+}
+
+func (t auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	entry := AuditEntry{
+		Time:      t.now().UTC(),
+		Kind:      callKind(req),
+		Operation: callOperation(req),
+		Resource:  callResource(req),
+	}
+	if scope, ok := req.Context().Value(auditScopeContextKey{}).(auditScope); ok {
+		entry.Tenant = scope.tenant
+		entry.Workspace = scope.workspace
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.StatusCode = resp.StatusCode
+		entry.ActionID, entry.Error = peekActionResult(resp)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), auditWriteTimeout)
+	defer cancel()
+	if recordErr := t.recorder.RecordHetznerCall(ctx, entry); recordErr != nil {
+		tracing.Logf(ctx, "hetzner audit: recording %s failed: %v", entry.Operation, recordErr)
+	}
+	return resp, err
+}
+
+// callKind is the Hetzner resource collection a request targets, e.g.
+// "servers" or "firewalls".
+func callKind(req *http.Request) string {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(segments) > 0 && isAPIVersion(segments[0]) {
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[0]
+}
+
+// callResource names the resource a request acts on: the name sent when one
+// is created, otherwise its ID from the path.
+func callResource(req *http.Request) string {
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			var payload struct {
+				Name string `json:"name"`
+			}
+			raw, _ := io.ReadAll(io.LimitReader(body, maxErrorBodyPeek))
+			_ = body.Close()
+			if json.Unmarshal(raw, &payload) == nil && payload.Name != "" {
+				return payload.Name
+			}
+		}
+	}
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for _, segment := range segments {
+		if isNumeric(segment) {
+			return segment
+		}
+	}
+	return ""
+}
+
+// peekActionResult reads the action ID of a successful response, or the
+// error code of a failed one. The body is restored for hcloud to decode.
+func peekActionResult(resp *http.Response) (actionID, errorCode string) {
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", responseErrorCode(resp)
+	}
+	if resp.Body == nil {
+		return "", ""
+	}
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return "", ""
+	}
+	var body struct {
+		Action *struct {
+			ID int64 `json:"id"`
+		} `json:"action"`
+		Actions []struct {
+			ID int64 `json:"id"`
+		} `json:"actions"`
+	}
+	if json.Unmarshal(peek, &body) != nil {
+		return "", ""
+	}
+	if body.Action != nil && body.Action.ID != 0 {
+		return strconv.FormatInt(body.Action.ID, 10), ""
+	}
+	if len(body.Actions) > 0 {
+		return strconv.FormatInt(body.Actions[0].ID, 10), ""
+	}
+	return "", ""
+}
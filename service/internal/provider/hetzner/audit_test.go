@@ -0,0 +1,77 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type auditLog struct {
+	entries []AuditEntry
+	err     error
+}
+
+func (l *auditLog) RecordHetznerCall(_ context.Context, entry AuditEntry) error {
+	l.entries = append(l.entries, entry)
+	return l.err
+}
+
+func TestAuditTransportRecordsMutationsOnly(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/servers":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"server":{"id":42,"name":"web-1"},"action":{"id":7001}}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusConflict)
+			_, _ = io.WriteString(w, `{"error":{"code":"conflict","message":"busy"}}`)
+		default:
+			_, _ = io.WriteString(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	log := &auditLog{err: errors.New("store down")}
+	client := &http.Client{Transport: auditCalls(http.DefaultTransport, log)}
+	ctx := WithAuditScope(context.Background(), "tenant-1", "ws-1")
+
+	do := func(method, path, body string) string {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		return string(raw)
+	}
+
+	do(http.MethodGet, "/v1/servers/42", "")
+	if body := do(http.MethodPost, "/v1/servers", `{"name":"web-1","server_type":"cx22"}`); !strings.Contains(body, `"web-1"`) {
+		t.Fatalf("expected response body to stay readable after inspection, got %q", body)
+	}
+	do(http.MethodDelete, "/v1/volumes/9", "")
+
+	if len(log.entries) != 2 {
+		t.Fatalf("expected 2 audit entries (reads are not audited), got %+v", log.entries)
+	}
+	created := log.entries[0]
+	if created.Tenant != "tenant-1" || created.Workspace != "ws-1" || created.Kind != "servers" || created.Operation != "POST /servers" || created.Resource != "web-1" || created.ActionID != "7001" || created.StatusCode != http.StatusCreated || created.Error != "" {
+		t.Fatalf("unexpected create entry: %+v", created)
+	}
+	deleted := log.entries[1]
+	if deleted.Kind != "volumes" || deleted.Operation != "DELETE /volumes/{id}" || deleted.Resource != "9" || deleted.Error != "conflict" || deleted.StatusCode != http.StatusConflict {
+		t.Fatalf("unexpected delete entry: %+v", deleted)
+	}
+}
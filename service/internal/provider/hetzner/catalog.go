@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
@@ -15,14 +16,40 @@ type ComputeSKU struct {
 	VCPU         int
 	RAMGiB       int
 	Architecture string
+	DiskGB       int
+	Deprecated   bool
+	Prices       []SKUPrice
+}
+
+// SKUPrice is what a server type costs in one location. Amounts are decimal
+// strings as Hetzner reports them.
+type SKUPrice struct {
+	Location             string
+	Currency             string
+	HourlyNet            string
+	HourlyGross          string
+	MonthlyNet           string
+	MonthlyGross         string
+	IncludedTrafficBytes uint64
+}
+
+// VolumePricing is the monthly price of one GB of block storage.
+type VolumePricing struct {
+	Currency          string
+	PerGBMonthlyNet   string
+	PerGBMonthlyGross string
 }
 
 type CatalogImage struct {
 	Name         string
 	Type         string
 	Architecture string
+	OSFlavor     string
+	OSVersion    string
 	Description  string
 	Status       string
+	Labels       map[string]string
+	CreatedAt    time.Time
 }
 
 type preferredRegionContextKey struct{}
@@ -78,6 +105,9 @@ func (s *RegionService) ListComputeSKUs(ctx context.Context) ([]ComputeSKU, erro
 			VCPU:         st.Cores,
 			RAMGiB:       int(st.Memory),
 			Architecture: string(st.Architecture),
+			DiskGB:       st.Disk,
+			Deprecated:   serverTypeDeprecated(st),
+			Prices:       serverTypePrices(st),
 		})
 	}
 
@@ -99,7 +129,7 @@ func (s *RegionService) GetComputeSKU(ctx context.Context, name string) (*Comput
 }
 
 func (s *RegionService) ListCatalogImages(ctx context.Context) ([]CatalogImage, error) {
-	images, err := s.clientFor(ctx).Image.AllWithOpts(ctx, hcloud.ImageListOpts{IncludeDeprecated: true})
+	images, err := s.listImages(ctx)
 	if err != nil {
 		if _, hasWorkspaceCred := workspaceCredentialFromContext(ctx); !hasWorkspaceCred && shouldUseStaticCatalogFallback(err) {
 			return s.staticCatalogImages(), nil
@@ -117,32 +147,56 @@ func (s *RegionService) ListCatalogImages(ctx context.Context) ([]CatalogImage,
 			Name:         name,
 			Type:         string(image.Type),
 			Architecture: string(image.Architecture),
+			OSFlavor:     image.OSFlavor,
+			OSVersion:    image.OSVersion,
 			Description:  image.Description,
 			Status:       string(image.Status),
+			Labels:       image.Labels,
+			CreatedAt:    image.Created,
 		})
 	}
 
 	sort.Slice(out, func(i, j int) bool {
-		if out[i].Name == out[j].Name {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		if out[i].Type != out[j].Type {
 			return out[i].Type < out[j].Type
 		}
-		return out[i].Name < out[j].Name
+		return out[i].Architecture < out[j].Architecture
 	})
 	return out, nil
 }
 
-func (s *RegionService) GetCatalogImage(ctx context.Context, name string) (*CatalogImage, error) {
+// GetCatalogImage returns the catalog image called name. Hetzner publishes
+// most system images once per architecture under the same name, so an
+// image of architecture ("x86" or "arm") is preferred when one exists; an
+// empty architecture takes the first match.
+func (s *RegionService) GetCatalogImage(ctx context.Context, name, architecture string) (*CatalogImage, error) {
 	images, err := s.ListCatalogImages(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return PickCatalogImage(images, name, architecture), nil
+}
+
+// PickCatalogImage implements the lookup of GetCatalogImage over images.
+func PickCatalogImage(images []CatalogImage, name, architecture string) *CatalogImage {
+	var match *CatalogImage
 	for _, image := range images {
-		if image.Name == name {
+		if image.Name != name {
+			continue
+		}
+		if architecture == "" || image.Architecture == architecture {
 			copyImage := image
-			return &copyImage, nil
+			return &copyImage
+		}
+		if match == nil {
+			copyImage := image
+			match = &copyImage
 		}
 	}
-	return nil, nil
+	return match
 }
 
 func int64ToString(v int64) string {
@@ -164,6 +218,58 @@ func preferredRegionFromContext(ctx context.Context) string {
 	return ""
 }
 
+// GetVolumePricing returns the block storage price. It returns nil without
+// error when the static catalog is in use, which has no prices.
+func (s *RegionService) GetVolumePricing(ctx context.Context) (*VolumePricing, error) {
+	pricing, err := s.getPricing(ctx)
+	if err != nil {
+		if _, hasWorkspaceCred := workspaceCredentialFromContext(ctx); !hasWorkspaceCred && shouldUseStaticCatalogFallback(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &VolumePricing{
+		Currency:          pricing.Currency,
+		PerGBMonthlyNet:   pricing.Volume.PerGBMonthly.Net,
+		PerGBMonthlyGross: pricing.Volume.PerGBMonthly.Gross,
+	}, nil
+}
+
+// serverTypeDeprecated reports whether the server type can no longer be
+// ordered: every location deprecates it, or, for responses without
+// per-location data, the type itself is deprecated.
+func serverTypeDeprecated(st *hcloud.ServerType) bool {
+	if len(st.Locations) == 0 {
+		return st.Deprecation != nil
+	}
+	for _, loc := range st.Locations {
+		if loc.Deprecation == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func serverTypePrices(st *hcloud.ServerType) []SKUPrice {
+	prices := make([]SKUPrice, 0, len(st.Pricings))
+	for _, pricing := range st.Pricings {
+		if pricing.Location == nil {
+			continue
+		}
+		prices = append(prices, SKUPrice{
+			Location:             strings.ToLower(pricing.Location.Name),
+			Currency:             pricing.Hourly.Currency,
+			HourlyNet:            pricing.Hourly.Net,
+			HourlyGross:          pricing.Hourly.Gross,
+			MonthlyNet:           pricing.Monthly.Net,
+			MonthlyGross:         pricing.Monthly.Gross,
+			IncludedTrafficBytes: pricing.IncludedTraffic,
+		})
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Location < prices[j].Location })
+	return prices
+}
+
 func serverTypeAvailableInRegion(st *hcloud.ServerType, region string) bool {
 	if st == nil || region == "" {
 		return true
@@ -210,6 +316,9 @@ func serverTypeLocationCount(st *hcloud.ServerType) int {
 }
 
 func shouldUseStaticCatalogFallback(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return true
+	}
 	var apiErr hcloud.Error
 	if !errors.As(err, &apiErr) {
 		return false
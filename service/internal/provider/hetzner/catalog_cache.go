@@ -0,0 +1,214 @@
+package hetzner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	catalogKindServerTypes = "server-types"
+	catalogKindImages      = "images"
+	catalogKindLocations   = "locations"
+	catalogKindPricing     = "pricing"
+	catalogKindDatacenters = "datacenters"
+)
+
+// catalogCache keeps catalog data (server types, images, locations,
+// datacenters, prices) per credential scope, so workspaces with their own
+// Hetzner token never see another project's catalog. Live resources such as servers and volumes are
+// never cached.
+type catalogCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[catalogCacheKey]catalogCacheEntry
+}
+
+type catalogCacheKey struct {
+	scope string
+	kind  string
+}
+
+type catalogCacheEntry struct {
+	value     any
+	fetchedAt time.Time
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{
+		now:     time.Now,
+		entries: map[catalogCacheKey]catalogCacheEntry{},
+	}
+}
+
+func (c *catalogCache) get(key catalogCacheKey, ttl time.Duration) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.now().Sub(entry.fetchedAt) >= ttl {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *catalogCache) put(key catalogCacheKey, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = catalogCacheEntry{value: value, fetchedAt: c.now()}
+}
+
+func (c *catalogCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[catalogCacheKey]catalogCacheEntry{}
+}
+
+// clearScope drops the cached entries of one credential scope.
+func (c *catalogCache) clearScope(scope string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.scope == scope {
+			delete(c.entries, key)
+		}
+	}
+}
+
+type catalogCacheBypassContextKey struct{}
+
+// WithoutCatalogCache makes catalog lookups on ctx fetch fresh data from
+// Hetzner. The fresh result still refreshes the cache.
+func WithoutCatalogCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, catalogCacheBypassContextKey{}, true)
+}
+
+func catalogCacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(catalogCacheBypassContextKey{}).(bool)
+	return bypass
+}
+
+// InvalidateCatalogCache drops every cached catalog entry for all credential
+// scopes.
+func (s *RegionService) InvalidateCatalogCache() {
+	s.catalog.clear()
+}
+
+// catalogScope identifies the credentials clientFor(ctx) uses. Tokens are
+// hashed so they are not kept in memory as map keys.
+func catalogScope(ctx context.Context) string {
+	cred, ok := workspaceCredentialFromContext(ctx)
+	if !ok || cred.Token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cred.Token + "\x00" + cred.CloudAPIURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedCatalog returns the cached list for kind in the credential scope of
+// ctx, calling fetch when it is missing, expired or bypassed. A ttl of zero
+// disables caching for kind.
+func cachedCatalog[T any](ctx context.Context, cache *catalogCache, kind string, ttl time.Duration, fetch func() ([]*T, error)) ([]*T, error) {
+	if cache == nil || ttl <= 0 {
+		return fetch()
+	}
+	key := catalogCacheKey{scope: catalogScope(ctx), kind: kind}
+	if !catalogCacheBypassed(ctx) {
+		if value, ok := cache.get(key, ttl); ok {
+			return append([]*T(nil), value.([]*T)...), nil
+		}
+	}
+	items, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, append([]*T(nil), items...))
+	return items, nil
+}
+
+func (s *RegionService) listServerTypes(ctx context.Context) ([]*hcloud.ServerType, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	// Server types carry per-location availability, so they follow the
+	// availability TTL rather than the catalog TTL.
+	return cachedCatalog(ctx, s.catalog, catalogKindServerTypes, s.availCacheTTL, func() ([]*hcloud.ServerType, error) {
+		return s.clientFor(ctx).ServerType.All(ctx)
+	})
+}
+
+func (s *RegionService) listDatacenters(ctx context.Context) ([]*hcloud.Datacenter, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	// Datacenters list the server types available right now, so they follow
+	// the availability TTL like server types.
+	return cachedCatalog(ctx, s.catalog, catalogKindDatacenters, s.availCacheTTL, func() ([]*hcloud.Datacenter, error) {
+		return s.clientFor(ctx).Datacenter.All(ctx)
+	})
+}
+
+func (s *RegionService) getPricing(ctx context.Context) (*hcloud.Pricing, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	items, err := cachedCatalog(ctx, s.catalog, catalogKindPricing, s.catalogCacheTTL, func() ([]*hcloud.Pricing, error) {
+		pricing, _, err := s.clientFor(ctx).Pricing.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []*hcloud.Pricing{&pricing}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func (s *RegionService) listImages(ctx context.Context) ([]*hcloud.Image, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	return cachedCatalog(ctx, s.catalog, catalogKindImages, s.catalogCacheTTL, func() ([]*hcloud.Image, error) {
+		return s.clientFor(ctx).Image.AllWithOpts(ctx, hcloud.ImageListOpts{IncludeDeprecated: true})
+	})
+}
+
+func (s *RegionService) listLocations(ctx context.Context) ([]*hcloud.Location, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	return cachedCatalog(ctx, s.catalog, catalogKindLocations, s.catalogCacheTTL, func() ([]*hcloud.Location, error) {
+		return s.clientFor(ctx).Location.All(ctx)
+	})
+}
+
+func (s *RegionService) serverTypeByName(ctx context.Context, name string) (*hcloud.ServerType, error) {
+	serverTypes, err := s.listServerTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, serverType := range serverTypes {
+		if serverType != nil && strings.EqualFold(serverType.Name, name) {
+			return serverType, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *RegionService) locationByName(ctx context.Context, name string) (*hcloud.Location, error) {
+	locations, err := s.listLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		if location != nil && strings.EqualFold(location.Name, name) {
+			return location, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package hetzner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestCatalogCacheReusesEntriesPerCredential(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newCatalogCache()
+	cache.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() ([]*hcloud.Location, error) {
+		fetches++
+		return []*hcloud.Location{{ID: int64(fetches), Name: "fsn1"}}, nil
+	}
+	list := func(ctx context.Context) []*hcloud.Location {
+		t.Helper()
+		items, err := cachedCatalog(ctx, cache, catalogKindLocations, time.Minute, fetch)
+		if err != nil {
+			t.Fatalf("cachedCatalog: %v", err)
+		}
+		return items
+	}
+
+	shared := context.Background()
+	workspace := WithWorkspaceCredential(shared, WorkspaceCredential{Token: "workspace-token"})
+	list(shared)
+	list(shared)
+	if fetches != 1 {
+		t.Fatalf("expected one fetch for repeated reads, got %d", fetches)
+	}
+	if items := list(workspace); fetches != 2 || items[0].ID != 2 {
+		t.Fatalf("expected workspace credential to use its own entry, got %d fetches", fetches)
+	}
+
+	list(WithoutCatalogCache(shared))
+	if fetches != 3 {
+		t.Fatalf("expected bypass to fetch, got %d fetches", fetches)
+	}
+	if items := list(shared); fetches != 3 || items[0].ID != 3 {
+		t.Fatalf("expected bypassed fetch to refresh the cache, got %d fetches", fetches)
+	}
+
+	now = now.Add(time.Minute)
+	list(shared)
+	if fetches != 4 {
+		t.Fatalf("expected expired entry to be fetched again, got %d fetches", fetches)
+	}
+
+	cache.clear()
+	list(workspace)
+	if fetches != 5 {
+		t.Fatalf("expected invalidation to drop workspace entries, got %d fetches", fetches)
+	}
+}
+
+func TestCatalogCacheDisabledWithZeroTTL(t *testing.T) {
+	t.Parallel()
+
+	cache := newCatalogCache()
+	fetches := 0
+	for range 2 {
+		if _, err := cachedCatalog(context.Background(), cache, catalogKindImages, 0, func() ([]*hcloud.Image, error) {
+			fetches++
+			return nil, nil
+		}); err != nil {
+			t.Fatalf("cachedCatalog: %v", err)
+		}
+	}
+	if fetches != 2 {
+		t.Fatalf("expected every read to fetch with a zero TTL, got %d fetches", fetches)
+	}
+}
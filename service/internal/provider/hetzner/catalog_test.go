@@ -0,0 +1,61 @@
+package hetzner
+
+import (
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestServerTypeDeprecationAndPrices(t *testing.T) {
+	t.Parallel()
+
+	deprecated := hcloud.DeprecatableResource{Deprecation: &hcloud.DeprecationInfo{}}
+	nbg1 := &hcloud.Location{Name: "nbg1"}
+	fsn1 := &hcloud.Location{Name: "FSN1"}
+	st := &hcloud.ServerType{
+		Locations: []hcloud.ServerTypeLocation{
+			{Location: nbg1, DeprecatableResource: deprecated},
+			{Location: fsn1},
+		},
+		Pricings: []hcloud.ServerTypeLocationPricing{
+			{Location: nbg1, Hourly: hcloud.Price{Currency: "EUR", Net: "0.0060"}, Monthly: hcloud.Price{Currency: "EUR", Net: "3.79"}, IncludedTraffic: 20 << 40},
+			{Location: fsn1, Hourly: hcloud.Price{Currency: "EUR", Net: "0.0060"}, Monthly: hcloud.Price{Currency: "EUR", Net: "3.79"}},
+		},
+	}
+	if serverTypeDeprecated(st) {
+		t.Fatal("expected a type still offered in fsn1 not to be deprecated")
+	}
+	st.Locations[1].DeprecatableResource = deprecated
+	if !serverTypeDeprecated(st) {
+		t.Fatal("expected a type deprecated in every location to be deprecated")
+	}
+
+	prices := serverTypePrices(st)
+	if len(prices) != 2 || prices[0].Location != "fsn1" || prices[1].Location != "nbg1" {
+		t.Fatalf("expected prices sorted by lowercase location, got %+v", prices)
+	}
+	if prices[1].MonthlyNet != "3.79" || prices[1].IncludedTrafficBytes != 20<<40 {
+		t.Fatalf("unexpected nbg1 price %+v", prices[1])
+	}
+}
+
+func TestPickCatalogImagePrefersArchitecture(t *testing.T) {
+	t.Parallel()
+
+	images := []CatalogImage{
+		{Name: "ubuntu-24.04", Architecture: "arm", OSFlavor: "ubuntu"},
+		{Name: "ubuntu-24.04", Architecture: "x86", OSFlavor: "ubuntu"},
+	}
+	if got := PickCatalogImage(images, "ubuntu-24.04", "x86"); got == nil || got.Architecture != "x86" {
+		t.Fatalf("expected the x86 image, got %+v", got)
+	}
+	if got := PickCatalogImage(images, "ubuntu-24.04", ""); got == nil || got.Architecture != "arm" {
+		t.Fatalf("expected the first image without an architecture, got %+v", got)
+	}
+	if got := PickCatalogImage(images[:1], "ubuntu-24.04", "x86"); got == nil || got.Architecture != "arm" {
+		t.Fatalf("expected a fallback to the only same-named image, got %+v", got)
+	}
+	if got := PickCatalogImage(images, "debian-12", "x86"); got != nil {
+		t.Fatalf("expected no image, got %+v", got)
+	}
+}
@@ -4,31 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"net"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 type Instance struct {
-	ID         int64
-	Name       string
-	SKUName    string
-	ImageName  string
-	Region     string
+	ID        int64
+	Name      string
+	SKUName   string
+	ImageName string
+	Region    string
+	// Zone is the datacenter the server runs in, e.g. fsn1-dc14.
+	Zone       string
 	PowerState string
-	CreatedAt  time.Time
+	// Status is the raw Hetzner server status, such as initializing or
+	// rebuilding; it is empty for servers that were not read from Hetzner.
+	Status    string
+	Locked    bool
+	Labels    map[string]string
+	VolumeIDs []int64
+	// PublicIPv4 is the public address and PublicIPv6 the public /64 network;
+	// both are empty when the server has no such public interface.
+	PublicIPv4 string
+	PublicIPv6 string
+	// BackupWindow is the window Hetzner runs automated backups in; it is
+	// empty when backups are disabled.
+	BackupWindow string
+	// PlacementGroup names the placement group the server is in, if any.
+	PlacementGroup string
+	CreatedAt      time.Time
 }
 
+// PublicNetwork selects the public interfaces of a new server.
+type PublicNetwork struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// MaxVolumesPerServer is the number of volumes Hetzner allows to be attached
+// to a single server.
+const MaxVolumesPerServer = 16
+
 type InstanceCreateRequest struct {
 	Name      string
 	SKUName   string
 	ImageName string
-	Region    string
-	UserData  string
-	Labels    map[string]string
+	// ImageID pins a tenant image snapshot; ImageName is then only used in
+	// error messages.
+	ImageID int64
+	// BootVolume names a volume to attach when the server is created. It is
+	// ignored for existing servers.
+	BootVolume string
+	// PublicNetwork nil keeps the dual-stack default. Without any public
+	// interface the server joins the workspace private network of its zone
+	// instead. It is ignored for existing servers.
+	PublicNetwork *PublicNetwork
+	Region        string
+	// Zone pins a datacenter of Region, e.g. fsn1-dc14. Empty or equal to
+	// Region leaves the datacenter to Hetzner.
+	Zone     string
+	UserData string
+	Labels   map[string]string
+	// PlacementGroup names an existing placement group for the server. An
+	// existing server is moved into it, or out of its group when empty.
+	PlacementGroup string
 }
 
 type BlockStorage struct {
@@ -37,7 +84,13 @@ type BlockStorage struct {
 	SizeGB     int
 	Region     string
 	AttachedTo string
-	CreatedAt  time.Time
+	// DevicePath is where the volume shows up on the server it is attached
+	// to, a stable /dev/disk/by-id path.
+	DevicePath string
+	// Status is the raw Hetzner volume status: creating or available.
+	Status    string
+	Labels    map[string]string
+	CreatedAt time.Time
 }
 
 type BlockStorageCreateRequest struct {
@@ -49,10 +102,15 @@ type BlockStorageCreateRequest struct {
 }
 
 func (s *RegionService) ListInstances(ctx context.Context) ([]Instance, error) {
-	if !s.configured {
+	return s.ListInstancesByLabels(ctx, nil)
+}
+
+// ListInstancesByLabels lists the servers carrying every given label.
+func (s *RegionService) ListInstancesByLabels(ctx context.Context, labels map[string]string) ([]Instance, error) {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	servers, err := s.clientFor(ctx).Server.All(ctx)
+	servers, err := s.clientFor(ctx).Server.AllWithOpts(ctx, hcloud.ServerListOpts{ListOpts: labelListOpts(labels)})
 	if err != nil {
 		return nil, err
 	}
@@ -67,10 +125,10 @@ func (s *RegionService) ListInstances(ctx context.Context) ([]Instance, error) {
 }
 
 func (s *RegionService) GetInstance(ctx context.Context, name string) (*Instance, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, name)
+	server, _, err := s.serverByName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -82,70 +140,47 @@ func (s *RegionService) GetInstance(ctx context.Context, name string) (*Instance
 }
 
 func (s *RegionService) CreateOrUpdateInstance(ctx context.Context, req InstanceCreateRequest) (*Instance, bool, string, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, false, "", ErrNotConfigured
 	}
 
-	current, _, err := s.clientFor(ctx).Server.GetByName(ctx, req.Name)
+	current, _, err := s.serverByName(ctx, req.Name)
 	if err != nil {
 		return nil, false, "", err
 	}
 	if current != nil {
-		instance := instanceFromServer(current)
-		return &instance, false, "", nil
-	}
-
-	serverType, _, err := s.clientFor(ctx).ServerType.GetByName(ctx, req.SKUName)
-	if err != nil {
-		return nil, false, "", err
-	}
-	if serverType == nil {
-		return nil, false, "", notFoundError(fmt.Sprintf("compute sku %q not found", req.SKUName))
-	}
-	if req.Region != "" && s.conformanceMode {
-		// TODO: Remove this conformance-only SKU substitution once placement and SKU
-		// selection semantics are fully aligned with the production API contract.
-		serverType, err = s.resolveServerTypeForRegion(ctx, serverType, req.Region)
+		instance, actionID, err := s.updateInstance(ctx, current, req)
 		if err != nil {
 			return nil, false, "", err
 		}
+		return instance, false, actionID, nil
 	}
 
-	image, err := s.resolveImageForArchitecture(ctx, req.ImageName, serverType.Architecture)
+	createOpts, err := s.instanceCreateOpts(ctx, req)
 	if err != nil {
 		return nil, false, "", err
 	}
-	if image == nil {
-		return nil, false, "", notFoundError(
-			fmt.Sprintf("image %q not found for architecture %q", req.ImageName, serverType.Architecture),
-		)
-	}
-
-	createOpts := hcloud.ServerCreateOpts{
-		Name:       req.Name,
-		ServerType: serverType,
-		Image:      image,
-		UserData:   req.UserData,
-		Labels:     req.Labels,
-		PublicNet: &hcloud.ServerCreatePublicNet{
-			EnableIPv4: true,
-			EnableIPv6: true,
-		},
-	}
-	if req.Region != "" {
-		location, _, locErr := s.clientFor(ctx).Location.GetByName(ctx, req.Region)
-		if locErr != nil {
-			return nil, false, "", locErr
+	serverType := createOpts.ServerType
+	if !createOpts.PublicNet.EnableIPv4 && !createOpts.PublicNet.EnableIPv6 {
+		// Hetzner refuses to start a server without any network interface.
+		location := serverCreateLocation(createOpts)
+		if location == nil || location.NetworkZone == "" {
+			return nil, false, "", invalidRequestError("a region is required for instances without public networking")
 		}
-		if location == nil {
-			return nil, false, "", notFoundError(fmt.Sprintf("region %q not found", req.Region))
+		network, netErr := s.workspacePrivateNetwork(ctx, location.NetworkZone)
+		if netErr != nil {
+			return nil, false, "", netErr
 		}
-		createOpts.Location = location
+		createOpts.Networks = []*hcloud.Network{network}
 	}
 
 	result, _, err := s.clientFor(ctx).Server.Create(ctx, createOpts)
 	if err != nil {
-		if s.conformanceMode && req.Region != "" && isUnsupportedLocationForServerTypeError(err) {
+		conformance := s.conformanceFor(ctx)
+		if !conformance.LocationFallback && req.Region != "" && isPlacementCapacityError(err) {
+			return nil, false, "", s.placementError(ctx, serverType, req.Region, "has no capacity")
+		}
+		if s.skuFallback(ctx) && req.Region != "" && isUnsupportedLocationForServerTypeError(err) {
 			// TODO: Remove this conformance-only fallback that silently changes SKU.
 			if fallbackInstance, actionID, ok := s.tryCreateWithRegionFallbackTypes(ctx, createOpts, req.Region); ok {
 				return fallbackInstance, true, actionID, nil
@@ -153,7 +188,7 @@ func (s *RegionService) CreateOrUpdateInstance(ctx context.Context, req Instance
 		}
 		// Some server types are temporarily unavailable in a specific location.
 		// Retry without location constraint to let Hetzner place the server.
-		if s.conformanceMode && req.Region != "" {
+		if conformance.LocationFallback && req.Region != "" {
 			// TODO: Remove this conformance-only fallback that may violate region pinning.
 			var apiErr hcloud.Error
 			if errors.As(err, &apiErr) {
@@ -161,6 +196,7 @@ func (s *RegionService) CreateOrUpdateInstance(ctx context.Context, req Instance
 				case hcloud.ErrorCodeResourceUnavailable, hcloud.ErrorCodeNoSpaceLeftInLocation, hcloud.ErrorCodeInvalidInput:
 					retryOpts := createOpts
 					retryOpts.Location = nil
+					retryOpts.Datacenter = nil
 					retryResult, _, retryErr := s.clientFor(ctx).Server.Create(ctx, retryOpts)
 					if retryErr == nil {
 						actionID := ""
@@ -190,8 +226,345 @@ func (s *RegionService) CreateOrUpdateInstance(ctx context.Context, req Instance
 	return &instance, true, actionID, nil
 }
 
+// instanceCreateOpts resolves the server type, image, location and boot volume
+// of a new server with read-only calls.
+func (s *RegionService) instanceCreateOpts(ctx context.Context, req InstanceCreateRequest) (hcloud.ServerCreateOpts, error) {
+	serverType, err := s.serverTypeByName(ctx, req.SKUName)
+	if err != nil {
+		return hcloud.ServerCreateOpts{}, err
+	}
+	if serverType == nil {
+		return hcloud.ServerCreateOpts{}, notFoundError(fmt.Sprintf("compute sku %q not found", req.SKUName))
+	}
+	if req.Region != "" && s.skuFallback(ctx) {
+		// TODO: Remove this conformance-only SKU substitution once placement and SKU
+		// selection semantics are fully aligned with the production API contract.
+		serverType, err = s.resolveServerTypeForRegion(ctx, serverType, req.Region)
+		if err != nil {
+			return hcloud.ServerCreateOpts{}, err
+		}
+	} else if req.Region != "" && !serverTypeSupportsLocation(serverType, req.Region) {
+		return hcloud.ServerCreateOpts{}, s.placementError(ctx, serverType, req.Region, "is not offered")
+	}
+
+	image, err := s.resolveRequestImage(ctx, req, serverType.Architecture)
+	if err != nil {
+		return hcloud.ServerCreateOpts{}, err
+	}
+	if image == nil {
+		return hcloud.ServerCreateOpts{}, notFoundError(
+			fmt.Sprintf("image %q not found for architecture %q", req.ImageName, serverType.Architecture),
+		)
+	}
+
+	createOpts := hcloud.ServerCreateOpts{
+		Name:       req.Name,
+		ServerType: serverType,
+		Image:      image,
+		UserData:   req.UserData,
+		Labels:     req.Labels,
+		PublicNet: &hcloud.ServerCreatePublicNet{
+			EnableIPv4: true,
+			EnableIPv6: true,
+		},
+	}
+	if req.PublicNetwork != nil {
+		createOpts.PublicNet.EnableIPv4 = req.PublicNetwork.IPv4
+		createOpts.PublicNet.EnableIPv6 = req.PublicNetwork.IPv6
+	}
+	if req.Region != "" {
+		location, locErr := s.locationByName(ctx, req.Region)
+		if locErr != nil {
+			return hcloud.ServerCreateOpts{}, locErr
+		}
+		if location == nil {
+			return hcloud.ServerCreateOpts{}, notFoundError(fmt.Sprintf("region %q not found", req.Region))
+		}
+		createOpts.Location = location
+	}
+	if req.BootVolume != "" {
+		volume, volErr := s.bootVolume(ctx, req.BootVolume, createOpts.Location)
+		if volErr != nil {
+			return hcloud.ServerCreateOpts{}, volErr
+		}
+		// Hetzner creates the server next to its volume.
+		createOpts.Volumes = []*hcloud.Volume{volume}
+		createOpts.Location = volume.Location
+	}
+	if zone := strings.ToLower(strings.TrimSpace(req.Zone)); zone != "" && !strings.EqualFold(zone, req.Region) {
+		datacenter, dcErr := s.datacenterInRegion(ctx, zone, req.Region)
+		if dcErr != nil {
+			return hcloud.ServerCreateOpts{}, dcErr
+		}
+		// A datacenter implies its location; Hetzner accepts only one of them.
+		createOpts.Datacenter = datacenter
+		createOpts.Location = nil
+	}
+	if req.PlacementGroup != "" {
+		group, pgErr := s.placementGroupByName(ctx, req.PlacementGroup)
+		if pgErr != nil {
+			return hcloud.ServerCreateOpts{}, pgErr
+		}
+		createOpts.PlacementGroup = group
+	}
+	return createOpts, nil
+}
+
+// datacenterInRegion resolves zone to one of the datacenters of region, the
+// names GET /v1/regions lists as availableZones.
+func (s *RegionService) datacenterInRegion(ctx context.Context, zone, region string) (*hcloud.Datacenter, error) {
+	datacenters, err := s.listDatacenters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var valid []string
+	for _, datacenter := range datacenters {
+		if datacenter == nil || datacenter.Location == nil || !strings.EqualFold(datacenter.Location.Name, region) {
+			continue
+		}
+		if strings.EqualFold(datacenter.Name, zone) {
+			return datacenter, nil
+		}
+		valid = append(valid, datacenter.Name)
+	}
+	sort.Strings(valid)
+	return nil, invalidRequestError(fmt.Sprintf("zone %q is not available in region %q; valid zones: %s", zone, region, strings.Join(valid, ", ")))
+}
+
+// serverCreateLocation is the location a server created with opts lands in.
+func serverCreateLocation(opts hcloud.ServerCreateOpts) *hcloud.Location {
+	if opts.Datacenter != nil {
+		return opts.Datacenter.Location
+	}
+	return opts.Location
+}
+
+// bootVolume resolves the volume to attach at server creation. It must be
+// detached and, when a location is pinned, live in that location.
+func (s *RegionService) bootVolume(ctx context.Context, name string, location *hcloud.Location) (*hcloud.Volume, error) {
+	volume, _, err := s.volumeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if volume == nil {
+		return nil, invalidRequestError(fmt.Sprintf("block storage %q not found", name))
+	}
+	if volume.Server != nil {
+		return nil, conflictError(fmt.Sprintf("block storage %q is already attached to an instance", name))
+	}
+	if location != nil && volume.Location != nil && !strings.EqualFold(volume.Location.Name, location.Name) {
+		return nil, invalidRequestError(fmt.Sprintf("block storage %q is in region %q, not %q", name, volume.Location.Name, location.Name))
+	}
+	return volume, nil
+}
+
+// updateInstance converges an existing server on the requested labels, SKU
+// and image. A SKU change powers the server off, changes its type without
+// growing the disk (so it can be downgraded again later) and powers it back
+// on if it was running. An image change rebuilds the server; callers that
+// must not rebuild reject image changes before calling CreateOrUpdateInstance.
+// The returned action ID belongs to the last action started, or is empty when
+// nothing changed.
+func (s *RegionService) updateInstance(ctx context.Context, server *hcloud.Server, req InstanceCreateRequest) (*Instance, string, error) {
+	serverType, skuChanged, err := s.instanceUpdateServerType(ctx, server, req)
+	if err != nil {
+		return nil, "", err
+	}
+	imageChanged := serverImageChanged(server, req)
+	if req.Labels != nil && !maps.Equal(server.Labels, req.Labels) {
+		updated, _, err := s.clientFor(ctx).Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: req.Labels})
+		if err != nil {
+			return nil, "", err
+		}
+		if updated != nil {
+			server = updated
+		}
+	}
+	actionID, err := s.updateInstancePlacement(ctx, server, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if !skuChanged && !imageChanged {
+		if actionID == "" {
+			instance := instanceFromServer(server)
+			return &instance, "", nil
+		}
+		latest, _, err := s.clientFor(ctx).Server.GetByID(ctx, server.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if latest == nil {
+			latest = server
+		}
+		instance := instanceFromServer(latest)
+		return &instance, actionID, nil
+	}
+
+	if skuChanged {
+		wasRunning := server.Status == hcloud.ServerStatusRunning || server.Status == hcloud.ServerStatusStarting
+		if server.Status != hcloud.ServerStatusOff {
+			action, _, err := s.clientFor(ctx).Server.Poweroff(ctx, server)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := s.waitForActions(ctx, action); err != nil {
+				return nil, "", err
+			}
+		}
+		action, _, err := s.clientFor(ctx).Server.ChangeType(ctx, server, hcloud.ServerChangeTypeOpts{
+			ServerType:  serverType,
+			UpgradeDisk: false,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return nil, "", err
+		}
+		actionID = fmt.Sprintf("%d", action.ID)
+		if wasRunning && !imageChanged {
+			powerOn, _, err := s.clientFor(ctx).Server.Poweron(ctx, server)
+			if err != nil {
+				return nil, "", err
+			}
+			actionID = fmt.Sprintf("%d", powerOn.ID)
+		}
+	}
+	if imageChanged {
+		image, err := s.resolveRequestImage(ctx, req, serverType.Architecture)
+		if err != nil {
+			return nil, "", err
+		}
+		if image == nil {
+			return nil, "", notFoundError(
+				fmt.Sprintf("image %q not found for architecture %q", req.ImageName, serverType.Architecture),
+			)
+		}
+		result, _, err := s.clientFor(ctx).Server.RebuildWithResult(ctx, server, hcloud.ServerRebuildOpts{Image: image})
+		if err != nil {
+			return nil, "", err
+		}
+		if result.Action != nil {
+			actionID = fmt.Sprintf("%d", result.Action.ID)
+		}
+	}
+
+	latest, _, err := s.clientFor(ctx).Server.GetByID(ctx, server.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if latest == nil {
+		latest = server
+	}
+	instance := instanceFromServer(latest)
+	return &instance, actionID, nil
+}
+
+// instanceUpdateServerType returns the server type an update moves server to
+// and whether that is a change. The new type must be offered in the server's
+// location and must not have a smaller disk.
+func (s *RegionService) instanceUpdateServerType(ctx context.Context, server *hcloud.Server, req InstanceCreateRequest) (*hcloud.ServerType, bool, error) {
+	serverType := server.ServerType
+	skuChanged := req.SKUName != "" && (serverType == nil || !strings.EqualFold(serverType.Name, req.SKUName))
+	if !skuChanged {
+		return serverType, false, nil
+	}
+	requested, err := s.serverTypeByName(ctx, req.SKUName)
+	if err != nil {
+		return nil, false, err
+	}
+	if requested == nil {
+		return nil, false, notFoundError(fmt.Sprintf("compute sku %q not found", req.SKUName))
+	}
+	if server.Location != nil && !serverTypeSupportsLocation(requested, server.Location.Name) {
+		if s.skuFallback(ctx) && serverType != nil {
+			// TODO: Remove together with the conformance-only SKU substitution on
+			// create. A server created on the substitute keeps it when the
+			// original SKU is requested again; any other change is refused.
+			substitute, err := s.resolveServerTypeForRegion(ctx, requested, server.Location.Name)
+			if err == nil && substitute.ID == serverType.ID {
+				return serverType, false, nil
+			}
+		}
+		return nil, false, s.placementError(ctx, requested, server.Location.Name, "is not offered")
+	}
+	if requested.Disk < server.PrimaryDiskSize {
+		return nil, false, conflictError(fmt.Sprintf(
+			"server type %q has a %d GB disk, smaller than the current %d GB disk",
+			requested.Name, requested.Disk, server.PrimaryDiskSize,
+		))
+	}
+	return requested, true, nil
+}
+
+// ValidateInstanceCreate makes the checks of CreateOrUpdateInstance with
+// read-only calls. It returns the instance the request would leave behind and
+// whether it would be created.
+func (s *RegionService) ValidateInstanceCreate(ctx context.Context, req InstanceCreateRequest) (*Instance, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	current, _, err := s.serverByName(ctx, req.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	if current != nil {
+		serverType, _, err := s.instanceUpdateServerType(ctx, current, req)
+		if err != nil {
+			return nil, false, err
+		}
+		instance := instanceFromServer(current)
+		if serverType != nil {
+			instance.SKUName = strings.ToLower(serverType.Name)
+		}
+		if serverImageChanged(current, req) {
+			var arch hcloud.Architecture
+			if serverType != nil {
+				arch = serverType.Architecture
+			}
+			image, err := s.resolveRequestImage(ctx, req, arch)
+			if err != nil {
+				return nil, false, err
+			}
+			if image == nil {
+				return nil, false, notFoundError(
+					fmt.Sprintf("image %q not found for architecture %q", req.ImageName, arch),
+				)
+			}
+			instance.ImageName = strings.ToLower(req.ImageName)
+		}
+		if req.Labels != nil {
+			instance.Labels = req.Labels
+		}
+		return &instance, false, nil
+	}
+
+	createOpts, err := s.instanceCreateOpts(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	location := serverCreateLocation(createOpts)
+	if !createOpts.PublicNet.EnableIPv4 && !createOpts.PublicNet.EnableIPv6 && (location == nil || location.NetworkZone == "") {
+		return nil, false, invalidRequestError("a region is required for instances without public networking")
+	}
+	instance := Instance{
+		Name:       strings.ToLower(req.Name),
+		SKUName:    strings.ToLower(createOpts.ServerType.Name),
+		ImageName:  strings.ToLower(req.ImageName),
+		PowerState: "off",
+		Labels:     req.Labels,
+	}
+	if location != nil {
+		instance.Region = strings.ToLower(location.Name)
+	}
+	if createOpts.Datacenter != nil {
+		instance.Zone = strings.ToLower(createOpts.Datacenter.Name)
+	}
+	return &instance, true, nil
+}
+
 func (s *RegionService) tryCreateWithRegionFallbackTypes(ctx context.Context, createOpts hcloud.ServerCreateOpts, region string) (*Instance, string, bool) {
-	candidates, err := s.serverTypeCandidatesForRegion(ctx, createOpts.ServerType, region)
+	candidates, err := s.fallbackServerTypes(ctx, createOpts.ServerType, region)
 	if err != nil {
 		return nil, "", false
 	}
@@ -225,7 +598,7 @@ func (s *RegionService) tryCreateWithRegionFallbackTypes(ctx context.Context, cr
 }
 
 func (s *RegionService) resolveServerTypeForRegion(ctx context.Context, requested *hcloud.ServerType, region string) (*hcloud.ServerType, error) {
-	candidates, err := s.serverTypeCandidatesForRegion(ctx, requested, region)
+	candidates, err := s.fallbackServerTypes(ctx, requested, region)
 	if err != nil {
 		return nil, err
 	}
@@ -235,6 +608,33 @@ func (s *RegionService) resolveServerTypeForRegion(ctx context.Context, requeste
 	return candidates[0], nil
 }
 
+// ServerTypeForRegion returns preferred when Hetzner offers it in region,
+// and otherwise the smallest server type of fallbackArchitecture that is
+// offered there.
+func (s *RegionService) ServerTypeForRegion(ctx context.Context, preferred, region, fallbackArchitecture string) (string, error) {
+	region = strings.ToLower(strings.TrimSpace(region))
+	if region == "" {
+		return preferred, nil
+	}
+	all, err := s.listServerTypes(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, st := range all {
+		if st != nil && strings.EqualFold(st.Name, preferred) && serverTypeSupportsLocation(st, region) {
+			return st.Name, nil
+		}
+	}
+	candidates, err := s.serverTypeCandidatesForRegion(ctx, &hcloud.ServerType{Architecture: hcloud.Architecture(fallbackArchitecture)}, region)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", conflictError(fmt.Sprintf("neither server type %q nor any %s server type is available in region %q", preferred, fallbackArchitecture, region))
+	}
+	return candidates[0].Name, nil
+}
+
 func (s *RegionService) serverTypeCandidatesForRegion(ctx context.Context, requested *hcloud.ServerType, region string) ([]*hcloud.ServerType, error) {
 	region = strings.ToLower(strings.TrimSpace(region))
 	if requested == nil || region == "" {
@@ -275,6 +675,92 @@ func (s *RegionService) serverTypeCandidatesForRegion(ctx context.Context, reque
 	return candidates, nil
 }
 
+// fallbackServerTypes returns the server types the SKU substitution tries in
+// region: the fewest cores and least memory first, or with the cheapest
+// strategy the lowest hourly price in region first.
+func (s *RegionService) fallbackServerTypes(ctx context.Context, requested *hcloud.ServerType, region string) ([]*hcloud.ServerType, error) {
+	candidates, err := s.serverTypeCandidatesForRegion(ctx, requested, region)
+	if err != nil || s.skuStrategy != config.SKUStrategyCheapest {
+		return candidates, err
+	}
+	region = strings.ToLower(strings.TrimSpace(region))
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return serverTypeHourlyPrice(candidates[i], region) < serverTypeHourlyPrice(candidates[j], region)
+	})
+	return candidates, nil
+}
+
+// serverTypeHourlyPrice is the net hourly price of serverType in region. A
+// type without a price there sorts last.
+func serverTypeHourlyPrice(serverType *hcloud.ServerType, region string) float64 {
+	if serverType == nil {
+		return math.Inf(1)
+	}
+	for _, pricing := range serverType.Pricings {
+		if pricing.Location == nil || !strings.EqualFold(pricing.Location.Name, region) {
+			continue
+		}
+		if price, err := strconv.ParseFloat(pricing.Hourly.Net, 64); err == nil {
+			return price
+		}
+	}
+	return math.Inf(1)
+}
+
+// placementError reports that serverType cannot be placed in region, listing
+// the regions that offer the SKU and the SKUs offered in the region so the
+// caller can pick one of them.
+func (s *RegionService) placementError(ctx context.Context, serverType *hcloud.ServerType, region, reason string) error {
+	region = strings.ToLower(strings.TrimSpace(region))
+	detail := fmt.Sprintf("compute sku %q %s in region %q", serverType.Name, reason, region)
+	if regions := serverTypeLocationNames(serverType); len(regions) > 0 {
+		detail += "; sku available in regions: " + strings.Join(regions, ", ")
+	}
+	candidates, err := s.serverTypeCandidatesForRegion(ctx, serverType, region)
+	if err == nil {
+		skus := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			if candidate != nil && !strings.EqualFold(candidate.Name, serverType.Name) {
+				skus = append(skus, candidate.Name)
+			}
+		}
+		if len(skus) > 0 {
+			detail += fmt.Sprintf("; skus available in region %q: %s", region, strings.Join(skus, ", "))
+		}
+	}
+	return conflictError(detail)
+}
+
+func serverTypeLocationNames(serverType *hcloud.ServerType) []string {
+	names := make([]string, 0, len(serverType.Locations))
+	for _, loc := range serverType.Locations {
+		if loc.Location != nil && !loc.IsDeprecated() {
+			names = append(names, loc.Location.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, pricing := range serverType.Pricings {
+			if pricing.Location != nil {
+				names = append(names, pricing.Location.Name)
+			}
+		}
+	}
+	return dedupeSorted(names)
+}
+
+// isPlacementCapacityError reports Hetzner errors meaning the server type
+// cannot currently be created in the requested location.
+func isPlacementCapacityError(err error) bool {
+	if isUnsupportedLocationForServerTypeError(err) {
+		return true
+	}
+	var apiErr hcloud.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == hcloud.ErrorCodeResourceUnavailable || apiErr.Code == hcloud.ErrorCodeNoSpaceLeftInLocation
+}
+
 func serverTypeSupportsLocation(serverType *hcloud.ServerType, location string) bool {
 	if serverType == nil {
 		return false
@@ -313,42 +799,27 @@ func isUnsupportedLocationForServerTypeError(err error) bool {
 }
 
 func (s *RegionService) resolveImageForArchitecture(ctx context.Context, imageName string, arch hcloud.Architecture) (*hcloud.Image, error) {
-	// Fast path when the named image already matches architecture.
-	if imageName != "" {
-		image, _, err := s.clientFor(ctx).Image.GetByName(ctx, imageName)
-		if err != nil {
-			return nil, err
-		}
-		if image != nil && (arch == "" || image.Architecture == arch) {
-			return image, nil
-		}
-	}
-
-	// Fallback: select a system image matching both name and architecture.
-	images, err := s.clientFor(ctx).Image.AllWithOpts(ctx, hcloud.ImageListOpts{
-		Type: []hcloud.ImageType{hcloud.ImageTypeSystem},
-	})
+	images, err := s.listImages(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// Prefer the named image with the requested architecture.
 	lowerName := strings.ToLower(strings.TrimSpace(imageName))
-	for _, image := range images {
-		if image == nil {
-			continue
-		}
-		if arch != "" && image.Architecture != arch {
-			continue
-		}
-		if lowerName != "" && !strings.EqualFold(image.Name, lowerName) {
-			continue
+	if lowerName != "" {
+		for _, image := range images {
+			if image == nil || !strings.EqualFold(image.Name, lowerName) {
+				continue
+			}
+			if arch == "" || image.Architecture == arch {
+				return image, nil
+			}
 		}
-		return image, nil
 	}
 
-	// Last resort: any system image for the requested architecture.
+	// Last resort: any current system image for the requested architecture.
 	for _, image := range images {
-		if image == nil {
+		if image == nil || image.Type != hcloud.ImageTypeSystem || image.IsDeprecated() {
 			continue
 		}
 		if arch != "" && image.Architecture != arch {
@@ -360,17 +831,22 @@ func (s *RegionService) resolveImageForArchitecture(ctx context.Context, imageNa
 	return nil, nil
 }
 
+// DeleteInstance deletes the server. Hetzner detaches its volumes, boot
+// volume included, and keeps them.
 func (s *RegionService) DeleteInstance(ctx context.Context, name string) (bool, string, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return false, "", ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, name)
+	server, _, err := s.serverByName(ctx, name)
 	if err != nil {
 		return false, "", err
 	}
 	if server == nil {
 		return false, "", nil
 	}
+	if err := s.detachServerVolumes(ctx, server); err != nil {
+		return false, "", err
+	}
 	result, _, err := s.clientFor(ctx).Server.DeleteWithResult(ctx, server)
 	if err != nil {
 		return false, "", err
@@ -382,6 +858,32 @@ func (s *RegionService) DeleteInstance(ctx context.Context, name string) (bool,
 	return true, actionID, nil
 }
 
+// detachServerVolumes detaches every volume of server and waits for it, so
+// the volumes can be deleted as soon as the server delete is accepted.
+func (s *RegionService) detachServerVolumes(ctx context.Context, server *hcloud.Server) error {
+	actions := make([]*hcloud.Action, 0, len(server.Volumes))
+	for _, volume := range server.Volumes {
+		if volume == nil {
+			continue
+		}
+		action, _, err := s.clientFor(ctx).Volume.Detach(ctx, volume)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	return s.waitForActions(ctx, actions...)
+}
+
+// DeferredPowerOn is the action ID StartInstance returns when the server is
+// locked by another action, such as its own create or a network attach. No
+// power-on was sent yet; the operation reconciler sends it once the lock is
+// gone instead of the request waiting for it.
+const DeferredPowerOn = "deferred:poweron"
+
 func (s *RegionService) StartInstance(ctx context.Context, name string) (bool, string, error) {
 	server, err := s.getServerByName(ctx, name)
 	if err != nil {
@@ -390,27 +892,29 @@ func (s *RegionService) StartInstance(ctx context.Context, name string) (bool, s
 	if server == nil {
 		return false, "", nil
 	}
-	var action *hcloud.Action
-	if s.conformanceMode {
+	lockMasking := s.conformanceFor(ctx).LockMasking
+	if lockMasking {
 		// TODO: Remove this conformance-only self-healing path that mutates network state.
 		_ = s.ensureServerHasNetworkInterface(ctx, server)
-		action, _, err = s.powerOnWithRetry(ctx, server)
-	} else {
-		action, _, err = s.clientFor(ctx).Server.Poweron(ctx, server)
 	}
+	action, _, err := s.clientFor(ctx).Server.Poweron(ctx, server)
 	if err != nil {
-		if s.conformanceMode && needsNetworkInterface(err) {
-			// TODO: Remove this conformance-only retry path with implicit network attachment.
+		if (lockMasking || !serverHasPublicNet(server)) && needsNetworkInterface(err) {
+			// Servers without public networking boot on the workspace private
+			// network; conformance runs attach every server to it.
 			if attachErr := s.ensureServerHasNetworkInterface(ctx, server); attachErr != nil {
 				return false, "", attachErr
 			}
-			action, _, err = s.powerOnWithRetry(ctx, server)
+			action, _, err = s.clientFor(ctx).Server.Poweron(ctx, server)
+			if isResourceLockedError(err) {
+				return true, DeferredPowerOn, nil
+			}
 			if err != nil {
 				return false, "", err
 			}
 			return true, fmt.Sprintf("%d", action.ID), nil
 		}
-		if s.conformanceMode && isResourceLockedError(err) {
+		if lockMasking && isResourceLockedError(err) {
 			// TODO: Remove this conformance-only lock masking once async lifecycle
 			// handling is coordinated with the conformance runner.
 			// If the server is already transitioning/running, treat start as accepted.
@@ -421,6 +925,9 @@ func (s *RegionService) StartInstance(ctx context.Context, name string) (bool, s
 				}
 			}
 		}
+		if isResourceLockedError(err) {
+			return true, DeferredPowerOn, nil
+		}
 		return false, "", err
 	}
 	actionID := ""
@@ -430,7 +937,11 @@ func (s *RegionService) StartInstance(ctx context.Context, name string) (bool, s
 	return true, actionID, nil
 }
 
-func (s *RegionService) powerOnWithRetry(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+// retryWhileLocked repeats a server action while Hetzner reports the server
+// locked by another action, such as the create action of a new server.
+func retryWhileLocked(ctx context.Context, call func() (*hcloud.Action, *hcloud.Response, error)) (*hcloud.Action, *hcloud.Response, error) {
+	ctx, done := TrackInFlight(ctx)
+	defer done()
 	const (
 		maxAttempts = 24
 		retryDelay  = 500 * time.Millisecond
@@ -438,7 +949,7 @@ func (s *RegionService) powerOnWithRetry(ctx context.Context, server *hcloud.Ser
 
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		action, resp, err := s.clientFor(ctx).Server.Poweron(ctx, server)
+		action, resp, err := call()
 		if err == nil {
 			return action, resp, nil
 		}
@@ -483,11 +994,42 @@ func (s *RegionService) RestartInstance(ctx context.Context, name string) (bool,
 	return true, fmt.Sprintf("%d", action.ID), nil
 }
 
+// SetInstanceBackups enables or disables Hetzner's automated backups for the
+// server. Hetzner picks the backup window itself. The backupSkip conformance
+// flag leaves backups untouched so test runs never incur backup charges.
+func (s *RegionService) SetInstanceBackups(ctx context.Context, name string, enabled bool) (bool, string, error) {
+	server, err := s.getServerByName(ctx, name)
+	if err != nil {
+		return false, "", err
+	}
+	if server == nil {
+		return false, "", nil
+	}
+	if s.conformanceFor(ctx).BackupSkip || (server.BackupWindow != "") == enabled {
+		return true, "", nil
+	}
+	action, _, err := retryWhileLocked(ctx, func() (*hcloud.Action, *hcloud.Response, error) {
+		if enabled {
+			return s.clientFor(ctx).Server.EnableBackup(ctx, server, "")
+		}
+		return s.clientFor(ctx).Server.DisableBackup(ctx, server)
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return true, fmt.Sprintf("%d", action.ID), nil
+}
+
 func (s *RegionService) ListBlockStorages(ctx context.Context) ([]BlockStorage, error) {
-	if !s.configured {
+	return s.ListBlockStoragesByLabels(ctx, nil)
+}
+
+// ListBlockStoragesByLabels lists the volumes carrying every given label.
+func (s *RegionService) ListBlockStoragesByLabels(ctx context.Context, labels map[string]string) ([]BlockStorage, error) {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	volumes, err := s.clientFor(ctx).Volume.All(ctx)
+	volumes, err := s.clientFor(ctx).Volume.AllWithOpts(ctx, hcloud.VolumeListOpts{ListOpts: labelListOpts(labels)})
 	if err != nil {
 		return nil, err
 	}
@@ -502,10 +1044,10 @@ func (s *RegionService) ListBlockStorages(ctx context.Context) ([]BlockStorage,
 }
 
 func (s *RegionService) GetBlockStorage(ctx context.Context, name string) (*BlockStorage, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	volume, _, err := s.clientFor(ctx).Volume.GetByName(ctx, name)
+	volume, _, err := s.volumeByName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -516,17 +1058,125 @@ func (s *RegionService) GetBlockStorage(ctx context.Context, name string) (*Bloc
 	return &block, nil
 }
 
+// blockStorageLocation resolves region to a location that offers block
+// storage.
+func (s *RegionService) blockStorageLocation(ctx context.Context, region string) (*hcloud.Location, error) {
+	location, err := s.locationByName(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		return nil, notFoundError(fmt.Sprintf("region %q not found", region))
+	}
+	offered, err := s.regionOffersBlockStorage(ctx, location.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !offered {
+		return nil, invalidRequestError(fmt.Sprintf("region %q does not offer block storage", region))
+	}
+	return location, nil
+}
+
+// ValidateBlockStorageCreate makes the checks of CreateOrUpdateBlockStorage
+// with read-only calls. It returns the block storage the request would leave
+// behind and whether it would be created.
+func (s *RegionService) ValidateBlockStorageCreate(ctx context.Context, req BlockStorageCreateRequest) (*BlockStorage, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	current, _, err := s.volumeByName(ctx, req.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	if current != nil {
+		if req.SizeGB > 0 && req.SizeGB < current.Size {
+			return nil, false, invalidRequestError(fmt.Sprintf("volume %q cannot shrink from %d GB to %d GB", req.Name, current.Size, req.SizeGB))
+		}
+		block := blockStorageFromVolume(current)
+		block.SizeGB = max(block.SizeGB, req.SizeGB)
+		if req.Labels != nil {
+			block.Labels = req.Labels
+		}
+		return &block, false, nil
+	}
+
+	block := BlockStorage{
+		Name:   strings.ToLower(req.Name),
+		SizeGB: req.SizeGB,
+		Region: strings.ToLower(req.Region),
+		Labels: req.Labels,
+	}
+	switch {
+	case req.AttachTo != "":
+		server, _, err := s.serverByName(ctx, req.AttachTo)
+		if err != nil {
+			return nil, false, err
+		}
+		if server == nil {
+			return nil, false, notFoundError(fmt.Sprintf("instance %q not found", req.AttachTo))
+		}
+		block.AttachedTo = strings.ToLower(server.Name)
+		if server.Location != nil {
+			block.Region = strings.ToLower(server.Location.Name)
+		}
+	case !s.conformanceFor(ctx).LocationFallback:
+		location, err := s.blockStorageLocation(ctx, req.Region)
+		if err != nil {
+			return nil, false, err
+		}
+		block.Region = strings.ToLower(location.Name)
+	default:
+		locations, err := s.blockStorageLocationCandidates(ctx, req.Region)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(locations) == 0 {
+			return nil, false, notFoundError("no usable region found")
+		}
+		block.Region = strings.ToLower(locations[0].Name)
+	}
+	return &block, true, nil
+}
+
 func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req BlockStorageCreateRequest) (*BlockStorage, bool, string, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, false, "", ErrNotConfigured
 	}
-	current, _, err := s.clientFor(ctx).Volume.GetByName(ctx, req.Name)
+	current, _, err := s.volumeByName(ctx, req.Name)
 	if err != nil {
 		return nil, false, "", err
 	}
 	if current != nil {
+		if req.Labels != nil && !maps.Equal(current.Labels, req.Labels) {
+			updated, _, err := s.clientFor(ctx).Volume.Update(ctx, current, hcloud.VolumeUpdateOpts{Labels: req.Labels})
+			if err != nil {
+				return nil, false, "", err
+			}
+			if updated != nil {
+				current = updated
+			}
+		}
+		actionID := ""
+		if req.SizeGB > 0 && req.SizeGB < current.Size {
+			return nil, false, "", invalidRequestError(fmt.Sprintf("volume %q cannot shrink from %d GB to %d GB", req.Name, current.Size, req.SizeGB))
+		}
+		if req.SizeGB > current.Size {
+			action, _, err := s.clientFor(ctx).Volume.Resize(ctx, current, req.SizeGB)
+			if err != nil {
+				return nil, false, "", err
+			}
+			if action != nil {
+				actionID = fmt.Sprintf("%d", action.ID)
+			}
+			// The resize answers with an action only; report the size the
+			// volume is growing to rather than the one it had.
+			resized := *current
+			resized.Size = req.SizeGB
+			current = &resized
+		}
 		block := blockStorageFromVolume(current)
-		return &block, false, "", nil
+		return &block, false, actionID, nil
 	}
 
 	createOpts := hcloud.VolumeCreateOpts{
@@ -535,7 +1185,7 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 		Labels: req.Labels,
 	}
 	if req.AttachTo != "" {
-		server, _, getErr := s.clientFor(ctx).Server.GetByName(ctx, req.AttachTo)
+		server, _, getErr := s.serverByName(ctx, req.AttachTo)
 		if getErr != nil {
 			return nil, false, "", getErr
 		}
@@ -543,19 +1193,16 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 			return nil, false, "", notFoundError(fmt.Sprintf("instance %q not found", req.AttachTo))
 		}
 		createOpts.Server = server
-	} else if !s.conformanceMode {
-		location, _, locErr := s.clientFor(ctx).Location.GetByName(ctx, req.Region)
+	} else if !s.conformanceFor(ctx).LocationFallback {
+		location, locErr := s.blockStorageLocation(ctx, req.Region)
 		if locErr != nil {
 			return nil, false, "", locErr
 		}
-		if location == nil {
-			return nil, false, "", notFoundError(fmt.Sprintf("region %q not found", req.Region))
-		}
 		createOpts.Location = location
 	} else {
 		// TODO: Remove this conformance-only fallback that can place volume outside
 		// the requested region when preferred capacity is unavailable.
-		locations, err := s.locationCandidates(ctx, req.Region)
+		locations, err := s.blockStorageLocationCandidates(ctx, req.Region)
 		if err != nil {
 			return nil, false, "", err
 		}
@@ -579,6 +1226,7 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 			}
 			var apiErr hcloud.Error
 			if strings.TrimSpace(req.Region) != "" && errors.As(createErr, &apiErr) && apiErr.Code == hcloud.ErrorCodeNoSpaceLeftInLocation {
+				s.volumeCapacity.markExhausted(ctx, location.Name)
 				lastAPIErr = &apiErr
 				continue
 			}
@@ -593,6 +1241,7 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 		if result.Volume == nil {
 			return nil, false, "", fmt.Errorf("hetzner returned empty volume")
 		}
+		s.volumeCapacity.markAvailable(ctx, createdWith.Name)
 		if result.Volume.Location == nil && createdWith != nil {
 			result.Volume.Location = createdWith
 		}
@@ -606,11 +1255,18 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 	}
 	result, _, err := s.clientFor(ctx).Volume.Create(ctx, createOpts)
 	if err != nil {
+		if createOpts.Location != nil && isNoSpaceLeftError(err) {
+			s.volumeCapacity.markExhausted(ctx, createOpts.Location.Name)
+			return nil, false, "", s.volumeCapacityError(ctx, createOpts.Location)
+		}
 		return nil, false, "", err
 	}
 	if result.Volume == nil {
 		return nil, false, "", fmt.Errorf("hetzner returned empty volume")
 	}
+	if createOpts.Location != nil {
+		s.volumeCapacity.markAvailable(ctx, createOpts.Location.Name)
+	}
 
 	actionID := ""
 	if result.Action != nil {
@@ -620,13 +1276,37 @@ func (s *RegionService) CreateOrUpdateBlockStorage(ctx context.Context, req Bloc
 	return &block, true, actionID, nil
 }
 
+// blockStorageLocationCandidates narrows locationCandidates to the regions
+// that offer block storage.
+func (s *RegionService) blockStorageLocationCandidates(ctx context.Context, preferred string) ([]*hcloud.Location, error) {
+	locations, err := s.locationCandidates(ctx, preferred)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := s.ListRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offered := make(map[string]bool, len(regions))
+	for _, region := range regions {
+		offered[strings.ToLower(region.Name)] = region.BlockStorage
+	}
+	out := make([]*hcloud.Location, 0, len(locations))
+	for _, location := range locations {
+		if offered[strings.ToLower(location.Name)] {
+			out = append(out, location)
+		}
+	}
+	return out, nil
+}
+
 func (s *RegionService) locationCandidates(ctx context.Context, preferred string) ([]*hcloud.Location, error) {
 	candidates := make([]*hcloud.Location, 0, 8)
 	seen := map[int64]struct{}{}
 	preferred = strings.TrimSpace(preferred)
 
 	if preferred != "" {
-		location, _, err := s.clientFor(ctx).Location.GetByName(ctx, preferred)
+		location, err := s.locationByName(ctx, preferred)
 		if err != nil {
 			return nil, err
 		}
@@ -636,7 +1316,7 @@ func (s *RegionService) locationCandidates(ctx context.Context, preferred string
 		}
 	}
 
-	locations, err := s.clientFor(ctx).Location.All(ctx)
+	locations, err := s.listLocations(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -653,17 +1333,32 @@ func (s *RegionService) locationCandidates(ctx context.Context, preferred string
 	return candidates, nil
 }
 
-func (s *RegionService) DeleteBlockStorage(ctx context.Context, name string) (bool, error) {
-	if !s.configured {
+// DeleteBlockStorage deletes the volume. Hetzner refuses to delete an attached
+// volume, so one that is still attached is reported as a conflict unless force
+// is set, in which case it is detached first.
+func (s *RegionService) DeleteBlockStorage(ctx context.Context, name string, force bool) (bool, error) {
+	if !s.configuredFor(ctx) {
 		return false, ErrNotConfigured
 	}
-	volume, _, err := s.clientFor(ctx).Volume.GetByName(ctx, name)
+	volume, _, err := s.volumeByName(ctx, name)
 	if err != nil {
 		return false, err
 	}
 	if volume == nil {
 		return false, nil
 	}
+	if volume.Server != nil {
+		if !force {
+			return false, conflictError(fmt.Sprintf("volume is attached to instance %s, detach first", s.volumeServerName(ctx, volume)))
+		}
+		action, _, err := s.clientFor(ctx).Volume.Detach(ctx, volume)
+		if err != nil {
+			return false, err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return false, err
+		}
+	}
 	_, err = s.clientFor(ctx).Volume.Delete(ctx, volume)
 	if err != nil {
 		return false, err
@@ -671,42 +1366,63 @@ func (s *RegionService) DeleteBlockStorage(ctx context.Context, name string) (bo
 	return true, nil
 }
 
-func (s *RegionService) AttachBlockStorage(ctx context.Context, name, instanceName string) (bool, string, error) {
-	if !s.configured {
+// AttachBlockStorage attaches the volume to the server and waits for the
+// action, so a following read reports the device path. With automount Hetzner
+// also mounts the volume's filesystem on the server. Attaching
+// to the server that already holds the volume is a no-op; attaching a volume
+// held by another server is a conflict.
+func (s *RegionService) AttachBlockStorage(ctx context.Context, name, instanceName string, automount bool) (bool, string, error) {
+	if !s.configuredFor(ctx) {
 		return false, "", ErrNotConfigured
 	}
-	volume, _, err := s.clientFor(ctx).Volume.GetByName(ctx, name)
+	volume, _, err := s.volumeByName(ctx, name)
 	if err != nil {
 		return false, "", err
 	}
 	if volume == nil {
 		return false, "", nil
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, instanceName)
+	server, _, err := s.serverByName(ctx, instanceName)
 	if err != nil {
 		return false, "", err
 	}
 	if server == nil {
 		return false, "", notFoundError(fmt.Sprintf("instance %q not found", instanceName))
 	}
-	action, _, err := s.clientFor(ctx).Volume.Attach(ctx, volume, server)
+	if volume.Server != nil {
+		if volume.Server.ID == server.ID {
+			return true, "", nil
+		}
+		return false, "", conflictError(fmt.Sprintf("volume is attached to instance %s, detach first", s.volumeServerName(ctx, volume)))
+	}
+	action, _, err := s.clientFor(ctx).Volume.AttachWithOpts(ctx, volume, hcloud.VolumeAttachOpts{
+		Server:    server,
+		Automount: hcloud.Ptr(automount),
+	})
 	if err != nil {
 		return false, "", err
 	}
+	if err := s.waitForActions(ctx, action); err != nil {
+		return false, "", err
+	}
 	return true, fmt.Sprintf("%d", action.ID), nil
 }
 
 func (s *RegionService) DetachBlockStorage(ctx context.Context, name string) (bool, string, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return false, "", ErrNotConfigured
 	}
-	volume, _, err := s.clientFor(ctx).Volume.GetByName(ctx, name)
+	volume, _, err := s.volumeByName(ctx, name)
 	if err != nil {
 		return false, "", err
 	}
 	if volume == nil {
 		return false, "", nil
 	}
+	if volume.Server == nil {
+		// Already detached: report success without an action to wait for.
+		return true, "", nil
+	}
 	action, _, err := s.clientFor(ctx).Volume.Detach(ctx, volume)
 	if err != nil {
 		return false, "", err
@@ -714,18 +1430,46 @@ func (s *RegionService) DetachBlockStorage(ctx context.Context, name string) (bo
 	return true, fmt.Sprintf("%d", action.ID), nil
 }
 
+// volumeServerName names the server an attached volume belongs to. The volume
+// only carries the server ID, so the name is looked up, falling back to the ID.
+func (s *RegionService) volumeServerName(ctx context.Context, volume *hcloud.Volume) string {
+	if volume.Server.Name != "" {
+		return volume.Server.Name
+	}
+	server, _, err := s.clientFor(ctx).Server.GetByID(ctx, volume.Server.ID)
+	if err != nil || server == nil {
+		return fmt.Sprintf("%d", volume.Server.ID)
+	}
+	return server.Name
+}
+
 func (s *RegionService) AttachInstanceToNetwork(ctx context.Context, instanceName, networkName string) (bool, string, error) {
-	if !s.configured {
+	return s.AttachInstanceToNetworkWithIP(ctx, instanceName, networkName, "")
+}
+
+// AttachInstanceToNetworkWithIP attaches the server to the network and, when ip
+// is set, requests that private address. An existing attachment with a
+// different address is reported as an invalid request because Hetzner only
+// allows one attachment per server and network.
+func (s *RegionService) AttachInstanceToNetworkWithIP(ctx context.Context, instanceName, networkName, ip string) (bool, string, error) {
+	if !s.configuredFor(ctx) {
 		return false, "", ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, strings.TrimSpace(instanceName))
+	var requestedIP net.IP
+	if ip = strings.TrimSpace(ip); ip != "" {
+		requestedIP = net.ParseIP(ip)
+		if requestedIP == nil || requestedIP.To4() == nil {
+			return false, "", invalidRequestError(fmt.Sprintf("invalid private ipv4 address %q", ip))
+		}
+	}
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(instanceName))
 	if err != nil {
 		return false, "", err
 	}
 	if server == nil {
 		return false, "", notFoundError(fmt.Sprintf("instance %q not found", instanceName))
 	}
-	network, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(networkName))
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
 	if err != nil {
 		return false, "", err
 	}
@@ -733,6 +1477,9 @@ func (s *RegionService) AttachInstanceToNetwork(ctx context.Context, instanceNam
 		return false, "", notFoundError(fmt.Sprintf("network %q not found", networkName))
 	}
 	if server.Location != nil && server.Location.NetworkZone != "" {
+		if zone := cloudSubnetZone(network); zone != "" && !hasCloudSubnetInZone(network, server.Location.NetworkZone) {
+			return false, "", conflictError(fmt.Sprintf("instance %q is in network zone %s but network %q is in %s", instanceName, server.Location.NetworkZone, networkName, zone))
+		}
 		if ensureErr := s.ensureNetworkHasCloudSubnetInZone(ctx, network, server.Location.NetworkZone); ensureErr != nil {
 			return false, "", ensureErr
 		}
@@ -740,11 +1487,14 @@ func (s *RegionService) AttachInstanceToNetwork(ctx context.Context, instanceNam
 
 	for _, privateNet := range server.PrivateNet {
 		if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+			if requestedIP != nil && privateNet.IP != nil && !privateNet.IP.Equal(requestedIP) {
+				return false, "", invalidRequestError(fmt.Sprintf("instance %q is already attached to network %q with address %s", instanceName, networkName, privateNet.IP))
+			}
 			return true, "", nil
 		}
 	}
 
-	action, _, err := s.clientFor(ctx).Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{Network: network})
+	action, _, err := s.clientFor(ctx).Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{Network: network, IP: requestedIP})
 	if err != nil {
 		var apiErr hcloud.Error
 		if errors.As(err, &apiErr) && apiErr.Code == hcloud.ErrorCodeServerAlreadyAttached {
@@ -756,13 +1506,55 @@ func (s *RegionService) AttachInstanceToNetwork(ctx context.Context, instanceNam
 	actionID := ""
 	if action != nil {
 		actionID = fmt.Sprintf("%d", action.ID)
-		if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 			return false, actionID, waitErr
 		}
 	}
 	return true, actionID, nil
 }
 
+// DetachInstanceFromNetwork removes the server's attachment to the network.
+// It reports false when the server was not attached.
+func (s *RegionService) DetachInstanceFromNetwork(ctx context.Context, instanceName, networkName string) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(instanceName))
+	if err != nil {
+		return false, err
+	}
+	if server == nil {
+		return false, nil
+	}
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
+	if err != nil {
+		return false, err
+	}
+	if network == nil {
+		return false, nil
+	}
+	attached := false
+	for _, privateNet := range server.PrivateNet {
+		if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+			attached = true
+			break
+		}
+	}
+	if !attached {
+		return false, nil
+	}
+	action, _, err := s.clientFor(ctx).Server.DetachFromNetwork(ctx, server, hcloud.ServerDetachFromNetworkOpts{Network: network})
+	if err != nil {
+		return false, err
+	}
+	if action != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
+			return false, waitErr
+		}
+	}
+	return true, nil
+}
+
 func (s *RegionService) ensureNetworkHasCloudSubnetInZone(ctx context.Context, network *hcloud.Network, zone hcloud.NetworkZone) error {
 	if network == nil {
 		return fmt.Errorf("network is nil")
@@ -788,7 +1580,7 @@ func (s *RegionService) ensureNetworkHasCloudSubnetInZone(ctx context.Context, n
 		return err
 	}
 	if action != nil {
-		if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 			return waitErr
 		}
 	}
@@ -884,10 +1676,10 @@ func uint32ToIPv4(v uint32) net.IP {
 }
 
 func (s *RegionService) SyncInstanceNetworks(ctx context.Context, instanceName string, networkNames []string) error {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, strings.TrimSpace(instanceName))
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(instanceName))
 	if err != nil {
 		return err
 	}
@@ -906,7 +1698,7 @@ func (s *RegionService) SyncInstanceNetworks(ctx context.Context, instanceName s
 
 	desiredByID := map[int64]struct{}{}
 	for networkName := range desiredByName {
-		network, _, getErr := s.clientFor(ctx).Network.GetByName(ctx, networkName)
+		network, _, getErr := s.networkByName(ctx, networkName)
 		if getErr != nil {
 			return getErr
 		}
@@ -938,7 +1730,7 @@ func (s *RegionService) SyncInstanceNetworks(ctx context.Context, instanceName s
 		}
 	}
 
-	server, _, err = s.clientFor(ctx).Server.GetByName(ctx, strings.TrimSpace(instanceName))
+	server, _, err = s.serverByName(ctx, strings.TrimSpace(instanceName))
 	if err != nil {
 		return err
 	}
@@ -959,7 +1751,7 @@ func (s *RegionService) SyncInstanceNetworks(ctx context.Context, instanceName s
 			return detachErr
 		}
 		if action != nil {
-			if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+			if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 				return waitErr
 			}
 		}
@@ -968,17 +1760,17 @@ func (s *RegionService) SyncInstanceNetworks(ctx context.Context, instanceName s
 }
 
 func (s *RegionService) GetInstancePrivateIPv4(ctx context.Context, instanceName, networkName string) (string, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return "", ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, strings.TrimSpace(instanceName))
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(instanceName))
 	if err != nil {
 		return "", err
 	}
 	if server == nil {
 		return "", notFoundError(fmt.Sprintf("instance %q not found", instanceName))
 	}
-	network, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(networkName))
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
 	if err != nil {
 		return "", err
 	}
@@ -994,10 +1786,10 @@ func (s *RegionService) GetInstancePrivateIPv4(ctx context.Context, instanceName
 }
 
 func (s *RegionService) getServerByName(ctx context.Context, name string) (*hcloud.Server, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	server, _, err := s.clientFor(ctx).Server.GetByName(ctx, name)
+	server, _, err := s.serverByName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -1052,9 +1844,9 @@ func waitContext(ctx context.Context, delay time.Duration) error {
 	}
 }
 
+// ensureServerHasNetworkInterface attaches server to the workspace private
+// network of its zone so it can be powered on without public networking.
 func (s *RegionService) ensureServerHasNetworkInterface(ctx context.Context, server *hcloud.Server) error {
-	// TODO: This helper is conformance-mode behavior; replace with explicit
-	// tenant/workspace network orchestration before enabling in production.
 	if server == nil {
 		return fmt.Errorf("server is nil")
 	}
@@ -1066,20 +1858,43 @@ func (s *RegionService) ensureServerHasNetworkInterface(ctx context.Context, ser
 	if zone == "" {
 		return nil
 	}
+	network, err := s.workspacePrivateNetwork(ctx, zone)
+	if err != nil {
+		return err
+	}
 
-	networkName := fmt.Sprintf("secapi-proxy-bootstrap-%s", strings.ToLower(string(zone)))
-	network, _, err := s.clientFor(ctx).Network.GetByName(ctx, networkName)
+	action, _, err := s.clientFor(ctx).Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{Network: network})
 	if err != nil {
+		var apiErr hcloud.Error
+		if errors.As(err, &apiErr) && apiErr.Code == hcloud.ErrorCodeServerAlreadyAttached {
+			return nil
+		}
 		return err
 	}
+	if action != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
+			return waitErr
+		}
+	}
+	return nil
+}
+
+// workspacePrivateNetwork returns the proxy-managed private network of zone
+// in the workspace project, creating it or its cloud subnet when missing.
+func (s *RegionService) workspacePrivateNetwork(ctx context.Context, zone hcloud.NetworkZone) (*hcloud.Network, error) {
+	networkName := fmt.Sprintf("secapi-proxy-bootstrap-%s", strings.ToLower(string(zone)))
+	network, _, err := s.networkByName(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
 	_, subnetRange, subnetParseErr := net.ParseCIDR(networkSubnetCIDRForZone(zone))
 	if subnetParseErr != nil {
-		return subnetParseErr
+		return nil, subnetParseErr
 	}
 	if network == nil {
 		_, ipRange, parseErr := net.ParseCIDR(networkCIDRForZone(zone))
 		if parseErr != nil {
-			return parseErr
+			return nil, parseErr
 		}
 		network, _, err = s.clientFor(ctx).Network.Create(ctx, hcloud.NetworkCreateOpts{
 			Name:    networkName,
@@ -1093,7 +1908,7 @@ func (s *RegionService) ensureServerHasNetworkInterface(ctx context.Context, ser
 			},
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else if !hasCloudSubnetInZone(network, zone) {
 		addAction, _, addErr := s.clientFor(ctx).Network.AddSubnet(ctx, network, hcloud.NetworkAddSubnetOpts{
@@ -1104,29 +1919,19 @@ func (s *RegionService) ensureServerHasNetworkInterface(ctx context.Context, ser
 			},
 		})
 		if addErr != nil {
-			return addErr
+			return nil, addErr
 		}
 		if addAction != nil {
-			if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, addAction); waitErr != nil {
-				return waitErr
+			if waitErr := s.waitForActions(ctx, addAction); waitErr != nil {
+				return nil, waitErr
 			}
 		}
 	}
+	return network, nil
+}
 
-	action, _, err := s.clientFor(ctx).Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{Network: network})
-	if err != nil {
-		var apiErr hcloud.Error
-		if errors.As(err, &apiErr) && apiErr.Code == hcloud.ErrorCodeServerAlreadyAttached {
-			return nil
-		}
-		return err
-	}
-	if action != nil {
-		if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
-			return waitErr
-		}
-	}
-	return nil
+func serverHasPublicNet(server *hcloud.Server) bool {
+	return !server.PublicNet.IPv4.IsUnspecified() || !server.PublicNet.IPv6.IsUnspecified()
 }
 
 func networkCIDRForZone(zone hcloud.NetworkZone) string {
@@ -1167,6 +1972,19 @@ func hasCloudSubnetInZone(network *hcloud.Network, zone hcloud.NetworkZone) bool
 	return false
 }
 
+// cloudSubnetZone is the network zone of the first cloud subnet of network.
+func cloudSubnetZone(network *hcloud.Network) hcloud.NetworkZone {
+	if network == nil {
+		return ""
+	}
+	for _, subnet := range network.Subnets {
+		if subnet.Type == hcloud.NetworkSubnetTypeCloud && subnet.NetworkZone != "" {
+			return subnet.NetworkZone
+		}
+	}
+	return ""
+}
+
 func instanceFromServer(server *hcloud.Server) Instance {
 	sku := ""
 	if server.ServerType != nil {
@@ -1174,21 +1992,58 @@ func instanceFromServer(server *hcloud.Server) Instance {
 	}
 	image := ""
 	if server.Image != nil {
-		image = strings.ToLower(server.Image.Name)
+		image = serverImageName(server.Image)
 	}
 	region := ""
 	if server.Location != nil {
 		region = strings.ToLower(server.Location.Name)
 	}
+	zone := ""
+	if server.Datacenter != nil {
+		zone = strings.ToLower(server.Datacenter.Name)
+	}
+	volumeIDs := make([]int64, 0, len(server.Volumes))
+	for _, volume := range server.Volumes {
+		if volume != nil {
+			volumeIDs = append(volumeIDs, volume.ID)
+		}
+	}
+	placementGroup := ""
+	if server.PlacementGroup != nil {
+		placementGroup = strings.ToLower(server.PlacementGroup.Name)
+	}
 	return Instance{
-		ID:         server.ID,
-		Name:       strings.ToLower(server.Name),
-		SKUName:    sku,
-		ImageName:  image,
-		Region:     region,
-		PowerState: normalizePowerState(server.Status),
-		CreatedAt:  server.Created,
+		ID:             server.ID,
+		Name:           strings.ToLower(server.Name),
+		SKUName:        sku,
+		ImageName:      image,
+		Region:         region,
+		Zone:           zone,
+		PowerState:     normalizePowerState(server.Status),
+		Status:         string(server.Status),
+		Locked:         server.Locked,
+		Labels:         server.Labels,
+		VolumeIDs:      volumeIDs,
+		PublicIPv4:     publicIPv4(server),
+		PublicIPv6:     publicIPv6(server),
+		BackupWindow:   server.BackupWindow,
+		PlacementGroup: placementGroup,
+		CreatedAt:      server.Created,
+	}
+}
+
+func publicIPv4(server *hcloud.Server) string {
+	if server.PublicNet.IPv4.IsUnspecified() {
+		return ""
+	}
+	return server.PublicNet.IPv4.IP.String()
+}
+
+func publicIPv6(server *hcloud.Server) string {
+	if server.PublicNet.IPv6.IsUnspecified() || server.PublicNet.IPv6.Network == nil {
+		return ""
 	}
+	return server.PublicNet.IPv6.Network.String()
 }
 
 func blockStorageFromVolume(volume *hcloud.Volume) BlockStorage {
@@ -1206,6 +2061,9 @@ func blockStorageFromVolume(volume *hcloud.Volume) BlockStorage {
 		SizeGB:     volume.Size,
 		Region:     region,
 		AttachedTo: attachedTo,
+		DevicePath: volume.LinuxDevice,
+		Status:     string(volume.Status),
+		Labels:     volume.Labels,
 		CreatedAt:  volume.Created,
 	}
 }
@@ -0,0 +1,1030 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+var fakeLocationIDs = map[string]int{"nbg1": 1, "fsn1": 2}
+
+// fakeHCloud serves the subset of the Hetzner Cloud API used to create a
+// server: cx22 is only offered in nbg1, cpx21 and cx23 are offered in fsn1,
+// where cpx21 is the cheaper one. Its datacenters report fsn1 as sold out.
+// Every network is 10.0.0.0/16 with the subnet 10.0.0.0/24; network actions
+// are recorded by path.
+type fakeHCloud struct {
+	mu             sync.Mutex
+	created        []map[string]any
+	networkActions map[string]map[string]any
+}
+
+func (f *fakeHCloud) serverTypes() []map[string]any {
+	serverType := func(id int, name string, cores int, hourly string, locations ...string) map[string]any {
+		locs := make([]map[string]any, 0, len(locations))
+		prices := make([]map[string]any, 0, len(locations))
+		for _, loc := range locations {
+			locs = append(locs, map[string]any{"id": fakeLocationIDs[loc], "name": loc})
+			prices = append(prices, map[string]any{"location": loc, "price_hourly": map[string]any{"net": hourly, "gross": hourly}})
+		}
+		return map[string]any{"id": id, "name": name, "cores": cores, "memory": float64(cores * 2), "disk": 40, "architecture": "x86", "locations": locs, "prices": prices}
+	}
+	return []map[string]any{
+		serverType(1, "cx22", 2, "0.0060", "nbg1"),
+		serverType(2, "cpx21", 3, "0.0050", "fsn1", "nbg1"),
+		serverType(3, "cx23", 2, "0.0080", "fsn1"),
+	}
+}
+
+func (f *fakeHCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/servers":
+		writeFakeJSON(w, map[string]any{"servers": []any{}})
+	case r.Method == http.MethodGet && r.URL.Path == "/server_types":
+		items := []map[string]any{}
+		for _, item := range f.serverTypes() {
+			if name == "" || item["name"] == name {
+				items = append(items, item)
+			}
+		}
+		writeFakeJSON(w, map[string]any{"server_types": items})
+	case r.Method == http.MethodGet && r.URL.Path == "/images":
+		writeFakeJSON(w, map[string]any{"images": []any{map[string]any{"id": 10, "name": "ubuntu-24.04", "type": "system", "architecture": "x86"}}})
+	case r.Method == http.MethodGet && r.URL.Path == "/volumes":
+		items := []any{}
+		if name == "data" {
+			items = append(items, map[string]any{"id": 30, "name": "data", "location": map[string]any{"id": 1, "name": "nbg1"}})
+		}
+		writeFakeJSON(w, map[string]any{"volumes": items})
+	case r.Method == http.MethodGet && r.URL.Path == "/locations":
+		items := []map[string]any{}
+		for loc, id := range fakeLocationIDs {
+			if name == "" || loc == name {
+				items = append(items, map[string]any{"id": id, "name": loc, "network_zone": "eu-central"})
+			}
+		}
+		writeFakeJSON(w, map[string]any{"locations": items})
+	case r.Method == http.MethodGet && r.URL.Path == "/datacenters":
+		datacenter := func(id int, name, loc string, available ...int) map[string]any {
+			return map[string]any{
+				"id":           id,
+				"name":         name,
+				"location":     map[string]any{"id": fakeLocationIDs[loc], "name": loc, "network_zone": "eu-central"},
+				"server_types": map[string]any{"supported": []int{1, 2, 3}, "available": available, "available_for_migration": []int{}},
+			}
+		}
+		writeFakeJSON(w, map[string]any{"datacenters": []any{
+			datacenter(1, "nbg1-dc3", "nbg1", 1, 2),
+			datacenter(2, "fsn1-dc14", "fsn1"),
+		}})
+	case r.Method == http.MethodGet && r.URL.Path == "/networks":
+		writeFakeJSON(w, map[string]any{"networks": []any{map[string]any{
+			"id":       50,
+			"name":     name,
+			"ip_range": "10.0.0.0/16",
+			"subnets":  []any{map[string]any{"type": "cloud", "network_zone": "eu-central", "ip_range": "10.0.0.0/24"}},
+		}}})
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/networks/50/actions/"):
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		if f.networkActions == nil {
+			f.networkActions = map[string]map[string]any{}
+		}
+		f.networkActions[strings.TrimPrefix(r.URL.Path, "/networks/50/actions/")] = body
+		f.mu.Unlock()
+		writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 12, "status": "success"}})
+	case r.Method == http.MethodPost && r.URL.Path == "/servers":
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		for _, item := range f.serverTypes() {
+			if body["server_type"] == float64(item["id"].(int)) {
+				body["server_type"] = item["name"]
+			}
+		}
+		// Each fake location has a single datacenter with the same ID.
+		datacenter := ""
+		if id, ok := body["datacenter"].(string); ok {
+			body["location"] = id
+			datacenter = map[string]string{"1": "nbg1-dc3", "2": "fsn1-dc14"}[id]
+		}
+		for loc, id := range fakeLocationIDs {
+			if body["location"] == strconv.Itoa(id) {
+				body["location"] = loc
+			}
+		}
+		f.mu.Lock()
+		f.created = append(f.created, body)
+		f.mu.Unlock()
+		publicNet := map[string]any{}
+		if enabled, _ := body["public_net"].(map[string]any)["enable_ipv4"].(bool); enabled {
+			publicNet["ipv4"] = map[string]any{"ip": "203.0.113.10"}
+		}
+		writeFakeJSON(w, map[string]any{
+			"server": map[string]any{
+				"id":          100,
+				"name":        body["name"],
+				"status":      "initializing",
+				"server_type": map[string]any{"id": 2, "name": body["server_type"]},
+				"location":    map[string]any{"id": fakeLocationIDs[body["location"].(string)], "name": body["location"]},
+				"datacenter":  map[string]any{"name": datacenter},
+				"public_net":  publicNet,
+			},
+			"action": map[string]any{"id": 7, "status": "running"},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeFakeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// lenientPlacement are the conformance flags that substitute SKUs and drop
+// location constraints.
+var lenientPlacement = config.ConformanceFlags{SKUFallback: true, LocationFallback: true}
+
+func newFakeRegionService(t *testing.T, conformance config.ConformanceFlags) (*RegionService, *fakeHCloud) {
+	t.Helper()
+	fake := &fakeHCloud{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return &RegionService{
+		client:      hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")),
+		globalToken: "test",
+		conformance: conformance,
+	}, fake
+}
+
+func TestCreateInstanceStrictPlacementRejectsUnavailableSKU(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	_, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:      "vm1",
+		SKUName:   "cx22",
+		ImageName: "ubuntu-24.04",
+		Region:    "fsn1",
+	})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	for _, want := range []string{`region "fsn1"`, "available in regions: nbg1", "cx23, cpx21"} {
+		if !strings.Contains(providerErr.Message, want) {
+			t.Fatalf("expected %q in %q", want, providerErr.Message)
+		}
+	}
+	if len(fake.created) != 0 {
+		t.Fatalf("expected no server to be created, got %v", fake.created)
+	}
+}
+
+func TestCreateInstanceLenientPlacementSubstitutesSKU(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, lenientPlacement)
+	instance, created, actionID, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:      "vm1",
+		SKUName:   "cx22",
+		ImageName: "ubuntu-24.04",
+		Region:    "fsn1",
+	})
+	if err != nil {
+		t.Fatalf("expected lenient placement to create the server, got %v", err)
+	}
+	if !created || actionID != "7" || instance.SKUName != "cx23" || instance.Region != "fsn1" {
+		t.Fatalf("unexpected result: created=%t action=%q instance=%+v", created, actionID, instance)
+	}
+	if len(fake.created) != 1 || fake.created[0]["server_type"] != "cx23" {
+		t.Fatalf("expected one create with the substituted sku, got %v", fake.created)
+	}
+}
+
+func TestCreateInstanceSKUStrategyOrdersSubstitutes(t *testing.T) {
+	t.Parallel()
+
+	for strategy, want := range map[string]string{config.SKUStrategySmallest: "cx23", config.SKUStrategyCheapest: "cpx21", config.SKUStrategyReject: ""} {
+		service, fake := newFakeRegionService(t, lenientPlacement)
+		service.skuStrategy = strategy
+		instance, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+			Name:      "vm1",
+			SKUName:   "cx22",
+			ImageName: "ubuntu-24.04",
+			Region:    "fsn1",
+		})
+		if want == "" {
+			var providerErr ProviderError
+			if !errors.As(err, &providerErr) || providerErr.Code != "conflict" || len(fake.created) != 0 {
+				t.Fatalf("%s: expected the unavailable sku to be rejected, got %v and creates %v", strategy, err, fake.created)
+			}
+			continue
+		}
+		if err != nil || instance.SKUName != want {
+			t.Fatalf("%s: expected %s, got %+v (%v)", strategy, want, instance, err)
+		}
+	}
+}
+
+func TestCreateInstanceContextConformanceFlagsOverrideDefaults(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, lenientPlacement)
+	ctx := WithConformanceFlags(context.Background(), config.ConformanceFlags{})
+	_, _, _, err := service.CreateOrUpdateInstance(ctx, InstanceCreateRequest{
+		Name:      "vm1",
+		SKUName:   "cx22",
+		ImageName: "ubuntu-24.04",
+		Region:    "fsn1",
+	})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected the request flags to place strictly, got %v", err)
+	}
+	if len(fake.created) != 0 {
+		t.Fatalf("expected no server to be created, got %v", fake.created)
+	}
+}
+
+func TestCreateInstanceAttachesBootVolumeInItsLocation(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	_, created, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:       "vm1",
+		SKUName:    "cx22",
+		ImageName:  "ubuntu-24.04",
+		BootVolume: "data",
+	})
+	if err != nil || !created {
+		t.Fatalf("expected the server to be created, got created=%t err=%v", created, err)
+	}
+	volumes, _ := fake.created[0]["volumes"].([]any)
+	if len(volumes) != 1 || volumes[0] != float64(30) || fake.created[0]["location"] != "nbg1" {
+		t.Fatalf("expected volume 30 attached in nbg1, got %v", fake.created[0])
+	}
+
+	_, _, _, err = service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:       "vm2",
+		SKUName:    "cx22",
+		ImageName:  "ubuntu-24.04",
+		BootVolume: "missing",
+	})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected invalid_request for a missing boot volume, got %v", err)
+	}
+}
+
+func TestDeleteInstanceDetachesVolumesFirst(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			writeFakeJSON(w, map[string]any{"servers": []any{map[string]any{"id": 5, "name": "vm1", "volumes": []int{31}}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/31/actions/detach":
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 8, "status": "success"}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/servers/5":
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 9, "status": "running"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	deleted, actionID, err := service.DeleteInstance(context.Background(), "vm1")
+	if err != nil || !deleted || actionID != "9" {
+		t.Fatalf("expected delete with action 9, got %v %q (err %v)", deleted, actionID, err)
+	}
+	want := []string{"GET /servers", "POST /volumes/31/actions/detach", "DELETE /servers/5"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
+
+// runningServer serves vm1 (id 5), a running ubuntu-24.04 server of type
+// serverType in fsn1, with the server types of fakeHCloud and the images
+// ubuntu-24.04 and debian-12. Server actions are recorded by path.
+func runningServer(t *testing.T, serverType string, conformance config.ConformanceFlags) (*RegionService, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := []string{}
+	types := map[string]map[string]any{}
+	for _, item := range (&fakeHCloud{}).serverTypes() {
+		types[item["name"].(string)] = item
+	}
+	images := map[string]map[string]any{
+		"ubuntu-24.04": {"id": 10, "name": "ubuntu-24.04", "type": "system", "architecture": "x86"},
+		"debian-12":    {"id": 11, "name": "debian-12", "type": "system", "architecture": "x86"},
+	}
+	server := map[string]any{
+		"id":                5,
+		"name":              "vm1",
+		"status":            "running",
+		"server_type":       types[serverType],
+		"location":          map[string]any{"id": 2, "name": "fsn1", "network_zone": "eu-central"},
+		"image":             images["ubuntu-24.04"],
+		"primary_disk_size": 40,
+	}
+	actionIDs := map[string]int{"poweroff": 21, "change_type": 22, "poweron": 23, "rebuild": 24}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			writeFakeJSON(w, map[string]any{"servers": []any{server}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/5":
+			writeFakeJSON(w, map[string]any{"server": server})
+		case r.Method == http.MethodGet && r.URL.Path == "/server_types":
+			items := []any{}
+			for _, item := range (&fakeHCloud{}).serverTypes() {
+				if name == "" || item["name"] == name {
+					items = append(items, item)
+				}
+			}
+			writeFakeJSON(w, map[string]any{"server_types": items})
+		case r.Method == http.MethodGet && r.URL.Path == "/images":
+			items := []any{}
+			for imageName, image := range images {
+				if name == "" || imageName == name {
+					items = append(items, image)
+				}
+			}
+			writeFakeJSON(w, map[string]any{"images": items})
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/servers/5/actions/"):
+			mu.Lock()
+			calls = append(calls, r.URL.Path)
+			mu.Unlock()
+			action := map[string]any{"id": actionIDs[strings.TrimPrefix(r.URL.Path, "/servers/5/actions/")], "status": "success"}
+			writeFakeJSON(w, map[string]any{"action": action})
+		case r.Method == http.MethodGet && r.URL.Path == "/actions":
+			actions := []any{}
+			for _, id := range r.URL.Query()["id"] {
+				actions = append(actions, map[string]any{"id": json.Number(id), "status": "success"})
+			}
+			writeFakeJSON(w, map[string]any{"actions": actions})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &RegionService{
+		client:      hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test"), hcloud.WithPollOpts(hcloud.PollOpts{BackoffFunc: hcloud.ConstantBackoff(0)})),
+		globalToken: "test",
+		conformance: conformance,
+	}, &calls
+}
+
+func TestUpdateInstanceChangesTypeWhilePoweredOff(t *testing.T) {
+	t.Parallel()
+
+	service, calls := runningServer(t, "cx23", config.ConformanceFlags{})
+	_, created, actionID, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{Name: "vm1", SKUName: "cpx21", ImageName: "ubuntu-24.04"})
+	if err != nil || created {
+		t.Fatalf("expected an update, got created=%t err=%v", created, err)
+	}
+	want := []string{"/servers/5/actions/poweroff", "/servers/5/actions/change_type", "/servers/5/actions/poweron"}
+	if strings.Join(*calls, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected %v, got %v", want, *calls)
+	}
+	if actionID != "23" {
+		t.Fatalf("expected the power-on action, got %q", actionID)
+	}
+}
+
+func TestUpdateInstanceRebuildsOnImageChange(t *testing.T) {
+	t.Parallel()
+
+	service, calls := runningServer(t, "cx23", config.ConformanceFlags{})
+	_, _, actionID, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{Name: "vm1", SKUName: "cx23", ImageName: "debian-12"})
+	if err != nil || actionID != "24" || strings.Join(*calls, " ") != "/servers/5/actions/rebuild" {
+		t.Fatalf("expected only a rebuild, got %v (action %q, err %v)", *calls, actionID, err)
+	}
+
+	service, calls = runningServer(t, "cx23", config.ConformanceFlags{})
+	if _, _, actionID, err = service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{Name: "vm1", SKUName: "cpx21", ImageName: "debian-12"}); err != nil {
+		t.Fatal(err)
+	}
+	// The rebuild boots the server, so it is not powered on separately.
+	want := []string{"/servers/5/actions/poweroff", "/servers/5/actions/change_type", "/servers/5/actions/rebuild"}
+	if strings.Join(*calls, " ") != strings.Join(want, " ") || actionID != "24" {
+		t.Fatalf("expected %v, got %v (action %q)", want, *calls, actionID)
+	}
+}
+
+func TestUpdateInstanceRefusesSKUNotOfferedInItsLocation(t *testing.T) {
+	t.Parallel()
+
+	for name, flags := range map[string]config.ConformanceFlags{"strict": {}, "lenient": lenientPlacement} {
+		service, calls := runningServer(t, "cpx21", flags)
+		_, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{Name: "vm1", SKUName: "cx22", ImageName: "ubuntu-24.04"})
+		var providerErr ProviderError
+		if !errors.As(err, &providerErr) || providerErr.Code != "conflict" || !strings.Contains(providerErr.Message, "available in regions: nbg1") {
+			t.Fatalf("%s: expected a placement conflict, got %v", name, err)
+		}
+		if len(*calls) != 0 {
+			t.Fatalf("%s: expected the server to be left alone, got %v", name, *calls)
+		}
+	}
+
+	// cx23 is what lenient placement creates for cx22 in fsn1.
+	service, calls := runningServer(t, "cx23", lenientPlacement)
+	instance, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{Name: "vm1", SKUName: "cx22", ImageName: "ubuntu-24.04"})
+	if err != nil || instance.SKUName != "cx23" || len(*calls) != 0 {
+		t.Fatalf("expected the substitute to be kept, got %+v, calls %v (err %v)", instance, *calls, err)
+	}
+}
+
+func TestSetInstanceBackupsTogglesOnlyOnChange(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			servers := map[string]any{
+				"vm1": map[string]any{"id": 5, "name": "vm1"},
+				"vm2": map[string]any{"id": 6, "name": "vm2", "backup_window": "22-02"},
+			}
+			writeFakeJSON(w, map[string]any{"servers": []any{servers[r.URL.Query().Get("name")]}})
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/servers/"):
+			calls = append(calls, r.URL.Path)
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 12, "status": "running"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	if found, actionID, err := service.SetInstanceBackups(context.Background(), "vm1", true); err != nil || !found || actionID != "12" {
+		t.Fatalf("expected enable action 12, got %v %q (err %v)", found, actionID, err)
+	}
+	if _, actionID, err := service.SetInstanceBackups(context.Background(), "vm2", true); err != nil || actionID != "" {
+		t.Fatalf("expected no action for already enabled backups, got %q (err %v)", actionID, err)
+	}
+	if _, _, err := service.SetInstanceBackups(context.Background(), "vm2", false); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	want := []string{"/servers/5/actions/enable_backup", "/servers/6/actions/disable_backup"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+
+	service.conformance.BackupSkip = true
+	if _, actionID, err := service.SetInstanceBackups(context.Background(), "vm1", true); err != nil || actionID != "" || len(calls) != 2 {
+		t.Fatalf("expected conformance mode to leave backups alone, got %q (err %v)", actionID, err)
+	}
+}
+
+func TestCreateInstanceWithoutPublicNetworkJoinsPrivateNetwork(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	instance, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:          "vm1",
+		SKUName:       "cx22",
+		ImageName:     "ubuntu-24.04",
+		Region:        "nbg1",
+		PublicNetwork: &PublicNetwork{},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	publicNet, _ := fake.created[0]["public_net"].(map[string]any)
+	if publicNet["enable_ipv4"] != false || publicNet["enable_ipv6"] != false {
+		t.Fatalf("expected public networking disabled, got %v", publicNet)
+	}
+	networks, _ := fake.created[0]["networks"].([]any)
+	if len(networks) != 1 || networks[0] != float64(50) {
+		t.Fatalf("expected the server on the private network, got %v", fake.created[0]["networks"])
+	}
+	if instance.PublicIPv4 != "" || instance.PublicIPv6 != "" {
+		t.Fatalf("expected no public addresses, got %+v", instance)
+	}
+
+	instance, _, _, err = service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name:      "vm2",
+		SKUName:   "cx22",
+		ImageName: "ubuntu-24.04",
+		Region:    "nbg1",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, ok := fake.created[1]["networks"]; ok || instance.PublicIPv4 != "203.0.113.10" {
+		t.Fatalf("expected a dual-stack server with its public address, got %v / %+v", fake.created[1], instance)
+	}
+}
+
+func TestListRegionsReportsDatacenterCapabilities(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newFakeRegionService(t, config.ConformanceFlags{})
+	regions, err := service.ListRegions(context.Background())
+	if err != nil {
+		t.Fatalf("list regions: %v", err)
+	}
+	if len(regions) != 2 || regions[0].Name != "fsn1" || regions[1].Name != "nbg1" {
+		t.Fatalf("expected fsn1 and nbg1, got %+v", regions)
+	}
+	nbg1 := regions[1]
+	if nbg1.NetworkZone != "eu-central" || len(nbg1.Zones) != 1 || nbg1.Zones[0] != "nbg1-dc3" {
+		t.Fatalf("expected nbg1-dc3 in eu-central, got %+v", nbg1)
+	}
+	if !nbg1.BlockStorage || len(nbg1.Architectures) != 1 || nbg1.Architectures[0] != "x86" {
+		t.Fatalf("expected nbg1 to offer x86 servers and block storage, got %+v", nbg1)
+	}
+	if regions[0].BlockStorage {
+		t.Fatal("expected sold-out fsn1 not to offer block storage")
+	}
+
+	region, err := service.GetRegion(context.Background(), "NBG1")
+	if err != nil || region == nil || region.Name != "nbg1" {
+		t.Fatalf("expected case-insensitive lookup of nbg1, got %+v, %v", region, err)
+	}
+	if missing, err := service.GetRegion(context.Background(), "mars1"); err != nil || missing != nil {
+		t.Fatalf("expected no region for mars1, got %+v, %v", missing, err)
+	}
+
+	_, _, _, err = service.CreateOrUpdateBlockStorage(context.Background(), BlockStorageCreateRequest{Name: "data2", SizeGB: 10, Region: "fsn1"})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected invalid_request for block storage in fsn1, got %v", err)
+	}
+}
+
+func TestServerTypeForRegionFallsBackWhenPreferredIsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newFakeRegionService(t, config.ConformanceFlags{})
+	cases := []struct {
+		preferred, region, want string
+	}{
+		{preferred: "cx22", region: "nbg1", want: "cx22"},
+		{preferred: "cx22", region: "fsn1", want: "cx23"},
+		{preferred: "cax11", region: "nbg1", want: "cx22"},
+	}
+	for _, tc := range cases {
+		got, err := service.ServerTypeForRegion(context.Background(), tc.preferred, tc.region, "x86")
+		if err != nil {
+			t.Fatalf("%s in %s: %v", tc.preferred, tc.region, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s in %s: expected %s, got %s", tc.preferred, tc.region, tc.want, got)
+		}
+	}
+	if _, err := service.ServerTypeForRegion(context.Background(), "cax11", "hel1", "x86"); err == nil {
+		t.Fatal("expected an error for a region without server types")
+	}
+}
+
+func TestValidateInstanceCreateMatchesCreateWithoutCreating(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	instance, created, err := service.ValidateInstanceCreate(context.Background(), InstanceCreateRequest{
+		Name:      "vm1",
+		SKUName:   "cpx21",
+		ImageName: "ubuntu-24.04",
+		Region:    "fsn1",
+	})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if !created || instance.SKUName != "cpx21" || instance.Region != "fsn1" || instance.ImageName != "ubuntu-24.04" {
+		t.Fatalf("unexpected planned instance %+v (created=%t)", instance, created)
+	}
+
+	_, _, err = service.ValidateInstanceCreate(context.Background(), InstanceCreateRequest{
+		Name:      "vm1",
+		SKUName:   "cx22",
+		ImageName: "ubuntu-24.04",
+		Region:    "fsn1",
+	})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected the placement conflict of a real create, got %v", err)
+	}
+	if len(fake.created) != 0 {
+		t.Fatalf("expected no server to be created, got %v", fake.created)
+	}
+}
+
+func TestValidateSubnetCreateRejectsOverlap(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newFakeRegionService(t, config.ConformanceFlags{})
+	err := service.ValidateSubnetCreate(context.Background(), NetworkSubnetRequest{NetworkName: "net1", CIDR: "10.0.0.128/25", Zone: "nbg1"})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected overlap conflict, got %v", err)
+	}
+	if err := service.ValidateSubnetCreate(context.Background(), NetworkSubnetRequest{NetworkName: "net1", CIDR: "10.0.1.0/24", Zone: "nbg1"}); err != nil {
+		t.Fatalf("expected free range to validate, got %v", err)
+	}
+	err = service.ValidateSubnetCreate(context.Background(), NetworkSubnetRequest{NetworkName: "net1", CIDR: "10.1.0.0/24", Zone: "nbg1"})
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected out-of-range rejection, got %v", err)
+	}
+}
+
+func TestCreateNetworkAddsCloudSubnetInRegionZone(t *testing.T) {
+	t.Parallel()
+
+	var created map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks":
+			writeFakeJSON(w, map[string]any{"networks": []any{}})
+		case r.Method == http.MethodGet && r.URL.Path == "/locations":
+			writeFakeJSON(w, map[string]any{"locations": []any{map[string]any{"id": 4, "name": "ash", "network_zone": "us-east"}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/networks":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			created["id"] = 60
+			writeFakeJSON(w, map[string]any{"network": created})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	network, _, err := service.CreateOrUpdateNetwork(context.Background(), NetworkCreateRequest{Name: "net1", CIDR: "10.1.0.0/16", Region: "ash"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	subnets, _ := created["subnets"].([]any)
+	if len(subnets) != 1 {
+		t.Fatalf("expected one initial subnet, got %v", created["subnets"])
+	}
+	subnet := subnets[0].(map[string]any)
+	if subnet["network_zone"] != "us-east" || subnet["ip_range"] != "10.1.0.0/24" || subnet["type"] != "cloud" {
+		t.Fatalf("unexpected initial subnet %v", subnet)
+	}
+	if network.Zone != "us-east" {
+		t.Fatalf("expected zone us-east, got %q", network.Zone)
+	}
+}
+
+func TestNetworkRangeProblemFollowsHetznerLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		"10.0.0.0/16":     true,
+		"172.20.0.0/24":   true,
+		"192.168.0.0/16":  true,
+		"10.0.0.0/8":      false,
+		"10.0.0.0/25":     false,
+		"172.32.0.0/16":   false,
+		"203.0.113.0/24":  false,
+		"fd00::/64":       false,
+		"192.168.10.0/20": true,
+	}
+	for cidr, ok := range cases {
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problem := NetworkRangeProblem(ipRange); (problem == "") != ok {
+			t.Fatalf("%s: expected acceptable %v, got problem %q", cidr, ok, problem)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/networks" {
+			writeFakeJSON(w, map[string]any{"networks": []any{}})
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+	_, _, err := service.ValidateNetworkCreate(context.Background(), NetworkCreateRequest{Name: "net1", CIDR: "8.8.0.0/16"})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected the provider to reject a public range, got %v", err)
+	}
+}
+
+func TestAttachInstanceToNetworkRejectsOtherZone(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			writeFakeJSON(w, map[string]any{"servers": []any{map[string]any{"id": 5, "name": "vm1", "location": map[string]any{"id": 4, "name": "ash", "network_zone": "us-east"}}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/networks":
+			writeFakeJSON(w, map[string]any{"networks": []any{map[string]any{
+				"id":       50,
+				"name":     "net1",
+				"ip_range": "10.0.0.0/16",
+				"subnets":  []any{map[string]any{"type": "cloud", "network_zone": "eu-central", "ip_range": "10.0.0.0/24"}},
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	_, _, err := service.AttachInstanceToNetwork(context.Background(), "vm1", "net1")
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected zone conflict, got %v", err)
+	}
+}
+
+func TestCreateInstancePinsRequestedDatacenter(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	instance, _, _, err := service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name: "vm1", SKUName: "cpx21", ImageName: "ubuntu-24.04", Region: "nbg1", Zone: "nbg1-dc3",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if fake.created[0]["datacenter"] != "1" || instance.Zone != "nbg1-dc3" {
+		t.Fatalf("expected the server pinned to nbg1-dc3, got request %v and zone %q", fake.created[0], instance.Zone)
+	}
+
+	_, _, _, err = service.CreateOrUpdateInstance(context.Background(), InstanceCreateRequest{
+		Name: "vm2", SKUName: "cpx21", ImageName: "ubuntu-24.04", Region: "nbg1", Zone: "nbg1-dc9",
+	})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" || !strings.Contains(providerErr.Message, "valid zones: nbg1-dc3") {
+		t.Fatalf("expected the valid zones to be listed, got %v", err)
+	}
+}
+
+// attachedVolumeServer serves volume "data" attached to server 5 ("vm1"),
+// next to server 6 ("vm2"), and records the volume calls it receives; an
+// attach call is recorded with its body. detachCode, when set, is returned as
+// the Hetzner error code of the detach action.
+func attachedVolumeServer(t *testing.T, attached bool, detachCode string) (*RegionService, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.Path
+		if r.URL.Path == "/volumes/3/actions/attach" {
+			body, _ := io.ReadAll(r.Body)
+			call += " " + strings.TrimSpace(string(body))
+		}
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/volumes":
+			volume := map[string]any{"id": 3, "name": "data", "status": "available", "linux_device": "/dev/disk/by-id/scsi-0HC_Volume_3"}
+			if attached {
+				volume["server"] = 5
+			}
+			writeFakeJSON(w, map[string]any{"volumes": []any{volume}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/5":
+			writeFakeJSON(w, map[string]any{"server": map[string]any{"id": 5, "name": "vm1"}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			ids := map[string]int{"vm1": 5, "vm2": 6}
+			name := r.URL.Query().Get("name")
+			writeFakeJSON(w, map[string]any{"servers": []any{map[string]any{"id": ids[name], "name": name}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/3/actions/attach":
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 12, "status": "running"}})
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/3/actions/detach":
+			if detachCode != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusLocked)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": detachCode, "message": "volume is busy"}})
+				return
+			}
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 11, "status": "running"}})
+		case r.Method == http.MethodGet && r.URL.Path == "/actions":
+			actions := []any{}
+			for _, id := range r.URL.Query()["id"] {
+				actions = append(actions, map[string]any{"id": json.Number(id), "status": "success"})
+			}
+			writeFakeJSON(w, map[string]any{"actions": actions})
+		case r.Method == http.MethodDelete && r.URL.Path == "/volumes/3":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test"), hcloud.WithPollOpts(hcloud.PollOpts{BackoffFunc: hcloud.ConstantBackoff(0)})), globalToken: "test"}, &calls
+}
+
+func TestDeleteBlockStorageRefusesAttachedVolumeWithoutForce(t *testing.T) {
+	t.Parallel()
+
+	service, calls := attachedVolumeServer(t, true, "")
+	_, err := service.DeleteBlockStorage(context.Background(), "data", false)
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" || providerErr.Message != "volume is attached to instance vm1, detach first" {
+		t.Fatalf("expected attached conflict, got %v", err)
+	}
+	for _, call := range *calls {
+		if strings.HasPrefix(call, http.MethodDelete) || strings.HasPrefix(call, http.MethodPost) {
+			t.Fatalf("expected no detach or delete, got %v", *calls)
+		}
+	}
+}
+
+func TestDeleteBlockStorageForceDetachesFirst(t *testing.T) {
+	t.Parallel()
+
+	service, calls := attachedVolumeServer(t, true, "")
+	deleted, err := service.DeleteBlockStorage(context.Background(), "data", true)
+	if err != nil || !deleted {
+		t.Fatalf("expected forced delete to succeed, got %t, %v", deleted, err)
+	}
+	detach, remove := -1, -1
+	for i, call := range *calls {
+		switch call {
+		case "POST /volumes/3/actions/detach":
+			detach = i
+		case "DELETE /volumes/3":
+			remove = i
+		}
+	}
+	if detach < 0 || remove < detach {
+		t.Fatalf("expected detach before delete, got %v", *calls)
+	}
+
+	service, _ = attachedVolumeServer(t, true, string(hcloud.ErrorCodeLocked))
+	_, err = service.DeleteBlockStorage(context.Background(), "data", true)
+	if !hcloud.IsError(err, hcloud.ErrorCodeLocked) {
+		t.Fatalf("expected the locked detach error to surface, got %v", err)
+	}
+}
+
+func TestDetachBlockStorageIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	service, calls := attachedVolumeServer(t, false, "")
+	found, actionID, err := service.DetachBlockStorage(context.Background(), "data")
+	if err != nil || !found || actionID != "" {
+		t.Fatalf("expected a no-op detach, got %t, %q, %v", found, actionID, err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected only the volume lookup, got %v", *calls)
+	}
+
+	service, _ = attachedVolumeServer(t, true, string(hcloud.ErrorCodeLocked))
+	if _, _, err := service.DetachBlockStorage(context.Background(), "data"); !hcloud.IsError(err, hcloud.ErrorCodeLocked) {
+		t.Fatalf("expected the locked error to surface, got %v", err)
+	}
+}
+
+func TestAttachBlockStorageForwardsAutomountAndWaits(t *testing.T) {
+	t.Parallel()
+
+	for _, automount := range []bool{false, true} {
+		service, calls := attachedVolumeServer(t, false, "")
+		found, actionID, err := service.AttachBlockStorage(context.Background(), "data", "vm2", automount)
+		if err != nil || !found || actionID != "12" {
+			t.Fatalf("automount=%t: expected the attach to succeed, got %t, %q, %v", automount, found, actionID, err)
+		}
+		attach, waited := "", false
+		for _, call := range *calls {
+			switch {
+			case strings.HasPrefix(call, "POST /volumes/3/actions/attach"):
+				attach = call
+			case call == "GET /actions":
+				waited = true
+			}
+		}
+		if want := fmt.Sprintf(`"automount":%t`, automount); !strings.Contains(attach, `"server":6`) || !strings.Contains(attach, want) {
+			t.Fatalf("automount=%t: unexpected attach call %q", automount, attach)
+		}
+		if !waited {
+			t.Fatalf("automount=%t: expected the attach action to be awaited, got %v", automount, *calls)
+		}
+	}
+
+	service, _ := attachedVolumeServer(t, false, "")
+	volume, err := service.GetBlockStorage(context.Background(), "data")
+	if err != nil || volume == nil || volume.DevicePath != "/dev/disk/by-id/scsi-0HC_Volume_3" {
+		t.Fatalf("expected the linux device as device path, got %+v, %v", volume, err)
+	}
+}
+
+func TestAttachBlockStorageRefusesVolumeAttachedElsewhere(t *testing.T) {
+	t.Parallel()
+
+	service, calls := attachedVolumeServer(t, true, "")
+	_, _, err := service.AttachBlockStorage(context.Background(), "data", "vm2", false)
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "conflict" || providerErr.Message != "volume is attached to instance vm1, detach first" {
+		t.Fatalf("expected attached conflict, got %v", err)
+	}
+	for _, call := range *calls {
+		if strings.HasPrefix(call, http.MethodPost) {
+			t.Fatalf("expected no attach, got %v", *calls)
+		}
+	}
+
+	found, actionID, err := service.AttachBlockStorage(context.Background(), "data", "vm1", false)
+	if err != nil || !found || actionID != "" {
+		t.Fatalf("expected attaching to the current server to be a no-op, got %t, %q, %v", found, actionID, err)
+	}
+}
+
+func TestCreateOrUpdateBlockStorageReportsResizedVolume(t *testing.T) {
+	t.Parallel()
+
+	var resized map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/volumes":
+			writeFakeJSON(w, map[string]any{"volumes": []any{map[string]any{"id": 30, "name": "data", "size": 10, "location": map[string]any{"id": 1, "name": "nbg1"}}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/30/actions/resize":
+			_ = json.NewDecoder(r.Body).Decode(&resized)
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 11, "status": "running"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	block, created, actionID, err := service.CreateOrUpdateBlockStorage(context.Background(), BlockStorageCreateRequest{Name: "data", SizeGB: 20})
+	if err != nil || created || actionID != "11" {
+		t.Fatalf("expected a resize with action 11, got created %v action %q (err %v)", created, actionID, err)
+	}
+	if resized["size"] != float64(20) || block.SizeGB != 20 {
+		t.Fatalf("expected the volume resized to and reported at 20 GB, got request %v and size %d", resized, block.SizeGB)
+	}
+	var providerErr ProviderError
+	if _, _, _, err := service.CreateOrUpdateBlockStorage(context.Background(), BlockStorageCreateRequest{Name: "data", SizeGB: 5}); !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected a shrink to be rejected, got %v", err)
+	}
+}
+
+func TestCreateBlockStorageOutOfSpaceNamesNearbyRegionsWithCapacity(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeHCloud{}
+	creates := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/datacenters":
+			writeFakeJSON(w, map[string]any{"datacenters": []any{
+				map[string]any{"id": 1, "name": "nbg1-dc3", "location": map[string]any{"id": 1, "name": "nbg1", "network_zone": "eu-central"}, "server_types": map[string]any{"available": []int{1, 2}}},
+				map[string]any{"id": 2, "name": "fsn1-dc14", "location": map[string]any{"id": 2, "name": "fsn1", "network_zone": "eu-central"}, "server_types": map[string]any{"available": []int{2, 3}}},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes":
+			creates++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": "no_space_left_in_location", "message": "no space left in location"}})
+		default:
+			fake.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test", availCacheTTL: time.Minute}
+
+	_, _, _, err := service.CreateOrUpdateBlockStorage(context.Background(), BlockStorageCreateRequest{Name: "vol1", SizeGB: 10, Region: "nbg1"})
+	var providerErr ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != "unavailable" {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+	if want := `no block storage capacity left in region "nbg1"; regions in network zone eu-central with capacity: fsn1`; providerErr.Message != want {
+		t.Fatalf("expected %q, got %q", want, providerErr.Message)
+	}
+	if creates != 1 {
+		t.Fatalf("expected a single create without moving the volume, got %d", creates)
+	}
+
+	capacities, err := service.VolumeCapacityByLocation(context.Background())
+	if err != nil {
+		t.Fatalf("capacity: %v", err)
+	}
+	available := map[string]bool{}
+	for _, capacity := range capacities {
+		available[capacity.Region] = capacity.Available
+	}
+	if available["nbg1"] || !available["fsn1"] {
+		t.Fatalf("expected nbg1 exhausted and fsn1 available, got %+v", capacities)
+	}
+}
@@ -0,0 +1,39 @@
+package hetzner
+
+import (
+	"context"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+)
+
+type conformanceFlagsContextKey struct{}
+
+// WithConformanceFlags makes the calls made with ctx follow flags instead of
+// the conformance flags the service was configured with, so one service can
+// serve conformance and production behavior side by side.
+func WithConformanceFlags(ctx context.Context, flags config.ConformanceFlags) context.Context {
+	return context.WithValue(ctx, conformanceFlagsContextKey{}, flags)
+}
+
+// ConformanceFlagsFrom returns the flags set on ctx with WithConformanceFlags,
+// or defaults when there are none.
+func ConformanceFlagsFrom(ctx context.Context, defaults config.ConformanceFlags) config.ConformanceFlags {
+	if ctx == nil {
+		return defaults
+	}
+	if flags, ok := ctx.Value(conformanceFlagsContextKey{}).(config.ConformanceFlags); ok {
+		return flags
+	}
+	return defaults
+}
+
+func (s *RegionService) conformanceFor(ctx context.Context) config.ConformanceFlags {
+	return ConformanceFlagsFrom(ctx, s.conformance)
+}
+
+// skuFallback reports whether an instance create may substitute another
+// server type for one not offered in the requested region. The reject SKU
+// strategy turns the substitution off even in conformance mode.
+func (s *RegionService) skuFallback(ctx context.Context) bool {
+	return s.conformanceFor(ctx).SKUFallback && s.skuStrategy != config.SKUStrategyReject
+}
@@ -2,10 +2,16 @@ package hetzner
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+// ErrCredentialRevoked is the cancellation cause of request contexts whose
+// workspace credential was replaced or deleted while they were in flight.
+var ErrCredentialRevoked = errors.New("workspace credential revoked")
+
 type workspaceCredentialContextKey struct{}
 
 type WorkspaceCredential struct {
@@ -21,13 +27,23 @@ func WithWorkspaceCredential(ctx context.Context, credential WorkspaceCredential
 	return context.WithValue(ctx, workspaceCredentialContextKey{}, credential)
 }
 
+// clientFor resolves the Hetzner client for ctx: the workspace credential on
+// ctx, else the global token set through SetGlobalToken, else the one from
+// SECA_HETZNER_TOKEN.
 func (s *RegionService) clientFor(ctx context.Context) *hcloud.Client {
 	cred, ok := workspaceCredentialFromContext(ctx)
 	if !ok || cred.Token == "" {
-		return s.client
+		client, _ := s.sharedClient()
+		return client
 	}
+	return s.tokenClient(cred)
+}
 
-	opts := []hcloud.ClientOption{hcloud.WithToken(cred.Token)}
+func (s *RegionService) tokenClient(cred WorkspaceCredential) *hcloud.Client {
+	opts := append(
+		readRetryClientOptions(callTimeoutTransport{base: revocationAwareTransport{base: instrumentTransport(auditCalls(traceTransport(http.DefaultTransport), s.audit), s.calls)}, timeouts: s.timeouts}, s.readRetry),
+		hcloud.WithToken(cred.Token),
+	)
 	if cred.CloudAPIURL != "" {
 		opts = append(opts, hcloud.WithEndpoint(cred.CloudAPIURL))
 	} else {
@@ -41,6 +57,16 @@ func (s *RegionService) clientFor(ctx context.Context) *hcloud.Client {
 	return hcloud.NewClient(opts...)
 }
 
+// configuredFor reports whether clientFor(ctx) has a token to call Hetzner
+// with: the workspace credential on ctx or, failing that, a global token.
+func (s *RegionService) configuredFor(ctx context.Context) bool {
+	if cred, ok := workspaceCredentialFromContext(ctx); ok && cred.Token != "" {
+		return true
+	}
+	_, configured := s.sharedClient()
+	return configured
+}
+
 func workspaceCredentialFromContext(ctx context.Context) (WorkspaceCredential, bool) {
 	if ctx == nil {
 		return WorkspaceCredential{}, false
@@ -48,3 +74,30 @@ func workspaceCredentialFromContext(ctx context.Context) (WorkspaceCredential, b
 	cred, ok := ctx.Value(workspaceCredentialContextKey{}).(WorkspaceCredential)
 	return cred, ok
 }
+
+// revocationAwareTransport refuses to send, or hand back, a response for a
+// request whose context was cancelled because its credential was revoked.
+type revocationAwareTransport struct {
+	base http.RoundTripper
+}
+
+func (t revocationAwareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := revokedCause(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if revokedErr := revokedCause(req.Context()); revokedErr != nil {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return nil, revokedErr
+	}
+	return resp, err
+}
+
+func revokedCause(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrCredentialRevoked) {
+		return cause
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestWorkspaceCredentialConfiguresServiceWithoutGlobalToken(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeFakeJSON(w, map[string]any{"networks": []any{}})
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL))}
+
+	if _, err := service.ListNetworks(context.Background()); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured without any token, got %v", err)
+	}
+	if skus, err := service.ListComputeSKUs(context.Background()); err != nil || len(skus) == 0 {
+		t.Fatalf("expected the static SKU catalog without any token, got %d SKUs (err %v)", len(skus), err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no Hetzner calls without any token, got %d", calls)
+	}
+
+	ctx := WithWorkspaceCredential(context.Background(), WorkspaceCredential{Token: "workspace-token", CloudAPIURL: srv.URL})
+	if _, err := service.ListNetworks(ctx); err != nil {
+		t.Fatalf("expected the workspace credential to be used, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one Hetzner call with the workspace credential, got %d", calls)
+	}
+}
@@ -0,0 +1,87 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is the cause of cancelling provider work that was still
+// running when the shutdown grace period ended.
+var ErrShuttingDown = errors.New("proxy is shutting down")
+
+// drainAbortGrace bounds how long cancelled work gets to record its outcome
+// once the grace period is over.
+const drainAbortGrace = 5 * time.Second
+
+// Drainer tracks the provider work in flight, such as action waits and
+// background follow-ups of accepted requests, so shutdown can let it finish.
+type Drainer struct {
+	wg      sync.WaitGroup
+	aborted context.Context
+	abort   context.CancelCauseFunc
+}
+
+func NewDrainer() *Drainer {
+	aborted, abort := context.WithCancelCause(context.Background())
+	return &Drainer{aborted: aborted, abort: abort}
+}
+
+// Drain waits for the tracked work until ctx is done, then cancels what is
+// left with ErrShuttingDown and gives it drainAbortGrace to return. It
+// reports whether everything finished in time.
+func (d *Drainer) Drain(ctx context.Context) bool {
+	idle := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return true
+	case <-ctx.Done():
+	}
+	d.abort(ErrShuttingDown)
+	select {
+	case <-idle:
+	case <-time.After(drainAbortGrace):
+	}
+	return false
+}
+
+type drainerContextKey struct{}
+
+// WithDrainer makes provider work started with ctx count as in flight for d.
+func WithDrainer(ctx context.Context, d *Drainer) context.Context {
+	return context.WithValue(ctx, drainerContextKey{}, d)
+}
+
+// TrackInFlight counts the work done with the returned context as in flight
+// until done is called. The context is cancelled with ErrShuttingDown when
+// the drainer of ctx gives up waiting. Without a drainer ctx is returned as
+// is.
+func TrackInFlight(ctx context.Context) (context.Context, func()) {
+	d, _ := ctx.Value(drainerContextKey{}).(*Drainer)
+	if d == nil {
+		return ctx, func() {}
+	}
+	d.wg.Add(1)
+	tracked, cancel := context.WithCancelCause(ctx)
+	if d.aborted.Err() != nil {
+		cancel(context.Cause(d.aborted))
+	}
+	stop := context.AfterFunc(d.aborted, func() {
+		cancel(context.Cause(d.aborted))
+	})
+	return tracked, func() {
+		stop()
+		cancel(nil)
+		d.wg.Done()
+	}
+}
+
+// shuttingDown reports whether ctx was cancelled by a drainer giving up.
+func shuttingDown(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrShuttingDown)
+}
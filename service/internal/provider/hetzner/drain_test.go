@@ -0,0 +1,50 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForTrackedWork(t *testing.T) {
+	t.Parallel()
+
+	drainer := NewDrainer()
+	_, done := TrackInFlight(WithDrainer(context.Background(), drainer))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		done()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !drainer.Drain(ctx) {
+		t.Fatal("expected the tracked work to finish within the grace period")
+	}
+}
+
+func TestDrainCancelsWorkLeftAfterTheGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	drainer := NewDrainer()
+	tracked, done := TrackInFlight(WithDrainer(context.Background(), drainer))
+	interrupted := make(chan error, 1)
+	go func() {
+		defer done()
+		<-tracked.Done()
+		interrupted <- context.Cause(tracked)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if drainer.Drain(ctx) {
+		t.Fatal("expected the drain to report unfinished work")
+	}
+	if err := <-interrupted; !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected ErrShuttingDown as the cause, got %v", err)
+	}
+	late, lateDone := TrackInFlight(WithDrainer(context.Background(), drainer))
+	defer lateDone()
+	if !shuttingDown(late) {
+		t.Fatal("expected work started after the drain gave up to be cancelled right away")
+	}
+}
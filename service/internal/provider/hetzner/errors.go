@@ -21,3 +21,11 @@ func invalidRequestError(message string) error {
 func notFoundError(message string) error {
 	return ProviderError{Code: "not_found", Message: message}
 }
+
+func conflictError(message string) error {
+	return ProviderError{Code: "conflict", Message: message}
+}
+
+func unavailableError(message string) error {
+	return ProviderError{Code: "unavailable", Message: message}
+}
@@ -6,10 +6,13 @@ import (
 )
 
 type fallbackRegionRecord struct {
-	Name    string   `json:"name"`
-	City    string   `json:"city"`
-	Country string   `json:"country"`
-	Zones   []string `json:"zones"`
+	Name          string   `json:"name"`
+	City          string   `json:"city"`
+	Country       string   `json:"country"`
+	Zones         []string `json:"zones"`
+	NetworkZone   string   `json:"networkZone"`
+	Architectures []string `json:"architectures"`
+	BlockStorage  bool     `json:"blockStorage"`
 }
 
 var (
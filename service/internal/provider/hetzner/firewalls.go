@@ -3,6 +3,9 @@ package hetzner
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,26 +13,53 @@ import (
 )
 
 type SecurityGroup struct {
+	ID        int64
 	Name      string
 	Labels    map[string]string
 	Rules     []SecurityGroupRule
+	ServerIDs []int64
 	CreatedAt time.Time
 }
 
+// SecurityGroupRule is one SECA rule. A rule with several port ranges maps
+// to one Hetzner firewall rule per range; the Hetzner rule description keeps
+// the SECA rule index so the group can be reassembled on read.
 type SecurityGroupRule struct {
 	Direction string
+	Protocol  string
+	Ports     []PortRange
+	CIDRs     []string
+	Version   string
+}
+
+type PortRange struct {
+	From int
+	To   int
 }
 
 type SecurityGroupCreateRequest struct {
 	Name   string
 	Labels map[string]string
+	Rules  []SecurityGroupRule
 }
 
+const (
+	SecurityGroupDirectionIngress = "ingress"
+	SecurityGroupDirectionEgress  = "egress"
+
+	securityGroupRuleDescriptionPrefix = "seca-rule-"
+)
+
 func (s *RegionService) ListSecurityGroups(ctx context.Context) ([]SecurityGroup, error) {
-	if !s.configured {
+	return s.ListSecurityGroupsByLabels(ctx, nil)
+}
+
+// ListSecurityGroupsByLabels lists the firewalls carrying every given label.
+func (s *RegionService) ListSecurityGroupsByLabels(ctx context.Context, labels map[string]string) ([]SecurityGroup, error) {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	items, err := s.clientFor(ctx).Firewall.All(ctx)
+	items, err := s.clientFor(ctx).Firewall.AllWithOpts(ctx, hcloud.FirewallListOpts{ListOpts: labelListOpts(labels)})
 	if err != nil {
 		return nil, err
 	}
@@ -44,10 +74,10 @@ func (s *RegionService) ListSecurityGroups(ctx context.Context) ([]SecurityGroup
 }
 
 func (s *RegionService) GetSecurityGroup(ctx context.Context, name string) (*SecurityGroup, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	item, _, err := s.clientFor(ctx).Firewall.GetByName(ctx, strings.TrimSpace(name))
+	item, _, err := s.firewallByName(ctx, strings.TrimSpace(name))
 	if err != nil {
 		return nil, err
 	}
@@ -58,8 +88,60 @@ func (s *RegionService) GetSecurityGroup(ctx context.Context, name string) (*Sec
 	return &group, nil
 }
 
+// GetSecurityGroupByID looks a firewall up by its Hetzner ID, which stays
+// stable for firewalls the proxy did not create and does not name.
+func (s *RegionService) GetSecurityGroupByID(ctx context.Context, id int64) (*SecurityGroup, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).Firewall.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, nil
+	}
+	group := securityGroupFromHCloud(item)
+	return &group, nil
+}
+
+// ManageSecurityGroup renames and relabels an existing firewall so that it is
+// addressed like a firewall created through CreateOrUpdateSecurityGroup. The
+// rules are left untouched.
+func (s *RegionService) ManageSecurityGroup(ctx context.Context, id int64, name string, labels map[string]string) (*SecurityGroup, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).Firewall.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, notFoundError(fmt.Sprintf("firewall %d not found", id))
+	}
+	name = strings.TrimSpace(name)
+	if !strings.EqualFold(item.Name, name) {
+		clash, _, err := s.clientFor(ctx).Firewall.GetByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if clash != nil && clash.ID != item.ID {
+			return nil, conflictError(fmt.Sprintf("firewall name %q is already in use", name))
+		}
+	}
+	updated, _, err := s.clientFor(ctx).Firewall.Update(ctx, item, hcloud.FirewallUpdateOpts{
+		Name:   name,
+		Labels: labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	group := securityGroupFromHCloud(updated)
+	return &group, nil
+}
+
 func (s *RegionService) CreateOrUpdateSecurityGroup(ctx context.Context, req SecurityGroupCreateRequest) (*SecurityGroup, bool, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, false, ErrNotConfigured
 	}
 	name := strings.TrimSpace(req.Name)
@@ -67,7 +149,12 @@ func (s *RegionService) CreateOrUpdateSecurityGroup(ctx context.Context, req Sec
 		return nil, false, invalidRequestError("security group name is required")
 	}
 
-	existing, _, err := s.clientFor(ctx).Firewall.GetByName(ctx, name)
+	rules, err := firewallRulesFromSecurityGroupRules(req.Rules)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, _, err := s.firewallByName(ctx, name)
 	if err != nil {
 		return nil, false, err
 	}
@@ -78,6 +165,16 @@ func (s *RegionService) CreateOrUpdateSecurityGroup(ctx context.Context, req Sec
 		if updateErr != nil {
 			return nil, false, updateErr
 		}
+		actions, _, setErr := s.clientFor(ctx).Firewall.SetRules(ctx, updated, hcloud.FirewallSetRulesOpts{Rules: rules})
+		if setErr != nil {
+			return nil, false, setErr
+		}
+		if len(actions) > 0 {
+			if waitErr := s.waitForActions(ctx, actions...); waitErr != nil {
+				return nil, false, waitErr
+			}
+		}
+		updated.Rules = rules
 		group := securityGroupFromHCloud(updated)
 		return &group, false, nil
 	}
@@ -85,6 +182,7 @@ func (s *RegionService) CreateOrUpdateSecurityGroup(ctx context.Context, req Sec
 	created, _, err := s.clientFor(ctx).Firewall.Create(ctx, hcloud.FirewallCreateOpts{
 		Name:   name,
 		Labels: req.Labels,
+		Rules:  rules,
 	})
 	if err != nil {
 		return nil, false, err
@@ -96,11 +194,116 @@ func (s *RegionService) CreateOrUpdateSecurityGroup(ctx context.Context, req Sec
 	return &group, true, nil
 }
 
+// ValidateSecurityGroupCreate makes the checks of CreateOrUpdateSecurityGroup
+// with read-only calls. It returns the security group the request would leave
+// behind and whether it would be created.
+func (s *RegionService) ValidateSecurityGroupCreate(ctx context.Context, req SecurityGroupCreateRequest) (*SecurityGroup, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, false, invalidRequestError("security group name is required")
+	}
+	rules, err := firewallRulesFromSecurityGroupRules(req.Rules)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, _, err := s.firewallByName(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		planned := *existing
+		planned.Labels = req.Labels
+		planned.Rules = rules
+		group := securityGroupFromHCloud(&planned)
+		return &group, false, nil
+	}
+	group := securityGroupFromHCloud(&hcloud.Firewall{Name: name, Labels: req.Labels, Rules: rules})
+	return &group, true, nil
+}
+
+// SyncInstanceSecurityGroups applies the named firewalls to the server and
+// removes the other firewalls managed reports as owned by the caller.
+// Firewalls it does not own, such as those applied from the console, and
+// firewalls reaching the server through a label selector are left alone.
+func (s *RegionService) SyncInstanceSecurityGroups(ctx context.Context, instanceName string, securityGroupNames []string, managed func(SecurityGroup) bool) error {
+	if !s.configuredFor(ctx) {
+		return ErrNotConfigured
+	}
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(instanceName))
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return notFoundError(fmt.Sprintf("instance %q not found", instanceName))
+	}
+	firewalls, err := s.clientFor(ctx).Firewall.All(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*hcloud.Firewall, len(firewalls))
+	for _, firewall := range firewalls {
+		if firewall != nil {
+			byName[strings.ToLower(firewall.Name)] = firewall
+		}
+	}
+	desired := map[int64]struct{}{}
+	for _, name := range securityGroupNames {
+		if id, ok := pinnedResourceID(ctx, ResourceFirewall, name); ok {
+			desired[id] = struct{}{}
+			continue
+		}
+		firewall, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return notFoundError(fmt.Sprintf("security group %q not found", name))
+		}
+		desired[firewall.ID] = struct{}{}
+	}
+
+	target := []hcloud.FirewallResource{{
+		Type:   hcloud.FirewallResourceTypeServer,
+		Server: &hcloud.FirewallResourceServer{ID: server.ID},
+	}}
+	for _, firewall := range firewalls {
+		if firewall == nil {
+			continue
+		}
+		applied := false
+		for _, resource := range firewall.AppliedTo {
+			if resource.Type == hcloud.FirewallResourceTypeServer && resource.Server != nil && resource.Server.ID == server.ID {
+				applied = true
+				break
+			}
+		}
+		_, want := desired[firewall.ID]
+		var actions []*hcloud.Action
+		switch {
+		case want && !applied:
+			actions, _, err = s.clientFor(ctx).Firewall.ApplyResources(ctx, firewall, target)
+		case !want && applied && managed(securityGroupFromHCloud(firewall)):
+			actions, _, err = s.clientFor(ctx).Firewall.RemoveResources(ctx, firewall, target)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(actions) > 0 {
+			if waitErr := s.waitForActions(ctx, actions...); waitErr != nil {
+				return waitErr
+			}
+		}
+	}
+	return nil
+}
+
 func (s *RegionService) DeleteSecurityGroup(ctx context.Context, name string) (bool, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return false, ErrNotConfigured
 	}
-	item, _, err := s.clientFor(ctx).Firewall.GetByName(ctx, strings.TrimSpace(name))
+	item, _, err := s.firewallByName(ctx, strings.TrimSpace(name))
 	if err != nil {
 		return false, err
 	}
@@ -115,18 +318,211 @@ func (s *RegionService) DeleteSecurityGroup(ctx context.Context, name string) (b
 }
 
 func securityGroupFromHCloud(item *hcloud.Firewall) SecurityGroup {
-	rules := make([]SecurityGroupRule, 0, len(item.Rules))
-	for _, rule := range item.Rules {
-		if direction := strings.TrimSpace(string(rule.Direction)); direction != "" {
-			rules = append(rules, SecurityGroupRule{
-				Direction: strings.ToLower(direction),
-			})
+	var serverIDs []int64
+	for _, resource := range item.AppliedTo {
+		if resource.Type == hcloud.FirewallResourceTypeServer && resource.Server != nil {
+			serverIDs = append(serverIDs, resource.Server.ID)
 		}
 	}
 	return SecurityGroup{
+		ID:        item.ID,
 		Name:      strings.ToLower(strings.TrimSpace(item.Name)),
 		Labels:    item.Labels,
-		Rules:     rules,
+		Rules:     securityGroupRulesFromFirewallRules(item.Rules),
+		ServerIDs: serverIDs,
 		CreatedAt: item.Created,
 	}
 }
+
+func firewallRulesFromSecurityGroupRules(rules []SecurityGroupRule) ([]hcloud.FirewallRule, error) {
+	out := make([]hcloud.FirewallRule, 0, len(rules))
+	for i, rule := range rules {
+		var direction hcloud.FirewallRuleDirection
+		switch strings.ToLower(strings.TrimSpace(rule.Direction)) {
+		case SecurityGroupDirectionIngress, "in":
+			direction = hcloud.FirewallRuleDirectionIn
+		case SecurityGroupDirectionEgress, "out":
+			direction = hcloud.FirewallRuleDirectionOut
+		default:
+			return nil, invalidRequestError(fmt.Sprintf("rule %d: unsupported direction %q", i, rule.Direction))
+		}
+		protocol := hcloud.FirewallRuleProtocol(strings.ToLower(strings.TrimSpace(rule.Protocol)))
+		cidrs, err := firewallRuleCIDRs(rule.CIDRs, rule.Version)
+		if err != nil {
+			return nil, invalidRequestError(fmt.Sprintf("rule %d: %s", i, err))
+		}
+		description := securityGroupRuleDescriptionPrefix + strconv.Itoa(i)
+		base := hcloud.FirewallRule{Direction: direction, Protocol: protocol, Description: hcloud.Ptr(description)}
+		if direction == hcloud.FirewallRuleDirectionIn {
+			base.SourceIPs = cidrs
+		} else {
+			base.DestinationIPs = cidrs
+		}
+		switch protocol {
+		case hcloud.FirewallRuleProtocolTCP, hcloud.FirewallRuleProtocolUDP:
+			if len(rule.Ports) == 0 {
+				fwRule := base
+				fwRule.Port = hcloud.Ptr("any")
+				out = append(out, fwRule)
+				continue
+			}
+			for _, ports := range rule.Ports {
+				fwRule := base
+				port := strconv.Itoa(ports.From)
+				if ports.To > ports.From {
+					port += "-" + strconv.Itoa(ports.To)
+				}
+				fwRule.Port = hcloud.Ptr(port)
+				out = append(out, fwRule)
+			}
+		case hcloud.FirewallRuleProtocolICMP, hcloud.FirewallRuleProtocolESP, hcloud.FirewallRuleProtocolGRE:
+			if len(rule.Ports) > 0 {
+				return nil, invalidRequestError(fmt.Sprintf("rule %d: protocol %s does not take ports", i, protocol))
+			}
+			out = append(out, base)
+		case "", "any":
+			// Hetzner has no catch-all protocol; approximate it with the
+			// protocols a workload usually needs.
+			if len(rule.Ports) > 0 {
+				return nil, invalidRequestError(fmt.Sprintf("rule %d: ports require protocol tcp or udp", i))
+			}
+			for _, p := range []hcloud.FirewallRuleProtocol{hcloud.FirewallRuleProtocolTCP, hcloud.FirewallRuleProtocolUDP} {
+				fwRule := base
+				fwRule.Protocol = p
+				fwRule.Port = hcloud.Ptr("any")
+				out = append(out, fwRule)
+			}
+			fwRule := base
+			fwRule.Protocol = hcloud.FirewallRuleProtocolICMP
+			out = append(out, fwRule)
+		default:
+			return nil, invalidRequestError(fmt.Sprintf("rule %d: unsupported protocol %q", i, rule.Protocol))
+		}
+	}
+	return out, nil
+}
+
+func firewallRuleCIDRs(cidrs []string, version string) ([]net.IPNet, error) {
+	if len(cidrs) == 0 {
+		var defaults []string
+		switch strings.ToLower(strings.TrimSpace(version)) {
+		case "ipv4":
+			defaults = []string{"0.0.0.0/0"}
+		case "ipv6":
+			defaults = []string{"::/0"}
+		case "":
+			defaults = []string{"0.0.0.0/0", "::/0"}
+		default:
+			return nil, fmt.Errorf("unsupported ip version %q", version)
+		}
+		cidrs = defaults
+	}
+	out := make([]net.IPNet, 0, len(cidrs))
+	for _, raw := range cidrs {
+		raw = strings.TrimSpace(raw)
+		if !strings.Contains(raw, "/") {
+			if ip := net.ParseIP(raw); ip != nil {
+				if ip.To4() != nil {
+					raw += "/32"
+				} else {
+					raw += "/128"
+				}
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", raw)
+		}
+		out = append(out, *ipNet)
+	}
+	return out, nil
+}
+
+// securityGroupRulesFromFirewallRules reverses firewallRulesFromSecurityGroupRules.
+// Rules created outside this service have no index and stay one rule each.
+func securityGroupRulesFromFirewallRules(rules []hcloud.FirewallRule) []SecurityGroupRule {
+	out := make([]SecurityGroupRule, 0, len(rules))
+	byIndex := map[int]int{}
+	for _, fwRule := range rules {
+		index := -1
+		if fwRule.Description != nil && strings.HasPrefix(*fwRule.Description, securityGroupRuleDescriptionPrefix) {
+			if parsed, err := strconv.Atoi(strings.TrimPrefix(*fwRule.Description, securityGroupRuleDescriptionPrefix)); err == nil {
+				index = parsed
+			}
+		}
+		ports, hasPorts := parseFirewallPort(fwRule.Port)
+		if pos, ok := byIndex[index]; ok && index >= 0 {
+			if !strings.EqualFold(out[pos].Protocol, string(fwRule.Protocol)) {
+				// Only the "any" expansion spreads one rule over protocols.
+				out[pos].Protocol = ""
+				out[pos].Ports = nil
+				continue
+			}
+			if hasPorts && out[pos].Protocol != "" {
+				out[pos].Ports = append(out[pos].Ports, ports)
+			}
+			continue
+		}
+		direction := SecurityGroupDirectionIngress
+		ipNets := fwRule.SourceIPs
+		if fwRule.Direction == hcloud.FirewallRuleDirectionOut {
+			direction = SecurityGroupDirectionEgress
+			ipNets = fwRule.DestinationIPs
+		}
+		rule := SecurityGroupRule{
+			Direction: direction,
+			Protocol:  strings.ToLower(string(fwRule.Protocol)),
+		}
+		rule.CIDRs, rule.Version = securityGroupRuleSources(ipNets)
+		if hasPorts {
+			rule.Ports = []PortRange{ports}
+		}
+		if index >= 0 {
+			byIndex[index] = len(out)
+		}
+		out = append(out, rule)
+	}
+	return out
+}
+
+func parseFirewallPort(port *string) (PortRange, bool) {
+	if port == nil {
+		return PortRange{}, false
+	}
+	raw := strings.TrimSpace(*port)
+	if raw == "" || strings.EqualFold(raw, "any") {
+		return PortRange{}, false
+	}
+	fromRaw, toRaw, isRange := strings.Cut(raw, "-")
+	from, err := strconv.Atoi(fromRaw)
+	if err != nil {
+		return PortRange{}, false
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(toRaw); err != nil {
+			return PortRange{}, false
+		}
+	}
+	return PortRange{From: from, To: to}, true
+}
+
+// securityGroupRuleSources folds the "any address" defaults back into an
+// empty CIDR list plus version, mirroring firewallRuleCIDRs.
+func securityGroupRuleSources(ipNets []net.IPNet) ([]string, string) {
+	cidrs := make([]string, 0, len(ipNets))
+	for _, ipNet := range ipNets {
+		cidrs = append(cidrs, ipNet.String())
+	}
+	sorted := append([]string(nil), cidrs...)
+	sort.Strings(sorted)
+	switch strings.Join(sorted, ",") {
+	case "0.0.0.0/0":
+		return nil, "IPv4"
+	case "::/0":
+		return nil, "IPv6"
+	case "0.0.0.0/0,::/0":
+		return nil, ""
+	}
+	return cidrs, ""
+}
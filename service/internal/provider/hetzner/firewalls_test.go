@@ -0,0 +1,46 @@
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestSyncInstanceSecurityGroupsLeavesForeignFirewallsAlone(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appliedToVM := []any{map[string]any{"type": "server", "server": map[string]any{"id": 5}}}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			writeFakeJSON(w, map[string]any{"servers": []any{map[string]any{"id": 5, "name": "vm1"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/firewalls":
+			writeFakeJSON(w, map[string]any{"firewalls": []any{
+				map[string]any{"id": 1, "name": "web", "labels": map[string]string{"owner": "ws1"}, "applied_to": []any{}},
+				map[string]any{"id": 2, "name": "stale", "labels": map[string]string{"owner": "ws1"}, "applied_to": appliedToVM},
+				map[string]any{"id": 3, "name": "console", "labels": map[string]string{}, "applied_to": appliedToVM},
+			}})
+		case r.Method == http.MethodPost:
+			calls = append(calls, r.URL.Path)
+			writeFakeJSON(w, map[string]any{"actions": []any{map[string]any{"id": 9, "status": "success"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	owned := func(group SecurityGroup) bool { return group.Labels["owner"] == "ws1" }
+	if err := service.SyncInstanceSecurityGroups(context.Background(), "vm1", []string{"web"}, owned); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	want := []string{"/firewalls/1/actions/apply_to_resources", "/firewalls/2/actions/remove_from_resources"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("expected only the owned firewalls to change, got %v", calls)
+	}
+}
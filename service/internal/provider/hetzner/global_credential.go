@@ -0,0 +1,56 @@
+package hetzner
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// globalCredential is the global token set at runtime through the admin API.
+// While set, it takes precedence over SECA_HETZNER_TOKEN.
+type globalCredential struct {
+	mu     sync.RWMutex
+	client *hcloud.Client
+}
+
+// SetGlobalToken replaces the global Hetzner token used for requests without
+// a workspace credential. An empty token falls back to SECA_HETZNER_TOKEN.
+func (s *RegionService) SetGlobalToken(token string) {
+	token = strings.TrimSpace(token)
+	var client *hcloud.Client
+	if token != "" {
+		client = s.tokenClient(WorkspaceCredential{Token: token})
+	}
+	s.global.mu.Lock()
+	s.global.client = client
+	s.global.mu.Unlock()
+
+	// The cached health result and the shared catalog belong to the previous
+	// token.
+	s.health.mu.Lock()
+	s.health.checkedAt = time.Time{}
+	s.health.mu.Unlock()
+	if s.catalog != nil {
+		s.catalog.clearScope(catalogScope(context.Background()))
+	}
+}
+
+// ValidateToken checks that Hetzner accepts token with a cheap read.
+func (s *RegionService) ValidateToken(ctx context.Context, token string) error {
+	_, err := s.tokenClient(WorkspaceCredential{Token: token}).Location.All(ctx)
+	return err
+}
+
+// sharedClient returns the client for requests without a workspace
+// credential and whether it has a token at all.
+func (s *RegionService) sharedClient() (*hcloud.Client, bool) {
+	s.global.mu.RLock()
+	defer s.global.mu.RUnlock()
+	if s.global.client != nil {
+		return s.global.client, true
+	}
+	return s.client, s.globalToken != ""
+}
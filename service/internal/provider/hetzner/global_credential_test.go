@@ -0,0 +1,89 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestSetGlobalTokenReplacesAndRestoresTheEnvToken(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		mu.Unlock()
+		writeFakeJSON(w, map[string]any{"locations": []any{}})
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("env")), cloudAPIURL: srv.URL}
+
+	if !errors.Is(service.CheckHealth(context.Background()), ErrNotConfigured) {
+		t.Fatalf("expected no global token without SECA_HETZNER_TOKEN")
+	}
+	service.SetGlobalToken("stored")
+	if err := service.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("expected the stored token to be used, got %v", err)
+	}
+	if _, err := service.listLocations(context.Background()); err != nil {
+		t.Fatalf("list locations: %v", err)
+	}
+	service.globalToken = "env"
+	service.SetGlobalToken("")
+	if _, err := service.listLocations(context.Background()); err != nil {
+		t.Fatalf("list locations: %v", err)
+	}
+	want := []string{"Bearer stored", "Bearer stored", "Bearer env"}
+	if len(tokens) != len(want) {
+		t.Fatalf("expected tokens %v, got %v", want, tokens)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Fatalf("expected tokens %v, got %v", want, tokens)
+		}
+	}
+}
+
+func TestSetGlobalTokenDropsTheSharedCatalog(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		mu.Unlock()
+		writeFakeJSON(w, map[string]any{"locations": []any{}})
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{
+		client:          hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("env")),
+		globalToken:     "env",
+		cloudAPIURL:     srv.URL,
+		catalog:         newCatalogCache(),
+		catalogCacheTTL: time.Hour,
+	}
+
+	for range 2 {
+		if _, err := service.listLocations(context.Background()); err != nil {
+			t.Fatalf("list locations: %v", err)
+		}
+	}
+	service.SetGlobalToken("stored")
+	if _, err := service.listLocations(context.Background()); err != nil {
+		t.Fatalf("list locations: %v", err)
+	}
+	want := []string{"Bearer env", "Bearer stored"}
+	if strings.Join(tokens, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected tokens %v, got %v", want, tokens)
+	}
+}
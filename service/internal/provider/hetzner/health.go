@@ -0,0 +1,41 @@
+package hetzner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	healthCheckTTL     = 30 * time.Second
+	healthCheckTimeout = 3 * time.Second
+)
+
+// healthProbe remembers the last reachability check so readiness polling
+// costs at most one Hetzner request per healthCheckTTL.
+type healthProbe struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// CheckHealth reports whether the Hetzner API answers with the global token.
+// It returns ErrNotConfigured without calling Hetzner when no global token is
+// set, so a misconfiguration reads differently from an outage.
+func (s *RegionService) CheckHealth(ctx context.Context) error {
+	client, configured := s.sharedClient()
+	if !configured {
+		return ErrNotConfigured
+	}
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	if !s.health.checkedAt.IsZero() && time.Since(s.health.checkedAt) < healthCheckTTL {
+		return s.health.err
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	_, err := client.Location.All(probeCtx)
+	s.health.checkedAt = time.Now()
+	s.health.err = err
+	return err
+}
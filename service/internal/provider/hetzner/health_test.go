@@ -0,0 +1,41 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestCheckHealthCachesProbe(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeFakeJSON(w, map[string]any{"locations": []any{map[string]any{"id": 1, "name": "fsn1"}}})
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	for range 3 {
+		if err := service.CheckHealth(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one Hetzner call within the cache window, got %d", calls)
+	}
+}
+
+func TestCheckHealthWithoutGlobalToken(t *testing.T) {
+	t.Parallel()
+
+	service := &RegionService{}
+	if err := service.CheckHealth(context.Background()); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
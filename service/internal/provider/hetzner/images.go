@@ -0,0 +1,197 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ImageSnapshot is a Hetzner snapshot image taken from a server on behalf of
+// a tenant image.
+type ImageSnapshot struct {
+	ID           int64
+	Description  string
+	Status       string
+	Architecture string
+	Labels       map[string]string
+	CreatedAt    time.Time
+}
+
+// ImageSnapshotCreateRequest names the server to snapshot either directly or
+// through a block storage attached to it.
+type ImageSnapshotCreateRequest struct {
+	InstanceName     string
+	BlockStorageName string
+	Description      string
+	Labels           map[string]string
+}
+
+// CreateImageSnapshot snapshots the server's root disk. The snapshot stays in
+// status "creating" until the returned action completes.
+func (s *RegionService) CreateImageSnapshot(ctx context.Context, req ImageSnapshotCreateRequest) (*ImageSnapshot, string, error) {
+	server, err := s.imageSnapshotSource(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	description := strings.TrimSpace(req.Description)
+	result, _, err := s.clientFor(ctx).Server.CreateImage(ctx, server, &hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Description: &description,
+		Labels:      req.Labels,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if result.Image == nil {
+		return nil, "", fmt.Errorf("hetzner returned no image for server %q", server.Name)
+	}
+	actionID := ""
+	if result.Action != nil {
+		actionID = fmt.Sprintf("%d", result.Action.ID)
+	}
+	snapshot := imageSnapshotFromImage(result.Image)
+	if snapshot.Architecture == "" && server.ServerType != nil {
+		snapshot.Architecture = string(server.ServerType.Architecture)
+	}
+	return &snapshot, actionID, nil
+}
+
+func (s *RegionService) imageSnapshotSource(ctx context.Context, req ImageSnapshotCreateRequest) (*hcloud.Server, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	if name := strings.TrimSpace(req.BlockStorageName); name != "" {
+		volume, _, err := s.volumeByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if volume == nil {
+			return nil, notFoundError(fmt.Sprintf("block storage %q not found", name))
+		}
+		if volume.Server == nil {
+			return nil, conflictError(fmt.Sprintf("block storage %q is not attached to an instance", name))
+		}
+		server, _, err := s.clientFor(ctx).Server.GetByID(ctx, volume.Server.ID)
+		if err != nil {
+			return nil, err
+		}
+		if server == nil {
+			return nil, notFoundError(fmt.Sprintf("instance of block storage %q not found", name))
+		}
+		return server, nil
+	}
+	server, _, err := s.serverByName(ctx, strings.TrimSpace(req.InstanceName))
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, notFoundError(fmt.Sprintf("instance %q not found", req.InstanceName))
+	}
+	return server, nil
+}
+
+// FindImageSnapshot returns the snapshot carrying every given label, or nil
+// when there is none.
+func (s *RegionService) FindImageSnapshot(ctx context.Context, labels map[string]string) (*ImageSnapshot, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	images, err := s.clientFor(ctx).Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector(labels)},
+		Type:     []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 || images[0] == nil {
+		return nil, nil
+	}
+	snapshot := imageSnapshotFromImage(images[0])
+	return &snapshot, nil
+}
+
+// DeleteImageSnapshot deletes the snapshot and reports false when it was
+// already gone.
+func (s *RegionService) DeleteImageSnapshot(ctx context.Context, id int64) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	image, _, err := s.clientFor(ctx).Image.GetByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if image == nil {
+		return false, nil
+	}
+	if _, err := s.clientFor(ctx).Image.Delete(ctx, image); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolveRequestImage returns the snapshot pinned by req.ImageID, or resolves
+// req.ImageName against the image catalog when no snapshot is pinned.
+func (s *RegionService) resolveRequestImage(ctx context.Context, req InstanceCreateRequest, arch hcloud.Architecture) (*hcloud.Image, error) {
+	if req.ImageID <= 0 {
+		return s.resolveImageForArchitecture(ctx, req.ImageName, arch)
+	}
+	image, _, err := s.clientFor(ctx).Image.GetByID(ctx, req.ImageID)
+	if err != nil || image == nil {
+		return nil, err
+	}
+	if arch != "" && image.Architecture != "" && image.Architecture != arch {
+		return nil, invalidRequestError(fmt.Sprintf("image %q is built for %q, not %q", req.ImageName, image.Architecture, arch))
+	}
+	return image, nil
+}
+
+func imageSnapshotFromImage(image *hcloud.Image) ImageSnapshot {
+	return ImageSnapshot{
+		ID:           image.ID,
+		Description:  image.Description,
+		Status:       string(image.Status),
+		Architecture: string(image.Architecture),
+		Labels:       image.Labels,
+		CreatedAt:    image.Created,
+	}
+}
+
+// labelListOpts selects the resources carrying every given label; no labels
+// select everything.
+func labelListOpts(labels map[string]string) hcloud.ListOpts {
+	return hcloud.ListOpts{LabelSelector: labelSelector(labels)}
+}
+
+func labelSelector(labels map[string]string) string {
+	terms := make([]string, 0, len(labels))
+	for key, value := range labels {
+		terms = append(terms, key+"="+value)
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+// serverImageChanged reports whether the request asks for a different image
+// than the one the server was built from.
+func serverImageChanged(server *hcloud.Server, req InstanceCreateRequest) bool {
+	if server.Image == nil {
+		return false
+	}
+	if req.ImageID > 0 {
+		return server.Image.ID != req.ImageID
+	}
+	return req.ImageName != "" && !strings.EqualFold(serverImageName(server.Image), req.ImageName)
+}
+
+// serverImageName is the name an image is requested by. Snapshots have no
+// name; tenant image snapshots carry it as their description.
+func serverImageName(image *hcloud.Image) string {
+	if image.Name == "" && image.Type == hcloud.ImageTypeSnapshot {
+		return strings.ToLower(image.Description)
+	}
+	return strings.ToLower(image.Name)
+}
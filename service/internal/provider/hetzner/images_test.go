@@ -0,0 +1,77 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestCreateImageSnapshotFromAttachedBlockStorage(t *testing.T) {
+	t.Parallel()
+
+	var createBody map[string]any
+	var selector string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/volumes":
+			writeFakeJSON(w, map[string]any{"volumes": []any{map[string]any{"id": 3, "name": "data", "server": 5}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/5":
+			writeFakeJSON(w, map[string]any{"server": map[string]any{"id": 5, "name": "vm1", "server_type": map[string]any{"id": 1, "name": "cx22", "architecture": "x86"}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/servers/5/actions/create_image":
+			_ = json.NewDecoder(r.Body).Decode(&createBody)
+			writeFakeJSON(w, map[string]any{
+				"image":  map[string]any{"id": 42, "type": "snapshot", "status": "creating", "description": createBody["description"]},
+				"action": map[string]any{"id": 9, "status": "running"},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/images":
+			selector = r.URL.Query().Get("label_selector")
+			writeFakeJSON(w, map[string]any{"images": []any{map[string]any{"id": 42, "type": "snapshot", "status": "available", "description": "golden"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	snapshot, actionID, err := service.CreateImageSnapshot(context.Background(), ImageSnapshotCreateRequest{
+		BlockStorageName: "data",
+		Description:      "golden",
+		Labels:           map[string]string{"seca.kind": "image"},
+	})
+	if err != nil {
+		t.Fatalf("CreateImageSnapshot returned error: %v", err)
+	}
+	if snapshot.ID != 42 || snapshot.Status != "creating" || snapshot.Architecture != "x86" || actionID != "9" {
+		t.Fatalf("unexpected snapshot %+v, action %q", snapshot, actionID)
+	}
+	if createBody["type"] != "snapshot" || createBody["description"] != "golden" {
+		t.Fatalf("unexpected create_image body: %v", createBody)
+	}
+
+	found, err := service.FindImageSnapshot(context.Background(), map[string]string{"seca.tenant": "t1", "seca.kind": "image"})
+	if err != nil || found == nil || found.Status != "available" {
+		t.Fatalf("expected the snapshot to be found, got %+v, %v", found, err)
+	}
+	if selector != "seca.kind=image,seca.tenant=t1" {
+		t.Fatalf("unexpected label selector %q", selector)
+	}
+}
+
+func TestServerImageChangedComparesSnapshotByID(t *testing.T) {
+	t.Parallel()
+
+	server := &hcloud.Server{Image: &hcloud.Image{ID: 42, Type: hcloud.ImageTypeSnapshot, Description: "golden"}}
+	if serverImageChanged(server, InstanceCreateRequest{ImageName: "golden", ImageID: 42}) {
+		t.Fatal("expected the pinned snapshot to match")
+	}
+	if !serverImageChanged(server, InstanceCreateRequest{ImageName: "golden", ImageID: 43}) {
+		t.Fatal("expected a different snapshot to be a change")
+	}
+	if serverImageChanged(server, InstanceCreateRequest{ImageName: "golden"}) {
+		t.Fatal("expected the snapshot description to stand in for its name")
+	}
+}
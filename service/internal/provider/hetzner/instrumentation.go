@@ -0,0 +1,125 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// maxErrorBodyPeek bounds how much of an error response is read to find the
+// Hetzner error code.
+const maxErrorBodyPeek = 64 << 10
+
+// CallObserver records every HTTP call made to the Hetzner API. code is "ok"
+// for successful responses, the Hetzner error code (e.g. "rate_limit_exceeded")
+// for API errors and "network_error" when no response was received.
+type CallObserver interface {
+	ObserveHetznerCall(operation, code string, elapsed time.Duration)
+}
+
+// instrumentedTransport measures each request it sends. It sits below
+// readRetryTransport, so every retry attempt is recorded on its own.
+type instrumentedTransport struct {
+	base     http.RoundTripper
+	observer CallObserver
+	now      func() time.Time
+}
+
+func instrumentTransport(base http.RoundTripper, observer CallObserver) http.RoundTripper {
+	if observer == nil {
+		return base
+	}
+	return instrumentedTransport{base: base, observer: observer, now: time.Now}
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := t.now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := t.now().Sub(start)
+	operation := callOperation(req)
+	if err != nil {
+		t.observer.ObserveHetznerCall(operation, "network_error", elapsed)
+		return resp, err
+	}
+	t.observer.ObserveHetznerCall(operation, responseErrorCode(resp), elapsed)
+	return resp, nil
+}
+
+// traceTransport opens a client span per Hetzner request, named like the
+// call metrics. It sits below readRetryTransport, so every retry attempt is
+// a span of its own. Spans are only recorded once tracing is set up.
+func traceTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return "hetzner " + callOperation(req)
+	}))
+}
+
+// callOperation names a request by method and path template, e.g.
+// "POST /servers/{id}/actions/poweron", so IDs do not explode label
+// cardinality.
+func callOperation(req *http.Request) string {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		if i == 0 && isAPIVersion(segment) {
+			continue
+		}
+		if isNumeric(segment) {
+			segment = "{id}"
+		}
+		out = append(out, segment)
+	}
+	return req.Method + " /" + strings.Join(out, "/")
+}
+
+func isAPIVersion(segment string) bool {
+	return len(segment) > 1 && segment[0] == 'v' && isNumeric(segment[1:])
+}
+
+func isNumeric(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// responseErrorCode returns "ok" for 2xx/3xx responses and otherwise the
+// error.code field of the Hetzner error body, falling back to the status
+// code. The body is restored so hcloud can still decode it.
+func responseErrorCode(resp *http.Response) string {
+	if resp.StatusCode < http.StatusBadRequest {
+		return "ok"
+	}
+	fallback := "http_" + strconv.Itoa(resp.StatusCode)
+	if resp.Body == nil {
+		return fallback
+	}
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return fallback
+	}
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(peek, &body) != nil || body.Error.Code == "" {
+		return fallback
+	}
+	return body.Error.Code
+}
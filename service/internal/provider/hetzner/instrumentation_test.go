@@ -0,0 +1,79 @@
+package hetzner
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordedCall struct {
+	operation string
+	code      string
+}
+
+type callRecorder struct {
+	calls []recordedCall
+}
+
+func (r *callRecorder) ObserveHetznerCall(operation, code string, _ time.Duration) {
+	r.calls = append(r.calls, recordedCall{operation: operation, code: code})
+}
+
+func TestInstrumentedTransportLabelsCalls(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusLocked)
+			_, _ = io.WriteString(w, `{"error":{"code":"locked","message":"server is locked"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+
+	recorder := &callRecorder{}
+	client := &http.Client{Transport: instrumentTransport(http.DefaultTransport, recorder)}
+
+	resp, err := client.Get(server.URL + "/v1/servers/42")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.Post(server.URL+"/v1/servers/42/actions/poweron", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"locked"`) {
+		t.Fatalf("expected error body to stay readable after inspection, got %q", body)
+	}
+
+	want := []recordedCall{
+		{operation: "GET /servers/{id}", code: "ok"},
+		{operation: "POST /servers/{id}/actions/poweron", code: "locked"},
+	}
+	if len(recorder.calls) != len(want) {
+		t.Fatalf("expected %d calls, got %+v", len(want), recorder.calls)
+	}
+	for i := range want {
+		if recorder.calls[i] != want[i] {
+			t.Fatalf("call %d: expected %+v, got %+v", i, want[i], recorder.calls[i])
+		}
+	}
+}
+
+func TestResponseErrorCodeFallsBackToStatus(t *testing.T) {
+	t.Parallel()
+
+	resp := &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("<html>bad gateway</html>"))}
+	if code := responseErrorCode(resp); code != "http_502" {
+		t.Fatalf("expected http_502, got %q", code)
+	}
+}
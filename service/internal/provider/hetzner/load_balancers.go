@@ -0,0 +1,615 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// defaultLoadBalancerType is the smallest Hetzner load balancer type; SECA
+// load balancers do not pick a size.
+const defaultLoadBalancerType = "lb11"
+
+// LoadBalancer is a Hetzner load balancer with its services and server
+// targets. Network is the name of the private network it is attached to.
+type LoadBalancer struct {
+	ID         int64
+	Name       string
+	Region     string
+	Labels     map[string]string
+	PublicIPv4 string
+	PublicIPv6 string
+	Network    string
+	PrivateIP  string
+	Services   []LoadBalancerService
+	Targets    []LoadBalancerTarget
+	CreatedAt  time.Time
+}
+
+// LoadBalancerService forwards ListenPort on the load balancer to
+// DestinationPort on every target. Protocol is tcp or http.
+type LoadBalancerService struct {
+	Protocol        string
+	ListenPort      int
+	DestinationPort int
+	HealthCheck     LoadBalancerHealthCheck
+}
+
+// LoadBalancerHealthCheck probes a target; Path is only used by http checks.
+// A zero Port checks the service's destination port.
+type LoadBalancerHealthCheck struct {
+	Protocol string
+	Port     int
+	Interval time.Duration
+	Timeout  time.Duration
+	Retries  int
+	Path     string
+}
+
+// LoadBalancerTarget is a server behind the load balancer. Health is
+// healthy, unhealthy or unknown, summarised over all services.
+type LoadBalancerTarget struct {
+	ServerID   int64
+	ServerName string
+	Health     string
+}
+
+// LoadBalancerCreateRequest describes a load balancer. Region picks the
+// network zone it is created in. Targets names the servers to balance over;
+// servers that do not exist are left out instead of failing the request.
+type LoadBalancerCreateRequest struct {
+	Name     string
+	Region   string
+	Network  string
+	Labels   map[string]string
+	Services []LoadBalancerService
+	Targets  []string
+}
+
+func (s *RegionService) ListLoadBalancers(ctx context.Context) ([]LoadBalancer, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	items, err := s.clientFor(ctx).LoadBalancer.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serverNames, err := s.loadBalancerServerNames(ctx, items...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LoadBalancer, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		out = append(out, loadBalancerFromHetzner(item, serverNames))
+	}
+	return out, nil
+}
+
+func (s *RegionService) GetLoadBalancer(ctx context.Context, name string) (*LoadBalancer, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).LoadBalancer.GetByName(ctx, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, nil
+	}
+	return s.describeLoadBalancer(ctx, item)
+}
+
+// CreateOrUpdateLoadBalancer creates a load balancer or converges an
+// existing one on req: labels, the attached network, the services and the
+// server targets. A new load balancer is placed in the network zone of
+// req.Region rather than in a fixed location.
+func (s *RegionService) CreateOrUpdateLoadBalancer(ctx context.Context, req LoadBalancerCreateRequest) (*LoadBalancer, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, false, invalidRequestError("load balancer name is required")
+	}
+	client := s.clientFor(ctx)
+	var network *hcloud.Network
+	if req.Network != "" {
+		var err error
+		network, _, err = s.networkByName(ctx, strings.TrimSpace(req.Network))
+		if err != nil {
+			return nil, false, err
+		}
+		if network == nil {
+			return nil, false, notFoundError(fmt.Sprintf("network %q not found", req.Network))
+		}
+	}
+	current, _, err := client.LoadBalancer.GetByName(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	created := false
+	if current == nil {
+		if strings.TrimSpace(req.Region) == "" {
+			return nil, false, invalidRequestError("load balancer region is required")
+		}
+		zone, err := s.networkZoneFor(ctx, req.Region)
+		if err != nil {
+			return nil, false, err
+		}
+		opts := hcloud.LoadBalancerCreateOpts{
+			Name:             name,
+			LoadBalancerType: &hcloud.LoadBalancerType{Name: defaultLoadBalancerType},
+			NetworkZone:      zone,
+			Labels:           req.Labels,
+		}
+		for _, service := range req.Services {
+			opts.Services = append(opts.Services, loadBalancerCreateService(service))
+		}
+		if network != nil {
+			if err := s.ensureNetworkHasCloudSubnetInZone(ctx, network, zone); err != nil {
+				return nil, false, err
+			}
+			opts.Network = network
+		}
+		result, _, err := client.LoadBalancer.Create(ctx, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		if result.LoadBalancer == nil {
+			return nil, false, fmt.Errorf("hetzner returned empty load balancer")
+		}
+		if result.Action != nil {
+			if err := s.waitForActions(ctx, result.Action); err != nil {
+				return nil, false, err
+			}
+		}
+		current, created = result.LoadBalancer, true
+	} else {
+		if req.Labels != nil && !maps.Equal(current.Labels, req.Labels) {
+			updated, _, err := client.LoadBalancer.Update(ctx, current, hcloud.LoadBalancerUpdateOpts{Labels: req.Labels})
+			if err != nil {
+				return nil, false, err
+			}
+			if updated != nil {
+				current = updated
+			}
+		}
+		if err := s.convergeLoadBalancerNetwork(ctx, current, network); err != nil {
+			return nil, false, err
+		}
+		if err := s.convergeLoadBalancerServices(ctx, current, req.Services); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := s.convergeLoadBalancerTargets(ctx, current, network, req.Targets); err != nil {
+		return nil, false, err
+	}
+	refreshed, _, err := client.LoadBalancer.GetByID(ctx, current.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if refreshed == nil {
+		return nil, false, notFoundError(fmt.Sprintf("load balancer %q not found", name))
+	}
+	lb, err := s.describeLoadBalancer(ctx, refreshed)
+	if err != nil {
+		return nil, false, err
+	}
+	return lb, created, nil
+}
+
+func (s *RegionService) DeleteLoadBalancer(ctx context.Context, name string) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).LoadBalancer.GetByName(ctx, strings.TrimSpace(name))
+	if err != nil {
+		return false, err
+	}
+	if item == nil {
+		return false, nil
+	}
+	if _, err := s.clientFor(ctx).LoadBalancer.Delete(ctx, item); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// convergeLoadBalancerNetwork attaches lb to network and detaches it from
+// any other network. Server targets reached over a network that goes away
+// are removed first, because Hetzner refuses to detach while they use it.
+func (s *RegionService) convergeLoadBalancerNetwork(ctx context.Context, lb *hcloud.LoadBalancer, network *hcloud.Network) error {
+	client := s.clientFor(ctx)
+	attached, changed := false, false
+	for _, privateNet := range lb.PrivateNet {
+		if privateNet.Network == nil {
+			continue
+		}
+		if network != nil && privateNet.Network.ID == network.ID {
+			attached = true
+			continue
+		}
+		for _, target := range lb.Targets {
+			if target.Type != hcloud.LoadBalancerTargetTypeServer || target.Server == nil || !target.UsePrivateIP {
+				continue
+			}
+			action, _, err := client.LoadBalancer.RemoveServerTarget(ctx, lb, target.Server.Server)
+			if err != nil {
+				return err
+			}
+			if err := s.waitForActions(ctx, action); err != nil {
+				return err
+			}
+		}
+		action, _, err := client.LoadBalancer.DetachFromNetwork(ctx, lb, hcloud.LoadBalancerDetachFromNetworkOpts{Network: privateNet.Network})
+		if err != nil {
+			return err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if network != nil && !attached {
+		if lb.Location != nil && lb.Location.NetworkZone != "" {
+			if err := s.ensureNetworkHasCloudSubnetInZone(ctx, network, lb.Location.NetworkZone); err != nil {
+				return err
+			}
+		}
+		action, _, err := client.LoadBalancer.AttachToNetwork(ctx, lb, hcloud.LoadBalancerAttachToNetworkOpts{Network: network})
+		if err != nil {
+			return err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	refreshed, _, err := client.LoadBalancer.GetByID(ctx, lb.ID)
+	if err != nil {
+		return err
+	}
+	if refreshed != nil {
+		*lb = *refreshed
+	}
+	return nil
+}
+
+// convergeLoadBalancerServices adds, updates and deletes services so lb
+// serves exactly the listen ports of services.
+func (s *RegionService) convergeLoadBalancerServices(ctx context.Context, lb *hcloud.LoadBalancer, services []LoadBalancerService) error {
+	client := s.clientFor(ctx)
+	wanted := make(map[int]LoadBalancerService, len(services))
+	for _, service := range services {
+		wanted[service.ListenPort] = service
+	}
+	var actions []*hcloud.Action
+	existing := map[int]bool{}
+	for _, current := range lb.Services {
+		existing[current.ListenPort] = true
+		service, ok := wanted[current.ListenPort]
+		if !ok {
+			action, _, err := client.LoadBalancer.DeleteService(ctx, lb, current.ListenPort)
+			if err != nil {
+				return err
+			}
+			actions = append(actions, action)
+			continue
+		}
+		if loadBalancerServiceFromHetzner(current) == withHealthCheckDefaults(service) {
+			continue
+		}
+		check := loadBalancerCreateService(service).HealthCheck
+		opts := hcloud.LoadBalancerUpdateServiceOpts{
+			Protocol:        hcloud.LoadBalancerServiceProtocol(service.Protocol),
+			DestinationPort: hcloud.Ptr(service.DestinationPort),
+			HealthCheck: &hcloud.LoadBalancerUpdateServiceOptsHealthCheck{
+				Protocol: check.Protocol,
+				Port:     check.Port,
+				Interval: check.Interval,
+				Timeout:  check.Timeout,
+				Retries:  check.Retries,
+			},
+		}
+		if check.HTTP != nil {
+			opts.HealthCheck.HTTP = &hcloud.LoadBalancerUpdateServiceOptsHealthCheckHTTP{Path: check.HTTP.Path}
+		}
+		action, _, err := client.LoadBalancer.UpdateService(ctx, lb, current.ListenPort, opts)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	for _, service := range services {
+		if existing[service.ListenPort] {
+			continue
+		}
+		check := loadBalancerCreateService(service).HealthCheck
+		opts := hcloud.LoadBalancerAddServiceOpts{
+			Protocol:        hcloud.LoadBalancerServiceProtocol(service.Protocol),
+			ListenPort:      hcloud.Ptr(service.ListenPort),
+			DestinationPort: hcloud.Ptr(service.DestinationPort),
+			HealthCheck: &hcloud.LoadBalancerAddServiceOptsHealthCheck{
+				Protocol: check.Protocol,
+				Port:     check.Port,
+				Interval: check.Interval,
+				Timeout:  check.Timeout,
+				Retries:  check.Retries,
+			},
+		}
+		if check.HTTP != nil {
+			opts.HealthCheck.HTTP = &hcloud.LoadBalancerAddServiceOptsHealthCheckHTTP{Path: check.HTTP.Path}
+		}
+		action, _, err := client.LoadBalancer.AddService(ctx, lb, opts)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	return s.waitForActions(ctx, actions...)
+}
+
+// convergeLoadBalancerTargets adds the named servers as targets and removes
+// every other server target. Names without a server are skipped, so a
+// deleted instance shows up as a missing target instead of an error. Servers
+// attached to network are reached over their private address.
+func (s *RegionService) convergeLoadBalancerTargets(ctx context.Context, lb *hcloud.LoadBalancer, network *hcloud.Network, names []string) error {
+	client := s.clientFor(ctx)
+	wanted := map[int64]*hcloud.Server{}
+	for _, name := range names {
+		server, _, err := s.serverByName(ctx, strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		if server != nil {
+			wanted[server.ID] = server
+		}
+	}
+	var actions []*hcloud.Action
+	present := map[int64]bool{}
+	for _, target := range lb.Targets {
+		if target.Type != hcloud.LoadBalancerTargetTypeServer || target.Server == nil || target.Server.Server == nil {
+			continue
+		}
+		id := target.Server.Server.ID
+		if _, ok := wanted[id]; ok {
+			present[id] = true
+			continue
+		}
+		action, _, err := client.LoadBalancer.RemoveServerTarget(ctx, lb, target.Server.Server)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	for id, server := range wanted {
+		if present[id] {
+			continue
+		}
+		action, _, err := client.LoadBalancer.AddServerTarget(ctx, lb, hcloud.LoadBalancerAddServerTargetOpts{
+			Server:       server,
+			UsePrivateIP: hcloud.Ptr(serverAttachedTo(server, network)),
+		})
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	return s.waitForActions(ctx, actions...)
+}
+
+func (s *RegionService) describeLoadBalancer(ctx context.Context, item *hcloud.LoadBalancer) (*LoadBalancer, error) {
+	serverNames, err := s.loadBalancerServerNames(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	lb := loadBalancerFromHetzner(item, serverNames)
+	return &lb, nil
+}
+
+// loadBalancerServerNames resolves the server targets of items to names;
+// Hetzner only reports their IDs.
+func (s *RegionService) loadBalancerServerNames(ctx context.Context, items ...*hcloud.LoadBalancer) (map[int64]string, error) {
+	names := map[int64]string{}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		for _, target := range item.Targets {
+			if target.Server == nil || target.Server.Server == nil {
+				continue
+			}
+			id := target.Server.Server.ID
+			if _, ok := names[id]; ok {
+				continue
+			}
+			server, _, err := s.clientFor(ctx).Server.GetByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if server != nil {
+				names[id] = server.Name
+			}
+		}
+	}
+	return names, nil
+}
+
+func serverAttachedTo(server *hcloud.Server, network *hcloud.Network) bool {
+	if network == nil {
+		return false
+	}
+	for _, privateNet := range server.PrivateNet {
+		if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func loadBalancerCreateService(service LoadBalancerService) hcloud.LoadBalancerCreateOptsService {
+	check := service.HealthCheck
+	protocol := check.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	port := check.Port
+	if port == 0 {
+		port = service.DestinationPort
+	}
+	interval, timeout, retries := check.Interval, check.Timeout, check.Retries
+	if interval == 0 {
+		interval = 15 * time.Second
+	}
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	if retries == 0 {
+		retries = 3
+	}
+	out := hcloud.LoadBalancerCreateOptsService{
+		Protocol:        hcloud.LoadBalancerServiceProtocol(service.Protocol),
+		ListenPort:      hcloud.Ptr(service.ListenPort),
+		DestinationPort: hcloud.Ptr(service.DestinationPort),
+		HealthCheck: &hcloud.LoadBalancerCreateOptsServiceHealthCheck{
+			Protocol: hcloud.LoadBalancerServiceProtocol(protocol),
+			Port:     hcloud.Ptr(port),
+			Interval: hcloud.Ptr(interval),
+			Timeout:  hcloud.Ptr(timeout),
+			Retries:  hcloud.Ptr(retries),
+		},
+	}
+	if protocol == "http" {
+		path := check.Path
+		if path == "" {
+			path = "/"
+		}
+		out.HealthCheck.HTTP = &hcloud.LoadBalancerCreateOptsServiceHealthCheckHTTP{Path: hcloud.Ptr(path)}
+	}
+	return out
+}
+
+// loadBalancerServiceFromHetzner mirrors loadBalancerCreateService, so a
+// service read back compares equal to the request that produced it once the
+// request's defaults are filled in.
+func loadBalancerServiceFromHetzner(service hcloud.LoadBalancerService) LoadBalancerService {
+	out := LoadBalancerService{
+		Protocol:        string(service.Protocol),
+		ListenPort:      service.ListenPort,
+		DestinationPort: service.DestinationPort,
+		HealthCheck: LoadBalancerHealthCheck{
+			Protocol: string(service.HealthCheck.Protocol),
+			Port:     service.HealthCheck.Port,
+			Interval: service.HealthCheck.Interval,
+			Timeout:  service.HealthCheck.Timeout,
+			Retries:  service.HealthCheck.Retries,
+		},
+	}
+	if service.HealthCheck.HTTP != nil {
+		out.HealthCheck.Path = service.HealthCheck.HTTP.Path
+	}
+	return out
+}
+
+// withHealthCheckDefaults fills in the health check settings
+// loadBalancerCreateService sends when a service leaves them out.
+func withHealthCheckDefaults(service LoadBalancerService) LoadBalancerService {
+	check := loadBalancerCreateService(service).HealthCheck
+	service.HealthCheck = LoadBalancerHealthCheck{
+		Protocol: string(check.Protocol),
+		Port:     *check.Port,
+		Interval: *check.Interval,
+		Timeout:  *check.Timeout,
+		Retries:  *check.Retries,
+	}
+	if check.HTTP != nil {
+		service.HealthCheck.Path = *check.HTTP.Path
+	}
+	return service
+}
+
+func loadBalancerFromHetzner(item *hcloud.LoadBalancer, serverNames map[int64]string) LoadBalancer {
+	lb := LoadBalancer{
+		ID:        item.ID,
+		Name:      strings.ToLower(item.Name),
+		Labels:    item.Labels,
+		CreatedAt: item.Created,
+	}
+	if item.Location != nil {
+		lb.Region = item.Location.Name
+	}
+	if item.PublicNet.Enabled {
+		if item.PublicNet.IPv4.IP != nil && !item.PublicNet.IPv4.IP.IsUnspecified() {
+			lb.PublicIPv4 = item.PublicNet.IPv4.IP.String()
+		}
+		if item.PublicNet.IPv6.IP != nil && !item.PublicNet.IPv6.IP.IsUnspecified() {
+			lb.PublicIPv6 = item.PublicNet.IPv6.IP.String()
+		}
+	}
+	for _, privateNet := range item.PrivateNet {
+		if privateNet.Network == nil {
+			continue
+		}
+		lb.Network = privateNet.Network.Name
+		if privateNet.IP != nil {
+			lb.PrivateIP = privateNet.IP.String()
+		}
+		break
+	}
+	for _, service := range item.Services {
+		lb.Services = append(lb.Services, loadBalancerServiceFromHetzner(service))
+	}
+	slices.SortFunc(lb.Services, func(a, b LoadBalancerService) int { return a.ListenPort - b.ListenPort })
+	for _, target := range item.Targets {
+		if target.Type != hcloud.LoadBalancerTargetTypeServer || target.Server == nil || target.Server.Server == nil {
+			continue
+		}
+		id := target.Server.Server.ID
+		lb.Targets = append(lb.Targets, LoadBalancerTarget{
+			ServerID:   id,
+			ServerName: serverNames[id],
+			Health:     loadBalancerTargetHealth(target.HealthStatus),
+		})
+	}
+	slices.SortFunc(lb.Targets, func(a, b LoadBalancerTarget) int { return strings.Compare(a.ServerName, b.ServerName) })
+	return lb
+}
+
+// loadBalancerTargetHealth summarises the per-service health of a target:
+// unhealthy when any service says so, healthy when every service does.
+func loadBalancerTargetHealth(statuses []hcloud.LoadBalancerTargetHealthStatus) string {
+	if len(statuses) == 0 {
+		return string(hcloud.LoadBalancerTargetHealthStatusStatusUnknown)
+	}
+	healthy := true
+	for _, status := range statuses {
+		switch status.Status {
+		case hcloud.LoadBalancerTargetHealthStatusStatusUnhealthy:
+			return string(hcloud.LoadBalancerTargetHealthStatusStatusUnhealthy)
+		case hcloud.LoadBalancerTargetHealthStatusStatusHealthy:
+		default:
+			healthy = false
+		}
+	}
+	if !healthy {
+		return string(hcloud.LoadBalancerTargetHealthStatusStatusUnknown)
+	}
+	return string(hcloud.LoadBalancerTargetHealthStatusStatusHealthy)
+}
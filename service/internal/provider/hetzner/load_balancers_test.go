@@ -0,0 +1,62 @@
+package hetzner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestLoadBalancerTargetHealthSummarisesServices(t *testing.T) {
+	t.Parallel()
+
+	healthy := hcloud.LoadBalancerTargetHealthStatus{ListenPort: 80, Status: hcloud.LoadBalancerTargetHealthStatusStatusHealthy}
+	unhealthy := hcloud.LoadBalancerTargetHealthStatus{ListenPort: 443, Status: hcloud.LoadBalancerTargetHealthStatusStatusUnhealthy}
+	unknown := hcloud.LoadBalancerTargetHealthStatus{ListenPort: 443, Status: hcloud.LoadBalancerTargetHealthStatusStatusUnknown}
+	cases := []struct {
+		statuses []hcloud.LoadBalancerTargetHealthStatus
+		want     string
+	}{
+		{nil, "unknown"},
+		{[]hcloud.LoadBalancerTargetHealthStatus{healthy}, "healthy"},
+		{[]hcloud.LoadBalancerTargetHealthStatus{healthy, unknown}, "unknown"},
+		{[]hcloud.LoadBalancerTargetHealthStatus{unknown, unhealthy, healthy}, "unhealthy"},
+	}
+	for _, tc := range cases {
+		if got := loadBalancerTargetHealth(tc.statuses); got != tc.want {
+			t.Fatalf("%+v: expected %s, got %s", tc.statuses, tc.want, got)
+		}
+	}
+}
+
+// TestLoadBalancerServiceRoundTrip keeps update detection honest: a service
+// Hetzner created from a request must compare equal to that request, or every
+// PUT would rewrite every service.
+func TestLoadBalancerServiceRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, service := range []LoadBalancerService{
+		{Protocol: "tcp", ListenPort: 443, DestinationPort: 8443},
+		{Protocol: "http", ListenPort: 80, DestinationPort: 8080, HealthCheck: LoadBalancerHealthCheck{Protocol: "http", Interval: 5 * time.Second}},
+	} {
+		opts := loadBalancerCreateService(service)
+		created := hcloud.LoadBalancerService{
+			Protocol:        opts.Protocol,
+			ListenPort:      *opts.ListenPort,
+			DestinationPort: *opts.DestinationPort,
+			HealthCheck: hcloud.LoadBalancerServiceHealthCheck{
+				Protocol: opts.HealthCheck.Protocol,
+				Port:     *opts.HealthCheck.Port,
+				Interval: *opts.HealthCheck.Interval,
+				Timeout:  *opts.HealthCheck.Timeout,
+				Retries:  *opts.HealthCheck.Retries,
+			},
+		}
+		if opts.HealthCheck.HTTP != nil {
+			created.HealthCheck.HTTP = &hcloud.LoadBalancerServiceHealthCheckHTTP{Path: *opts.HealthCheck.HTTP.Path}
+		}
+		if got, want := loadBalancerServiceFromHetzner(created), withHealthCheckDefaults(service); got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+	}
+}
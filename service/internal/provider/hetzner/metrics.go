@@ -0,0 +1,92 @@
+package hetzner
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// MetricSample is one point of a metric time series.
+type MetricSample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// InstanceMetricsRequest selects one metric type over a time range. A zero
+// StepSeconds lets Hetzner pick the resolution.
+type InstanceMetricsRequest struct {
+	Name        string
+	Type        string
+	Start       time.Time
+	End         time.Time
+	StepSeconds int
+}
+
+// InstanceMetrics holds the series Hetzner returns for a metric type, keyed
+// by series name such as cpu or network.0.bandwidth.in.
+type InstanceMetrics struct {
+	Start       time.Time
+	End         time.Time
+	StepSeconds int
+	Series      map[string][]MetricSample
+}
+
+// GetInstanceMetrics reads metrics of the named server. It returns nil when
+// the server does not exist and empty series while it is off.
+func (s *RegionService) GetInstanceMetrics(ctx context.Context, req InstanceMetricsRequest) (*InstanceMetrics, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	server, _, err := s.serverByName(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, nil
+	}
+	out := &InstanceMetrics{
+		Start:       req.Start,
+		End:         req.End,
+		StepSeconds: req.StepSeconds,
+		Series:      map[string][]MetricSample{},
+	}
+	if server.Status == hcloud.ServerStatusOff {
+		return out, nil
+	}
+	metrics, _, err := s.clientFor(ctx).Server.GetMetrics(ctx, server, hcloud.ServerGetMetricsOpts{
+		Types: []hcloud.ServerMetricType{hcloud.ServerMetricType(req.Type)},
+		Start: req.Start,
+		End:   req.End,
+		Step:  req.StepSeconds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if metrics == nil {
+		return out, nil
+	}
+	out.Start = metrics.Start
+	out.End = metrics.End
+	out.StepSeconds = int(metrics.Step)
+	for name, values := range metrics.TimeSeries {
+		samples := make([]MetricSample, 0, len(values))
+		for _, value := range values {
+			parsed, err := strconv.ParseFloat(value.Value, 64)
+			if err != nil || math.IsNaN(parsed) {
+				continue
+			}
+			seconds, fraction := math.Modf(value.Timestamp)
+			samples = append(samples, MetricSample{
+				Timestamp: time.Unix(int64(seconds), int64(fraction*1e9)).UTC(),
+				Value:     parsed,
+			})
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+		out.Series[name] = samples
+	}
+	return out, nil
+}
@@ -10,24 +10,45 @@ import (
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+// Network is a Hetzner network. Zone is the network zone of its cloud
+// subnets, empty while it has none.
 type Network struct {
+	ID        int64
 	Name      string
 	CIDR      string
+	Zone      string
 	Labels    map[string]string
 	CreatedAt time.Time
 }
 
+// NetworkCreateRequest describes a network. Region is the workspace region;
+// a new network gets a cloud subnet in that region's network zone so servers
+// there can attach right away.
 type NetworkCreateRequest struct {
 	Name   string
 	CIDR   string
+	Region string
 	Labels map[string]string
 }
 
+// NetworkSubnetRequest describes a cloud subnet of a network. Zone is a
+// datacenter or location name and selects the subnet's network zone.
+type NetworkSubnetRequest struct {
+	NetworkName string
+	CIDR        string
+	Zone        string
+}
+
 func (s *RegionService) ListNetworks(ctx context.Context) ([]Network, error) {
-	if !s.configured {
+	return s.ListNetworksByLabels(ctx, nil)
+}
+
+// ListNetworksByLabels lists the networks carrying every given label.
+func (s *RegionService) ListNetworksByLabels(ctx context.Context, labels map[string]string) ([]Network, error) {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	items, err := s.clientFor(ctx).Network.All(ctx)
+	items, err := s.clientFor(ctx).Network.AllWithOpts(ctx, hcloud.NetworkListOpts{ListOpts: labelListOpts(labels)})
 	if err != nil {
 		return nil, err
 	}
@@ -42,10 +63,10 @@ func (s *RegionService) ListNetworks(ctx context.Context) ([]Network, error) {
 }
 
 func (s *RegionService) GetNetwork(ctx context.Context, name string) (*Network, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, ErrNotConfigured
 	}
-	item, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(name))
+	item, _, err := s.networkByName(ctx, strings.TrimSpace(name))
 	if err != nil {
 		return nil, err
 	}
@@ -57,23 +78,15 @@ func (s *RegionService) GetNetwork(ctx context.Context, name string) (*Network,
 }
 
 func (s *RegionService) CreateOrUpdateNetwork(ctx context.Context, req NetworkCreateRequest) (*Network, bool, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return nil, false, ErrNotConfigured
 	}
-	name := strings.TrimSpace(req.Name)
-	if name == "" {
-		return nil, false, invalidRequestError("network name is required")
-	}
-	cidr := strings.TrimSpace(req.CIDR)
-	if cidr == "" {
-		return nil, false, invalidRequestError("network cidr is required")
-	}
-	_, ipRange, err := net.ParseCIDR(cidr)
-	if err != nil || ipRange == nil {
-		return nil, false, invalidRequestError("invalid network cidr")
+	name, ipRange, err := networkCreateRange(req)
+	if err != nil {
+		return nil, false, err
 	}
 
-	existing, _, err := s.clientFor(ctx).Network.GetByName(ctx, name)
+	existing, _, err := s.networkByName(ctx, name)
 	if err != nil {
 		return nil, false, err
 	}
@@ -87,10 +100,18 @@ func (s *RegionService) CreateOrUpdateNetwork(ctx context.Context, req NetworkCr
 		network := networkFromHCloud(updated)
 		return &network, false, nil
 	}
+	if problem := NetworkRangeProblem(ipRange); problem != "" {
+		return nil, false, invalidRequestError(problem)
+	}
 
+	subnets, err := s.initialNetworkSubnets(ctx, req.Region, ipRange)
+	if err != nil {
+		return nil, false, err
+	}
 	created, _, err := s.clientFor(ctx).Network.Create(ctx, hcloud.NetworkCreateOpts{
 		Name:    name,
 		IPRange: ipRange,
+		Subnets: subnets,
 		Labels:  req.Labels,
 	})
 	if err != nil {
@@ -103,11 +124,116 @@ func (s *RegionService) CreateOrUpdateNetwork(ctx context.Context, req NetworkCr
 	return &network, true, nil
 }
 
+// ValidateNetworkCreate makes the checks of CreateOrUpdateNetwork with
+// read-only calls. It returns the network the request would leave behind and
+// whether it would be created.
+func (s *RegionService) ValidateNetworkCreate(ctx context.Context, req NetworkCreateRequest) (*Network, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	name, ipRange, err := networkCreateRange(req)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, _, err := s.networkByName(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		network := networkFromHCloud(existing)
+		network.Labels = req.Labels
+		return &network, false, nil
+	}
+	if problem := NetworkRangeProblem(ipRange); problem != "" {
+		return nil, false, invalidRequestError(problem)
+	}
+	subnets, err := s.initialNetworkSubnets(ctx, req.Region, ipRange)
+	if err != nil {
+		return nil, false, err
+	}
+	network := networkFromHCloud(&hcloud.Network{Name: name, IPRange: ipRange, Subnets: subnets, Labels: req.Labels})
+	return &network, true, nil
+}
+
+// initialNetworkSubnets is the cloud subnet a new network starts with: the
+// first /24 of its range in the network zone of region. Regions that are not
+// Hetzner locations, such as "global", start without one.
+func (s *RegionService) initialNetworkSubnets(ctx context.Context, region string, ipRange *net.IPNet) ([]hcloud.NetworkSubnet, error) {
+	region = strings.TrimSpace(region)
+	if region == "" {
+		return nil, nil
+	}
+	location, err := s.locationByName(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil || location.NetworkZone == "" {
+		return nil, nil
+	}
+	subnetRange, err := deriveAvailableCloudSubnet(&hcloud.Network{IPRange: ipRange}, 24)
+	if err != nil {
+		return nil, err
+	}
+	return []hcloud.NetworkSubnet{{
+		Type:        hcloud.NetworkSubnetTypeCloud,
+		NetworkZone: location.NetworkZone,
+		IPRange:     subnetRange,
+	}}, nil
+}
+
+// Hetzner takes network ranges from the private address space of RFC 1918,
+// between MinNetworkPrefix and MaxNetworkPrefix bits long.
+const (
+	MinNetworkPrefix = 16
+	MaxNetworkPrefix = 24
+)
+
+var privateNetworkRanges = []*net.IPNet{
+	{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(172, 16, 0, 0).To4(), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+}
+
+// NetworkRangeProblem describes why Hetzner would reject ipRange as the range
+// of a new network, or returns "" when it is acceptable.
+func NetworkRangeProblem(ipRange *net.IPNet) string {
+	ones, bits := ipRange.Mask.Size()
+	if bits != 32 {
+		return "network cidr must be an IPv4 range"
+	}
+	if ones < MinNetworkPrefix || ones > MaxNetworkPrefix {
+		return fmt.Sprintf("network cidr must have a prefix length between /%d and /%d", MinNetworkPrefix, MaxNetworkPrefix)
+	}
+	for _, private := range privateNetworkRanges {
+		privateOnes, _ := private.Mask.Size()
+		if private.Contains(ipRange.IP) && ones >= privateOnes {
+			return ""
+		}
+	}
+	return "network cidr must be inside 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16"
+}
+
+func networkCreateRange(req NetworkCreateRequest) (string, *net.IPNet, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", nil, invalidRequestError("network name is required")
+	}
+	cidr := strings.TrimSpace(req.CIDR)
+	if cidr == "" {
+		return "", nil, invalidRequestError("network cidr is required")
+	}
+	_, ipRange, err := net.ParseCIDR(cidr)
+	if err != nil || ipRange == nil {
+		return "", nil, invalidRequestError("invalid network cidr")
+	}
+	return name, ipRange, nil
+}
+
 func (s *RegionService) DeleteNetwork(ctx context.Context, name string) (bool, error) {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return false, ErrNotConfigured
 	}
-	item, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(name))
+	item, _, err := s.networkByName(ctx, strings.TrimSpace(name))
 	if err != nil {
 		return false, err
 	}
@@ -122,10 +248,10 @@ func (s *RegionService) DeleteNetwork(ctx context.Context, name string) (bool, e
 }
 
 func (s *RegionService) UpsertNetworkRoute(ctx context.Context, networkName, destinationCIDR, gatewayIP string) error {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return ErrNotConfigured
 	}
-	network, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(networkName))
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
 	if err != nil {
 		return err
 	}
@@ -158,7 +284,7 @@ func (s *RegionService) UpsertNetworkRoute(ctx context.Context, networkName, des
 			return deleteErr
 		}
 		if action != nil {
-			if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+			if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 				return waitErr
 			}
 		}
@@ -175,7 +301,7 @@ func (s *RegionService) UpsertNetworkRoute(ctx context.Context, networkName, des
 		return err
 	}
 	if action != nil {
-		if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 			return waitErr
 		}
 	}
@@ -183,10 +309,10 @@ func (s *RegionService) UpsertNetworkRoute(ctx context.Context, networkName, des
 }
 
 func (s *RegionService) DeleteNetworkRoute(ctx context.Context, networkName, destinationCIDR string) error {
-	if !s.configured {
+	if !s.configuredFor(ctx) {
 		return ErrNotConfigured
 	}
-	network, _, err := s.clientFor(ctx).Network.GetByName(ctx, strings.TrimSpace(networkName))
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
 	if err != nil {
 		return err
 	}
@@ -211,7 +337,7 @@ func (s *RegionService) DeleteNetworkRoute(ctx context.Context, networkName, des
 			return deleteErr
 		}
 		if action != nil {
-			if waitErr := s.clientFor(ctx).Action.WaitFor(ctx, action); waitErr != nil {
+			if waitErr := s.waitForActions(ctx, action); waitErr != nil {
 				return waitErr
 			}
 		}
@@ -220,14 +346,182 @@ func (s *RegionService) DeleteNetworkRoute(ctx context.Context, networkName, des
 	return nil
 }
 
+// AddSubnet creates a cloud subnet with the requested range and reports
+// whether it did. The range must lie inside the network range and must not
+// overlap another subnet; adding a subnet that already exists with the same
+// range and zone is a no-op.
+func (s *RegionService) AddSubnet(ctx context.Context, req NetworkSubnetRequest) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	network, subnet, err := s.subnetToAdd(ctx, req)
+	if err != nil || network == nil {
+		return false, err
+	}
+	action, _, err := s.clientFor(ctx).Network.AddSubnet(ctx, network, hcloud.NetworkAddSubnetOpts{
+		Subnet: subnet,
+	})
+	if err != nil {
+		return false, err
+	}
+	if action != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
+			return true, waitErr
+		}
+	}
+	return true, nil
+}
+
+// ValidateSubnetCreate makes the checks of AddSubnet with read-only calls.
+func (s *RegionService) ValidateSubnetCreate(ctx context.Context, req NetworkSubnetRequest) error {
+	if !s.configuredFor(ctx) {
+		return ErrNotConfigured
+	}
+	_, _, err := s.subnetToAdd(ctx, req)
+	return err
+}
+
+// subnetToAdd checks req against its network and returns the network and the
+// subnet to add to it. The network is nil when the subnet already exists.
+func (s *RegionService) subnetToAdd(ctx context.Context, req NetworkSubnetRequest) (*hcloud.Network, hcloud.NetworkSubnet, error) {
+	_, subnetRange, err := net.ParseCIDR(strings.TrimSpace(req.CIDR))
+	if err != nil || subnetRange == nil {
+		return nil, hcloud.NetworkSubnet{}, invalidRequestError("invalid subnet cidr")
+	}
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(req.NetworkName))
+	if err != nil {
+		return nil, hcloud.NetworkSubnet{}, err
+	}
+	if network == nil {
+		return nil, hcloud.NetworkSubnet{}, notFoundError(fmt.Sprintf("network %q not found", req.NetworkName))
+	}
+	if network.IPRange == nil || !cidrContains(network.IPRange, subnetRange) {
+		return nil, hcloud.NetworkSubnet{}, invalidRequestError(fmt.Sprintf("subnet cidr %s is outside network range %s", subnetRange, network.IPRange))
+	}
+	zone, err := s.networkZoneFor(ctx, req.Zone)
+	if err != nil {
+		return nil, hcloud.NetworkSubnet{}, err
+	}
+	subnet := hcloud.NetworkSubnet{
+		Type:        hcloud.NetworkSubnetTypeCloud,
+		NetworkZone: zone,
+		IPRange:     subnetRange,
+	}
+	for _, existing := range network.Subnets {
+		if existing.IPRange == nil || !cidrOverlaps(existing.IPRange, subnetRange) {
+			continue
+		}
+		if existing.IPRange.String() == subnetRange.String() && existing.NetworkZone == zone {
+			return nil, subnet, nil
+		}
+		return nil, hcloud.NetworkSubnet{}, conflictError(fmt.Sprintf("subnet cidr %s overlaps existing subnet %s", subnetRange, existing.IPRange))
+	}
+	return network, subnet, nil
+}
+
+// RemoveSubnet deletes the subnet with the given range. It reports false when
+// the network or subnet does not exist and fails with a conflict while a
+// server still holds an address in the range.
+func (s *RegionService) RemoveSubnet(ctx context.Context, networkName, cidr string) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	_, subnetRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil || subnetRange == nil {
+		return false, invalidRequestError("invalid subnet cidr")
+	}
+	network, _, err := s.networkByName(ctx, strings.TrimSpace(networkName))
+	if err != nil {
+		return false, err
+	}
+	if network == nil {
+		return false, nil
+	}
+	var subnet *hcloud.NetworkSubnet
+	for i := range network.Subnets {
+		if network.Subnets[i].IPRange != nil && network.Subnets[i].IPRange.String() == subnetRange.String() {
+			subnet = &network.Subnets[i]
+			break
+		}
+	}
+	if subnet == nil {
+		return false, nil
+	}
+	for _, ref := range network.Servers {
+		if ref == nil {
+			continue
+		}
+		server, _, err := s.clientFor(ctx).Server.GetByID(ctx, ref.ID)
+		if err != nil {
+			return false, err
+		}
+		if server == nil {
+			continue
+		}
+		for _, privateNet := range server.PrivateNet {
+			if privateNet.Network == nil || privateNet.Network.ID != network.ID {
+				continue
+			}
+			if privateNet.IP != nil && subnetRange.Contains(privateNet.IP) {
+				return false, conflictError(fmt.Sprintf("server %q still holds %s in subnet %s", server.Name, privateNet.IP, subnetRange))
+			}
+		}
+	}
+	action, _, err := s.clientFor(ctx).Network.DeleteSubnet(ctx, network, hcloud.NetworkDeleteSubnetOpts{Subnet: *subnet})
+	if err != nil {
+		return false, err
+	}
+	if action != nil {
+		if waitErr := s.waitForActions(ctx, action); waitErr != nil {
+			return false, waitErr
+		}
+	}
+	return true, nil
+}
+
+// networkZoneFor resolves a datacenter or location name to its network zone.
+func (s *RegionService) networkZoneFor(ctx context.Context, zone string) (hcloud.NetworkZone, error) {
+	name := strings.ToLower(strings.TrimSpace(zone))
+	if name == "" {
+		return "", invalidRequestError("subnet zone is required")
+	}
+	datacenter, _, err := s.clientFor(ctx).Datacenter.GetByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if datacenter != nil && datacenter.Location != nil && datacenter.Location.NetworkZone != "" {
+		return datacenter.Location.NetworkZone, nil
+	}
+	location, err := s.locationByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if location == nil || location.NetworkZone == "" {
+		return "", invalidRequestError(fmt.Sprintf("unknown zone %q", zone))
+	}
+	return location.NetworkZone, nil
+}
+
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+func cidrOverlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
 func networkFromHCloud(item *hcloud.Network) Network {
 	cidr := ""
 	if item.IPRange != nil {
 		cidr = item.IPRange.String()
 	}
 	return Network{
+		ID:        item.ID,
 		Name:      strings.ToLower(strings.TrimSpace(item.Name)),
 		CIDR:      cidr,
+		Zone:      string(cloudSubnetZone(item)),
 		Labels:    item.Labels,
 		CreatedAt: item.Created,
 	}
@@ -0,0 +1,142 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// subnetInUseServer serves network net1 (10.0.0.0/16) with the subnets
+// 10.0.0.0/24 and 10.0.1.0/24, where server 100 holds 10.0.1.5. Subnet
+// deletes are recorded by path.
+func subnetInUseServer(t *testing.T) (*RegionService, map[string]map[string]any) {
+	t.Helper()
+	changes := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks":
+			writeFakeJSON(w, map[string]any{"networks": []any{map[string]any{
+				"id":       50,
+				"name":     "net1",
+				"ip_range": "10.0.0.0/16",
+				"subnets": []any{
+					map[string]any{"type": "cloud", "network_zone": "eu-central", "ip_range": "10.0.0.0/24"},
+					map[string]any{"type": "cloud", "network_zone": "eu-central", "ip_range": "10.0.1.0/24"},
+				},
+				"servers": []int{100},
+			}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/100":
+			writeFakeJSON(w, map[string]any{"server": map[string]any{
+				"id":          100,
+				"name":        "vm1",
+				"private_net": []any{map[string]any{"network": 50, "ip": "10.0.1.5"}},
+			}})
+		case r.Method == http.MethodPost:
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			changes[r.URL.Path] = body
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 12, "status": "success"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}, changes
+}
+
+func TestAddSubnetCreatesCloudSubnetOnce(t *testing.T) {
+	t.Parallel()
+
+	service, fake := newFakeRegionService(t, config.ConformanceFlags{})
+	added, err := service.AddSubnet(context.Background(), NetworkSubnetRequest{NetworkName: "net1", CIDR: "10.0.2.0/24", Zone: "fsn1"})
+	if err != nil || !added {
+		t.Fatalf("expected the subnet to be added, got %v (err %v)", added, err)
+	}
+	body := fake.networkActions["add_subnet"]
+	if body["ip_range"] != "10.0.2.0/24" || body["network_zone"] != "eu-central" || body["type"] != "cloud" {
+		t.Fatalf("unexpected add_subnet request %v", body)
+	}
+
+	fake.networkActions = nil
+	added, err = service.AddSubnet(context.Background(), NetworkSubnetRequest{NetworkName: "net1", CIDR: "10.0.0.0/24", Zone: "nbg1"})
+	if err != nil || added || len(fake.networkActions) != 0 {
+		t.Fatalf("expected an existing subnet to be a no-op, got added %v actions %v (err %v)", added, fake.networkActions, err)
+	}
+}
+
+func TestRemoveSubnetRefusesRangesInUse(t *testing.T) {
+	t.Parallel()
+
+	service, changes := subnetInUseServer(t)
+	var providerErr ProviderError
+	if _, err := service.RemoveSubnet(context.Background(), "net1", "10.0.1.0/24"); !errors.As(err, &providerErr) || providerErr.Code != "conflict" {
+		t.Fatalf("expected a conflict while vm1 holds an address, got %v", err)
+	}
+	if removed, err := service.RemoveSubnet(context.Background(), "net1", "10.0.9.0/24"); err != nil || removed {
+		t.Fatalf("expected a missing subnet to report false, got %v (err %v)", removed, err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no subnet to be deleted yet, got %v", changes)
+	}
+	removed, err := service.RemoveSubnet(context.Background(), "net1", "10.0.0.0/24")
+	if err != nil || !removed {
+		t.Fatalf("expected the free subnet to be removed, got %v (err %v)", removed, err)
+	}
+	if body := changes["/networks/50/actions/delete_subnet"]; body["ip_range"] != "10.0.0.0/24" {
+		t.Fatalf("unexpected delete_subnet request %v", body)
+	}
+}
+
+func TestNetworkZoneForResolvesDatacentersAndLocations(t *testing.T) {
+	t.Parallel()
+
+	service, _ := newFakeRegionService(t, config.ConformanceFlags{})
+	for _, zone := range []string{"fsn1-dc14", "nbg1", "FSN1"} {
+		got, err := service.networkZoneFor(context.Background(), zone)
+		if err != nil || got != hcloud.NetworkZoneEUCentral {
+			t.Fatalf("%s: expected eu-central, got %s (err %v)", zone, got, err)
+		}
+	}
+	var providerErr ProviderError
+	if _, err := service.networkZoneFor(context.Background(), ""); !errors.As(err, &providerErr) || providerErr.Code != "invalid_request" {
+		t.Fatalf("expected a missing zone to be rejected, got %v", err)
+	}
+}
+
+func TestCIDRContainsAndOverlaps(t *testing.T) {
+	t.Parallel()
+
+	parse := func(cidr string) *net.IPNet {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ipNet
+	}
+	network := parse("10.0.0.0/16")
+	cases := []struct {
+		cidr               string
+		contains, overlaps bool
+	}{
+		{cidr: "10.0.1.0/24", contains: true, overlaps: true},
+		{cidr: "10.0.0.0/16", contains: true, overlaps: true},
+		{cidr: "10.0.0.0/8", contains: false, overlaps: true},
+		{cidr: "10.1.0.0/24", contains: false, overlaps: false},
+		{cidr: "fd00::/64", contains: false, overlaps: false},
+	}
+	for _, tc := range cases {
+		if got := cidrContains(network, parse(tc.cidr)); got != tc.contains {
+			t.Fatalf("%s: expected contains %v, got %v", tc.cidr, tc.contains, got)
+		}
+		if got := cidrOverlaps(network, parse(tc.cidr)); got != tc.overlaps {
+			t.Fatalf("%s: expected overlaps %v, got %v", tc.cidr, tc.overlaps, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// PlacementGroup is a Hetzner spread placement group: its servers run on
+// different physical hosts.
+type PlacementGroup struct {
+	ID        int64
+	Name      string
+	Labels    map[string]string
+	ServerIDs []int64
+	CreatedAt time.Time
+}
+
+type PlacementGroupCreateRequest struct {
+	Name   string
+	Labels map[string]string
+}
+
+func (s *RegionService) ListPlacementGroups(ctx context.Context) ([]PlacementGroup, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	items, err := s.clientFor(ctx).PlacementGroup.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PlacementGroup, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		out = append(out, placementGroupFromHetzner(item))
+	}
+	return out, nil
+}
+
+func (s *RegionService) GetPlacementGroup(ctx context.Context, name string) (*PlacementGroup, error) {
+	if !s.configuredFor(ctx) {
+		return nil, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).PlacementGroup.GetByName(ctx, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, nil
+	}
+	group := placementGroupFromHetzner(item)
+	return &group, nil
+}
+
+// CreateOrUpdatePlacementGroup creates a spread placement group or updates
+// the labels of an existing one.
+func (s *RegionService) CreateOrUpdatePlacementGroup(ctx context.Context, req PlacementGroupCreateRequest) (*PlacementGroup, bool, error) {
+	if !s.configuredFor(ctx) {
+		return nil, false, ErrNotConfigured
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, false, invalidRequestError("placement group name is required")
+	}
+	existing, _, err := s.clientFor(ctx).PlacementGroup.GetByName(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		if req.Labels != nil && !maps.Equal(existing.Labels, req.Labels) {
+			updated, _, err := s.clientFor(ctx).PlacementGroup.Update(ctx, existing, hcloud.PlacementGroupUpdateOpts{Labels: req.Labels})
+			if err != nil {
+				return nil, false, err
+			}
+			if updated != nil {
+				existing = updated
+			}
+		}
+		group := placementGroupFromHetzner(existing)
+		return &group, false, nil
+	}
+	result, _, err := s.clientFor(ctx).PlacementGroup.Create(ctx, hcloud.PlacementGroupCreateOpts{
+		Name:   name,
+		Labels: req.Labels,
+		Type:   hcloud.PlacementGroupTypeSpread,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if result.PlacementGroup == nil {
+		return nil, false, fmt.Errorf("hetzner returned empty placement group")
+	}
+	group := placementGroupFromHetzner(result.PlacementGroup)
+	return &group, true, nil
+}
+
+// DeletePlacementGroup deletes the placement group. Hetzner refuses to delete
+// a group that still holds servers; callers check that first.
+func (s *RegionService) DeletePlacementGroup(ctx context.Context, name string) (bool, error) {
+	if !s.configuredFor(ctx) {
+		return false, ErrNotConfigured
+	}
+	item, _, err := s.clientFor(ctx).PlacementGroup.GetByName(ctx, strings.TrimSpace(name))
+	if err != nil {
+		return false, err
+	}
+	if item == nil {
+		return false, nil
+	}
+	if _, err := s.clientFor(ctx).PlacementGroup.Delete(ctx, item); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// placementGroupByName resolves the placement group an instance request
+// names; a missing group is a not-found error.
+func (s *RegionService) placementGroupByName(ctx context.Context, name string) (*hcloud.PlacementGroup, error) {
+	group, _, err := s.clientFor(ctx).PlacementGroup.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, notFoundError(fmt.Sprintf("placement group %q not found", name))
+	}
+	return group, nil
+}
+
+// updateInstancePlacement moves server into the placement group req names,
+// or out of its group when req names none. Hetzner only adds powered-off
+// servers to a group, so a running server is powered off for the move and on
+// again afterwards. It returns the ID of the last action, or "" when the
+// server already is where req wants it.
+func (s *RegionService) updateInstancePlacement(ctx context.Context, server *hcloud.Server, req InstanceCreateRequest) (string, error) {
+	current := ""
+	if server.PlacementGroup != nil {
+		current = server.PlacementGroup.Name
+	}
+	if strings.EqualFold(current, req.PlacementGroup) {
+		return "", nil
+	}
+	var target *hcloud.PlacementGroup
+	if req.PlacementGroup != "" {
+		var err error
+		if target, err = s.placementGroupByName(ctx, req.PlacementGroup); err != nil {
+			return "", err
+		}
+	}
+	client := s.clientFor(ctx)
+	var last *hcloud.Action
+	if server.PlacementGroup != nil {
+		action, _, err := client.Server.RemoveFromPlacementGroup(ctx, server)
+		if err != nil {
+			return "", err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return "", err
+		}
+		last = action
+	}
+	if target != nil {
+		wasRunning := server.Status == hcloud.ServerStatusRunning || server.Status == hcloud.ServerStatusStarting
+		if server.Status != hcloud.ServerStatusOff {
+			action, _, err := client.Server.Poweroff(ctx, server)
+			if err != nil {
+				return "", err
+			}
+			if err := s.waitForActions(ctx, action); err != nil {
+				return "", err
+			}
+		}
+		action, _, err := client.Server.AddToPlacementGroup(ctx, server, target)
+		if err != nil {
+			return "", err
+		}
+		if err := s.waitForActions(ctx, action); err != nil {
+			return "", err
+		}
+		last = action
+		if wasRunning {
+			powerOn, _, err := client.Server.Poweron(ctx, server)
+			if err != nil {
+				return "", err
+			}
+			last = powerOn
+		}
+	}
+	if last == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%d", last.ID), nil
+}
+
+func placementGroupFromHetzner(item *hcloud.PlacementGroup) PlacementGroup {
+	return PlacementGroup{
+		ID:        item.ID,
+		Name:      strings.ToLower(item.Name),
+		Labels:    item.Labels,
+		ServerIDs: item.Servers,
+		CreatedAt: item.Created,
+	}
+}
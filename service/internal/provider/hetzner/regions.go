@@ -3,8 +3,9 @@ package hetzner
 import (
 	"context"
 	"errors"
+	"net/http"
 	"sort"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/config"
@@ -14,11 +15,14 @@ import (
 var ErrNotConfigured = errors.New("hetzner api token not configured")
 
 type Region struct {
-	Name      string
-	City      string
-	Country   string
-	Zones     []string
-	Providers []Provider
+	Name          string
+	City          string
+	Country       string
+	Zones         []string
+	NetworkZone   string
+	Architectures []string
+	BlockStorage  bool
+	Providers     []Provider
 }
 
 type Provider struct {
@@ -29,116 +33,118 @@ type Provider struct {
 
 type RegionService struct {
 	client          *hcloud.Client
-	configured      bool
+	globalToken     string
 	publicBase      string
 	cloudAPIURL     string
 	apiURL          string
 	availCacheTTL   time.Duration
-	conformanceMode bool
-
-	serverTypesCacheMu sync.RWMutex
-	serverTypesCacheAt time.Time
-	serverTypesCache   []*hcloud.ServerType
+	catalogCacheTTL time.Duration
+	conformance     config.ConformanceFlags
+	skuStrategy     string
+	readRetry       ReadRetryPolicy
+	timeouts        CallTimeouts
+	calls           CallObserver
+	audit           AuditRecorder
+
+	catalog        *catalogCache
+	volumeCapacity volumeCapacityTracker
+	warmup         warmupTracker
+	health         healthProbe
+	global         globalCredential
 }
 
-func NewRegionService(cfg config.Config) *RegionService {
-	client := hcloud.NewClient(
-		hcloud.WithToken(""),
+// NewRegionService builds the Hetzner provider. calls, when non-nil, observes
+// every Hetzner API request made with the shared or a workspace credential;
+// audit, when non-nil, records the mutating ones.
+func NewRegionService(cfg config.Config, calls CallObserver, audit AuditRecorder) *RegionService {
+	readRetry := ReadRetryPolicy{
+		MaxAttempts: cfg.HetznerReadRetries,
+		BaseDelay:   cfg.HetznerRetryBackoff,
+	}
+	timeouts := CallTimeouts{
+		Read:       cfg.HetznerReadTimeout,
+		Mutation:   cfg.HetznerWriteTimeout,
+		ActionWait: cfg.HetznerActionWait,
+	}
+	client := hcloud.NewClient(append(
+		readRetryClientOptions(callTimeoutTransport{base: instrumentTransport(auditCalls(traceTransport(http.DefaultTransport), audit), calls), timeouts: timeouts}, readRetry),
+		hcloud.WithToken(cfg.HetznerToken),
 		hcloud.WithEndpoint(cfg.HetznerCloudAPIURL),
 		hcloud.WithHetznerEndpoint(cfg.HetznerPrimaryAPIURL),
-	)
+	)...)
 	return &RegionService{
 		client:          client,
-		configured:      true,
+		globalToken:     cfg.HetznerToken,
 		publicBase:      cfg.PublicBaseURL,
 		cloudAPIURL:     cfg.HetznerCloudAPIURL,
 		apiURL:          cfg.HetznerPrimaryAPIURL,
 		availCacheTTL:   cfg.HetznerAvailCacheTTL,
-		conformanceMode: cfg.ConformanceMode,
-	}
-}
-
-func (s *RegionService) listServerTypes(ctx context.Context) ([]*hcloud.ServerType, error) {
-	if _, ok := workspaceCredentialFromContext(ctx); ok {
-		return s.clientFor(ctx).ServerType.All(ctx)
-	}
-	if s.availCacheTTL <= 0 {
-		return s.clientFor(ctx).ServerType.All(ctx)
-	}
-
-	now := time.Now()
-	s.serverTypesCacheMu.RLock()
-	if len(s.serverTypesCache) > 0 && now.Sub(s.serverTypesCacheAt) < s.availCacheTTL {
-		cached := cloneServerTypes(s.serverTypesCache)
-		s.serverTypesCacheMu.RUnlock()
-		return cached, nil
-	}
-	s.serverTypesCacheMu.RUnlock()
-
-	serverTypes, err := s.clientFor(ctx).ServerType.All(ctx)
-	if err != nil {
-		return nil, err
-	}
-	cloned := cloneServerTypes(serverTypes)
-
-	s.serverTypesCacheMu.Lock()
-	s.serverTypesCache = cloneServerTypes(cloned)
-	s.serverTypesCacheAt = time.Now()
-	s.serverTypesCacheMu.Unlock()
-
-	return cloned, nil
-}
-
-func cloneServerTypes(in []*hcloud.ServerType) []*hcloud.ServerType {
-	if len(in) == 0 {
-		return nil
+		catalogCacheTTL: cfg.CatalogCacheTTL,
+		conformance:     cfg.ConformanceFlags,
+		skuStrategy:     cfg.DefaultSKUStrategy,
+		readRetry:       readRetry,
+		timeouts:        timeouts,
+		calls:           calls,
+		audit:           audit,
+		catalog:         newCatalogCache(),
 	}
-	out := make([]*hcloud.ServerType, len(in))
-	copy(out, in)
-	return out
 }
 
 func (s *RegionService) ListRegions(ctx context.Context) ([]Region, error) {
-	locations, err := s.clientFor(ctx).Location.All(ctx)
+	locations, err := s.listLocations(ctx)
 	if err != nil {
 		if _, hasWorkspaceCred := workspaceCredentialFromContext(ctx); !hasWorkspaceCred && shouldUseStaticRegionsFallback(err) {
 			return s.staticRegions(), nil
 		}
 		return nil, err
 	}
-	dataCenters, err := s.clientFor(ctx).Datacenter.All(ctx)
+	dataCenters, err := s.listDatacenters(ctx)
 	if err != nil {
 		if _, hasWorkspaceCred := workspaceCredentialFromContext(ctx); !hasWorkspaceCred && shouldUseStaticRegionsFallback(err) {
 			return s.staticRegions(), nil
 		}
 		return nil, err
 	}
+	serverTypes, err := s.listServerTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	architectureByType := make(map[int64]string, len(serverTypes))
+	for _, st := range serverTypes {
+		if st != nil {
+			architectureByType[st.ID] = string(st.Architecture)
+		}
+	}
 
 	zonesByLocation := make(map[string][]string)
+	architecturesByLocation := make(map[string][]string)
 	for _, dc := range dataCenters {
-		if dc.Location == nil {
+		if dc == nil || dc.Location == nil {
 			continue
 		}
 		zonesByLocation[dc.Location.Name] = append(zonesByLocation[dc.Location.Name], dc.Name)
+		for _, st := range dc.ServerTypes.Available {
+			if st == nil || architectureByType[st.ID] == "" {
+				continue
+			}
+			architecturesByLocation[dc.Location.Name] = append(architecturesByLocation[dc.Location.Name], architectureByType[st.ID])
+		}
 	}
 
 	regions := make([]Region, 0, len(locations))
 	for _, loc := range locations {
-		zones := dedupeSorted(zonesByLocation[loc.Name])
+		architectures := dedupeSorted(architecturesByLocation[loc.Name])
 		regions = append(regions, Region{
-			Name:    loc.Name,
-			City:    loc.City,
-			Country: loc.Country,
-			Zones:   zones,
-			Providers: []Provider{
-				{Name: "hetzner.cloud", Version: "v1", URL: s.cloudAPIURL},
-				{Name: "hetzner", Version: "v1", URL: s.apiURL},
-				{Name: "seca.region", Version: "v1", URL: s.publicBase},
-				{Name: "seca.workspace", Version: "v1", URL: s.publicBase + "/workspace"},
-				{Name: "seca.compute", Version: "v1", URL: s.publicBase + "/compute"},
-				{Name: "seca.storage", Version: "v1", URL: s.publicBase + "/storage"},
-				{Name: "seca.network", Version: "v1", URL: s.publicBase + "/network"},
-			},
+			Name:          loc.Name,
+			City:          loc.City,
+			Country:       loc.Country,
+			Zones:         dedupeSorted(zonesByLocation[loc.Name]),
+			NetworkZone:   string(loc.NetworkZone),
+			Architectures: architectures,
+			// Volumes attach only to servers in their own location, so a
+			// location offers block storage while it offers servers.
+			BlockStorage: len(architectures) > 0,
+			Providers:    s.regionProviders(),
 		})
 	}
 
@@ -149,6 +155,9 @@ func (s *RegionService) ListRegions(ctx context.Context) ([]Region, error) {
 }
 
 func shouldUseStaticRegionsFallback(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return true
+	}
 	var apiErr hcloud.Error
 	if !errors.As(err, &apiErr) {
 		return false
@@ -159,7 +168,23 @@ func shouldUseStaticRegionsFallback(err error) bool {
 func (s *RegionService) staticRegions() []Region {
 	records := loadFallbackRegions()
 	out := make([]Region, 0, len(records))
-	providers := []Provider{
+	for _, record := range records {
+		out = append(out, Region{
+			Name:          record.Name,
+			City:          record.City,
+			Country:       record.Country,
+			Zones:         record.Zones,
+			NetworkZone:   record.NetworkZone,
+			Architectures: record.Architectures,
+			BlockStorage:  record.BlockStorage,
+			Providers:     s.regionProviders(),
+		})
+	}
+	return out
+}
+
+func (s *RegionService) regionProviders() []Provider {
+	return []Provider{
 		{Name: "hetzner.cloud", Version: "v1", URL: s.cloudAPIURL},
 		{Name: "hetzner", Version: "v1", URL: s.apiURL},
 		{Name: "seca.region", Version: "v1", URL: s.publicBase},
@@ -168,16 +193,6 @@ func (s *RegionService) staticRegions() []Region {
 		{Name: "seca.storage", Version: "v1", URL: s.publicBase + "/storage"},
 		{Name: "seca.network", Version: "v1", URL: s.publicBase + "/network"},
 	}
-	for _, record := range records {
-		out = append(out, Region{
-			Name:      record.Name,
-			City:      record.City,
-			Country:   record.Country,
-			Zones:     record.Zones,
-			Providers: providers,
-		})
-	}
-	return out
 }
 
 func (s *RegionService) GetRegion(ctx context.Context, name string) (*Region, error) {
@@ -186,7 +201,7 @@ func (s *RegionService) GetRegion(ctx context.Context, name string) (*Region, er
 		return nil, err
 	}
 	for _, region := range regions {
-		if region.Name == name {
+		if strings.EqualFold(region.Name, strings.TrimSpace(name)) {
 			copyRegion := region
 			return &copyRegion, nil
 		}
@@ -194,6 +209,16 @@ func (s *RegionService) GetRegion(ctx context.Context, name string) (*Region, er
 	return nil, nil
 }
 
+// regionOffersBlockStorage reports whether volumes can be created in region,
+// going by Region.BlockStorage like every other block storage placement.
+func (s *RegionService) regionOffersBlockStorage(ctx context.Context, region string) (bool, error) {
+	found, err := s.GetRegion(ctx, region)
+	if err != nil || found == nil {
+		return false, err
+	}
+	return found.BlockStorage, nil
+}
+
 func dedupeSorted(values []string) []string {
 	if len(values) == 0 {
 		return []string{}
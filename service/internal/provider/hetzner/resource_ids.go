@@ -0,0 +1,135 @@
+package hetzner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// Kinds of Hetzner resources whose IDs can be pinned with WithResourceID.
+const (
+	ResourceServer   = "servers"
+	ResourceVolume   = "volumes"
+	ResourceNetwork  = "networks"
+	ResourceFirewall = "firewalls"
+)
+
+type resourceIDsContextKey struct{}
+
+type resourceIDLookupContextKey struct{}
+
+// ResourceIDLookup returns the Hetzner ID recorded for the named resource of
+// kind, or false when none is recorded.
+type ResourceIDLookup func(ctx context.Context, kind, name string) (int64, bool)
+
+// WithResourceID pins the Hetzner ID the resource binding recorded for the
+// named resource. Lookups by that name then fetch the resource by ID, so a
+// server, volume, network or firewall renamed in the Hetzner console is still
+// found, and a
+// resource created under the same name by someone else is never mistaken for
+// it. Resources without a pinned ID are looked up by name, which is how
+// pre-existing resources are adopted.
+func WithResourceID(ctx context.Context, kind, name string, id int64) context.Context {
+	if id <= 0 {
+		return ctx
+	}
+	current, _ := ctx.Value(resourceIDsContextKey{}).(map[string]int64)
+	ids := make(map[string]int64, len(current)+1)
+	for key, value := range current {
+		ids[key] = value
+	}
+	ids[resourceIDKey(kind, name)] = id
+	return context.WithValue(ctx, resourceIDsContextKey{}, ids)
+}
+
+// WithResourceIDLookup resolves pinned IDs on demand: a name without an ID
+// set by WithResourceID is passed to lookup when a lookup by that name needs
+// it, so a request only reads the bindings it uses.
+func WithResourceIDLookup(ctx context.Context, lookup ResourceIDLookup) context.Context {
+	return context.WithValue(ctx, resourceIDLookupContextKey{}, lookup)
+}
+
+func pinnedResourceID(ctx context.Context, kind, name string) (int64, bool) {
+	ids, _ := ctx.Value(resourceIDsContextKey{}).(map[string]int64)
+	if id, ok := ids[resourceIDKey(kind, name)]; ok {
+		return id, true
+	}
+	lookup, _ := ctx.Value(resourceIDLookupContextKey{}).(ResourceIDLookup)
+	if lookup == nil {
+		return 0, false
+	}
+	id, ok := lookup(ctx, kind, strings.ToLower(strings.TrimSpace(name)))
+	return id, ok && id > 0
+}
+
+func resourceIDKey(kind, name string) string {
+	return kind + "/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// serverByName resolves the server behind a SECA instance name: by its pinned
+// ID when one is set, by name otherwise. A pinned server that no longer
+// exists is nil, not whatever now carries the name. A server renamed in
+// Hetzner keeps the SECA name.
+func (s *RegionService) serverByName(ctx context.Context, name string) (*hcloud.Server, *hcloud.Response, error) {
+	name = strings.TrimSpace(name)
+	id, ok := pinnedResourceID(ctx, ResourceServer, name)
+	if !ok {
+		return s.clientFor(ctx).Server.GetByName(ctx, name)
+	}
+	server, resp, err := s.clientFor(ctx).Server.GetByID(ctx, id)
+	if err != nil || server == nil || strings.EqualFold(server.Name, name) {
+		return server, resp, err
+	}
+	renamed := *server
+	renamed.Name = strings.ToLower(name)
+	return &renamed, resp, nil
+}
+
+// volumeByName is serverByName for volumes.
+func (s *RegionService) volumeByName(ctx context.Context, name string) (*hcloud.Volume, *hcloud.Response, error) {
+	name = strings.TrimSpace(name)
+	id, ok := pinnedResourceID(ctx, ResourceVolume, name)
+	if !ok {
+		return s.clientFor(ctx).Volume.GetByName(ctx, name)
+	}
+	volume, resp, err := s.clientFor(ctx).Volume.GetByID(ctx, id)
+	if err != nil || volume == nil || strings.EqualFold(volume.Name, name) {
+		return volume, resp, err
+	}
+	renamed := *volume
+	renamed.Name = strings.ToLower(name)
+	return &renamed, resp, nil
+}
+
+// networkByName is serverByName for networks.
+func (s *RegionService) networkByName(ctx context.Context, name string) (*hcloud.Network, *hcloud.Response, error) {
+	name = strings.TrimSpace(name)
+	id, ok := pinnedResourceID(ctx, ResourceNetwork, name)
+	if !ok {
+		return s.clientFor(ctx).Network.GetByName(ctx, name)
+	}
+	network, resp, err := s.clientFor(ctx).Network.GetByID(ctx, id)
+	if err != nil || network == nil || strings.EqualFold(network.Name, name) {
+		return network, resp, err
+	}
+	renamed := *network
+	renamed.Name = strings.ToLower(name)
+	return &renamed, resp, nil
+}
+
+// firewallByName is serverByName for firewalls.
+func (s *RegionService) firewallByName(ctx context.Context, name string) (*hcloud.Firewall, *hcloud.Response, error) {
+	name = strings.TrimSpace(name)
+	id, ok := pinnedResourceID(ctx, ResourceFirewall, name)
+	if !ok {
+		return s.clientFor(ctx).Firewall.GetByName(ctx, name)
+	}
+	firewall, resp, err := s.clientFor(ctx).Firewall.GetByID(ctx, id)
+	if err != nil || firewall == nil || strings.EqualFold(firewall.Name, name) {
+		return firewall, resp, err
+	}
+	renamed := *firewall
+	renamed.Name = strings.ToLower(name)
+	return &renamed, resp, nil
+}
@@ -0,0 +1,104 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestPinnedServerIsLookedUpByIDAfterRename(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			writeFakeJSON(w, map[string]any{"servers": []any{map[string]any{"id": 5, "name": "vm1"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/7":
+			writeFakeJSON(w, map[string]any{"server": map[string]any{"id": 7, "name": "renamed-in-console"}})
+		case r.Method == http.MethodGet && r.URL.Path == "/servers/8":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": "not_found", "message": "server not found"}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/servers/7":
+			writeFakeJSON(w, map[string]any{"action": map[string]any{"id": 9, "status": "running"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	ctx := WithResourceID(context.Background(), ResourceServer, "vm1", 7)
+	instance, err := service.GetInstance(ctx, "vm1")
+	if err != nil || instance == nil || instance.ID != 7 || instance.Name != "vm1" {
+		t.Fatalf("expected server 7 under its SECA name, got %+v (err %v)", instance, err)
+	}
+	deleted, _, err := service.DeleteInstance(ctx, "vm1")
+	if err != nil || !deleted {
+		t.Fatalf("expected delete, got %v (err %v)", deleted, err)
+	}
+
+	gone := WithResourceID(context.Background(), ResourceServer, "vm1", 8)
+	instance, err = service.GetInstance(gone, "vm1")
+	if err != nil || instance != nil {
+		t.Fatalf("expected a deleted pinned server to stay gone, got %+v (err %v)", instance, err)
+	}
+
+	want := []string{"GET /servers/7", "GET /servers/7", "DELETE /servers/7", "GET /servers/8"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestResourceIDLookupPinsNetworksAndFirewalls(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks/11":
+			writeFakeJSON(w, map[string]any{"network": map[string]any{"id": 11, "name": "renamed-net", "ip_range": "10.0.0.0/16"}})
+		case r.Method == http.MethodGet && r.URL.Path == "/firewalls/12":
+			writeFakeJSON(w, map[string]any{"firewall": map[string]any{"id": 12, "name": "renamed-fw"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	service := &RegionService{client: hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("test")), globalToken: "test"}
+
+	var looked []string
+	ctx := WithResourceIDLookup(context.Background(), func(_ context.Context, kind, name string) (int64, bool) {
+		looked = append(looked, kind+"/"+name)
+		switch kind + "/" + name {
+		case ResourceNetwork + "/net-1":
+			return 11, true
+		case ResourceFirewall + "/sg-1":
+			return 12, true
+		}
+		return 0, false
+	})
+	network, err := service.GetNetwork(ctx, "Net-1")
+	if err != nil || network == nil || network.ID != 11 || network.Name != "net-1" {
+		t.Fatalf("expected network 11 under its SECA name, got %+v (err %v)", network, err)
+	}
+	group, err := service.GetSecurityGroup(ctx, "sg-1")
+	if err != nil || group == nil || group.ID != 12 || group.Name != "sg-1" {
+		t.Fatalf("expected firewall 12 under its SECA name, got %+v (err %v)", group, err)
+	}
+
+	if want := []string{"networks/net-1", "firewalls/sg-1"}; strings.Join(looked, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected lookups %v, got %v", want, looked)
+	}
+	if want := []string{"GET /networks/11", "GET /firewalls/12"}; strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
@@ -0,0 +1,141 @@
+package hetzner
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// maxReadRetryDelay caps a single wait between read attempts, including waits
+// requested by Hetzner through Retry-After or RateLimit-Reset.
+const maxReadRetryDelay = 30 * time.Second
+
+// ReadRetryPolicy controls how GET requests to Hetzner are retried.
+// MaxAttempts counts the first try; 1 or less disables retries.
+type ReadRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+}
+
+// readRetryTransport retries idempotent requests (GET, HEAD) that hit a rate
+// limit or a transient gateway error, with exponential backoff and full
+// jitter. Mutating requests are sent exactly once: the hcloud client's own
+// retries are disabled, so a create or delete is never repeated behind the
+// caller's back.
+type readRetryTransport struct {
+	base   http.RoundTripper
+	policy ReadRetryPolicy
+	now    func() time.Time
+	jitter func(time.Duration) time.Duration
+}
+
+func newReadRetryTransport(base http.RoundTripper, policy ReadRetryPolicy) readRetryTransport {
+	return readRetryTransport{
+		base:   base,
+		policy: policy,
+		now:    time.Now,
+		jitter: func(d time.Duration) time.Duration {
+			if d <= 0 {
+				return 0
+			}
+			return rand.N(d) + 1
+		},
+	}
+}
+
+func (t readRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || ctx.Err() != nil || !retryableRead(resp, err) {
+			return resp, err
+		}
+		delay := t.delay(resp, attempt)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay honours a retry hint from Hetzner and otherwise backs off
+// exponentially from BaseDelay.
+func (t readRetryTransport) delay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if hint, ok := retryAfterFromHeader(resp.Header, t.now()); ok {
+			return min(hint, maxReadRetryDelay)
+		}
+	}
+	backoff := t.policy.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > maxReadRetryDelay {
+		backoff = maxReadRetryDelay
+	}
+	return t.jitter(backoff)
+}
+
+func retryableRead(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfterFromHeader reads Retry-After (seconds) or, failing that, the
+// Hetzner RateLimit-Reset timestamp.
+func retryAfterFromHeader(header http.Header, now time.Time) (time.Duration, bool) {
+	if raw := header.Get("Retry-After"); raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(raw); err == nil {
+			return max(at.Sub(now), 0), true
+		}
+	}
+	if raw := header.Get("RateLimit-Reset"); raw != "" {
+		if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return max(time.Unix(unix, 0).Sub(now), 0), true
+		}
+	}
+	return 0, false
+}
+
+// RetryAfter returns how long Hetzner asked callers to wait before retrying
+// the request that failed with err.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr hcloud.Error
+	if !errors.As(err, &apiErr) || apiErr.Response() == nil || apiErr.Response().Response == nil {
+		return 0, false
+	}
+	return retryAfterFromHeader(apiErr.Response().Header, time.Now())
+}
+
+// readRetryClientOptions routes an hcloud client through readRetryTransport
+// and turns off the client's built-in retries, which would also repeat
+// mutating requests.
+func readRetryClientOptions(base http.RoundTripper, policy ReadRetryPolicy) []hcloud.ClientOption {
+	return []hcloud.ClientOption{
+		hcloud.WithHTTPClient(&http.Client{Transport: newReadRetryTransport(base, policy)}),
+		hcloud.WithRetryOpts(hcloud.RetryOpts{MaxRetries: 0}),
+	}
+}
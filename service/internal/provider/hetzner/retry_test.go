@@ -0,0 +1,149 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const rateLimitBody = `{"error":{"code":"rate_limit_exceeded","message":"limit reached"}}`
+
+func newRetryTestClient(t *testing.T, handler http.HandlerFunc, policy ReadRetryPolicy) *hcloud.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return hcloud.NewClient(append(
+		readRetryClientOptions(http.DefaultTransport, policy),
+		hcloud.WithEndpoint(srv.URL),
+		hcloud.WithToken("test"),
+	)...)
+}
+
+func TestReadRetryTransportRetriesRateLimitedReads(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	client := newRetryTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(rateLimitBody))
+			return
+		}
+		_, _ = w.Write([]byte(`{"locations":[{"id":1,"name":"fsn1"}]}`))
+	}, ReadRetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond})
+
+	location, _, err := client.Location.GetByName(context.Background(), "fsn1")
+	if err != nil || location == nil || location.Name != "fsn1" {
+		t.Fatalf("expected read to succeed after retries, got %v, %v", location, err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestReadRetryTransportGivesUpWithRetryHint(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	client := newRetryTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(rateLimitBody))
+	}, ReadRetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	_, _, err := client.Location.GetByName(context.Background(), "fsn1")
+	if !hcloud.IsError(err, hcloud.ErrorCodeRateLimitExceeded) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected attempts to be capped at 2, got %d", got)
+	}
+	if wait, ok := RetryAfter(err); !ok || wait != 0 {
+		t.Fatalf("expected Retry-After hint on the error, got %s, %t", wait, ok)
+	}
+}
+
+func TestReadRetryTransportNeverRetriesMutations(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	client := newRetryTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(rateLimitBody))
+	}, ReadRetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond})
+
+	_, _, err := client.Network.Create(context.Background(), hcloud.NetworkCreateOpts{Name: "net1", IPRange: mustParseCIDR(t, "10.0.0.0/16")})
+	if !hcloud.IsError(err, hcloud.ErrorCodeRateLimitExceeded) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected a single POST, got %d", got)
+	}
+}
+
+func TestReadRetryTransportStopsOnCancelledContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newRetryTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		cancel()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(rateLimitBody))
+	}, ReadRetryPolicy{MaxAttempts: 4, BaseDelay: time.Hour})
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.Location.GetByName(ctx, "fsn1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) && !hcloud.IsError(err, hcloud.ErrorCodeRateLimitExceeded) {
+			t.Fatalf("expected cancellation or the last rate limit error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected retry loop to stop when the context is cancelled")
+	}
+}
+
+func TestReadRetryDelayHonoursRateLimitReset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	transport := newReadRetryTransport(http.DefaultTransport, ReadRetryPolicy{MaxAttempts: 3, BaseDelay: time.Second})
+	transport.now = func() time.Time { return now }
+	transport.jitter = func(d time.Duration) time.Duration { return d }
+
+	resp := &http.Response{Header: http.Header{"Ratelimit-Reset": []string{"1700000007"}}}
+	if got := transport.delay(resp, 1); got != 7*time.Second {
+		t.Fatalf("expected RateLimit-Reset hint, got %s", got)
+	}
+	if got := transport.delay(&http.Response{Header: http.Header{}}, 3); got != 4*time.Second {
+		t.Fatalf("expected exponential backoff, got %s", got)
+	}
+	if got := transport.delay(&http.Response{Header: http.Header{"Retry-After": []string{"3600"}}}, 1); got != maxReadRetryDelay {
+		t.Fatalf("expected hint to be capped, got %s", got)
+	}
+}
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("parse %s: %v", cidr, err)
+	}
+	return ipNet
+}
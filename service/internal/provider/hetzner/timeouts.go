@@ -0,0 +1,102 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ErrTimeout reports a Hetzner request, or a wait for a Hetzner action, that
+// did not finish within its configured bound. A timed-out mutation or action
+// may still complete at Hetzner.
+var ErrTimeout = errors.New("hetzner did not answer in time")
+
+// CallTimeouts bound the time spent on Hetzner. Read and Mutation apply to
+// each request sent, retries of a read included one by one; ActionWait
+// applies to each wait for an action to finish. Zero disables a bound.
+type CallTimeouts struct {
+	Read       time.Duration
+	Mutation   time.Duration
+	ActionWait time.Duration
+}
+
+// callTimeoutTransport gives every request its own deadline, so a slow
+// Hetzner API fails the request instead of holding the handler until the
+// client gives up.
+type callTimeoutTransport struct {
+	base     http.RoundTripper
+	timeouts CallTimeouts
+}
+
+func (t callTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := t.timeouts.Mutation
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		timeout = t.timeouts.Read
+	}
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeoutCause(req.Context(), timeout, ErrTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if errors.Is(context.Cause(ctx), ErrTimeout) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrTimeout, callOperation(req), timeout)
+		}
+		return nil, err
+	}
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, operation: callOperation(req), timeout: timeout}
+	return resp, nil
+}
+
+// deadlineBody keeps the request deadline running while the body is read
+// and releases it on Close.
+type deadlineBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	cancel    context.CancelFunc
+	operation string
+	timeout   time.Duration
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && errors.Is(context.Cause(b.ctx), ErrTimeout) {
+		return n, fmt.Errorf("%w: %s after %s", ErrTimeout, b.operation, b.timeout)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// waitForActions waits until the actions finish, at most the configured
+// action wait. Running out of time fails with ErrTimeout, and a shutdown
+// that cannot wait any longer with ErrShuttingDown, while the actions carry
+// on at Hetzner.
+func (s *RegionService) waitForActions(ctx context.Context, actions ...*hcloud.Action) error {
+	ctx, done := TrackInFlight(ctx)
+	defer done()
+	waitCtx, cancel := ctx, context.CancelFunc(func() {})
+	if wait := s.timeouts.ActionWait; wait > 0 {
+		waitCtx, cancel = context.WithTimeoutCause(ctx, wait, ErrTimeout)
+	}
+	defer cancel()
+	err := s.clientFor(waitCtx).Action.WaitFor(waitCtx, actions...)
+	switch {
+	case err == nil:
+		return nil
+	case shuttingDown(ctx):
+		return fmt.Errorf("%w: action still running", ErrShuttingDown)
+	case ctx.Err() == nil && errors.Is(context.Cause(waitCtx), ErrTimeout):
+		return fmt.Errorf("%w: action still running after %s", ErrTimeout, s.timeouts.ActionWait)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestCallTimeoutTransportFailsSlowRequestsWithErrTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	client := hcloud.NewClient(append(
+		readRetryClientOptions(callTimeoutTransport{
+			base:     http.DefaultTransport,
+			timeouts: CallTimeouts{Read: 20 * time.Millisecond, Mutation: time.Minute},
+		}, ReadRetryPolicy{MaxAttempts: 1}),
+		hcloud.WithEndpoint(srv.URL),
+		hcloud.WithToken("test"),
+	)...)
+
+	_, _, err := client.Location.GetByName(context.Background(), "fsn1")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestCallTimeoutTransportKeepsCallerCancellation(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport := callTimeoutTransport{base: http.DefaultTransport, timeouts: CallTimeouts{Read: time.Minute}}
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:1/locations", nil)
+	req.RequestURI = ""
+
+	_, err := transport.RoundTrip(req)
+	if err == nil || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected the caller's cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// VolumeCapacity is the block storage availability of one region. Hetzner
+// publishes no volume capacity, so a region that offers block storage counts
+// as available until a volume create there fails with no_space_left_in_location.
+type VolumeCapacity struct {
+	Region      string
+	NetworkZone string
+	Available   bool
+	// ExhaustedAt is when a volume create in the region last ran out of space,
+	// zero unless that happened within the availability cache TTL.
+	ExhaustedAt time.Time
+}
+
+// volumeCapacityTracker remembers, per credential scope, the locations where
+// Hetzner recently had no space left for volumes.
+type volumeCapacityTracker struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	exhausted map[catalogCacheKey]time.Time
+}
+
+func (t *volumeCapacityTracker) markExhausted(ctx context.Context, location string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.exhausted == nil {
+		t.exhausted = map[catalogCacheKey]time.Time{}
+	}
+	t.exhausted[catalogCacheKey{scope: catalogScope(ctx), kind: strings.ToLower(location)}] = t.clock()
+}
+
+func (t *volumeCapacityTracker) markAvailable(ctx context.Context, location string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.exhausted, catalogCacheKey{scope: catalogScope(ctx), kind: strings.ToLower(location)})
+}
+
+// exhaustedAt returns when location last ran out of space, or zero when that
+// is longer ago than ttl.
+func (t *volumeCapacityTracker) exhaustedAt(ctx context.Context, location string, ttl time.Duration) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.exhausted[catalogCacheKey{scope: catalogScope(ctx), kind: strings.ToLower(location)}]
+	if !ok || t.clock().Sub(at) >= ttl {
+		return time.Time{}
+	}
+	return at
+}
+
+func (t *volumeCapacityTracker) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// VolumeCapacityByLocation reports for every region whether volumes can
+// currently be created there, sorted by region name.
+func (s *RegionService) VolumeCapacityByLocation(ctx context.Context) ([]VolumeCapacity, error) {
+	regions, err := s.ListRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]VolumeCapacity, 0, len(regions))
+	for _, region := range regions {
+		capacity := VolumeCapacity{Region: region.Name, NetworkZone: region.NetworkZone}
+		if region.BlockStorage {
+			capacity.ExhaustedAt = s.volumeCapacity.exhaustedAt(ctx, region.Name, s.availCacheTTL)
+			capacity.Available = capacity.ExhaustedAt.IsZero()
+		}
+		out = append(out, capacity)
+	}
+	return out, nil
+}
+
+func isNoSpaceLeftError(err error) bool {
+	var apiErr hcloud.Error
+	return errors.As(err, &apiErr) && apiErr.Code == hcloud.ErrorCodeNoSpaceLeftInLocation
+}
+
+// volumeCapacityError explains a volume create that ran out of space in
+// location and names the regions in the same network zone that still have
+// capacity. The volume is never moved there; only the conformance location
+// fallback does that.
+func (s *RegionService) volumeCapacityError(ctx context.Context, location *hcloud.Location) error {
+	region := strings.ToLower(location.Name)
+	detail := fmt.Sprintf("no block storage capacity left in region %q", region)
+	capacities, err := s.VolumeCapacityByLocation(ctx)
+	if err != nil {
+		return unavailableError(detail)
+	}
+	nearby := make([]string, 0, len(capacities))
+	for _, capacity := range capacities {
+		if capacity.Available && capacity.NetworkZone == string(location.NetworkZone) && !strings.EqualFold(capacity.Region, region) {
+			nearby = append(nearby, capacity.Region)
+		}
+	}
+	if len(nearby) == 0 {
+		return unavailableError(fmt.Sprintf("%s and no other region in network zone %s currently has capacity", detail, location.NetworkZone))
+	}
+	return unavailableError(fmt.Sprintf("%s; regions in network zone %s with capacity: %s", detail, location.NetworkZone, strings.Join(nearby, ", ")))
+}
@@ -0,0 +1,87 @@
+package hetzner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	WarmupStateDisabled = "disabled"
+	WarmupStatePending  = "pending"
+	WarmupStateRunning  = "running"
+	WarmupStateDone     = "done"
+	WarmupStateFailed   = "failed"
+)
+
+// WarmupStatus describes the outcome of the startup catalog pre-fetch.
+type WarmupStatus struct {
+	State      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Errors     []string
+}
+
+type warmupTracker struct {
+	mu     sync.RWMutex
+	status WarmupStatus
+}
+
+// DisableWarmup records that the startup pre-fetch was skipped on purpose.
+func (s *RegionService) DisableWarmup() {
+	s.warmup.mu.Lock()
+	s.warmup.status = WarmupStatus{State: WarmupStateDisabled}
+	s.warmup.mu.Unlock()
+}
+
+// Warmup pre-fetches locations, server types and system images so the first
+// requests do not pay for cold caches. Each step is bounded by budget and a
+// failure is only recorded: callers fetch lazily on first use either way.
+func (s *RegionService) Warmup(ctx context.Context, budget time.Duration) WarmupStatus {
+	s.warmup.mu.Lock()
+	s.warmup.status = WarmupStatus{State: WarmupStateRunning, StartedAt: time.Now().UTC()}
+	s.warmup.mu.Unlock()
+
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	var errs []string
+	if _, err := s.ListRegions(ctx); err != nil {
+		errs = append(errs, "locations: "+err.Error())
+	}
+	if _, err := s.listServerTypes(ctx); err != nil {
+		errs = append(errs, "server types: "+err.Error())
+	}
+	if _, err := s.ListCatalogImages(ctx); err != nil {
+		errs = append(errs, "images: "+err.Error())
+	}
+
+	s.warmup.mu.Lock()
+	defer s.warmup.mu.Unlock()
+	s.warmup.status.FinishedAt = time.Now().UTC()
+	s.warmup.status.Errors = errs
+	s.warmup.status.State = WarmupStateDone
+	if len(errs) > 0 {
+		s.warmup.status.State = WarmupStateFailed
+	}
+	return s.warmupStatusLocked()
+}
+
+// WarmupStatus returns a snapshot of the startup pre-fetch state.
+func (s *RegionService) WarmupStatus() WarmupStatus {
+	s.warmup.mu.RLock()
+	defer s.warmup.mu.RUnlock()
+	return s.warmupStatusLocked()
+}
+
+func (s *RegionService) warmupStatusLocked() WarmupStatus {
+	status := s.warmup.status
+	if status.State == "" {
+		status.State = WarmupStatePending
+	}
+	status.Errors = append([]string(nil), status.Errors...)
+	return status
+}
@@ -0,0 +1,215 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// bindingStatusOrphaned marks a binding whose provider resource was missing
+// on the last pass. A binding still orphaned on the next pass is deleted.
+const bindingStatusOrphaned = "orphaned"
+
+// orphanCheckedKinds are the binding kinds backed one to one by a Hetzner
+// resource the binding reconciler can look up.
+var orphanCheckedKinds = []string{"instance", "block-storage", "security-group", "network"}
+
+// BindingStore is the part of *state.Store the binding reconciler uses.
+type BindingStore interface {
+	ListResourceBindingsOfKinds(ctx context.Context, kinds []string) ([]state.ResourceBinding, error)
+	UpdateResourceBindingStatus(ctx context.Context, secaRef, status string) (bool, error)
+	DeleteResourceBindingIfStatus(ctx context.Context, secaRef, status string) (bool, error)
+	GetWorkspaceProviderCredential(ctx context.Context, tenant, workspace, provider string) (*state.WorkspaceProviderCredential, error)
+}
+
+// Inventory lists a project's Hetzner resources; *hetzner.RegionService
+// implements it.
+type Inventory interface {
+	ListInstances(ctx context.Context) ([]hetzner.Instance, error)
+	ListBlockStorages(ctx context.Context) ([]hetzner.BlockStorage, error)
+	ListSecurityGroups(ctx context.Context) ([]hetzner.SecurityGroup, error)
+	ListNetworks(ctx context.Context) ([]hetzner.Network, error)
+}
+
+// Bindings garbage-collects resource bindings whose Hetzner resource was
+// deleted out of band. A missing resource first marks its binding orphaned;
+// the binding is deleted when the resource is still missing one pass later,
+// and restored to active if the resource shows up again.
+type Bindings struct {
+	store     BindingStore
+	inventory Inventory
+	interval  time.Duration
+}
+
+// NewBindings builds the binding reconciler.
+func NewBindings(store BindingStore, inventory Inventory, interval time.Duration) *Bindings {
+	return &Bindings{store: store, inventory: inventory, interval: interval}
+}
+
+// Run reconciles every interval until ctx is done.
+func (b *Bindings) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Reconcile(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("binding reconcile failed: %v", err)
+			}
+		}
+	}
+}
+
+// Reconcile runs a single pass. Workspaces whose inventory cannot be read
+// right now are left alone, so a failing Hetzner API never orphans bindings.
+// So are workspaces without a credential: workspace resources never use the
+// global token, so the shared project says nothing about their bindings.
+func (b *Bindings) Reconcile(ctx context.Context) error {
+	bindings, err := b.store.ListResourceBindingsOfKinds(ctx, orphanCheckedKinds)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(bindings); {
+		end := start + 1
+		for end < len(bindings) && bindings[end].Tenant == bindings[start].Tenant && bindings[end].Workspace == bindings[start].Workspace {
+			end++
+		}
+		if err := b.reconcileWorkspace(ctx, bindings[start].Tenant, bindings[start].Workspace, bindings[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (b *Bindings) reconcileWorkspace(ctx context.Context, tenant, workspace string, bindings []state.ResourceBinding) error {
+	key := tenant + "/" + workspace
+	cred, err := b.store.GetWorkspaceProviderCredential(ctx, tenant, workspace, "hetzner")
+	if err != nil {
+		log.Printf("binding reconcile: credential lookup for %s failed: %v", key, err)
+		return nil
+	}
+	if cred == nil {
+		return nil
+	}
+	inventory, err := loadWorkspaceInventory(hetzner.WithWorkspaceCredential(ctx, hetzner.WorkspaceCredential{
+		Token:       cred.APIToken,
+		CloudAPIURL: cred.APIEndpoint,
+	}), b.inventory)
+	if err != nil {
+		log.Printf("binding reconcile: inventory of %s: %v", key, err)
+		return nil
+	}
+	for _, binding := range bindings {
+		exists := inventory.has(binding)
+		switch {
+		case exists && binding.Status == bindingStatusOrphaned:
+			if _, err := b.store.UpdateResourceBindingStatus(ctx, binding.SecaRef, "active"); err != nil {
+				return err
+			}
+		case !exists && binding.Status == bindingStatusOrphaned:
+			deleted, err := b.store.DeleteResourceBindingIfStatus(ctx, binding.SecaRef, bindingStatusOrphaned)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				log.Printf("binding reconcile: removed orphaned binding %s", binding.SecaRef)
+			}
+		case !exists:
+			if _, err := b.store.UpdateResourceBindingStatus(ctx, binding.SecaRef, bindingStatusOrphaned); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// workspaceInventory holds the names and IDs of a project's resources per
+// binding kind.
+type workspaceInventory struct {
+	names map[string]map[string]struct{}
+	ids   map[string]map[int64]struct{}
+}
+
+func loadWorkspaceInventory(ctx context.Context, inventory Inventory) (*workspaceInventory, error) {
+	out := &workspaceInventory{names: map[string]map[string]struct{}{}, ids: map[string]map[int64]struct{}{}}
+	instances, err := inventory.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		out.add("instance", instance.ID, instance.Name)
+	}
+	volumes, err := inventory.ListBlockStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range volumes {
+		out.add("block-storage", volume.ID, volume.Name)
+	}
+	groups, err := inventory.ListSecurityGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		out.add("security-group", group.ID, group.Name)
+	}
+	networks, err := inventory.ListNetworks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		out.add("network", network.ID, network.Name)
+	}
+	return out, nil
+}
+
+func (i *workspaceInventory) add(kind string, id int64, name string) {
+	if i.names[kind] == nil {
+		i.names[kind] = map[string]struct{}{}
+		i.ids[kind] = map[int64]struct{}{}
+	}
+	i.names[kind][strings.ToLower(name)] = struct{}{}
+	if id > 0 {
+		i.ids[kind][id] = struct{}{}
+	}
+}
+
+// has reports whether the binding's provider resource exists. Server, volume
+// and network bindings carry the Hetzner ID in their provider ref, security
+// groups in their payload; bindings written before IDs were recorded are
+// matched by name.
+func (i *workspaceInventory) has(binding state.ResourceBinding) bool {
+	if id := bindingProviderID(binding); id > 0 {
+		_, ok := i.ids[binding.Kind][id]
+		return ok
+	}
+	name := binding.SecaRef[strings.LastIndex(binding.SecaRef, "/")+1:]
+	_, ok := i.names[binding.Kind][strings.ToLower(name)]
+	return ok
+}
+
+func bindingProviderID(binding state.ResourceBinding) int64 {
+	if strings.HasPrefix(binding.ProviderRef, "{") {
+		var payload struct {
+			ProviderID int64 `json:"providerId"`
+		}
+		if err := json.Unmarshal([]byte(binding.ProviderRef), &payload); err != nil {
+			return 0
+		}
+		return payload.ProviderID
+	}
+	id, err := strconv.ParseInt(binding.ProviderRef[strings.LastIndex(binding.ProviderRef, "/")+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
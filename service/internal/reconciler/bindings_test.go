@@ -0,0 +1,89 @@
+package reconciler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type fakeBindingStore struct {
+	bindings    []state.ResourceBinding
+	credentials map[string]*state.WorkspaceProviderCredential
+	statuses    map[string]string
+	deleted     []string
+}
+
+func (f *fakeBindingStore) ListResourceBindingsOfKinds(context.Context, []string) ([]state.ResourceBinding, error) {
+	return f.bindings, nil
+}
+
+func (f *fakeBindingStore) UpdateResourceBindingStatus(_ context.Context, secaRef, status string) (bool, error) {
+	f.statuses[secaRef] = status
+	return true, nil
+}
+
+func (f *fakeBindingStore) DeleteResourceBindingIfStatus(_ context.Context, secaRef, _ string) (bool, error) {
+	f.deleted = append(f.deleted, secaRef)
+	return true, nil
+}
+
+func (f *fakeBindingStore) GetWorkspaceProviderCredential(_ context.Context, tenant, workspace, _ string) (*state.WorkspaceProviderCredential, error) {
+	return f.credentials[tenant+"/"+workspace], nil
+}
+
+type fakeInventory struct{}
+
+func (fakeInventory) ListInstances(context.Context) ([]hetzner.Instance, error) {
+	return []hetzner.Instance{{ID: 7, Name: "vm1"}}, nil
+}
+
+func (fakeInventory) ListBlockStorages(context.Context) ([]hetzner.BlockStorage, error) {
+	return nil, nil
+}
+
+func (fakeInventory) ListSecurityGroups(context.Context) ([]hetzner.SecurityGroup, error) {
+	return []hetzner.SecurityGroup{{ID: 40, Name: "legacy-fw"}}, nil
+}
+
+func (fakeInventory) ListNetworks(context.Context) ([]hetzner.Network, error) {
+	return []hetzner.Network{{ID: 50, Name: "renamed-net"}}, nil
+}
+
+func TestReconcileBindingsOrphansThenRemovesMissingResources(t *testing.T) {
+	t.Parallel()
+
+	prefix := "seca.compute/v1/tenants/t1/workspaces/ws1/"
+	store := &fakeBindingStore{
+		bindings: []state.ResourceBinding{
+			{Tenant: "t1", Workspace: "ws1", Kind: "instance", SecaRef: prefix + "instances/vm1", ProviderRef: "hetzner.cloud/servers/7", Status: "active"},
+			{Tenant: "t1", Workspace: "ws1", Kind: "instance", SecaRef: prefix + "instances/vm2", ProviderRef: "hetzner.cloud/servers/8", Status: "active"},
+			{Tenant: "t1", Workspace: "ws1", Kind: "block-storage", SecaRef: "seca.storage/v1/tenants/t1/workspaces/ws1/block-storages/data", ProviderRef: "hetzner.cloud/volumes/9", Status: bindingStatusOrphaned},
+			{Tenant: "t1", Workspace: "ws1", Kind: "security-group", SecaRef: "seca.network/v1/tenants/t1/workspaces/ws1/security-groups/web", ProviderRef: `{"name":"web","origin":"adopted","providerId":40}`, Status: bindingStatusOrphaned},
+			{Tenant: "t1", Workspace: "ws1", Kind: "network", SecaRef: "seca.network/v1/tenants/t1/workspaces/ws1/networks/net1", ProviderRef: "hetzner.cloud/networks/50", Status: "active"},
+			{Tenant: "t1", Workspace: "ws2", Kind: "instance", SecaRef: "seca.compute/v1/tenants/t1/workspaces/ws2/instances/vm3", ProviderRef: "hetzner.cloud/servers/10", Status: "active"},
+		},
+		credentials: map[string]*state.WorkspaceProviderCredential{"t1/ws1": {APIToken: "token"}},
+		statuses:    map[string]string{},
+	}
+
+	if err := NewBindings(store, fakeInventory{}, 0).Reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := map[string]string{
+		prefix + "instances/vm2":  bindingStatusOrphaned,
+		store.bindings[3].SecaRef: "active",
+	}
+	if len(store.statuses) != len(want) {
+		t.Fatalf("expected status updates %v, got %v", want, store.statuses)
+	}
+	for ref, status := range want {
+		if store.statuses[ref] != status {
+			t.Fatalf("%s: expected %q, got %q", ref, status, store.statuses[ref])
+		}
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != store.bindings[2].SecaRef {
+		t.Fatalf("expected only the orphaned volume binding to be deleted, got %v", store.deleted)
+	}
+}
@@ -0,0 +1,244 @@
+// Package reconciler runs background loops that bring stored state in line
+// with the provider.
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+// operationBatchSize bounds how many pending operations one pass checks.
+const operationBatchSize = 100
+
+// errorTrackedCollections are the collections whose bindings turn to
+// state.BindingStatusError when an operation on the resource fails, so their
+// GET reports the failure until the next PUT.
+var errorTrackedCollections = []string{"/instances/", "/block-storages/"}
+
+// OperationStore is the part of *state.Store the operation reconciler uses.
+type OperationStore interface {
+	ListPendingOperations(ctx context.Context, limit int32) ([]state.OperationRecord, error)
+	UpdateOperationPhase(ctx context.Context, operationID, phase, errorText string) error
+	UpdateOperationProviderAction(ctx context.Context, operationID, providerActionID, phase string) error
+	UpdateResourceBindingStatus(ctx context.Context, secaRef, status string) (bool, error)
+	PruneOperations(ctx context.Context, cutoff time.Time) (int64, error)
+	GetWorkspaceProviderCredential(ctx context.Context, tenant, workspace, provider string) (*state.WorkspaceProviderCredential, error)
+}
+
+// ActionProvider resolves Hetzner actions and sends the power-ons that were
+// deferred while a server was locked; *hetzner.RegionService implements it.
+type ActionProvider interface {
+	GetAction(ctx context.Context, id int64) (*hetzner.Action, error)
+	StartInstance(ctx context.Context, name string) (bool, string, error)
+}
+
+// Operations moves accepted operations to running, succeeded or failed by
+// polling the Hetzner action each one recorded, sends deferred power-ons, and
+// prunes finished operations once they are older than the retention window.
+// A failed action also marks the binding of its instance or block storage as
+// errored.
+type Operations struct {
+	store     OperationStore
+	actions   ActionProvider
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewOperations builds the reconciler. A retention of zero keeps finished
+// operations forever.
+func NewOperations(store OperationStore, actions ActionProvider, interval, retention time.Duration) *Operations {
+	return &Operations{
+		store:     store,
+		actions:   actions,
+		interval:  interval,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Run reconciles every interval until ctx is done.
+func (o *Operations) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.Reconcile(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("operation reconcile failed: %v", err)
+			}
+		}
+	}
+}
+
+// Reconcile runs a single pass. Operations whose workspace has no credential
+// or whose action cannot be read right now are left for the next pass.
+func (o *Operations) Reconcile(ctx context.Context) error {
+	pending, err := o.store.ListPendingOperations(ctx, operationBatchSize)
+	if err != nil {
+		return err
+	}
+	credentials := map[string]*state.WorkspaceProviderCredential{}
+	for _, operation := range pending {
+		if operation.ProviderActionID == hetzner.DeferredPowerOn {
+			if err := o.powerOn(ctx, operation, credentials); err != nil {
+				return err
+			}
+			continue
+		}
+		phase, errorText, ok := o.observe(ctx, operation, credentials)
+		if !ok {
+			continue
+		}
+		if err := o.store.UpdateOperationPhase(ctx, operation.OperationID, phase, errorText); err != nil {
+			return err
+		}
+		if phase == "failed" && tracksErrors(operation.SecaRef) {
+			if _, err := o.store.UpdateResourceBindingStatus(ctx, operation.SecaRef, state.BindingStatusError); err != nil {
+				return err
+			}
+		}
+	}
+	if o.retention > 0 {
+		if _, err := o.store.PruneOperations(ctx, o.now().Add(-o.retention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Operations) observe(ctx context.Context, operation state.OperationRecord, credentials map[string]*state.WorkspaceProviderCredential) (phase, errorText string, ok bool) {
+	actionID, err := strconv.ParseInt(operation.ProviderActionID, 10, 64)
+	if err != nil {
+		return "failed", "invalid provider action id " + strconv.Quote(operation.ProviderActionID), true
+	}
+	if _, _, found := workspaceFromRef(operation.SecaRef); !found {
+		return "failed", "operation does not reference a workspace", true
+	}
+	credCtx, ok := o.workspaceContext(ctx, operation, credentials)
+	if !ok {
+		return "", "", false
+	}
+	action, err := o.actions.GetAction(credCtx, actionID)
+	if err != nil {
+		log.Printf("operation reconcile: action %d of %s: %v", actionID, operation.OperationID, err)
+		return "", "", false
+	}
+	switch {
+	case action == nil:
+		return "failed", "provider action not found", true
+	case action.Status == hetzner.ActionStatusSuccess:
+		return "succeeded", "", true
+	case action.Status == hetzner.ActionStatusError:
+		return "failed", actionErrorText(action), true
+	default:
+		return "running", "", true
+	}
+}
+
+// powerOn sends a power-on that was deferred while the server was locked. It
+// stays deferred while the lock holds; once sent the operation follows the
+// power-on action like any other.
+func (o *Operations) powerOn(ctx context.Context, operation state.OperationRecord, credentials map[string]*state.WorkspaceProviderCredential) error {
+	_, _, found := workspaceFromRef(operation.SecaRef)
+	name := instanceFromRef(operation.SecaRef)
+	if !found || name == "" {
+		return o.store.UpdateOperationPhase(ctx, operation.OperationID, "failed", "operation does not reference an instance")
+	}
+	credCtx, ok := o.workspaceContext(ctx, operation, credentials)
+	if !ok {
+		return nil
+	}
+	started, actionID, err := o.actions.StartInstance(credCtx, name)
+	switch {
+	case err != nil:
+		if errors.Is(err, hetzner.ErrTimeout) {
+			log.Printf("operation reconcile: power on %s for %s: %v", name, operation.OperationID, err)
+			return nil
+		}
+		if _, rateLimited := hetzner.RetryAfter(err); rateLimited {
+			return nil
+		}
+		return o.store.UpdateOperationPhase(ctx, operation.OperationID, "failed", err.Error())
+	case !started:
+		return o.store.UpdateOperationPhase(ctx, operation.OperationID, "failed", "instance not found")
+	case actionID == hetzner.DeferredPowerOn:
+		return nil
+	case actionID == "":
+		return o.store.UpdateOperationPhase(ctx, operation.OperationID, "succeeded", "")
+	}
+	return o.store.UpdateOperationProviderAction(ctx, operation.OperationID, actionID, "running")
+}
+
+// workspaceContext returns ctx carrying the Hetzner credential of the
+// operation's workspace. It reports false while the workspace has none or
+// it cannot be read.
+func (o *Operations) workspaceContext(ctx context.Context, operation state.OperationRecord, credentials map[string]*state.WorkspaceProviderCredential) (context.Context, bool) {
+	tenant, workspace, _ := workspaceFromRef(operation.SecaRef)
+	key := tenant + "/" + workspace
+	cred, cached := credentials[key]
+	if !cached {
+		var err error
+		cred, err = o.store.GetWorkspaceProviderCredential(ctx, tenant, workspace, "hetzner")
+		if err != nil {
+			log.Printf("operation reconcile: credential lookup for %s failed: %v", key, err)
+			return nil, false
+		}
+		credentials[key] = cred
+	}
+	if cred == nil {
+		return nil, false
+	}
+	return hetzner.WithAuditScope(hetzner.WithWorkspaceCredential(ctx, hetzner.WorkspaceCredential{
+		Token:       cred.APIToken,
+		CloudAPIURL: cred.APIEndpoint,
+	}), tenant, workspace), true
+}
+
+// actionErrorText is the error of a failed action as recorded on its
+// operation: the Hetzner error code followed by the message.
+func actionErrorText(action *hetzner.Action) string {
+	if action.ErrorCode == "" {
+		return action.ErrorMessage
+	}
+	return action.ErrorCode + ": " + action.ErrorMessage
+}
+
+func tracksErrors(ref string) bool {
+	for _, collection := range errorTrackedCollections {
+		if strings.Contains(ref, collection) {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceFromRef extracts the instance name from a SECA instance reference.
+func instanceFromRef(ref string) string {
+	_, name, found := strings.Cut(ref, "/instances/")
+	if !found || strings.Contains(name, "/") {
+		return ""
+	}
+	return name
+}
+
+// workspaceFromRef extracts tenant and workspace from a SECA reference such
+// as seca.compute/v1/tenants/t/workspaces/w/instances/vm1.
+func workspaceFromRef(ref string) (tenant, workspace string, ok bool) {
+	segments := strings.Split(ref, "/")
+	for i := 0; i+3 < len(segments); i++ {
+		if segments[i] == "tenants" && segments[i+2] == "workspaces" {
+			return segments[i+1], segments[i+3], segments[i+1] != "" && segments[i+3] != ""
+		}
+	}
+	return "", "", false
+}
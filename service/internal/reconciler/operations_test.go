@@ -0,0 +1,177 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/provider/hetzner"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/state"
+)
+
+type phaseUpdate struct {
+	phase, errorText string
+}
+
+type actionUpdate struct {
+	providerActionID, phase string
+}
+
+type fakeOperationStore struct {
+	pending     []state.OperationRecord
+	credentials map[string]*state.WorkspaceProviderCredential
+	updates     map[string]phaseUpdate
+	actions     map[string]actionUpdate
+	bindings    map[string]string
+	prunedAt    time.Time
+}
+
+func (f *fakeOperationStore) ListPendingOperations(context.Context, int32) ([]state.OperationRecord, error) {
+	return f.pending, nil
+}
+
+func (f *fakeOperationStore) UpdateOperationPhase(_ context.Context, operationID, phase, errorText string) error {
+	f.updates[operationID] = phaseUpdate{phase: phase, errorText: errorText}
+	return nil
+}
+
+func (f *fakeOperationStore) UpdateOperationProviderAction(_ context.Context, operationID, providerActionID, phase string) error {
+	f.actions[operationID] = actionUpdate{providerActionID: providerActionID, phase: phase}
+	return nil
+}
+
+func (f *fakeOperationStore) UpdateResourceBindingStatus(_ context.Context, secaRef, status string) (bool, error) {
+	f.bindings[secaRef] = status
+	return true, nil
+}
+
+func (f *fakeOperationStore) PruneOperations(_ context.Context, cutoff time.Time) (int64, error) {
+	f.prunedAt = cutoff
+	return 0, nil
+}
+
+func (f *fakeOperationStore) GetWorkspaceProviderCredential(_ context.Context, tenant, workspace, _ string) (*state.WorkspaceProviderCredential, error) {
+	return f.credentials[tenant+"/"+workspace], nil
+}
+
+type fakeActions map[int64]*hetzner.Action
+
+func (f fakeActions) GetAction(_ context.Context, id int64) (*hetzner.Action, error) {
+	if id == 99 {
+		return nil, errors.New("rate limited")
+	}
+	return f[id], nil
+}
+
+// StartInstance powers on "vm" with action 42, finds "locked" still locked
+// and does not know any other instance.
+func (f fakeActions) StartInstance(_ context.Context, name string) (bool, string, error) {
+	switch name {
+	case "vm":
+		return true, "42", nil
+	case "locked":
+		return true, hetzner.DeferredPowerOn, nil
+	}
+	return false, "", nil
+}
+
+func TestReconcileUpdatesPhasesFromActions(t *testing.T) {
+	t.Parallel()
+
+	ref := func(name string) string { return "seca.compute/v1/tenants/t1/workspaces/ws1/instances/" + name }
+	store := &fakeOperationStore{
+		pending: []state.OperationRecord{
+			{OperationID: "done", SecaRef: ref("a"), ProviderActionID: "1", Phase: "accepted"},
+			{OperationID: "broken", SecaRef: ref("b"), ProviderActionID: "2", Phase: "accepted"},
+			{OperationID: "busy", SecaRef: ref("c"), ProviderActionID: "3", Phase: "accepted"},
+			{OperationID: "gone", SecaRef: ref("d"), ProviderActionID: "4", Phase: "running"},
+			{OperationID: "flaky", SecaRef: ref("e"), ProviderActionID: "99", Phase: "accepted"},
+			{OperationID: "unbound", SecaRef: "seca.compute/v1/tenants/t1/workspaces/ws2/instances/f", ProviderActionID: "1", Phase: "accepted"},
+		},
+		credentials: map[string]*state.WorkspaceProviderCredential{"t1/ws1": {APIToken: "token"}},
+		updates:     map[string]phaseUpdate{},
+		bindings:    map[string]string{},
+	}
+	actions := fakeActions{
+		1: {ID: 1, Status: hetzner.ActionStatusSuccess},
+		2: {ID: 2, Status: hetzner.ActionStatusError, ErrorCode: "locked", ErrorMessage: "server locked"},
+		3: {ID: 3, Status: hetzner.ActionStatusRunning},
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	reconciler := NewOperations(store, actions, time.Second, time.Hour)
+	reconciler.now = func() time.Time { return now }
+
+	if err := reconciler.Reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := map[string]phaseUpdate{
+		"done":   {phase: "succeeded"},
+		"broken": {phase: "failed", errorText: "locked: server locked"},
+		"busy":   {phase: "running"},
+		"gone":   {phase: "failed", errorText: "provider action not found"},
+	}
+	if len(store.updates) != len(want) {
+		t.Fatalf("expected updates %v, got %v", want, store.updates)
+	}
+	for id, update := range want {
+		if store.updates[id] != update {
+			t.Fatalf("%s: expected %+v, got %+v", id, update, store.updates[id])
+		}
+	}
+	wantBindings := map[string]string{ref("b"): state.BindingStatusError, ref("d"): state.BindingStatusError}
+	if len(store.bindings) != len(wantBindings) {
+		t.Fatalf("expected bindings %v, got %v", wantBindings, store.bindings)
+	}
+	for secaRef, status := range wantBindings {
+		if store.bindings[secaRef] != status {
+			t.Fatalf("%s: expected binding status %q, got %q", secaRef, status, store.bindings[secaRef])
+		}
+	}
+	if !store.prunedAt.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("expected prune before %s, got %s", now.Add(-time.Hour), store.prunedAt)
+	}
+}
+
+func TestReconcileSendsDeferredPowerOns(t *testing.T) {
+	t.Parallel()
+
+	ref := func(name string) string { return "seca.compute/v1/tenants/t1/workspaces/ws1/instances/" + name }
+	store := &fakeOperationStore{
+		pending: []state.OperationRecord{
+			{OperationID: "start", SecaRef: ref("vm"), ProviderActionID: hetzner.DeferredPowerOn, Phase: "accepted"},
+			{OperationID: "wait", SecaRef: ref("locked"), ProviderActionID: hetzner.DeferredPowerOn, Phase: "accepted"},
+			{OperationID: "gone", SecaRef: ref("deleted"), ProviderActionID: hetzner.DeferredPowerOn, Phase: "accepted"},
+		},
+		credentials: map[string]*state.WorkspaceProviderCredential{"t1/ws1": {APIToken: "token"}},
+		updates:     map[string]phaseUpdate{},
+		actions:     map[string]actionUpdate{},
+	}
+	if err := NewOperations(store, fakeActions{}, time.Second, 0).Reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := store.actions["start"]; got != (actionUpdate{providerActionID: "42", phase: "running"}) {
+		t.Fatalf("expected the power-on action to be recorded, got %+v", got)
+	}
+	if _, ok := store.actions["wait"]; ok {
+		t.Fatal("expected a still locked server to stay deferred")
+	}
+	if _, ok := store.updates["wait"]; ok {
+		t.Fatal("expected a still locked server to stay deferred")
+	}
+	if got := store.updates["gone"]; got != (phaseUpdate{phase: "failed", errorText: "instance not found"}) {
+		t.Fatalf("expected a missing instance to fail the operation, got %+v", got)
+	}
+}
+
+func TestWorkspaceFromRef(t *testing.T) {
+	t.Parallel()
+
+	tenant, workspace, ok := workspaceFromRef("seca.storage/v1/tenants/t1/workspaces/ws1/block-storages/data")
+	if !ok || tenant != "t1" || workspace != "ws1" {
+		t.Fatalf("expected t1/ws1, got %q/%q (%v)", tenant, workspace, ok)
+	}
+	if _, _, ok := workspaceFromRef("seca.compute/v1/tenants/t1/skus/cx22"); ok {
+		t.Fatal("expected no workspace for a tenant-scoped ref")
+	}
+}
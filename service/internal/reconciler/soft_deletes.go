@@ -0,0 +1,56 @@
+package reconciler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SoftDeleteStore is the part of *state.Store the soft-delete purge uses.
+type SoftDeleteStore interface {
+	PurgeSoftDeleted(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// SoftDeletes hard-deletes soft-deleted workspaces, roles, role assignments
+// and workspace credentials once they are older than the retention window,
+// after which they can no longer be restored.
+type SoftDeletes struct {
+	store     SoftDeleteStore
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewSoftDeletes builds the purge. It needs a positive retention; without
+// one soft-deleted rows are kept forever and there is nothing to run.
+func NewSoftDeletes(store SoftDeleteStore, interval, retention time.Duration) *SoftDeletes {
+	return &SoftDeletes{store: store, interval: interval, retention: retention, now: time.Now}
+}
+
+// Run purges every interval until ctx is done.
+func (p *SoftDeletes) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Reconcile(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("soft delete purge failed: %v", err)
+			}
+		}
+	}
+}
+
+// Reconcile runs a single purge.
+func (p *SoftDeletes) Reconcile(ctx context.Context) error {
+	purged, err := p.store.PurgeSoftDeleted(ctx, p.now().Add(-p.retention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("purged %d soft-deleted rows past the %s retention", purged, p.retention)
+	}
+	return nil
+}
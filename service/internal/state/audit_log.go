@@ -0,0 +1,81 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AuditEntry is one mutating call the proxy sent to the provider.
+type AuditEntry struct {
+	ID         int64
+	Time       time.Time
+	Tenant     string
+	Workspace  string
+	Kind       string
+	Operation  string
+	Resource   string
+	ActionID   string
+	StatusCode int
+	Error      string
+}
+
+// AuditFilter narrows ListAuditEntries. Empty fields match everything;
+// BeforeID continues a listing below the last ID already returned.
+type AuditFilter struct {
+	Tenant   string
+	Kind     string
+	Since    time.Time
+	BeforeID int64
+	Limit    int
+}
+
+func (s *Store) AppendAuditEntry(ctx context.Context, entry AuditEntry) error {
+	if err := s.queries.CreateAuditLogEntry(ctx, dbsqlc.CreateAuditLogEntryParams{
+		Tenant:     entry.Tenant,
+		Workspace:  entry.Workspace,
+		Kind:       entry.Kind,
+		Operation:  entry.Operation,
+		Resource:   entry.Resource,
+		ActionID:   entry.ActionID,
+		StatusCode: int32(entry.StatusCode),
+		ErrorText:  entry.Error,
+		CreatedAt:  pgtype.Timestamptz{Time: entry.Time, Valid: true},
+	}); err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns matching entries, newest first.
+func (s *Store) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	rows, err := s.queries.ListAuditLogEntries(ctx, dbsqlc.ListAuditLogEntriesParams{
+		Tenant:   filter.Tenant,
+		Kind:     filter.Kind,
+		Since:    pgtype.Timestamptz{Time: filter.Since, Valid: true},
+		BeforeID: filter.BeforeID,
+		RowLimit: int32(filter.Limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, AuditEntry{
+			ID:         row.ID,
+			Time:       row.CreatedAt.Time.UTC(),
+			Tenant:     row.Tenant,
+			Workspace:  row.Workspace,
+			Kind:       row.Kind,
+			Operation:  row.Operation,
+			Resource:   row.Resource,
+			ActionID:   row.ActionID,
+			StatusCode: int(row.StatusCode),
+			Error:      row.ErrorText,
+		})
+	}
+	return entries, nil
+}
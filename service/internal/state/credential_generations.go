@@ -0,0 +1,60 @@
+package state
+
+import (
+	"strings"
+	"sync"
+)
+
+// CredentialGenerations tracks a per workspace provider binding counter that
+// moves every time the credential is written or removed. Holders of a
+// decrypted token compare generations, or wait on the returned channel, to
+// learn that their copy is stale. The counter lives in process memory; other
+// replicas converge when they reload the credential from the database.
+type CredentialGenerations struct {
+	mu      sync.Mutex
+	entries map[string]*credentialGeneration
+}
+
+type credentialGeneration struct {
+	value   uint64
+	changed chan struct{}
+}
+
+func NewCredentialGenerations() *CredentialGenerations {
+	return &CredentialGenerations{entries: map[string]*credentialGeneration{}}
+}
+
+// Current returns the generation for the binding and a channel that is closed
+// once the generation moves past it.
+func (g *CredentialGenerations) Current(tenant, workspace, provider string) (uint64, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	entry := g.entryLocked(credentialGenerationKey(tenant, workspace, provider))
+	return entry.value, entry.changed
+}
+
+// Bump invalidates every holder of the current generation.
+func (g *CredentialGenerations) Bump(tenant, workspace, provider string) uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	entry := g.entryLocked(credentialGenerationKey(tenant, workspace, provider))
+	close(entry.changed)
+	entry.value++
+	entry.changed = make(chan struct{})
+	return entry.value
+}
+
+func (g *CredentialGenerations) entryLocked(key string) *credentialGeneration {
+	entry, ok := g.entries[key]
+	if !ok {
+		entry = &credentialGeneration{changed: make(chan struct{})}
+		g.entries[key] = entry
+	}
+	return entry
+}
+
+func credentialGenerationKey(tenant, workspace, provider string) string {
+	return strings.ToLower(strings.TrimSpace(tenant)) + "/" +
+		strings.ToLower(strings.TrimSpace(workspace)) + "/" +
+		strings.ToLower(strings.TrimSpace(provider))
+}
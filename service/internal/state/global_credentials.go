@@ -0,0 +1,120 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+// GlobalProviderCredential is the provider token the proxy uses outside any
+// workspace, stored encrypted like the workspace credentials.
+type GlobalProviderCredential struct {
+	Provider  string
+	APIToken  string
+	CreatedAt time.Time
+	RotatedAt time.Time
+}
+
+// UpsertGlobalProviderCredential stores the global token of provider,
+// replacing the one stored before.
+func (s *Store) UpsertGlobalProviderCredential(ctx context.Context, provider, token string) (*GlobalProviderCredential, error) {
+	encryptedToken, err := s.tokenCodec.Encrypt(token)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt global provider credential token: %w", err)
+	}
+	row, err := s.queries.UpsertGlobalProviderCredential(ctx, dbsqlc.UpsertGlobalProviderCredentialParams{
+		Provider:          provider,
+		ApiTokenEncrypted: encryptedToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upsert global provider credential: %w", err)
+	}
+	out := globalProviderCredentialMetadata(row)
+	out.APIToken = token
+	return &out, nil
+}
+
+// GetGlobalProviderCredential returns the stored global token of provider,
+// or nil when none is stored.
+func (s *Store) GetGlobalProviderCredential(ctx context.Context, provider string) (*GlobalProviderCredential, error) {
+	row, err := s.queries.GetGlobalProviderCredential(ctx, provider)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get global provider credential: %w", err)
+	}
+	out := globalProviderCredentialMetadata(row)
+	token, err := s.tokenCodec.Decrypt(row.ApiTokenEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt global provider credential token: %w", err)
+	}
+	out.APIToken = token
+	return &out, nil
+}
+
+func (s *Store) DeleteGlobalProviderCredential(ctx context.Context, provider string) (bool, error) {
+	count, err := s.queries.DeleteGlobalProviderCredential(ctx, provider)
+	if err != nil {
+		return false, fmt.Errorf("delete global provider credential: %w", err)
+	}
+	return count > 0, nil
+}
+
+// reencryptGlobalProviderCredentials is the global credential part of
+// ReencryptWorkspaceProviderCredentials.
+func (s *Store) reencryptGlobalProviderCredentials(ctx context.Context, result *CredentialRotationResult) error {
+	rows, err := s.queries.ListGlobalProviderCredentials(ctx)
+	if err != nil {
+		return fmt.Errorf("list global provider credentials: %w", err)
+	}
+	for _, row := range rows {
+		if s.tokenCodec.Current(row.ApiTokenEncrypted) {
+			result.Unchanged++
+			continue
+		}
+		fail := func(err error) {
+			result.Failures = append(result.Failures, CredentialRotationFailure{Provider: row.Provider, Error: err.Error()})
+		}
+		token, err := s.tokenCodec.Decrypt(row.ApiTokenEncrypted)
+		if err != nil {
+			fail(fmt.Errorf("decrypt: %w", err))
+			continue
+		}
+		encryptedToken, err := s.tokenCodec.Encrypt(token)
+		if err != nil {
+			fail(fmt.Errorf("encrypt: %w", err))
+			continue
+		}
+		count, err := s.queries.ReencryptGlobalProviderCredentialToken(ctx, dbsqlc.ReencryptGlobalProviderCredentialTokenParams{
+			NewToken: encryptedToken,
+			Provider: row.Provider,
+			OldToken: row.ApiTokenEncrypted,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("re-encrypt global provider credential: %w", err)
+			}
+			fail(err)
+			continue
+		}
+		if count == 0 {
+			fail(errors.New("credential changed during rotation"))
+			continue
+		}
+		result.Rotated++
+	}
+	return nil
+}
+
+func globalProviderCredentialMetadata(row dbsqlc.GlobalProviderCredential) GlobalProviderCredential {
+	return GlobalProviderCredential{
+		Provider:  row.Provider,
+		CreatedAt: row.CreatedAt.Time.UTC(),
+		RotatedAt: row.RotatedAt.Time.UTC(),
+	}
+}
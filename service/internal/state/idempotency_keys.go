@@ -0,0 +1,98 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// IdempotencyRecord is the request an Idempotency-Key was first used for and,
+// once that request finished, the response it got. StatusCode is 0 while the
+// first request is still running.
+type IdempotencyRecord struct {
+	Tenant      string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Header      map[string][]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// ClaimIdempotencyKey records key for a new request and reports true. When
+// the key is already taken it returns the existing record and false. Expired
+// keys of the tenant are dropped first, so a key can be reused after its TTL.
+func (s *Store) ClaimIdempotencyKey(ctx context.Context, tenant, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	if _, err := s.queries.DeleteExpiredIdempotencyKeys(ctx, tenant); err != nil {
+		return nil, false, fmt.Errorf("prune idempotency keys: %w", err)
+	}
+	row, err := s.queries.ClaimIdempotencyKey(ctx, dbsqlc.ClaimIdempotencyKeyParams{
+		Tenant:         tenant,
+		IdempotencyKey: key,
+		RequestHash:    requestHash,
+		ExpiresAt:      pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	})
+	if err == nil {
+		record, err := idempotencyRecordFromRow(row)
+		return record, true, err
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	row, err = s.queries.GetIdempotencyKey(ctx, dbsqlc.GetIdempotencyKeyParams{Tenant: tenant, IdempotencyKey: key})
+	if err != nil {
+		return nil, false, fmt.Errorf("get idempotency key: %w", err)
+	}
+	record, err := idempotencyRecordFromRow(row)
+	return record, false, err
+}
+
+// CompleteIdempotencyKey stores the response of the request that claimed key.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, tenant, key string, statusCode int, header map[string][]string, body []byte) error {
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("encode idempotency response header: %w", err)
+	}
+	if _, err := s.queries.CompleteIdempotencyKey(ctx, dbsqlc.CompleteIdempotencyKeyParams{
+		Tenant:         tenant,
+		IdempotencyKey: key,
+		StatusCode:     int32(statusCode),
+		ResponseHeader: rawHeader,
+		ResponseBody:   body,
+	}); err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key, so a retry with it runs the request
+// again.
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, tenant, key string) error {
+	if _, err := s.queries.DeleteIdempotencyKey(ctx, dbsqlc.DeleteIdempotencyKeyParams{Tenant: tenant, IdempotencyKey: key}); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func idempotencyRecordFromRow(row dbsqlc.IdempotencyKey) (*IdempotencyRecord, error) {
+	record := &IdempotencyRecord{
+		Tenant:      row.Tenant,
+		Key:         row.IdempotencyKey,
+		RequestHash: row.RequestHash,
+		StatusCode:  int(row.StatusCode),
+		Body:        row.ResponseBody,
+		ExpiresAt:   row.ExpiresAt.Time.UTC(),
+	}
+	if len(row.ResponseHeader) > 0 {
+		if err := json.Unmarshal(row.ResponseHeader, &record.Header); err != nil {
+			return nil, fmt.Errorf("decode idempotency response header: %w", err)
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"context"
+	"strings"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryFaultFunc is consulted before every query with the sqlc query name,
+// e.g. "GetResourceBinding"; a non-nil error is returned instead of running
+// the query.
+type QueryFaultFunc func(ctx context.Context, query string) error
+
+// InjectQueryFaults routes every store query through fault. It is meant for
+// chaos testing and must be called before the store serves requests.
+func (s *Store) InjectQueryFaults(fault QueryFaultFunc) {
+	s.db = faultingDBTX{base: s.db, fault: fault}
+	s.queries = dbsqlc.New(s.db)
+}
+
+type faultingDBTX struct {
+	base  dbsqlc.DBTX
+	fault QueryFaultFunc
+}
+
+func (d faultingDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := d.fault(ctx, queryName(sql)); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return d.base.Exec(ctx, sql, args...)
+}
+
+func (d faultingDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := d.fault(ctx, queryName(sql)); err != nil {
+		return nil, err
+	}
+	return d.base.Query(ctx, sql, args...)
+}
+
+func (d faultingDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := d.fault(ctx, queryName(sql)); err != nil {
+		return faultRow{err: err}
+	}
+	return d.base.QueryRow(ctx, sql, args...)
+}
+
+func (d faultingDBTX) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	if len(batch.QueuedQueries) > 0 {
+		if err := d.fault(ctx, queryName(batch.QueuedQueries[0].SQL)); err != nil {
+			return faultBatchResults{err: err}
+		}
+	}
+	return d.base.SendBatch(ctx, batch)
+}
+
+// queryName extracts NAME from the "-- name: NAME :kind" header sqlc puts on
+// every generated query.
+func queryName(sql string) string {
+	header, _, _ := strings.Cut(sql, "\n")
+	rest, ok := strings.CutPrefix(strings.TrimSpace(header), "-- name:")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+type faultRow struct {
+	err error
+}
+
+func (r faultRow) Scan(...any) error {
+	return r.err
+}
+
+type faultBatchResults struct {
+	err error
+}
+
+func (b faultBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, b.err
+}
+
+func (b faultBatchResults) Query() (pgx.Rows, error) {
+	return nil, b.err
+}
+
+func (b faultBatchResults) QueryRow() pgx.Row {
+	return faultRow{err: b.err}
+}
+
+func (b faultBatchResults) Close() error {
+	return b.err
+}
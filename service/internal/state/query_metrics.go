@@ -0,0 +1,106 @@
+package state
+
+import (
+	"context"
+	"errors"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ObserveQueryErrors reports the sqlc name of every query that fails. A
+// missing row is an expected outcome for lookups and is not reported. Like
+// InjectQueryFaults it must be called before the store serves requests.
+func (s *Store) ObserveQueryErrors(observe func(query string)) {
+	s.db = observedDBTX{base: s.db, observe: observe}
+	s.queries = dbsqlc.New(s.db)
+}
+
+type observedDBTX struct {
+	base    dbsqlc.DBTX
+	observe func(query string)
+}
+
+func (d observedDBTX) report(sql string, err error) error {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		d.observe(queryName(sql))
+	}
+	return err
+}
+
+func (d observedDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := d.base.Exec(ctx, sql, args...)
+	return tag, d.report(sql, err)
+}
+
+func (d observedDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := d.base.Query(ctx, sql, args...)
+	if err != nil {
+		return rows, d.report(sql, err)
+	}
+	return &observedRows{Rows: rows, report: func(err error) error { return d.report(sql, err) }}, nil
+}
+
+func (d observedDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return observedRow{row: d.base.QueryRow(ctx, sql, args...), report: func(err error) error { return d.report(sql, err) }}
+}
+
+func (d observedDBTX) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	sql := ""
+	if len(batch.QueuedQueries) > 0 {
+		sql = batch.QueuedQueries[0].SQL
+	}
+	return observedBatchResults{BatchResults: d.base.SendBatch(ctx, batch), report: func(err error) error { return d.report(sql, err) }}
+}
+
+type observedRow struct {
+	row    pgx.Row
+	report func(error) error
+}
+
+func (r observedRow) Scan(dest ...any) error {
+	return r.report(r.row.Scan(dest...))
+}
+
+// observedRows reports a failure surfacing from Scan or Err once per query.
+type observedRows struct {
+	pgx.Rows
+	report   func(error) error
+	reported bool
+}
+
+func (r *observedRows) Scan(dest ...any) error {
+	return r.once(r.Rows.Scan(dest...))
+}
+
+func (r *observedRows) Err() error {
+	return r.once(r.Rows.Err())
+}
+
+func (r *observedRows) once(err error) error {
+	if err == nil || r.reported {
+		return err
+	}
+	r.reported = true
+	return r.report(err)
+}
+
+type observedBatchResults struct {
+	pgx.BatchResults
+	report func(error) error
+}
+
+func (b observedBatchResults) Exec() (pgconn.CommandTag, error) {
+	tag, err := b.BatchResults.Exec()
+	return tag, b.report(err)
+}
+
+func (b observedBatchResults) Query() (pgx.Rows, error) {
+	rows, err := b.BatchResults.Query()
+	return rows, b.report(err)
+}
+
+func (b observedBatchResults) QueryRow() pgx.Row {
+	return observedRow{row: b.BatchResults.QueryRow(), report: b.report}
+}
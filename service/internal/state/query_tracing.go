@@ -0,0 +1,118 @@
+package state
+
+import (
+	"context"
+	"errors"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceQueries wraps every store query in a span named after its sqlc query,
+// so a trace shows how long a request spent in Postgres. Like
+// ObserveQueryErrors it must be called before the store serves requests.
+func (s *Store) TraceQueries() {
+	s.db = tracedDBTX{base: s.db}
+	s.queries = dbsqlc.New(s.db)
+}
+
+type tracedDBTX struct {
+	base dbsqlc.DBTX
+}
+
+func startQuerySpan(ctx context.Context, sql string) (context.Context, trace.Span) {
+	name := queryName(sql)
+	if name == "" {
+		name = "query"
+	}
+	return tracing.Tracer().Start(ctx, "store "+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", name),
+	))
+}
+
+// endQuerySpan ends span with err. A missing row is an expected outcome for
+// lookups and does not mark the span as failed.
+func endQuerySpan(span trace.Span, err error) error {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+func (d tracedDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startQuerySpan(ctx, sql)
+	tag, err := d.base.Exec(ctx, sql, args...)
+	return tag, endQuerySpan(span, err)
+}
+
+func (d tracedDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startQuerySpan(ctx, sql)
+	rows, err := d.base.Query(ctx, sql, args...)
+	if err != nil {
+		return rows, endQuerySpan(span, err)
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (d tracedDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startQuerySpan(ctx, sql)
+	return tracedRow{row: d.base.QueryRow(ctx, sql, args...), span: span}
+}
+
+func (d tracedDBTX) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	sql := ""
+	if len(batch.QueuedQueries) > 0 {
+		sql = batch.QueuedQueries[0].SQL
+	}
+	ctx, span := startQuerySpan(ctx, sql)
+	span.SetAttributes(attribute.Int("db.operation.batch.size", len(batch.QueuedQueries)))
+	return &tracedBatchResults{BatchResults: d.base.SendBatch(ctx, batch), span: span}
+}
+
+type tracedRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+func (r tracedRow) Scan(dest ...any) error {
+	return endQuerySpan(r.span, r.row.Scan(dest...))
+}
+
+// tracedRows ends the span once the rows are closed, which sqlc does after
+// reading the last row.
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	if !r.ended {
+		r.ended = true
+		_ = endQuerySpan(r.span, r.Rows.Err())
+	}
+}
+
+type tracedBatchResults struct {
+	pgx.BatchResults
+	span  trace.Span
+	ended bool
+}
+
+func (b *tracedBatchResults) Close() error {
+	err := b.BatchResults.Close()
+	if !b.ended {
+		b.ended = true
+		_ = endQuerySpan(b.span, err)
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Deletion tells a live row from a soft-deleted one and from one that was
+// never created or has been purged.
+type Deletion struct {
+	Found bool
+	// DeletedAt is zero while the row is live.
+	DeletedAt time.Time
+}
+
+// Deleted reports whether the row exists and is soft-deleted.
+func (d Deletion) Deleted() bool {
+	return d.Found && !d.DeletedAt.IsZero()
+}
+
+func deletionFromQuery(deletedAt pgtype.Timestamptz, err error, what string) (Deletion, error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Deletion{}, nil
+	}
+	if err != nil {
+		return Deletion{}, fmt.Errorf("get %s deletion: %w", what, err)
+	}
+	if !deletedAt.Valid {
+		return Deletion{Found: true}, nil
+	}
+	return Deletion{Found: true, DeletedAt: deletedAt.Time}, nil
+}
+
+func (s *Store) WorkspaceDeletion(ctx context.Context, tenant, name string) (Deletion, error) {
+	deletedAt, err := s.queries.GetWorkspaceDeletedAt(ctx, dbsqlc.GetWorkspaceDeletedAtParams{Tenant: tenant, Name: name})
+	return deletionFromQuery(deletedAt, err, "workspace")
+}
+
+func (s *Store) RoleDeletion(ctx context.Context, tenant, name string) (Deletion, error) {
+	deletedAt, err := s.queries.GetAuthRoleDeletedAt(ctx, dbsqlc.GetAuthRoleDeletedAtParams{Tenant: tenant, Name: name})
+	return deletionFromQuery(deletedAt, err, "role")
+}
+
+func (s *Store) RoleAssignmentDeletion(ctx context.Context, tenant, name string) (Deletion, error) {
+	deletedAt, err := s.queries.GetAuthRoleAssignmentDeletedAt(ctx, dbsqlc.GetAuthRoleAssignmentDeletedAtParams{Tenant: tenant, Name: name})
+	return deletionFromQuery(deletedAt, err, "role assignment")
+}
+
+// RestoreWorkspace clears the deletion marker of a workspace soft-deleted at
+// or after deletedAfter. It returns nil when no such workspace exists.
+func (s *Store) RestoreWorkspace(ctx context.Context, tenant, name string, deletedAfter time.Time) (*WorkspaceResource, error) {
+	row, err := s.queries.RestoreWorkspace(ctx, dbsqlc.RestoreWorkspaceParams{
+		Tenant:       tenant,
+		Name:         name,
+		DeletedAfter: pgtype.Timestamptz{Time: deletedAfter, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("restore workspace: %w", err)
+	}
+	resource, err := workspaceResourceFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// RestoreRole is RestoreWorkspace for roles.
+func (s *Store) RestoreRole(ctx context.Context, tenant, name string, deletedAfter time.Time) (*AuthResource, error) {
+	row, err := s.queries.RestoreAuthRole(ctx, dbsqlc.RestoreAuthRoleParams{
+		Tenant:       tenant,
+		Name:         name,
+		DeletedAfter: pgtype.Timestamptz{Time: deletedAfter, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("restore role: %w", err)
+	}
+	resource, err := authResourceFromRoleRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// RestoreRoleAssignment is RestoreWorkspace for role assignments.
+func (s *Store) RestoreRoleAssignment(ctx context.Context, tenant, name string, deletedAfter time.Time) (*AuthResource, error) {
+	row, err := s.queries.RestoreAuthRoleAssignment(ctx, dbsqlc.RestoreAuthRoleAssignmentParams{
+		Tenant:       tenant,
+		Name:         name,
+		DeletedAfter: pgtype.Timestamptz{Time: deletedAfter, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("restore role assignment: %w", err)
+	}
+	resource, err := authResourceFromRoleAssignmentRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// PurgeSoftDeleted hard-deletes the workspaces, roles, role assignments and
+// workspace credentials soft-deleted before cutoff and returns how many rows
+// were removed.
+func (s *Store) PurgeSoftDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	before := pgtype.Timestamptz{Time: cutoff, Valid: true}
+	var total int64
+	for _, purge := range []struct {
+		what string
+		run  func(context.Context, pgtype.Timestamptz) (int64, error)
+	}{
+		{"workspaces", s.queries.PurgeDeletedWorkspaces},
+		{"roles", s.queries.PurgeDeletedAuthRoles},
+		{"role assignments", s.queries.PurgeDeletedAuthRoleAssignments},
+		{"workspace credentials", s.queries.PurgeDeletedWorkspaceProviderCredentials},
+	} {
+		deleted, err := purge.run(ctx, before)
+		if err != nil {
+			return total, fmt.Errorf("purge deleted %s: %w", purge.what, err)
+		}
+		total += deleted
+	}
+	return total, nil
+}
@@ -13,29 +13,56 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrOperationExists reports an operation ID that is already recorded.
+var ErrOperationExists = errors.New("operation already exists")
+
+// ErrVersionConflict reports a conditional write whose expected resource
+// version no longer matches the stored one.
+var ErrVersionConflict = errors.New("resource version conflict")
+
+// BindingStatusError marks a binding whose last provider action failed. The
+// syncs done by reads keep it; only UpsertResourceBinding, as done by a PUT,
+// replaces it.
+const BindingStatusError = "error"
+
 type Store struct {
-	pool       *pgxpool.Pool
-	queries    *dbsqlc.Queries
-	tokenCodec *tokenCodec
+	pool           *pgxpool.Pool
+	db             dbsqlc.DBTX
+	queries        *dbsqlc.Queries
+	tokenCodec     *tokenCodec
+	credentialGens *CredentialGenerations
 }
 
 type ResourceBinding struct {
-	Tenant      string
-	Workspace   string
-	Kind        string
-	SecaRef     string
-	ProviderRef string
-	Status      string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Tenant          string
+	Workspace       string
+	Kind            string
+	SecaRef         string
+	ProviderRef     string
+	Status          string
+	ResourceVersion int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 type OperationRecord struct {
+	ID               int64
 	OperationID      string
 	SecaRef          string
 	ProviderActionID string
 	Phase            string
 	ErrorText        string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// OperationFilter narrows ListOperationsBySecaRef. An empty Phase matches
+// every phase; BeforeID continues a listing below the last ID already
+// returned.
+type OperationFilter struct {
+	Phase    string
+	BeforeID int64
+	Limit    int
 }
 
 type AuthResource struct {
@@ -45,6 +72,8 @@ type AuthResource struct {
 	Spec            map[string]any
 	Status          map[string]any
 	ResourceVersion int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 type WorkspaceResource struct {
@@ -59,6 +88,31 @@ type WorkspaceResource struct {
 	UpdatedAt       time.Time
 }
 
+// TenantEntitlements lists the SECA providers a tenant may use. Tenants
+// without a stored record are entitled to every provider.
+type TenantEntitlements struct {
+	Tenant    string
+	Providers []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Tenant is an entry of the admin-managed tenant registry.
+type Tenant struct {
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TenantAPIToken is a named bearer token for the public API. Only a hash of
+// the token is stored.
+type TenantAPIToken struct {
+	Tenant    string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type WorkspaceProviderCredential struct {
 	Tenant      string
 	Workspace   string
@@ -66,10 +120,16 @@ type WorkspaceProviderCredential struct {
 	ProjectRef  string
 	APIEndpoint string
 	APIToken    string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
-func New(ctx context.Context, databaseURL, credentialsKey string) (*Store, error) {
-	pool, err := pgxpool.New(ctx, databaseURL)
+func New(ctx context.Context, databaseURL, credentialsKey string, poolCfg PoolConfig) (*Store, error) {
+	config, err := newPoolConfig(databaseURL, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("parse database url: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("create pool: %w", err)
 	}
@@ -82,7 +142,8 @@ func New(ctx context.Context, databaseURL, credentialsKey string) (*Store, error
 		pool.Close()
 		return nil, fmt.Errorf("init token codec: %w", err)
 	}
-	return &Store{pool: pool, queries: dbsqlc.New(pool), tokenCodec: codec}, nil
+	db := storeDBTX{base: pooledDBTX{pool: pool, acquireTimeout: poolCfg.AcquireTimeout, statementTimeout: poolCfg.StatementTimeout}}
+	return &Store{pool: pool, db: db, queries: dbsqlc.New(db), tokenCodec: codec, credentialGens: NewCredentialGenerations()}, nil
 }
 
 func (s *Store) Ping(ctx context.Context) error {
@@ -94,7 +155,39 @@ func (s *Store) Close() {
 }
 
 func (s *Store) UpsertResourceBinding(ctx context.Context, binding ResourceBinding) error {
-	_, err := s.queries.UpsertResourceBinding(ctx, dbsqlc.UpsertResourceBindingParams{
+	_, err := s.UpsertResourceBindingIfVersion(ctx, binding, 0)
+	return err
+}
+
+// UpsertResourceBindingIfVersion saves binding and returns the stored row.
+// With a non-zero expectedVersion an existing binding is only updated while it
+// is still at that version; otherwise ErrVersionConflict is returned.
+func (s *Store) UpsertResourceBindingIfVersion(ctx context.Context, binding ResourceBinding, expectedVersion int64) (*ResourceBinding, error) {
+	row, err := s.queries.UpsertResourceBinding(ctx, dbsqlc.UpsertResourceBindingParams{
+		Tenant:          binding.Tenant,
+		Workspace:       binding.Workspace,
+		Kind:            binding.Kind,
+		SecaRef:         binding.SecaRef,
+		ProviderRef:     binding.ProviderRef,
+		Status:          binding.Status,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("upsert resource binding %s: %w", binding.SecaRef, ErrVersionConflict)
+		}
+		return nil, fmt.Errorf("upsert resource binding: %w", err)
+	}
+	saved := resourceBindingFromRow(row)
+	return &saved, nil
+}
+
+// SyncResourceBinding records binding like UpsertResourceBinding but keeps
+// the version and updated_at of a stored binding whose provider ref and status
+// already match, so reads that refresh bindings are not modifications. An
+// active binding does not replace one marked BindingStatusError.
+func (s *Store) SyncResourceBinding(ctx context.Context, binding ResourceBinding) (*ResourceBinding, error) {
+	row, err := s.queries.SyncResourceBinding(ctx, dbsqlc.SyncResourceBindingParams{
 		Tenant:      binding.Tenant,
 		Workspace:   binding.Workspace,
 		Kind:        binding.Kind,
@@ -103,9 +196,66 @@ func (s *Store) UpsertResourceBinding(ctx context.Context, binding ResourceBindi
 		Status:      binding.Status,
 	})
 	if err != nil {
-		return fmt.Errorf("upsert resource binding: %w", err)
+		return nil, fmt.Errorf("sync resource binding: %w", err)
 	}
-	return nil
+	saved := resourceBindingFromRow(row)
+	return &saved, nil
+}
+
+// SyncResourceBindings records bindings of one tenant, workspace and kind like
+// SyncResourceBinding and returns the stored bindings keyed by seca ref. Those
+// already stored with the same provider ref and status are not written again
+// and the rest are written in one batch, so a listing costs one read and at
+// most one write round trip however many resources it holds.
+func (s *Store) SyncResourceBindings(ctx context.Context, tenant, workspace, kind string, bindings []ResourceBinding) (map[string]ResourceBinding, error) {
+	existing, err := s.ListResourceBindings(ctx, tenant, workspace, kind)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]ResourceBinding, len(existing))
+	for _, binding := range existing {
+		stored[binding.SecaRef] = binding
+	}
+	params := make([]dbsqlc.SyncResourceBindingsParams, 0)
+	for _, binding := range bindings {
+		if current, ok := stored[binding.SecaRef]; ok && current.ProviderRef == binding.ProviderRef && syncedStatus(current.Status, binding.Status) == current.Status {
+			continue
+		}
+		params = append(params, dbsqlc.SyncResourceBindingsParams{
+			Tenant:      tenant,
+			Workspace:   workspace,
+			Kind:        kind,
+			SecaRef:     binding.SecaRef,
+			ProviderRef: binding.ProviderRef,
+			Status:      binding.Status,
+		})
+	}
+	if len(params) == 0 {
+		return stored, nil
+	}
+	var batchErr error
+	s.queries.SyncResourceBindings(ctx, params).QueryRow(func(_ int, row dbsqlc.ResourceBinding, err error) {
+		if err != nil {
+			if batchErr == nil {
+				batchErr = fmt.Errorf("sync resource bindings: %w", err)
+			}
+			return
+		}
+		stored[row.SecaRef] = resourceBindingFromRow(row)
+	})
+	if batchErr != nil {
+		return nil, batchErr
+	}
+	return stored, nil
+}
+
+// syncedStatus is the status a sync to status leaves on a binding stored with
+// current, as the sync queries compute it.
+func syncedStatus(current, status string) string {
+	if current == BindingStatusError && status == "active" {
+		return current
+	}
+	return status
 }
 
 func (s *Store) GetResourceBinding(ctx context.Context, secaRef string) (*ResourceBinding, error) {
@@ -116,16 +266,8 @@ func (s *Store) GetResourceBinding(ctx context.Context, secaRef string) (*Resour
 		}
 		return nil, fmt.Errorf("get resource binding: %w", err)
 	}
-	return &ResourceBinding{
-		Tenant:      row.Tenant,
-		Workspace:   row.Workspace,
-		Kind:        row.Kind,
-		SecaRef:     row.SecaRef,
-		ProviderRef: row.ProviderRef,
-		Status:      row.Status,
-		CreatedAt:   row.CreatedAt.Time,
-		UpdatedAt:   row.UpdatedAt.Time,
-	}, nil
+	binding := resourceBindingFromRow(row)
+	return &binding, nil
 }
 
 func (s *Store) ListResourceBindings(ctx context.Context, tenant, workspace, kind string) ([]ResourceBinding, error) {
@@ -137,20 +279,104 @@ func (s *Store) ListResourceBindings(ctx context.Context, tenant, workspace, kin
 	}
 	out := make([]ResourceBinding, 0, len(rows))
 	for _, row := range rows {
-		out = append(out, ResourceBinding{
-			Tenant:      row.Tenant,
-			Workspace:   row.Workspace,
-			Kind:        row.Kind,
-			SecaRef:     row.SecaRef,
-			ProviderRef: row.ProviderRef,
-			Status:      row.Status,
-			CreatedAt:   row.CreatedAt.Time,
-			UpdatedAt:   row.UpdatedAt.Time,
-		})
+		out = append(out, resourceBindingFromRow(row))
+	}
+	return out, nil
+}
+
+// ListWorkspaceResourceBindings lists every binding of the workspace,
+// whatever its kind.
+func (s *Store) ListWorkspaceResourceBindings(ctx context.Context, tenant, workspace string) ([]ResourceBinding, error) {
+	rows, err := s.queries.ListResourceBindingsByScope(ctx, dbsqlc.ListResourceBindingsByScopeParams{
+		Tenant: tenant, Workspace: workspace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list workspace resource bindings: %w", err)
+	}
+	out := make([]ResourceBinding, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, resourceBindingFromRow(row))
+	}
+	return out, nil
+}
+
+// ResourceBindingCount is the number of bindings of one kind in a workspace.
+type ResourceBindingCount struct {
+	Workspace string
+	Kind      string
+	Count     int
+}
+
+// CountTenantResourceBindings counts the bindings of every workspace of the
+// tenant, grouped by workspace and kind.
+func (s *Store) CountTenantResourceBindings(ctx context.Context, tenant string) ([]ResourceBindingCount, error) {
+	rows, err := s.queries.CountResourceBindingsByTenant(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("count resource bindings: %w", err)
+	}
+	out := make([]ResourceBindingCount, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, ResourceBindingCount{Workspace: row.Workspace, Kind: row.Kind, Count: int(row.Count)})
+	}
+	return out, nil
+}
+
+// ListTenantResourceBindings lists the bindings of kind across every
+// workspace of the tenant, for tenant-scoped resources backed by a workspace.
+func (s *Store) ListTenantResourceBindings(ctx context.Context, tenant, kind string) ([]ResourceBinding, error) {
+	rows, err := s.queries.ListResourceBindingsByTenantAndKind(ctx, dbsqlc.ListResourceBindingsByTenantAndKindParams{
+		Tenant: tenant, Kind: kind,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list tenant resource bindings: %w", err)
+	}
+	out := make([]ResourceBinding, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, resourceBindingFromRow(row))
+	}
+	return out, nil
+}
+
+// ListResourceBindingsOfKinds lists the bindings of the given kinds across
+// every tenant and workspace, ordered by tenant and workspace.
+func (s *Store) ListResourceBindingsOfKinds(ctx context.Context, kinds []string) ([]ResourceBinding, error) {
+	rows, err := s.queries.ListResourceBindingsByKinds(ctx, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("list resource bindings by kind: %w", err)
+	}
+	out := make([]ResourceBinding, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, resourceBindingFromRow(row))
 	}
 	return out, nil
 }
 
+// UpdateResourceBindingStatus sets the binding's status without touching its
+// provider ref or version. It reports false when the binding is gone.
+func (s *Store) UpdateResourceBindingStatus(ctx context.Context, secaRef, status string) (bool, error) {
+	count, err := s.queries.UpdateResourceBindingStatus(ctx, dbsqlc.UpdateResourceBindingStatusParams{
+		SecaRef: secaRef,
+		Status:  status,
+	})
+	if err != nil {
+		return false, fmt.Errorf("update resource binding status: %w", err)
+	}
+	return count > 0, nil
+}
+
+// DeleteResourceBindingIfStatus deletes the binding only while it still has
+// status, so a binding refreshed in the meantime survives.
+func (s *Store) DeleteResourceBindingIfStatus(ctx context.Context, secaRef, status string) (bool, error) {
+	count, err := s.queries.DeleteResourceBindingIfStatus(ctx, dbsqlc.DeleteResourceBindingIfStatusParams{
+		SecaRef: secaRef,
+		Status:  status,
+	})
+	if err != nil {
+		return false, fmt.Errorf("delete resource binding: %w", err)
+	}
+	return count > 0, nil
+}
+
 func (s *Store) DeleteResourceBinding(ctx context.Context, secaRef string) error {
 	if err := s.queries.DeleteResourceBindingBySecaRef(ctx, secaRef); err != nil {
 		return fmt.Errorf("delete resource binding: %w", err)
@@ -158,21 +384,47 @@ func (s *Store) DeleteResourceBinding(ctx context.Context, secaRef string) error
 	return nil
 }
 
-func (s *Store) CreateOperation(ctx context.Context, operation OperationRecord) error {
-	var providerActionID pgtype.Text
-	if operation.ProviderActionID != "" {
-		providerActionID = pgtype.Text{String: operation.ProviderActionID, Valid: true}
+// DeleteResourceBindingIfVersion deletes the binding only while it is at
+// expectedVersion and returns ErrVersionConflict otherwise. A zero
+// expectedVersion deletes unconditionally.
+func (s *Store) DeleteResourceBindingIfVersion(ctx context.Context, secaRef string, expectedVersion int64) error {
+	if expectedVersion == 0 {
+		return s.DeleteResourceBinding(ctx, secaRef)
 	}
-	var errorText pgtype.Text
-	if operation.ErrorText != "" {
-		errorText = pgtype.Text{String: operation.ErrorText, Valid: true}
+	count, err := s.queries.DeleteResourceBindingIfVersion(ctx, dbsqlc.DeleteResourceBindingIfVersionParams{
+		SecaRef:         secaRef,
+		ResourceVersion: expectedVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("delete resource binding: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("delete resource binding %s: %w", secaRef, ErrVersionConflict)
 	}
+	return nil
+}
+
+func resourceBindingFromRow(row dbsqlc.ResourceBinding) ResourceBinding {
+	return ResourceBinding{
+		Tenant:          row.Tenant,
+		Workspace:       row.Workspace,
+		Kind:            row.Kind,
+		SecaRef:         row.SecaRef,
+		ProviderRef:     row.ProviderRef,
+		Status:          row.Status,
+		ResourceVersion: row.ResourceVersion,
+		CreatedAt:       row.CreatedAt.Time,
+		UpdatedAt:       row.UpdatedAt.Time,
+	}
+}
+
+func (s *Store) CreateOperation(ctx context.Context, operation OperationRecord) error {
 	_, err := s.queries.CreateOperation(ctx, dbsqlc.CreateOperationParams{
 		OperationID:      operation.OperationID,
 		SecaRef:          operation.SecaRef,
-		ProviderActionID: providerActionID,
+		ProviderActionID: optionalText(operation.ProviderActionID),
 		Phase:            operation.Phase,
-		ErrorText:        errorText,
+		ErrorText:        optionalText(operation.ErrorText),
 	})
 	if err != nil {
 		return fmt.Errorf("create operation: %w", err)
@@ -180,7 +432,161 @@ func (s *Store) CreateOperation(ctx context.Context, operation OperationRecord)
 	return nil
 }
 
+// CreateOperations writes the operations in a single round trip and returns
+// one error slot per record. A record whose operation ID already exists fails
+// with ErrOperationExists without affecting the others. Any other failure
+// aborts the whole batch, since it runs in one implicit transaction, and is
+// then reported for every record.
+func (s *Store) CreateOperations(ctx context.Context, operations []OperationRecord) []error {
+	errs := make([]error, len(operations))
+	if len(operations) == 0 {
+		return errs
+	}
+	params := make([]dbsqlc.CreateOperationsParams, 0, len(operations))
+	for _, operation := range operations {
+		params = append(params, dbsqlc.CreateOperationsParams{
+			OperationID:      operation.OperationID,
+			SecaRef:          operation.SecaRef,
+			ProviderActionID: optionalText(operation.ProviderActionID),
+			Phase:            operation.Phase,
+			ErrorText:        optionalText(operation.ErrorText),
+		})
+	}
+	var batchErr error
+	s.queries.CreateOperations(ctx, params).QueryRow(func(i int, _ string, err error) {
+		switch {
+		case err == nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			errs[i] = fmt.Errorf("create operation %s: %w", operations[i].OperationID, ErrOperationExists)
+		default:
+			errs[i] = fmt.Errorf("create operation %s: %w", operations[i].OperationID, err)
+			if batchErr == nil {
+				batchErr = errs[i]
+			}
+		}
+	})
+	if batchErr != nil {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = batchErr
+			}
+		}
+	}
+	return errs
+}
+
+// CountActiveOperationsByPhase returns how many operations sit in each
+// non-final phase.
+func (s *Store) CountActiveOperationsByPhase(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.queries.CountActiveOperationsByPhase(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("count active operations: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Phase] = row.Count
+	}
+	return counts, nil
+}
+
+// ListPendingOperations returns up to limit operations that carry a provider
+// action and have not reached a final phase, least recently checked first.
+func (s *Store) ListPendingOperations(ctx context.Context, limit int32) ([]OperationRecord, error) {
+	rows, err := s.queries.ListPendingOperations(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list pending operations: %w", err)
+	}
+	out := make([]OperationRecord, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, OperationRecord{
+			OperationID:      row.OperationID,
+			SecaRef:          row.SecaRef,
+			ProviderActionID: row.ProviderActionID.String,
+			Phase:            row.Phase,
+			ErrorText:        row.ErrorText.String,
+		})
+	}
+	return out, nil
+}
+
+// ListOperationsBySecaRef returns the matching operations recorded for one
+// resource, newest first.
+func (s *Store) ListOperationsBySecaRef(ctx context.Context, secaRef string, filter OperationFilter) ([]OperationRecord, error) {
+	rows, err := s.queries.ListOperationsBySecaRef(ctx, dbsqlc.ListOperationsBySecaRefParams{
+		SecaRef:  secaRef,
+		Phase:    filter.Phase,
+		BeforeID: filter.BeforeID,
+		RowLimit: int32(filter.Limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list operations by seca ref: %w", err)
+	}
+	out := make([]OperationRecord, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, OperationRecord{
+			ID:               row.ID,
+			OperationID:      row.OperationID,
+			SecaRef:          row.SecaRef,
+			ProviderActionID: row.ProviderActionID.String,
+			Phase:            row.Phase,
+			ErrorText:        row.ErrorText.String,
+			CreatedAt:        row.CreatedAt.Time.UTC(),
+			UpdatedAt:        row.UpdatedAt.Time.UTC(),
+		})
+	}
+	return out, nil
+}
+
+// UpdateOperationPhase moves an operation to phase. Updating it to its
+// current phase still marks it as checked.
+func (s *Store) UpdateOperationPhase(ctx context.Context, operationID, phase, errorText string) error {
+	if _, err := s.queries.UpdateOperationPhase(ctx, dbsqlc.UpdateOperationPhaseParams{
+		OperationID: operationID,
+		Phase:       phase,
+		ErrorText:   optionalText(errorText),
+	}); err != nil {
+		return fmt.Errorf("update operation phase: %w", err)
+	}
+	return nil
+}
+
+// UpdateOperationProviderAction records the Hetzner action an operation is
+// now waiting for, such as the power-on sent for a deferred start.
+func (s *Store) UpdateOperationProviderAction(ctx context.Context, operationID, providerActionID, phase string) error {
+	if _, err := s.queries.UpdateOperationProviderAction(ctx, dbsqlc.UpdateOperationProviderActionParams{
+		OperationID:      operationID,
+		ProviderActionID: optionalText(providerActionID),
+		Phase:            phase,
+	}); err != nil {
+		return fmt.Errorf("update operation provider action: %w", err)
+	}
+	return nil
+}
+
+// PruneOperations deletes succeeded and failed operations last updated
+// before cutoff and returns how many were removed.
+func (s *Store) PruneOperations(ctx context.Context, cutoff time.Time) (int64, error) {
+	deleted, err := s.queries.DeleteCompletedOperationsBefore(ctx, pgtype.Timestamptz{Time: cutoff, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("prune operations: %w", err)
+	}
+	return deleted, nil
+}
+
+func optionalText(value string) pgtype.Text {
+	if value == "" {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: value, Valid: true}
+}
+
 func (s *Store) UpsertRole(ctx context.Context, resource AuthResource) error {
+	return s.UpsertRoleIfVersion(ctx, resource, 0)
+}
+
+// UpsertRoleIfVersion saves resource; a non-zero expectedVersion only updates
+// a role still at that version and otherwise returns ErrVersionConflict.
+func (s *Store) UpsertRoleIfVersion(ctx context.Context, resource AuthResource, expectedVersion int64) error {
 	labelsJSON, err := json.Marshal(resource.Labels)
 	if err != nil {
 		return fmt.Errorf("marshal role labels: %w", err)
@@ -194,13 +600,17 @@ func (s *Store) UpsertRole(ctx context.Context, resource AuthResource) error {
 		return fmt.Errorf("marshal role status: %w", err)
 	}
 	_, err = s.queries.UpsertAuthRole(ctx, dbsqlc.UpsertAuthRoleParams{
-		Tenant: resource.Tenant,
-		Name:   resource.Name,
-		Labels: labelsJSON,
-		Spec:   specJSON,
-		Status: statusJSON,
+		Tenant:          resource.Tenant,
+		Name:            resource.Name,
+		Labels:          labelsJSON,
+		Spec:            specJSON,
+		Status:          statusJSON,
+		ExpectedVersion: expectedVersion,
 	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("upsert role %s: %w", resource.Name, ErrVersionConflict)
+		}
 		return fmt.Errorf("upsert role: %w", err)
 	}
 	return nil
@@ -238,14 +648,29 @@ func (s *Store) ListRoles(ctx context.Context, tenant string) ([]AuthResource, e
 }
 
 func (s *Store) SoftDeleteRole(ctx context.Context, tenant, name string) (bool, error) {
-	count, err := s.queries.SoftDeleteAuthRole(ctx, dbsqlc.SoftDeleteAuthRoleParams{Tenant: tenant, Name: name})
+	return s.SoftDeleteRoleIfVersion(ctx, tenant, name, 0)
+}
+
+// SoftDeleteRoleIfVersion deletes the role only while it is at a non-zero
+// expectedVersion and returns ErrVersionConflict otherwise.
+func (s *Store) SoftDeleteRoleIfVersion(ctx context.Context, tenant, name string, expectedVersion int64) (bool, error) {
+	count, err := s.queries.SoftDeleteAuthRole(ctx, dbsqlc.SoftDeleteAuthRoleParams{Tenant: tenant, Name: name, ExpectedVersion: expectedVersion})
 	if err != nil {
 		return false, fmt.Errorf("soft delete role: %w", err)
 	}
+	if count == 0 && expectedVersion != 0 {
+		return false, fmt.Errorf("soft delete role %s: %w", name, ErrVersionConflict)
+	}
 	return count > 0, nil
 }
 
 func (s *Store) UpsertRoleAssignment(ctx context.Context, resource AuthResource) error {
+	return s.UpsertRoleAssignmentIfVersion(ctx, resource, 0)
+}
+
+// UpsertRoleAssignmentIfVersion saves resource; a non-zero expectedVersion only updates
+// a role assignment still at that version and otherwise returns ErrVersionConflict.
+func (s *Store) UpsertRoleAssignmentIfVersion(ctx context.Context, resource AuthResource, expectedVersion int64) error {
 	labelsJSON, err := json.Marshal(resource.Labels)
 	if err != nil {
 		return fmt.Errorf("marshal role assignment labels: %w", err)
@@ -259,13 +684,17 @@ func (s *Store) UpsertRoleAssignment(ctx context.Context, resource AuthResource)
 		return fmt.Errorf("marshal role assignment status: %w", err)
 	}
 	_, err = s.queries.UpsertAuthRoleAssignment(ctx, dbsqlc.UpsertAuthRoleAssignmentParams{
-		Tenant: resource.Tenant,
-		Name:   resource.Name,
-		Labels: labelsJSON,
-		Spec:   specJSON,
-		Status: statusJSON,
+		Tenant:          resource.Tenant,
+		Name:            resource.Name,
+		Labels:          labelsJSON,
+		Spec:            specJSON,
+		Status:          statusJSON,
+		ExpectedVersion: expectedVersion,
 	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("upsert role assignment %s: %w", resource.Name, ErrVersionConflict)
+		}
 		return fmt.Errorf("upsert role assignment: %w", err)
 	}
 	return nil
@@ -302,15 +731,53 @@ func (s *Store) ListRoleAssignments(ctx context.Context, tenant string) ([]AuthR
 	return out, nil
 }
 
+// ListRoleAssignmentsMatching lists the role assignments naming subject in
+// their subs and any of roles in their roles. An empty subject or roles
+// matches every assignment.
+func (s *Store) ListRoleAssignmentsMatching(ctx context.Context, tenant, subject string, roles []string) ([]AuthResource, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+	rows, err := s.queries.ListAuthRoleAssignmentsMatching(ctx, dbsqlc.ListAuthRoleAssignmentsMatchingParams{Tenant: tenant, Subject: subject, Roles: roles})
+	if err != nil {
+		return nil, fmt.Errorf("list matching role assignments: %w", err)
+	}
+	out := make([]AuthResource, 0, len(rows))
+	for _, row := range rows {
+		resource, convErr := authResourceFromRoleAssignmentRow(row)
+		if convErr != nil {
+			return nil, convErr
+		}
+		out = append(out, resource)
+	}
+	return out, nil
+}
+
 func (s *Store) SoftDeleteRoleAssignment(ctx context.Context, tenant, name string) (bool, error) {
-	count, err := s.queries.SoftDeleteAuthRoleAssignment(ctx, dbsqlc.SoftDeleteAuthRoleAssignmentParams{Tenant: tenant, Name: name})
+	return s.SoftDeleteRoleAssignmentIfVersion(ctx, tenant, name, 0)
+}
+
+// SoftDeleteRoleAssignmentIfVersion deletes the role assignment only while it is at a non-zero
+// expectedVersion and returns ErrVersionConflict otherwise.
+func (s *Store) SoftDeleteRoleAssignmentIfVersion(ctx context.Context, tenant, name string, expectedVersion int64) (bool, error) {
+	count, err := s.queries.SoftDeleteAuthRoleAssignment(ctx, dbsqlc.SoftDeleteAuthRoleAssignmentParams{Tenant: tenant, Name: name, ExpectedVersion: expectedVersion})
 	if err != nil {
 		return false, fmt.Errorf("soft delete role assignment: %w", err)
 	}
+	if count == 0 && expectedVersion != 0 {
+		return false, fmt.Errorf("soft delete role assignment %s: %w", name, ErrVersionConflict)
+	}
 	return count > 0, nil
 }
 
 func (s *Store) UpsertWorkspace(ctx context.Context, resource WorkspaceResource) (*WorkspaceResource, error) {
+	return s.UpsertWorkspaceIfVersion(ctx, resource, 0)
+}
+
+// UpsertWorkspaceIfVersion saves resource; a non-zero expectedVersion only
+// updates a workspace still at that version and otherwise returns
+// ErrVersionConflict.
+func (s *Store) UpsertWorkspaceIfVersion(ctx context.Context, resource WorkspaceResource, expectedVersion int64) (*WorkspaceResource, error) {
 	labelsJSON, err := json.Marshal(resource.Labels)
 	if err != nil {
 		return nil, fmt.Errorf("marshal workspace labels: %w", err)
@@ -324,14 +791,18 @@ func (s *Store) UpsertWorkspace(ctx context.Context, resource WorkspaceResource)
 		return nil, fmt.Errorf("marshal workspace status: %w", err)
 	}
 	row, err := s.queries.UpsertWorkspace(ctx, dbsqlc.UpsertWorkspaceParams{
-		Tenant: resource.Tenant,
-		Name:   resource.Name,
-		Region: resource.Region,
-		Labels: labelsJSON,
-		Spec:   specJSON,
-		Status: statusJSON,
+		Tenant:          resource.Tenant,
+		Name:            resource.Name,
+		Region:          resource.Region,
+		Labels:          labelsJSON,
+		Spec:            specJSON,
+		Status:          statusJSON,
+		ExpectedVersion: expectedVersion,
 	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("upsert workspace %s: %w", resource.Name, ErrVersionConflict)
+		}
 		return nil, fmt.Errorf("upsert workspace: %w", err)
 	}
 	out, err := workspaceResourceFromRow(row)
@@ -373,10 +844,161 @@ func (s *Store) ListWorkspaces(ctx context.Context, tenant string) ([]WorkspaceR
 }
 
 func (s *Store) SoftDeleteWorkspace(ctx context.Context, tenant, name string) (bool, error) {
-	count, err := s.queries.SoftDeleteWorkspace(ctx, dbsqlc.SoftDeleteWorkspaceParams{Tenant: tenant, Name: name})
+	return s.SoftDeleteWorkspaceIfVersion(ctx, tenant, name, 0)
+}
+
+// SoftDeleteWorkspaceIfVersion deletes the workspace only while it is at a
+// non-zero expectedVersion and returns ErrVersionConflict otherwise.
+func (s *Store) SoftDeleteWorkspaceIfVersion(ctx context.Context, tenant, name string, expectedVersion int64) (bool, error) {
+	count, err := s.queries.SoftDeleteWorkspace(ctx, dbsqlc.SoftDeleteWorkspaceParams{Tenant: tenant, Name: name, ExpectedVersion: expectedVersion})
 	if err != nil {
 		return false, fmt.Errorf("soft delete workspace: %w", err)
 	}
+	if count == 0 && expectedVersion != 0 {
+		return false, fmt.Errorf("soft delete workspace %s: %w", name, ErrVersionConflict)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) UpsertTenantEntitlements(ctx context.Context, tenant string, providers []string) (*TenantEntitlements, error) {
+	if providers == nil {
+		providers = []string{}
+	}
+	providersJSON, err := json.Marshal(providers)
+	if err != nil {
+		return nil, fmt.Errorf("marshal tenant entitlements: %w", err)
+	}
+	row, err := s.queries.UpsertTenantEntitlements(ctx, dbsqlc.UpsertTenantEntitlementsParams{
+		Tenant:    tenant,
+		Providers: providersJSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upsert tenant entitlements: %w", err)
+	}
+	out, err := tenantEntitlementsFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *Store) GetTenantEntitlements(ctx context.Context, tenant string) (*TenantEntitlements, error) {
+	row, err := s.queries.GetTenantEntitlements(ctx, tenant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get tenant entitlements: %w", err)
+	}
+	out, err := tenantEntitlementsFromRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *Store) DeleteTenantEntitlements(ctx context.Context, tenant string) (bool, error) {
+	count, err := s.queries.DeleteTenantEntitlements(ctx, tenant)
+	if err != nil {
+		return false, fmt.Errorf("delete tenant entitlements: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) UpsertTenant(ctx context.Context, name string) (*Tenant, error) {
+	row, err := s.queries.UpsertTenant(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("upsert tenant: %w", err)
+	}
+	out := tenantFromRow(row)
+	return &out, nil
+}
+
+// GetTenant returns the registered tenant, or nil when it is not registered.
+func (s *Store) GetTenant(ctx context.Context, name string) (*Tenant, error) {
+	row, err := s.queries.GetTenant(ctx, name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get tenant: %w", err)
+	}
+	out := tenantFromRow(row)
+	return &out, nil
+}
+
+func (s *Store) ListTenants(ctx context.Context) ([]Tenant, error) {
+	rows, err := s.queries.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	out := make([]Tenant, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, tenantFromRow(row))
+	}
+	return out, nil
+}
+
+func (s *Store) DeleteTenant(ctx context.Context, name string) (bool, error) {
+	count, err := s.queries.DeleteTenant(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("delete tenant: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) UpsertTenantAPIToken(ctx context.Context, tenant, name, token string) (*TenantAPIToken, error) {
+	row, err := s.queries.UpsertTenantAPIToken(ctx, dbsqlc.UpsertTenantAPITokenParams{
+		Tenant:    tenant,
+		Name:      name,
+		TokenHash: s.tokenCodec.Hash(token),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upsert tenant api token: %w", err)
+	}
+	out := tenantAPITokenFromRow(row)
+	return &out, nil
+}
+
+// LookupTenantAPIToken returns the token matching the presented bearer
+// token, or nil when none does. A token hashed under an older credentials key
+// is re-hashed under the newest one once it is presented.
+func (s *Store) LookupTenantAPIToken(ctx context.Context, token string) (*TenantAPIToken, error) {
+	for i, hash := range s.tokenCodec.Hashes(token) {
+		row, err := s.queries.GetTenantAPITokenByHash(ctx, hash)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, fmt.Errorf("lookup tenant api token: %w", err)
+		}
+		if i > 0 {
+			// Best effort: the old hash keeps working until the key is dropped.
+			_, _ = s.UpsertTenantAPIToken(ctx, row.Tenant, row.Name, token)
+		}
+		out := tenantAPITokenFromRow(row)
+		return &out, nil
+	}
+	return nil, nil
+}
+
+func (s *Store) ListTenantAPITokens(ctx context.Context, tenant string) ([]TenantAPIToken, error) {
+	rows, err := s.queries.ListTenantAPITokens(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("list tenant api tokens: %w", err)
+	}
+	out := make([]TenantAPIToken, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, tenantAPITokenFromRow(row))
+	}
+	return out, nil
+}
+
+func (s *Store) DeleteTenantAPIToken(ctx context.Context, tenant, name string) (bool, error) {
+	count, err := s.queries.DeleteTenantAPIToken(ctx, dbsqlc.DeleteTenantAPITokenParams{Tenant: tenant, Name: name})
+	if err != nil {
+		return false, fmt.Errorf("delete tenant api token: %w", err)
+	}
 	return count > 0, nil
 }
 
@@ -404,6 +1026,7 @@ func (s *Store) UpsertWorkspaceProviderCredential(ctx context.Context, cred Work
 	if err != nil {
 		return nil, fmt.Errorf("upsert workspace provider credential: %w", err)
 	}
+	s.credentialGens.Bump(cred.Tenant, cred.Workspace, cred.Provider)
 	out, convErr := s.workspaceProviderCredentialFromRow(row)
 	if convErr != nil {
 		return nil, convErr
@@ -428,6 +1051,116 @@ func (s *Store) GetWorkspaceProviderCredential(ctx context.Context, tenant, work
 	return &out, nil
 }
 
+// ListWorkspaceProviderCredentials lists the tenant's active credentials
+// without decrypting them; APIToken is always empty.
+func (s *Store) ListWorkspaceProviderCredentials(ctx context.Context, tenant string) ([]WorkspaceProviderCredential, error) {
+	rows, err := s.queries.ListWorkspaceProviderCredentialsByTenant(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("list workspace provider credentials: %w", err)
+	}
+	out := make([]WorkspaceProviderCredential, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, workspaceProviderCredentialMetadata(row))
+	}
+	return out, nil
+}
+
+// RotateWorkspaceProviderCredentialToken replaces the token of an active
+// credential in place, keeping its project and endpoint. It returns nil when
+// the workspace has no active credential.
+func (s *Store) RotateWorkspaceProviderCredentialToken(ctx context.Context, tenant, workspace, provider, token string) (*WorkspaceProviderCredential, error) {
+	encryptedToken, err := s.tokenCodec.Encrypt(token)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt workspace provider credential token: %w", err)
+	}
+	row, err := s.queries.RotateWorkspaceProviderCredentialToken(ctx, dbsqlc.RotateWorkspaceProviderCredentialTokenParams{
+		Tenant:            tenant,
+		Workspace:         workspace,
+		Provider:          provider,
+		ApiTokenEncrypted: encryptedToken,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("rotate workspace provider credential: %w", err)
+	}
+	s.credentialGens.Bump(tenant, workspace, provider)
+	out := workspaceProviderCredentialMetadata(row)
+	return &out, nil
+}
+
+// CredentialRotationResult reports a ReencryptWorkspaceProviderCredentials run.
+type CredentialRotationResult struct {
+	Rotated   int
+	Unchanged int
+	Failures  []CredentialRotationFailure
+}
+
+// CredentialRotationFailure names a credential that could not be re-encrypted.
+type CredentialRotationFailure struct {
+	Tenant    string
+	Workspace string
+	Provider  string
+	Error     string
+}
+
+// ReencryptWorkspaceProviderCredentials re-encrypts every active credential,
+// the global ones included, that is not yet under the newest credentials key.
+// A row that cannot be decrypted or re-written is reported in Failures and
+// the run continues.
+func (s *Store) ReencryptWorkspaceProviderCredentials(ctx context.Context) (CredentialRotationResult, error) {
+	rows, err := s.queries.ListAllWorkspaceProviderCredentials(ctx)
+	if err != nil {
+		return CredentialRotationResult{}, fmt.Errorf("list workspace provider credentials: %w", err)
+	}
+	var result CredentialRotationResult
+	for _, row := range rows {
+		if s.tokenCodec.Current(row.ApiTokenEncrypted) {
+			result.Unchanged++
+			continue
+		}
+		fail := func(err error) {
+			result.Failures = append(result.Failures, CredentialRotationFailure{
+				Tenant: row.Tenant, Workspace: row.Workspace, Provider: row.Provider, Error: err.Error(),
+			})
+		}
+		token, err := s.tokenCodec.Decrypt(row.ApiTokenEncrypted)
+		if err != nil {
+			fail(fmt.Errorf("decrypt: %w", err))
+			continue
+		}
+		encryptedToken, err := s.tokenCodec.Encrypt(token)
+		if err != nil {
+			fail(fmt.Errorf("encrypt: %w", err))
+			continue
+		}
+		// The update only applies if the row still holds the ciphertext that
+		// was read, so a concurrent rotation of the token is not overwritten.
+		count, err := s.queries.ReencryptWorkspaceProviderCredentialToken(ctx, dbsqlc.ReencryptWorkspaceProviderCredentialTokenParams{
+			NewToken: encryptedToken,
+			ID:       row.ID,
+			OldToken: row.ApiTokenEncrypted,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, fmt.Errorf("re-encrypt workspace provider credential: %w", err)
+			}
+			fail(err)
+			continue
+		}
+		if count == 0 {
+			fail(errors.New("credential changed during rotation"))
+			continue
+		}
+		result.Rotated++
+	}
+	if err := s.reencryptGlobalProviderCredentials(ctx, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
 func (s *Store) SoftDeleteWorkspaceProviderCredential(ctx context.Context, tenant, workspace, provider string) (bool, error) {
 	count, err := s.queries.SoftDeleteWorkspaceProviderCredential(ctx, dbsqlc.SoftDeleteWorkspaceProviderCredentialParams{
 		Tenant: tenant, Workspace: workspace, Provider: provider,
@@ -435,9 +1168,18 @@ func (s *Store) SoftDeleteWorkspaceProviderCredential(ctx context.Context, tenan
 	if err != nil {
 		return false, fmt.Errorf("soft delete workspace provider credential: %w", err)
 	}
+	if count > 0 {
+		s.credentialGens.Bump(tenant, workspace, provider)
+	}
 	return count > 0, nil
 }
 
+// CredentialGenerations exposes the invalidation counters bumped by credential
+// upserts and deletes.
+func (s *Store) CredentialGenerations() *CredentialGenerations {
+	return s.credentialGens
+}
+
 func authResourceFromRoleRow(row dbsqlc.AuthRole) (AuthResource, error) {
 	labels := map[string]string{}
 	if err := json.Unmarshal(row.Labels, &labels); err != nil {
@@ -458,6 +1200,8 @@ func authResourceFromRoleRow(row dbsqlc.AuthRole) (AuthResource, error) {
 		Spec:            spec,
 		Status:          status,
 		ResourceVersion: row.ResourceVersion,
+		CreatedAt:       row.CreatedAt.Time.UTC(),
+		UpdatedAt:       row.UpdatedAt.Time.UTC(),
 	}, nil
 }
 
@@ -481,6 +1225,8 @@ func authResourceFromRoleAssignmentRow(row dbsqlc.AuthRoleAssignment) (AuthResou
 		Spec:            spec,
 		Status:          status,
 		ResourceVersion: row.ResourceVersion,
+		CreatedAt:       row.CreatedAt.Time.UTC(),
+		UpdatedAt:       row.UpdatedAt.Time.UTC(),
 	}, nil
 }
 
@@ -511,21 +1257,60 @@ func workspaceResourceFromRow(row dbsqlc.Workspace) (WorkspaceResource, error) {
 }
 
 func (s *Store) workspaceProviderCredentialFromRow(row dbsqlc.WorkspaceProviderCredential) (WorkspaceProviderCredential, error) {
-	out := WorkspaceProviderCredential{
-		Tenant:    row.Tenant,
-		Workspace: row.Workspace,
-		Provider:  row.Provider,
-	}
+	out := workspaceProviderCredentialMetadata(row)
 	token, err := s.tokenCodec.Decrypt(row.ApiTokenEncrypted)
 	if err != nil {
 		return WorkspaceProviderCredential{}, fmt.Errorf("decrypt workspace provider credential token: %w", err)
 	}
 	out.APIToken = token
+	return out, nil
+}
+
+func workspaceProviderCredentialMetadata(row dbsqlc.WorkspaceProviderCredential) WorkspaceProviderCredential {
+	out := WorkspaceProviderCredential{
+		Tenant:    row.Tenant,
+		Workspace: row.Workspace,
+		Provider:  row.Provider,
+		CreatedAt: row.CreatedAt.Time.UTC(),
+		UpdatedAt: row.UpdatedAt.Time.UTC(),
+	}
 	if row.ProjectRef.Valid {
 		out.ProjectRef = row.ProjectRef.String
 	}
 	if row.ApiEndpoint.Valid {
 		out.APIEndpoint = row.ApiEndpoint.String
 	}
-	return out, nil
+	return out
+}
+
+func tenantEntitlementsFromRow(row dbsqlc.TenantEntitlement) (TenantEntitlements, error) {
+	providers := []string{}
+	if len(row.Providers) > 0 {
+		if err := json.Unmarshal(row.Providers, &providers); err != nil {
+			return TenantEntitlements{}, fmt.Errorf("unmarshal tenant entitlements: %w", err)
+		}
+	}
+	return TenantEntitlements{
+		Tenant:    row.Tenant,
+		Providers: providers,
+		CreatedAt: row.CreatedAt.Time.UTC(),
+		UpdatedAt: row.UpdatedAt.Time.UTC(),
+	}, nil
+}
+
+func tenantFromRow(row dbsqlc.Tenant) Tenant {
+	return Tenant{
+		Name:      row.Name,
+		CreatedAt: row.CreatedAt.Time.UTC(),
+		UpdatedAt: row.UpdatedAt.Time.UTC(),
+	}
+}
+
+func tenantAPITokenFromRow(row dbsqlc.TenantApiToken) TenantAPIToken {
+	return TenantAPIToken{
+		Tenant:    row.Tenant,
+		Name:      row.Name,
+		CreatedAt: row.CreatedAt.Time.UTC(),
+		UpdatedAt: row.UpdatedAt.Time.UTC(),
+	}
 }
@@ -0,0 +1,174 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// countingDBTX keeps resource bindings in memory and counts the round trips
+// the store makes for them. It only understands the binding list and the
+// batched sync.
+type countingDBTX struct {
+	bindings map[string]dbsqlc.ResourceBinding
+	queries  int
+	batches  int
+	batched  int
+}
+
+func (d *countingDBTX) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("unexpected exec")
+}
+
+func (d *countingDBTX) Query(_ context.Context, _ string, args ...interface{}) (pgx.Rows, error) {
+	d.queries++
+	rows := []dbsqlc.ResourceBinding{}
+	for _, binding := range d.bindings {
+		if binding.Tenant == args[0] && binding.Workspace == args[1] && binding.Kind == args[2] {
+			rows = append(rows, binding)
+		}
+	}
+	return &fakeBindingRows{rows: rows, next: -1}, nil
+}
+
+func (d *countingDBTX) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	d.queries++
+	return fakeBindingRow{err: errors.New("unexpected query row")}
+}
+
+func (d *countingDBTX) SendBatch(_ context.Context, batch *pgx.Batch) pgx.BatchResults {
+	d.batches++
+	results := &fakeBindingBatch{}
+	for _, queued := range batch.QueuedQueries {
+		d.batched++
+		args := queued.Arguments
+		row := dbsqlc.ResourceBinding{
+			ID:              int64(len(d.bindings) + 1),
+			Tenant:          args[0].(string),
+			Workspace:       args[1].(string),
+			Kind:            args[2].(string),
+			SecaRef:         args[3].(string),
+			ProviderRef:     args[4].(string),
+			Status:          args[5].(string),
+			ResourceVersion: 1,
+		}
+		if existing, ok := d.bindings[row.SecaRef]; ok {
+			row.ID = existing.ID
+			row.ResourceVersion = existing.ResourceVersion + 1
+		}
+		d.bindings[row.SecaRef] = row
+		results.rows = append(results.rows, row)
+	}
+	return results
+}
+
+type fakeBindingRows struct {
+	rows []dbsqlc.ResourceBinding
+	next int
+}
+
+func (r *fakeBindingRows) Close()                                       {}
+func (r *fakeBindingRows) Err() error                                   { return nil }
+func (r *fakeBindingRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeBindingRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeBindingRows) Values() ([]any, error)                       { return nil, nil }
+func (r *fakeBindingRows) RawValues() [][]byte                          { return nil }
+func (r *fakeBindingRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeBindingRows) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *fakeBindingRows) Scan(dest ...any) error {
+	return scanFakeBinding(r.rows[r.next], dest)
+}
+
+type fakeBindingRow struct {
+	binding dbsqlc.ResourceBinding
+	err     error
+}
+
+func (r fakeBindingRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return scanFakeBinding(r.binding, dest)
+}
+
+type fakeBindingBatch struct {
+	rows []dbsqlc.ResourceBinding
+	next int
+}
+
+func (b *fakeBindingBatch) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, nil }
+func (b *fakeBindingBatch) Query() (pgx.Rows, error)         { return nil, errors.New("unexpected query") }
+func (b *fakeBindingBatch) Close() error                     { return nil }
+
+func (b *fakeBindingBatch) QueryRow() pgx.Row {
+	row := fakeBindingRow{binding: b.rows[b.next]}
+	b.next++
+	return row
+}
+
+func scanFakeBinding(row dbsqlc.ResourceBinding, dest []any) error {
+	values := []any{row.ID, row.Tenant, row.Workspace, row.Kind, row.SecaRef, row.ProviderRef, row.Status, row.CreatedAt, row.UpdatedAt, row.ResourceVersion}
+	if len(dest) != len(values) {
+		return fmt.Errorf("scan %d columns into %d destinations", len(values), len(dest))
+	}
+	for i, value := range values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func TestSyncResourceBindingsBatchesWritesAndSkipsUnchanged(t *testing.T) {
+	t.Parallel()
+
+	db := &countingDBTX{bindings: map[string]dbsqlc.ResourceBinding{}}
+	store := &Store{db: db, queries: dbsqlc.New(db)}
+	ctx := context.Background()
+	bindings := make([]ResourceBinding, 0, 200)
+	for i := 0; i < 200; i++ {
+		bindings = append(bindings, ResourceBinding{
+			SecaRef:     fmt.Sprintf("seca.compute/v1/tenants/t1/workspaces/ws1/instances/vm-%d", i),
+			ProviderRef: fmt.Sprintf("hetzner.cloud/servers/%d", i),
+			Status:      "active",
+		})
+	}
+
+	stored, err := store.SyncResourceBindings(ctx, "t1", "ws1", "instance", bindings)
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if len(stored) != 200 || db.queries != 1 || db.batches != 1 || db.batched != 200 {
+		t.Fatalf("expected one read and one batch of 200 writes, got %d bindings, %d queries, %d batches of %d", len(stored), db.queries, db.batches, db.batched)
+	}
+
+	db.queries, db.batches, db.batched = 0, 0, 0
+	if _, err := store.SyncResourceBindings(ctx, "t1", "ws1", "instance", bindings); err != nil {
+		t.Fatalf("unchanged sync: %v", err)
+	}
+	if db.queries != 1 || db.batches != 0 {
+		t.Fatalf("expected an unchanged listing to only read, got %d queries and %d batches", db.queries, db.batches)
+	}
+
+	db.queries, db.batches, db.batched = 0, 0, 0
+	bindings[7].ProviderRef = "hetzner.cloud/servers/1007"
+	stored, err = store.SyncResourceBindings(ctx, "t1", "ws1", "instance", bindings)
+	if err != nil {
+		t.Fatalf("changed sync: %v", err)
+	}
+	if db.queries != 1 || db.batches != 1 || db.batched != 1 {
+		t.Fatalf("expected only the changed binding to be written, got %d queries, %d batches of %d", db.queries, db.batches, db.batched)
+	}
+	if got := stored[bindings[7].SecaRef]; got.ProviderRef != "hetzner.cloud/servers/1007" || got.ResourceVersion != 2 {
+		t.Fatalf("unexpected changed binding %+v", got)
+	}
+}
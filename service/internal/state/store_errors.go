@@ -0,0 +1,158 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Store failures are wrapped in one of these so callers can tell them apart
+// without looking at pgx errors. The original error stays in the chain.
+var (
+	// ErrNotFound reports a query that matched no row.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict reports a write that lost against another one: a unique
+	// violation, a serialization failure or a deadlock.
+	ErrConflict = errors.New("conflicting write")
+	// ErrUnavailable reports that the database could not be reached, shut
+	// the connection down or did not answer in time.
+	ErrUnavailable = errors.New("state store unavailable")
+)
+
+// classifyStoreError wraps err in the typed store error it corresponds to.
+func classifyStoreError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505", "40001", "40P01":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case "57014":
+			// query_canceled, raised when statement_timeout fires.
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	}
+	var connectErr *pgconn.ConnectError
+	if isTransientStoreError(err) || errors.As(err, &connectErr) || pgconn.Timeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// isTransientStoreError reports connection failures a query is retried
+// after: a reset or dropped connection, or a server shutting down.
+func isTransientStoreError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection_exception; 57P01-57P03 are the shutdown codes.
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	return pgconn.SafeToRetry(err) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// storeDBTX runs every query once more after a transient connection failure,
+// then wraps what still fails with classifyStoreError. The pool hands out a
+// fresh connection for the retry. Batches are classified but not retried.
+type storeDBTX struct {
+	base dbsqlc.DBTX
+}
+
+func (d storeDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := d.base.Exec(ctx, sql, args...)
+	if isTransientStoreError(err) && ctx.Err() == nil {
+		tag, err = d.base.Exec(ctx, sql, args...)
+	}
+	return tag, classifyStoreError(err)
+}
+
+func (d storeDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := d.base.Query(ctx, sql, args...)
+	if isTransientStoreError(err) && ctx.Err() == nil {
+		rows, err = d.base.Query(ctx, sql, args...)
+	}
+	if err != nil {
+		return rows, classifyStoreError(err)
+	}
+	return classifiedRows{Rows: rows}, nil
+}
+
+func (d storeDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return storeRow{ctx: ctx, base: d.base, sql: sql, args: args}
+}
+
+func (d storeDBTX) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	return classifiedBatchResults{BatchResults: d.base.SendBatch(ctx, batch)}
+}
+
+// storeRow runs its query when scanned so a transient failure, which pgx
+// only reports from Scan, can still be retried.
+type storeRow struct {
+	ctx  context.Context
+	base dbsqlc.DBTX
+	sql  string
+	args []interface{}
+}
+
+func (r storeRow) Scan(dest ...any) error {
+	err := r.base.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if isTransientStoreError(err) && r.ctx.Err() == nil {
+		err = r.base.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return classifyStoreError(err)
+}
+
+type classifiedRows struct {
+	pgx.Rows
+}
+
+func (r classifiedRows) Scan(dest ...any) error {
+	return classifyStoreError(r.Rows.Scan(dest...))
+}
+
+func (r classifiedRows) Err() error {
+	return classifyStoreError(r.Rows.Err())
+}
+
+type classifiedBatchResults struct {
+	pgx.BatchResults
+}
+
+func (b classifiedBatchResults) Exec() (pgconn.CommandTag, error) {
+	tag, err := b.BatchResults.Exec()
+	return tag, classifyStoreError(err)
+}
+
+func (b classifiedBatchResults) Query() (pgx.Rows, error) {
+	rows, err := b.BatchResults.Query()
+	if err != nil {
+		return rows, classifyStoreError(err)
+	}
+	return classifiedRows{Rows: rows}, nil
+}
+
+func (b classifiedBatchResults) QueryRow() pgx.Row {
+	return classifiedRow{row: b.BatchResults.QueryRow()}
+}
+
+type classifiedRow struct {
+	row pgx.Row
+}
+
+func (r classifiedRow) Scan(dest ...any) error {
+	return classifyStoreError(r.row.Scan(dest...))
+}
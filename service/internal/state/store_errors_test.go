@@ -0,0 +1,76 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	dbsqlc "github.com/eu-sovereign-cloud/secapi-proxy-hetzner/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestClassifyStoreErrorMapsSQLState(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		code string
+		want error
+	}{
+		{code: "23505", want: ErrConflict},
+		{code: "40001", want: ErrConflict},
+		{code: "57P01", want: ErrUnavailable},
+		{code: "08006", want: ErrUnavailable},
+	}
+	for _, tc := range cases {
+		pgErr := &pgconn.PgError{Code: tc.code}
+		err := classifyStoreError(fmt.Errorf("upsert resource binding: %w", pgErr))
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.code, tc.want, err)
+		}
+		var unwrapped *pgconn.PgError
+		if !errors.As(err, &unwrapped) || unwrapped.Code != tc.code {
+			t.Fatalf("%s: expected the pg error to stay in the chain, got %v", tc.code, err)
+		}
+	}
+
+	if err := classifyStoreError(pgx.ErrNoRows); !errors.Is(err, ErrNotFound) || !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected ErrNotFound wrapping ErrNoRows, got %v", err)
+	}
+	syntax := &pgconn.PgError{Code: "42601"}
+	if err := classifyStoreError(syntax); err != syntax {
+		t.Fatalf("expected other errors to pass through, got %v", err)
+	}
+}
+
+// flakyDBTX fails the first Exec with err and succeeds afterwards; any other
+// call panics on the nil embedded interface.
+type flakyDBTX struct {
+	dbsqlc.DBTX
+	err   error
+	calls int
+}
+
+func (d *flakyDBTX) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	d.calls++
+	if d.calls == 1 {
+		return pgconn.CommandTag{}, d.err
+	}
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func TestStoreDBTXRetriesTransientFailuresOnce(t *testing.T) {
+	t.Parallel()
+
+	shutdown := &flakyDBTX{err: &pgconn.PgError{Code: "57P01"}}
+	if _, err := (storeDBTX{base: shutdown}).Exec(context.Background(), "UPDATE"); err != nil || shutdown.calls != 2 {
+		t.Fatalf("expected admin shutdown to be retried, got %d calls (err %v)", shutdown.calls, err)
+	}
+
+	unique := &flakyDBTX{err: &pgconn.PgError{Code: "23505"}}
+	_, err := (storeDBTX{base: unique}).Exec(context.Background(), "UPDATE")
+	if !errors.Is(err, ErrConflict) || unique.calls != 1 {
+		t.Fatalf("expected a unique violation to fail without retry, got %d calls (err %v)", unique.calls, err)
+	}
+}
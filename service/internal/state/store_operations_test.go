@@ -0,0 +1,76 @@
+package state
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"testing"
+)
+
+const benchmarkOperationCount = 100
+
+// benchmarkStore connects to SECA_TEST_DATABASE_URL and applies migrations.
+// The benchmarks are skipped when no test database is configured.
+func benchmarkStore(b *testing.B) *Store {
+	b.Helper()
+	databaseURL := os.Getenv("SECA_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		b.Skip("SECA_TEST_DATABASE_URL is not set")
+	}
+	if err := MigrateUp(databaseURL, "../../db/migrations"); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		b.Fatalf("generate key: %v", err)
+	}
+	store, err := New(context.Background(), databaseURL, base64.StdEncoding.EncodeToString(key), PoolConfig{})
+	if err != nil {
+		b.Fatalf("open store: %v", err)
+	}
+	b.Cleanup(store.Close)
+	return store
+}
+
+func benchmarkOperations(prefix string, iteration int) []OperationRecord {
+	operations := make([]OperationRecord, 0, benchmarkOperationCount)
+	for i := 0; i < benchmarkOperationCount; i++ {
+		operations = append(operations, OperationRecord{
+			OperationID:      fmt.Sprintf("%s-%d-%d", prefix, iteration, i),
+			SecaRef:          fmt.Sprintf("seca.compute/v1/tenants/bench/workspaces/ws/instances/vm-%d", i),
+			ProviderActionID: fmt.Sprintf("%d", i),
+			Phase:            "accepted",
+		})
+	}
+	return operations
+}
+
+func BenchmarkCreateOperationSingle(b *testing.B) {
+	store := benchmarkStore(b)
+	ctx := context.Background()
+	prefix := fmt.Sprintf("bench-single-%s", rand.Text())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, operation := range benchmarkOperations(prefix, n) {
+			if err := store.CreateOperation(ctx, operation); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCreateOperationsBatch(b *testing.B) {
+	store := benchmarkStore(b)
+	ctx := context.Background()
+	prefix := fmt.Sprintf("bench-batch-%s", rand.Text())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, err := range store.CreateOperations(ctx, benchmarkOperations(prefix, n)) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig sizes the connection pool and bounds how long a query may wait
+// for a connection and how long it may run. Zero values keep the pgx
+// defaults and leave queries unbounded.
+type PoolConfig struct {
+	MaxConns         int32
+	MinConns         int32
+	AcquireTimeout   time.Duration
+	StatementTimeout time.Duration
+}
+
+// PoolStats is a snapshot of the connection pool.
+type PoolStats struct {
+	Acquired int32
+	Idle     int32
+	Total    int32
+	Max      int32
+}
+
+func (s *Store) PoolStats() PoolStats {
+	stat := s.pool.Stat()
+	return PoolStats{
+		Acquired: stat.AcquiredConns(),
+		Idle:     stat.IdleConns(),
+		Total:    stat.TotalConns(),
+		Max:      stat.MaxConns(),
+	}
+}
+
+func newPoolConfig(databaseURL string, cfg PoolConfig) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		config.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		config.MinConns = cfg.MinConns
+	}
+	if cfg.StatementTimeout > 0 {
+		// The server cancels a runaway statement even if the client context
+		// is lost with its connection.
+		config.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprint(cfg.StatementTimeout.Milliseconds())
+	}
+	return config, nil
+}
+
+// pooledDBTX runs each query on its own pool connection. Waiting for the
+// connection is bounded by acquireTimeout and the query itself by
+// statementTimeout, so a stuck database fails requests quickly instead of
+// piling them up behind the pool.
+type pooledDBTX struct {
+	pool             *pgxpool.Pool
+	acquireTimeout   time.Duration
+	statementTimeout time.Duration
+}
+
+func (d pooledDBTX) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if d.acquireTimeout <= 0 {
+		return d.pool.Acquire(ctx)
+	}
+	acquireCtx, cancel := context.WithTimeout(ctx, d.acquireTimeout)
+	defer cancel()
+	conn, err := d.pool.Acquire(acquireCtx)
+	if err != nil && ctx.Err() == nil && acquireCtx.Err() != nil {
+		return nil, fmt.Errorf("acquire connection within %s: %w", d.acquireTimeout, context.DeadlineExceeded)
+	}
+	return conn, err
+}
+
+func (d pooledDBTX) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.statementTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.statementTimeout)
+}
+
+func (d pooledDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	conn, err := d.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+	queryCtx, cancel := d.queryContext(ctx)
+	defer cancel()
+	return conn.Exec(queryCtx, sql, args...)
+}
+
+func (d pooledDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	conn, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryCtx, cancel := d.queryContext(ctx)
+	rows, err := conn.Query(queryCtx, sql, args...)
+	if err != nil {
+		cancel()
+		conn.Release()
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, release: func() { cancel(); conn.Release() }}, nil
+}
+
+func (d pooledDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	conn, err := d.acquire(ctx)
+	if err != nil {
+		return faultRow{err: err}
+	}
+	queryCtx, cancel := d.queryContext(ctx)
+	return releasingRow{row: conn.QueryRow(queryCtx, sql, args...), release: func() { cancel(); conn.Release() }}
+}
+
+func (d pooledDBTX) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	conn, err := d.acquire(ctx)
+	if err != nil {
+		return faultBatchResults{err: err}
+	}
+	queryCtx, cancel := d.queryContext(ctx)
+	return releasingBatchResults{BatchResults: conn.SendBatch(queryCtx, batch), release: func() { cancel(); conn.Release() }}
+}
+
+// releasingRows hands the connection back once the rows are read or closed.
+type releasingRows struct {
+	pgx.Rows
+	release  func()
+	released bool
+}
+
+func (r *releasingRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *releasingRows) Close() {
+	r.Rows.Close()
+	if !r.released {
+		r.released = true
+		r.release()
+	}
+}
+
+type releasingRow struct {
+	row     pgx.Row
+	release func()
+}
+
+func (r releasingRow) Scan(dest ...any) error {
+	defer r.release()
+	return r.row.Scan(dest...)
+}
+
+type releasingBatchResults struct {
+	pgx.BatchResults
+	release func()
+}
+
+func (b releasingBatchResults) Close() error {
+	defer b.release()
+	return b.BatchResults.Close()
+}
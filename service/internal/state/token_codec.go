@@ -3,75 +3,167 @@ package state
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 )
 
-const encryptedTokenPrefix = "enc:v1:"
+const (
+	// legacyEncryptedTokenPrefix marks ciphertexts written before keys were
+	// tagged; they are tried against every key.
+	legacyEncryptedTokenPrefix = "enc:v1:"
+	// encryptedTokenPrefix is followed by the key ID, a colon and the
+	// ciphertext.
+	encryptedTokenPrefix = "enc:v2:"
+	hashedTokenPrefix    = "hmac:v1:"
+)
 
+// tokenCodec encrypts with the first configured key and decrypts with any of
+// them, so a new key can be put in front while rows written under the old
+// ones are re-encrypted.
 type tokenCodec struct {
-	aead cipher.AEAD
+	keys []codecKey
+}
+
+type codecKey struct {
+	id      string
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// newTokenCodec parses a comma-separated list of base64 keys, newest first.
+func newTokenCodec(rawKeys string) (*tokenCodec, error) {
+	codec := &tokenCodec{}
+	for _, rawKey := range strings.Split(rawKeys, ",") {
+		key, err := newCodecKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("credentials key %d: %w", len(codec.keys)+1, err)
+		}
+		codec.keys = append(codec.keys, key)
+	}
+	return codec, nil
 }
 
-func newTokenCodec(rawKey string) (*tokenCodec, error) {
+func newCodecKey(rawKey string) (codecKey, error) {
 	rawKey = strings.TrimSpace(rawKey)
 	if rawKey == "" {
-		return nil, errors.New("empty credentials key")
+		return codecKey{}, errors.New("empty credentials key")
 	}
 	keyBytes, err := base64.StdEncoding.DecodeString(rawKey)
 	if err != nil {
-		return nil, fmt.Errorf("decode base64 credentials key: %w", err)
+		return codecKey{}, fmt.Errorf("decode base64 credentials key: %w", err)
 	}
 	if len(keyBytes) != 32 {
-		return nil, fmt.Errorf("credentials key must decode to 32 bytes, got %d", len(keyBytes))
+		return codecKey{}, fmt.Errorf("credentials key must decode to 32 bytes, got %d", len(keyBytes))
 	}
 	block, err := aes.NewCipher(keyBytes)
 	if err != nil {
-		return nil, fmt.Errorf("create aes cipher: %w", err)
+		return codecKey{}, fmt.Errorf("create aes cipher: %w", err)
 	}
 	aead, err := cipher.NewGCM(block)
 	if err != nil {
-		return nil, fmt.Errorf("create gcm: %w", err)
+		return codecKey{}, fmt.Errorf("create gcm: %w", err)
 	}
-	return &tokenCodec{aead: aead}, nil
+	// The hash key and ID are derived so the encryption key is never used
+	// as a MAC key or revealed.
+	derived := hmac.New(sha256.New, keyBytes)
+	derived.Write([]byte("seca token hash"))
+	fingerprint := hmac.New(sha256.New, keyBytes)
+	fingerprint.Write([]byte("seca key id"))
+	return codecKey{
+		id:      hex.EncodeToString(fingerprint.Sum(nil))[:8],
+		aead:    aead,
+		hashKey: derived.Sum(nil),
+	}, nil
 }
 
 func (c *tokenCodec) Encrypt(plaintext string) (string, error) {
 	if strings.TrimSpace(plaintext) == "" {
 		return "", errors.New("empty token")
 	}
-	nonce := make([]byte, c.aead.NonceSize())
+	key := c.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return "", fmt.Errorf("generate nonce: %w", err)
 	}
-	ciphertext := c.aead.Seal(nil, nonce, []byte(plaintext), nil)
+	ciphertext := key.aead.Seal(nil, nonce, []byte(plaintext), nil)
 	blob := append(nonce, ciphertext...)
-	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(blob), nil
+	return encryptedTokenPrefix + key.id + ":" + base64.StdEncoding.EncodeToString(blob), nil
 }
 
 func (c *tokenCodec) Decrypt(ciphertext string) (string, error) {
-	if !strings.HasPrefix(ciphertext, encryptedTokenPrefix) {
+	if payload, ok := strings.CutPrefix(ciphertext, encryptedTokenPrefix); ok {
+		id, payload, _ := strings.Cut(payload, ":")
+		for _, key := range c.keys {
+			if key.id == id {
+				return key.open(payload)
+			}
+		}
+		return "", fmt.Errorf("ciphertext uses credentials key %s, which is not configured", id)
+	}
+	payload, ok := strings.CutPrefix(ciphertext, legacyEncryptedTokenPrefix)
+	if !ok {
 		// Backward-compatibility for previously stored plaintext rows.
 		return ciphertext, nil
 	}
-	payload := strings.TrimPrefix(ciphertext, encryptedTokenPrefix)
+	var err error
+	for _, key := range c.keys {
+		var plaintext string
+		if plaintext, err = key.open(payload); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", err
+}
+
+// Current reports whether ciphertext is already encrypted with the newest key.
+func (c *tokenCodec) Current(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, encryptedTokenPrefix+c.keys[0].id+":")
+}
+
+func (k codecKey) open(payload string) (string, error) {
 	raw, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		return "", fmt.Errorf("decode ciphertext: %w", err)
 	}
-	nonceSize := c.aead.NonceSize()
+	nonceSize := k.aead.NonceSize()
 	if len(raw) < nonceSize {
 		return "", errors.New("ciphertext too short")
 	}
 	nonce := raw[:nonceSize]
 	enc := raw[nonceSize:]
-	plaintext, err := c.aead.Open(nil, nonce, enc, nil)
+	plaintext, err := k.aead.Open(nil, nonce, enc, nil)
 	if err != nil {
 		return "", fmt.Errorf("decrypt ciphertext: %w", err)
 	}
 	return string(plaintext), nil
 }
+
+// Hash returns a keyed, deterministic digest of token for tokens that are
+// looked up by value and never decrypted, such as tenant API tokens. It uses
+// the newest key.
+func (c *tokenCodec) Hash(token string) string {
+	return c.keys[0].hash(token)
+}
+
+// Hashes returns the digest of token under every key, newest first, to find
+// tokens stored before the newest key was added.
+func (c *tokenCodec) Hashes(token string) []string {
+	hashes := make([]string, 0, len(c.keys))
+	for _, key := range c.keys {
+		hashes = append(hashes, key.hash(token))
+	}
+	return hashes
+}
+
+func (k codecKey) hash(token string) string {
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write([]byte(token))
+	return hashedTokenPrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestTokenCodecDecryptsUnderOlderKeys(t *testing.T) {
+	t.Parallel()
+
+	oldKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", 32)))
+	newKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("n", 32)))
+	old, err := newTokenCodec(oldKey)
+	if err != nil {
+		t.Fatalf("old codec: %v", err)
+	}
+	rotated, err := newTokenCodec(newKey + "," + oldKey)
+	if err != nil {
+		t.Fatalf("rotated codec: %v", err)
+	}
+
+	ciphertext, err := old.Encrypt("hcloud-token")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if plaintext, err := rotated.Decrypt(ciphertext); err != nil || plaintext != "hcloud-token" {
+		t.Fatalf("expected the old key to still decrypt, got %q (err %v)", plaintext, err)
+	}
+	if rotated.Current(ciphertext) || !old.Current(ciphertext) {
+		t.Fatalf("expected %q to be current only under the old key", ciphertext)
+	}
+
+	// Ciphertexts written before keys were tagged carry no key ID.
+	legacy := legacyEncryptedTokenPrefix + strings.SplitN(ciphertext, ":", 4)[3]
+	if plaintext, err := rotated.Decrypt(legacy); err != nil || plaintext != "hcloud-token" {
+		t.Fatalf("expected an untagged ciphertext to decrypt, got %q (err %v)", plaintext, err)
+	}
+
+	fresh, err := newTokenCodec(newKey)
+	if err != nil {
+		t.Fatalf("fresh codec: %v", err)
+	}
+	if _, err := fresh.Decrypt(ciphertext); err == nil {
+		t.Fatal("expected a ciphertext under a dropped key to fail")
+	}
+	if hashes := rotated.Hashes("tenant-token"); len(hashes) != 2 || hashes[1] != old.Hash("tenant-token") {
+		t.Fatalf("expected the old key's hash to be tried second, got %v", hashes)
+	}
+}
@@ -0,0 +1,87 @@
+// Package tracing wires OpenTelemetry into the proxy. Spans are exported over
+// OTLP/HTTP when the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variable is set; otherwise the global
+// tracer provider stays a no-op and tracing costs next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/eu-sovereign-cloud/secapi-proxy-hetzner"
+	serviceName         = "secapi-proxy-hetzner"
+)
+
+// Enabled reports whether the environment configures an OTLP trace endpoint.
+// OTEL_TRACES_EXPORTER=none turns tracing off even then.
+func Enabled() bool {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")), "none") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. The exporter reads the standard OTEL_EXPORTER_OTLP_* variables
+// and the resource OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES. The
+// returned shutdown flushes pending spans; without an endpoint it does
+// nothing.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp trace exporter: %w", err)
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(serviceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the proxy's tracer from the global provider, so spans
+// started before Setup simply are not recorded.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TraceID returns the ID of the sampled trace ctx belongs to, or "" when
+// there is none.
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() || !spanContext.IsSampled() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// Logf logs like log.Printf and appends the trace ID of ctx, if any, so log
+// lines can be matched to their trace.
+func Logf(ctx context.Context, format string, args ...any) {
+	if traceID := TraceID(ctx); traceID != "" {
+		log.Printf(format+" trace_id=%s", append(args, traceID)...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetupIsNoopWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if Enabled() {
+		t.Fatal("expected tracing to be off without an endpoint")
+	}
+	shutdown, err := Setup(context.Background())
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if id := TraceID(context.Background()); id != "" {
+		t.Fatalf("expected no trace ID, got %q", id)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if !Enabled() {
+		t.Fatal("expected tracing to be on with an endpoint")
+	}
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	if Enabled() {
+		t.Fatal("expected OTEL_TRACES_EXPORTER=none to turn tracing off")
+	}
+}